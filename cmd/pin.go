@@ -0,0 +1,85 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"ssl-pinning/internal/keys"
+)
+
+// pinCmd represents the pin command
+var pinCmd = &cobra.Command{
+	Use:   "pin FILE...",
+	Short: "Compute pins of PEM certificates or certificate requests",
+	Long: `Compute the SPKI pins (base64-encoded SHA-256 hashes of the public key) of PEM encoded
+certificates, certificate signing requests or public keys, e.g. of the next certificate of
+a domain before it is deployed. A FILE of "-" is read from standard input. The pins can be
+configured as backup_pins of the domain or staged with POST /admin/v1/domains/{fqdn}/pins.`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, file := range args {
+			var (
+				data []byte
+				err  error
+			)
+
+			if file == "-" {
+				data, err = io.ReadAll(cmd.InOrStdin())
+			} else {
+				data, err = os.ReadFile(file)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			pin, err := keys.ParsePin(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%s  %s\n", pin, file)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+}
@@ -49,6 +49,9 @@ import (
 
 	logger "gopkg.in/slog-handler.v1"
 
+	"ssl-pinning/internal/config"
+	"ssl-pinning/internal/logging"
+	"ssl-pinning/internal/server"
 	"ssl-pinning/internal/version"
 )
 
@@ -107,19 +110,124 @@ func initConfig() {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	viper.SetEnvPrefix(pkg)
 
+	config.BindEnv()
+
+	viper.SetDefault("alerts.expiry_days", []int{30, 14, 7})
+	viper.SetDefault("alerts.webhook.timeout", 5*time.Second)
+	viper.SetDefault("alerts.webhook.url", "")
+	viper.SetDefault("metrics.listen", "127.0.0.1:9090")
+	viper.SetDefault("metrics.otlp.enabled", false)
+	viper.SetDefault("metrics.otlp.endpoint", "")
+	viper.SetDefault("metrics.otlp.insecure", false)
+	viper.SetDefault("metrics.otlp.interval", time.Minute)
+	viper.SetDefault("metrics.path_prefix", "")
+	viper.SetDefault("metrics.pprof", false)
+	viper.SetDefault("metrics.pushgateway.enabled", false)
+	viper.SetDefault("metrics.pushgateway.instance", "")
+	viper.SetDefault("metrics.pushgateway.job", "ssl-pinning")
+	viper.SetDefault("metrics.pushgateway.timeout", 10*time.Second)
+	viper.SetDefault("metrics.pushgateway.url", "")
+	viper.SetDefault("metrics.statsd.enabled", false)
+	viper.SetDefault("metrics.statsd.host", "127.0.0.1")
+	viper.SetDefault("metrics.statsd.interval", 10*time.Second)
+	viper.SetDefault("metrics.statsd.port", 8125)
+	viper.SetDefault("metrics.statsd.prefix", "ssl_pinning.")
+	viper.SetDefault("reload.interval", time.Minute)
+	viper.SetDefault("reload.watch", true)
+	viper.SetDefault("server.access_log", true)
+	viper.SetDefault("server.admin_oidc.audience", "")
+	viper.SetDefault("server.admin_oidc.issuer", "")
+	viper.SetDefault("server.admin_oidc.jwks_url", "")
+	viper.SetDefault("server.admin_oidc.scopes", []string{})
+	viper.SetDefault("server.admin_token", "")
+	viper.SetDefault("server.api_keys", []map[string]string{})
+	viper.SetDefault("server.cache_max_age", 0)
+	viper.SetDefault("server.chaos.jitter", 0)
+	viper.SetDefault("server.chaos.latency", 0)
+	viper.SetDefault("server.compression.enabled", true)
+	viper.SetDefault("server.compression.min_size", 1024)
+	viper.SetDefault("server.cors.allowed_headers", []string{"Accept", "If-Modified-Since", "If-None-Match"})
+	viper.SetDefault("server.cors.allowed_methods", []string{"GET", "HEAD"})
+	viper.SetDefault("server.cors.allowed_origins", []string{})
+	viper.SetDefault("server.cors.exposed_headers", []string{"ETag"})
+	viper.SetDefault("server.cors.max_age", 10*time.Minute)
+	viper.SetDefault("server.drain_timeout", 10*time.Second)
+	viper.SetDefault("server.envelope", "legacy")
+	viper.SetDefault("server.handler_timeout", 0)
+	viper.SetDefault("server.health", false)
+	viper.SetDefault("server.http2.enabled", true)
+	viper.SetDefault("server.http2.h2c", false)
 	viper.SetDefault("server.listen", "127.0.0.1:7500")
+	viper.SetDefault("server.naming", "legacy")
+	viper.SetDefault("server.pin_encoding", "base64")
+	viper.SetDefault("server.rate_limit.burst", 10)
+	viper.SetDefault("server.rate_limit.rate", 0)
 	viper.SetDefault("server.read_timeout", 5*time.Second)
+	viper.SetDefault("server.route_timeouts", []map[string]any{})
+	viper.SetDefault("server.sandbox", false)
+	viper.SetDefault("server.security_headers", true)
+	viper.SetDefault("server.swagger_ui", false)
+	viper.SetDefault("server.tls.acme.cache_dir", fmt.Sprintf("%s/acme", configPath))
+	viper.SetDefault("server.tls.acme.directory_url", "")
+	viper.SetDefault("server.tls.acme.email", "")
+	viper.SetDefault("server.tls.acme.hosts", []string{})
+	viper.SetDefault("server.tls.acme.http_listen", "")
+	viper.SetDefault("server.tls.cert_file", "")
+	viper.SetDefault("server.tls.client_allowed_names", []string{})
+	viper.SetDefault("server.tls.client_ca_file", "")
+	viper.SetDefault("server.tls.reload_interval", time.Minute)
+	viper.SetDefault("server.tls.key_file", "")
 	viper.SetDefault("server.write_timeout", 5*time.Second)
+	viper.SetDefault("storage.cache.size", 1024)
+	viper.SetDefault("storage.cache.ttl", 0)
 	viper.SetDefault("storage.conn_max_idle_time", 5*time.Minute)
 	viper.SetDefault("storage.conn_max_lifetime", 30*time.Minute)
 	viper.SetDefault("storage.dsn", "")
 	viper.SetDefault("storage.dump_dir", "/tmp")
+	viper.SetDefault("storage.encryption.key", "")
+	viper.SetDefault("storage.encryption.key_file", "")
+	viper.SetDefault("storage.max_age", 0)
 	viper.SetDefault("storage.max_idle_conns", 5)
 	viper.SetDefault("storage.max_open_conns", 5)
 	viper.SetDefault("storage.type", "memory")
+	viper.SetDefault("tls.algorithm", "")
+	viper.SetDefault("tls.all_addresses", false)
+	viper.SetDefault("tls.ca_file", "")
+	viper.SetDefault("tls.ct.log_list", "")
+	viper.SetDefault("tls.ct.min_scts", 2)
+	viper.SetDefault("tls.dial_burst", 1)
+	viper.SetDefault("tls.dial_rate", 0)
 	viper.SetDefault("tls.dir", fmt.Sprintf("%s/tls", configPath))
 	viper.SetDefault("tls.dump_interval", 5*time.Second)
+	viper.SetDefault("tls.fetch_concurrency", 16)
+	viper.SetDefault("tls.fetch_interval", time.Minute)
+	viper.SetDefault("tls.kms.endpoint", "")
+	viper.SetDefault("tls.kms.key_id", "")
+	viper.SetDefault("tls.kms.region", "")
+	viper.SetDefault("tls.legacy_format", true)
+	viper.SetDefault("tls.maintenance", false)
+	viper.SetDefault("tls.ocsp.enabled", false)
+	viper.SetDefault("tls.ocsp.withhold", false)
+	viper.SetDefault("tls.passphrase", "")
+	viper.SetDefault("tls.passphrase_file", "")
+	viper.SetDefault("tls.pin_history", 1)
+	viper.SetDefault("tls.proxy", "")
+	viper.SetDefault("tls.resolvers", []string{})
+	viper.SetDefault("tls.rotation.cutover", time.Time{})
+	viper.SetDefault("tls.rotation.key_file", "")
+	viper.SetDefault("tls.signer", "")
+	viper.SetDefault("tls.skip_verify", false)
 	viper.SetDefault("tls.timeout", 5*time.Second)
+	viper.SetDefault("tls.tsa.url", "")
+	viper.SetDefault("tls.vault.address", "")
+	viper.SetDefault("tls.vault.key", "")
+	viper.SetDefault("tls.vault.mount", "transit")
+	viper.SetDefault("tls.vault.namespace", "")
+	viper.SetDefault("tls.vault.token_file", "")
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.endpoint", "")
+	viper.SetDefault("tracing.insecure", false)
+	viper.SetDefault("tracing.sample_ratio", 1.0)
 
 	if err := viper.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
 		slog.Error("failed to read the configuration file", "err", err)
@@ -137,11 +245,16 @@ func initConfig() {
 			},
 			AddSource: true,
 			Format:    viper.GetString("log.format"),
-			Level:     viper.GetString("log.level"),
+			Level:     "debug",
 			Pretty:    viper.GetBool("log.pretty"),
 		},
 	)
 
+	// the levels of log.packages are applied with the configuration (see application.New)
+	logging.SetLevels(logging.Levels{Level: logger.ParseLevel(viper.GetString("log.level"))})
+
+	slog.SetDefault(slog.New(server.NewLogHandler(logging.NewHandler(slog.Default().Handler()))))
+
 	color.NoColor = false
 
 	slog.Debug(fmt.Sprintf("using config file: %s", viper.ConfigFileUsed()))
@@ -0,0 +1,318 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"ssl-pinning/internal/server"
+	"ssl-pinning/internal/storage/types"
+)
+
+const (
+	// defaultPageLimit is the number of items of a v2 page requested without a limit.
+	defaultPageLimit = 100
+	// maxPageLimit is the largest limit accepted by the v2 API.
+	maxPageLimit = 1000
+)
+
+// Error codes of the v2 API, see types.APIError.
+const (
+	codeInternal         = "internal_error"
+	codeInvalidParameter = "invalid_parameter"
+	codeNotAcceptable    = "not_acceptable"
+	codeNotFound         = "not_found"
+	codeUnauthorized     = "unauthorized"
+)
+
+// writeAPIError writes a v2 error (see types.ErrorResponse) with the given status, code and message.
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	res := types.ErrorResponse{
+		Error: types.APIError{
+			Code:      code,
+			Message:   message,
+			RequestID: server.RequestID(r.Context()),
+			Status:    status,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// page is a requested page of a v2 list: the offset of its first item and its maximum size.
+type page struct {
+	limit  int
+	offset int
+}
+
+// parsePage reads the requested page from the limit and cursor query parameters.
+// The limit defaults to defaultPageLimit and the cursor, returned as next_cursor of the
+// previous page, to the first page.
+// Returns an error if the limit is out of range or the cursor is malformed.
+func parsePage(r *http.Request) (page, error) {
+	p := page{limit: defaultPageLimit}
+	query := r.URL.Query()
+
+	if query.Has("limit") {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page{}, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+
+		p.limit = limit
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return page{}, errors.New("malformed cursor")
+		}
+
+		offset, err := strconv.Atoi(string(raw))
+		if err != nil || offset < 0 {
+			return page{}, errors.New("malformed cursor")
+		}
+
+		p.offset = offset
+	}
+
+	return p, nil
+}
+
+// paginate returns the items of the requested page and its description. Cursors are the offset
+// of the next item, so pages may shift when items are added or removed between requests.
+func paginate[T any](items []T, p page) ([]T, types.Page) {
+	start := min(p.offset, len(items))
+	end := min(start+p.limit, len(items))
+
+	res := types.Page{
+		Limit: p.limit,
+		Total: len(items),
+	}
+
+	if end < len(items) {
+		res.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+	}
+
+	return items[start:end], res
+}
+
+// keyFilter selects keys of a file by the fqdn and expiring_before query parameters.
+// Keys match when their FQDN is one of fqdns, if any, and their certificate expires before
+// expiringBefore, if set; keys without a known expiry never match expiringBefore.
+type keyFilter struct {
+	expiringBefore time.Time
+	fqdns          []string
+}
+
+// parseKeyFilter reads the key filter of a request. The fqdn parameter may be repeated and
+// expiring_before is an RFC 3339 time.
+// Returns an error if expiring_before is malformed.
+func parseKeyFilter(r *http.Request) (keyFilter, error) {
+	query := r.URL.Query()
+
+	f := keyFilter{fqdns: query["fqdn"]}
+
+	if v := query.Get("expiring_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return keyFilter{}, fmt.Errorf("expiring_before must be an RFC 3339 time, got %q", v)
+		}
+
+		f.expiringBefore = t
+	}
+
+	return f, nil
+}
+
+// match reports whether a key is selected by the filter.
+func (f keyFilter) match(k types.DomainKey) bool {
+	if len(f.fqdns) > 0 && !slices.Contains(f.fqdns, k.Fqdn) {
+		return false
+	}
+
+	if !f.expiringBefore.IsZero() && (k.Expire == 0 || k.Expire >= f.expiringBefore.Unix()) {
+		return false
+	}
+
+	return true
+}
+
+// handleFilesV2 handles HTTP requests for listing published pin files.
+// It accepts GET requests to /api/v2/files and returns a page of the files known to storage,
+// sorted by name, with their key count and the time of the latest key update (see parsePage).
+// Errors are returned as types.ErrorResponse: 400 if the page is invalid or 500 if storage
+// cannot be queried.
+func (a *App) handleFilesV2(w http.ResponseWriter, r *http.Request) {
+	p, err := parsePage(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	store := types.WithContext(r.Context(), a.storage)
+
+	files, err := store.ListFiles()
+	if err != nil {
+		writeAPIError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	slices.Sort(files)
+
+	files, res := paginate(files, p)
+
+	infos, err := a.fileInfos(r.Context(), store, files)
+	if err != nil {
+		writeAPIError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(types.FilePage{Files: infos, Page: res}); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// handleFileV2 handles HTTP requests for the keys of a pin file.
+// It accepts GET requests to /api/v2/files/{file} and returns a page of the keys of the file
+// selected by the fqdn and expiring_before query parameters (see parseKeyFilter and parsePage),
+// sorted by expiry and FQDN and signed as a file of its own in the legacy envelope, so that
+// every page can be verified independently. Naming and pin encoding are negotiated as in v1.
+// Errors are returned as types.ErrorResponse: 400 if a parameter is invalid, 404 if the file
+// is not found, 406 if the naming is unknown, or 500 on internal errors.
+func (a *App) handleFileV2(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	if err := types.ValidateFile(file); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	naming, err := a.naming(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusNotAcceptable, codeNotAcceptable, err.Error())
+		return
+	}
+
+	encoding, err := a.pinEncoding(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	filter, err := parseKeyFilter(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var keys []types.DomainKey
+
+	if file == sandboxFile && a.config.Server.Sandbox {
+		keys = sandboxKeys()
+	} else {
+		stored, data, err := types.WithContext(r.Context(), a.storage).GetByFile(file)
+		if err == nil {
+			keys, err = fileKeys(stored, data)
+		}
+
+		if err != nil && !errors.Is(err, types.ErrNotFound) {
+			writeAPIError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+	}
+
+	if len(keys) == 0 {
+		writeAPIError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("file %s not found", file))
+		return
+	}
+
+	keys = slices.DeleteFunc(slices.Clone(keys), func(k types.DomainKey) bool {
+		return !filter.match(k)
+	})
+
+	slices.SortFunc(keys, func(x, y types.DomainKey) int {
+		return cmp.Or(cmp.Compare(x.Expire, y.Expire), cmp.Compare(x.Fqdn, y.Fqdn))
+	})
+
+	keys, res := paginate(keys, p)
+
+	if keys, err = types.EncodePins(keys, encoding); err != nil {
+		writeAPIError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	signed, err := a.sign(file, func() ([]byte, error) {
+		return types.SignedKeysWithNaming(file, keys, a.signer, naming)
+	})
+	if err != nil {
+		writeAPIError(w, r, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	contentType := "application/json"
+	if naming != types.NamingLegacy {
+		contentType = fmt.Sprintf("%s; naming=%s", contentType, naming)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+
+	if err := json.NewEncoder(w).Encode(types.KeyPage{File: signed, Page: res}); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// handleNotFoundV2 answers requests to unknown v2 routes with a types.ErrorResponse.
+func handleNotFoundV2(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, r, http.StatusNotFound, codeNotFound, fmt.Sprintf("%s %s not found", r.Method, r.URL.Path))
+}
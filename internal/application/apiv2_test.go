@@ -0,0 +1,281 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+	"ssl-pinning/pkg/verify"
+)
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    page
+		wantErr bool
+	}{
+		{name: "defaults", want: page{limit: defaultPageLimit}},
+		{name: "limit", query: "limit=5", want: page{limit: 5}},
+		{name: "cursor", query: "cursor=MTA", want: page{limit: defaultPageLimit, offset: 10}},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "limit too large", query: "limit=1001", wantErr: true},
+		{name: "malformed limit", query: "limit=ten", wantErr: true},
+		{name: "malformed cursor", query: "cursor=!", wantErr: true},
+		{name: "negative cursor", query: "cursor=LTE", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePage(httptest.NewRequest(http.MethodGet, "/api/v2/files?"+tt.query, nil))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestApp_handleFilesV2(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	storage := newMockStorage()
+	for _, file := range []string{"c.json", "a.json", "b.json"} {
+		storage.keys[file] = []types.DomainKey{{Date: &now, Fqdn: "www." + file, Key: "key"}}
+	}
+
+	app := &App{storage: storage}
+
+	var files []string
+
+	cursor := ""
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/api/v2/files?limit=2&cursor="+cursor, nil)
+		w := httptest.NewRecorder()
+
+		app.handleFilesV2(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var res types.FilePage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, 2, res.Page.Limit)
+		assert.Equal(t, 3, res.Page.Total)
+
+		for _, f := range res.Files {
+			files = append(files, f.File)
+		}
+
+		if cursor = res.Page.NextCursor; cursor == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"a.json", "b.json", "c.json"}, files)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/files?limit=0", nil)
+	w := httptest.NewRecorder()
+
+	app.handleFilesV2(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var res types.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, codeInvalidParameter, res.Error.Code)
+	assert.Equal(t, http.StatusBadRequest, res.Error.Status)
+}
+
+func TestApp_handleFileV2(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expire := func(d time.Duration) int64 { return now.Add(d).Unix() }
+
+	testSigner, _ := setupTestSigner(t)
+
+	tests := []struct {
+		name           string
+		file           string
+		query          string
+		accept         string
+		wantStatusCode int
+		wantCode       string
+		wantFqdns      []string
+		wantPage       types.Page
+	}{
+		{
+			name:           "all keys sorted by expiry",
+			file:           "a.json",
+			wantStatusCode: http.StatusOK,
+			wantFqdns:      []string{"c.example.com", "a.example.com", "b.example.com"},
+			wantPage:       types.Page{Limit: defaultPageLimit, Total: 3},
+		},
+		{
+			name:           "fqdn filter",
+			file:           "a.json",
+			query:          "fqdn=a.example.com&fqdn=b.example.com",
+			wantStatusCode: http.StatusOK,
+			wantFqdns:      []string{"a.example.com", "b.example.com"},
+			wantPage:       types.Page{Limit: defaultPageLimit, Total: 2},
+		},
+		{
+			name:           "expiring before",
+			file:           "a.json",
+			query:          "expiring_before=2025-01-05T00:00:00Z",
+			wantStatusCode: http.StatusOK,
+			wantFqdns:      []string{"c.example.com", "a.example.com"},
+			wantPage:       types.Page{Limit: defaultPageLimit, Total: 2},
+		},
+		{
+			name:           "first page",
+			file:           "a.json",
+			query:          "limit=1",
+			wantStatusCode: http.StatusOK,
+			wantFqdns:      []string{"c.example.com"},
+			wantPage:       types.Page{Limit: 1, NextCursor: "MQ", Total: 3},
+		},
+		{
+			name:           "last page",
+			file:           "a.json",
+			query:          "limit=2&cursor=Mg",
+			wantStatusCode: http.StatusOK,
+			wantFqdns:      []string{"b.example.com"},
+			wantPage:       types.Page{Limit: 2, Total: 3},
+		},
+		{
+			name:           "no match",
+			file:           "a.json",
+			query:          "fqdn=www.unknown.com",
+			wantStatusCode: http.StatusOK,
+			wantPage:       types.Page{Limit: defaultPageLimit},
+		},
+		{name: "file not found", file: "missing.json", wantStatusCode: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "invalid file", file: ".a.json", wantStatusCode: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "invalid expiring before", file: "a.json", query: "expiring_before=tomorrow", wantStatusCode: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "invalid pin encoding", file: "a.json", query: "pin_encoding=base32", wantStatusCode: http.StatusBadRequest, wantCode: codeInvalidParameter},
+		{name: "unknown naming", file: "a.json", accept: "application/json; naming=kebab", wantStatusCode: http.StatusNotAcceptable, wantCode: codeNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMockStorage()
+			storage.keys["a.json"] = []types.DomainKey{
+				{Date: &now, Expire: expire(72 * time.Hour), Fqdn: "a.example.com", Key: "key1"},
+				{Date: &now, Expire: expire(240 * time.Hour), Fqdn: "b.example.com", Key: "key2"},
+				{Date: &now, Expire: expire(24 * time.Hour), Fqdn: "c.example.com", Key: "key3"},
+			}
+
+			app := &App{storage: storage, signer: testSigner}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v2/files/"+tt.file+"?"+tt.query, nil)
+			req.SetPathValue("file", tt.file)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			app.handleFileV2(w, req)
+
+			require.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			if tt.wantCode != "" {
+				var res types.ErrorResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tt.wantCode, res.Error.Code)
+				assert.Equal(t, tt.wantStatusCode, res.Error.Status)
+				assert.NotEmpty(t, res.Error.Message)
+				return
+			}
+
+			var res types.KeyPage
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, tt.wantPage, res.Page)
+
+			if len(tt.wantFqdns) == 0 {
+				assert.Nil(t, res.File)
+				return
+			}
+
+			structure, err := types.ParseFileStructure(res.File)
+			require.NoError(t, err)
+
+			fqdns := make([]string, 0, len(structure.Payload.Keys))
+			for _, k := range structure.Payload.Keys {
+				fqdns = append(fqdns, k.Fqdn)
+			}
+			assert.Equal(t, tt.wantFqdns, fqdns)
+
+			verifier, err := verify.New(testSigner.PublicKeys()...)
+			require.NoError(t, err)
+
+			_, err = verifier.Verify(res.File)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestApp_authenticate_V2(t *testing.T) {
+	app := &App{apiKeys: []apiKey{{name: "ios", value: []byte("secret")}}, collector: new(metrics.Collector)}
+
+	h := app.authenticate(http.HandlerFunc(handleNotFoundV2))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/files", nil)
+	req.Pattern = "/api/v2/"
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var res types.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, codeUnauthorized, res.Error.Code)
+}
@@ -32,36 +32,76 @@ POSSIBILITY OF SUCH DAMAGE.
 package application
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
 
 	"ssl-pinning/internal/config"
 	"ssl-pinning/internal/keys"
+	"ssl-pinning/internal/logging"
 	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/notify"
+	"ssl-pinning/internal/oidc"
+	"ssl-pinning/internal/openapi"
 	"ssl-pinning/internal/server"
 	"ssl-pinning/internal/signer"
+	"ssl-pinning/internal/signer/gcpkms"
+	"ssl-pinning/internal/signer/kms"
+	"ssl-pinning/internal/signer/tsa"
+	"ssl-pinning/internal/signer/vault"
 	"ssl-pinning/internal/storage"
+	"ssl-pinning/internal/storage/cached"
+	"ssl-pinning/internal/storage/encrypted"
+	"ssl-pinning/internal/storage/instrumented"
+	"ssl-pinning/internal/storage/traced"
 	"ssl-pinning/internal/storage/types"
+	"ssl-pinning/internal/tracing"
+	"ssl-pinning/pkg/verify"
 )
 
 // App represents the main application structure that orchestrates all components
 // including HTTP servers, storage, cryptographic signer, and domain keys management.
 // It manages the application lifecycle from initialization to graceful shutdown.
 type App struct {
-	config        config.Config
-	keys          *keys.Keys
-	serverHttp    *server.Server
-	serverMetrics *server.Server
-	signer        *signer.Signer
-	storage       types.Storage
+	adminVerifier   *oidc.Verifier
+	apiKeys         []apiKey
+	catalogMu       sync.Mutex
+	collector       *metrics.Collector
+	config          config.Config
+	history         *history
+	keys            *keys.Keys
+	reloadMu        sync.Mutex
+	responses       *responses
+	serverHttp      *server.Server
+	serverMetrics   *server.Server
+	shutdownMetrics func(context.Context) error
+	shutdownStatsD  func(context.Context) error
+	shutdownTracing func(context.Context) error
+	signer          *signer.Signer
+	staleness       *types.Staleness
+	storage         types.Storage
+	stream          *stream
 }
 
 // New creates and initializes a new App instance with all required components.
@@ -82,118 +122,1003 @@ func New() (*App, error) {
 		return nil, err
 	}
 
-	signer, err := signer.NewSigner(
-		fmt.Sprintf("%s/prv.pem", cfg.TLS.Dir),
+	logging.SetLevels(configuredLogLevels(cfg.Log))
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		slog.Error("failed to set up tracing")
+		return nil, err
+	}
+
+	var passphrase []byte
+
+	if cfg.TLS.Passphrase != "" || cfg.TLS.PassphraseFile != "" {
+		if passphrase, err = signer.LoadPassphrase(cfg.TLS.Passphrase, cfg.TLS.PassphraseFile); err != nil {
+			slog.Error("failed to load private key passphrase")
+			return nil, err
+		}
+	}
+
+	signer, err := newSigner(ctx, cfg,
+		signer.WithAlgorithm(cfg.TLS.Algorithm),
+		signer.WithLegacyFormat(cfg.TLS.LegacyFormat),
+		signer.WithPassphrase(passphrase),
+		signer.WithRotation(cfg.TLS.Rotation.KeyFile, cfg.TLS.Rotation.Cutover),
 	)
 	if err != nil {
 		slog.Error("failed to create signer")
 		return nil, err
 	}
 
+	staleness := types.NewStaleness()
+
 	store, err := storage.New(ctx, cfg.Storage.Type,
 		types.WithAppID(cfg.UUID.String()),
 		types.WithConnMaxIdleTime(cfg.Storage.ConnMaxIdleTime),
 		types.WithConnMaxLifetime(cfg.Storage.ConnMaxLifetime),
 		types.WithDSN(cfg.Storage.DSN),
 		types.WithDumpDir(cfg.Storage.DumpDir),
+		types.WithMaxAge(cfg.Storage.MaxAge),
 		types.WithMaxIdleConns(cfg.Storage.MaxIdleConns),
 		types.WithMaxOpenConns(cfg.Storage.MaxOpenConns),
 		types.WithSigner(signer),
+		types.WithStaleness(staleness),
 	)
 	if err != nil {
 		slog.Error("failed to create storage")
 		return nil, err
 	}
 
+	if cfg.Storage.Encryption.Enabled() {
+		key, err := encrypted.LoadKey(cfg.Storage.Encryption.Key, cfg.Storage.Encryption.KeyFile)
+		if err != nil {
+			slog.Error("failed to load storage encryption key")
+			return nil, err
+		}
+
+		if store, err = encrypted.New(store, key); err != nil {
+			slog.Error("failed to create encrypted storage")
+			return nil, err
+		}
+	}
+
+	apiKeys, err := loadAPIKeys(cfg.Server.APIKeys)
+	if err != nil {
+		slog.Error("failed to load api keys")
+		return nil, err
+	}
+
+	adminVerifier, err := newAdminVerifier(cfg)
+	if err != nil {
+		slog.Error("failed to create admin oidc verifier")
+		return nil, err
+	}
+
 	collector := metrics.NewCollector()
 
-	k := keys.NewKeys(ctx, cfg.Keys,
-		keys.WithCollector(collector),
-		keys.WithDumpInterval(cfg.TLS.DumpInterval),
-		keys.WithFlushFunc(func(keys map[string]types.DomainKey) error {
-			slog.Debug("flushing keys to storage", "keys", keys)
+	var shutdownMetrics func(context.Context) error
+	if cfg.Metrics.OTLP.Enabled {
+		shutdownMetrics, err = metrics.SetupOTLP(ctx, prometheus.DefaultGatherer, metrics.OTLP{
+			Endpoint: cfg.Metrics.OTLP.Endpoint,
+			Insecure: cfg.Metrics.OTLP.Insecure,
+			Interval: cfg.Metrics.OTLP.Interval,
+		})
+		if err != nil {
+			slog.Error("failed to set up otlp metrics export")
+			return nil, err
+		}
+	}
 
-			store.SaveKeys(keys)
+	var shutdownStatsD func(context.Context) error
+	if s := cfg.Metrics.StatsD; s.Enabled {
+		shutdownStatsD, err = metrics.SetupStatsD(ctx, prometheus.DefaultGatherer, metrics.StatsD{
+			Addr:     net.JoinHostPort(s.Host, strconv.Itoa(s.Port)),
+			Prefix:   s.Prefix,
+			Interval: s.Interval,
+		})
+		if err != nil {
+			slog.Error("failed to set up statsd metrics push")
+			return nil, err
+		}
+	}
 
-			return nil
-		}),
-		keys.WithTimeout(cfg.TLS.Timeout),
-	)
+	if cfg.Tracing.Enabled {
+		store = traced.New(store, cfg.Storage.Type)
+	}
+
+	store = instrumented.New(store, cfg.Storage.Type, collector)
+
+	if cfg.Storage.Cache.TTL > 0 {
+		store = cached.New(store, cfg.Storage.Cache.TTL, cfg.Storage.Cache.Size)
+	}
+
+	var clientCAs *x509.CertPool
+
+	if cfg.Server.TLS.ClientCAFile != "" {
+		if clientCAs, err = server.LoadClientCAs(cfg.Server.TLS.ClientCAFile); err != nil {
+			slog.Error("failed to load client CAs")
+			return nil, err
+		}
+	}
 
 	srvHttp := server.NewServer(
+		server.WithAccessLog(cfg.Server.AccessLog),
+		server.WithACME(server.ACME{
+			CacheDir:     cfg.Server.TLS.ACME.CacheDir,
+			DirectoryURL: cfg.Server.TLS.ACME.DirectoryURL,
+			Email:        cfg.Server.TLS.ACME.Email,
+			Hosts:        cfg.Server.TLS.ACME.Hosts,
+			HTTPListen:   cfg.Server.TLS.ACME.HTTPListen,
+		}),
 		server.WithAddr(cfg.Server.Listen),
+		server.WithChaos(server.Chaos{Jitter: cfg.Server.Chaos.Jitter, Latency: cfg.Server.Chaos.Latency}),
+		server.WithClientAuth(clientCAs, cfg.Server.TLS.ClientAllowedNames),
+		server.WithCompression(cfg.Server.Compression.Enabled, cfg.Server.Compression.MinSize),
+		server.WithCORS(server.CORS{
+			AllowedHeaders: cfg.Server.CORS.AllowedHeaders,
+			AllowedMethods: cfg.Server.CORS.AllowedMethods,
+			AllowedOrigins: cfg.Server.CORS.AllowedOrigins,
+			ExposedHeaders: cfg.Server.CORS.ExposedHeaders,
+			MaxAge:         cfg.Server.CORS.MaxAge,
+		}),
+		server.WithDrainTimeout(cfg.Server.DrainTimeout),
+		server.WithHTTP2(cfg.Server.HTTP2.Enabled, cfg.Server.HTTP2.H2C),
+		server.WithMetrics(collector.HTTPObserver("api")),
+		server.WithRateLimit(server.RateLimit{
+			Burst: cfg.Server.RateLimit.Burst,
+			Key:   rateLimitKey(apiKeys),
+			Rate:  cfg.Server.RateLimit.Rate,
+		}),
 		server.WithReadTimeout(cfg.Server.ReadTimeout),
+		server.WithRecovery(func(*http.Request) { collector.IncPanic("api") }),
+		server.WithSecurityHeaders(cfg.Server.SecurityHeaders),
 		// server.WithStorage(store),
+		server.WithCertReload(cfg.Server.TLS.ReloadInterval),
+		server.WithTLS(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile),
+		server.WithTracing(cfg.Tracing.Enabled),
 		server.WithWriteTimeout(cfg.Server.WriteTimeout),
 	)
 
 	srvMetrics := server.NewServer(
-		server.WithAddr("127.0.0.1:9090"),
+		server.WithAddr(cfg.Metrics.Listen),
+		server.WithDrainTimeout(cfg.Server.DrainTimeout),
+		server.WithMetrics(collector.HTTPObserver("metrics")),
+		server.WithPathPrefix(cfg.Metrics.PathPrefix),
+		server.WithRecovery(func(*http.Request) { collector.IncPanic("metrics") }),
+		server.WithSecurityHeaders(cfg.Server.SecurityHeaders),
 	)
-	srvMetrics.SetHandle("/metrics", promhttp.Handler())
-	srvMetrics.SetHandleFunc("/", metrics.Root)
-	srvMetrics.SetHandleFunc("/health/liveness", store.ProbeLiveness())
-	srvMetrics.SetHandleFunc("/health/readiness", store.ProbeReadiness())
-	srvMetrics.SetHandleFunc("/health/startup", store.ProbeStartup())
+	srvMetrics.SetHandle("GET /metrics", promhttp.Handler())
+	srvMetrics.SetHandleFunc("GET /{$}", metrics.Landing(srvMetrics.Path("/metrics")))
+	srvMetrics.SetHandleFunc("GET /health/liveness", store.ProbeLiveness())
+	srvMetrics.SetHandleFunc("GET /health/startup", store.ProbeStartup())
 
 	app := &App{
-		config:        cfg,
-		keys:          k,
-		serverMetrics: srvMetrics,
-		serverHttp:    srvHttp,
-		signer:        signer,
-		storage:       store,
+		adminVerifier:   adminVerifier,
+		apiKeys:         apiKeys,
+		collector:       collector,
+		config:          cfg,
+		history:         newHistory(),
+		responses:       newResponses(),
+		serverMetrics:   srvMetrics,
+		serverHttp:      srvHttp,
+		shutdownMetrics: shutdownMetrics,
+		shutdownStatsD:  shutdownStatsD,
+		shutdownTracing: shutdownTracing,
+		signer:          signer,
+		staleness:       staleness,
+		storage:         store,
+		stream:          newStream(),
 	}
 
-	srvHttp.SetHandleFunc("/api/v1/{file}", app.handleFileJSON)
+	var rootCAs *x509.CertPool
+
+	if cfg.TLS.CAFile != "" {
+		if rootCAs, err = keys.LoadRootCAs(cfg.TLS.CAFile); err != nil {
+			slog.Error("failed to load root CAs")
+			return nil, err
+		}
+	}
+
+	if cfg.TLS.SkipVerify {
+		slog.Warn("certificate verification of domains is disabled")
+	}
+
+	var ctLogs keys.CTLogs
+
+	if cfg.TLS.CT.LogList != "" {
+		if ctLogs, err = keys.LoadCTLogs(cfg.TLS.CT.LogList); err != nil {
+			slog.Error("failed to load ct log list")
+			return nil, err
+		}
+	}
+
+	webhook, err := newWebhook(cfg)
+	if err != nil {
+		slog.Error("failed to create alerts webhook")
+		return nil, err
+	}
+
+	app.keys = keys.NewKeys(ctx, cfg.Keys,
+		keys.WithAllAddresses(cfg.TLS.AllAddresses),
+		keys.WithCollector(collector),
+		keys.WithConcurrency(cfg.TLS.FetchConcurrency),
+		keys.WithCTLogs(ctLogs),
+		keys.WithDeleteFunc(app.deleteKeys),
+		keys.WithDialRate(cfg.TLS.DialRate, cfg.TLS.DialBurst),
+		keys.WithDumpInterval(cfg.TLS.DumpInterval),
+		keys.WithExpiryFunc(webhookFunc[keys.ExpiryEvent](ctx, webhook)),
+		keys.WithExpiryThresholds(cfg.Alerts.ExpiryDays),
+		keys.WithFetchInterval(cfg.TLS.FetchInterval),
+		keys.WithFlushFunc(app.flush),
+		keys.WithMinSCTs(cfg.TLS.CT.MinSCTs),
+		keys.WithOCSP(cfg.TLS.OCSP.Enabled),
+		keys.WithOCSPWithhold(cfg.TLS.OCSP.Withhold),
+		keys.WithPaused(cfg.TLS.Maintenance),
+		keys.WithPinChangeFunc(webhookFunc[keys.PinChange](ctx, webhook)),
+		keys.WithPinHistory(cfg.TLS.PinHistory),
+		keys.WithProxy(cfg.TLS.Proxy),
+		keys.WithResolvers(cfg.TLS.Resolvers),
+		keys.WithRootCAs(rootCAs),
+		keys.WithSkipVerify(cfg.TLS.SkipVerify),
+		keys.WithTimeout(cfg.TLS.Timeout),
+	)
+
+	catalog, err := app.loadCatalog(store)
+	if err != nil {
+		slog.Error("failed to load catalog")
+		return nil, err
+	}
+
+	for _, key := range catalogKeys(catalog, cfg.Keys) {
+		app.keys.AddKey(key.Fqdn, &key)
+	}
+
+	if since, paused := app.keys.Paused(); paused {
+		slog.Warn("starting in maintenance mode, certificates are not fetched")
+
+		staleness.Pause(since)
+	}
+
+	timeouts, err := newRouteTimeouts(cfg.Server)
+	if err != nil {
+		slog.Error("failed to configure handler timeouts")
+		return nil, err
+	}
+
+	// handle registers a route of the public API with its handler timeout
+	handle := func(pattern string, h http.HandlerFunc) {
+		srvHttp.SetHandleFunc(pattern, timeouts.wrap(pattern, h))
+	}
+
+	srvHttp.Use(app.authenticate)
+
+	handle("GET /api/v1/domains/{fqdn}", app.handleDomain)
+	handle("GET /api/v1/domains/{fqdn}/cert", app.handleDomainCert)
+	handle("GET /api/v1/files", app.handleFiles)
+	handle("GET /api/v1/openapi.json", openapi.HandleDocument)
+	handle("GET /api/v1/schema.json", openapi.HandleSchema)
+	handle("GET /api/v1/stream", app.handleStream)
+	handle("GET /api/v1/subscribe", app.handleSubscribe)
+	handle("POST /api/v1/verify", app.handleVerify)
+	handle("GET /api/v1/{file}", app.handleFileJSON)
+	handle("GET /api/v1/{file}/{view}", app.handleFileView)
+	handle("GET /api/v2/files", app.handleFilesV2)
+	handle("GET /api/v2/files/{file}", app.handleFileV2)
+	handle("/api/v2/", handleNotFoundV2)
+	handle("GET /openapi.json", openapi.HandleDocument)
+	handle("GET /version", handleVersion)
+
+	if cfg.Server.SwaggerUI {
+		handle("GET /docs", openapi.HandleSwaggerUI)
+	}
+
+	// for load balancers that cannot reach the metrics server
+	if cfg.Server.Health {
+		handle("GET /health/liveness", store.ProbeLiveness())
+		handle("GET /health/readiness", app.probeReadiness(store.ProbeReadiness()))
+		handle("GET /health/startup", store.ProbeStartup())
+	}
+
+	if unknown := timeouts.unknown(); len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown routes in server.route_timeouts: %s", strings.Join(unknown, ", "))
+	}
+
+	srvMetrics.SetHandleFunc("POST /admin/v1/domains", app.authorize(app.handleAddDomain))
+	srvMetrics.SetHandleFunc("DELETE /admin/v1/domains/{fqdn}", app.authorize(app.handleRemoveDomain))
+	srvMetrics.SetHandleFunc("POST /admin/v1/domains/{fqdn}/pins", app.authorize(app.handleStagePin))
+	srvMetrics.SetHandleFunc("GET /admin/v1/domains/{fqdn}/status", app.authorize(app.handleDomainStatus))
+	srvMetrics.SetHandleFunc("GET /admin/v1/files", app.authorize(app.handleCatalogFiles))
+	srvMetrics.SetHandleFunc("POST /admin/v1/files", app.authorize(app.handleCreateFile))
+	srvMetrics.SetHandleFunc("GET /admin/v1/files/{file}", app.authorize(app.handleCatalogFile))
+	srvMetrics.SetHandleFunc("DELETE /admin/v1/files/{file}", app.authorize(app.handleDeleteFile))
+	srvMetrics.SetHandleFunc("PUT /admin/v1/files/{file}/domains/{fqdn}", app.authorize(app.handleAssignDomain))
+	srvMetrics.SetHandleFunc("DELETE /admin/v1/files/{file}/domains/{fqdn}", app.authorize(app.handleUnassignDomain))
+	srvMetrics.SetHandleFunc("DELETE /admin/v1/files/{file}/keys/{fqdn}", app.authorize(app.handleDeleteKeys))
+	srvMetrics.SetHandleFunc("GET /admin/v1/loglevel", app.authorize(app.handleLogLevel))
+	srvMetrics.SetHandleFunc("PUT /admin/v1/loglevel", app.authorize(app.handleLogLevel))
+	srvMetrics.SetHandleFunc("DELETE /admin/v1/loglevel", app.authorize(app.handleLogLevel))
+	srvMetrics.SetHandleFunc("GET /admin/v1/maintenance", app.authorize(app.handleMaintenance))
+	srvMetrics.SetHandleFunc("PUT /admin/v1/maintenance", app.authorize(app.handleMaintenance))
+	srvMetrics.SetHandleFunc("DELETE /admin/v1/maintenance", app.authorize(app.handleMaintenance))
+	srvMetrics.SetHandleFunc("GET /admin/v1/pin-changes", app.authorize(app.handlePinChanges))
+	srvMetrics.SetHandleFunc("GET /health/readiness", app.probeReadiness(store.ProbeReadiness()))
+	srvMetrics.SetHandleFunc("GET /health/status", app.handleStatus)
+	srvMetrics.SetHandleFunc("GET /version", handleVersion)
+
+	if cfg.Metrics.Pprof {
+		slog.Warn("serving runtime profiles", "addr", cfg.Metrics.Listen, "path", srvMetrics.Path("/debug/pprof/"))
+		app.registerPprof(srvMetrics.SetHandleFunc)
+	}
 
 	return app, nil
 }
 
+// newWebhook creates the webhook of alerts.webhook.url notified of certificates crossing the
+// expiry thresholds of alerts.expiry_days and of pin changes. It returns nil when no webhook is
+// configured, alerts are then only logged and exposed as metrics.
+func newWebhook(cfg config.Config) (*notify.Webhook, error) {
+	if cfg.Alerts.Webhook.URL == "" {
+		return nil, nil
+	}
+
+	webhook, err := notify.New(cfg.Alerts.Webhook.URL, notify.WithTimeout(cfg.Alerts.Webhook.Timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("sending alerts to webhook", "expiry_days", cfg.Alerts.ExpiryDays)
+
+	return webhook, nil
+}
+
+// webhookFunc returns a function posting events to a webhook, or nil when webhook is nil.
+func webhookFunc[T any](ctx context.Context, webhook *notify.Webhook) func(T) error {
+	if webhook == nil {
+		return nil
+	}
+
+	return func(ev T) error {
+		return webhook.Send(ctx, ev)
+	}
+}
+
+// newSigner creates the signer of published files. It signs with the Google Cloud KMS key
+// of tls.signer, the AWS KMS key of tls.kms.key_id or the Vault transit key of tls.vault.key
+// when configured and with the private key tls.dir/prv.pem otherwise. Signatures are
+// timestamped by the TSA of tls.tsa.url when configured.
+func newSigner(ctx context.Context, cfg config.Config, opts ...signer.Option) (*signer.Signer, error) {
+	if cfg.TLS.TSA.URL != "" {
+		client, err := tsa.New(cfg.TLS.TSA.URL, tsa.WithTimeout(cfg.TLS.Timeout))
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("timestamping signatures", "tsa", cfg.TLS.TSA.URL)
+
+		opts = append(opts, signer.WithTimestamper(client))
+	}
+
+	if cfg.TLS.Vault.Key != "" {
+		key, err := vault.New(ctx, cfg.TLS.Vault.Key,
+			vault.WithAddress(cfg.TLS.Vault.Address),
+			vault.WithMount(cfg.TLS.Vault.Mount),
+			vault.WithNamespace(cfg.TLS.Vault.Namespace),
+			vault.WithTimeout(cfg.TLS.Timeout),
+			vault.WithTokenFile(cfg.TLS.Vault.TokenFile),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("signing with vault transit key", "mount", cfg.TLS.Vault.Mount, "key", cfg.TLS.Vault.Key)
+
+		return signer.NewKeySigner(key, opts...)
+	}
+
+	if cfg.TLS.Signer != "" {
+		key, err := gcpkms.New(ctx, cfg.TLS.Signer, gcpkms.WithTimeout(cfg.TLS.Timeout))
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("signing with google cloud kms key", "signer", cfg.TLS.Signer)
+
+		return signer.NewKeySigner(key, opts...)
+	}
+
+	if cfg.TLS.KMS.KeyID == "" {
+		return signer.NewSigner(fmt.Sprintf("%s/prv.pem", cfg.TLS.Dir), opts...)
+	}
+
+	key, err := kms.New(ctx, cfg.TLS.KMS.KeyID,
+		kms.WithEndpoint(cfg.TLS.KMS.Endpoint),
+		kms.WithRegion(cfg.TLS.KMS.Region),
+		kms.WithTimeout(cfg.TLS.Timeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("signing with aws kms key", "key_id", cfg.TLS.KMS.KeyID)
+
+	return signer.NewKeySigner(key, opts...)
+}
+
 // handleFileJSON handles HTTP requests for retrieving domain keys by filename.
 // It accepts GET requests to /api/v1/{file}, retrieves corresponding domain keys
-// from storage, signs them if multiple keys are found, and returns JSON response.
-// Returns 400 if filename is missing, 404 if file not found, or 500 on internal errors.
+// from storage, signs them (see signFile), and returns JSON response.
+// The payload field naming and the envelope (legacy JSON or JWS) are negotiated via
+// the Accept header (see naming and envelope) and the pin encoding via the pin_encoding
+// query parameter (see pinEncoding).
+// The built-in sandbox file (see sandboxKeys) is served without a storage lookup when enabled
+// and cached like any other file.
+// Requests with the format query parameter are served the file as client configuration
+// (see handleFileFormat).
+// Responses carry a strong ETag of the payload (see etag), the latest update of its keys as
+// Last-Modified and a Cache-Control header (see cacheControl); requests with a matching
+// If-None-Match or If-Modified-Since are answered with 304 Not Modified.
+// Rendered responses are cached until the keys change (see response).
+// Returns 400 if filename is missing or invalid or the pin encoding is unknown, 404 if file not found, or 500 on internal errors.
 func (a *App) handleFileJSON(w http.ResponseWriter, r *http.Request) {
-	time.Sleep(time.Second * 3)
 	file := r.PathValue("file")
 	if file == "" {
 		http.Error(w, "file required", http.StatusBadRequest)
 		return
 	}
 
-	slog.Debug("request", "req", r.URL.Path, "file", file)
+	if err := types.ValidateFile(file); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Has("format") {
+		a.handleFileFormat(w, r, file)
+		return
+	}
 
-	keys, data, err := a.storage.GetByFile(file)
+	naming, err := a.naming(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	envelope := a.envelope(r)
+
+	encoding, err := a.pinEncoding(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.DebugContext(r.Context(), "request", "req", r.URL.Path, "file", file, "naming", naming, "envelope", envelope, "pin_encoding", encoding)
+
+	res, err := a.response(r.Context(), responseKey{file: file, naming: naming, envelope: envelope, encoding: encoding})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if len(keys) > 1 {
+	if res != nil {
+		w.Header().Set("Cache-Control", a.cacheControl())
+		w.Header().Set("Content-Type", res.contentType)
+		w.Header().Set("ETag", res.etag)
+		w.Header().Add("Vary", "Accept")
+
+		// answers conditional requests (If-None-Match, If-Modified-Since) with 304
+		http.ServeContent(w, r, file, res.modified, bytes.NewReader(res.data))
+		return
+	}
+
+	slog.ErrorContext(r.Context(), "file not found", "file", file)
+
+	http.Error(w, fmt.Sprintf("file %s not found", file), http.StatusNotFound)
+}
+
+// payload renders the signed payload of a file with the requested naming, envelope and pin
+// encoding and returns it with the time its keys were last updated (see lastModified).
+// Payloads are rendered on every call; requests are served from the response cache (see response).
+func (a *App) payload(ctx context.Context, file string, naming types.Naming, envelope types.Envelope, encoding types.PinEncoding) ([]byte, time.Time, error) {
+	if file == sandboxFile && a.config.Server.Sandbox {
+		data, err := a.signFile(file, sandboxKeys(), nil, naming, envelope, encoding)
+		return data, sandboxDate, err
+	}
+
+	keys, data, err := types.WithContext(ctx, a.storage).GetByFile(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	modified, err := lastModified(keys, data)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if data, err = a.signFile(file, keys, data, naming, envelope, encoding); err != nil || data == nil {
+		return nil, time.Time{}, err
+	}
+
+	return data, modified, nil
+}
+
+// lastModified returns the latest update time of the keys of a file, read from the keys and
+// the pre-signed data returned by storage (see fileKeys), or the zero time if none has one.
+func lastModified(keys []types.DomainKey, data []byte) (time.Time, error) {
+	keys, err := fileKeys(keys, data)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var modified time.Time
+
+	for _, k := range keys {
+		if k.Date != nil && k.Date.After(modified) {
+			modified = *k.Date
+		}
+	}
+
+	return modified, nil
+}
+
+// flush persists the domain keys to storage, invalidates the response cache and pre-renders the
+// response of every file with the configured naming, envelope and pin encoding, so that requests
+// are served without signing (see response). Responses with other namings, envelopes or pin
+// encodings are rendered on their first request. Keys skipped by storage, e.g. of domains that
+// could not be fetched, do not fail the flush (see types.ErrPartialSave).
+// The keys of every file are recorded in the history and files whose pin set changed are
+// published to the subscribers of the stream (see observe).
+func (a *App) flush(keys map[string]types.DomainKey) error {
+	slog.Debug("flushing keys to storage", "keys", keys)
+
+	err := a.storage.SaveKeys(keys)
+
+	// storage may hold some of the keys even if saving failed
+	a.responses.invalidate()
+	a.observe()
+
+	if errors.Is(err, types.ErrPartialSave) {
+		slog.Warn("some keys were not flushed", "error", err)
+	} else if err != nil {
+		return err
+	}
+
+	// without a response cache there is nothing to pre-render
+	if a.responses == nil {
+		return nil
+	}
+
+	files, err := a.storage.ListFiles()
+	if err != nil {
+		slog.Error("failed to list files for pre-signing", "error", err)
+		return nil
+	}
+
+	naming, err := types.ParseNaming(string(a.config.Server.Naming))
+	if err != nil {
+		slog.Error("failed to pre-sign files", "error", err)
+		return nil
+	}
+
+	envelope := a.config.Server.Envelope
+	if envelope == "" {
+		envelope = types.EnvelopeLegacy
+	}
+
+	encoding, err := types.ParsePinEncoding(string(a.config.Server.PinEncoding))
+	if err != nil {
+		slog.Error("failed to pre-sign files", "error", err)
+		return nil
+	}
+
+	for _, file := range files {
+		if _, err := a.response(context.Background(), responseKey{file: file, naming: naming, envelope: envelope, encoding: encoding}); err != nil {
+			slog.Error("failed to pre-sign file", "file", file, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// deleteKeys deletes the stored keys of a domain from a file, e.g. of a domain whose pins
+// are withheld. Keys that are not stored are ignored.
+func (a *App) deleteKeys(file, fqdn string) error {
+	defer a.responses.invalidate()
+
+	if err := a.storage.DeleteKeys(file, fqdn); err != nil && !errors.Is(err, types.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// signFile renders the payload of a file with the requested naming, envelope and pin encoding
+// from the keys and the pre-signed data returned by storage. Legacy payloads with base64 pins
+// are returned as stored if storage keeps pre-signed data, otherwise signed from the keys.
+// Returns nil if there is nothing to serve.
+func (a *App) signFile(file string, keys []types.DomainKey, data []byte, naming types.Naming, envelope types.Envelope, encoding types.PinEncoding) ([]byte, error) {
+	if naming != types.NamingLegacy || envelope != types.EnvelopeLegacy || encoding != types.PinEncodingBase64 {
+		keys, err := fileKeys(keys, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if keys, err = types.EncodePins(keys, encoding); err != nil {
+			return nil, err
+		}
+
+		return a.sign(file, func() ([]byte, error) {
+			return types.SignedKeysWithEnvelope(file, keys, a.signer, naming, envelope)
+		})
+	}
+
+	if len(keys) > 0 && data == nil {
 		slog.Debug("found keys", "file", file, "keys", keys)
-		res, err := types.SignedKeys(file, keys, a.signer)
+		return a.sign(file, func() ([]byte, error) {
+			return types.SignedKeys(file, keys, a.signer)
+		})
+	}
+
+	return data, nil
+}
+
+// sign calls signFunc to sign a payload of file and records the duration and outcome
+// of the signing with the algorithm of the signer.
+func (a *App) sign(file string, signFunc func() ([]byte, error)) ([]byte, error) {
+	start := time.Now()
+	data, err := signFunc()
+
+	if a.collector != nil && a.signer != nil {
+		a.collector.ObserveSign(file, a.signer.Algorithm(), time.Since(start), err)
+	}
+
+	return data, err
+}
+
+// handleFiles handles HTTP requests for listing published pin files.
+// It accepts GET requests to /api/v1/files and returns every file known to storage
+// with its key count and the time of the latest key update.
+// Returns 500 if storage cannot be queried.
+func (a *App) handleFiles(w http.ResponseWriter, r *http.Request) {
+	store := types.WithContext(r.Context(), a.storage)
+
+	files, err := store.ListFiles()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	infos, err := a.fileInfos(r.Context(), store, files)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(types.FileList{Files: infos}); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// fileInfos returns the key count and the time of the latest key update of the files.
+// Files that cannot be parsed are logged and skipped.
+func (a *App) fileInfos(ctx context.Context, store types.Storage, files []string) ([]types.FileInfo, error) {
+	infos := make([]types.FileInfo, 0, len(files))
+
+	for _, file := range files {
+		keys, data, err := store.GetByFile(file)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, err
+		}
+
+		if keys, err = fileKeys(keys, data); err != nil {
+			slog.ErrorContext(ctx, "failed to parse file", "file", file, "error", err)
+			continue
+		}
+
+		infos = append(infos, fileInfo(file, keys))
+	}
+
+	return infos, nil
+}
+
+// fileInfo returns the key count and the time of the latest key update of a file.
+func fileInfo(file string, keys []types.DomainKey) types.FileInfo {
+	info := types.FileInfo{
+		File: file,
+		Keys: len(keys),
+	}
+
+	for _, k := range keys {
+		if k.Date != nil && (info.UpdatedAt == nil || k.Date.After(*info.UpdatedAt)) {
+			info.UpdatedAt = k.Date
+		}
+	}
+
+	return info
+}
+
+// handleDomain handles HTTP requests for the current keys of a single host.
+// It accepts GET requests to /api/v1/domains/{fqdn} and returns the pin, expiry and last error
+// of the FQDN for every file it is published in, without signature.
+// Returns 400 if fqdn is missing, 404 if the FQDN is unknown, or 500 on internal errors.
+func (a *App) handleDomain(w http.ResponseWriter, r *http.Request) {
+	fqdn := r.PathValue("fqdn")
+	if fqdn == "" {
+		http.Error(w, "fqdn required", http.StatusBadRequest)
+		return
+	}
+
+	keys, err := types.WithContext(r.Context(), a.storage).GetByFqdn(fqdn)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("domain %s not found", fqdn), http.StatusNotFound)
 			return
 		}
 
-		data = res
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(types.FileKeys{Keys: keys}); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
 	}
+}
 
-	if data != nil {
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write(data)
+// handleDomainCert handles HTTP requests for the metadata of the certificate of a single host.
+// It accepts GET requests to /api/v1/domains/{fqdn}/cert and returns the issuer, subject, serial
+// number, validity and subject alternative names of the certificate captured at the latest
+// successful fetch of the FQDN by this instance.
+// Returns 400 if fqdn is missing or 404 if the FQDN is not monitored or not fetched yet.
+func (a *App) handleDomainCert(w http.ResponseWriter, r *http.Request) {
+	fqdn := r.PathValue("fqdn")
+	if fqdn == "" {
+		http.Error(w, "fqdn required", http.StatusBadRequest)
 		return
 	}
 
-	slog.Error("file not found", "file", file, "keys_found", len(keys), "data_len", len(data))
+	key, ok := a.keys.Get(fqdn)
+	if !ok || key.Cert == nil {
+		http.Error(w, fmt.Sprintf("certificate of domain %s not found", fqdn), http.StatusNotFound)
+		return
+	}
 
-	http.Error(w, fmt.Sprintf("file %s not found", file), http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(key.Cert); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// handleVerify handles HTTP requests for verifying a signed file.
+// It accepts POST requests to /api/v1/verify with a signed file in the legacy envelope as body
+// and verifies its signatures with the public keys of the signer (see verify.Verifier).
+// Returns 200 with the key ID, algorithm and signing time of the matching signature if the file
+// is valid, 400 if it is not a signed file, 413 if it exceeds types.MaxFileSize, 422 if no
+// signature matches a key of this service, or 500 on internal errors.
+func (a *App) handleVerify(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, types.MaxFileSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("file too large, limit is %d bytes", types.MaxFileSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	verifier, err := verify.New(a.signer.PublicKeys()...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+
+	res, err := verifier.Verify(data)
+	if err != nil {
+		res = verify.Verification{Error: err.Error()}
+
+		status = http.StatusUnprocessableEntity
+		if errors.Is(err, verify.ErrMalformed) {
+			status = http.StatusBadRequest
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// fileKeys returns the domain keys of a file as returned by storage.
+// Backends that store pre-signed files may return raw data only, in which case the keys
+// are taken from the payload of the signed structure.
+func fileKeys(keys []types.DomainKey, data []byte) ([]types.DomainKey, error) {
+	if len(keys) > 0 || data == nil {
+		return keys, nil
+	}
+
+	structure, err := types.ParseFileStructure(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return structure.Payload.Keys, nil
+}
+
+// loadKeys returns the keys of a file from storage, parsed from its pre-signed payload if the
+// storage keeps no keys (see fileKeys), or the sandbox keys if the file is the sandbox.
+// Returns no keys and no error if the file is not found.
+func (a *App) loadKeys(ctx context.Context, file string) ([]types.DomainKey, error) {
+	if file == sandboxFile && a.config.Server.Sandbox {
+		return sandboxKeys(), nil
+	}
+
+	keys, data, err := types.WithContext(ctx, a.storage).GetByFile(file)
+	if err == nil {
+		keys, err = fileKeys(keys, data)
+	}
+
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, nil
+	}
+
+	return keys, err
+}
+
+// handleDeleteKeys handles admin requests for removing a decommissioned FQDN from a file.
+// It accepts DELETE requests to /admin/v1/files/{file}/keys/{fqdn} and deletes the keys
+// from storage for all application instances. Keys of domains that are still configured
+// are written again by the next flush.
+// Returns 204 on success, 400 if a path parameter is missing or invalid, 404 if nothing was deleted,
+// or 500 on internal errors.
+func (a *App) handleDeleteKeys(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	fqdn := r.PathValue("fqdn")
+
+	if file == "" || fqdn == "" {
+		http.Error(w, "file and fqdn required", http.StatusBadRequest)
+		return
+	}
+
+	if err := types.ValidateFile(file); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defer a.responses.invalidate()
+
+	if err := types.WithContext(r.Context(), a.storage).DeleteKeys(file, fqdn); err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "failed to delete keys", "file", file, "fqdn", fqdn, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "keys deleted", "file", file, "fqdn", fqdn)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// etag returns the strong entity tag of a payload, the hex encoded SHA-256 hash of its bytes.
+func etag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, sha256.Sum256(data))
+}
+
+// cacheControl returns the Cache-Control header of signed files: public with the max-age of
+// server.cache_max_age, or no-cache, so clients revalidate with the ETag on every request, when unset.
+func (a *App) cacheControl() string {
+	if maxAge := a.config.Server.CacheMaxAge; maxAge > 0 {
+		return fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	}
+
+	return "no-cache"
+}
+
+// naming resolves the payload field naming for a request.
+// A "naming" parameter on any Accept media range (e.g. "application/json; naming=snake_case")
+// takes precedence over the server.naming configuration value.
+// Returns an error if the requested naming is unknown.
+func (a *App) naming(r *http.Request) (types.Naming, error) {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+
+		if v, ok := params["naming"]; ok {
+			return types.ParseNaming(v)
+		}
+	}
+
+	return types.ParseNaming(string(a.config.Server.Naming))
+}
+
+// envelope resolves the envelope of a signed file for a request.
+// The first Accept media range naming a supported envelope ("application/json",
+// "application/jose", "application/jose+json" or "application/cose") selects it,
+// otherwise the server.envelope configuration value is used.
+func (a *App) envelope(r *http.Request) types.Envelope {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+
+		for _, e := range []types.Envelope{types.EnvelopeLegacy, types.EnvelopeJWS, types.EnvelopeJWSJSON, types.EnvelopeCOSE} {
+			if mediaType == e.MediaType() {
+				return e
+			}
+		}
+	}
+
+	if a.config.Server.Envelope == "" {
+		return types.EnvelopeLegacy
+	}
+
+	return a.config.Server.Envelope
+}
+
+// pinEncoding resolves the pin encoding of a signed file for a request.
+// The pin_encoding query parameter (e.g. "?pin_encoding=hex") takes precedence over the
+// server.pin_encoding configuration value.
+// Returns an error if the requested encoding is unknown.
+func (a *App) pinEncoding(r *http.Request) (types.PinEncoding, error) {
+	if r.URL.Query().Has("pin_encoding") {
+		return types.ParsePinEncoding(r.URL.Query().Get("pin_encoding"))
+	}
+
+	return types.ParsePinEncoding(string(a.config.Server.PinEncoding))
+}
+
+// reload re-reads the configuration file and the pin catalogue and applies their domains
+// without a restart (see keys.Keys.Reconcile). The keys of removed domains are deleted from
+// storage for all application instances. Other settings require a restart.
+// Reloads on SIGHUP and on changes of the file (see watchConfig) run one at a time.
+func (a *App) reload() error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	catalog, err := a.loadCatalog(a.storage)
+	if err != nil {
+		return fmt.Errorf("failed to load the catalog: %w", err)
+	}
+
+	want := append(slices.Clone(cfg.Keys), catalogKeys(catalog, cfg.Keys)...)
+
+	removed := a.keys.Reconcile(want)
+
+	for _, key := range removed {
+		if err := a.deleteKeys(key.File, key.Fqdn); err != nil {
+			slog.Error("failed to delete keys", "file", key.File, "fqdn", key.Fqdn, "error", err)
+		}
+	}
+
+	slog.Info("configuration reloaded", "keys", len(want), "removed", len(removed))
+
+	return nil
 }
 
 // Up starts the application and all its components in separate goroutines.
 // It launches metrics server, main HTTP server, and periodic domain keys persistence to storage.
+// SIGHUP and, with reload.watch, changes of the configuration file reload the domain keys of the
+// configuration file (see reload and watchConfig).
 // Blocks until context is cancelled (via signal or timeout), then triggers graceful shutdown.
 func (a *App) Up() {
 	slog.Info("starting application",
@@ -201,19 +1126,34 @@ func (a *App) Up() {
 		"app_id", a.config.UUID.String(),
 	)
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	go a.keys.StartPeriodicFlush()
 	go a.serverMetrics.Up()
 	go a.serverHttp.Up()
 
+	if file := viper.ConfigFileUsed(); a.config.Reload.Watch && file != "" {
+		go a.watchConfig(ctx, file, a.config.Reload.Interval)
+	}
+
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs,
+		syscall.SIGHUP,
 		syscall.SIGTERM,
 		syscall.SIGINT,
 	)
 
 	sig := <-sigs
+	for ; sig == syscall.SIGHUP; sig = <-sigs {
+		if err := a.reload(); err != nil {
+			slog.Error("failed to reload configuration", "error", err)
+		}
+	}
+
 	slog.Info("shutdown signal received", "signal", fmt.Sprintf("%s (%d)", sig.String(), sig))
 
+	cancel()
+
 	a.Down()
 }
 
@@ -221,6 +1161,10 @@ func (a *App) Up() {
 // It closes the storage connection and ensures all resources are properly released.
 // Logs any errors encountered during shutdown and returns the last error if any.
 func (a *App) Down() error {
+	if a.stream != nil {
+		a.stream.close()
+	}
+
 	a.serverMetrics.Down()
 	a.serverHttp.Down()
 
@@ -230,6 +1174,47 @@ func (a *App) Down() error {
 		}
 	}
 
+	a.pushMetrics()
+
+	if a.shutdownMetrics != nil {
+		if err := a.shutdownMetrics(context.Background()); err != nil {
+			slog.Error("failed to shutdown otlp metrics export", "error", err)
+		}
+	}
+
+	if a.shutdownStatsD != nil {
+		if err := a.shutdownStatsD(context.Background()); err != nil {
+			slog.Error("failed to shutdown statsd metrics push", "error", err)
+		}
+	}
+
+	if a.shutdownTracing != nil {
+		if err := a.shutdownTracing(context.Background()); err != nil {
+			slog.Error("failed to shutdown tracing", "error", err)
+		}
+	}
+
 	slog.Info("application stopped")
 	return nil
 }
+
+// pushMetrics pushes the final metrics to the Pushgateway when it is enabled, so that the metrics
+// of batch or one-shot runs outlive the process.
+func (a *App) pushMetrics() {
+	p := a.config.Metrics.Pushgateway
+	if !p.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+
+	err := metrics.Push(ctx, prometheus.DefaultGatherer, metrics.Pushgateway{
+		URL:      p.URL,
+		Job:      p.Job,
+		Instance: p.Instance,
+	})
+	if err != nil {
+		slog.Error("failed to push metrics to pushgateway", "error", err)
+	}
+}
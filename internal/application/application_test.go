@@ -32,32 +32,48 @@ POSSIBILITY OF SUCH DAMAGE.
 package application
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	logger "gopkg.in/slog-handler.v1"
 
+	"ssl-pinning/internal/config"
+	"ssl-pinning/internal/metrics"
 	"ssl-pinning/internal/server"
 	"ssl-pinning/internal/signer"
+	"ssl-pinning/internal/storage/cached"
+	"ssl-pinning/internal/storage/filesystem"
 	"ssl-pinning/internal/storage/types"
+	"ssl-pinning/pkg/verify"
 )
 
 // mockStorage is a simple in-memory storage for testing
 type mockStorage struct {
+	catalog     []byte
 	keys        map[string][]types.DomainKey
 	data        map[string][]byte
 	closeCalled bool
+	saveErr     error
 	saveKeys    map[string]types.DomainKey
 }
 
@@ -80,10 +96,79 @@ func (m *mockStorage) GetByFile(file string) ([]types.DomainKey, []byte, error)
 	return keys, data, nil
 }
 
+func (m *mockStorage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	keys := make([]types.DomainKey, 0)
+	for _, file := range sortedFiles(m.keys) {
+		for _, k := range m.keys[file] {
+			if k.Fqdn == fqdn {
+				k.File = file
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, types.ErrNotFound
+	}
+
+	return keys, nil
+}
+
+func sortedFiles(m map[string][]types.DomainKey) []string {
+	files := make([]string, 0, len(m))
+	for file := range m {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
 func (m *mockStorage) SaveKeys(keys map[string]types.DomainKey) error {
 	for k, v := range keys {
 		m.saveKeys[k] = v
 	}
+	return m.saveErr
+}
+
+func (m *mockStorage) ListFiles() ([]string, error) {
+	files := make([]string, 0, len(m.keys)+len(m.data))
+	for file := range m.keys {
+		files = append(files, file)
+	}
+	for file := range m.data {
+		if _, ok := m.keys[file]; !ok {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (m *mockStorage) DeleteKeys(file, fqdn string) error {
+	keys := make([]types.DomainKey, 0, len(m.keys[file]))
+	for _, k := range m.keys[file] {
+		if k.Fqdn != fqdn {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == len(m.keys[file]) {
+		return types.ErrNotFound
+	}
+
+	m.keys[file] = keys
+	return nil
+}
+
+func (m *mockStorage) GetCatalog() ([]byte, error) {
+	if m.catalog == nil {
+		return nil, types.ErrNotFound
+	}
+	return m.catalog, nil
+}
+
+func (m *mockStorage) SaveCatalog(data []byte) error {
+	m.catalog = data
 	return nil
 }
 
@@ -100,6 +185,8 @@ func (m *mockStorage) WithConnMaxIdleTime(d time.Duration) {}
 func (m *mockStorage) WithConnMaxLifetime(d time.Duration) {}
 func (m *mockStorage) WithMaxIdleConns(n int)              {}
 func (m *mockStorage) WithMaxOpenConns(n int)              {}
+func (m *mockStorage) WithMaxAge(d time.Duration)          {}
+func (m *mockStorage) WithStaleness(st *types.Staleness)   {}
 func (m *mockStorage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -117,7 +204,7 @@ func (m *mockStorage) ProbeStartup() func(w http.ResponseWriter, r *http.Request
 }
 
 // setupTestSigner creates a test RSA key pair and signer
-func setupTestSigner(t *testing.T) (*signer.Signer, string) {
+func setupTestSigner(t *testing.T, opts ...signer.Option) (*signer.Signer, string) {
 	t.Helper()
 
 	tmpDir := t.TempDir()
@@ -143,7 +230,7 @@ func setupTestSigner(t *testing.T) (*signer.Signer, string) {
 	privKeyFile.Close()
 
 	// Create signer
-	signer, err := signer.NewSigner(privKeyPath)
+	signer, err := signer.NewSigner(privKeyPath, opts...)
 	require.NoError(t, err)
 
 	return signer, tmpDir
@@ -167,10 +254,21 @@ func TestApp_handleFileJSON(t *testing.T) {
 		validate       func(t *testing.T, body string)
 	}{
 		{
-			name: "success with single key returns data",
+			name: "success with pre-signed data returns data",
 			file: "test.json",
 			setupStorage: func(m *mockStorage) {
 				m.data["test.json"] = []byte(`{"test":"data"}`)
+			},
+			setupSigner:    true,
+			wantStatusCode: http.StatusOK,
+			validate: func(t *testing.T, body string) {
+				assert.Equal(t, `{"test":"data"}`, body)
+			},
+		},
+		{
+			name: "success with single key returns signed data",
+			file: "test.json",
+			setupStorage: func(m *mockStorage) {
 				m.keys["test.json"] = []types.DomainKey{
 					{
 						Date:       &now,
@@ -184,7 +282,12 @@ func TestApp_handleFileJSON(t *testing.T) {
 			setupSigner:    true,
 			wantStatusCode: http.StatusOK,
 			validate: func(t *testing.T, body string) {
-				assert.Equal(t, `{"test":"data"}`, body)
+				var result types.FileStructure
+				err := json.Unmarshal([]byte(body), &result)
+				require.NoError(t, err)
+				assert.NotEmpty(t, result.Signature)
+				require.Len(t, result.Payload.Keys, 1)
+				assert.Equal(t, "test-key", result.Payload.Keys[0].Key)
 			},
 		},
 		{
@@ -228,6 +331,16 @@ func TestApp_handleFileJSON(t *testing.T) {
 			wantStatusCode: http.StatusBadRequest,
 			wantBody:       "file required",
 		},
+		{
+			name: "error path traversal",
+			file: "../../etc/passwd",
+			setupStorage: func(m *mockStorage) {
+				m.data["../../etc/passwd"] = []byte("root:x:0:0")
+			},
+			setupSigner:    true,
+			wantStatusCode: http.StatusBadRequest,
+			wantBody:       "invalid file name",
+		},
 		{
 			name: "error file not found",
 			file: "nonexistent.json",
@@ -288,6 +401,255 @@ func TestApp_handleFileJSON(t *testing.T) {
 	}
 }
 
+func TestApp_handleFiles(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	testSigner, _ := setupTestSigner(t)
+
+	storage := newMockStorage()
+	storage.keys["a.json"] = []types.DomainKey{
+		{Date: &older, Fqdn: "www.example.com", Key: "key1"},
+		{Date: &newer, Fqdn: "www.test.com", Key: "key2"},
+	}
+
+	signed, err := types.SignedKeys("b.json", []types.DomainKey{
+		{Date: &older, Fqdn: "www.example.org", Key: "key3"},
+	}, testSigner)
+	require.NoError(t, err)
+	storage.data["b.json"] = signed
+
+	app := &App{storage: storage, signer: testSigner}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+	w := httptest.NewRecorder()
+
+	app.handleFiles(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var result types.FileList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.Len(t, result.Files, 2)
+
+	assert.Equal(t, "a.json", result.Files[0].File)
+	assert.Equal(t, 2, result.Files[0].Keys)
+	require.NotNil(t, result.Files[0].UpdatedAt)
+	assert.True(t, newer.Equal(*result.Files[0].UpdatedAt))
+
+	assert.Equal(t, "b.json", result.Files[1].File)
+	assert.Equal(t, 1, result.Files[1].Keys)
+	require.NotNil(t, result.Files[1].UpdatedAt)
+	assert.True(t, older.Equal(*result.Files[1].UpdatedAt))
+}
+
+func TestApp_handleDomain(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		fqdn           string
+		wantStatusCode int
+		wantKeys       int
+	}{
+		{name: "found in two files", fqdn: "www.example.com", wantStatusCode: http.StatusOK, wantKeys: 2},
+		{name: "unknown fqdn", fqdn: "www.unknown.com", wantStatusCode: http.StatusNotFound},
+		{name: "missing fqdn", fqdn: "", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMockStorage()
+			storage.keys["a.json"] = []types.DomainKey{
+				{Date: &now, Expire: 3600, Fqdn: "www.example.com", Key: "key1", LastError: "timeout"},
+				{Date: &now, Expire: 3600, Fqdn: "www.test.com", Key: "key2"},
+			}
+			storage.keys["b.json"] = []types.DomainKey{
+				{Date: &now, Expire: 7200, Fqdn: "www.example.com", Key: "key3"},
+			}
+
+			app := &App{storage: storage}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/domains/"+tt.fqdn, nil)
+			req.SetPathValue("fqdn", tt.fqdn)
+			w := httptest.NewRecorder()
+
+			app.handleDomain(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var result types.FileKeys
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Len(t, result.Keys, tt.wantKeys)
+
+			assert.Equal(t, "a.json", result.Keys[0].File)
+			assert.Equal(t, "key1", result.Keys[0].Key)
+			assert.Equal(t, "timeout", result.Keys[0].LastError)
+			assert.Equal(t, "b.json", result.Keys[1].File)
+			assert.Equal(t, int64(7200), result.Keys[1].Expire)
+		})
+	}
+}
+
+func TestApp_handleDomainCert(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	cert := &types.Certificate{
+		Fqdn:     "www.example.com",
+		Issuer:   "CN=Example CA",
+		NotAfter: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		SANs:     []string{"www.example.com"},
+		Serial:   "0a0b0c",
+		Subject:  "CN=www.example.com",
+	}
+
+	tests := []struct {
+		name           string
+		fqdn           string
+		wantStatusCode int
+	}{
+		{name: "fetched", fqdn: "www.example.com", wantStatusCode: http.StatusOK},
+		{name: "not fetched yet", fqdn: "new.example.com", wantStatusCode: http.StatusNotFound},
+		{name: "unknown fqdn", fqdn: "www.unknown.com", wantStatusCode: http.StatusNotFound},
+		{name: "missing fqdn", fqdn: "", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				keys: newStatusKeys(t,
+					types.DomainKey{Fqdn: "www.example.com", File: "test.json", Key: "key1", Cert: cert},
+					types.DomainKey{Fqdn: "new.example.com", File: "test.json"},
+				),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/domains/"+tt.fqdn+"/cert", nil)
+			req.SetPathValue("fqdn", tt.fqdn)
+			w := httptest.NewRecorder()
+
+			app.handleDomainCert(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var result types.Certificate
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.Equal(t, *cert, result)
+		})
+	}
+}
+
+func TestApp_handleVerify(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t, signer.WithLegacyFormat(false))
+	otherSigner, _ := setupTestSigner(t, signer.WithLegacyFormat(false))
+
+	keys := []types.DomainKey{
+		{DomainName: "*.example.com", Expire: 3600, Fqdn: "example.com", Key: "key1"},
+		{DomainName: "*.test.com", Expire: 7200, Fqdn: "test.com", Key: "key2"},
+	}
+
+	signed, err := types.SignedKeys("a.json", keys, testSigner)
+	require.NoError(t, err)
+
+	foreign, err := types.SignedKeys("a.json", keys, otherSigner)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatusCode int
+		wantValid      bool
+	}{
+		{name: "valid", body: string(signed), wantStatusCode: http.StatusOK, wantValid: true},
+		{name: "tampered", body: strings.Replace(string(signed), "key1", "key3", 1), wantStatusCode: http.StatusUnprocessableEntity},
+		{name: "other key", body: string(foreign), wantStatusCode: http.StatusUnprocessableEntity},
+		{name: "malformed", body: "not json", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{signer: testSigner}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/verify", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			app.handleVerify(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var result verify.Verification
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.Equal(t, tt.wantValid, result.Valid)
+
+			if tt.wantValid {
+				assert.Equal(t, testSigner.KeyID(), result.Kid)
+				assert.Equal(t, signer.AlgorithmRS512, result.Alg)
+				assert.NotNil(t, result.SignedAt)
+				assert.Empty(t, result.Error)
+			} else {
+				assert.NotEmpty(t, result.Error)
+			}
+		})
+	}
+}
+
+func TestApp_handleDeleteKeys(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name           string
+		file           string
+		fqdn           string
+		wantStatusCode int
+		wantKeys       int
+	}{
+		{name: "success", file: "test.json", fqdn: "www.example.com", wantStatusCode: http.StatusNoContent, wantKeys: 1},
+		{name: "not found", file: "test.json", fqdn: "www.unknown.com", wantStatusCode: http.StatusNotFound, wantKeys: 2},
+		{name: "missing fqdn", file: "test.json", fqdn: "", wantStatusCode: http.StatusBadRequest, wantKeys: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMockStorage()
+			storage.keys["test.json"] = []types.DomainKey{
+				{Fqdn: "www.example.com", Key: "key1"},
+				{Fqdn: "www.test.com", Key: "key2"},
+			}
+
+			app := &App{storage: storage}
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/v1/files/"+tt.file+"/keys/"+tt.fqdn, nil)
+			req.SetPathValue("file", tt.file)
+			req.SetPathValue("fqdn", tt.fqdn)
+			w := httptest.NewRecorder()
+
+			app.handleDeleteKeys(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Len(t, storage.keys["test.json"], tt.wantKeys)
+		})
+	}
+}
+
 func TestApp_Down(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -327,6 +689,36 @@ func TestApp_Down(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "pushes metrics to pushgateway",
+			setup: func() *App {
+				var pushed atomic.Bool
+				gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					pushed.Store(r.Method == http.MethodPut && r.URL.Path == "/metrics/job/ssl-pinning/instance/ci")
+				}))
+				t.Cleanup(gateway.Close)
+
+				cfg := config.Config{}
+				cfg.Metrics.Pushgateway = config.ConfigMetricsPushgateway{
+					Enabled:  true,
+					Instance: "ci",
+					Job:      "ssl-pinning",
+					Timeout:  5 * time.Second,
+					URL:      gateway.URL,
+				}
+
+				app := &App{
+					config:        cfg,
+					serverHttp:    server.NewServer(server.WithAddr("127.0.0.1:0")),
+					serverMetrics: server.NewServer(server.WithAddr("127.0.0.1:0")),
+				}
+
+				t.Cleanup(func() { assert.True(t, pushed.Load()) })
+
+				return app
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -404,39 +796,765 @@ func TestApp_handleFileJSON_WithRealStorage(t *testing.T) {
 	assert.Contains(t, fqdns, "api.test.com")
 }
 
-// mockStorageWithError simulates storage errors
-type mockStorageWithError struct {
-	*mockStorage
-	getByFileError bool
-}
-
-func (m *mockStorageWithError) GetByFile(file string) ([]types.DomainKey, []byte, error) {
-	if m.getByFileError {
-		return nil, nil, assert.AnError
-	}
-	return m.mockStorage.GetByFile(file)
-}
+func TestApp_handleFileJSON_FilesystemStorage(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
 
-func TestApp_handleFileJSON_StorageErrors(t *testing.T) {
 	testSigner, _ := setupTestSigner(t)
 
-	storage := &mockStorageWithError{
-		mockStorage:    newMockStorage(),
-		getByFileError: true,
-	}
+	now := time.Now().UTC().Truncate(time.Second)
+	pin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	backup := "YLh1dUR9y6Kja30RrAn7JKnbQG/uEtLMkBgFF2Fuihg="
+
+	dumpDir := t.TempDir()
+
+	storage, err := filesystem.New(context.Background(),
+		types.WithDumpDir(dumpDir),
+		types.WithSigner(testSigner),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.SaveKeys(map[string]types.DomainKey{
+		"www.example.com": {
+			Date:          &now,
+			DomainName:    "example.com",
+			Expire:        3600,
+			File:          "test.json",
+			Fqdn:          "www.example.com",
+			Key:           pin,
+			LastError:     "i/o timeout",
+			ErrorCategory: "timeout",
+			Pins:          []string{pin, backup},
+		},
+	}))
 
 	app := &App{
-		storage: storage,
-		signer:  testSigner,
+		responses: newResponses(),
+		storage:   storage,
+		signer:    testSigner,
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
-	req.SetPathValue("file", "test.json")
-	w := httptest.NewRecorder()
+	get := func(t *testing.T, naming types.Naming) []byte {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+		req.Header.Set("Accept", "application/json; naming="+string(naming))
+		req.SetPathValue("file", "test.json")
+		w := httptest.NewRecorder()
 
-	app.handleFileJSON(w, req)
+		app.handleFileJSON(w, req)
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		return w.Body.Bytes()
+	}
+
+	t.Run("legacy serves the stored file", func(t *testing.T) {
+		stored, err := os.ReadFile(filepath.Join(dumpDir, "test.json"))
+		require.NoError(t, err)
+		assert.Equal(t, string(stored), string(get(t, types.NamingLegacy)))
+	})
+
+	t.Run("snake case publishes the pins", func(t *testing.T) {
+		structure, err := types.ParseFileStructure(get(t, types.NamingSnake))
+		require.NoError(t, err)
+		require.Len(t, structure.Payload.Keys, 1)
+		assert.Equal(t, []string{pin, backup}, structure.Payload.Keys[0].Pins)
+	})
+
+	t.Run("snake case publishes the error category", func(t *testing.T) {
+		structure, err := types.ParseFileStructure(get(t, types.NamingSnake))
+		require.NoError(t, err)
+		require.Len(t, structure.Payload.Keys, 1)
+		assert.Equal(t, "timeout", structure.Payload.Keys[0].ErrorCategory)
+	})
+}
+
+func TestApp_handleFileJSON_Naming(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Now()
+	expire := now.Add(24 * time.Hour).Unix()
+
+	testSigner, _ := setupTestSigner(t)
+
+	newKeys := func() []types.DomainKey {
+		return []types.DomainKey{
+			{
+				Date:       &now,
+				DomainName: "*.example.com",
+				Expire:     expire,
+				Fqdn:       "example.com",
+				Key:        "key1",
+			},
+			{
+				Date:       &now,
+				DomainName: "*.example.org",
+				Expire:     expire,
+				Fqdn:       "example.org",
+				Key:        "key2",
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		accept          string
+		config          types.Naming
+		setupStorage    func(m *mockStorage)
+		wantStatusCode  int
+		wantContentType string
+		wantField       string
+		notWantField    string
+	}{
+		{
+			name:   "default legacy naming",
+			accept: "application/json",
+			setupStorage: func(m *mockStorage) {
+				m.keys["test.json"] = newKeys()
+			},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/json",
+			wantField:       `"domainName"`,
+		},
+		{
+			name:   "snake case via accept header",
+			accept: "application/json; naming=snake_case",
+			setupStorage: func(m *mockStorage) {
+				m.keys["test.json"] = newKeys()
+			},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/json; naming=snake_case",
+			wantField:       `"domain_name"`,
+			notWantField:    `"domainName"`,
+		},
+		{
+			name:   "camel case via config",
+			config: types.NamingCamel,
+			setupStorage: func(m *mockStorage) {
+				m.keys["test.json"] = newKeys()
+			},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/json; naming=camelCase",
+			wantField:       `"domainName"`,
+		},
+		{
+			name:   "accept header overrides config",
+			accept: "text/html, application/json; naming=legacy",
+			config: types.NamingSnake,
+			setupStorage: func(m *mockStorage) {
+				m.keys["test.json"] = newKeys()
+			},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/json",
+			wantField:       `"domainName"`,
+		},
+		{
+			name:   "re-signs pre-rendered data",
+			accept: "application/json; naming=snake_case",
+			setupStorage: func(m *mockStorage) {
+				data, err := types.SignedKeys("test.json", newKeys(), testSigner)
+				require.NoError(t, err)
+				m.data["test.json"] = data
+			},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/json; naming=snake_case",
+			wantField:       `"domain_name"`,
+			notWantField:    `"domainName"`,
+		},
+		{
+			name:   "unknown naming",
+			accept: "application/json; naming=kebab-case",
+			setupStorage: func(m *mockStorage) {
+				m.keys["test.json"] = newKeys()
+			},
+			wantStatusCode: http.StatusNotAcceptable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMockStorage()
+			tt.setupStorage(storage)
+
+			app := &App{
+				storage: storage,
+				signer:  testSigner,
+			}
+			app.config.Server.Naming = tt.config
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+			req.SetPathValue("file", "test.json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			app.handleFileJSON(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			}
+
+			if tt.wantField != "" {
+				assert.Contains(t, w.Body.String(), tt.wantField)
+			}
+
+			if tt.notWantField != "" {
+				assert.NotContains(t, w.Body.String(), tt.notWantField)
+			}
+		})
+	}
+}
+
+func TestApp_envelope(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		config types.Envelope
+		want   types.Envelope
+	}{
+		{name: "no accept header", want: types.EnvelopeLegacy},
+		{name: "config default", config: types.EnvelopeJWS, want: types.EnvelopeJWS},
+		{name: "compact", accept: "application/jose", want: types.EnvelopeJWS},
+		{name: "json serialization", accept: "application/jose+json", want: types.EnvelopeJWSJSON},
+		{name: "cose", accept: `application/cose; cose-type="cose-sign1"`, want: types.EnvelopeCOSE},
+		{name: "json overrides config", accept: "application/json; naming=snake_case", config: types.EnvelopeJWS, want: types.EnvelopeLegacy},
+		{name: "first supported wins", accept: "text/html, application/jose, application/json", want: types.EnvelopeJWS},
+		{name: "unsupported falls back to config", accept: "*/*", config: types.EnvelopeJWSJSON, want: types.EnvelopeJWSJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{}
+			app.config.Server.Envelope = tt.config
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			assert.Equal(t, tt.want, app.envelope(req))
+		})
+	}
+}
+
+func TestApp_handleFileJSON_Envelope(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t)
+
+	now := time.Now()
+	storage := newMockStorage()
+	storage.keys["test.json"] = []types.DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: now.Unix(), Fqdn: "a.example.com", Key: "key1"},
+	}
+
+	app := &App{
+		storage: storage,
+		signer:  testSigner,
+	}
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		check           func(t *testing.T, body []byte)
+	}{
+		{
+			name:            "compact serialization",
+			accept:          "application/jose",
+			wantContentType: "application/jose",
+			check: func(t *testing.T, body []byte) {
+				assert.Len(t, strings.Split(string(body), "."), 3)
+			},
+		},
+		{
+			name:            "json serialization with naming",
+			accept:          "application/jose+json; naming=snake_case",
+			wantContentType: "application/jose+json; naming=snake_case",
+			check: func(t *testing.T, body []byte) {
+				var jws struct {
+					Payload   string `json:"payload"`
+					Protected string `json:"protected"`
+					Signature string `json:"signature"`
+				}
+				require.NoError(t, json.Unmarshal(body, &jws))
+				assert.NotEmpty(t, jws.Protected)
+				assert.NotEmpty(t, jws.Signature)
+
+				payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+				require.NoError(t, err)
+				assert.Contains(t, string(payload), `"domain_name"`)
+			},
+		},
+		{
+			name:            "cose",
+			accept:          "application/cose",
+			wantContentType: "application/cose",
+			check: func(t *testing.T, body []byte) {
+				// a COSE_Sign1 tagged array of four items
+				require.Greater(t, len(body), 2)
+				assert.Equal(t, []byte{0xd2, 0x84}, body[:2])
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+			req.SetPathValue("file", "test.json")
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+
+			app.handleFileJSON(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			tt.check(t, w.Body.Bytes())
+		})
+	}
+}
+
+// mockStorageWithError simulates storage errors
+type mockStorageWithError struct {
+	*mockStorage
+	getByFileError bool
+}
+
+func (m *mockStorageWithError) GetByFile(file string) ([]types.DomainKey, []byte, error) {
+	if m.getByFileError {
+		return nil, nil, assert.AnError
+	}
+	return m.mockStorage.GetByFile(file)
+}
+
+func TestApp_handleFileJSON_PinEncoding(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t)
+
+	pin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	now := time.Now()
+	storage := newMockStorage()
+	storage.keys["test.json"] = []types.DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: 3600, Fqdn: "a.example.com", Key: pin, Pins: []string{pin}},
+		{Date: &now, DomainName: "example.com", Expire: 3600, Fqdn: "b.example.com", Key: pin, Pins: []string{pin}},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		config         types.PinEncoding
+		wantStatusCode int
+		wantKey        string
+	}{
+		{name: "default", wantStatusCode: http.StatusOK, wantKey: pin},
+		{name: "sha256", query: "?pin_encoding=sha256", wantStatusCode: http.StatusOK, wantKey: "sha256//" + pin},
+		{
+			name:           "hex",
+			query:          "?pin_encoding=hex",
+			wantStatusCode: http.StatusOK,
+			wantKey:        "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		{name: "configured", config: types.PinEncodingSHA256, wantStatusCode: http.StatusOK, wantKey: "sha256//" + pin},
+		{name: "query overrides configured", query: "?pin_encoding=base64", config: types.PinEncodingHex, wantStatusCode: http.StatusOK, wantKey: pin},
+		{name: "unknown", query: "?pin_encoding=base32", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				storage: storage,
+				signer:  testSigner,
+			}
+			app.config.Server.PinEncoding = tt.config
+
+			// the v1 payload is byte-stable and publishes no pins
+			for naming, wantPins := range map[types.Naming][]string{types.NamingLegacy: nil, types.NamingSnake: {tt.wantKey}} {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json"+tt.query, nil)
+				req.Header.Set("Accept", "application/json; naming="+string(naming))
+				req.SetPathValue("file", "test.json")
+				w := httptest.NewRecorder()
+
+				app.handleFileJSON(w, req)
+
+				require.Equal(t, tt.wantStatusCode, w.Code, w.Body.String())
+
+				if tt.wantStatusCode != http.StatusOK {
+					return
+				}
+
+				structure, err := types.ParseFileStructure(w.Body.Bytes())
+				require.NoError(t, err)
+				require.Len(t, structure.Payload.Keys, 2)
+
+				for _, k := range structure.Payload.Keys {
+					assert.Equal(t, tt.wantKey, k.Key)
+					assert.Equal(t, wantPins, k.Pins, naming)
+				}
+			}
+		})
+	}
+}
+
+func TestApp_handleFileJSON_StorageErrors(t *testing.T) {
+	testSigner, _ := setupTestSigner(t)
+
+	storage := &mockStorageWithError{
+		mockStorage:    newMockStorage(),
+		getByFileError: true,
+	}
+
+	app := &App{
+		storage: storage,
+		signer:  testSigner,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+	req.SetPathValue("file", "test.json")
+	w := httptest.NewRecorder()
+
+	app.handleFileJSON(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestApp_handleFileJSON_Cache(t *testing.T) {
+	testSigner, _ := setupTestSigner(t)
+
+	now := time.Now()
+	backend := newMockStorage()
+	backend.keys["test.json"] = []types.DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: now.Unix(), Fqdn: "a.example.com", Key: "key1"},
+		{Date: &now, DomainName: "example.com", Expire: now.Unix(), Fqdn: "b.example.com", Key: "key2"},
+	}
+
+	store := cached.New(backend, time.Minute, 10)
+	app := &App{
+		storage: store,
+		signer:  testSigner,
+	}
+
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+		req.SetPathValue("file", "test.json")
+		w := httptest.NewRecorder()
+
+		app.handleFileJSON(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		return w.Body.String()
+	}
+
+	assert.Contains(t, get(), "key1")
+
+	// the keys are served from the cache until keys are saved
+	backend.keys["test.json"][0].Key = "rotated"
+	assert.Contains(t, get(), "key1")
+
+	require.NoError(t, store.SaveKeys(map[string]types.DomainKey{}))
+	assert.Contains(t, get(), "rotated")
+}
+
+func TestApp_handleFileJSON_SignatureMetrics(t *testing.T) {
+	testSigner, _ := setupTestSigner(t)
+
+	now := time.Now()
+	backend := newMockStorage()
+	backend.keys["test.json"] = []types.DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: now.Unix(), Fqdn: "a.example.com", Key: "key1"},
+	}
+
+	collector := new(metrics.Collector)
+	app := &App{
+		collector: collector,
+		responses: newResponses(),
+		storage:   backend,
+		signer:    testSigner,
+	}
+
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+		req.SetPathValue("file", "test.json")
+		w := httptest.NewRecorder()
+
+		app.handleFileJSON(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// signed once, served from the cache afterwards
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "ssl_pinning_sign_duration_seconds"))
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP ssl_pinning_payload_cache_hits_total Number of signed payloads served from the payload cache
+# TYPE ssl_pinning_payload_cache_hits_total counter
+ssl_pinning_payload_cache_hits_total 2
+# HELP ssl_pinning_payload_cache_misses_total Number of payloads rendered and signed on a miss of the payload cache
+# TYPE ssl_pinning_payload_cache_misses_total counter
+ssl_pinning_payload_cache_misses_total 1
+# HELP ssl_pinning_sign_errors_total Number of failed signings of files
+# TYPE ssl_pinning_sign_errors_total counter
+ssl_pinning_sign_errors_total{algorithm="`+testSigner.Algorithm()+`",file="test.json"} 0
+`), "ssl_pinning_payload_cache_hits_total", "ssl_pinning_payload_cache_misses_total", "ssl_pinning_sign_errors_total"))
+}
+
+func TestApp_handleFileJSON_Conditional(t *testing.T) {
+	testSigner, _ := setupTestSigner(t)
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+
+	backend := newMockStorage()
+	backend.keys["test.json"] = []types.DomainKey{
+		{Date: &earlier, DomainName: "example.com", Expire: now.Unix(), Fqdn: "a.example.com", Key: "key1"},
+		{Date: &now, DomainName: "example.com", Expire: now.Unix(), Fqdn: "b.example.com", Key: "key2"},
+	}
+
+	app := &App{
+		config:    config.Config{Server: config.ConfigServer{CacheMaxAge: 5 * time.Minute}},
+		responses: newResponses(),
+		storage:   backend,
+		signer:    testSigner,
+	}
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+		req.SetPathValue("file", "test.json")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+
+		app.handleFileJSON(w, req)
+
+		return w
+	}
+
+	w := get("", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, etag(w.Body.Bytes()), w.Header().Get("ETag"))
+	assert.Equal(t, now.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+
+	tag := w.Header().Get("ETag")
+
+	w = get("If-None-Match", tag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, tag, w.Header().Get("ETag"))
+
+	w = get("If-Modified-Since", now.Format(http.TimeFormat))
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = get("If-None-Match", `"stale"`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, tag, w.Header().Get("ETag"))
+
+	app.config.Server.CacheMaxAge = 0
+	assert.Equal(t, "no-cache", app.cacheControl())
+}
+
+func TestApp_reload(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	t.Cleanup(viper.Reset)
+
+	tests := []struct {
+		name      string
+		config    string
+		wantErr   bool
+		wantKeys  []string
+		wantFiles map[string]int
+	}{
+		{
+			name: "keys reconciled",
+			config: `
+keys:
+  - fqdn: www.example.com
+    file: test.json
+  - fqdn: new.example.com
+`,
+			wantKeys:  []string{"new.example.com", "www.example.com"},
+			wantFiles: map[string]int{"test.json": 1},
+		},
+		{
+			name:      "invalid configuration",
+			config:    "keys:\n  - fqdn: www.example.com\n    interval: -1s\n",
+			wantErr:   true,
+			wantKeys:  []string{"www.example.com", "www.test.com"},
+			wantFiles: map[string]int{"test.json": 2},
+		},
+		{
+			name:      "missing configuration file",
+			wantErr:   true,
+			wantKeys:  []string{"www.example.com", "www.test.com"},
+			wantFiles: map[string]int{"test.json": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.config != "" {
+				require.NoError(t, os.WriteFile(path, []byte(tt.config), 0o600))
+			}
+
+			viper.Reset()
+			viper.SetConfigFile(path)
+
+			storage := newMockStorage()
+			storage.keys["test.json"] = []types.DomainKey{
+				{Fqdn: "www.example.com", Key: "key1"},
+				{Fqdn: "www.test.com", Key: "key2"},
+			}
+
+			app := &App{
+				keys: newStatusKeys(t,
+					types.DomainKey{Fqdn: "www.example.com", File: "test.json", DomainName: "*.www.example.com"},
+					types.DomainKey{Fqdn: "www.test.com", File: "test.json", DomainName: "*.www.test.com"},
+				),
+				storage: storage,
+			}
+
+			err := app.reload()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			var keys []string
+			for fqdn := range app.keys.Snapshot() {
+				keys = append(keys, fqdn)
+			}
+			sort.Strings(keys)
+
+			assert.Equal(t, tt.wantKeys, keys)
+			for file, n := range tt.wantFiles {
+				assert.Len(t, storage.keys[file], n)
+			}
+		})
+	}
+}
+
+func TestApp_flush(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t)
+
+	now := time.Now()
+	backend := newMockStorage()
+	backend.keys["test.json"] = []types.DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: now.Unix(), Fqdn: "a.example.com", Key: "key1"},
+		{Date: &now, DomainName: "example.com", Expire: now.Unix(), Fqdn: "b.example.com", Key: "key2"},
+	}
+
+	app := &App{
+		responses: newResponses(),
+		storage:   backend,
+		signer:    testSigner,
+	}
+
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json", nil)
+		req.SetPathValue("file", "test.json")
+		w := httptest.NewRecorder()
+
+		app.handleFileJSON(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		return w.Body.String()
+	}
+
+	flushed := map[string]types.DomainKey{"a.example.com": {Fqdn: "a.example.com", Key: "key1"}}
+	require.NoError(t, app.flush(flushed))
+	assert.Equal(t, flushed, backend.saveKeys)
+
+	// the payload is signed at flush time and served as is until the next flush
+	backend.keys["test.json"][0].Key = "rotated"
+	first := get()
+	assert.Contains(t, first, "key1")
+	assert.Equal(t, first, get())
+
+	require.NoError(t, app.flush(flushed))
+	assert.Contains(t, get(), "rotated")
+
+	// keys skipped by storage do not fail the flush and the files are signed again
+	backend.saveErr = fmt.Errorf("%w: empty key", types.ErrPartialSave)
+	backend.keys["test.json"][0].Key = "partial"
+	require.NoError(t, app.flush(flushed))
+	assert.Contains(t, get(), "partial")
+
+	backend.saveErr = assert.AnError
+	assert.ErrorIs(t, app.flush(flushed), assert.AnError)
+}
+
+// freeAddr returns a local address with a port that is free to listen on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func TestNew_Health(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	t.Cleanup(viper.Reset)
+
+	probes := []string{"/health/liveness", "/health/readiness", "/health/startup"}
+
+	for _, health := range []bool{true, false} {
+		t.Run(fmt.Sprintf("health %t", health), func(t *testing.T) {
+			_, tlsDir := setupTestSigner(t)
+			apiAddr, metricsAddr := freeAddr(t), freeAddr(t)
+
+			viper.Reset()
+			viper.Set("metrics.listen", metricsAddr)
+			viper.Set("server.health", health)
+			viper.Set("server.listen", apiAddr)
+			viper.Set("storage.type", "memory")
+			viper.Set("tls.dir", tlsDir)
+			viper.Set("tls.dump_interval", "1s")
+			viper.Set("tls.timeout", "1s")
+
+			app, err := New()
+			require.NoError(t, err)
+
+			go app.serverHttp.Up()
+			go app.serverMetrics.Up()
+			t.Cleanup(func() { app.Down() })
+
+			get := func(addr, path string) int {
+				res, err := http.Get("http://" + addr + path)
+				if err != nil {
+					return 0
+				}
+				defer res.Body.Close()
+
+				return res.StatusCode
+			}
+
+			require.Eventually(t, func() bool {
+				return get(apiAddr, "/version") == http.StatusOK && get(metricsAddr, "/version") == http.StatusOK
+			}, 5*time.Second, 10*time.Millisecond)
+
+			for _, probe := range probes {
+				// the metrics server always serves the probes, with no keys fetched yet they fail
+				status := get(metricsAddr, probe)
+				assert.Contains(t, []int{http.StatusOK, http.StatusServiceUnavailable}, status, "metrics %s", probe)
+
+				if health {
+					assert.Equal(t, status, get(apiAddr, probe), "api %s", probe)
+				} else {
+					assert.Equal(t, http.StatusNotFound, get(apiAddr, probe), "api %s", probe)
+				}
+			}
+		})
+	}
 }
 
 func TestApp_Down_Integration(t *testing.T) {
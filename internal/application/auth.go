@@ -0,0 +1,204 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"ssl-pinning/internal/config"
+	"ssl-pinning/internal/oidc"
+	"ssl-pinning/internal/server"
+)
+
+// apiKey is a key granting access to the public API, identified by name in metrics and logs.
+type apiKey struct {
+	name  string
+	value []byte
+}
+
+// loadAPIKeys returns the keys of server.api_keys, reading the values of keys given by key_file.
+func loadAPIKeys(keys []config.ConfigServerAPIKey) ([]apiKey, error) {
+	loaded := make([]apiKey, 0, len(keys))
+
+	for _, k := range keys {
+		value := k.Key
+
+		if k.KeyFile != "" {
+			data, err := os.ReadFile(k.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read api key %q: %w", k.Name, err)
+			}
+
+			value = strings.TrimSpace(string(data))
+		}
+
+		if value == "" {
+			return nil, fmt.Errorf("api key %q is empty", k.Name)
+		}
+
+		loaded = append(loaded, apiKey{name: k.Name, value: []byte(value)})
+	}
+
+	return loaded, nil
+}
+
+// apiKeyName returns the name of the key of keys given by r in the X-API-Key header or the api_key
+// query parameter, or an empty string if the request carries none of them.
+func apiKeyName(keys []apiKey, r *http.Request) string {
+	got := r.Header.Get("X-API-Key")
+	if got == "" {
+		got = r.URL.Query().Get("api_key")
+	}
+
+	if got == "" {
+		return ""
+	}
+
+	name := ""
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(got), k.value) == 1 {
+			name = k.name
+		}
+	}
+
+	return name
+}
+
+// rateLimitKey returns the function identifying clients for server.rate_limit: requests with one
+// of keys share the bucket of the key, others the bucket of their IP address. Unknown keys fall
+// back to the IP so that clients cannot evade the limit by rotating keys.
+func rateLimitKey(keys []apiKey) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if name := apiKeyName(keys, r); name != "" {
+			return "api_key:" + name
+		}
+
+		return "ip:" + server.ClientIP(r)
+	}
+}
+
+// authenticate is the middleware of the public server requiring one of the keys of server.api_keys
+// in the X-API-Key header or the api_key query parameter for the routes below /api/. Other routes,
+// e.g. /version and the health probes, and all routes without configured keys are served as is.
+// Requests are counted per key name; returns 401 if the key is missing or unknown, as a
+// types.ErrorResponse for the v2 API.
+func (a *App) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(a.apiKeys) == 0 || !strings.HasPrefix(routePath(r.Pattern), "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name := apiKeyName(a.apiKeys, r)
+		if name == "" {
+			slog.DebugContext(r.Context(), "rejected request without valid api key", "path", r.URL.Path)
+
+			a.collector.IncAPIKeyRejection()
+
+			if strings.HasPrefix(r.URL.Path, "/api/v2/") {
+				writeAPIError(w, r, http.StatusUnauthorized, codeUnauthorized, "missing or unknown api key")
+				return
+			}
+
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		a.collector.IncAPIKeyRequest(name)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newAdminVerifier creates the verifier of bearer tokens of server.admin_oidc.
+// It returns nil when no issuer is configured.
+func newAdminVerifier(cfg config.Config) (*oidc.Verifier, error) {
+	if cfg.Server.AdminOIDC.Issuer == "" {
+		return nil, nil
+	}
+
+	return oidc.New(cfg.Server.AdminOIDC.Issuer,
+		oidc.WithAudience(cfg.Server.AdminOIDC.Audience),
+		oidc.WithJWKSURL(cfg.Server.AdminOIDC.JWKSURL),
+	)
+}
+
+// authorize wraps an admin handler to require a bearer token in the Authorization header
+// (e.g. "Authorization: Bearer secret"): the token of server.admin_token or a JWT of the
+// OpenID Connect provider of server.admin_oidc granting its scopes. Without either the handler
+// is served as is, the admin API is then only protected by the listen address of the metrics server.
+// Returns 401 with a Bearer challenge if the token is missing or invalid and 403 if a valid JWT
+// lacks a required scope (RFC 6750).
+func (a *App) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := a.config.Server.AdminToken
+		if token == "" && a.adminVerifier == nil {
+			next(w, r)
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			next(w, r)
+			return
+		}
+
+		if ok && a.adminVerifier != nil {
+			scopes := a.config.Server.AdminOIDC.Scopes
+			claims, err := a.adminVerifier.Verify(r.Context(), got)
+
+			switch {
+			case err != nil:
+				slog.DebugContext(r.Context(), "rejected admin bearer token", "err", err)
+			case !claims.HasScopes(scopes...):
+				slog.DebugContext(r.Context(), "admin bearer token lacks required scopes", "sub", claims.Subject, "scopes", scopes)
+
+				w.Header().Set("WWW-Authenticate",
+					fmt.Sprintf(`Bearer realm="admin", error="insufficient_scope", scope=%q`, strings.Join(scopes, " ")))
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			default:
+				slog.DebugContext(r.Context(), "authorized admin request", "sub", claims.Subject, "path", r.URL.Path)
+
+				next(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}
+}
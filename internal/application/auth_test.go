@@ -0,0 +1,284 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/config"
+	"ssl-pinning/internal/metrics"
+)
+
+func TestApp_authorize(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		header         string
+		wantStatusCode int
+	}{
+		{name: "no token configured", wantStatusCode: http.StatusOK},
+		{name: "valid token", token: "secret", header: "Bearer secret", wantStatusCode: http.StatusOK},
+		{name: "missing token", token: "secret", wantStatusCode: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer other", wantStatusCode: http.StatusUnauthorized},
+		{name: "wrong scheme", token: "secret", header: "Basic secret", wantStatusCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{config: config.Config{Server: config.ConfigServer{AdminToken: tt.token}}}
+
+			h := app.authorize(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/v1/files", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			h(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			if tt.wantStatusCode == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="admin"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestApp_authenticate(t *testing.T) {
+	tests := []struct {
+		name           string
+		keys           []apiKey
+		header         string
+		query          string
+		pattern        string
+		wantStatusCode int
+	}{
+		{name: "no keys configured", wantStatusCode: http.StatusOK},
+		{name: "public route", keys: []apiKey{{name: "ios", value: []byte("secret")}}, pattern: "GET /version", wantStatusCode: http.StatusOK},
+		{name: "health probe", keys: []apiKey{{name: "ios", value: []byte("secret")}}, pattern: "GET /health/liveness", wantStatusCode: http.StatusOK},
+		{name: "v2 catch-all", keys: []apiKey{{name: "ios", value: []byte("secret")}}, pattern: "/api/v2/", wantStatusCode: http.StatusUnauthorized},
+		{name: "valid header", keys: []apiKey{{name: "ios", value: []byte("secret")}}, header: "secret", wantStatusCode: http.StatusOK},
+		{name: "valid query", keys: []apiKey{{name: "ios", value: []byte("secret")}}, query: "secret", wantStatusCode: http.StatusOK},
+		{name: "second key", keys: []apiKey{{name: "ios", value: []byte("a")}, {name: "android", value: []byte("b")}}, header: "b", wantStatusCode: http.StatusOK},
+		{name: "missing key", keys: []apiKey{{name: "ios", value: []byte("secret")}}, wantStatusCode: http.StatusUnauthorized},
+		{name: "wrong key", keys: []apiKey{{name: "ios", value: []byte("secret")}}, header: "other", wantStatusCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{apiKeys: tt.keys, collector: new(metrics.Collector)}
+
+			h := app.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			target := "/api/v1/files"
+			if tt.query != "" {
+				target += "?api_key=" + tt.query
+			}
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Pattern = "GET /api/v1/files"
+			if tt.pattern != "" {
+				req.Pattern = tt.pattern
+			}
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+		})
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "android")
+	require.NoError(t, os.WriteFile(file, []byte("from-file\n"), 0o600))
+
+	keys, err := loadAPIKeys([]config.ConfigServerAPIKey{
+		{Name: "ios", Key: "inline"},
+		{Name: "android", KeyFile: file},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []apiKey{
+		{name: "ios", value: []byte("inline")},
+		{name: "android", value: []byte("from-file")},
+	}, keys)
+
+	_, err = loadAPIKeys([]config.ConfigServerAPIKey{{Name: "ios", KeyFile: filepath.Join(t.TempDir(), "missing")}})
+	assert.Error(t, err)
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	require.NoError(t, os.WriteFile(empty, []byte("\n"), 0o600))
+
+	_, err = loadAPIKeys([]config.ConfigServerAPIKey{{Name: "ios", KeyFile: empty}})
+	assert.Error(t, err)
+}
+
+func TestApp_authorize_OIDC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "EC", "kid": "sso", "crv": "P-256",
+				"x": b64(key.X.FillBytes(make([]byte, 32))),
+				"y": b64(key.Y.FillBytes(make([]byte, 32))),
+			}},
+		})
+	}))
+	defer jwks.Close()
+
+	issuer := "https://sso.example.com/realms/ops"
+
+	sign := func(claims map[string]any) string {
+		c, err := json.Marshal(claims)
+		require.NoError(t, err)
+
+		signed := b64([]byte(`{"alg":"ES256","kid":"sso"}`)) + "." + b64(c)
+		digest := sha256.Sum256([]byte(signed))
+
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+
+		return signed + "." + b64(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...))
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+
+	cfg := config.Config{Server: config.ConfigServer{
+		AdminOIDC: config.ConfigServerAdminOIDC{
+			Audience: "ssl-pinning",
+			Issuer:   issuer,
+			JWKSURL:  jwks.URL,
+			Scopes:   []string{"pins:admin"},
+		},
+		AdminToken: "secret",
+	}}
+
+	verifier, err := newAdminVerifier(cfg)
+	require.NoError(t, err)
+
+	app := &App{adminVerifier: verifier, config: cfg}
+
+	tests := []struct {
+		name           string
+		header         string
+		wantStatusCode int
+		wantChallenge  string
+	}{
+		{
+			name:           "static token",
+			header:         "Bearer secret",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "valid jwt",
+			header:         "Bearer " + sign(map[string]any{"iss": issuer, "aud": "ssl-pinning", "exp": exp, "scope": "openid pins:admin"}),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "missing scope",
+			header:         "Bearer " + sign(map[string]any{"iss": issuer, "aud": "ssl-pinning", "exp": exp, "scope": "openid"}),
+			wantStatusCode: http.StatusForbidden,
+			wantChallenge:  `Bearer realm="admin", error="insufficient_scope", scope="pins:admin"`,
+		},
+		{
+			name:           "wrong audience",
+			header:         "Bearer " + sign(map[string]any{"iss": issuer, "aud": "other", "exp": exp, "scope": "pins:admin"}),
+			wantStatusCode: http.StatusUnauthorized,
+			wantChallenge:  `Bearer realm="admin"`,
+		},
+		{
+			name:           "missing token",
+			wantStatusCode: http.StatusUnauthorized,
+			wantChallenge:  `Bearer realm="admin"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := app.authorize(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/v1/files", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			h(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantChallenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	key := rateLimitKey([]apiKey{{name: "ios", value: []byte("secret")}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "ip:192.0.2.1", key(req))
+
+	req.Header.Set("X-API-Key", "secret")
+	assert.Equal(t, "api_key:ios", key(req))
+
+	req.Header.Set("X-API-Key", "rotated")
+	assert.Equal(t, "ip:192.0.2.1", key(req))
+}
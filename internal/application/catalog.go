@@ -0,0 +1,450 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// catalog is the pin catalogue managed with the /admin/v1/files API: pin files and the domains
+// assigned to them, keyed by file name. It is persisted in storage (see types.Storage.SaveCatalog),
+// so its domains are monitored across restarts, and applied by every application instance on
+// start and reload (see catalogKeys).
+type catalog struct {
+	Files map[string][]domainRequest `json:"files"`
+}
+
+// catalogFile is a pin file of the catalogue in responses of the /admin/v1/files API.
+type catalogFile struct {
+	File    string          `json:"file"`
+	Domains []domainRequest `json:"domains"`
+}
+
+// catalogList is the response of handleCatalogFiles.
+type catalogList struct {
+	Files []catalogFile `json:"files"`
+}
+
+// fileRequest is the request body of handleCreateFile.
+type fileRequest struct {
+	File string `json:"file"`
+}
+
+// loadCatalog reads the pin catalogue from storage. A catalogue that was never saved is empty.
+func (a *App) loadCatalog(store types.Storage) (catalog, error) {
+	c := catalog{Files: map[string][]domainRequest{}}
+
+	data, err := store.GetCatalog()
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return c, nil
+		}
+
+		return c, err
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+
+	if c.Files == nil {
+		c.Files = map[string][]domainRequest{}
+	}
+
+	return c, nil
+}
+
+// saveCatalog writes the pin catalogue to storage.
+func (a *App) saveCatalog(store types.Storage, c catalog) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return store.SaveCatalog(data)
+}
+
+// catalogKeys returns the domain keys of the domains of the pin catalogue, sorted by file and
+// FQDN. Domains of configured keys are skipped, the configuration takes precedence, as are
+// domains that are no longer valid.
+func catalogKeys(c catalog, configured []types.DomainKey) []types.DomainKey {
+	var out []types.DomainKey
+
+	for _, file := range slices.Sorted(maps.Keys(c.Files)) {
+		for _, req := range c.Files[file] {
+			if slices.ContainsFunc(configured, func(k types.DomainKey) bool { return k.Fqdn == req.Fqdn }) {
+				slog.Warn("catalog domain is configured, skipping", "fqdn", req.Fqdn, "file", file)
+				continue
+			}
+
+			req.File = file
+
+			key, err := req.domainKey()
+			if err != nil {
+				slog.Error("invalid catalog domain, skipping", "fqdn", req.Fqdn, "file", file, "error", err)
+				continue
+			}
+
+			out = append(out, key)
+		}
+	}
+
+	return out
+}
+
+// fileOf returns the file of the pin catalogue a domain is assigned to.
+func (c catalog) fileOf(fqdn string) (string, bool) {
+	for file, domains := range c.Files {
+		if slices.ContainsFunc(domains, func(d domainRequest) bool { return d.Fqdn == fqdn }) {
+			return file, true
+		}
+	}
+
+	return "", false
+}
+
+// writeCatalogJSON writes v as a JSON response with the given status code.
+func writeCatalogJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to write response", "error", err)
+	}
+}
+
+// handleCatalogFiles handles admin requests for the pin catalogue.
+// It accepts GET requests to /admin/v1/files and returns the files of the catalogue with the
+// domains assigned to them, sorted by file name.
+// Returns 200 with the files or 500 if the catalogue cannot be read.
+func (a *App) handleCatalogFiles(w http.ResponseWriter, r *http.Request) {
+	c, err := a.loadCatalog(types.WithContext(r.Context(), a.storage))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := catalogList{Files: make([]catalogFile, 0, len(c.Files))}
+
+	for _, file := range slices.Sorted(maps.Keys(c.Files)) {
+		res.Files = append(res.Files, catalogFile{File: file, Domains: c.Files[file]})
+	}
+
+	writeCatalogJSON(w, http.StatusOK, res)
+}
+
+// handleCatalogFile handles admin requests for a pin file of the catalogue.
+// It accepts GET requests to /admin/v1/files/{file} and returns the domains assigned to the file.
+// Returns 200 with the file, 404 if the file is not in the catalogue or 500 if the catalogue
+// cannot be read.
+func (a *App) handleCatalogFile(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+
+	c, err := a.loadCatalog(types.WithContext(r.Context(), a.storage))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	domains, ok := c.Files[file]
+	if !ok {
+		http.Error(w, fmt.Sprintf("file %s not in catalog", file), http.StatusNotFound)
+		return
+	}
+
+	writeCatalogJSON(w, http.StatusOK, catalogFile{File: file, Domains: domains})
+}
+
+// handleCreateFile handles admin requests for adding a pin file to the catalogue.
+// It accepts POST requests to /admin/v1/files with a fileRequest body. Domains are assigned to
+// the file with handleAssignDomain; the file is published once their keys are flushed.
+// Returns 201 with the file, 400 if the body or file name is invalid, 409 if the file is
+// already in the catalogue, 413 if the body is too large or 500 if the catalogue cannot be saved.
+func (a *App) handleCreateFile(w http.ResponseWriter, r *http.Request) {
+	var req fileRequest
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDomainRequestSize)).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("request too large, limit is %d bytes", maxDomainRequestSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.File == "" {
+		http.Error(w, "file required", http.StatusBadRequest)
+		return
+	}
+
+	if err := types.ValidateFile(req.File); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.catalogMu.Lock()
+	defer a.catalogMu.Unlock()
+
+	store := types.WithContext(r.Context(), a.storage)
+
+	c, err := a.loadCatalog(store)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := c.Files[req.File]; ok {
+		http.Error(w, fmt.Sprintf("file %s already in catalog", req.File), http.StatusConflict)
+		return
+	}
+
+	c.Files[req.File] = []domainRequest{}
+
+	if err := a.saveCatalog(store, c); err != nil {
+		slog.ErrorContext(r.Context(), "failed to save catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "catalog file created", "file", req.File)
+
+	writeCatalogJSON(w, http.StatusCreated, catalogFile{File: req.File, Domains: []domainRequest{}})
+}
+
+// handleDeleteFile handles admin requests for removing a pin file from the catalogue.
+// It accepts DELETE requests to /admin/v1/files/{file}, unassigns every domain of the file
+// (see handleUnassignDomain) and removes the file from the catalogue.
+// Returns 204 on success, 404 if the file is not in the catalogue or 500 if the catalogue
+// cannot be read or saved.
+func (a *App) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+
+	a.catalogMu.Lock()
+	defer a.catalogMu.Unlock()
+
+	store := types.WithContext(r.Context(), a.storage)
+
+	c, err := a.loadCatalog(store)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	domains, ok := c.Files[file]
+	if !ok {
+		http.Error(w, fmt.Sprintf("file %s not in catalog", file), http.StatusNotFound)
+		return
+	}
+
+	delete(c.Files, file)
+
+	if err := a.saveCatalog(store, c); err != nil {
+		slog.ErrorContext(r.Context(), "failed to save catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, d := range domains {
+		a.unassign(store, file, d.Fqdn)
+	}
+
+	slog.InfoContext(r.Context(), "catalog file deleted", "file", file, "domains", len(domains))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAssignDomain handles admin requests for assigning a domain to a pin file of the catalogue.
+// It accepts PUT requests to /admin/v1/files/{file}/domains/{fqdn} with an optional
+// domainRequest body whose fqdn and file are taken from the path, saves the domain in the
+// catalogue and monitors it on this instance. Assigning a domain again replaces its settings.
+// Other instances monitor the domain after their next reload or restart.
+// Returns 201 with the domain key of a new domain or 200 of a replaced one, 400 if the body is
+// invalid, 404 if the file is not in the catalogue, 409 if the domain is configured, added with
+// /admin/v1/domains or assigned to another file, 413 if the body is too large, or 500 if the
+// catalogue cannot be read or saved.
+func (a *App) handleAssignDomain(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	fqdn := r.PathValue("fqdn")
+
+	var req domainRequest
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDomainRequestSize)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("request too large, limit is %d bytes", maxDomainRequestSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.Fqdn = fqdn
+	req.File = file
+
+	key, err := req.domainKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.catalogMu.Lock()
+	defer a.catalogMu.Unlock()
+
+	store := types.WithContext(r.Context(), a.storage)
+
+	c, err := a.loadCatalog(store)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	domains, ok := c.Files[file]
+	if !ok {
+		http.Error(w, fmt.Sprintf("file %s not in catalog", file), http.StatusNotFound)
+		return
+	}
+
+	assigned, ok := c.fileOf(fqdn)
+	if ok && assigned != file {
+		http.Error(w, fmt.Sprintf("domain %s assigned to file %s", fqdn, assigned), http.StatusConflict)
+		return
+	}
+
+	if _, monitored := a.keys.Get(fqdn); monitored && !ok {
+		http.Error(w, fmt.Sprintf("domain %s already monitored", fqdn), http.StatusConflict)
+		return
+	}
+
+	domains = slices.DeleteFunc(domains, func(d domainRequest) bool { return d.Fqdn == fqdn })
+	domains = append(domains, req)
+
+	slices.SortFunc(domains, func(a, b domainRequest) int {
+		return strings.Compare(a.Fqdn, b.Fqdn)
+	})
+
+	c.Files[file] = domains
+
+	if err := a.saveCatalog(store, c); err != nil {
+		slog.ErrorContext(r.Context(), "failed to save catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusCreated
+
+	// settings of an assigned domain are replaced by fetching it again
+	if ok {
+		a.keys.RemoveKey(fqdn)
+		status = http.StatusOK
+	}
+
+	a.keys.AddKey(fqdn, &key)
+
+	slog.InfoContext(r.Context(), "catalog domain assigned", "fqdn", fqdn, "file", file)
+
+	writeCatalogJSON(w, status, key)
+}
+
+// handleUnassignDomain handles admin requests for unassigning a domain from a pin file of the
+// catalogue. It accepts DELETE requests to /admin/v1/files/{file}/domains/{fqdn}, removes the
+// domain from the catalogue, stops monitoring it on this instance and deletes its keys from storage.
+// Returns 204 on success, 404 if the domain is not assigned to the file or 500 if the catalogue
+// cannot be read or saved.
+func (a *App) handleUnassignDomain(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	fqdn := r.PathValue("fqdn")
+
+	a.catalogMu.Lock()
+	defer a.catalogMu.Unlock()
+
+	store := types.WithContext(r.Context(), a.storage)
+
+	c, err := a.loadCatalog(store)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	domains := c.Files[file]
+
+	i := slices.IndexFunc(domains, func(d domainRequest) bool { return d.Fqdn == fqdn })
+	if i < 0 {
+		http.Error(w, fmt.Sprintf("domain %s not assigned to file %s", fqdn, file), http.StatusNotFound)
+		return
+	}
+
+	c.Files[file] = slices.Delete(domains, i, i+1)
+
+	if err := a.saveCatalog(store, c); err != nil {
+		slog.ErrorContext(r.Context(), "failed to save catalog", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	a.unassign(store, file, fqdn)
+
+	slog.InfoContext(r.Context(), "catalog domain unassigned", "fqdn", fqdn, "file", file)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unassign stops monitoring a domain removed from the catalogue and deletes its keys in file
+// from storage. Instances that still monitor the domain write its keys again with their next flush.
+func (a *App) unassign(store types.Storage, file, fqdn string) {
+	a.keys.RemoveKey(fqdn)
+
+	defer a.responses.invalidate()
+
+	// keys of a domain unassigned before the first flush are not stored yet
+	if err := store.DeleteKeys(file, fqdn); err != nil && !errors.Is(err, types.ErrNotFound) {
+		slog.Error("failed to delete keys", "file", file, "fqdn", fqdn, "error", err)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+func newCatalogMux(app *App) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/v1/files", app.handleCatalogFiles)
+	mux.HandleFunc("POST /admin/v1/files", app.handleCreateFile)
+	mux.HandleFunc("GET /admin/v1/files/{file}", app.handleCatalogFile)
+	mux.HandleFunc("DELETE /admin/v1/files/{file}", app.handleDeleteFile)
+	mux.HandleFunc("PUT /admin/v1/files/{file}/domains/{fqdn}", app.handleAssignDomain)
+	mux.HandleFunc("DELETE /admin/v1/files/{file}/domains/{fqdn}", app.handleUnassignDomain)
+
+	return mux
+}
+
+func TestApp_catalog(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	storage := newMockStorage()
+	app := &App{
+		keys:    newStatusKeys(t, types.DomainKey{Fqdn: "example.com", File: "example.com.json"}),
+		storage: storage,
+	}
+	mux := newCatalogMux(app)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/admin/v1/files", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"files": []}`, w.Body.String())
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/admin/v1/files", `{"file": "pins.json"}`).Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/admin/v1/files", `{"file": "pins.json"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/v1/files", `{"file": "../pins.json"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/v1/files", `{}`).Code)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/admin/v1/files/other.json/domains/api.example.org", "").Code, "file not in catalog")
+	assert.Equal(t, http.StatusConflict, do(http.MethodPut, "/admin/v1/files/pins.json/domains/example.com", "").Code, "domain configured")
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/admin/v1/files/pins.json/domains/api.example.org", `{"interval": "often"}`).Code)
+
+	w = do(http.MethodPut, "/admin/v1/files/pins.json/domains/api.example.org", "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	key, ok := app.keys.Get("api.example.org")
+	require.True(t, ok)
+	assert.Equal(t, "pins.json", key.File)
+
+	w = do(http.MethodPut, "/admin/v1/files/pins.json/domains/api.example.org", `{"file": "ignored.json", "domainName": "api.example.org"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	key, _ = app.keys.Get("api.example.org")
+	assert.Equal(t, "api.example.org", key.DomainName, "settings are replaced")
+	assert.Equal(t, "pins.json", key.File, "the file is taken from the path")
+
+	assert.Equal(t, http.StatusCreated, do(http.MethodPut, "/admin/v1/files/pins.json/domains/www.example.org", "").Code)
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/admin/v1/files", `{"file": "other.json"}`).Code)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPut, "/admin/v1/files/other.json/domains/www.example.org", "").Code, "assigned to another file")
+
+	w = do(http.MethodGet, "/admin/v1/files/pins.json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var file catalogFile
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&file))
+	assert.Equal(t, "pins.json", file.File)
+	require.Len(t, file.Domains, 2)
+	assert.Equal(t, "api.example.org", file.Domains[0].Fqdn)
+	assert.Equal(t, "www.example.org", file.Domains[1].Fqdn)
+
+	c, err := app.loadCatalog(storage)
+	require.NoError(t, err)
+	assert.Len(t, c.Files, 2, "the catalog is persisted")
+	assert.Equal(t, []string{"api.example.org", "www.example.org"}, fqdns(catalogKeys(c, nil)))
+	assert.Equal(t, []string{"www.example.org"}, fqdns(catalogKeys(c, []types.DomainKey{{Fqdn: "api.example.org"}})), "configured domains take precedence")
+
+	storage.keys["pins.json"] = []types.DomainKey{{Fqdn: "api.example.org", Key: "key1"}, {Fqdn: "www.example.org", Key: "key2"}}
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/v1/files/pins.json/domains/api.example.org", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/admin/v1/files/pins.json/domains/api.example.org", "").Code)
+
+	_, ok = app.keys.Get("api.example.org")
+	assert.False(t, ok, "unassigned domains are no longer monitored")
+	assert.Len(t, storage.keys["pins.json"], 1, "keys of unassigned domains are deleted")
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/v1/files/pins.json", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/admin/v1/files/pins.json", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/v1/files/pins.json", "").Code)
+
+	_, ok = app.keys.Get("www.example.org")
+	assert.False(t, ok)
+	assert.Empty(t, storage.keys["pins.json"])
+
+	w = do(http.MethodGet, "/admin/v1/files", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"files": [{"file": "other.json", "domains": []}]}`, w.Body.String())
+}
+
+func fqdns(keys []types.DomainKey) []string {
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, k.Fqdn)
+	}
+
+	return out
+}
@@ -0,0 +1,319 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ssl-pinning/internal/keys"
+	"ssl-pinning/internal/storage/types"
+)
+
+// maxDomainRequestSize is the largest request body accepted by handleAddDomain and handleStagePin.
+const maxDomainRequestSize = 64 << 10
+
+// domainRequest is the request body of handleAddDomain.
+// File and DomainName default as in the keys configuration (see types.DomainKey.WithDefaults),
+// an empty Connect fetches the certificate from the FQDN, an empty ServerName sends the host
+// of the FQDN as SNI, an empty Proxy selects tls.proxy and an empty Interval selects
+// tls.fetch_interval. ClientCert and ClientKey are paths of PEM files on the server and Chain
+// selects the certificates of the chain to pin (see keys.ValidateChain) and BackupPins are
+// published alongside them.
+type domainRequest struct {
+	Fqdn       string   `json:"fqdn"`
+	BackupPins []string `json:"backup_pins,omitempty"`
+	Chain      []string `json:"chain,omitempty"`
+	ClientCert string   `json:"client_cert,omitempty"`
+	ClientKey  string   `json:"client_key,omitempty"`
+	Connect    string   `json:"connect,omitempty"`
+	DomainName string   `json:"domainName,omitempty"`
+	File       string   `json:"file,omitempty"`
+	Interval   string   `json:"interval,omitempty"`
+	Proxy      string   `json:"proxy,omitempty"`
+	ServerName string   `json:"server_name,omitempty"`
+}
+
+// domainKey validates the request and returns the domain key it describes.
+func (d domainRequest) domainKey() (types.DomainKey, error) {
+	if d.Fqdn == "" {
+		return types.DomainKey{}, errors.New("fqdn required")
+	}
+
+	if host, port, err := net.SplitHostPort(d.Fqdn); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || host == "" {
+			return types.DomainKey{}, fmt.Errorf("invalid fqdn %q", d.Fqdn)
+		}
+	} else if strings.Contains(d.Fqdn, ":") {
+		return types.DomainKey{}, fmt.Errorf("invalid fqdn %q", d.Fqdn)
+	}
+
+	if strings.ContainsAny(d.Fqdn, " /") || strings.ContainsAny(d.Connect, " /") {
+		return types.DomainKey{}, fmt.Errorf("invalid fqdn %q or connect address %q", d.Fqdn, d.Connect)
+	}
+
+	if strings.ContainsAny(d.ServerName, " /:") {
+		return types.DomainKey{}, fmt.Errorf("invalid server name %q", d.ServerName)
+	}
+
+	if err := keys.ValidateChain(d.Chain); err != nil {
+		return types.DomainKey{}, err
+	}
+
+	if err := keys.ValidatePins(d.BackupPins); err != nil {
+		return types.DomainKey{}, err
+	}
+
+	if (d.ClientCert == "") != (d.ClientKey == "") {
+		return types.DomainKey{}, errors.New("client_cert and client_key must be set together")
+	}
+
+	if d.Proxy != "" {
+		if _, err := keys.ParseProxy(d.Proxy); err != nil {
+			return types.DomainKey{}, err
+		}
+	}
+
+	key := types.DomainKey{
+		Fqdn:       d.Fqdn,
+		BackupPins: d.BackupPins,
+		Chain:      d.Chain,
+		ClientCert: d.ClientCert,
+		ClientKey:  d.ClientKey,
+		Connect:    d.Connect,
+		DomainName: d.DomainName,
+		File:       d.File,
+		Proxy:      d.Proxy,
+		ServerName: d.ServerName,
+	}.WithDefaults()
+
+	if err := types.ValidateFile(key.File); err != nil {
+		return types.DomainKey{}, err
+	}
+
+	if d.Interval != "" {
+		interval, err := time.ParseDuration(d.Interval)
+		if err != nil {
+			return types.DomainKey{}, fmt.Errorf("invalid interval: %w", err)
+		}
+
+		if interval < 0 {
+			return types.DomainKey{}, fmt.Errorf("interval must not be negative, got %s", interval)
+		}
+
+		key.Interval = interval
+	}
+
+	return key, nil
+}
+
+// handleAddDomain handles admin requests for monitoring a new domain without a restart.
+// It accepts POST requests to /admin/v1/domains with a domainRequest body, adds the domain
+// to the keys of this instance and fetches its certificate immediately. Its keys are
+// written to storage by the next flush. Domains added at runtime are not persisted to the
+// configuration and are lost on restart.
+// Returns 201 with the domain key, 400 if the body is invalid, 409 if the domain is
+// already monitored, or 413 if the body is too large.
+func (a *App) handleAddDomain(w http.ResponseWriter, r *http.Request) {
+	var req domainRequest
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDomainRequestSize)).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("request too large, limit is %d bytes", maxDomainRequestSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := req.domainKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := a.keys.Get(key.Fqdn); ok {
+		http.Error(w, fmt.Sprintf("domain %s already monitored", key.Fqdn), http.StatusConflict)
+		return
+	}
+
+	a.keys.AddKey(key.Fqdn, &key)
+
+	slog.InfoContext(r.Context(), "domain added", "fqdn", key.Fqdn, "file", key.File)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(key); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// handleRemoveDomain handles admin requests for no longer monitoring a domain without a restart.
+// It accepts DELETE requests to /admin/v1/domains/{fqdn}, stops fetching the certificate of
+// the domain on this instance and deletes its keys from storage for all application instances.
+// Instances that still have the domain configured write its keys again with their next flush.
+// Returns 204 on success, 400 if fqdn is missing, 404 if the domain is not monitored,
+// or 500 if the keys could not be deleted from storage, in which case the domain stays monitored.
+func (a *App) handleRemoveDomain(w http.ResponseWriter, r *http.Request) {
+	fqdn := r.PathValue("fqdn")
+	if fqdn == "" {
+		http.Error(w, "fqdn required", http.StatusBadRequest)
+		return
+	}
+
+	key, ok := a.keys.RemoveKey(fqdn)
+	if !ok {
+		http.Error(w, fmt.Sprintf("domain %s not monitored", fqdn), http.StatusNotFound)
+		return
+	}
+
+	defer a.responses.invalidate()
+
+	// keys of a domain removed before the first flush are not stored yet
+	if err := types.WithContext(r.Context(), a.storage).DeleteKeys(key.File, fqdn); err != nil && !errors.Is(err, types.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "failed to delete keys", "file", key.File, "fqdn", fqdn, "error", err)
+
+		// keep monitoring the domain so the request can be retried
+		a.keys.AddKey(fqdn, &key)
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "domain removed", "fqdn", fqdn, "file", key.File)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDomainStatus handles admin requests for the state of the certificate fetches of a domain.
+// It accepts GET requests to /admin/v1/domains/{fqdn}/status and returns the current pin and
+// expiry, the latest fetch and successful fetch, the latest error, the number of consecutive
+// failed fetches and the next scheduled fetch of the domain on this instance (see keys.Keys.Status).
+// Returns 200 with the status, 400 if fqdn is missing or 404 if the domain is not monitored.
+func (a *App) handleDomainStatus(w http.ResponseWriter, r *http.Request) {
+	fqdn := r.PathValue("fqdn")
+	if fqdn == "" {
+		http.Error(w, "fqdn required", http.StatusBadRequest)
+		return
+	}
+
+	status, ok := a.keys.Status(fqdn)
+	if !ok {
+		http.Error(w, fmt.Sprintf("domain %s not monitored", fqdn), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// stagedPin is the response of handleStagePin.
+type stagedPin struct {
+	Fqdn       string   `json:"fqdn"`
+	Pin        string   `json:"pin"`
+	BackupPins []string `json:"backup_pins"`
+}
+
+// handleStagePin handles admin requests for publishing the pin of an upcoming certificate of a
+// domain before it is deployed. It accepts POST requests to /admin/v1/domains/{fqdn}/pins with a
+// PEM encoded certificate, certificate signing request or public key body (see keys.ParsePin)
+// and adds its pin to the backup pins of the domain (see keys.Keys.StagePin). Its keys are
+// written to storage by the next flush. Staged pins are not persisted to the configuration.
+// Returns 201 with the pin and the backup pins of the domain, 400 if the body is invalid,
+// 404 if the domain is not monitored, or 413 if the body is too large.
+func (a *App) handleStagePin(w http.ResponseWriter, r *http.Request) {
+	fqdn := r.PathValue("fqdn")
+	if fqdn == "" {
+		http.Error(w, "fqdn required", http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDomainRequestSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("request too large, limit is %d bytes", maxDomainRequestSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pin, err := keys.ParsePin(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, ok := a.keys.StagePin(fqdn, pin)
+	if !ok {
+		http.Error(w, fmt.Sprintf("domain %s not monitored", fqdn), http.StatusNotFound)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "pin staged", "fqdn", fqdn, "pin", pin)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(stagedPin{Fqdn: fqdn, Pin: pin, BackupPins: key.BackupPins}); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// handlePinChanges handles admin requests for the most recent pin changes of domains.
+// It accepts GET requests to /admin/v1/pin-changes, optionally filtered by the fqdn query
+// parameter, and returns 200 with the pin changes, newest first (see keys.Keys.PinChanges).
+func (a *App) handlePinChanges(w http.ResponseWriter, r *http.Request) {
+	changes := a.keys.PinChanges(r.URL.Query().Get("fqdn"))
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(changes); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,388 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/keys"
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestApp_handleAddDomain(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatusCode int
+		wantKey        types.DomainKey
+	}{
+		{
+			name:           "defaults",
+			body:           `{"fqdn": "example.org"}`,
+			wantStatusCode: http.StatusCreated,
+			wantKey:        types.DomainKey{Fqdn: "example.org", File: "example.org.json", DomainName: "*.example.org"},
+		},
+		{
+			name:           "file, domain name and interval",
+			body:           `{"fqdn": "api.example.org", "file": "pins.json", "domainName": "api.example.org", "interval": "5m"}`,
+			wantStatusCode: http.StatusCreated,
+			wantKey:        types.DomainKey{Fqdn: "api.example.org", File: "pins.json", DomainName: "api.example.org", Interval: 5 * time.Minute},
+		},
+		{name: "already monitored", body: `{"fqdn": "example.com"}`, wantStatusCode: http.StatusConflict},
+		{name: "missing fqdn", body: `{"file": "pins.json"}`, wantStatusCode: http.StatusBadRequest},
+		{
+			name:           "port and connect address",
+			body:           `{"fqdn": "internal.example.org:8443", "connect": "10.0.0.1"}`,
+			wantStatusCode: http.StatusCreated,
+			wantKey:        types.DomainKey{Fqdn: "internal.example.org:8443", Connect: "10.0.0.1", File: "internal.example.org:8443.json", DomainName: "*.internal.example.org"},
+		},
+		{
+			name:           "server name",
+			body:           `{"fqdn": "lb.example.org", "connect": "10.0.0.1", "server_name": "www.example.org", "proxy": "direct"}`,
+			wantStatusCode: http.StatusCreated,
+			wantKey:        types.DomainKey{Fqdn: "lb.example.org", Connect: "10.0.0.1", File: "lb.example.org.json", DomainName: "*.lb.example.org", Proxy: "direct", ServerName: "www.example.org"},
+		},
+		{
+			name:           "chain",
+			body:           `{"fqdn": "example.org", "chain": ["leaf", "intermediate"]}`,
+			wantStatusCode: http.StatusCreated,
+			wantKey:        types.DomainKey{Fqdn: "example.org", Chain: []string{"leaf", "intermediate"}, File: "example.org.json", DomainName: "*.example.org"},
+		},
+		{
+			name:           "backup pins",
+			body:           `{"fqdn": "example.org", "backup_pins": ["47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="]}`,
+			wantStatusCode: http.StatusCreated,
+			wantKey:        types.DomainKey{Fqdn: "example.org", BackupPins: []string{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}, File: "example.org.json", DomainName: "*.example.org"},
+		},
+		{name: "invalid backup pin", body: `{"fqdn": "example.org", "backup_pins": ["next"]}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid chain", body: `{"fqdn": "example.org", "chain": ["issuer"]}`, wantStatusCode: http.StatusBadRequest},
+		{name: "client certificate without key", body: `{"fqdn": "example.org", "client_cert": "/etc/ssl-pinning/client.pem"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid proxy", body: `{"fqdn": "example.org", "proxy": "ftp://proxy.internal"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid server name", body: `{"fqdn": "lb.example.org", "server_name": "www.example.org:443"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid fqdn", body: `{"fqdn": "example.org/path"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid port", body: `{"fqdn": "example.org:https"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid connect address", body: `{"fqdn": "example.org", "connect": "10.0.0.1/8"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid file", body: `{"fqdn": "example.org", "file": "../pins.json"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid interval", body: `{"fqdn": "example.org", "interval": "often"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "negative interval", body: `{"fqdn": "example.org", "interval": "-1m"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid body", body: `{`, wantStatusCode: http.StatusBadRequest},
+		{name: "body too large", body: `{"fqdn": "` + strings.Repeat("a", maxDomainRequestSize) + `"}`, wantStatusCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				keys:    newStatusKeys(t, types.DomainKey{Fqdn: "example.com", File: "example.com.json"}),
+				storage: newMockStorage(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/v1/domains", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			app.handleAddDomain(w, req)
+
+			require.Equal(t, tt.wantStatusCode, w.Code, w.Body.String())
+
+			if tt.wantStatusCode != http.StatusCreated {
+				assert.Len(t, app.keys.Snapshot(), 1)
+				return
+			}
+
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var res types.DomainKey
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			assert.Equal(t, tt.wantKey.Fqdn, res.Fqdn)
+			assert.Equal(t, tt.wantKey.File, res.File)
+
+			key, ok := app.keys.Get(tt.wantKey.Fqdn)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantKey, key)
+		})
+	}
+}
+
+// deleteErrorStorage fails to delete keys.
+type deleteErrorStorage struct {
+	*mockStorage
+}
+
+func (m *deleteErrorStorage) DeleteKeys(file, fqdn string) error {
+	return assert.AnError
+}
+
+func TestApp_handleRemoveDomain(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name           string
+		fqdn           string
+		storage        func() types.Storage
+		wantStatusCode int
+		wantMonitored  bool
+		wantKeys       int
+	}{
+		{name: "success", fqdn: "www.example.com", wantStatusCode: http.StatusNoContent, wantKeys: 1},
+		{name: "not stored yet", fqdn: "new.example.com", wantStatusCode: http.StatusNoContent, wantKeys: 2},
+		{name: "not monitored", fqdn: "www.unknown.com", wantStatusCode: http.StatusNotFound, wantKeys: 2},
+		{name: "missing fqdn", fqdn: "", wantStatusCode: http.StatusBadRequest, wantKeys: 2},
+		{
+			name: "storage error",
+			fqdn: "www.example.com",
+			storage: func() types.Storage {
+				return &deleteErrorStorage{mockStorage: newMockStorage()}
+			},
+			wantStatusCode: http.StatusInternalServerError,
+			wantMonitored:  true,
+			wantKeys:       2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newMockStorage()
+			storage.keys["test.json"] = []types.DomainKey{
+				{Fqdn: "www.example.com", Key: "key1"},
+				{Fqdn: "www.test.com", Key: "key2"},
+			}
+
+			app := &App{
+				keys: newStatusKeys(t,
+					types.DomainKey{Fqdn: "www.example.com", File: "test.json", Key: "key1"},
+					types.DomainKey{Fqdn: "new.example.com", File: "test.json"},
+				),
+				storage: storage,
+			}
+			if tt.storage != nil {
+				app.storage = tt.storage()
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/v1/domains/"+tt.fqdn, nil)
+			req.SetPathValue("fqdn", tt.fqdn)
+			w := httptest.NewRecorder()
+
+			app.handleRemoveDomain(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Len(t, storage.keys["test.json"], tt.wantKeys)
+
+			if tt.fqdn != "" {
+				_, ok := app.keys.Get(tt.fqdn)
+				assert.Equal(t, tt.wantMonitored, ok)
+			}
+		})
+	}
+}
+
+func TestApp_handleDomainStatus(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name           string
+		fqdn           string
+		wantStatusCode int
+	}{
+		{name: "monitored", fqdn: "www.example.com", wantStatusCode: http.StatusOK},
+		{name: "not monitored", fqdn: "www.unknown.com", wantStatusCode: http.StatusNotFound},
+		{name: "missing fqdn", fqdn: "", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				keys: newStatusKeys(t, types.DomainKey{
+					Date:          &now,
+					ErrorCategory: "timeout",
+					Expire:        3600,
+					File:          "test.json",
+					Fqdn:          "www.example.com",
+					Key:           "key1",
+					LastError:     "i/o timeout",
+				}),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/v1/domains/"+tt.fqdn+"/status", nil)
+			req.SetPathValue("fqdn", tt.fqdn)
+			w := httptest.NewRecorder()
+
+			app.handleDomainStatus(w, req)
+
+			require.Equal(t, tt.wantStatusCode, w.Code, w.Body.String())
+
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var res keys.DomainStatus
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			assert.Equal(t, "www.example.com", res.Fqdn)
+			assert.Equal(t, "test.json", res.File)
+			assert.Equal(t, "key1", res.Pin)
+			assert.Equal(t, int64(3600), res.Expire)
+			assert.Equal(t, "i/o timeout", res.LastError)
+			assert.Equal(t, "timeout", res.ErrorCategory)
+			require.NotNil(t, res.LastFetch)
+			assert.True(t, now.Equal(*res.LastFetch))
+		})
+	}
+}
+
+func TestApp_handleStagePin(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: tmpl.Subject}, priv)
+	require.NoError(t, err)
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+
+	pin, err := keys.ParsePin([]byte(certPEM))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		fqdn           string
+		body           string
+		wantStatusCode int
+		wantPins       []string
+	}{
+		{name: "certificate", fqdn: "www.example.com", body: certPEM, wantStatusCode: http.StatusCreated, wantPins: []string{"key1", pin}},
+		{name: "certificate request", fqdn: "www.example.com", body: csrPEM, wantStatusCode: http.StatusCreated, wantPins: []string{"key1", pin}},
+		{name: "not fetched yet", fqdn: "new.example.com", body: certPEM, wantStatusCode: http.StatusCreated},
+		{name: "not monitored", fqdn: "www.unknown.com", body: certPEM, wantStatusCode: http.StatusNotFound},
+		{name: "missing fqdn", fqdn: "", body: certPEM, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid body", fqdn: "www.example.com", body: "not a certificate", wantStatusCode: http.StatusBadRequest},
+		{name: "body too large", fqdn: "www.example.com", body: strings.Repeat("a", maxDomainRequestSize+1), wantStatusCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				keys: newStatusKeys(t,
+					types.DomainKey{Fqdn: "www.example.com", File: "test.json", Key: "key1"},
+					types.DomainKey{Fqdn: "new.example.com", File: "test.json"},
+				),
+				storage: newMockStorage(),
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/v1/domains/"+tt.fqdn+"/pins", strings.NewReader(tt.body))
+			req.SetPathValue("fqdn", tt.fqdn)
+			w := httptest.NewRecorder()
+
+			app.handleStagePin(w, req)
+
+			require.Equal(t, tt.wantStatusCode, w.Code, w.Body.String())
+
+			if tt.wantStatusCode != http.StatusCreated {
+				return
+			}
+
+			var res stagedPin
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			assert.Equal(t, tt.fqdn, res.Fqdn)
+			assert.Equal(t, pin, res.Pin)
+			assert.Equal(t, []string{pin}, res.BackupPins)
+
+			key, ok := app.keys.Get(tt.fqdn)
+			require.True(t, ok)
+			assert.Equal(t, []string{pin}, key.BackupPins)
+			assert.Equal(t, tt.wantPins, key.Pins)
+		})
+	}
+}
+
+func TestApp_handlePinChanges(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "all domains", query: ""},
+		{name: "domain", query: "?fqdn=www.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				keys: newStatusKeys(t, types.DomainKey{Fqdn: "www.example.com", File: "test.json", Key: "key1"}),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/v1/pin-changes"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			app.handlePinChanges(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var res []keys.PinChange
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			assert.NotNil(t, res)
+			assert.Empty(t, res)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// handleFileFormat handles HTTP requests for a file rendered as client configuration.
+// It serves GET requests to /api/v1/{file}?format={format} with the keys of the file in the
+// format (see types.Format), e.g. the TrustKit configuration of mobile apps. Configurations
+// are not signed, they are meant to be built into clients rather than fetched at runtime.
+// Returns 400 if the format is unknown, 404 if the file is not found, 409 if a domain lacks the
+// backup pin the format requires (see types.ErrTooFewPins), or 500 on internal errors.
+func (a *App) handleFileFormat(w http.ResponseWriter, r *http.Request, file string) {
+	format, err := types.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.DebugContext(r.Context(), "request", "req", r.URL.Path, "file", file, "format", format)
+
+	keys, err := a.loadKeys(r.Context(), file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(keys) == 0 {
+		http.Error(w, fmt.Sprintf("file %s not found", file), http.StatusNotFound)
+		return
+	}
+
+	data, err := format.Render(keys)
+	if errors.Is(err, types.ErrTooFewPins) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	modified, err := lastModified(keys, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", a.cacheControl())
+	w.Header().Set("Content-Type", format.MediaType())
+	w.Header().Set("ETag", etag(data))
+
+	http.ServeContent(w, r, file, modified, bytes.NewReader(data))
+}
@@ -0,0 +1,152 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestApp_handleFileJSON_Format(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t)
+
+	pin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	backup := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storage := newMockStorage()
+	storage.keys["test.json"] = []types.DomainKey{
+		{Date: &now, Expire: 3600, Fqdn: "api.example.com", Key: pin, Pins: []string{pin, backup}},
+	}
+
+	tests := []struct {
+		name            string
+		file            string
+		query           string
+		wantStatusCode  int
+		wantContentType string
+		wantContains    string
+	}{
+		{name: "trustkit", file: "test.json", query: "?format=trustkit", wantStatusCode: http.StatusOK, wantContentType: "application/json", wantContains: `"kTSKEnforcePinning": true`},
+		{name: "trustkit plist", file: "test.json", query: "?format=trustkit-plist", wantStatusCode: http.StatusOK, wantContentType: "application/x-plist", wantContains: "<string>" + backup + "</string>"},
+		{name: "okhttp", file: "test.json", query: "?format=okhttp", wantStatusCode: http.StatusOK, wantContentType: "application/json", wantContains: `"sha256/` + backup + `"`},
+		{name: "okhttp kotlin", file: "test.json", query: "?format=okhttp-kotlin", wantStatusCode: http.StatusOK, wantContentType: "text/x-kotlin; charset=utf-8", wantContains: `"api.example.com",`},
+		{name: "hpkp", file: "test.json", query: "?format=hpkp", wantStatusCode: http.StatusOK, wantContentType: "text/plain; charset=utf-8", wantContains: `pin-sha256="` + backup + `"`},
+		{name: "curl", file: "test.json", query: "?format=curl", wantStatusCode: http.StatusOK, wantContentType: "text/plain; charset=utf-8", wantContains: "sha256//" + pin + ";sha256//" + backup},
+		{name: "unknown format", file: "test.json", query: "?format=android", wantStatusCode: http.StatusBadRequest},
+		{name: "file not found", file: "missing.json", query: "?format=trustkit", wantStatusCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				storage: storage,
+				signer:  testSigner,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+tt.file+tt.query, nil)
+			req.SetPathValue("file", tt.file)
+			w := httptest.NewRecorder()
+
+			app.handleFileJSON(w, req)
+
+			require.Equal(t, tt.wantStatusCode, w.Code)
+
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			assert.NotEmpty(t, w.Header().Get("ETag"))
+			assert.Equal(t, now.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+
+			assert.Contains(t, w.Body.String(), tt.wantContains)
+		})
+	}
+}
+
+func TestApp_handleFileJSON_FormatSinglePin(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t)
+
+	pin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storage := newMockStorage()
+	storage.keys["test.json"] = []types.DomainKey{
+		{Date: &now, Expire: 3600, Fqdn: "api.example.com", Key: pin},
+	}
+
+	tests := []struct {
+		format         string
+		wantStatusCode int
+	}{
+		{format: "trustkit", wantStatusCode: http.StatusConflict},
+		{format: "trustkit-plist", wantStatusCode: http.StatusConflict},
+		{format: "okhttp", wantStatusCode: http.StatusConflict},
+		{format: "okhttp-kotlin", wantStatusCode: http.StatusConflict},
+		{format: "hpkp", wantStatusCode: http.StatusConflict},
+		{format: "curl", wantStatusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			app := &App{
+				storage: storage,
+				signer:  testSigner,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/test.json?format="+tt.format, nil)
+			req.SetPathValue("file", "test.json")
+			w := httptest.NewRecorder()
+
+			app.handleFileJSON(w, req)
+
+			require.Equal(t, tt.wantStatusCode, w.Code, w.Body.String())
+
+			if tt.wantStatusCode == http.StatusConflict {
+				assert.Contains(t, w.Body.String(), "api.example.com has no backup pin")
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// historySize is the number of versions of a file kept to answer delta requests;
+// older versions are answered with a reset (see types.Delta).
+const historySize = 64
+
+// snapshot is a version of the keys of a file and the time it was first seen by this instance.
+type snapshot struct {
+	keys    []types.DomainKey
+	seen    time.Time
+	version string
+}
+
+// history keeps the recent versions of the keys of every file, recorded on flush and on
+// delta requests, to compute the changes since a version a client has (see handleFileDelta).
+// Versions are derived from the pins (see types.Version), so a version returned by one
+// instance is known to the others once they have seen the same pins.
+type history struct {
+	files map[string][]snapshot
+	mu    sync.Mutex
+}
+
+// newHistory creates an empty history.
+func newHistory() *history {
+	return &history{
+		files: make(map[string][]snapshot),
+	}
+}
+
+// record adds the keys of a file seen at the given time as its latest version, unless it is
+// the latest version already, and returns the version. The oldest versions beyond historySize are dropped.
+func (h *history) record(file string, keys []types.DomainKey, seen time.Time) string {
+	version := types.Version(keys)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshots := h.files[file]
+	if n := len(snapshots); n > 0 && snapshots[n-1].version == version {
+		return version
+	}
+
+	snapshots = append(snapshots, snapshot{keys: slices.Clone(keys), seen: seen, version: version})
+	if len(snapshots) > historySize {
+		snapshots = slices.Delete(snapshots, 0, len(snapshots)-historySize)
+	}
+
+	h.files[file] = snapshots
+
+	return version
+}
+
+// version returns the keys of a version of a file, or false if the version is unknown.
+func (h *history) version(file, version string) ([]types.DomainKey, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, s := range h.files[file] {
+		if s.version == version {
+			return s.keys, true
+		}
+	}
+
+	return nil, false
+}
+
+// at returns the keys of a file at the given time, the latest version seen at or before it,
+// or false if the file was not seen before it.
+func (h *history) at(file string, t time.Time) ([]types.DomainKey, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshots := h.files[file]
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].seen.After(t) {
+			return snapshots[i].keys, true
+		}
+	}
+
+	return nil, false
+}
+
+// handleFileView handles HTTP requests for the alternative views of a file at /api/v1/{file}/{view}.
+// Returns 404 if the view is unknown.
+func (a *App) handleFileView(w http.ResponseWriter, r *http.Request) {
+	switch r.PathValue("view") {
+	case "delta":
+		a.handleFileDelta(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleFileDelta handles HTTP requests for the changes of the keys of a file since a version.
+// It accepts GET requests to /api/v1/{file}/delta and returns a types.Delta signed like the file
+// (see types.SignedDelta) with the keys added or changed and the FQDNs removed since the version
+// in the since query parameter, and the current version as cursor for the next request.
+// since is either a cursor returned by a previous delta request or subscription message, or an
+// RFC 3339 time. Unknown or missing versions, e.g. older than historySize versions or predating
+// the start of the instance, are answered with a reset containing all keys. Pins are encoded
+// with the pin_encoding query parameter (see pinEncoding).
+// Returns 400 if the file name or pin encoding is invalid, 404 if the file is not found, or 500 on internal errors.
+func (a *App) handleFileDelta(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	if err := types.ValidateFile(file); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	encoding, err := a.pinEncoding(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keys, err := a.loadKeys(r.Context(), file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(keys) == 0 {
+		http.Error(w, fmt.Sprintf("file %s not found", file), http.StatusNotFound)
+		return
+	}
+
+	version := a.history.record(file, keys, time.Now().UTC())
+
+	var (
+		previous []types.DomainKey
+		found    bool
+	)
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			previous, found = a.history.at(file, t)
+		} else {
+			previous, found = a.history.version(file, since)
+		}
+	}
+
+	delta := types.Diff(file, previous, keys)
+	delta.Cursor = version
+	delta.Reset = !found
+
+	if delta.Changed, err = types.EncodePins(delta.Changed, encoding); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := a.sign(delta.File, func() ([]byte, error) {
+		return types.SignedDelta(delta, a.signer)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.DebugContext(r.Context(), "delta", "file", file, "changed", len(delta.Changed), "removed", len(delta.Removed), "reset", delta.Reset)
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(data); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/storage/types"
+	"ssl-pinning/pkg/verify"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	v1 := []types.DomainKey{{Fqdn: "www.example.com", Key: "key1"}}
+	v2 := []types.DomainKey{{Fqdn: "www.example.com", Key: "key2"}}
+
+	h := newHistory()
+
+	version1 := h.record("a.json", v1, start)
+	assert.Equal(t, version1, h.record("a.json", v1, start.Add(time.Minute)), "unchanged keys keep their version")
+	version2 := h.record("a.json", v2, start.Add(time.Hour))
+	assert.NotEqual(t, version1, version2)
+
+	keys, ok := h.version("a.json", version1)
+	require.True(t, ok)
+	assert.Equal(t, v1, keys)
+
+	_, ok = h.version("b.json", version1)
+	assert.False(t, ok)
+
+	keys, ok = h.at("a.json", start.Add(30*time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, v1, keys, "unchanged keys keep the time they were first seen")
+
+	keys, ok = h.at("a.json", start.Add(2*time.Hour))
+	require.True(t, ok)
+	assert.Equal(t, v2, keys)
+
+	_, ok = h.at("a.json", start.Add(-time.Second))
+	assert.False(t, ok)
+
+	for i := range historySize {
+		h.record("a.json", []types.DomainKey{{Fqdn: "www.example.com", Key: string(rune('a' + i))}}, start.Add(2*time.Hour))
+	}
+
+	assert.Len(t, h.files["a.json"], historySize)
+
+	_, ok = h.version("a.json", version2)
+	assert.False(t, ok, "oldest versions are dropped")
+}
+
+func TestApp_handleFileDelta(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Now()
+
+	testSigner, _ := setupTestSigner(t)
+
+	verifier, err := verify.New(testSigner.PublicKeys()...)
+	require.NoError(t, err)
+
+	storage := newMockStorage()
+	storage.keys["a.json"] = []types.DomainKey{
+		{Date: &now, Fqdn: "www.example.com", Key: "key1"},
+		{Date: &now, Fqdn: "api.example.com", Key: "key2"},
+	}
+
+	app := &App{history: newHistory(), signer: testSigner, storage: storage}
+
+	get := func(t *testing.T, file, query string) (int, types.Delta) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/"+file+"/delta?"+query, nil)
+		req.SetPathValue("file", file)
+		req.SetPathValue("view", "delta")
+		w := httptest.NewRecorder()
+
+		app.handleFileView(w, req)
+
+		if w.Code != http.StatusOK {
+			return w.Code, types.Delta{}
+		}
+
+		_, err := verifier.Verify(w.Body.Bytes())
+		require.NoError(t, err)
+
+		var signed struct {
+			Payload types.Delta `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &signed))
+
+		return w.Code, signed.Payload
+	}
+
+	status, first := get(t, "a.json", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, first.Reset)
+	assert.Len(t, first.Changed, 2)
+	assert.NotEmpty(t, first.Cursor)
+
+	status, unchanged := get(t, "a.json", "since="+first.Cursor)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, unchanged.Reset)
+	assert.True(t, unchanged.Empty())
+	assert.Equal(t, first.Cursor, unchanged.Cursor)
+
+	storage.keys["a.json"] = []types.DomainKey{
+		{Date: &now, Fqdn: "www.example.com", Key: "key3"},
+		{Date: &now, Fqdn: "new.example.com", Key: "key4"},
+	}
+
+	status, delta := get(t, "a.json", "since="+first.Cursor)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, delta.Reset)
+	require.Len(t, delta.Changed, 2)
+	assert.Equal(t, "new.example.com", delta.Changed[0].Fqdn)
+	assert.Equal(t, "www.example.com", delta.Changed[1].Fqdn)
+	assert.Equal(t, []string{"api.example.com"}, delta.Removed)
+	assert.NotEqual(t, first.Cursor, delta.Cursor)
+
+	status, delta = get(t, "a.json", "since="+now.Add(time.Hour).UTC().Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, delta.Reset)
+	assert.True(t, delta.Empty())
+
+	status, delta = get(t, "a.json", "since="+now.Add(-time.Hour).UTC().Format(time.RFC3339))
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, delta.Reset, "time before the history")
+	assert.Len(t, delta.Changed, 2)
+
+	status, delta = get(t, "a.json", "since=unknown")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, delta.Reset)
+
+	status, _ = get(t, "missing.json", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = get(t, ".a.json", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = get(t, "a.json", "pin_encoding=base32")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/a.json/other", nil)
+	req.SetPathValue("file", "a.json")
+	req.SetPathValue("view", "other")
+	w := httptest.NewRecorder()
+
+	app.handleFileView(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestApp_observe(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	storage := newMockStorage()
+	storage.keys["a.json"] = []types.DomainKey{{Fqdn: "www.example.com", Key: "key1"}}
+
+	app := &App{history: newHistory(), storage: storage}
+
+	require.NoError(t, app.flush(nil))
+
+	_, ok := app.history.version("a.json", types.Version(storage.keys["a.json"]))
+	assert.True(t, ok, "flush records the keys in the history")
+}
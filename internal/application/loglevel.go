@@ -0,0 +1,167 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/config"
+	"ssl-pinning/internal/logging"
+)
+
+// maxLogLevelsRequestSize is the maximum size of the body of requests to /admin/v1/loglevel.
+const maxLogLevelsRequestSize = 16 << 10
+
+// logLevels are the log levels in requests to and responses of /admin/v1/loglevel.
+// Packages are named by their path below internal, e.g. keys (see logging.Levels).
+type logLevels struct {
+	Level    string            `json:"level,omitempty"`
+	Packages map[string]string `json:"packages,omitempty"`
+}
+
+// configuredLogLevels returns the log levels of the configuration. An unknown log.level is
+// taken as info, as by the logger; the levels of log.packages are validated by config.New.
+func configuredLogLevels(cfg config.ConfigLog) logging.Levels {
+	l := logging.Levels{
+		Level:    logger.ParseLevel(cfg.Level),
+		Packages: make(map[string]slog.Level, len(cfg.Packages)),
+	}
+
+	for pkg, level := range cfg.Packages {
+		l.Packages[pkg], _ = logging.ParseLevel(level)
+	}
+
+	return l
+}
+
+// currentLogLevels returns the log levels in effect.
+func currentLogLevels() logLevels {
+	l := logging.CurrentLevels()
+
+	res := logLevels{Level: strings.ToLower(l.Level.String())}
+
+	if len(l.Packages) > 0 {
+		res.Packages = make(map[string]string, len(l.Packages))
+		for pkg, level := range l.Packages {
+			res.Packages[pkg] = strings.ToLower(level.String())
+		}
+	}
+
+	return res
+}
+
+// apply returns levels changed by the request: the level unless empty and the levels of the
+// packages, where an empty level removes the level of the package.
+func (req logLevels) apply(levels logging.Levels) (logging.Levels, error) {
+	if req.Level != "" {
+		level, err := logging.ParseLevel(req.Level)
+		if err != nil {
+			return levels, err
+		}
+
+		levels.Level = level
+	}
+
+	levels.Packages = maps.Clone(levels.Packages)
+	if levels.Packages == nil {
+		levels.Packages = make(map[string]slog.Level, len(req.Packages))
+	}
+
+	for pkg, value := range req.Packages {
+		if value == "" {
+			delete(levels.Packages, pkg)
+			continue
+		}
+
+		level, err := logging.ParseLevel(value)
+		if err != nil {
+			return levels, fmt.Errorf("package %s: %w", pkg, err)
+		}
+
+		levels.Packages[pkg] = level
+	}
+
+	return levels, nil
+}
+
+// handleLogLevel handles admin requests for the log levels of this instance, e.g. to log the
+// keys worker at debug level while investigating a domain without a redeploy.
+// GET requests to /admin/v1/loglevel return the log levels in effect, PUT requests with a
+// logLevels body change the level and the levels of the packages given, where an empty
+// level removes the level of a package, and DELETE requests restore the configured levels.
+// Changes are not persisted and are lost on restart.
+// Returns 200 with the log levels, 400 if the body is invalid, or 413 if the body is too large.
+func (a *App) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		var req logLevels
+
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLogLevelsRequestSize)).Decode(&req); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, fmt.Sprintf("request too large, limit is %d bytes", maxLogLevelsRequestSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		levels, err := req.apply(logging.CurrentLevels())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logging.SetLevels(levels)
+
+		slog.InfoContext(r.Context(), "log levels changed", "level", req.Level, "packages", req.Packages)
+	case http.MethodDelete:
+		logging.SetLevels(configuredLogLevels(a.config.Log))
+
+		slog.InfoContext(r.Context(), "log levels restored")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(currentLogLevels()); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
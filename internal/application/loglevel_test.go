@@ -0,0 +1,99 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/config"
+	"ssl-pinning/internal/logging"
+)
+
+func TestApp_handleLogLevel(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	t.Cleanup(func() { logging.SetLevels(logging.Levels{Level: slog.LevelInfo}) })
+
+	app := &App{
+		config: config.Config{Log: config.ConfigLog{Level: "info", Packages: map[string]string{"storage": "warn"}}},
+	}
+
+	logging.SetLevels(configuredLogLevels(app.config.Log))
+
+	request := func(method, body string, wantCode int) logLevels {
+		t.Helper()
+
+		req := httptest.NewRequest(method, "/admin/v1/loglevel", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		app.handleLogLevel(w, req)
+
+		require.Equal(t, wantCode, w.Code, w.Body.String())
+
+		var res logLevels
+		if wantCode == http.StatusOK {
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		}
+
+		return res
+	}
+
+	configured := logLevels{Level: "info", Packages: map[string]string{"storage": "warn"}}
+	assert.Equal(t, configured, request(http.MethodGet, "", http.StatusOK))
+
+	res := request(http.MethodPut, `{"packages": {"keys": "debug", "storage": ""}}`, http.StatusOK)
+	assert.Equal(t, logLevels{Level: "info", Packages: map[string]string{"keys": "debug"}}, res)
+
+	res = request(http.MethodPut, `{"level": "warn"}`, http.StatusOK)
+	assert.Equal(t, logLevels{Level: "warn", Packages: map[string]string{"keys": "debug"}}, res)
+	assert.Equal(t, slog.LevelWarn, logging.CurrentLevels().Level)
+
+	// invalid requests keep the levels
+	request(http.MethodPut, `{"level": "verbose"}`, http.StatusBadRequest)
+	request(http.MethodPut, `{"packages": {"keys": "verbose"}}`, http.StatusBadRequest)
+	request(http.MethodPut, `{`, http.StatusBadRequest)
+	request(http.MethodPut, `{"level": "`+strings.Repeat("a", maxLogLevelsRequestSize)+`"}`, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, res, request(http.MethodGet, "", http.StatusOK))
+
+	assert.Equal(t, configured, request(http.MethodDelete, "", http.StatusOK))
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maintenanceStatus reports whether certificate fetching is paused for maintenance.
+type maintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// maintenance returns the maintenance status of the keys worker.
+func (a *App) maintenance() maintenanceStatus {
+	if a.keys == nil {
+		return maintenanceStatus{}
+	}
+
+	since, paused := a.keys.Paused()
+	if !paused {
+		return maintenanceStatus{}
+	}
+
+	since = since.UTC()
+
+	return maintenanceStatus{Enabled: true, Since: &since}
+}
+
+// handleMaintenance handles admin requests for the maintenance mode of this instance.
+// GET requests to /admin/v1/maintenance return the maintenance status, PUT requests pause
+// certificate fetching (see keys.Keys.Pause) and DELETE requests resume it. While paused the
+// keys fetched last stay published and the health probes of storage do not count the time
+// spent paused towards the key age (see types.Staleness).
+// Returns 200 with the maintenance status.
+func (a *App) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		if a.keys.Pause() {
+			since, _ := a.keys.Paused()
+			a.staleness.Pause(since)
+
+			slog.InfoContext(r.Context(), "maintenance enabled")
+		}
+	case http.MethodDelete:
+		if a.keys.Resume() {
+			a.staleness.Resume(time.Now())
+
+			slog.InfoContext(r.Context(), "maintenance disabled")
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(a.maintenance()); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
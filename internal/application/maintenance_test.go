@@ -0,0 +1,92 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestApp_handleMaintenance(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	app := &App{
+		keys:      newStatusKeys(t),
+		staleness: types.NewStaleness(),
+	}
+
+	request := func(method string) maintenanceStatus {
+		t.Helper()
+
+		req := httptest.NewRequest(method, "/admin/v1/maintenance", nil)
+		w := httptest.NewRecorder()
+
+		app.handleMaintenance(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var res maintenanceStatus
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+
+		return res
+	}
+
+	assert.Equal(t, maintenanceStatus{}, request(http.MethodGet))
+
+	date := time.Now().Add(-time.Minute)
+
+	res := request(http.MethodPut)
+	require.True(t, res.Enabled)
+	require.NotNil(t, res.Since)
+
+	// the age of keys is frozen while paused
+	assert.Less(t, app.staleness.Age(date, time.Now().Add(time.Hour)), 2*time.Minute)
+
+	// enabling twice keeps the start of the maintenance
+	again := request(http.MethodPut)
+	assert.Equal(t, res.Since, again.Since)
+	assert.Equal(t, res, request(http.MethodGet))
+
+	assert.Equal(t, maintenanceStatus{}, request(http.MethodDelete))
+	assert.Equal(t, maintenanceStatus{}, request(http.MethodGet))
+}
@@ -0,0 +1,53 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof registers the runtime profiles of net/http/pprof at /debug/pprof/ with handle, e.g. to
+// profile the CPU spent in signing or the memory growth of long-running instances. Like the admin API
+// they require the admin token or an OpenID Connect token when configured.
+func (a *App) registerPprof(handle func(pattern string, h http.HandlerFunc)) {
+	handle("GET /debug/pprof/", a.authorize(pprof.Index))
+	handle("GET /debug/pprof/cmdline", a.authorize(pprof.Cmdline))
+	handle("GET /debug/pprof/profile", a.authorize(pprof.Profile))
+	handle("GET /debug/pprof/symbol", a.authorize(pprof.Symbol))
+	handle("POST /debug/pprof/symbol", a.authorize(pprof.Symbol))
+	handle("GET /debug/pprof/trace", a.authorize(pprof.Trace))
+	// pprof.Index serves named profiles only at /debug/pprof/{profile}, not below a path prefix
+	handle("GET /debug/pprof/{profile}", a.authorize(func(w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(r.PathValue("profile")).ServeHTTP(w, r)
+	}))
+}
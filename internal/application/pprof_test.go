@@ -0,0 +1,82 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"ssl-pinning/internal/config"
+)
+
+func TestApp_registerPprof(t *testing.T) {
+	app := &App{config: config.Config{Server: config.ConfigServer{AdminToken: "secret"}}}
+
+	// registered below a path prefix like server.WithPathPrefix
+	mux := http.NewServeMux()
+	app.registerPprof(func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(strings.Replace(pattern, " /", " /ops/", 1), h)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		header         string
+		wantStatusCode int
+		wantBody       string
+	}{
+		{name: "index", path: "/ops/debug/pprof/", header: "Bearer secret", wantStatusCode: http.StatusOK, wantBody: "Types of profiles available"},
+		{name: "named profile", path: "/ops/debug/pprof/goroutine?debug=1", header: "Bearer secret", wantStatusCode: http.StatusOK, wantBody: "goroutine profile"},
+		{name: "cmdline", path: "/ops/debug/pprof/cmdline", header: "Bearer secret", wantStatusCode: http.StatusOK},
+		{name: "unknown profile", path: "/ops/debug/pprof/unknown", header: "Bearer secret", wantStatusCode: http.StatusNotFound},
+		{name: "missing token", path: "/ops/debug/pprof/heap", wantStatusCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+}
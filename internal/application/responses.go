@@ -0,0 +1,176 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// response is the rendered HTTP response of a signed file.
+type response struct {
+	contentType string
+	data        []byte
+	etag        string
+	modified    time.Time
+}
+
+// responseKey identifies a rendered variant of a file.
+type responseKey struct {
+	file     string
+	naming   types.Naming
+	envelope types.Envelope
+	encoding types.PinEncoding
+}
+
+// responses caches the rendered responses of files, so that requests for unchanged files are
+// served with a map lookup. The cache is invalidated whenever this instance changes stored keys
+// (see flush and deleteKeys). The generation is incremented on every invalidation, so responses
+// rendered before an invalidation are not stored after it. A nil cache caches nothing.
+type responses struct {
+	mu      sync.RWMutex
+	entries map[responseKey]*response
+	gen     uint64
+}
+
+// newResponses returns an empty response cache.
+func newResponses() *responses {
+	return &responses{entries: make(map[responseKey]*response)}
+}
+
+// get returns the response stored under key.
+func (c *responses) get(key responseKey) (*response, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res, ok := c.entries[key]
+
+	return res, ok
+}
+
+// generation returns the current cache generation.
+func (c *responses) generation() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.gen
+}
+
+// set stores res under key unless the cache has been invalidated since gen.
+func (c *responses) set(key responseKey, res *response, gen uint64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	c.entries[key] = res
+}
+
+// invalidate drops every response and starts a new generation.
+func (c *responses) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	clear(c.entries)
+}
+
+// response returns the response of a file with the requested naming, envelope and pin encoding,
+// from the response cache if available (see responses), otherwise rendered from its signed
+// payload (see payload), so that files are signed once per flush rather than on every request.
+// Lookups are counted as hits or misses. Returns nil if the file is not found.
+func (a *App) response(ctx context.Context, key responseKey) (*response, error) {
+	if res, ok := a.responses.get(key); ok {
+		a.observeResponseCache(true)
+		return res, nil
+	}
+
+	gen := a.responses.generation()
+
+	data, modified, err := a.payload(ctx, key.file, key.naming, key.envelope, key.encoding)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	a.observeResponseCache(false)
+
+	res := newResponse(data, modified, key.naming, key.envelope)
+
+	a.responses.set(key, res, gen)
+
+	return res, nil
+}
+
+// newResponse returns the response of a signed payload with the media type of the envelope
+// and the naming parameter unless the naming is legacy.
+func newResponse(data []byte, modified time.Time, naming types.Naming, envelope types.Envelope) *response {
+	contentType := envelope.MediaType()
+	if naming != types.NamingLegacy {
+		contentType = fmt.Sprintf("%s; naming=%s", contentType, naming)
+	}
+
+	return &response{
+		contentType: contentType,
+		data:        data,
+		etag:        etag(data),
+		modified:    modified,
+	}
+}
+
+// observeResponseCache counts a lookup of the response cache if metrics are enabled.
+func (a *App) observeResponseCache(hit bool) {
+	if a.collector != nil {
+		a.collector.ObservePayloadCache(hit)
+	}
+}
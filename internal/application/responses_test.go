@@ -0,0 +1,133 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestApp_response(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	storage := &mockStorageWithError{mockStorage: newMockStorage()}
+	storage.keys["test.json"] = []types.DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: 3600, Fqdn: "a.example.com", Key: "key1"},
+		{Date: &now, DomainName: "example.com", Expire: 3600, Fqdn: "b.example.com", Key: "key2"},
+	}
+
+	app := &App{
+		responses: newResponses(),
+		signer:    testSigner,
+		storage:   storage,
+	}
+
+	key := responseKey{file: "test.json", naming: types.NamingSnake, envelope: types.EnvelopeLegacy, encoding: types.PinEncodingBase64}
+
+	res, err := app.response(context.Background(), key)
+	require.NoError(t, err)
+	require.NotNil(t, res)
+
+	assert.Equal(t, "application/json; naming=snake_case", res.contentType)
+	assert.Equal(t, etag(res.data), res.etag)
+	assert.Equal(t, now, res.modified)
+
+	// served from the cache without storage
+	storage.getByFileError = true
+
+	cached, err := app.response(context.Background(), key)
+	require.NoError(t, err)
+	assert.Same(t, res, cached)
+
+	// invalidated by a flush
+	require.NoError(t, app.flush(map[string]types.DomainKey{}))
+
+	_, err = app.response(context.Background(), key)
+	require.Error(t, err)
+
+	// and by a flush that failed to save the keys
+	storage.getByFileError = false
+
+	_, err = app.response(context.Background(), key)
+	require.NoError(t, err)
+
+	storage.saveErr = assert.AnError
+	require.ErrorIs(t, app.flush(map[string]types.DomainKey{}), assert.AnError)
+	storage.saveErr = nil
+
+	storage.getByFileError = true
+
+	_, err = app.response(context.Background(), key)
+	require.Error(t, err)
+
+	storage.getByFileError = false
+
+	_, err = app.response(context.Background(), responseKey{file: "missing.json", naming: types.NamingLegacy, envelope: types.EnvelopeLegacy, encoding: types.PinEncodingBase64})
+	require.NoError(t, err)
+
+	_, ok := app.responses.get(responseKey{file: "missing.json", naming: types.NamingLegacy, envelope: types.EnvelopeLegacy, encoding: types.PinEncodingBase64})
+	assert.False(t, ok, "missing files are not cached")
+}
+
+func TestResponses_generation(t *testing.T) {
+	cache := newResponses()
+	key := responseKey{file: "test.json"}
+
+	gen := cache.generation()
+	cache.invalidate()
+	cache.set(key, &response{}, gen)
+
+	_, ok := cache.get(key)
+	assert.False(t, ok, "responses rendered before an invalidation are not stored")
+
+	cache.set(key, &response{}, cache.generation())
+
+	_, ok = cache.get(key)
+	assert.True(t, ok)
+
+	var disabled *responses
+	disabled.set(key, &response{}, disabled.generation())
+	disabled.invalidate()
+
+	_, ok = disabled.get(key)
+	assert.False(t, ok)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+const (
+	// sandboxFile is the name of the built-in file with synthetic pins for client SDK integration tests
+	sandboxFile = "_sandbox.json"
+	// sandboxFqdn is the reserved test domain the synthetic pins are published for
+	sandboxFqdn = "example.com"
+	// sandboxExpire is the remaining certificate lifetime reported for synthetic pins, in seconds
+	sandboxExpire = int64(365 * 24 * time.Hour / time.Second)
+)
+
+// sandboxDate is the update time reported for synthetic pins
+var sandboxDate = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// sandboxKeys returns the synthetic primary and backup pins of the sandbox file.
+// The pins are derived from fixed seeds and dated sandboxDate, so the file never changes between
+// releases or instances and is signed once like any other file (see App.response).
+// The file is signed with the service key, which lets client test suites exercise signature
+// verification without coupling to real production pins.
+func sandboxKeys() []types.DomainKey {
+	date := sandboxDate
+
+	keys := make([]types.DomainKey, 0, 2)
+
+	for _, seed := range []string{"primary", "backup"} {
+		hash := sha256.Sum256([]byte("ssl-pinning sandbox " + seed))
+
+		keys = append(keys, types.DomainKey{
+			Date:       &date,
+			DomainName: "*." + sandboxFqdn,
+			Expire:     sandboxExpire,
+			File:       sandboxFile,
+			Fqdn:       sandboxFqdn,
+			Key:        base64.StdEncoding.EncodeToString(hash[:]),
+		})
+	}
+
+	return keys
+}
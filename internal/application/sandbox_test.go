@@ -0,0 +1,125 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ssl-pinning/internal/storage/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+func TestSandboxKeys(t *testing.T) {
+	first := sandboxKeys()
+	second := sandboxKeys()
+
+	require.Len(t, first, 2)
+	require.Len(t, second, 2)
+
+	assert.NotEqual(t, first[0].Key, first[1].Key)
+
+	for i, k := range first {
+		assert.Equal(t, second[i].Key, k.Key, "pins must be stable")
+		assert.Equal(t, sandboxFqdn, k.Fqdn)
+		assert.Equal(t, sandboxFile, k.File)
+		assert.Equal(t, "*."+sandboxFqdn, k.DomainName)
+		assert.Equal(t, sandboxExpire, k.Expire)
+		assert.Equal(t, sandboxDate, *k.Date)
+	}
+}
+
+func TestApp_handleFileJSON_Sandbox(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t)
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		wantStatusCode int
+	}{
+		{
+			name:           "enabled",
+			enabled:        true,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "disabled",
+			enabled:        false,
+			wantStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{
+				responses: newResponses(),
+				storage:   newMockStorage(),
+				signer:    testSigner,
+			}
+			app.config.Server.Sandbox = tt.enabled
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/"+sandboxFile, nil)
+			req.SetPathValue("file", sandboxFile)
+			w := httptest.NewRecorder()
+
+			app.handleFileJSON(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			var res types.FileStructure
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+
+			assert.NotEmpty(t, res.Signature)
+			require.Len(t, res.Payload.Keys, 2)
+			assert.Equal(t, sandboxFqdn, res.Payload.Keys[0].Fqdn)
+			assert.Equal(t, sandboxDate.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+
+			// the file is signed once and served from the response cache
+			again := httptest.NewRecorder()
+			app.handleFileJSON(again, req)
+
+			assert.Equal(t, w.Body.Bytes(), again.Body.Bytes())
+			assert.Equal(t, w.Header().Get("ETag"), again.Header().Get("ETag"))
+		})
+	}
+}
@@ -0,0 +1,275 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"time"
+
+	"ssl-pinning/internal/version"
+)
+
+const (
+	// statusOK reports a healthy component
+	statusOK = "ok"
+	// statusDegraded reports a component that works with errors, e.g. some domains fail to fetch
+	statusDegraded = "degraded"
+	// statusUnavailable reports a component that cannot serve requests
+	statusUnavailable = "unavailable"
+	// statusPending reports a component that has not run yet
+	statusPending = "pending"
+)
+
+// healthStatus is the document served by /health/status.
+type healthStatus struct {
+	Status      string            `json:"status"`
+	Time        time.Time         `json:"time"`
+	Build       version.BuildInfo `json:"build"`
+	Maintenance maintenanceStatus `json:"maintenance"`
+	Signer      signerStatus      `json:"signer"`
+	Storage     storageStatus     `json:"storage"`
+	Flush       flushStatus       `json:"flush"`
+	Domains     []domainStatus    `json:"domains"`
+}
+
+// signerStatus reports whether the signer is able to sign payloads.
+type signerStatus struct {
+	Status    string `json:"status"`
+	Algorithm string `json:"algorithm,omitempty"`
+	KeySize   int    `json:"key_size,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// storageStatus reports the outcome of the storage health probes.
+type storageStatus struct {
+	Status    string      `json:"status"`
+	Type      string      `json:"type"`
+	Liveness  probeStatus `json:"liveness"`
+	Readiness probeStatus `json:"readiness"`
+}
+
+// probeStatus is the outcome of a single storage probe.
+type probeStatus struct {
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// flushStatus reports the latest periodic flush of keys to storage.
+type flushStatus struct {
+	Status string     `json:"status"`
+	Last   *time.Time `json:"last,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// domainStatus reports the latest key fetch of a configured domain.
+type domainStatus struct {
+	Status        string     `json:"status"`
+	Fqdn          string     `json:"fqdn"`
+	File          string     `json:"file"`
+	LastFetch     *time.Time `json:"last_fetch,omitempty"`
+	Expire        int64      `json:"expire,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	ErrorCategory string     `json:"error_category,omitempty"`
+}
+
+// handleStatus handles requests to /health/status with a JSON document describing
+// the state of every component: storage probes, signer, latest flush, the latest
+// fetch of each configured domain, the maintenance mode and build information.
+// The overall status is "unavailable" if storage or signer fail, "degraded" if the
+// latest flush or any domain fetch failed and "ok" otherwise.
+// Returns 503 if the service is unavailable, 200 otherwise.
+func (a *App) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{
+		Time:        time.Now().UTC(),
+		Build:       version.Get(),
+		Maintenance: a.maintenance(),
+		Signer:      a.signerStatus(),
+		Storage:     a.storageStatus(r),
+		Flush:       a.flushStatus(),
+		Domains:     a.domainStatuses(),
+	}
+
+	status.Status = statusOK
+
+	if status.Flush.Status == statusDegraded ||
+		slices.ContainsFunc(status.Domains, func(d domainStatus) bool { return d.Status == statusDegraded }) {
+		status.Status = statusDegraded
+	}
+
+	code := http.StatusOK
+	if status.Signer.Status != statusOK || status.Storage.Status != statusOK {
+		status.Status = statusUnavailable
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// probeReadiness wraps the readiness probe of the storage, so that an instance only turns ready once
+// it fetched the certificates of all its domains and flushed them (see keys.Keys.Ready), rather than as
+// soon as other instances stored valid keys. It answers 503 until then.
+func (a *App) probeReadiness(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.keys != nil && !a.keys.Ready() {
+			slog.Debug("readiness: NOT ready, waiting for the first fetch and flush")
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("waiting for the first fetch and flush of the keys"))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleVersion handles requests to /version with the build information of the binary,
+// so fleets can be audited for outdated binaries.
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	_ = json.NewEncoder(w).Encode(version.Get())
+}
+
+// signerStatus signs an empty document to verify the signing key is usable.
+func (a *App) signerStatus() signerStatus {
+	if a.signer == nil {
+		return signerStatus{Status: statusUnavailable, Error: "signer not configured"}
+	}
+
+	status := signerStatus{
+		Status:    statusOK,
+		Algorithm: a.signer.Algorithm(),
+		KeySize:   a.signer.KeySize(),
+	}
+
+	if _, err := a.signer.Sign([]byte("{}")); err != nil {
+		status.Status = statusUnavailable
+		status.Error = err.Error()
+	}
+
+	return status
+}
+
+// storageStatus runs the liveness and readiness probes of the storage.
+func (a *App) storageStatus(r *http.Request) storageStatus {
+	status := storageStatus{
+		Status:    statusOK,
+		Type:      string(a.config.Storage.Type),
+		Liveness:  probe(a.storage.ProbeLiveness(), r),
+		Readiness: probe(a.storage.ProbeReadiness(), r),
+	}
+
+	if status.Liveness.Status != statusOK || status.Readiness.Status != statusOK {
+		status.Status = statusUnavailable
+	}
+
+	return status
+}
+
+// probe runs a storage probe handler and converts its plain-text response into a probeStatus.
+func probe(h func(http.ResponseWriter, *http.Request), r *http.Request) probeStatus {
+	rec := httptest.NewRecorder()
+	h(rec, r)
+
+	if rec.Code == http.StatusOK {
+		return probeStatus{Status: statusOK}
+	}
+
+	status := probeStatus{Status: statusUnavailable}
+	for line := range strings.Lines(rec.Body.String()) {
+		if line = strings.TrimSpace(line); line != "" {
+			status.Errors = append(status.Errors, line)
+		}
+	}
+
+	return status
+}
+
+// flushStatus reports the latest periodic flush of the keys worker.
+func (a *App) flushStatus() flushStatus {
+	if a.keys == nil {
+		return flushStatus{Status: statusPending}
+	}
+
+	last, err := a.keys.LastFlush()
+	if last.IsZero() {
+		return flushStatus{Status: statusPending}
+	}
+
+	status := flushStatus{Status: statusOK, Last: &last}
+	if err != nil {
+		status.Status = statusDegraded
+		status.Error = err.Error()
+	}
+
+	return status
+}
+
+// domainStatuses reports the latest fetch of every configured domain, sorted by FQDN.
+func (a *App) domainStatuses() []domainStatus {
+	domains := make([]domainStatus, 0)
+	if a.keys == nil {
+		return domains
+	}
+
+	for _, k := range a.keys.Snapshot() {
+		status := domainStatus{
+			Status:        statusOK,
+			Fqdn:          k.Fqdn,
+			File:          k.File,
+			LastFetch:     k.Date,
+			Expire:        k.Expire,
+			LastError:     k.LastError,
+			ErrorCategory: k.ErrorCategory,
+		}
+
+		switch {
+		case k.LastError != "":
+			status.Status = statusDegraded
+		case k.Date == nil:
+			status.Status = statusPending
+		}
+
+		domains = append(domains, status)
+	}
+
+	slices.SortFunc(domains, func(a, b domainStatus) int { return strings.Compare(a.Fqdn, b.Fqdn) })
+
+	return domains
+}
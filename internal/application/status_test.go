@@ -0,0 +1,274 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/config"
+	"ssl-pinning/internal/keys"
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+	"ssl-pinning/internal/version"
+)
+
+// failingProbeStorage fails the readiness probe with a plain-text error list.
+type failingProbeStorage struct {
+	*mockStorage
+}
+
+func (m *failingProbeStorage) ProbeReadiness() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("no keys in storage\nkey for a.example.com appears stale"))
+	}
+}
+
+// newStatusKeys returns a keys collection without running workers.
+func newStatusKeys(t *testing.T, domains ...types.DomainKey) *keys.Keys {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	k := keys.NewKeys(ctx, nil, keys.WithCollector(metrics.NewCollector()))
+	for _, d := range domains {
+		k.Set(d.Fqdn, d)
+	}
+
+	return k
+}
+
+func TestApp_handleStatus(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner, _ := setupTestSigner(t)
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name           string
+		app            func(t *testing.T) *App
+		wantStatusCode int
+		wantStatus     string
+		validate       func(t *testing.T, s healthStatus)
+	}{
+		{
+			name: "ok",
+			app: func(t *testing.T) *App {
+				return &App{
+					keys: newStatusKeys(t,
+						types.DomainKey{Fqdn: "b.example.com", File: "b.json", Date: &now, Expire: 3600, Key: "pin"},
+						types.DomainKey{Fqdn: "a.example.com", File: "a.json", Date: &now, Expire: 7200, Key: "pin"},
+					),
+					signer:  testSigner,
+					storage: newMockStorage(),
+				}
+			},
+			wantStatusCode: http.StatusOK,
+			wantStatus:     statusOK,
+			validate: func(t *testing.T, s healthStatus) {
+				assert.Equal(t, statusOK, s.Signer.Status)
+				assert.Equal(t, "RS512", s.Signer.Algorithm)
+				assert.Equal(t, 2048, s.Signer.KeySize)
+				assert.Equal(t, statusOK, s.Storage.Liveness.Status)
+				assert.Equal(t, statusOK, s.Storage.Readiness.Status)
+				assert.Equal(t, statusPending, s.Flush.Status)
+				assert.Nil(t, s.Flush.Last)
+
+				require.Len(t, s.Domains, 2)
+				assert.Equal(t, "a.example.com", s.Domains[0].Fqdn)
+				assert.Equal(t, "a.json", s.Domains[0].File)
+				assert.Equal(t, int64(7200), s.Domains[0].Expire)
+				assert.Equal(t, statusOK, s.Domains[0].Status)
+				require.NotNil(t, s.Domains[0].LastFetch)
+				assert.True(t, now.Equal(*s.Domains[0].LastFetch))
+				assert.Equal(t, "b.example.com", s.Domains[1].Fqdn)
+			},
+		},
+		{
+			name: "degraded with failing domain",
+			app: func(t *testing.T) *App {
+				return &App{
+					keys: newStatusKeys(t,
+						types.DomainKey{Fqdn: "a.example.com", File: "a.json", Date: &now, LastError: "dial tcp: i/o timeout"},
+						types.DomainKey{Fqdn: "b.example.com", File: "b.json"},
+					),
+					signer:  testSigner,
+					storage: newMockStorage(),
+				}
+			},
+			wantStatusCode: http.StatusOK,
+			wantStatus:     statusDegraded,
+			validate: func(t *testing.T, s healthStatus) {
+				require.Len(t, s.Domains, 2)
+				assert.Equal(t, statusDegraded, s.Domains[0].Status)
+				assert.Equal(t, "dial tcp: i/o timeout", s.Domains[0].LastError)
+				assert.Equal(t, statusPending, s.Domains[1].Status)
+				assert.Nil(t, s.Domains[1].LastFetch)
+			},
+		},
+		{
+			name: "unavailable storage",
+			app: func(t *testing.T) *App {
+				return &App{
+					config:  config.Config{Storage: config.ConfigStorage{Type: types.StorageRedis}},
+					signer:  testSigner,
+					storage: &failingProbeStorage{mockStorage: newMockStorage()},
+				}
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantStatus:     statusUnavailable,
+			validate: func(t *testing.T, s healthStatus) {
+				assert.Equal(t, statusUnavailable, s.Storage.Status)
+				assert.Equal(t, "redis", s.Storage.Type)
+				assert.Equal(t, statusOK, s.Storage.Liveness.Status)
+				assert.Equal(t, statusUnavailable, s.Storage.Readiness.Status)
+				assert.Equal(t, []string{
+					"no keys in storage",
+					"key for a.example.com appears stale",
+				}, s.Storage.Readiness.Errors)
+				assert.Empty(t, s.Domains)
+			},
+		},
+		{
+			name: "unavailable signer",
+			app: func(t *testing.T) *App {
+				return &App{storage: newMockStorage()}
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantStatus:     statusUnavailable,
+			validate: func(t *testing.T, s healthStatus) {
+				assert.Equal(t, statusUnavailable, s.Signer.Status)
+				assert.NotEmpty(t, s.Signer.Error)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := tt.app(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/health/status", nil)
+			w := httptest.NewRecorder()
+
+			app.handleStatus(w, req)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var status healthStatus
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+
+			assert.Equal(t, tt.wantStatus, status.Status)
+			assert.False(t, status.Time.IsZero())
+			assert.NotEmpty(t, status.Build.GoVersion)
+
+			tt.validate(t, status)
+		})
+	}
+}
+
+func TestApp_flushStatus(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := keys.NewKeys(ctx, nil,
+		keys.WithCollector(metrics.NewCollector()),
+		keys.WithDumpInterval(10*time.Millisecond),
+		keys.WithFlushFunc(func(map[string]types.DomainKey) error { return assert.AnError }),
+	)
+	go k.StartPeriodicFlush()
+
+	app := &App{keys: k}
+
+	require.Eventually(t, func() bool {
+		return app.flushStatus().Status != statusPending
+	}, time.Second, 5*time.Millisecond)
+
+	status := app.flushStatus()
+	assert.Equal(t, statusDegraded, status.Status)
+	assert.Equal(t, assert.AnError.Error(), status.Error)
+	assert.NotNil(t, status.Last)
+}
+
+func TestApp_probeReadiness(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := keys.NewKeys(ctx, nil,
+		keys.WithCollector(metrics.NewCollector()),
+		keys.WithDumpInterval(10*time.Millisecond),
+		keys.WithFlushFunc(func(map[string]types.DomainKey) error { return nil }),
+	)
+
+	app := &App{keys: k}
+	probe := app.probeReadiness(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	probe(w, httptest.NewRequest(http.MethodGet, "/health/readiness", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "first fetch and flush")
+
+	go k.StartPeriodicFlush()
+	require.Eventually(t, k.Ready, time.Second, 5*time.Millisecond)
+
+	w = httptest.NewRecorder()
+	probe(w, httptest.NewRequest(http.MethodGet, "/health/readiness", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandleVersion(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleVersion(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var info version.BuildInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, version.Get(), info)
+}
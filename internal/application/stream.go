@@ -0,0 +1,297 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+const (
+	// streamBuffer is the number of events buffered per subscriber; subscribers falling
+	// further behind are disconnected and expected to reconnect and refetch their files.
+	streamBuffer = 64
+	// streamHeartbeat is the interval of the comments keeping idle streams open through proxies.
+	streamHeartbeat = 30 * time.Second
+)
+
+// streamEvent is a change of the pin set of a file published to the subscribers of the stream:
+// the file with its current key count and the delta of its keys.
+type streamEvent struct {
+	delta types.Delta
+	id    uint64
+	info  types.FileInfo
+}
+
+// stream publishes changes of the pin sets of files to the subscribers of /api/v1/stream and
+// /api/v1/subscribe. Changes are detected on flush by comparing the keys of every file with the
+// previous flush (see types.Diff); the keys are only kept while there are subscribers (see active).
+type stream struct {
+	closed bool
+	files  map[string][]types.DomainKey
+	id     uint64
+	mu     sync.Mutex
+	subs   map[chan streamEvent]struct{}
+}
+
+// newStream creates a stream without subscribers.
+func newStream() *stream {
+	return &stream{
+		subs: make(map[chan streamEvent]struct{}),
+	}
+}
+
+// subscribe registers a subscriber and returns the channel of its events. The channel is closed
+// when the subscriber falls behind or the stream is closed.
+func (s *stream) subscribe() chan streamEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan streamEvent, streamBuffer)
+
+	if s.closed {
+		close(ch)
+		return ch
+	}
+
+	s.subs[ch] = struct{}{}
+
+	return ch
+}
+
+// unsubscribe removes a subscriber. The keys are dropped with the last subscriber,
+// so the next subscriber starts from the next flush.
+func (s *stream) unsubscribe(ch chan streamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+
+	if len(s.subs) == 0 {
+		s.files = nil
+	}
+}
+
+// active reports whether the stream has subscribers.
+func (s *stream) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.subs) > 0
+}
+
+// update records the keys of the files of a flush and publishes the files whose keys changed
+// since the previous flush, in the order of their names, including files that are no longer
+// published, with zero keys. The first update after subscribing only records the keys.
+func (s *stream) update(files map[string][]types.DomainKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.files
+	s.files = files
+
+	if previous == nil {
+		return
+	}
+
+	names := slices.Sorted(maps.Keys(files))
+	for file := range previous {
+		if _, ok := files[file]; !ok {
+			names = append(names, file)
+		}
+	}
+
+	slices.Sort(names)
+
+	for _, file := range names {
+		if delta := types.Diff(file, previous[file], files[file]); !delta.Empty() {
+			delta.Cursor = types.Version(files[file])
+			s.publish(streamEvent{delta: delta, info: fileInfo(file, files[file])})
+		}
+	}
+}
+
+// publish sends a change to every subscriber; subscribers whose buffer is full are disconnected.
+// It must be called with mu held.
+func (s *stream) publish(event streamEvent) {
+	s.id++
+	event.id = s.id
+
+	for ch := range s.subs {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("disconnecting slow stream subscriber", "file", event.info.File)
+
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// close disconnects all subscribers and rejects new ones, e.g. on shutdown, as open streams
+// would otherwise delay the graceful shutdown of the server until its timeout.
+func (s *stream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// observe records the keys of every file in the history and publishes the files whose pin set
+// changed since the previous flush to the subscribers of the stream.
+func (a *App) observe() {
+	if a.history == nil && a.stream == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+
+	files, err := a.storage.ListFiles()
+	if err != nil {
+		slog.Error("failed to list files for the stream", "error", err)
+		return
+	}
+
+	keys := make(map[string][]types.DomainKey, len(files))
+
+	for _, file := range files {
+		stored, data, err := a.storage.GetByFile(file)
+		if err == nil {
+			stored, err = fileKeys(stored, data)
+		}
+
+		if err != nil {
+			slog.Error("failed to read file for the stream", "file", file, "error", err)
+			return
+		}
+
+		keys[file] = stored
+
+		if a.history != nil {
+			a.history.record(file, stored, now)
+		}
+	}
+
+	if a.stream != nil && a.stream.active() {
+		a.stream.update(keys)
+	}
+}
+
+// handleStream handles HTTP requests for the Server-Sent Events stream of pin updates.
+// It accepts GET requests to /api/v1/stream and sends a "file" event with the file name, key
+// count and latest key update (see types.FileInfo) whenever the pin set of a file changes, so
+// clients can refetch it instead of polling. Files that are no longer published are sent with
+// zero keys. The file query parameter, which may be repeated, restricts the stream to these files.
+// Events carry an increasing ID; missed events are not replayed, so clients should refetch
+// their files after reconnecting.
+func (a *App) handleStream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	// streams outlive server.write_timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.ErrorContext(r.Context(), "failed to clear write deadline", "error", err)
+	}
+
+	files := r.URL.Query()["file"]
+
+	events := a.stream.subscribe()
+	defer a.stream.unsubscribe(events)
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		slog.ErrorContext(r.Context(), "streaming not supported", "error", err)
+		return
+	}
+
+	slog.DebugContext(r.Context(), "stream subscribed", "files", files)
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if len(files) > 0 && !slices.Contains(files, event.info.File) {
+				continue
+			}
+
+			data, merr := json.Marshal(event.info)
+			if merr != nil {
+				slog.ErrorContext(r.Context(), "failed to marshal stream event", "error", merr)
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "id: %d\nevent: file\ndata: %s\n\n", event.id, data)
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+
+		if err != nil {
+			slog.DebugContext(r.Context(), "stream closed", "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestStream_update(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	s := newStream()
+	assert.False(t, s.active())
+
+	ch := s.subscribe()
+	assert.True(t, s.active())
+
+	a1 := types.DomainKey{Fqdn: "a.example.com", Key: "key1"}
+	a2 := types.DomainKey{Fqdn: "a.example.com", Key: "key2"}
+	b := types.DomainKey{Fqdn: "b.example.com", Key: "key3"}
+	c := types.DomainKey{Fqdn: "c.example.com", Key: "key4"}
+
+	s.update(map[string][]types.DomainKey{"a.json": {a1}, "b.json": {b}})
+	assert.Empty(t, ch, "first update only records keys")
+
+	s.update(map[string][]types.DomainKey{"a.json": {a2, c}})
+	require.Len(t, ch, 2)
+
+	event := <-ch
+	assert.Equal(t, uint64(1), event.id)
+	assert.Equal(t, types.FileInfo{File: "a.json", Keys: 2}, event.info)
+	assert.Equal(t, types.Delta{Changed: []types.DomainKey{a2, c}, Cursor: types.Version([]types.DomainKey{a2, c}), File: "a.json"}, event.delta)
+
+	event = <-ch
+	assert.Equal(t, uint64(2), event.id)
+	assert.Equal(t, types.FileInfo{File: "b.json"}, event.info)
+	assert.Equal(t, types.Delta{Cursor: types.Version(nil), File: "b.json", Removed: []string{"b.example.com"}}, event.delta)
+
+	s.update(map[string][]types.DomainKey{"a.json": {c, a2}})
+	assert.Empty(t, ch, "unchanged files are not published")
+
+	s.unsubscribe(ch)
+	assert.False(t, s.active())
+	assert.Nil(t, s.files)
+
+	s.close()
+
+	_, ok := <-s.subscribe()
+	assert.False(t, ok, "closed stream rejects subscribers")
+}
+
+func TestStream_slowSubscriber(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	s := newStream()
+	ch := s.subscribe()
+
+	s.update(map[string][]types.DomainKey{})
+
+	for i := range streamBuffer + 1 {
+		s.update(map[string][]types.DomainKey{"a.json": {{Fqdn: "a.example.com", Key: string(rune('a' + i%2))}}})
+	}
+
+	for range ch {
+	}
+
+	assert.False(t, s.active())
+}
+
+func TestApp_handleStream(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	storage := newMockStorage()
+	storage.keys["a.json"] = []types.DomainKey{{Date: &now, Fqdn: "www.example.com", Key: "key1"}}
+	storage.keys["b.json"] = []types.DomainKey{{Date: &now, Fqdn: "www.test.com", Key: "key2"}}
+
+	app := &App{storage: storage, stream: newStream()}
+
+	srv := httptest.NewServer(http.HandlerFunc(app.handleStream))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/v1/stream?file=a.json")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	require.Eventually(t, app.stream.active, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, app.flush(nil))
+
+	storage.keys["a.json"] = []types.DomainKey{{Date: &now, Fqdn: "www.example.com", Key: "key3"}}
+	storage.keys["b.json"] = []types.DomainKey{{Date: &now, Fqdn: "www.test.com", Key: "key4"}}
+
+	require.NoError(t, app.flush(nil))
+
+	reader := bufio.NewReader(res.Body)
+
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		if line == "\n" {
+			break
+		}
+
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+
+	require.Len(t, lines, 3)
+	assert.Equal(t, "id: 1", lines[0])
+	assert.Equal(t, "event: file", lines[1])
+
+	var info types.FileInfo
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &info))
+	assert.Equal(t, "a.json", info.File)
+	assert.Equal(t, 1, info.Keys)
+
+	app.stream.close()
+
+	_, err = reader.ReadString('\n')
+	assert.Error(t, err, "stream ends when closed")
+}
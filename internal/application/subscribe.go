@@ -0,0 +1,213 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// subscriptionMaxSize is the maximum size of a subscription message of a WebSocket client.
+const subscriptionMaxSize = 64 << 10
+
+// pingCodec sends WebSocket ping frames; clients answer them with pong frames (RFC 6455, 5.5.2).
+var pingCodec = websocket.Codec{
+	Marshal: func(any) ([]byte, byte, error) {
+		return nil, websocket.PingFrame, nil
+	},
+}
+
+// subscription selects the changes sent to a WebSocket subscriber: the files and the FQDNs of
+// interest, all if empty.
+type subscription struct {
+	Files []string `json:"files,omitempty"`
+	Fqdns []string `json:"fqdns,omitempty"`
+}
+
+// delta returns the part of a delta selected by the subscription, an empty delta if none.
+func (s subscription) delta(d types.Delta) types.Delta {
+	if len(s.Files) > 0 && !slices.Contains(s.Files, d.File) {
+		return types.Delta{File: d.File}
+	}
+
+	return d.Filter(s.Fqdns)
+}
+
+// handleSubscribe handles WebSocket subscriptions to pin updates.
+// It accepts GET requests to /api/v1/subscribe upgraded to a WebSocket and sends a text message
+// with a signed types.Delta of the keys of a file whenever the keys selected by the subscription
+// change (see types.SignedDelta), so long-lived services can update the pins of their TLS dialers.
+// The subscription is set with the file and fqdn query parameters, which may be repeated, and
+// replaced by every subscription JSON message of the client; other messages close the connection.
+// Missed deltas are not replayed, so clients should refetch their files after reconnecting.
+// Returns 400 if the request is not a WebSocket handshake or does not use HTTP/1.1, and 403 if
+// its Origin is not allowed (see checkOrigin).
+func (a *App) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 1 {
+		http.Error(w, "websocket requires HTTP/1.1", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	sub := subscription{Files: query["file"], Fqdns: query["fqdn"]}
+
+	websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			if err := checkOrigin(r, a.config.Server.CORS.AllowedOrigins); err != nil {
+				slog.DebugContext(r.Context(), "websocket handshake rejected", "error", err)
+				return err
+			}
+
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			a.serveSubscription(ws, sub)
+		},
+	}.ServeHTTP(w, r)
+}
+
+// checkOrigin protects WebSocket subscriptions against cross-site WebSocket hijacking, as browsers
+// do not apply CORS to WebSocket handshakes. It accepts handshakes without an Origin (non-browser
+// clients), from the origin of the server itself and from the allowed origins of server.cors, where
+// "*" allows any origin.
+func checkOrigin(r *http.Request, allowed []string) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+// serveSubscription sends the deltas selected by a subscription to a WebSocket client until the
+// client disconnects, falls behind or the stream is closed, and pings idle clients (see streamHeartbeat).
+func (a *App) serveSubscription(ws *websocket.Conn, sub subscription) {
+	ctx := ws.Request().Context()
+
+	// subscriptions outlive server.read_timeout and server.write_timeout
+	if err := ws.SetDeadline(time.Time{}); err != nil {
+		slog.ErrorContext(ctx, "failed to clear deadline", "error", err)
+	}
+
+	ws.MaxPayloadBytes = subscriptionMaxSize
+
+	events := a.stream.subscribe()
+	defer a.stream.unsubscribe(events)
+
+	slog.DebugContext(ctx, "websocket subscribed", "files", sub.Files, "fqdns", sub.Fqdns)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	updates := make(chan subscription)
+	closed := make(chan error, 1)
+
+	go func() {
+		for {
+			var next subscription
+			if err := websocket.JSON.Receive(ws, &next); err != nil {
+				closed <- err
+				return
+			}
+
+			select {
+			case updates <- next:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+
+		select {
+		case err = <-closed:
+			slog.DebugContext(ctx, "websocket closed", "error", err)
+			ws.Close()
+			return
+		case sub = <-updates:
+			slog.DebugContext(ctx, "websocket subscription updated", "files", sub.Files, "fqdns", sub.Fqdns)
+			continue
+		case <-heartbeat.C:
+			err = pingCodec.Send(ws, nil)
+		case event, ok := <-events:
+			if !ok {
+				ws.Close()
+				return
+			}
+
+			delta := sub.delta(event.delta)
+			if delta.Empty() {
+				continue
+			}
+
+			data, serr := a.sign(delta.File, func() ([]byte, error) {
+				return types.SignedDelta(delta, a.signer)
+			})
+			if serr != nil {
+				slog.ErrorContext(ctx, "failed to sign delta", "file", delta.File, "error", serr)
+				continue
+			}
+
+			err = websocket.Message.Send(ws, string(data))
+		}
+
+		if err != nil {
+			slog.DebugContext(ctx, "websocket closed", "error", err)
+			ws.Close()
+			return
+		}
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/storage/types"
+	"ssl-pinning/pkg/verify"
+)
+
+func TestSubscription_delta(t *testing.T) {
+	delta := types.Delta{
+		Changed: []types.DomainKey{{Fqdn: "a.example.com"}},
+		File:    "a.json",
+		Removed: []string{"b.example.com"},
+	}
+
+	assert.Equal(t, delta, subscription{}.delta(delta))
+	assert.Equal(t, delta, subscription{Files: []string{"a.json"}}.delta(delta))
+	assert.True(t, subscription{Files: []string{"b.json"}}.delta(delta).Empty())
+	assert.Equal(t, []string{"b.example.com"}, subscription{Fqdns: []string{"b.example.com"}}.delta(delta).Removed)
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		allowed []string
+		wantErr bool
+	}{
+		{name: "no origin", origin: ""},
+		{name: "same origin", origin: "https://pins.example.com"},
+		{name: "same origin case insensitive", origin: "https://PINS.example.com"},
+		{name: "allowed origin", origin: "https://dashboard.example.com", allowed: []string{"https://dashboard.example.com"}},
+		{name: "any origin", origin: "https://evil.example.com", allowed: []string{"*"}},
+		{name: "foreign origin", origin: "https://evil.example.com", wantErr: true},
+		{name: "foreign origin with allowlist", origin: "https://evil.example.com", allowed: []string{"https://dashboard.example.com"}, wantErr: true},
+		{name: "other port", origin: "https://pins.example.com:8443", wantErr: true},
+		{name: "null origin", origin: "null", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://pins.example.com/api/v1/subscribe", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			err := checkOrigin(r, tt.allowed)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestApp_handleSubscribe_Origin(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	app := &App{storage: newMockStorage(), stream: newStream()}
+	app.config.Server.CORS.AllowedOrigins = []string{"https://dashboard.example.com"}
+
+	srv := httptest.NewServer(http.HandlerFunc(app.handleSubscribe))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/subscribe"
+
+	t.Run("foreign origin", func(t *testing.T) {
+		_, err := websocket.Dial(url, "", "https://evil.example.com")
+		require.Error(t, err)
+
+		var dialErr *websocket.DialError
+		require.ErrorAs(t, err, &dialErr)
+		assert.ErrorIs(t, dialErr.Err, websocket.ErrBadStatus)
+	})
+
+	t.Run("allowed origin", func(t *testing.T) {
+		ws, err := websocket.Dial(url, "", "https://dashboard.example.com")
+		require.NoError(t, err)
+		ws.Close()
+	})
+}
+
+func TestApp_handleSubscribe(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testSigner, _ := setupTestSigner(t)
+
+	storage := newMockStorage()
+	storage.keys["a.json"] = []types.DomainKey{
+		{Date: &now, Fqdn: "www.example.com", Key: "key1"},
+		{Date: &now, Fqdn: "api.example.com", Key: "key2"},
+	}
+
+	app := &App{signer: testSigner, storage: storage, stream: newStream()}
+
+	srv := httptest.NewServer(http.HandlerFunc(app.handleSubscribe))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/subscribe?file=a.json&fqdn=www.example.com"
+
+	ws, err := websocket.Dial(url, "", srv.URL)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	require.Eventually(t, app.stream.active, time.Second, 10*time.Millisecond)
+	require.NoError(t, app.flush(nil))
+
+	receive := func(t *testing.T, timeout time.Duration) (types.Delta, bool) {
+		t.Helper()
+
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(timeout)))
+
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			var netErr net.Error
+			require.ErrorAs(t, err, &netErr)
+			require.True(t, netErr.Timeout())
+
+			return types.Delta{}, false
+		}
+
+		verifier, err := verify.New(testSigner.PublicKeys()...)
+		require.NoError(t, err)
+
+		_, err = verifier.Verify(data)
+		require.NoError(t, err)
+
+		structure, err := types.ParseFileStructure(data)
+		require.NoError(t, err)
+		assert.NotEmpty(t, structure.Signature)
+
+		var signed struct {
+			Payload types.Delta `json:"payload"`
+		}
+		require.NoError(t, websocket.JSON.Unmarshal(data, websocket.TextFrame, &signed))
+
+		return signed.Payload, true
+	}
+
+	// changes of other hosts are not sent
+	storage.keys["a.json"] = []types.DomainKey{
+		{Date: &now, Fqdn: "www.example.com", Key: "key1"},
+		{Date: &now, Fqdn: "api.example.com", Key: "key3"},
+	}
+	require.NoError(t, app.flush(nil))
+
+	storage.keys["a.json"] = []types.DomainKey{
+		{Date: &now, Fqdn: "www.example.com", Key: "key4"},
+		{Date: &now, Fqdn: "api.example.com", Key: "key3"},
+	}
+	require.NoError(t, app.flush(nil))
+
+	delta, ok := receive(t, 5*time.Second)
+	require.True(t, ok)
+	assert.Equal(t, "a.json", delta.File)
+	require.Len(t, delta.Changed, 1)
+	assert.Equal(t, "www.example.com", delta.Changed[0].Fqdn)
+	assert.Equal(t, "key4", delta.Changed[0].Key)
+
+	// the client replaces its subscription
+	require.NoError(t, websocket.JSON.Send(ws, subscription{Fqdns: []string{"api.example.com"}}))
+
+	// changes of the old host are no longer sent, so the first message follows the replacement
+	for i := 0; ; i++ {
+		require.Less(t, i, 100, "subscription not replaced")
+
+		storage.keys["a.json"] = []types.DomainKey{
+			{Date: &now, Fqdn: "www.example.com", Key: "key4"},
+			{Date: &now, Fqdn: "api.example.com", Key: fmt.Sprintf("key%d", 5+i)},
+		}
+		require.NoError(t, app.flush(nil))
+
+		if delta, ok := receive(t, 50*time.Millisecond); ok {
+			require.Len(t, delta.Changed, 1)
+			assert.Equal(t, "api.example.com", delta.Changed[0].Fqdn)
+			break
+		}
+	}
+
+	app.stream.close()
+
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var data []byte
+	assert.Error(t, websocket.Message.Receive(ws, &data), "connection ends when the stream is closed")
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"ssl-pinning/internal/config"
+)
+
+// streamingRoutes are the routes of the public API with long-lived responses, which are never timed out.
+var streamingRoutes = []string{"GET /api/v1/stream", "GET /api/v1/subscribe"}
+
+// routeTimeouts holds the handler timeouts of the routes of the public API: the timeout of the
+// route if configured, otherwise the default. Routes are configured by their pattern, e.g.
+// "GET /api/v2/files", or by its path, e.g. "/api/v2/files". Handlers are not timed out with
+// a zero timeout.
+type routeTimeouts struct {
+	def    time.Duration
+	routes map[string]time.Duration
+	seen   map[string]bool
+}
+
+// newRouteTimeouts returns the handler timeouts of server.handler_timeout and server.route_timeouts.
+// Returns an error if a timeout is configured for a streaming route.
+func newRouteTimeouts(cfg config.ConfigServer) (*routeTimeouts, error) {
+	t := &routeTimeouts{
+		def:    cfg.HandlerTimeout,
+		routes: make(map[string]time.Duration, len(cfg.RouteTimeouts)),
+		seen:   make(map[string]bool),
+	}
+
+	for _, rt := range cfg.RouteTimeouts {
+		if rt.Timeout > 0 && slices.ContainsFunc(streamingRoutes, func(p string) bool {
+			return rt.Route == p || rt.Route == routePath(p)
+		}) {
+			return nil, fmt.Errorf("route %q streams its responses and cannot be timed out", rt.Route)
+		}
+
+		t.routes[rt.Route] = rt.Timeout
+	}
+
+	return t, nil
+}
+
+// wrap returns h answering with 503 Service Unavailable when it exceeds the timeout of the route
+// of pattern (see http.TimeoutHandler). The request context of h is cancelled at the timeout,
+// so storage calls are aborted. Responses of timed out routes are buffered until h returns.
+func (t *routeTimeouts) wrap(pattern string, h http.HandlerFunc) http.HandlerFunc {
+	d := t.def
+
+	for _, route := range []string{pattern, routePath(pattern)} {
+		if timeout, ok := t.routes[route]; ok {
+			t.seen[route] = true
+			d = timeout
+
+			break
+		}
+	}
+
+	if d <= 0 || slices.Contains(streamingRoutes, pattern) {
+		return h
+	}
+
+	return http.TimeoutHandler(h, d, "handler timeout").ServeHTTP
+}
+
+// routePath returns the path of a route pattern without its method.
+func routePath(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+
+	return pattern
+}
+
+// unknown returns the configured routes that were never wrapped, e.g. because of a typo.
+func (t *routeTimeouts) unknown() []string {
+	var routes []string
+
+	for route := range t.routes {
+		if !t.seen[route] {
+			routes = append(routes, route)
+		}
+	}
+
+	slices.Sort(routes)
+
+	return routes
+}
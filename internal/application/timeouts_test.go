@@ -0,0 +1,92 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/config"
+)
+
+func TestRouteTimeouts(t *testing.T) {
+	timeouts, err := newRouteTimeouts(config.ConfigServer{
+		HandlerTimeout: 10 * time.Millisecond,
+		RouteTimeouts: []config.ConfigServerRouteTimeout{
+			{Route: "/api/v1/files", Timeout: 0},
+			{Route: "/api/v2/files", Timeout: time.Hour},
+			{Route: "/api/v1/typo", Timeout: time.Second},
+		},
+	})
+	require.NoError(t, err)
+
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}
+
+	serve := func(h http.HandlerFunc) int {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(timeouts.wrap("/api/v1/{file}", slow)), "default timeout")
+	assert.Equal(t, http.StatusTeapot, serve(timeouts.wrap("/api/v1/files", slow)), "disabled for the route")
+	assert.Equal(t, http.StatusTeapot, serve(timeouts.wrap("GET /api/v2/files", slow)), "route configured by path")
+	assert.Equal(t, http.StatusTeapot, serve(timeouts.wrap("GET /api/v1/stream", slow)), "streaming route")
+
+	assert.Equal(t, []string{"/api/v1/typo"}, timeouts.unknown())
+}
+
+func TestNewRouteTimeouts_Streaming(t *testing.T) {
+	for _, route := range []string{"GET /api/v1/stream", "/api/v1/subscribe"} {
+		_, err := newRouteTimeouts(config.ConfigServer{
+			RouteTimeouts: []config.ConfigServerRouteTimeout{{Route: route, Timeout: time.Second}},
+		})
+		assert.Error(t, err, route)
+	}
+
+	_, err := newRouteTimeouts(config.ConfigServer{
+		RouteTimeouts: []config.ConfigServerRouteTimeout{{Route: "/api/v1/stream", Timeout: 0}},
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configWatchDelay is the time waited after a change in the directory of the configuration file
+// before it is reloaded, so that the events of one update, e.g. the symbolic link swap of a
+// Kubernetes ConfigMap, reload it once.
+const configWatchDelay = time.Second
+
+// configFileVersion returns the size and modification time of file, following symbolic links,
+// which change whenever the file is replaced.
+func configFileVersion(file string) (string, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d:%d", fi.Size(), fi.ModTime().UnixNano()), nil
+}
+
+// watchConfig reloads the configuration (see reload) when the configuration file changes, so that
+// domains are added and removed by editing a ConfigMap rather than by a rollout, until ctx is done.
+// The directory of the file is watched rather than the file, so that a file replaced by a rename
+// or a symbolic link swap, as of Kubernetes ConfigMaps, is seen. The file is also checked every
+// interval if positive, e.g. on file systems without change notifications.
+func (a *App) watchConfig(ctx context.Context, file string, interval time.Duration) {
+	version, err := configFileVersion(file)
+	if err != nil {
+		slog.Warn("failed to read the configuration file", "file", file, "error", err)
+	}
+
+	var events <-chan fsnotify.Event
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("failed to watch the configuration file", "file", file, "error", err)
+	} else {
+		defer watcher.Close()
+
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			slog.Warn("failed to watch the configuration directory", "dir", filepath.Dir(file), "error", err)
+		}
+
+		events = watcher.Events
+	}
+
+	var tick <-chan time.Time
+
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	delay := time.NewTimer(configWatchDelay)
+	delay.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			delay.Stop()
+			return
+		case <-events:
+			delay.Reset(configWatchDelay)
+			continue
+		case <-tick:
+		case <-delay.C:
+		}
+
+		current, err := configFileVersion(file)
+		if err != nil || current == version {
+			continue
+		}
+
+		version = current
+
+		slog.Info("configuration file changed", "file", file)
+
+		if err := a.reload(); err != nil {
+			slog.Error("failed to reload configuration", "error", err)
+		}
+	}
+}
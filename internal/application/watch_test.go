@@ -0,0 +1,113 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package application
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestApp_watchConfig(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	t.Cleanup(viper.Reset)
+
+	// a ConfigMap mount: the file is a symbolic link into a data directory swapped on updates
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "v1"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "v1", "config.yaml"), []byte("keys:\n  - fqdn: www.example.com\n    file: test.json\n"), 0o600))
+	require.NoError(t, os.Symlink("v1", filepath.Join(dir, "..data")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "config.yaml"), filepath.Join(dir, "config.yaml")))
+
+	path := filepath.Join(dir, "config.yaml")
+
+	viper.Reset()
+	viper.SetConfigFile(path)
+
+	app := &App{
+		keys:    newStatusKeys(t, types.DomainKey{Fqdn: "www.example.com", File: "test.json", DomainName: "*.www.example.com"}),
+		storage: newMockStorage(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		app.watchConfig(ctx, path, 0)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// the watcher is set up asynchronously
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "v2"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "v2", "config.yaml"), []byte("keys:\n  - fqdn: www.example.com\n    file: test.json\n  - fqdn: new.example.com\n"), 0o600))
+	require.NoError(t, os.Symlink("v2", filepath.Join(dir, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+
+	assert.Eventually(t, func() bool {
+		_, ok := app.keys.Get("new.example.com")
+		return ok
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestConfigFileVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	_, err := configFileVersion(path)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("keys: []\n"), 0o600))
+	v1, err := configFileVersion(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("keys:\n  - fqdn: www.example.com\n"), 0o600))
+	v2, err := configFileVersion(path)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, v1, v2)
+}
@@ -32,68 +32,419 @@ POSSIBILITY OF SUCH DAMAGE.
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
 	"time"
 
+	"ssl-pinning/internal/keys"
+	"ssl-pinning/internal/logging"
+	"ssl-pinning/internal/notify"
+	"ssl-pinning/internal/signer/gcpkms"
+	"ssl-pinning/internal/signer/tsa"
 	"ssl-pinning/internal/storage/types"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
 )
 
 // Config represents the main application configuration structure.
-// It contains all settings including domain keys, logging, server, storage, and TLS configuration.
+// It contains all settings including alerts, domain keys, logging, metrics, reload, server, storage, and TLS configuration.
 // UUID is generated automatically for each application instance.
 type Config struct {
+	Alerts  ConfigAlerts      `mapstructure:"alerts"`
 	Keys    []types.DomainKey `mapstructure:"keys"`
 	Log     ConfigLog         `mapstructure:"log"`
+	Metrics ConfigMetrics     `mapstructure:"metrics"`
+	Reload  ConfigReload      `mapstructure:"reload"`
 	Server  ConfigServer      `mapstructure:"server"`
 	Storage ConfigStorage     `mapstructure:"storage"`
 	TLS     ConfigTLS         `mapstructure:"tls"`
+	Tracing ConfigTracing     `mapstructure:"tracing"`
 	UUID    uuid.UUID
 }
 
+// ConfigAlerts defines alerting on certificates of domains close to their expiry and on
+// changes of the pins of domains. ExpiryDays are the thresholds in days before the expiry at
+// which a certificate is reported (see keys.WithExpiryThresholds) and Webhook the endpoint
+// notified when one is crossed or a pin changes.
+type ConfigAlerts struct {
+	ExpiryDays []int               `mapstructure:"expiry_days"`
+	Webhook    ConfigAlertsWebhook `mapstructure:"webhook"`
+}
+
+// ConfigAlertsWebhook defines the HTTP endpoint alerts are posted to as JSON.
+// Alerts are only logged and exposed as metrics when URL is empty.
+type ConfigAlertsWebhook struct {
+	Timeout time.Duration `mapstructure:"timeout"`
+	URL     string        `mapstructure:"url"`
+}
+
 // ConfigLog defines logging configuration for the application.
 // It controls log output format, verbosity level, and pretty-printing options.
+// Packages sets the level of single packages by their path below internal, e.g. keys: debug
+// (see logging.Levels); the levels can be changed at runtime with the admin API.
 type ConfigLog struct {
-	Format string `mapstructure:"format"`
-	Level  string `mapstructure:"level"`
-	Pretty bool   `mapstructure:"pretty"`
+	Format   string            `mapstructure:"format"`
+	Level    string            `mapstructure:"level"`
+	Packages map[string]string `mapstructure:"packages"`
+	Pretty   bool              `mapstructure:"pretty"`
+}
+
+// ConfigMetrics defines the metrics server, which serves the Prometheus metrics, the health probes and
+// the admin API on Listen. With PathPrefix all its endpoints are served below the prefix, e.g. behind a
+// path based ingress. Pprof serves the runtime profiles of net/http/pprof at /debug/pprof/, protected
+// like the admin API. OTLP additionally exports the metrics via OTLP, StatsD pushes them to a StatsD server
+// and Pushgateway pushes the final metrics to a Prometheus Pushgateway on shutdown.
+type ConfigMetrics struct {
+	Listen      string                   `mapstructure:"listen"`
+	OTLP        ConfigMetricsOTLP        `mapstructure:"otlp"`
+	PathPrefix  string                   `mapstructure:"path_prefix"`
+	Pprof       bool                     `mapstructure:"pprof"`
+	Pushgateway ConfigMetricsPushgateway `mapstructure:"pushgateway"`
+	StatsD      ConfigMetricsStatsD      `mapstructure:"statsd"`
+}
+
+// ConfigMetricsOTLP defines the export of the metrics via OTLP/HTTP every Interval, for environments
+// without a Prometheus scraper. Endpoint is the collector URL (e.g. http://localhost:4318); when empty
+// the standard OTEL_EXPORTER_OTLP_* environment variables are used.
+type ConfigMetricsOTLP struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Endpoint string        `mapstructure:"endpoint"`
+	Insecure bool          `mapstructure:"insecure"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// ConfigMetricsPushgateway defines the push of the final metrics to the Prometheus Pushgateway at URL on
+// shutdown, so the metrics of batch or one-shot runs do not vanish with the process. The metrics are grouped
+// by Job and Instance (the hostname when empty) and the push is given up after Timeout.
+type ConfigMetricsPushgateway struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Instance string        `mapstructure:"instance"`
+	Job      string        `mapstructure:"job"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	URL      string        `mapstructure:"url"`
+}
+
+// ConfigMetricsStatsD defines the push of the metrics to the StatsD server at Host:Port every Interval in the
+// DogStatsD format, for teams monitoring with Datadog. Prefix is prepended to every metric name.
+type ConfigMetricsStatsD struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Host     string        `mapstructure:"host"`
+	Interval time.Duration `mapstructure:"interval"`
+	Port     int           `mapstructure:"port"`
+	Prefix   string        `mapstructure:"prefix"`
+}
+
+// ConfigReload defines the reload of the domain keys of the configuration file without a restart
+// (besides SIGHUP). With Watch the directory of the file is watched, so changes such as a ConfigMap
+// update are applied automatically, and the file is also checked every Interval if positive.
+type ConfigReload struct {
+	Interval time.Duration `mapstructure:"interval"`
+	Watch    bool          `mapstructure:"watch"`
 }
 
 // ConfigServer defines HTTP server configuration parameters.
-// It specifies the listen address, read timeout, and write timeout for the server.
+// It specifies the listen address, read timeout, and write timeout for the server,
+// the default JSON field naming, envelope and pin encoding of published payloads and whether the sandbox file is served.
+// AdminToken is the bearer token required by the admin API when set (usually provided via environment);
+// AdminOIDC additionally accepts bearer tokens of an OpenID Connect provider.
+// APIKeys are the keys required by the public API when set. AccessLog logs every request to the public API.
+// SwaggerUI serves a Swagger UI of the OpenAPI document at /docs.
+// CacheMaxAge is the max-age of the Cache-Control header of signed files.
+// HandlerTimeout limits the duration of handlers of the public API, RouteTimeouts per route.
+// DrainTimeout limits how long in-flight requests may take to complete on shutdown.
+// Health serves the health probes of the metrics server on the public server as well.
+// HTTP2 enables HTTP/2 of the HTTP server.
+// SecurityHeaders adds headers like X-Content-Type-Options and Strict-Transport-Security to responses.
 type ConfigServer struct {
-	Listen       string        `mapstructure:"listen"`
-	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
-	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	AccessLog       bool                       `mapstructure:"access_log"`
+	AdminOIDC       ConfigServerAdminOIDC      `mapstructure:"admin_oidc"`
+	AdminToken      string                     `mapstructure:"admin_token"`
+	APIKeys         []ConfigServerAPIKey       `mapstructure:"api_keys"`
+	CacheMaxAge     time.Duration              `mapstructure:"cache_max_age"`
+	Chaos           ConfigServerChaos          `mapstructure:"chaos"`
+	Compression     ConfigServerCompression    `mapstructure:"compression"`
+	CORS            ConfigServerCORS           `mapstructure:"cors"`
+	DrainTimeout    time.Duration              `mapstructure:"drain_timeout"`
+	Envelope        types.Envelope             `mapstructure:"envelope"`
+	HandlerTimeout  time.Duration              `mapstructure:"handler_timeout"`
+	Health          bool                       `mapstructure:"health"`
+	HTTP2           ConfigServerHTTP2          `mapstructure:"http2"`
+	Listen          string                     `mapstructure:"listen"`
+	Naming          types.Naming               `mapstructure:"naming"`
+	PinEncoding     types.PinEncoding          `mapstructure:"pin_encoding"`
+	RateLimit       ConfigServerRateLimit      `mapstructure:"rate_limit"`
+	ReadTimeout     time.Duration              `mapstructure:"read_timeout"`
+	RouteTimeouts   []ConfigServerRouteTimeout `mapstructure:"route_timeouts"`
+	Sandbox         bool                       `mapstructure:"sandbox"`
+	SecurityHeaders bool                       `mapstructure:"security_headers"`
+	SwaggerUI       bool                       `mapstructure:"swagger_ui"`
+	TLS             ConfigServerTLS            `mapstructure:"tls"`
+	WriteTimeout    time.Duration              `mapstructure:"write_timeout"`
+}
+
+// ConfigServerChaos defines artificial latency of requests to the HTTP server for testing clients:
+// every request is delayed by Latency plus a random duration of up to Jitter. Disabled when both are zero.
+type ConfigServerChaos struct {
+	Jitter  time.Duration `mapstructure:"jitter"`
+	Latency time.Duration `mapstructure:"latency"`
+}
+
+// ConfigServerHTTP2 defines HTTP/2 of the HTTP server, which multiplexes requests on one connection.
+// Enabled serves HTTP/2 over TLS, H2C additionally HTTP/2 without TLS (with prior knowledge),
+// e.g. behind a load balancer terminating TLS. HTTP/1.1 is always served.
+type ConfigServerHTTP2 struct {
+	Enabled bool `mapstructure:"enabled"`
+	H2C     bool `mapstructure:"h2c"`
+}
+
+// ConfigServerRateLimit defines per-client rate limiting of the HTTP server: every client may send
+// Burst requests at once and Rate requests per second on average. Clients are identified by their
+// API key (see APIKeys) or IP address. Requests are not limited when Rate is zero.
+type ConfigServerRateLimit struct {
+	Burst int     `mapstructure:"burst"`
+	Rate  float64 `mapstructure:"rate"`
+}
+
+// ConfigServerRouteTimeout defines the handler timeout of a route of the public API, identified
+// by its pattern, e.g. "/api/v1/{file}". A zero Timeout disables the timeout of the route.
+type ConfigServerRouteTimeout struct {
+	Route   string        `mapstructure:"route"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// ConfigServerTLS defines HTTPS of the HTTP server with the certificate chain and private key of
+// CertFile and KeyFile. With ClientCAFile clients must present a certificate issued by one of its
+// CAs (mTLS), and one of ClientAllowedNames as its common name or SAN when set.
+// The certificate and key are reloaded when they change, checked every ReloadInterval and on
+// changes of their directories. TLS is disabled when CertFile is empty, unless certificates are
+// obtained via ACME instead.
+type ConfigServerTLS struct {
+	ACME               ConfigServerTLSACME `mapstructure:"acme"`
+	CertFile           string              `mapstructure:"cert_file"`
+	ClientAllowedNames []string            `mapstructure:"client_allowed_names"`
+	ClientCAFile       string              `mapstructure:"client_ca_file"`
+	KeyFile            string              `mapstructure:"key_file"`
+	ReloadInterval     time.Duration       `mapstructure:"reload_interval"`
+}
+
+// ConfigServerTLSACME defines certificates of the HTTP server obtained and renewed automatically
+// from the ACME CA of DirectoryURL (Let's Encrypt when empty) for Hosts, registered with Email and
+// stored in CacheDir. Challenges are answered with TLS-ALPN-01 on the listen address of the server
+// and, when HTTPListen is set, with HTTP-01 on that address. ACME is disabled when Hosts is empty.
+type ConfigServerTLSACME struct {
+	CacheDir     string   `mapstructure:"cache_dir"`
+	DirectoryURL string   `mapstructure:"directory_url"`
+	Email        string   `mapstructure:"email"`
+	Hosts        []string `mapstructure:"hosts"`
+	HTTPListen   string   `mapstructure:"http_listen"`
+}
+
+// ConfigServerAdminOIDC defines the OpenID Connect provider whose JWT bearer tokens grant access
+// to the admin API. Tokens must be issued by Issuer for Audience (unless empty) and grant all Scopes.
+// The signing keys are discovered from the provider metadata of the issuer unless JWKSURL is set.
+// OIDC is disabled when Issuer is empty.
+type ConfigServerAdminOIDC struct {
+	Audience string   `mapstructure:"audience"`
+	Issuer   string   `mapstructure:"issuer"`
+	JWKSURL  string   `mapstructure:"jwks_url"`
+	Scopes   []string `mapstructure:"scopes"`
+}
+
+// ConfigServerAPIKey defines a key granting access to the public API. Name identifies the key
+// in metrics and logs; Key is the secret itself or KeyFile the path of a file containing it.
+type ConfigServerAPIKey struct {
+	Key     string `mapstructure:"key"`
+	KeyFile string `mapstructure:"key_file"`
+	Name    string `mapstructure:"name"`
+}
+
+// ConfigServerCORS defines the cross-origin requests browsers are allowed to make to the HTTP server.
+// CORS is disabled when AllowedOrigins is empty; "*" allows any origin.
+type ConfigServerCORS struct {
+	AllowedHeaders []string      `mapstructure:"allowed_headers"`
+	AllowedMethods []string      `mapstructure:"allowed_methods"`
+	AllowedOrigins []string      `mapstructure:"allowed_origins"`
+	ExposedHeaders []string      `mapstructure:"exposed_headers"`
+	MaxAge         time.Duration `mapstructure:"max_age"`
+}
+
+// ConfigServerCompression defines compression of responses of the HTTP server negotiated
+// via Accept-Encoding. Bodies smaller than MinSize bytes are sent uncompressed.
+type ConfigServerCompression struct {
+	Enabled bool `mapstructure:"enabled"`
+	MinSize int  `mapstructure:"min_size"`
 }
 
 // ConfigStorage defines storage backend configuration.
 // It includes connection parameters (DSN), dump directory for file-based persistence,
 // periodic dump interval, and storage type (filesystem, memory, redis, postgres).
 type ConfigStorage struct {
-	ConnMaxIdleTime time.Duration     `mapstructure:"conn_max_idle_time"`
-	ConnMaxLifetime time.Duration     `mapstructure:"conn_max_lifetime"`
-	DSN             string            `mapstructure:"dsn"`
-	DumpDir         string            `mapstructure:"dump_dir"`
-	MaxIdleConns    int               `mapstructure:"max_idle_conns"`
-	MaxOpenConns    int               `mapstructure:"max_open_conns"`
-	Type            types.StorageType `mapstructure:"type"`
+	Cache           ConfigStorageCache      `mapstructure:"cache"`
+	ConnMaxIdleTime time.Duration           `mapstructure:"conn_max_idle_time"`
+	ConnMaxLifetime time.Duration           `mapstructure:"conn_max_lifetime"`
+	DSN             string                  `mapstructure:"dsn"`
+	DumpDir         string                  `mapstructure:"dump_dir"`
+	Encryption      ConfigStorageEncryption `mapstructure:"encryption"`
+	MaxAge          time.Duration           `mapstructure:"max_age"`
+	MaxIdleConns    int                     `mapstructure:"max_idle_conns"`
+	MaxOpenConns    int                     `mapstructure:"max_open_conns"`
+	Type            types.StorageType       `mapstructure:"type"`
+}
+
+// maxAgeDumpIntervals is the default probe freshness window (storage.max_age)
+// expressed in dump intervals, so that one delayed flush does not fail the probes.
+const maxAgeDumpIntervals = 3
+
+// ConfigStorageCache defines the read-through cache of files read from storage.
+// The cache is disabled when TTL is zero.
+type ConfigStorageCache struct {
+	Size int           `mapstructure:"size"`
+	TTL  time.Duration `mapstructure:"ttl"`
+}
+
+// ConfigStorageEncryption defines encryption at rest of key material in external storage.
+// Key is a base64 encoded AES key (16, 24 or 32 bytes), usually provided via environment;
+// KeyFile is the path of a file containing such a key. Encryption is enabled when either is set.
+type ConfigStorageEncryption struct {
+	Key     string `mapstructure:"key"`
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// Enabled reports whether an encryption key is configured.
+func (c ConfigStorageEncryption) Enabled() bool {
+	return c.Key != "" || c.KeyFile != ""
 }
 
 // ConfigTLS defines TLS/cryptographic configuration.
 // Dir specifies the directory containing TLS certificate files (prv.pem, pub.pem).
 // Timeout sets the duration for TLS operations.
+// FetchInterval is the interval of certificate fetches of domain keys without their own interval
+// and FetchConcurrency the maximum number of concurrent fetches. DialRate limits the outbound
+// dials of all fetches per second with bursts of up to DialBurst dials. Maintenance starts the service
+// with fetching paused (see keys.Keys.Pause). Proxy is the proxy certificates
+// of domain keys without their own proxy are fetched through (see keys.ParseProxy). CAFile adds
+// root certificates the certificate chains of domains are verified against and SkipVerify
+// disables the verification. Resolvers replace the system resolver for the host names of domains
+// fetched without a proxy (see keys.ParseResolver) and AllAddresses fetches certificates from
+// every address of a domain. PinHistory is the number of distinct pins of a domain that are
+// published, the current and the previous ones. OCSP configures revocation checks of the
+// certificates of domains and CT checks of their Certificate Transparency SCTs.
+// Algorithm requires the signature algorithm of the signing key (RS512, ES256, ES384);
+// when empty it is selected from the key.
+// LegacyFormat (the default) publishes signed JSON files without key ID, algorithm and
+// signing time metadata.
+// Passphrase (usually provided via environment) or the contents of PassphraseFile decrypt
+// encrypted PKCS#8 private keys. Rotation configures a rotation of the signing key.
+// KMS replaces prv.pem with an AWS KMS key, Signer with the Google Cloud KMS key of a
+// kms://projects/.../cryptoKeyVersions/... DSN and Vault with a Vault transit key. TSA
+// configures the timestamping of signatures.
 type ConfigTLS struct {
-	Dir          string        `mapstructure:"dir"`
-	DumpInterval time.Duration `mapstructure:"dump_interval"`
-	Timeout      time.Duration `mapstructure:"timeout"`
+	Algorithm        string            `mapstructure:"algorithm"`
+	AllAddresses     bool              `mapstructure:"all_addresses"`
+	CAFile           string            `mapstructure:"ca_file"`
+	CT               ConfigTLSCT       `mapstructure:"ct"`
+	DialBurst        int               `mapstructure:"dial_burst"`
+	DialRate         float64           `mapstructure:"dial_rate"`
+	Dir              string            `mapstructure:"dir"`
+	DumpInterval     time.Duration     `mapstructure:"dump_interval"`
+	FetchConcurrency int               `mapstructure:"fetch_concurrency"`
+	FetchInterval    time.Duration     `mapstructure:"fetch_interval"`
+	KMS              ConfigTLSKMS      `mapstructure:"kms"`
+	LegacyFormat     bool              `mapstructure:"legacy_format"`
+	Maintenance      bool              `mapstructure:"maintenance"`
+	OCSP             ConfigTLSOCSP     `mapstructure:"ocsp"`
+	Passphrase       string            `mapstructure:"passphrase"`
+	PassphraseFile   string            `mapstructure:"passphrase_file"`
+	PinHistory       int               `mapstructure:"pin_history"`
+	Proxy            string            `mapstructure:"proxy"`
+	Resolvers        []string          `mapstructure:"resolvers"`
+	Rotation         ConfigTLSRotation `mapstructure:"rotation"`
+	Signer           string            `mapstructure:"signer"`
+	SkipVerify       bool              `mapstructure:"skip_verify"`
+	Timeout          time.Duration     `mapstructure:"timeout"`
+	TSA              ConfigTLSTSA      `mapstructure:"tsa"`
+	Vault            ConfigTLSVault    `mapstructure:"vault"`
+}
+
+// ConfigTLSCT defines checks of the Certificate Transparency SCTs of the certificates of domains.
+// LogList is the path of a CT log list in the JSON format (v3) of Chrome; the checks are disabled
+// when it is empty. Certificates with valid SCTs of fewer than MinSCTs known logs are reported
+// in the last_error of the domain key.
+type ConfigTLSCT struct {
+	LogList string `mapstructure:"log_list"`
+	MinSCTs int    `mapstructure:"min_scts"`
+}
+
+// ConfigTLSKMS defines an asymmetric AWS KMS signing key used instead of prv.pem.
+// KeyID is the key ID, key ARN or alias; Region defaults to the region of the key ARN or
+// AWS_REGION and Endpoint to the public KMS endpoint of the region.
+// Signing is done by KMS when KeyID is set.
+type ConfigTLSKMS struct {
+	Endpoint string `mapstructure:"endpoint"`
+	KeyID    string `mapstructure:"key_id"`
+	Region   string `mapstructure:"region"`
+}
+
+// ConfigTLSOCSP defines OCSP revocation checks of the certificates of domains.
+// The revocation of a certificate is reported in the last_error of the domain key;
+// Withhold additionally withholds the pin of the revoked certificate from published files.
+type ConfigTLSOCSP struct {
+	Enabled  bool `mapstructure:"enabled"`
+	Withhold bool `mapstructure:"withhold"`
+}
+
+// ConfigTLSRotation defines a rotation of the signing key.
+// KeyFile is the path of the new private key; until Cutover (RFC 3339) files are signed
+// with both the key in Dir and the new key, afterwards with the new key only.
+// The rotation is disabled when KeyFile is empty.
+type ConfigTLSRotation struct {
+	Cutover time.Time `mapstructure:"cutover"`
+	KeyFile string    `mapstructure:"key_file"`
+}
+
+// ConfigTLSTSA defines the RFC 3161 time-stamping authority of signed files.
+// URL is the HTTP endpoint of the TSA; the primary signature of every file in the legacy
+// envelope is timestamped when it is set.
+type ConfigTLSTSA struct {
+	URL string `mapstructure:"url"`
+}
+
+// ConfigTLSVault defines a Vault transit signing key used instead of prv.pem.
+// Key is the name of the transit key in the secrets engine mounted at Mount; Address and
+// Namespace default to VAULT_ADDR and VAULT_NAMESPACE. The token is read from TokenFile
+// (e.g. a Vault agent sink) or VAULT_TOKEN. Signing is done by Vault when Key is set.
+type ConfigTLSVault struct {
+	Address   string `mapstructure:"address"`
+	Key       string `mapstructure:"key"`
+	Mount     string `mapstructure:"mount"`
+	Namespace string `mapstructure:"namespace"`
+	TokenFile string `mapstructure:"token_file"`
+}
+
+// ConfigTracing defines OpenTelemetry tracing configuration.
+// Endpoint is the OTLP/HTTP collector URL (e.g. http://localhost:4318); when empty the
+// standard OTEL_EXPORTER_OTLP_* environment variables are used. SampleRatio is the fraction
+// of new traces that are recorded, requests with a sampled parent are always recorded.
+type ConfigTracing struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
 }
 
 // New loads and validates application configuration from viper.
 // It unmarshals configuration from file, validates storage type against allowed values,
+// validates the payload naming style, envelope and pin encoding, storage cache and encryption settings, private key
+// passphrase, signer DSN, TSA URL, expiry alerts, key rotation, tracing sample ratio and fetch intervals,
+// defaults the probe freshness window (storage.max_age) to a multiple of the dump interval,
 // sets default values for domain keys (File and DomainName fields if not specified),
 // and generates a unique UUID for the application instance.
 // Returns an error if unmarshaling fails or storage type is invalid.
@@ -102,23 +453,482 @@ func New() (Config, error) {
 		UUID: uuid.New(),
 	}
 
-	if err := viper.Unmarshal(&config); err != nil {
+	decodeHook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		stringToJSONHookFunc(),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+		mapstructure.StringToWeakSliceHookFunc(","),
+	))
+
+	if err := viper.Unmarshal(&config, decodeHook); err != nil {
 		return config, fmt.Errorf("failed to unmarshal storage config: %w", err)
 	}
 
+	naming, err := types.ParseNaming(string(config.Server.Naming))
+	if err != nil {
+		return config, err
+	}
+	config.Server.Naming = naming
+
+	envelope, err := types.ParseEnvelope(string(config.Server.Envelope))
+	if err != nil {
+		return config, err
+	}
+	config.Server.Envelope = envelope
+
+	encoding, err := types.ParsePinEncoding(string(config.Server.PinEncoding))
+	if err != nil {
+		return config, err
+	}
+	config.Server.PinEncoding = encoding
+
+	if config.Storage.Cache.TTL < 0 {
+		return config, fmt.Errorf("storage cache ttl must not be negative, got %s", config.Storage.Cache.TTL)
+	}
+
+	if config.Storage.Cache.TTL > 0 && config.Storage.Cache.Size < 1 {
+		return config, fmt.Errorf("storage cache size must be positive, got %d", config.Storage.Cache.Size)
+	}
+
+	if config.Storage.MaxAge < 0 {
+		return config, fmt.Errorf("storage max_age must not be negative, got %s", config.Storage.MaxAge)
+	}
+
+	if config.Storage.MaxAge == 0 {
+		config.Storage.MaxAge = maxAgeDumpIntervals * config.TLS.DumpInterval
+	}
+
+	if config.Storage.MaxAge > 0 && config.Storage.MaxAge <= config.TLS.DumpInterval {
+		slog.Warn("storage max_age does not exceed the dump interval, probes may fail between flushes",
+			"max_age", config.Storage.MaxAge,
+			"dump_interval", config.TLS.DumpInterval,
+		)
+	}
+
+	if config.Storage.Encryption.Key != "" && config.Storage.Encryption.KeyFile != "" {
+		return config, fmt.Errorf("storage encryption key and key_file are mutually exclusive")
+	}
+
+	if config.Storage.Encryption.Enabled() &&
+		config.Storage.Type != types.StorageRedis && config.Storage.Type != types.StoragePostgres {
+		return config, fmt.Errorf("storage encryption is supported for redis and postgres storage only, got %q",
+			config.Storage.Type)
+	}
+
+	if config.TLS.Passphrase != "" && config.TLS.PassphraseFile != "" {
+		return config, fmt.Errorf("tls passphrase and passphrase_file are mutually exclusive")
+	}
+
+	if config.TLS.Signer != "" {
+		if _, err := gcpkms.ParseDSN(config.TLS.Signer); err != nil {
+			return config, err
+		}
+	}
+
+	if config.TLS.Proxy != "" {
+		if _, err := keys.ParseProxy(config.TLS.Proxy); err != nil {
+			return config, fmt.Errorf("tls proxy: %w", err)
+		}
+	}
+
+	for _, r := range config.TLS.Resolvers {
+		if _, err := keys.ParseResolver(r); err != nil {
+			return config, fmt.Errorf("tls resolvers: %w", err)
+		}
+	}
+
+	if config.TLS.OCSP.Withhold && !config.TLS.OCSP.Enabled {
+		return config, fmt.Errorf("tls ocsp withhold requires enabled")
+	}
+
+	if config.TLS.CAFile != "" && config.TLS.SkipVerify {
+		return config, fmt.Errorf("tls ca_file and skip_verify are mutually exclusive")
+	}
+
+	if config.TLS.TSA.URL != "" {
+		if _, err := tsa.New(config.TLS.TSA.URL); err != nil {
+			return config, err
+		}
+	}
+
+	for _, d := range config.Alerts.ExpiryDays {
+		if d < 1 {
+			return config, fmt.Errorf("alerts expiry_days must be positive, got %d", d)
+		}
+	}
+
+	if config.Alerts.Webhook.Timeout < 0 {
+		return config, fmt.Errorf("alerts webhook timeout must not be negative, got %s", config.Alerts.Webhook.Timeout)
+	}
+
+	if config.Alerts.Webhook.URL != "" {
+		if _, err := notify.New(config.Alerts.Webhook.URL); err != nil {
+			return config, fmt.Errorf("alerts webhook: %w", err)
+		}
+	}
+
+	if (config.TLS.Signer != "" && config.TLS.KMS.KeyID != "") ||
+		(config.TLS.Signer != "" && config.TLS.Vault.Key != "") ||
+		(config.TLS.KMS.KeyID != "" && config.TLS.Vault.Key != "") {
+		return config, fmt.Errorf("tls signer, kms key_id and vault key are mutually exclusive")
+	}
+
+	if config.TLS.Rotation.KeyFile != "" && config.TLS.Rotation.Cutover.IsZero() {
+		return config, fmt.Errorf("tls rotation cutover is required with key_file")
+	}
+
+	if config.TLS.Rotation.KeyFile == "" && !config.TLS.Rotation.Cutover.IsZero() {
+		return config, fmt.Errorf("tls rotation key_file is required with cutover")
+	}
+
+	if config.TLS.Rotation.KeyFile != "" && !time.Now().Before(config.TLS.Rotation.Cutover) {
+		slog.Warn("tls rotation cutover has passed, files are signed with the new key only; replace prv.pem with it",
+			"cutover", config.TLS.Rotation.Cutover,
+			"key_file", config.TLS.Rotation.KeyFile,
+		)
+	}
+
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		return config, fmt.Errorf("invalid tracing sample ratio: %v", config.Tracing.SampleRatio)
+	}
+
+	if config.TLS.FetchConcurrency < 0 {
+		return config, fmt.Errorf("tls fetch_concurrency must not be negative, got %d", config.TLS.FetchConcurrency)
+	}
+
+	if config.TLS.CT.MinSCTs < 0 {
+		return config, fmt.Errorf("tls ct min_scts must not be negative, got %d", config.TLS.CT.MinSCTs)
+	}
+
+	if config.TLS.PinHistory < 0 {
+		return config, fmt.Errorf("tls pin_history must not be negative, got %d", config.TLS.PinHistory)
+	}
+
+	if config.Server.CacheMaxAge < 0 {
+		return config, fmt.Errorf("server cache_max_age must not be negative, got %s", config.Server.CacheMaxAge)
+	}
+
+	if config.Server.Compression.MinSize < 0 {
+		return config, fmt.Errorf("server compression min_size must not be negative, got %d", config.Server.Compression.MinSize)
+	}
+
+	if oidc := config.Server.AdminOIDC; oidc.Issuer == "" && (oidc.Audience != "" || oidc.JWKSURL != "" || len(oidc.Scopes) > 0) {
+		return config, fmt.Errorf("server admin_oidc requires an issuer")
+	}
+
+	// the admin API must not be reachable by others without authentication
+	if l := config.Metrics.Listen; l != "" && !isLoopback(l) && config.Server.AdminToken == "" && config.Server.AdminOIDC.Issuer == "" {
+		return config, fmt.Errorf("metrics listen %q is not a loopback address, set server admin_token or server admin_oidc issuer to protect the admin API", l)
+	}
+
+	if config.Server.Chaos.Latency < 0 || config.Server.Chaos.Jitter < 0 {
+		return config, fmt.Errorf("server chaos latency and jitter must not be negative")
+	}
+
+	if p := config.Metrics.PathPrefix; p != "" && (!strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") || strings.ContainsAny(p, " {}")) {
+		return config, fmt.Errorf("metrics path_prefix must be a path like /ssl-pinning without a trailing slash, got %q", p)
+	}
+
+	if config.Metrics.OTLP.Interval < 0 {
+		return config, fmt.Errorf("metrics otlp interval must not be negative, got %s", config.Metrics.OTLP.Interval)
+	}
+
+	if p := config.Metrics.Pushgateway; p.Enabled {
+		if u, err := url.Parse(p.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return config, fmt.Errorf("metrics pushgateway url must be an http(s) URL, got %q", p.URL)
+		}
+
+		if p.Job == "" {
+			return config, fmt.Errorf("metrics pushgateway job is required when the pushgateway is enabled")
+		}
+
+		if p.Timeout <= 0 {
+			return config, fmt.Errorf("metrics pushgateway timeout must be positive, got %s", p.Timeout)
+		}
+	}
+
+	if s := config.Metrics.StatsD; s.Enabled {
+		if s.Host == "" {
+			return config, fmt.Errorf("metrics statsd host is required when statsd is enabled")
+		}
+
+		if s.Port < 1 || s.Port > 65535 {
+			return config, fmt.Errorf("metrics statsd port must be between 1 and 65535, got %d", s.Port)
+		}
+
+		if s.Interval <= 0 {
+			return config, fmt.Errorf("metrics statsd interval must be positive, got %s", s.Interval)
+		}
+	}
+
+	if config.Reload.Interval < 0 {
+		return config, fmt.Errorf("reload interval must not be negative, got %s", config.Reload.Interval)
+	}
+
+	for pkg, level := range config.Log.Packages {
+		if _, err := logging.ParseLevel(level); err != nil {
+			return config, fmt.Errorf("log packages %s: %w", pkg, err)
+		}
+	}
+
+	if config.Server.DrainTimeout < 0 {
+		return config, fmt.Errorf("server drain_timeout must not be negative, got %s", config.Server.DrainTimeout)
+	}
+
+	if config.Server.HandlerTimeout < 0 {
+		return config, fmt.Errorf("server handler_timeout must not be negative, got %s", config.Server.HandlerTimeout)
+	}
+
+	routes := make(map[string]bool, len(config.Server.RouteTimeouts))
+
+	for _, rt := range config.Server.RouteTimeouts {
+		if rt.Route == "" {
+			return config, fmt.Errorf("server route_timeouts require a route")
+		}
+
+		if routes[rt.Route] {
+			return config, fmt.Errorf("server route_timeouts route %q is not unique", rt.Route)
+		}
+
+		routes[rt.Route] = true
+
+		if rt.Timeout < 0 {
+			return config, fmt.Errorf("server route_timeouts timeout of %q must not be negative, got %s", rt.Route, rt.Timeout)
+		}
+	}
+
+	if config.Server.DrainTimeout > 0 {
+		handlerTimeout := config.Server.HandlerTimeout
+		for _, rt := range config.Server.RouteTimeouts {
+			handlerTimeout = max(handlerTimeout, rt.Timeout)
+		}
+
+		if config.Server.DrainTimeout < handlerTimeout {
+			slog.Warn("server drain_timeout is shorter than handler timeouts, requests in flight may be cut off on shutdown",
+				"drain_timeout", config.Server.DrainTimeout,
+				"handler_timeout", handlerTimeout,
+			)
+		}
+	}
+
+	if config.Server.HTTP2.H2C && !config.Server.HTTP2.Enabled {
+		return config, fmt.Errorf("server http2 h2c requires enabled")
+	}
+
+	if config.Server.RateLimit.Rate < 0 {
+		return config, fmt.Errorf("server rate_limit rate must not be negative, got %g", config.Server.RateLimit.Rate)
+	}
+
+	if config.Server.RateLimit.Rate > 0 && config.Server.RateLimit.Burst < 1 {
+		return config, fmt.Errorf("server rate_limit burst must be positive, got %d", config.Server.RateLimit.Burst)
+	}
+
+	if config.Server.TLS.ReloadInterval < 0 {
+		return config, fmt.Errorf("server tls reload_interval must not be negative, got %s", config.Server.TLS.ReloadInterval)
+	}
+
+	if (config.Server.TLS.CertFile == "") != (config.Server.TLS.KeyFile == "") {
+		return config, fmt.Errorf("server tls cert_file and key_file must be set together")
+	}
+
+	if acme := config.Server.TLS.ACME; len(acme.Hosts) > 0 {
+		if config.Server.TLS.CertFile != "" {
+			return config, fmt.Errorf("server tls acme hosts and cert_file are mutually exclusive")
+		}
+
+		if acme.CacheDir == "" {
+			return config, fmt.Errorf("server tls acme requires cache_dir")
+		}
+
+		if slices.Contains(acme.Hosts, "") {
+			return config, fmt.Errorf("server tls acme hosts must not be empty")
+		}
+
+		if acme.DirectoryURL != "" {
+			if u, err := url.Parse(acme.DirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				return config, fmt.Errorf("server tls acme directory_url must be an https URL, got %q", acme.DirectoryURL)
+			}
+		}
+	}
+
+	if config.Server.TLS.ClientCAFile != "" && config.Server.TLS.CertFile == "" && len(config.Server.TLS.ACME.Hosts) == 0 {
+		return config, fmt.Errorf("server tls client_ca_file requires cert_file and key_file or acme hosts")
+	}
+
+	if len(config.Server.TLS.ClientAllowedNames) > 0 && config.Server.TLS.ClientCAFile == "" {
+		return config, fmt.Errorf("server tls client_allowed_names requires client_ca_file")
+	}
+
+	apiKeys := make(map[string]bool, len(config.Server.APIKeys))
+
+	for _, k := range config.Server.APIKeys {
+		if k.Name == "" {
+			return config, fmt.Errorf("server api_keys require a name")
+		}
+
+		if apiKeys[k.Name] {
+			return config, fmt.Errorf("server api_keys name %q is not unique", k.Name)
+		}
+
+		apiKeys[k.Name] = true
+
+		if (k.Key == "") == (k.KeyFile == "") {
+			return config, fmt.Errorf("server api_keys %q requires exactly one of key and key_file", k.Name)
+		}
+	}
+
+	for _, origin := range config.Server.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			return config, fmt.Errorf("server cors allowed_origins must be \"*\" or an origin such as https://example.com, got %q", origin)
+		}
+	}
+
+	if config.Server.CORS.MaxAge < 0 {
+		return config, fmt.Errorf("server cors max_age must not be negative, got %s", config.Server.CORS.MaxAge)
+	}
+
+	if config.TLS.DialRate < 0 {
+		return config, fmt.Errorf("tls dial_rate must not be negative, got %g", config.TLS.DialRate)
+	}
+
+	if config.TLS.DialRate > 0 && config.TLS.DialBurst < 1 {
+		return config, fmt.Errorf("tls dial_burst must be positive, got %d", config.TLS.DialBurst)
+	}
+
+	if config.TLS.FetchInterval < 0 {
+		return config, fmt.Errorf("tls fetch_interval must not be negative, got %s", config.TLS.FetchInterval)
+	}
+
 	for i, k := range config.Keys {
-		if k.File == "" {
-			k.File = fmt.Sprintf("%s.json", k.Fqdn)
+		if k.Interval < 0 {
+			return config, fmt.Errorf("interval of key %q must not be negative, got %s", k.Fqdn, k.Interval)
+		}
+
+		if err := keys.ValidateChain(k.Chain); err != nil {
+			return config, fmt.Errorf("chain of key %q: %w", k.Fqdn, err)
+		}
+
+		if err := keys.ValidatePins(k.BackupPins); err != nil {
+			return config, fmt.Errorf("backup_pins of key %q: %w", k.Fqdn, err)
+		}
+
+		if (k.ClientCert == "") != (k.ClientKey == "") {
+			return config, fmt.Errorf("client_cert and client_key of key %q must be set together", k.Fqdn)
+		}
+
+		if strings.Contains(k.Apex(), "*") || (k.IsWildcard() && k.Apex() == "") {
+			return config, fmt.Errorf("fqdn of key %q must be a host or a wildcard like *.example.com", k.Fqdn)
+		}
+
+		if len(k.Hosts) > 0 && !k.IsWildcard() {
+			return config, fmt.Errorf("hosts of key %q require a wildcard fqdn", k.Fqdn)
+		}
+
+		for _, host := range k.Hosts {
+			if !k.MatchesWildcard(host) {
+				return config, fmt.Errorf("host %q of key %q does not match the wildcard", host, k.Fqdn)
+			}
 		}
 
-		if k.DomainName == "" {
-			k.DomainName = fmt.Sprintf("*.%s", k.Fqdn)
+		if k.Proxy != "" {
+			if _, err := keys.ParseProxy(k.Proxy); err != nil {
+				return config, fmt.Errorf("proxy of key %q: %w", k.Fqdn, err)
+			}
 		}
 
-		config.Keys[i] = k
+		config.Keys[i] = k.WithDefaults()
 	}
 
 	slog.Debug("configuration loaded", "config", config)
 
 	return config, nil
 }
+
+// isLoopback reports whether the listen address addr only accepts connections from the local host.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// BindEnv binds every setting of Config to its environment variable, e.g. storage.dsn to
+// SSL_PINNING_STORAGE_DSN, so that settings without a default are read from the environment as
+// well; viper only looks up the variables of settings it knows of otherwise. Lists are given
+// comma-separated, lists of objects (keys, server.api_keys, server.route_timeouts) and maps
+// (log.packages) JSON-encoded (see stringToJSONHookFunc).
+// The environment prefix and key replacer of viper must be set before.
+func BindEnv() {
+	bindEnv(reflect.TypeFor[Config](), "")
+}
+
+// bindEnv binds the settings of the fields of the struct t below prefix.
+func bindEnv(t reflect.Type, prefix string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("mapstructure")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		key := prefix + tag
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		switch {
+		case ft.Kind() == reflect.Struct && hasSettings(ft):
+			bindEnv(ft, key+".")
+		default:
+			viper.BindEnv(key)
+		}
+	}
+}
+
+// stringToJSONHookFunc returns a decode hook that decodes a JSON-encoded string, e.g. of an
+// environment variable, into maps and lists of objects, which have no other string form.
+func stringToJSONHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data any) (any, error) {
+		if f.Kind() != reflect.String || !isJSONSetting(t) {
+			return data, nil
+		}
+
+		var v any
+		if err := json.Unmarshal([]byte(data.(string)), &v); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON of %s: %w", t, err)
+		}
+
+		return v, nil
+	}
+}
+
+// isJSONSetting reports whether settings of type t are given JSON-encoded in a single string.
+func isJSONSetting(t reflect.Type) bool {
+	return t.Kind() == reflect.Map || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct
+}
+
+// hasSettings reports whether the struct t has fields with settings, unlike e.g. time.Time.
+func hasSettings(t reflect.Type) bool {
+	for i := range t.NumField() {
+		if t.Field(i).Tag.Get("mapstructure") != "" {
+			return true
+		}
+	}
+
+	return false
+}
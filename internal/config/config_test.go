@@ -32,6 +32,7 @@ POSSIBILITY OF SUCH DAMAGE.
 package config
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -132,55 +133,1336 @@ func TestNew(t *testing.T) {
 				assert.Equal(t, "*.example.org", cfg.Keys[0].DomainName)
 			},
 		},
+		{
+			name: "fetch intervals",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.fetch_concurrency", 64)
+				viper.Set("tls.fetch_interval", "5m")
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "interval": "60s"},
+					{"fqdn": "example.com"},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 64, cfg.TLS.FetchConcurrency)
+				assert.Equal(t, 5*time.Minute, cfg.TLS.FetchInterval)
+				require.Len(t, cfg.Keys, 2)
+				assert.Equal(t, time.Minute, cfg.Keys[0].Interval)
+				assert.Zero(t, cfg.Keys[1].Interval)
+			},
+		},
+		{
+			name: "port, connect address and server name",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "internal.example.org:8443", "connect": "10.0.0.10", "server_name": "www.example.org"},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Keys, 1)
+				assert.Equal(t, "10.0.0.10", cfg.Keys[0].Connect)
+				assert.Equal(t, "*.internal.example.org", cfg.Keys[0].DomainName)
+				assert.Equal(t, "10.0.0.10:8443", cfg.Keys[0].Address())
+				assert.Equal(t, "www.example.org", cfg.Keys[0].SNI())
+			},
+		},
+		{
+			name: "proxies",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.proxy", "http://proxy.internal:3128")
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "proxy": "socks5://proxy.internal"},
+					{"fqdn": "internal.example.org", "proxy": "direct"},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "http://proxy.internal:3128", cfg.TLS.Proxy)
+				require.Len(t, cfg.Keys, 2)
+				assert.Equal(t, "socks5://proxy.internal", cfg.Keys[0].Proxy)
+				assert.Equal(t, "direct", cfg.Keys[1].Proxy)
+			},
+		},
+		{
+			name: "client certificate",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "internal.example.org", "client_cert": "/etc/ssl-pinning/client.pem", "client_key": "/etc/ssl-pinning/client.key"},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Keys, 1)
+				assert.Equal(t, "/etc/ssl-pinning/client.pem", cfg.Keys[0].ClientCert)
+				assert.Equal(t, "/etc/ssl-pinning/client.key", cfg.Keys[0].ClientKey)
+			},
+		},
+		{
+			name: "client certificate without key",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "internal.example.org", "client_cert": "/etc/ssl-pinning/client.pem"},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "chain",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "chain": []string{"leaf", "intermediate"}},
+					{"fqdn": "example.com", "chain": "leaf,root"},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Keys, 2)
+				assert.Equal(t, []string{"leaf", "intermediate"}, cfg.Keys[0].Chain)
+				assert.Equal(t, []string{"leaf", "root"}, cfg.Keys[1].Chain)
+			},
+		},
+		{
+			name: "backup pins",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "backup_pins": []string{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Keys, 1)
+				assert.Equal(t, []string{"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}, cfg.Keys[0].BackupPins)
+			},
+		},
+		{
+			name: "invalid backup pin",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "backup_pins": []string{"sha256/next"}},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcard",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "*.example.org"},
+					{"fqdn": "*.example.com", "hosts": []string{"a.example.com", "b.example.com"}},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Keys, 2)
+				assert.True(t, cfg.Keys[0].IsWildcard())
+				assert.Equal(t, "*.example.org", cfg.Keys[0].DomainName)
+				assert.Equal(t, []string{"a.example.com", "b.example.com"}, cfg.Keys[1].Hosts)
+			},
+		},
+		{
+			name: "invalid wildcard",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "a.*.example.org"},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "hosts without wildcard",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "hosts": []string{"a.example.org"}},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "host not matching wildcard",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "*.example.org", "hosts": []string{"a.b.example.org"}},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "ocsp withhold without enabled",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.ocsp.withhold", true)
+			},
+			wantErr: true,
+		},
+		{
+			name: "ocsp withhold",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.ocsp.enabled", true)
+				viper.Set("tls.ocsp.withhold", true)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.TLS.OCSP.Enabled)
+				assert.True(t, cfg.TLS.OCSP.Withhold)
+			},
+		},
+		{
+			name: "invalid resolver",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.resolvers", []string{"1.1.1.1", "tls://1.1.1.1"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "resolvers",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.resolvers", []string{"1.1.1.1", "https://cloudflare-dns.com/dns-query"})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, []string{"1.1.1.1", "https://cloudflare-dns.com/dns-query"}, cfg.TLS.Resolvers)
+			},
+		},
+		{
+			name: "negative min scts",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.ct.min_scts", -1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "ct",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.ct.log_list", "/etc/ssl-pinning/log_list.json")
+				viper.Set("tls.ct.min_scts", 3)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "/etc/ssl-pinning/log_list.json", cfg.TLS.CT.LogList)
+				assert.Equal(t, 3, cfg.TLS.CT.MinSCTs)
+			},
+		},
+		{
+			name: "negative pin history",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.pin_history", -1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid chain",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "chain": []string{"issuer"}},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid proxy",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.proxy", "ftp://proxy.internal")
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid key proxy",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "proxy": "proxy.internal:3128"},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "root CAs",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.ca_file", "/etc/ssl-pinning/ca.pem")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "/etc/ssl-pinning/ca.pem", cfg.TLS.CAFile)
+				assert.False(t, cfg.TLS.SkipVerify)
+			},
+		},
+		{
+			name: "skip verify",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.skip_verify", true)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.TLS.SkipVerify)
+			},
+		},
+		{
+			name: "root CAs and skip verify",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.ca_file", "/etc/ssl-pinning/ca.pem")
+				viper.Set("tls.skip_verify", true)
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative fetch concurrency",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.fetch_concurrency", -1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "dial rate",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.dial_rate", 2.5)
+				viper.Set("tls.dial_burst", 5)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 2.5, cfg.TLS.DialRate)
+				assert.Equal(t, 5, cfg.TLS.DialBurst)
+			},
+		},
+		{
+			name: "negative cache max age",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.cache_max_age", -time.Second)
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative compression min size",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.compression.min_size", -1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin oidc",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.admin_oidc.issuer", "https://sso.example.com/realms/ops")
+				viper.Set("server.admin_oidc.audience", "ssl-pinning")
+				viper.Set("server.admin_oidc.scopes", []string{"pins:admin"})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "https://sso.example.com/realms/ops", cfg.Server.AdminOIDC.Issuer)
+				assert.Equal(t, []string{"pins:admin"}, cfg.Server.AdminOIDC.Scopes)
+			},
+		},
+		{
+			name: "admin oidc without issuer",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.admin_oidc.scopes", []string{"pins:admin"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics on all interfaces without admin authentication",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", "0.0.0.0:9464")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics on any address without admin authentication",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", ":9090")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics on a host name without admin authentication",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", "ssl-pinning.internal:9090")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics on all interfaces with admin token",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", "0.0.0.0:9464")
+				viper.Set("server.admin_token", "secret")
+			},
+			wantErr: false,
+		},
+		{
+			name: "metrics on all interfaces with admin oidc",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", "[::]:9464")
+				viper.Set("server.admin_oidc.issuer", "https://sso.example.com/realms/ops")
+			},
+			wantErr: false,
+		},
+		{
+			name: "metrics on loopback without admin authentication",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", "127.0.0.1:9090")
+			},
+			wantErr: false,
+		},
+		{
+			name: "metrics on ipv6 loopback without admin authentication",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", "[::1]:9090")
+			},
+			wantErr: false,
+		},
+		{
+			name: "metrics on localhost without admin authentication",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", "localhost:9090")
+			},
+			wantErr: false,
+		},
+		{
+			name: "server mtls",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.tls.cert_file", "/etc/ssl-pinning/tls.crt")
+				viper.Set("server.tls.key_file", "/etc/ssl-pinning/tls.key")
+				viper.Set("server.tls.client_ca_file", "/etc/ssl-pinning/clients.pem")
+				viper.Set("server.tls.client_allowed_names", []string{"gateway.internal"})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "/etc/ssl-pinning/clients.pem", cfg.Server.TLS.ClientCAFile)
+				assert.Equal(t, []string{"gateway.internal"}, cfg.Server.TLS.ClientAllowedNames)
+			},
+		},
+		{
+			name: "server tls cert without key",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.tls.cert_file", "/etc/ssl-pinning/tls.crt")
+			},
+			wantErr: true,
+		},
+		{
+			name: "server client ca without tls",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.tls.client_ca_file", "/etc/ssl-pinning/clients.pem")
+			},
+			wantErr: true,
+		},
+		{
+			name: "server tls acme",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.tls.acme.hosts", []string{"pins.example.com"})
+				viper.Set("server.tls.acme.cache_dir", "/var/lib/ssl-pinning/acme")
+				viper.Set("server.tls.acme.email", "ops@example.com")
+				viper.Set("server.tls.acme.http_listen", ":80")
+				viper.Set("server.tls.client_ca_file", "/etc/ssl-pinning/clients.pem")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, []string{"pins.example.com"}, cfg.Server.TLS.ACME.Hosts)
+				assert.Equal(t, "/var/lib/ssl-pinning/acme", cfg.Server.TLS.ACME.CacheDir)
+				assert.Equal(t, "ops@example.com", cfg.Server.TLS.ACME.Email)
+				assert.Equal(t, ":80", cfg.Server.TLS.ACME.HTTPListen)
+			},
+		},
+		{
+			name: "server tls acme with cert file",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.tls.acme.hosts", []string{"pins.example.com"})
+				viper.Set("server.tls.acme.cache_dir", "/var/lib/ssl-pinning/acme")
+				viper.Set("server.tls.cert_file", "/etc/ssl-pinning/tls.crt")
+				viper.Set("server.tls.key_file", "/etc/ssl-pinning/tls.key")
+			},
+			wantErr: true,
+		},
+		{
+			name: "server tls acme without cache dir",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.tls.acme.hosts", []string{"pins.example.com"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "server tls acme invalid directory url",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.tls.acme.hosts", []string{"pins.example.com"})
+				viper.Set("server.tls.acme.cache_dir", "/var/lib/ssl-pinning/acme")
+				viper.Set("server.tls.acme.directory_url", "http://localhost:14000/dir")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics server",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.listen", "0.0.0.0:9464")
+				viper.Set("metrics.path_prefix", "/ssl-pinning")
+				viper.Set("server.admin_token", "secret")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "0.0.0.0:9464", cfg.Metrics.Listen)
+				assert.Equal(t, "/ssl-pinning", cfg.Metrics.PathPrefix)
+			},
+		},
+		{
+			name: "metrics path prefix with trailing slash",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.path_prefix", "/ssl-pinning/")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics path prefix without leading slash",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.path_prefix", "ssl-pinning")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics otlp",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.otlp.enabled", true)
+				viper.Set("metrics.otlp.endpoint", "http://localhost:4318")
+				viper.Set("metrics.otlp.interval", "30s")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.Metrics.OTLP.Enabled)
+				assert.Equal(t, "http://localhost:4318", cfg.Metrics.OTLP.Endpoint)
+				assert.Equal(t, 30*time.Second, cfg.Metrics.OTLP.Interval)
+			},
+		},
+		{
+			name: "negative metrics otlp interval",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.otlp.interval", "-1s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics pushgateway",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.pushgateway.enabled", true)
+				viper.Set("metrics.pushgateway.url", "http://pushgateway:9091")
+				viper.Set("metrics.pushgateway.job", "ssl-pinning")
+				viper.Set("metrics.pushgateway.instance", "ci")
+				viper.Set("metrics.pushgateway.timeout", "5s")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.Metrics.Pushgateway.Enabled)
+				assert.Equal(t, "http://pushgateway:9091", cfg.Metrics.Pushgateway.URL)
+				assert.Equal(t, "ssl-pinning", cfg.Metrics.Pushgateway.Job)
+				assert.Equal(t, "ci", cfg.Metrics.Pushgateway.Instance)
+				assert.Equal(t, 5*time.Second, cfg.Metrics.Pushgateway.Timeout)
+			},
+		},
+		{
+			name: "invalid metrics pushgateway url",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.pushgateway.enabled", true)
+				viper.Set("metrics.pushgateway.url", "pushgateway:9091")
+				viper.Set("metrics.pushgateway.job", "ssl-pinning")
+				viper.Set("metrics.pushgateway.timeout", "5s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics pushgateway without job",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.pushgateway.enabled", true)
+				viper.Set("metrics.pushgateway.url", "http://pushgateway:9091")
+				viper.Set("metrics.pushgateway.timeout", "5s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics statsd",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.statsd.enabled", true)
+				viper.Set("metrics.statsd.host", "datadog-agent")
+				viper.Set("metrics.statsd.port", 8125)
+				viper.Set("metrics.statsd.prefix", "pinning.")
+				viper.Set("metrics.statsd.interval", "10s")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.Metrics.StatsD.Enabled)
+				assert.Equal(t, "datadog-agent", cfg.Metrics.StatsD.Host)
+				assert.Equal(t, 8125, cfg.Metrics.StatsD.Port)
+				assert.Equal(t, "pinning.", cfg.Metrics.StatsD.Prefix)
+				assert.Equal(t, 10*time.Second, cfg.Metrics.StatsD.Interval)
+			},
+		},
+		{
+			name: "invalid metrics statsd port",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.statsd.enabled", true)
+				viper.Set("metrics.statsd.host", "datadog-agent")
+				viper.Set("metrics.statsd.port", 0)
+				viper.Set("metrics.statsd.interval", "10s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics statsd without interval",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("metrics.statsd.enabled", true)
+				viper.Set("metrics.statsd.host", "datadog-agent")
+				viper.Set("metrics.statsd.port", 8125)
+			},
+			wantErr: true,
+		},
+		{
+			name: "reload",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("reload.watch", true)
+				viper.Set("reload.interval", "30s")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.Reload.Watch)
+				assert.Equal(t, 30*time.Second, cfg.Reload.Interval)
+			},
+		},
+		{
+			name: "negative reload interval",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("reload.interval", "-1s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "log packages",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("log.packages", map[string]string{"keys": "debug", "storage/redis": "warn"})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, map[string]string{"keys": "debug", "storage/redis": "warn"}, cfg.Log.Packages)
+			},
+		},
+		{
+			name: "invalid log packages level",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("log.packages", map[string]string{"keys": "verbose"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "server drain timeout",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.drain_timeout", "30s")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 30*time.Second, cfg.Server.DrainTimeout)
+			},
+		},
+		{
+			name: "server negative drain timeout",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.drain_timeout", "-1s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "server http2 h2c",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.http2.enabled", true)
+				viper.Set("server.http2.h2c", true)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.Server.HTTP2.Enabled)
+				assert.True(t, cfg.Server.HTTP2.H2C)
+			},
+		},
+		{
+			name: "server http2 h2c without http2",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.http2.h2c", true)
+			},
+			wantErr: true,
+		},
+		{
+			name: "rate limit",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.rate_limit.rate", 0.5)
+				viper.Set("server.rate_limit.burst", 10)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 0.5, cfg.Server.RateLimit.Rate)
+				assert.Equal(t, 10, cfg.Server.RateLimit.Burst)
+			},
+		},
+		{
+			name: "chaos",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.chaos.latency", "3s")
+				viper.Set("server.chaos.jitter", "500ms")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, ConfigServerChaos{Jitter: 500 * time.Millisecond, Latency: 3 * time.Second}, cfg.Server.Chaos)
+			},
+		},
+		{
+			name: "route timeouts",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.handler_timeout", "2s")
+				viper.Set("server.route_timeouts", []map[string]any{
+					{"route": "/api/v1/{file}", "timeout": "1s"},
+					{"route": "POST /api/v1/verify", "timeout": "0s"},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 2*time.Second, cfg.Server.HandlerTimeout)
+				assert.Equal(t, []ConfigServerRouteTimeout{
+					{Route: "/api/v1/{file}", Timeout: time.Second},
+					{Route: "POST /api/v1/verify"},
+				}, cfg.Server.RouteTimeouts)
+			},
+		},
+		{
+			name: "route timeout without route",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.route_timeouts", []map[string]any{{"timeout": "1s"}})
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate route timeout",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.route_timeouts", []map[string]any{
+					{"route": "/api/v1/files", "timeout": "1s"},
+					{"route": "/api/v1/files", "timeout": "2s"},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative chaos latency",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.chaos.latency", "-1s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "rate limit without burst",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.rate_limit.rate", 1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "api keys",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.api_keys", []map[string]string{
+					{"name": "ios", "key": "secret"},
+					{"name": "android", "key_file": "/run/secrets/android"},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, []ConfigServerAPIKey{
+					{Name: "ios", Key: "secret"},
+					{Name: "android", KeyFile: "/run/secrets/android"},
+				}, cfg.Server.APIKeys)
+			},
+		},
+		{
+			name: "api key without name",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.api_keys", []map[string]string{{"key": "secret"}})
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate api key name",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.api_keys", []map[string]string{
+					{"name": "ios", "key": "a"},
+					{"name": "ios", "key": "b"},
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "api key with key and key file",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.api_keys", []map[string]string{{"name": "ios", "key": "a", "key_file": "/a"}})
+			},
+			wantErr: true,
+		},
+		{
+			name: "cors origins",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.cors.allowed_origins", []string{"*", "https://dashboard.example.com"})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, []string{"*", "https://dashboard.example.com"}, cfg.Server.CORS.AllowedOrigins)
+			},
+		},
+		{
+			name: "invalid cors origin",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.cors.allowed_origins", []string{"https://dashboard.example.com/pins"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative dial rate",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.dial_rate", -1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "dial rate without burst",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.dial_rate", 1)
+				viper.Set("tls.dial_burst", 0)
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative fetch interval",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.fetch_interval", "-1s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative key interval",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "example.org", "interval": "-1s"},
+				})
+			},
+			wantErr: true,
+		},
 		{
 			name: "preserve existing File and DomainName",
 			setupViper: func() {
 				viper.Reset()
-				viper.Set("keys", []map[string]interface{}{
-					{
-						"fqdn":       "custom.com",
-						"file":       "custom-file.json",
-						"domainName": "custom.domain.com",
-					},
-				})
+				viper.Set("keys", []map[string]interface{}{
+					{
+						"fqdn":       "custom.com",
+						"file":       "custom-file.json",
+						"domainName": "custom.domain.com",
+					},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Keys, 1)
+				assert.Equal(t, "custom.com", cfg.Keys[0].Fqdn)
+				assert.Equal(t, "custom-file.json", cfg.Keys[0].File)
+				assert.Equal(t, "custom.domain.com", cfg.Keys[0].DomainName)
+			},
+		},
+		{
+			name: "multiple keys",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("keys", []map[string]interface{}{
+					{"fqdn": "first.com"},
+					{"fqdn": "second.com", "file": "second.json"},
+					{"fqdn": "third.com", "domainName": "*.custom.third.com"},
+				})
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Keys, 3)
+
+				// First key
+				assert.Equal(t, "first.com", cfg.Keys[0].Fqdn)
+				assert.Equal(t, "first.com.json", cfg.Keys[0].File)
+				assert.Equal(t, "*.first.com", cfg.Keys[0].DomainName)
+
+				// Second key
+				assert.Equal(t, "second.com", cfg.Keys[1].Fqdn)
+				assert.Equal(t, "second.json", cfg.Keys[1].File)
+				assert.Equal(t, "*.second.com", cfg.Keys[1].DomainName)
+
+				// Third key
+				assert.Equal(t, "third.com", cfg.Keys[2].Fqdn)
+				assert.Equal(t, "third.com.json", cfg.Keys[2].File)
+				assert.Equal(t, "*.custom.third.com", cfg.Keys[2].DomainName)
+			},
+		},
+		{
+			name: "naming defaults to legacy",
+			setupViper: func() {
+				viper.Reset()
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, types.NamingLegacy, cfg.Server.Naming)
+			},
+		},
+		{
+			name: "snake case naming",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.naming", "snake_case")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, types.NamingSnake, cfg.Server.Naming)
+			},
+		},
+		{
+			name: "invalid naming",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.naming", "kebab-case")
+			},
+			wantErr: true,
+		},
+		{
+			name: "pin encoding defaults to base64",
+			setupViper: func() {
+				viper.Reset()
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, types.PinEncodingBase64, cfg.Server.PinEncoding)
+			},
+		},
+		{
+			name: "hex pin encoding",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.pin_encoding", "hex")
 			},
 			wantErr: false,
 			validateFunc: func(t *testing.T, cfg Config) {
-				require.Len(t, cfg.Keys, 1)
-				assert.Equal(t, "custom.com", cfg.Keys[0].Fqdn)
-				assert.Equal(t, "custom-file.json", cfg.Keys[0].File)
-				assert.Equal(t, "custom.domain.com", cfg.Keys[0].DomainName)
+				assert.Equal(t, types.PinEncodingHex, cfg.Server.PinEncoding)
 			},
 		},
 		{
-			name: "multiple keys",
+			name: "invalid pin encoding",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.pin_encoding", "base32")
+			},
+			wantErr: true,
+		},
+		{
+			name: "envelope defaults to legacy",
 			setupViper: func() {
 				viper.Reset()
-				viper.Set("keys", []map[string]interface{}{
-					{"fqdn": "first.com"},
-					{"fqdn": "second.com", "file": "second.json"},
-					{"fqdn": "third.com", "domainName": "*.custom.third.com"},
-				})
 			},
 			wantErr: false,
 			validateFunc: func(t *testing.T, cfg Config) {
-				require.Len(t, cfg.Keys, 3)
-
-				// First key
-				assert.Equal(t, "first.com", cfg.Keys[0].Fqdn)
-				assert.Equal(t, "first.com.json", cfg.Keys[0].File)
-				assert.Equal(t, "*.first.com", cfg.Keys[0].DomainName)
-
-				// Second key
-				assert.Equal(t, "second.com", cfg.Keys[1].Fqdn)
-				assert.Equal(t, "second.json", cfg.Keys[1].File)
-				assert.Equal(t, "*.second.com", cfg.Keys[1].DomainName)
-
-				// Third key
-				assert.Equal(t, "third.com", cfg.Keys[2].Fqdn)
-				assert.Equal(t, "third.com.json", cfg.Keys[2].File)
-				assert.Equal(t, "*.custom.third.com", cfg.Keys[2].DomainName)
+				assert.Equal(t, types.EnvelopeLegacy, cfg.Server.Envelope)
+			},
+		},
+		{
+			name: "jws envelope",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.envelope", "jws")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, types.EnvelopeJWS, cfg.Server.Envelope)
+			},
+		},
+		{
+			name: "invalid envelope",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("server.envelope", "jwt")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls legacy format",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.legacy_format", true)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.TLS.LegacyFormat)
+			},
+		},
+		{
+			name: "tls passphrase and passphrase file",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.passphrase", "secret")
+				viper.Set("tls.passphrase_file", "/run/secrets/passphrase")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls rotation",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.rotation.key_file", "/etc/tls/prv.next.pem")
+				viper.Set("tls.rotation.cutover", "2030-01-02T03:04:05Z")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "/etc/tls/prv.next.pem", cfg.TLS.Rotation.KeyFile)
+				assert.Equal(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), cfg.TLS.Rotation.Cutover.UTC())
+			},
+		},
+		{
+			name: "tls kms",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.kms.key_id", "alias/ssl-pinning")
+				viper.Set("tls.kms.region", "eu-west-1")
+				viper.Set("tls.kms.endpoint", "https://vpce.kms.eu-west-1.vpce.amazonaws.com")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, ConfigTLSKMS{
+					Endpoint: "https://vpce.kms.eu-west-1.vpce.amazonaws.com",
+					KeyID:    "alias/ssl-pinning",
+					Region:   "eu-west-1",
+				}, cfg.TLS.KMS)
+			},
+		},
+		{
+			name: "tls signer",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.signer", "kms://projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "kms://projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", cfg.TLS.Signer)
+			},
+		},
+		{
+			name: "invalid tls signer",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.signer", "kms://projects/p/locations/global/keyRings/r/cryptoKeys/k")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls tsa",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.tsa.url", "https://freetsa.org/tsr")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "https://freetsa.org/tsr", cfg.TLS.TSA.URL)
+			},
+		},
+		{
+			name: "invalid tls tsa url",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.tsa.url", "freetsa.org/tsr")
+			},
+			wantErr: true,
+		},
+		{
+			name: "alerts",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("alerts.expiry_days", "30,14,7")
+				viper.Set("alerts.webhook.timeout", "10s")
+				viper.Set("alerts.webhook.url", "https://hooks.example.com/ssl-pinning")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, []int{30, 14, 7}, cfg.Alerts.ExpiryDays)
+				assert.Equal(t, 10*time.Second, cfg.Alerts.Webhook.Timeout)
+				assert.Equal(t, "https://hooks.example.com/ssl-pinning", cfg.Alerts.Webhook.URL)
+			},
+		},
+		{
+			name: "invalid alerts expiry days",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("alerts.expiry_days", []int{30, 0})
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative alerts webhook timeout",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("alerts.webhook.timeout", "-1s")
 			},
+			wantErr: true,
+		},
+		{
+			name: "invalid alerts webhook url",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("alerts.webhook.url", "hooks.example.com")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls signer and kms key id",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.signer", "kms://projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1")
+				viper.Set("tls.kms.key_id", "alias/ssl-pinning")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls vault",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.vault.address", "https://vault:8200")
+				viper.Set("tls.vault.key", "ssl-pinning")
+				viper.Set("tls.vault.mount", "pki-signing")
+				viper.Set("tls.vault.token_file", "/run/vault/token")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, ConfigTLSVault{
+					Address:   "https://vault:8200",
+					Key:       "ssl-pinning",
+					Mount:     "pki-signing",
+					TokenFile: "/run/vault/token",
+				}, cfg.TLS.Vault)
+			},
+		},
+		{
+			name: "tls vault and kms key id",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.vault.key", "ssl-pinning")
+				viper.Set("tls.kms.key_id", "alias/ssl-pinning")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls rotation without cutover",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.rotation.key_file", "/etc/tls/prv.next.pem")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls rotation without key file",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.rotation.cutover", "2030-01-02T03:04:05Z")
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid tls rotation cutover",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.rotation.key_file", "/etc/tls/prv.next.pem")
+				viper.Set("tls.rotation.cutover", "next monday")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tracing config",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tracing.enabled", true)
+				viper.Set("tracing.endpoint", "http://localhost:4318")
+				viper.Set("tracing.sample_ratio", 0.25)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.Tracing.Enabled)
+				assert.Equal(t, "http://localhost:4318", cfg.Tracing.Endpoint)
+				assert.Equal(t, 0.25, cfg.Tracing.SampleRatio)
+			},
+		},
+		{
+			name: "invalid tracing sample ratio",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tracing.sample_ratio", 1.5)
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage max age defaults to multiple of dump interval",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.dump_interval", "30s")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 90*time.Second, cfg.Storage.MaxAge)
+			},
+		},
+		{
+			name: "storage max age",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("tls.dump_interval", "30s")
+				viper.Set("storage.max_age", "2m")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 2*time.Minute, cfg.Storage.MaxAge)
+			},
+		},
+		{
+			name: "negative storage max age",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("storage.max_age", "-1s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage cache",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("storage.cache.ttl", "30s")
+				viper.Set("storage.cache.size", 128)
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 30*time.Second, cfg.Storage.Cache.TTL)
+				assert.Equal(t, 128, cfg.Storage.Cache.Size)
+			},
+		},
+		{
+			name: "negative storage cache ttl",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("storage.cache.ttl", "-1s")
+				viper.Set("storage.cache.size", 128)
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage cache without size",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("storage.cache.ttl", "30s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage encryption",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("storage.type", "redis")
+				viper.Set("storage.encryption.key", "c2VjcmV0LWtleS0xNi1ieQ==")
+			},
+			wantErr: false,
+			validateFunc: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.Storage.Encryption.Enabled())
+				assert.Equal(t, "c2VjcmV0LWtleS0xNi1ieQ==", cfg.Storage.Encryption.Key)
+			},
+		},
+		{
+			name: "storage encryption key and key file",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("storage.type", "postgres")
+				viper.Set("storage.encryption.key", "c2VjcmV0LWtleS0xNi1ieQ==")
+				viper.Set("storage.encryption.key_file", "/etc/ssl-pinning/storage.key")
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage encryption with local storage",
+			setupViper: func() {
+				viper.Reset()
+				viper.Set("storage.type", "fs")
+				viper.Set("storage.encryption.key_file", "/etc/ssl-pinning/storage.key")
+			},
+			wantErr: true,
 		},
 		{
 			name: "empty config",
@@ -230,3 +1512,83 @@ func TestConfig_UUIDGeneration(t *testing.T) {
 	assert.NotEmpty(t, cfg1.UUID.String())
 	assert.NotEmpty(t, cfg2.UUID.String())
 }
+
+func TestBindEnv(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.SetEnvPrefix("ssl-pinning")
+	BindEnv()
+
+	// settings without a default, which viper does not look up on its own
+	t.Setenv("SSL_PINNING_STORAGE_DSN", "postgres://localhost:5432/db")
+	t.Setenv("SSL_PINNING_STORAGE_MAX_OPEN_CONNS", "7")
+	t.Setenv("SSL_PINNING_SERVER_CORS_ALLOWED_ORIGINS", "https://a.example.com,https://b.example.com")
+	t.Setenv("SSL_PINNING_METRICS_OTLP_INTERVAL", "45s")
+	t.Setenv("SSL_PINNING_TLS_DIR", "/etc/ssl-pinning")
+
+	cfg, err := New()
+	require.NoError(t, err)
+
+	assert.Equal(t, "postgres://localhost:5432/db", cfg.Storage.DSN)
+	assert.Equal(t, 7, cfg.Storage.MaxOpenConns)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, cfg.Server.CORS.AllowedOrigins)
+	assert.Equal(t, 45*time.Second, cfg.Metrics.OTLP.Interval)
+	assert.Equal(t, "/etc/ssl-pinning", cfg.TLS.Dir)
+
+	assert.Contains(t, viper.AllKeys(), "tls.rotation.cutover")
+}
+
+func TestBindEnv_JSON(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`
+keys:
+  - fqdn: file.example.com
+log:
+  packages:
+    keys: warn
+`)))
+
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.SetEnvPrefix("ssl-pinning")
+	BindEnv()
+
+	t.Run("file", func(t *testing.T) {
+		cfg, err := New()
+		require.NoError(t, err)
+
+		require.Len(t, cfg.Keys, 1)
+		assert.Equal(t, "file.example.com", cfg.Keys[0].Fqdn)
+		assert.Equal(t, map[string]string{"keys": "warn"}, cfg.Log.Packages)
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("SSL_PINNING_KEYS", `[{"fqdn": "env.example.com", "backup_pins": ["LXEWQrcmsEQBYnyp+6wy9chTD7GQPMTbAiWHF5IaSIE="], "interval": "1m"}]`)
+		t.Setenv("SSL_PINNING_LOG_PACKAGES", `{"storage": "debug"}`)
+		t.Setenv("SSL_PINNING_SERVER_API_KEYS", `[{"name": "ios", "key": "secret"}]`)
+		t.Setenv("SSL_PINNING_SERVER_ROUTE_TIMEOUTS", `[{"route": "/api/v1/{file}", "timeout": "2s"}]`)
+
+		cfg, err := New()
+		require.NoError(t, err)
+
+		require.Len(t, cfg.Keys, 1)
+		assert.Equal(t, "env.example.com", cfg.Keys[0].Fqdn)
+		assert.Equal(t, []string{"LXEWQrcmsEQBYnyp+6wy9chTD7GQPMTbAiWHF5IaSIE="}, cfg.Keys[0].BackupPins)
+		assert.Equal(t, time.Minute, cfg.Keys[0].Interval)
+		assert.Equal(t, map[string]string{"storage": "debug"}, cfg.Log.Packages)
+		assert.Equal(t, []ConfigServerAPIKey{{Name: "ios", Key: "secret"}}, cfg.Server.APIKeys)
+		assert.Equal(t, []ConfigServerRouteTimeout{{Route: "/api/v1/{file}", Timeout: 2 * time.Second}}, cfg.Server.RouteTimeouts)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("SSL_PINNING_KEYS", "file.example.com")
+
+		_, err := New()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decode JSON")
+	})
+}
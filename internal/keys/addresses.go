@@ -0,0 +1,174 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// addresses returns the addresses of a domain fetched without a proxy, resolved with the
+// resolvers set with WithResolvers or the system resolver. Returns nil for domains fetched
+// through a proxy, IP addresses and host names that cannot be resolved.
+func (k *Keys) addresses(ctx context.Context, key *types.DomainKey) []string {
+	if proxyURL, err := k.proxyFor(key); err != nil || proxyURL != nil {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(key.Address())
+	if err != nil || net.ParseIP(host) != nil {
+		return nil
+	}
+
+	var ips []string
+	if len(k.resolvers) > 0 {
+		ips, err = k.lookup(ctx, host)
+	} else {
+		ips, err = net.DefaultResolver.LookupHost(ctx, host)
+	}
+
+	if err != nil {
+		return nil
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, port)
+	}
+
+	return addrs
+}
+
+// fetchAddresses fetches the certificate of a domain from each of its addresses concurrently.
+// The key of the first address that answered is returned with the pins of all addresses, so
+// that clients accept the certificate of every edge, and the earliest expiration. Addresses
+// serving different certificates are logged and reported by the ssl_pinning_pin_mismatch metric;
+// unreachable addresses are logged. The first address is dialed with the token the fetch took
+// from the limiter (see WithDialRate), every other address waits for its own token.
+// Returns the error of the first address if none answered.
+func (k *Keys) fetchAddresses(ctx context.Context, key *types.DomainKey, cfg *tls.Config, addrs []string) (*types.DomainKey, error) {
+	results := make([]*types.DomainKey, len(addrs))
+	errs := make([]error, len(addrs))
+
+	var wg sync.WaitGroup
+
+	for i, addr := range addrs {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if i > 0 {
+				if errs[i] = k.limiter.wait(ctx); errs[i] != nil {
+					return
+				}
+			}
+
+			results[i], errs[i] = k.fetchAddress(ctx, key, cfg, addr)
+		}()
+	}
+
+	wg.Wait()
+
+	var (
+		res    *types.DomainKey
+		pins   []string
+		served = make(map[string][]string)
+	)
+
+	for i, r := range results {
+		if r == nil {
+			slog.Warn("failed to fetch domain key from address", "fqdn", key.Fqdn, "addr", addrs[i], "err", errs[i])
+			continue
+		}
+
+		if res == nil {
+			res = r
+		} else {
+			res.Expire = min(res.Expire, r.Expire)
+
+			if r.LastError != "" && !strings.Contains(res.LastError, r.LastError) {
+				addLastError(res, r.ErrorCategory, r.LastError)
+			}
+		}
+
+		served[r.Key] = append(served[r.Key], addrs[i])
+
+		for _, p := range append([]string{r.Key}, r.Pins...) {
+			if !slices.Contains(pins, p) {
+				pins = append(pins, p)
+			}
+		}
+	}
+
+	if res == nil {
+		return nil, errs[0]
+	}
+
+	if len(served) > 1 {
+		slog.Warn("addresses of domain serve different certificates", "fqdn", key.Fqdn, "pins", served)
+
+		k.collector.SetPinMismatch(key.Fqdn, 1)
+	} else {
+		k.collector.SetPinMismatch(key.Fqdn, 0)
+	}
+
+	if len(pins) > 1 {
+		res.Pins = pins
+	}
+
+	return res, nil
+}
+
+// fetchAddress fetches the certificate of a domain from one of its addresses.
+func (k *Keys) fetchAddress(ctx context.Context, key *types.DomainKey, cfg *tls.Config, addr string) (*types.DomainKey, error) {
+	var dialer net.Dialer
+
+	raw, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := handshake(ctx, cfg, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.keyOf(ctx, key, state)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+// newEdge starts a TLS server presenting the leaf of c on addr.
+func newEdge(t *testing.T, c testChain, addr string) *httptest.Server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", addr, err)
+	}
+
+	srv := &httptest.Server{
+		Listener: ln,
+		Config:   &http.Server{Handler: http.NotFoundHandler()},
+	}
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{c.leaf.Raw, c.intermediate.Raw},
+			PrivateKey:  c.leafKey,
+		}},
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestKeys_FetchDomainKey_AllAddresses(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	c1, c2 := newTestChain(t), newTestChain(t)
+
+	edge1 := newEdge(t, c1, "127.0.0.1:0")
+	_, port, err := net.SplitHostPort(edge1.Listener.Addr().String())
+	require.NoError(t, err)
+
+	newEdge(t, c2, net.JoinHostPort("127.0.0.2", port))
+	newEdge(t, c1, net.JoinHostPort("127.0.0.3", port))
+
+	pin1, err := pin(c1.leaf)
+	require.NoError(t, err)
+
+	pin2, err := pin(c2.leaf)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(c1.root)
+	roots.AddCert(c2.root)
+
+	doh := newDoHServer(t, map[string][]string{
+		"mismatch.example.com.":    {"127.0.0.1", "127.0.0.2"},
+		"consistent.example.com.":  {"127.0.0.1", "127.0.0.3"},
+		"unreachable.example.com.": {"127.0.0.4", "127.0.0.1"},
+		"single.example.com.":      {"127.0.0.2"},
+	})
+
+	tests := []struct {
+		name     string
+		host     string
+		all      bool
+		wantKey  string
+		wantPins []string
+	}{
+		{name: "disabled", host: "mismatch.example.com", wantKey: pin1},
+		{name: "different certificates", host: "mismatch.example.com", all: true, wantKey: pin1, wantPins: []string{pin1, pin2}},
+		{name: "same certificate", host: "consistent.example.com", all: true, wantKey: pin1},
+		{name: "unreachable address", host: "unreachable.example.com", all: true, wantKey: pin1},
+		{name: "single address", host: "single.example.com", all: true, wantKey: pin2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k := NewKeys(ctx, []types.DomainKey{},
+				WithAllAddresses(tt.all),
+				WithCollector(metrics.NewCollector()),
+				WithProxy(ProxyDirect),
+				WithResolvers([]string{doh.URL}),
+				WithRootCAs(roots),
+				WithTimeout(2*time.Second),
+			)
+			k.dohClient = doh.Client()
+
+			res, err := k.fetchDomainKey(&types.DomainKey{Fqdn: net.JoinHostPort(tt.host, port), ServerName: "example.com"})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantKey, res.Key)
+			assert.Equal(t, tt.wantPins, res.Pins)
+		})
+	}
+}
+
+func TestKeys_addresses(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	doh := newDoHServer(t, map[string][]string{"example.com.": {"192.0.2.1", "192.0.2.2"}})
+
+	tests := []struct {
+		name string
+		key  types.DomainKey
+		opts []Option
+		want []string
+	}{
+		{name: "host name", key: types.DomainKey{Fqdn: "example.com"}, want: []string{"192.0.2.1:443", "192.0.2.2:443"}},
+		{name: "port", key: types.DomainKey{Fqdn: "example.com:8443"}, want: []string{"192.0.2.1:8443", "192.0.2.2:8443"}},
+		{name: "ip address", key: types.DomainKey{Fqdn: "example.com", Connect: "192.0.2.9"}},
+		{name: "proxy", key: types.DomainKey{Fqdn: "example.com", Proxy: "http://proxy.internal:3128"}},
+		{name: "not found", key: types.DomainKey{Fqdn: "missing.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := NewKeys(ctx, []types.DomainKey{}, WithProxy(ProxyDirect), WithResolvers([]string{doh.URL}))
+			k.dohClient = doh.Client()
+
+			assert.Equal(t, tt.want, k.addresses(ctx, &tt.key))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+func TestCertificateOf(t *testing.T) {
+	date := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	uri, _ := url.Parse("spiffe://example.com/web")
+
+	cert := &x509.Certificate{
+		SerialNumber:   big.NewInt(0x0a0b0c),
+		Issuer:         pkix.Name{CommonName: "Example CA", Organization: []string{"Example"}},
+		Subject:        pkix.Name{CommonName: "example.com"},
+		NotBefore:      date.Add(-24 * time.Hour),
+		NotAfter:       date.Add(90 * 24 * time.Hour),
+		DNSNames:       []string{"example.com", "www.example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1")},
+		EmailAddresses: []string{"admin@example.com"},
+		URIs:           []*url.URL{uri},
+	}
+
+	got := certificateOf("example.com", cert, date)
+
+	assert.Equal(t, &types.Certificate{
+		Date:      date,
+		Fqdn:      "example.com",
+		Issuer:    "CN=Example CA,O=Example",
+		NotAfter:  cert.NotAfter,
+		NotBefore: cert.NotBefore,
+		SANs:      []string{"example.com", "www.example.com", "192.0.2.1", "admin@example.com", "spiffe://example.com/web"},
+		Serial:    "0a0b0c",
+		Subject:   "CN=example.com",
+	}, got)
+}
+
+func TestKeys_FetchDomainKey_Cert(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{}, WithSkipVerify(true), WithTimeout(2*time.Second))
+
+	fqdn := strings.TrimPrefix(srv.URL, "https://")
+
+	res, err := k.fetchDomainKey(&types.DomainKey{Fqdn: fqdn})
+	require.NoError(t, err)
+	require.NotNil(t, res.Cert)
+
+	leaf := srv.Certificate()
+	assert.Equal(t, fqdn, res.Cert.Fqdn)
+	assert.Equal(t, leaf.Issuer.String(), res.Cert.Issuer)
+	assert.Equal(t, leaf.Subject.String(), res.Cert.Subject)
+	assert.True(t, leaf.NotAfter.Equal(res.Cert.NotAfter))
+	assert.Contains(t, res.Cert.SANs, "example.com")
+	assert.Contains(t, res.Cert.SANs, "127.0.0.1")
+	assert.NotEmpty(t, res.Cert.Serial)
+	assert.WithinDuration(t, time.Now(), res.Cert.Date, time.Minute)
+}
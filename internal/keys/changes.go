@@ -0,0 +1,108 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// maxPinChanges is the number of most recent pin changes kept (see PinChanges).
+const maxPinChanges = 100
+
+// Event types of notifications, see ExpiryEvent and PinChange.
+const (
+	EventExpiry    = "expiry"
+	EventPinChange = "pin_change"
+)
+
+// PinChange records that the pin of a domain changed between two certificate fetches,
+// e.g. because its certificate was replaced with one of a new key.
+type PinChange struct {
+	Event  string    `json:"event"`
+	Date   time.Time `json:"date"`
+	Fqdn   string    `json:"fqdn"`
+	File   string    `json:"file"`
+	OldPin string    `json:"old_pin"`
+	NewPin string    `json:"new_pin"`
+}
+
+// WithPinChangeFunc sets the function notified when the pin of a domain changes, e.g. a webhook.
+func WithPinChangeFunc(f func(PinChange) error) Option {
+	return func(k *Keys) {
+		k.pinChangeFunc = f
+	}
+}
+
+// PinChanges returns the most recent pin changes, newest first, of the domain fqdn or of all
+// domains when fqdn is empty. Pin changes are kept in memory by this instance only and are
+// lost on restart; changes of removed domains are kept.
+func (k *Keys) PinChanges(fqdn string) []PinChange {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	changes := make([]PinChange, 0, len(k.pinChanges))
+	for i := len(k.pinChanges) - 1; i >= 0; i-- {
+		if fqdn == "" || k.pinChanges[i].Fqdn == fqdn {
+			changes = append(changes, k.pinChanges[i])
+		}
+	}
+
+	return changes
+}
+
+// recordPinChange appends a pin change to the most recent pin changes and drops the oldest
+// beyond maxPinChanges. The caller must hold k.mu.
+func (k *Keys) recordPinChange(change PinChange) {
+	k.pinChanges = append(k.pinChanges, change)
+
+	if n := len(k.pinChanges) - maxPinChanges; n > 0 {
+		k.pinChanges = slices.Delete(k.pinChanges, 0, n)
+	}
+}
+
+// notifyPinChange reports a pin change in the ssl_pinning_pin_rotations_total metric and the
+// log and notifies the function set with WithPinChangeFunc.
+func (k *Keys) notifyPinChange(change PinChange) {
+	k.collector.IncPinRotation(change.Fqdn)
+
+	slog.Warn("domain pin changed", "fqdn", change.Fqdn, "old_pin", change.OldPin, "new_pin", change.NewPin)
+
+	if k.pinChangeFunc == nil {
+		return
+	}
+
+	if err := k.pinChangeFunc(change); err != nil {
+		slog.Error("failed to notify pin change", "fqdn", change.Fqdn, "err", err)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+func TestKeys_recordPinChange(t *testing.T) {
+	k := &Keys{}
+
+	for i := range maxPinChanges + 5 {
+		k.recordPinChange(PinChange{Fqdn: "example.com", NewPin: fmt.Sprint(i)})
+	}
+
+	require.Len(t, k.pinChanges, maxPinChanges)
+	assert.Equal(t, "5", k.pinChanges[0].NewPin)
+	assert.Equal(t, fmt.Sprint(maxPinChanges+4), k.pinChanges[maxPinChanges-1].NewPin)
+}
+
+func TestKeys_PinChanges(t *testing.T) {
+	k := &Keys{}
+	k.recordPinChange(PinChange{Fqdn: "example.com", NewPin: "pin1"})
+	k.recordPinChange(PinChange{Fqdn: "www.example.com", NewPin: "pin2"})
+	k.recordPinChange(PinChange{Fqdn: "example.com", NewPin: "pin3"})
+
+	tests := []struct {
+		name string
+		fqdn string
+		want []string
+	}{
+		{name: "all domains", fqdn: "", want: []string{"pin3", "pin2", "pin1"}},
+		{name: "domain", fqdn: "example.com", want: []string{"pin3", "pin1"}},
+		{name: "unknown domain", fqdn: "unknown.com", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pins := []string{}
+			for _, c := range k.PinChanges(tt.fqdn) {
+				pins = append(pins, c.NewPin)
+			}
+
+			assert.Equal(t, tt.want, pins)
+		})
+	}
+}
+
+func TestKeys_Update_PinChange(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		notified []PinChange
+		pin      atomic.Value
+		fail     atomic.Bool
+	)
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithPinChangeFunc(func(c PinChange) error {
+			notified = append(notified, c)
+			return nil
+		}),
+	)
+
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		if fail.Load() {
+			return nil, errors.New("connection refused")
+		}
+
+		return &types.DomainKey{Expire: 3600, Key: pin.Load().(string)}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.mu.Lock()
+	k.scheduled[key.Fqdn] = 1
+	k.mu.Unlock()
+	k.Set(key.Fqdn, key)
+
+	pin.Store("pin1")
+	k.update(&key)
+	assert.Empty(t, k.PinChanges(""), "first fetch")
+
+	k.update(&key)
+	assert.Empty(t, k.PinChanges(""), "unchanged pin")
+
+	fail.Store(true)
+	k.update(&key)
+	assert.Empty(t, k.PinChanges(""), "failed fetch")
+
+	fail.Store(false)
+	pin.Store("pin2")
+	k.update(&key)
+
+	changes := k.PinChanges(key.Fqdn)
+	require.Len(t, changes, 1)
+	assert.Equal(t, EventPinChange, changes[0].Event)
+	assert.Equal(t, "example.com", changes[0].Fqdn)
+	assert.Equal(t, "example.json", changes[0].File)
+	assert.Equal(t, "pin1", changes[0].OldPin)
+	assert.Equal(t, "pin2", changes[0].NewPin)
+	assert.False(t, changes[0].Date.IsZero())
+	assert.Equal(t, changes, notified)
+}
@@ -0,0 +1,357 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// SCT entry types and algorithms (RFC 6962).
+const (
+	sctVersionV1          = 0
+	sctEntryX509          = 0
+	sctEntryPrecert       = 1
+	sctHashSHA256         = 4
+	sctSignatureRSA       = 1
+	sctSignatureECDSA     = 3
+	sctTypeCertTimestamp  = 0
+	sctLogIDSize          = sha256.Size
+	sctFixedHeaderSize    = 1 + sctLogIDSize + 8
+	sctSignatureFixedSize = 1 + 1 + 2
+)
+
+// oidSCTList is the X.509 extension of SCTs embedded in certificates.
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// CTLogs maps the IDs of known Certificate Transparency logs, the SHA-256 hashes of their
+// public keys, to the public keys.
+type CTLogs map[[sctLogIDSize]byte]crypto.PublicKey
+
+// ctLogEntry is a log of a CT log list.
+type ctLogEntry struct {
+	Key string `json:"key"`
+}
+
+// LoadCTLogs loads the known CT logs from a log list in the JSON format (v3) of
+// https://www.gstatic.com/ct/log_list/v3/log_list.json, including tiled logs.
+// Returns an error if the file cannot be read or parsed or contains no log.
+func LoadCTLogs(file string) (CTLogs, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ct log list: %w", err)
+	}
+
+	var list struct {
+		Operators []struct {
+			Logs      []ctLogEntry `json:"logs"`
+			TiledLogs []ctLogEntry `json:"tiled_logs"`
+		} `json:"operators"`
+	}
+
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse ct log list: %w", err)
+	}
+
+	logs := make(CTLogs)
+
+	for _, operator := range list.Operators {
+		for _, log := range append(operator.Logs, operator.TiledLogs...) {
+			der, err := base64.StdEncoding.DecodeString(log.Key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ct log key: %w", err)
+			}
+
+			pub, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ct log key: %w", err)
+			}
+
+			logs[sha256.Sum256(der)] = pub
+		}
+	}
+
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no ct logs found in %s", file)
+	}
+
+	return logs, nil
+}
+
+// sct is a signed certificate timestamp (RFC 6962, section 3.2).
+type sct struct {
+	logID      [sctLogIDSize]byte
+	timestamp  uint64
+	extensions []byte
+	hash       byte
+	signature  byte
+	sig        []byte
+}
+
+// parseSCT parses a serialized v1 SCT.
+func parseSCT(b []byte) (*sct, error) {
+	if len(b) < sctFixedHeaderSize+2 || b[0] != sctVersionV1 {
+		return nil, errors.New("unsupported sct")
+	}
+
+	s := &sct{timestamp: binary.BigEndian.Uint64(b[1+sctLogIDSize:])}
+	copy(s.logID[:], b[1:])
+
+	b = b[sctFixedHeaderSize:]
+
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n+sctSignatureFixedSize {
+		return nil, errors.New("truncated sct")
+	}
+
+	s.extensions, b = b[2:2+n], b[2+n:]
+	s.hash, s.signature = b[0], b[1]
+
+	n = int(binary.BigEndian.Uint16(b[2:]))
+	if len(b) != sctSignatureFixedSize+n {
+		return nil, errors.New("invalid sct signature length")
+	}
+
+	s.sig = b[sctSignatureFixedSize:]
+
+	return s, nil
+}
+
+// parseSCTList splits a serialized SignedCertificateTimestampList into its SCTs.
+func parseSCTList(b []byte) ([][]byte, error) {
+	if len(b) < 2 || int(binary.BigEndian.Uint16(b)) != len(b)-2 {
+		return nil, errors.New("invalid sct list")
+	}
+
+	var list [][]byte
+
+	for b = b[2:]; len(b) > 0; {
+		if len(b) < 2 {
+			return nil, errors.New("truncated sct list")
+		}
+
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b) < 2+n {
+			return nil, errors.New("truncated sct list")
+		}
+
+		list, b = append(list, b[2:2+n]), b[2+n:]
+	}
+
+	return list, nil
+}
+
+// appendUint24 appends the length prefixed data with a 24-bit length.
+func appendUint24(b, data []byte) []byte {
+	return append(append(b, byte(len(data)>>16), byte(len(data)>>8), byte(len(data))), data...)
+}
+
+// signedData returns the data signed by the log of an SCT of a log entry.
+func (s *sct) signedData(entryType uint16, entry []byte) []byte {
+	b := []byte{sctVersionV1, sctTypeCertTimestamp}
+	b = binary.BigEndian.AppendUint64(b, s.timestamp)
+	b = binary.BigEndian.AppendUint16(b, entryType)
+	b = append(b, entry...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s.extensions)))
+
+	return append(b, s.extensions...)
+}
+
+// verify checks that an SCT was issued by a known log for a log entry before now.
+func (logs CTLogs) verify(s *sct, entryType uint16, entry []byte, now time.Time) error {
+	pub, ok := logs[s.logID]
+	if !ok {
+		return errors.New("sct of unknown log")
+	}
+
+	if time.UnixMilli(int64(s.timestamp)).After(now) {
+		return errors.New("sct timestamp in the future")
+	}
+
+	if s.hash != sctHashSHA256 {
+		return fmt.Errorf("unsupported sct hash algorithm %d", s.hash)
+	}
+
+	digest := sha256.Sum256(s.signedData(entryType, entry))
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if s.signature != sctSignatureECDSA || !ecdsa.VerifyASN1(pub, digest[:], s.sig) {
+			return errors.New("invalid sct signature")
+		}
+	case *rsa.PublicKey:
+		if s.signature != sctSignatureRSA || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], s.sig) != nil {
+			return errors.New("invalid sct signature")
+		}
+	default:
+		return fmt.Errorf("unsupported ct log key %T", pub)
+	}
+
+	return nil
+}
+
+// precertTBS returns the TBSCertificate of a certificate without the embedded SCTs,
+// which is the TBSCertificate of the precertificate signed by the logs.
+func precertTBS(cert *x509.Certificate) ([]byte, error) {
+	var tbs asn1.RawValue
+	if _, err := asn1.Unmarshal(cert.RawTBSCertificate, &tbs); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+
+	for rest := tbs.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return nil, err
+		}
+
+		if field.Class == asn1.ClassContextSpecific && field.Tag == 3 {
+			var extensions []pkix.Extension
+			if _, err := asn1.Unmarshal(field.Bytes, &extensions); err != nil {
+				return nil, err
+			}
+
+			kept := extensions[:0]
+			for _, ext := range extensions {
+				if !ext.Id.Equal(oidSCTList) {
+					kept = append(kept, ext)
+				}
+			}
+
+			der, err := asn1.Marshal(kept)
+			if err != nil {
+				return nil, err
+			}
+
+			if field.FullBytes, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: der}); err != nil {
+				return nil, err
+			}
+		}
+
+		body = append(body, field.FullBytes...)
+	}
+
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: body})
+}
+
+// validSCTs returns the number of distinct known logs with a valid SCT of the certificate of a
+// domain, delivered in the TLS handshake or embedded in the certificate. Embedded SCTs are
+// only verified if the issuer of the certificate is known (see issuerOf).
+func (logs CTLogs) validSCTs(state tls.ConnectionState, now time.Time) int {
+	cert := state.PeerCertificates[0]
+	valid := make(map[[sctLogIDSize]byte]bool)
+
+	check := func(raw []byte, entryType uint16, entry []byte) {
+		s, err := parseSCT(raw)
+		if err == nil {
+			err = logs.verify(s, entryType, entry, now)
+		}
+
+		if err != nil {
+			slog.Debug("invalid sct", "cn", cert.Subject.CommonName, "err", err)
+			return
+		}
+
+		valid[s.logID] = true
+	}
+
+	for _, raw := range state.SignedCertificateTimestamps {
+		check(raw, sctEntryX509, appendUint24(nil, cert.Raw))
+	}
+
+	issuer := issuerOf(state)
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSCTList) || issuer == nil {
+			continue
+		}
+
+		var raw []byte
+		if _, err := asn1.Unmarshal(ext.Value, &raw); err != nil {
+			slog.Debug("invalid sct list", "cn", cert.Subject.CommonName, "err", err)
+			continue
+		}
+
+		list, err := parseSCTList(raw)
+		if err != nil {
+			slog.Debug("invalid sct list", "cn", cert.Subject.CommonName, "err", err)
+			continue
+		}
+
+		tbs, err := precertTBS(cert)
+		if err != nil {
+			slog.Debug("failed to parse certificate", "cn", cert.Subject.CommonName, "err", err)
+			continue
+		}
+
+		keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+		entry := appendUint24(keyHash[:], tbs)
+
+		for _, s := range list {
+			check(s, sctEntryPrecert, entry)
+		}
+	}
+
+	return len(valid)
+}
+
+// checkSCTs records the number of known CT logs with a valid SCT of the certificate of a
+// domain (see WithCTLogs) in SCTs of res and the ssl_pinning_ct_scts metric. Certificates with
+// fewer SCTs than set with WithMinSCTs are reported in LastError of res.
+func (k *Keys) checkSCTs(fqdn string, state tls.ConnectionState, res *types.DomainKey) {
+	res.SCTs = k.ctLogs.validSCTs(state, time.Now())
+
+	k.collector.SetSCTs(fqdn, float64(res.SCTs))
+
+	if res.SCTs < k.minSCTs {
+		addLastError(res, ErrorVerifyFailed, fmt.Sprintf("certificate has %d valid SCTs of known CT logs, expected at least %d", res.SCTs, k.minSCTs))
+	}
+}
@@ -0,0 +1,429 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+// testLog is a CT log issuing SCTs in tests.
+type testLog struct {
+	key *ecdsa.PrivateKey
+	der []byte
+}
+
+func newTestLog(t *testing.T) testLog {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+
+	return testLog{key: priv, der: der}
+}
+
+// sct returns a serialized SCT of the log for a log entry issued at a time.
+func (l testLog) sct(t *testing.T, entryType uint16, entry []byte, at time.Time) []byte {
+	t.Helper()
+
+	s := &sct{logID: sha256.Sum256(l.der), timestamp: uint64(at.UnixMilli())}
+	digest := sha256.Sum256(s.signedData(entryType, entry))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	require.NoError(t, err)
+
+	b := append([]byte{sctVersionV1}, s.logID[:]...)
+	b = binary.BigEndian.AppendUint64(b, s.timestamp)
+	b = binary.BigEndian.AppendUint16(b, 0)
+	b = append(b, sctHashSHA256, sctSignatureECDSA)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(sig)))
+
+	return append(b, sig...)
+}
+
+// newCTLogs returns the CT logs of test logs.
+func newCTLogs(logs ...testLog) CTLogs {
+	out := make(CTLogs)
+	for _, l := range logs {
+		out[sha256.Sum256(l.der)] = &l.key.PublicKey
+	}
+
+	return out
+}
+
+// newSCTLeaf returns a leaf certificate issued by the intermediate CA of c with SCTs of the logs
+// embedded, signed over the certificate without them like the precertificate of a real CA.
+func newSCTLeaf(t *testing.T, c testChain, logs ...testLog) *x509.Certificate {
+	t.Helper()
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	pub := c.leafKey.Public()
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, c.intermediate, pub, c.intermediateKey)
+	require.NoError(t, err)
+
+	precert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyHash := sha256.Sum256(c.intermediate.RawSubjectPublicKeyInfo)
+	entry := appendUint24(keyHash[:], precert.RawTBSCertificate)
+
+	var list []byte
+	for _, l := range logs {
+		s := l.sct(t, sctEntryPrecert, entry, time.Now())
+		list = binary.BigEndian.AppendUint16(list, uint16(len(s)))
+		list = append(list, s...)
+	}
+
+	value, err := asn1.Marshal(append(binary.BigEndian.AppendUint16(nil, uint16(len(list))), list...))
+	require.NoError(t, err)
+
+	tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+
+	der, err = x509.CreateCertificate(rand.Reader, tmpl, c.intermediate, pub, c.intermediateKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func TestLoadCTLogs(t *testing.T) {
+	log1, log2 := newTestLog(t), newTestLog(t)
+
+	entry := func(l testLog) map[string]string {
+		return map[string]string{"key": base64.StdEncoding.EncodeToString(l.der)}
+	}
+
+	write := func(t *testing.T, v any) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+
+		file := filepath.Join(t.TempDir(), "log_list.json")
+		require.NoError(t, os.WriteFile(file, data, 0o600))
+
+		return file
+	}
+
+	tests := []struct {
+		name    string
+		list    any
+		want    CTLogs
+		wantErr string
+	}{
+		{
+			name: "logs and tiled logs",
+			list: map[string]any{"operators": []any{
+				map[string]any{"logs": []any{entry(log1)}, "tiled_logs": []any{entry(log2)}},
+			}},
+			want: newCTLogs(log1, log2),
+		},
+		{
+			name:    "invalid key",
+			list:    map[string]any{"operators": []any{map[string]any{"logs": []any{map[string]string{"key": "bm90IGEga2V5"}}}}},
+			wantErr: "invalid ct log key",
+		},
+		{
+			name:    "no logs",
+			list:    map[string]any{"operators": []any{}},
+			wantErr: "no ct logs found",
+		},
+		{
+			name:    "invalid json",
+			list:    "operators",
+			wantErr: "failed to parse ct log list",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := LoadCTLogs(write(t, tt.list))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, logs)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadCTLogs(filepath.Join(t.TempDir(), "missing.json"))
+		assert.ErrorContains(t, err, "failed to read ct log list")
+	})
+}
+
+func TestParseSCT(t *testing.T) {
+	l := newTestLog(t)
+	raw := l.sct(t, sctEntryX509, []byte("entry"), time.Now())
+
+	tests := []struct {
+		name    string
+		raw     []byte
+		wantErr bool
+	}{
+		{name: "valid", raw: raw},
+		{name: "empty", raw: nil, wantErr: true},
+		{name: "unsupported version", raw: append([]byte{1}, raw[1:]...), wantErr: true},
+		{name: "truncated", raw: raw[:len(raw)-1], wantErr: true},
+		{name: "trailing data", raw: append(append([]byte{}, raw...), 0), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseSCT(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, sha256.Sum256(l.der), s.logID)
+			assert.Equal(t, byte(sctHashSHA256), s.hash)
+		})
+	}
+}
+
+func TestParseSCTList(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     []byte
+		want    [][]byte
+		wantErr bool
+	}{
+		{name: "two scts", raw: []byte{0, 7, 0, 2, 1, 2, 0, 1, 3}, want: [][]byte{{1, 2}, {3}}},
+		{name: "empty", raw: []byte{0, 0}},
+		{name: "invalid length", raw: []byte{0, 4, 0, 1, 1}, wantErr: true},
+		{name: "truncated sct", raw: []byte{0, 3, 0, 5, 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSCTList(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCTLogs_validSCTs(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	c := newTestChain(t)
+	log1, log2, unknown := newTestLog(t), newTestLog(t), newTestLog(t)
+
+	x509Entry := func(cert *x509.Certificate) []byte {
+		return appendUint24(nil, cert.Raw)
+	}
+
+	embedded := newSCTLeaf(t, c, log1, unknown)
+
+	tests := []struct {
+		name  string
+		state tls.ConnectionState
+		want  int
+	}{
+		{
+			name:  "no scts",
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{c.leaf, c.intermediate}},
+		},
+		{
+			name: "tls extension",
+			state: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{c.leaf},
+				SignedCertificateTimestamps: [][]byte{
+					log1.sct(t, sctEntryX509, x509Entry(c.leaf), time.Now()),
+					log2.sct(t, sctEntryX509, x509Entry(c.leaf), time.Now()),
+				},
+			},
+			want: 2,
+		},
+		{
+			name: "same log twice",
+			state: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{c.leaf},
+				SignedCertificateTimestamps: [][]byte{
+					log1.sct(t, sctEntryX509, x509Entry(c.leaf), time.Now()),
+					log1.sct(t, sctEntryX509, x509Entry(c.leaf), time.Now().Add(-time.Minute)),
+				},
+			},
+			want: 1,
+		},
+		{
+			name: "unknown log",
+			state: tls.ConnectionState{
+				PeerCertificates:            []*x509.Certificate{c.leaf},
+				SignedCertificateTimestamps: [][]byte{unknown.sct(t, sctEntryX509, x509Entry(c.leaf), time.Now())},
+			},
+		},
+		{
+			name: "sct of another certificate",
+			state: tls.ConnectionState{
+				PeerCertificates:            []*x509.Certificate{c.leaf},
+				SignedCertificateTimestamps: [][]byte{log1.sct(t, sctEntryX509, x509Entry(c.intermediate), time.Now())},
+			},
+		},
+		{
+			name: "timestamp in the future",
+			state: tls.ConnectionState{
+				PeerCertificates:            []*x509.Certificate{c.leaf},
+				SignedCertificateTimestamps: [][]byte{log1.sct(t, sctEntryX509, x509Entry(c.leaf), time.Now().Add(time.Hour))},
+			},
+		},
+		{
+			name:  "embedded",
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{embedded, c.intermediate}},
+			want:  1,
+		},
+		{
+			name: "embedded and tls extension",
+			state: tls.ConnectionState{
+				PeerCertificates:            []*x509.Certificate{embedded, c.intermediate},
+				SignedCertificateTimestamps: [][]byte{log2.sct(t, sctEntryX509, x509Entry(embedded), time.Now())},
+			},
+			want: 2,
+		},
+		{
+			name:  "embedded without issuer",
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{embedded}},
+		},
+		{
+			name:  "embedded with wrong issuer",
+			state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{embedded, c.root}},
+		},
+	}
+
+	logs := newCTLogs(log1, log2)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, logs.validSCTs(tt.state, time.Now()))
+		})
+	}
+}
+
+func TestKeys_FetchDomainKey_CT(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	c := newTestChain(t)
+	l := newTestLog(t)
+
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate:                 [][]byte{c.leaf.Raw, c.intermediate.Raw},
+			PrivateKey:                  c.leafKey,
+			SignedCertificateTimestamps: [][]byte{l.sct(t, sctEntryX509, appendUint24(nil, c.leaf.Raw), time.Now())},
+		}},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(c.root)
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		wantSCTs      int
+		wantLastError string
+	}{
+		{name: "disabled"},
+		{name: "enough scts", opts: []Option{WithCTLogs(newCTLogs(l)), WithMinSCTs(1)}, wantSCTs: 1},
+		{
+			name:          "too few scts",
+			opts:          []Option{WithCTLogs(newCTLogs(l)), WithMinSCTs(2)},
+			wantSCTs:      1,
+			wantLastError: "certificate has 1 valid SCTs of known CT logs, expected at least 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			opts := append([]Option{WithCollector(metrics.NewCollector()), WithRootCAs(roots), WithTimeout(2 * time.Second)}, tt.opts...)
+			k := NewKeys(ctx, []types.DomainKey{}, opts...)
+
+			res, err := k.fetchDomainKey(&types.DomainKey{Fqdn: "example.com", Connect: srv.Listener.Addr().String()})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantSCTs, res.SCTs)
+			assert.Equal(t, tt.wantLastError, res.LastError)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// Categories of fetch errors, the values of ErrorCategory of domain keys and of the category
+// label of the ssl_pinning_domain_error metric.
+const (
+	ErrorConnect      = "connect"
+	ErrorDNS          = "dns"
+	ErrorExpired      = "expired"
+	ErrorHandshake    = "handshake"
+	ErrorOther        = "other"
+	ErrorRevoked      = "revoked"
+	ErrorTimeout      = "timeout"
+	ErrorVerifyFailed = "verify-failed"
+)
+
+// handshakeError is an error of the TLS handshake with a domain.
+type handshakeError struct {
+	err error
+}
+
+func (e *handshakeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *handshakeError) Unwrap() error {
+	return e.err
+}
+
+// errorCategory returns the category of a fetch error: a revoked, expired or otherwise
+// unverifiable certificate, a failed DNS lookup, a timeout, a failed TLS handshake or a
+// failed connection. Other errors, e.g. of proxies or client certificates, are ErrorOther.
+func errorCategory(err error) string {
+	var (
+		revoked   *RevokedError
+		invalid   x509.CertificateInvalidError
+		unknown   x509.UnknownAuthorityError
+		hostname  x509.HostnameError
+		verify    *tls.CertificateVerificationError
+		dns       *net.DNSError
+		netErr    net.Error
+		handshake *handshakeError
+		opErr     *net.OpError
+	)
+
+	switch {
+	case errors.As(err, &revoked):
+		return ErrorRevoked
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return ErrorExpired
+	case errors.As(err, &verify), errors.As(err, &invalid), errors.As(err, &unknown), errors.As(err, &hostname):
+		return ErrorVerifyFailed
+	case errors.As(err, &dns):
+		return ErrorDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &handshake):
+		return ErrorHandshake
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return ErrorConnect
+	default:
+		return ErrorOther
+	}
+}
+
+// addLastError adds a message to LastError of a domain key, after the previous one if any.
+// The category of the first message is kept in ErrorCategory.
+func addLastError(key *types.DomainKey, category, msg string) {
+	if key.LastError != "" {
+		msg = key.LastError + "; " + msg
+	}
+
+	if key.ErrorCategory == "" {
+		key.ErrorCategory = category
+	}
+
+	key.LastError = msg
+}
@@ -0,0 +1,212 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestErrorCategory(t *testing.T) {
+	verification := func(err error) error {
+		return &handshakeError{err: &tls.CertificateVerificationError{Err: err}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "revoked", err: &RevokedError{Pin: "leaf"}, want: ErrorRevoked},
+		{name: "expired", err: verification(x509.CertificateInvalidError{Reason: x509.Expired}), want: ErrorExpired},
+		{name: "not authorized to sign", err: verification(x509.CertificateInvalidError{Reason: x509.NotAuthorizedToSign}), want: ErrorVerifyFailed},
+		{name: "unknown authority", err: verification(x509.UnknownAuthorityError{}), want: ErrorVerifyFailed},
+		{name: "host name mismatch", err: verification(x509.HostnameError{Host: "example.com"}), want: ErrorVerifyFailed},
+		{name: "dns", err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example.com"}}, want: ErrorDNS},
+		{name: "dial timeout", err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, want: ErrorTimeout},
+		{name: "context deadline", err: fmt.Errorf("proxy: %w", context.DeadlineExceeded), want: ErrorTimeout},
+		{name: "handshake timeout", err: &handshakeError{err: context.DeadlineExceeded}, want: ErrorTimeout},
+		{name: "handshake", err: &handshakeError{err: io.EOF}, want: ErrorHandshake},
+		{name: "handshake alert", err: &handshakeError{err: tls.AlertError(40)}, want: ErrorHandshake},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: ErrorConnect},
+		{name: "other", err: errors.New("proxy responded 403 Forbidden"), want: ErrorOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorCategory(tt.err))
+		})
+	}
+}
+
+func TestAddLastError(t *testing.T) {
+	key := &types.DomainKey{}
+
+	addLastError(key, ErrorRevoked, "certificate revoked")
+	assert.Equal(t, "certificate revoked", key.LastError)
+	assert.Equal(t, ErrorRevoked, key.ErrorCategory)
+
+	addLastError(key, ErrorVerifyFailed, "too few SCTs")
+	assert.Equal(t, "certificate revoked; too few SCTs", key.LastError)
+	assert.Equal(t, ErrorRevoked, key.ErrorCategory)
+}
+
+func TestKeys_FetchDomainKey_ErrorCategory(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	untrusted := httptest.NewTLSServer(http.NotFoundHandler())
+	defer untrusted.Close()
+
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		connect string
+		want    string
+	}{
+		{name: "untrusted certificate", connect: untrusted.Listener.Addr().String(), want: ErrorVerifyFailed},
+		{name: "not a tls server", connect: plain.Listener.Addr().String(), want: ErrorHandshake},
+		{name: "connection refused", connect: closed.Addr().String(), want: ErrorConnect},
+	}
+
+	k := NewKeys(ctx, []types.DomainKey{}, WithCollector(metrics.NewCollector()), WithProxy(ProxyDirect), WithTimeout(2*time.Second))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := k.fetchDomainKey(&types.DomainKey{Fqdn: "example.com", Connect: tt.connect})
+			assert.Error(t, err)
+			assert.Equal(t, tt.want, errorCategory(err))
+		})
+	}
+}
+
+func TestKeys_Update_ErrorCategory(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{}, WithCollector(metrics.NewCollector()))
+
+	var fail atomic.Bool
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		if fail.Load() {
+			return nil, &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: key.Fqdn}}
+		}
+
+		return &types.DomainKey{Expire: 3600, Key: "leaf"}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.mu.Lock()
+	k.scheduled[key.Fqdn] = 1
+	k.mu.Unlock()
+	k.Set(key.Fqdn, key)
+
+	fail.Store(true)
+	k.update(&key)
+	val, _ := k.Get(key.Fqdn)
+	assert.Equal(t, ErrorDNS, val.ErrorCategory)
+	assert.Contains(t, val.LastError, "no such host")
+
+	fail.Store(false)
+	k.update(&key)
+	val, _ = k.Get(key.Fqdn)
+	assert.Empty(t, val.ErrorCategory)
+	assert.Empty(t, val.LastError)
+}
+
+func TestKeys_Update_FetchDuration(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	collector := metrics.NewCollector()
+	k := NewKeys(ctx, []types.DomainKey{}, WithCollector(collector))
+
+	var fail atomic.Bool
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		if fail.Load() {
+			return nil, &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+		}
+
+		return &types.DomainKey{Expire: 3600, Key: "leaf"}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.mu.Lock()
+	k.scheduled[key.Fqdn] = 1
+	k.mu.Unlock()
+	k.Set(key.Fqdn, key)
+
+	const name = "ssl_pinning_fetch_duration_seconds"
+
+	k.update(&key)
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, name))
+
+	fail.Store(true)
+	k.update(&key)
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, name))
+
+	k.RemoveKey(key.Fqdn)
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, name))
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// day is the unit of expiry thresholds.
+const day = 24 * time.Hour
+
+// ExpiryEvent notifies that the certificate of a domain crossed an expiry threshold
+// (see WithExpiryThresholds).
+type ExpiryEvent struct {
+	Event     string    `json:"event"`
+	Fqdn      string    `json:"fqdn"`
+	File      string    `json:"file"`
+	Key       string    `json:"key"`
+	NotAfter  time.Time `json:"not_after"`
+	DaysLeft  int       `json:"days_left"`
+	Threshold int       `json:"threshold_days"`
+}
+
+// WithExpiryThresholds sets the numbers of days before the expiry of the certificates of
+// domains at which they are reported, e.g. 30, 14 and 7 (see checkExpiry).
+// Non-positive thresholds are ignored.
+func WithExpiryThresholds(days []int) Option {
+	return func(k *Keys) {
+		k.expiryThresholds = nil
+
+		for _, d := range days {
+			if d > 0 && !slices.Contains(k.expiryThresholds, d) {
+				k.expiryThresholds = append(k.expiryThresholds, d)
+			}
+		}
+
+		slices.Sort(k.expiryThresholds)
+	}
+}
+
+// WithExpiryFunc sets the function notified when the certificate of a domain crosses an expiry
+// threshold, e.g. a webhook. A failed notification is retried with the next fetch.
+func WithExpiryFunc(f func(ExpiryEvent) error) Option {
+	return func(k *Keys) {
+		k.expiryFunc = f
+	}
+}
+
+// expiryThreshold returns the lowest expiry threshold in days a certificate expiring in
+// expire seconds crossed, or 0 if it crossed none.
+func (k *Keys) expiryThreshold(expire int64) int {
+	for _, d := range k.expiryThresholds {
+		if time.Duration(expire)*time.Second <= time.Duration(d)*day {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// checkExpiry records the expiry threshold crossed by the certificate of a domain in the
+// ssl_pinning_expiry_threshold metric. When the certificate crosses another threshold than
+// at the previous fetch, e.g. 14 days after 30 days, it is logged and the function set with
+// WithExpiryFunc is notified once. A renewed certificate resets the notifications.
+func (k *Keys) checkExpiry(key, res *types.DomainKey) {
+	if len(k.expiryThresholds) == 0 {
+		return
+	}
+
+	threshold := k.expiryThreshold(res.Expire)
+
+	k.collector.SetExpiryThreshold(key.Fqdn, float64(threshold))
+
+	k.mu.Lock()
+	_, scheduled := k.scheduled[key.Fqdn]
+	crossed := scheduled && threshold != 0 && threshold != k.expiryNotified[key.Fqdn]
+	if scheduled && !crossed {
+		k.expiryNotified[key.Fqdn] = threshold
+	}
+	k.mu.Unlock()
+
+	if !crossed {
+		return
+	}
+
+	ev := ExpiryEvent{
+		Event:     EventExpiry,
+		Fqdn:      key.Fqdn,
+		File:      key.File,
+		Key:       res.Key,
+		NotAfter:  time.Now().Add(time.Duration(res.Expire) * time.Second).UTC().Truncate(time.Second),
+		DaysLeft:  int(time.Duration(res.Expire) * time.Second / day),
+		Threshold: threshold,
+	}
+
+	slog.Warn("certificate expires soon", "fqdn", ev.Fqdn, "not_after", ev.NotAfter, "threshold_days", ev.Threshold)
+
+	if k.expiryFunc != nil {
+		if err := k.expiryFunc(ev); err != nil {
+			slog.Error("failed to notify certificate expiry", "fqdn", ev.Fqdn, "err", err)
+			return
+		}
+	}
+
+	k.mu.Lock()
+	if _, ok := k.scheduled[key.Fqdn]; ok {
+		k.expiryNotified[key.Fqdn] = threshold
+	}
+	k.mu.Unlock()
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+func TestWithExpiryThresholds(t *testing.T) {
+	tests := []struct {
+		name string
+		days []int
+		want []int
+	}{
+		{name: "sorted", days: []int{30, 14, 7}, want: []int{7, 14, 30}},
+		{name: "duplicates and non-positive", days: []int{14, 0, 14, -1, 30}, want: []int{14, 30}},
+		{name: "empty", days: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &Keys{}
+			WithExpiryThresholds(tt.days)(k)
+			assert.Equal(t, tt.want, k.expiryThresholds)
+		})
+	}
+}
+
+func TestKeys_expiryThreshold(t *testing.T) {
+	k := &Keys{}
+	WithExpiryThresholds([]int{30, 14, 7})(k)
+
+	tests := []struct {
+		name   string
+		expire int64
+		want   int
+	}{
+		{name: "none crossed", expire: 31 * 86400, want: 0},
+		{name: "at threshold", expire: 30 * 86400, want: 30},
+		{name: "between thresholds", expire: 20 * 86400, want: 30},
+		{name: "second threshold", expire: 10 * 86400, want: 14},
+		{name: "lowest threshold", expire: 3600, want: 7},
+		{name: "expired", expire: 0, want: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, k.expiryThreshold(tt.expire))
+		})
+	}
+}
+
+func TestKeys_Update_Expiry(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		events []ExpiryEvent
+		fail   atomic.Bool
+		expire atomic.Int64
+	)
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithExpiryThresholds([]int{30, 14, 7}),
+		WithExpiryFunc(func(ev ExpiryEvent) error {
+			if fail.Load() {
+				return errors.New("webhook unavailable")
+			}
+
+			events = append(events, ev)
+
+			return nil
+		}),
+	)
+
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		return &types.DomainKey{Expire: expire.Load(), Key: "leaf"}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.mu.Lock()
+	k.scheduled[key.Fqdn] = 1
+	k.mu.Unlock()
+	k.Set(key.Fqdn, key)
+
+	update := func(days int64) {
+		expire.Store(days * 86400)
+		k.update(&key)
+	}
+
+	update(60)
+	assert.Empty(t, events, "no threshold crossed")
+
+	update(29)
+	require.Len(t, events, 1)
+	assert.Equal(t, "example.com", events[0].Fqdn)
+	assert.Equal(t, "example.json", events[0].File)
+	assert.Equal(t, "leaf", events[0].Key)
+	assert.Equal(t, 29, events[0].DaysLeft)
+	assert.Equal(t, 30, events[0].Threshold)
+
+	update(28)
+	assert.Len(t, events, 1, "notified once per threshold")
+
+	fail.Store(true)
+	update(13)
+	assert.Len(t, events, 1, "failed notification")
+
+	fail.Store(false)
+	update(13)
+	require.Len(t, events, 2, "failed notification is retried")
+	assert.Equal(t, 14, events[1].Threshold)
+
+	update(90)
+	assert.Len(t, events, 2, "renewed certificate")
+
+	update(25)
+	require.Len(t, events, 3, "renewal resets notifications")
+	assert.Equal(t, 30, events[2].Threshold)
+
+	k.RemoveKey(key.Fqdn)
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	assert.NotContains(t, k.expiryNotified, key.Fqdn)
+}
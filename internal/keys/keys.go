@@ -33,33 +33,67 @@ package keys
 
 import (
 	"context"
-	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/base64"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
 	"ssl-pinning/internal/metrics"
 	"ssl-pinning/internal/storage/types"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/net/http/httpproxy"
 )
 
+// defaultFetchInterval is the certificate fetch interval of domain keys without an interval
+// when none is set with WithFetchInterval.
+const defaultFetchInterval = time.Minute
+
+// defaultConcurrency is the number of concurrent certificate fetches when none is set with WithConcurrency.
+const defaultConcurrency = 16
+
 // NewKeys creates and initializes a new Keys instance with domain key management.
 // It accepts a context for lifecycle management, a list of domain keys to monitor,
 // and optional configuration via functional options.
-// Automatically starts workers for each domain key to fetch and update their SSL certificates.
+// Automatically starts the scheduler and the worker pool fetching the SSL certificates
+// of the domain keys (see schedule).
 func NewKeys(ctx context.Context, keys []types.DomainKey, opts ...Option) *Keys {
 	k := &Keys{
-		ctx:     ctx,
-		store:   make(map[string]*types.DomainKey),
-		workers: make(map[string]context.CancelFunc),
+		ctx:            ctx,
+		concurrency:    defaultConcurrency,
+		dohClient:      &http.Client{},
+		expiryNotified: make(map[string]int),
+		fetchInterval:  defaultFetchInterval,
+		fetchStates:    make(map[string]fetchState),
+		history:        make(map[string][]string),
+		jobs:           make(chan fetch),
+		ocspClient:     &http.Client{},
+		scheduled:      make(map[string]uint64),
+		store:          make(map[string]*types.DomainKey),
+		wake:           make(chan struct{}, 1),
 	}
 
+	k.envProxy = httpproxy.FromEnvironment().ProxyFunc()
+	k.fetch = k.fetchDomainKey
+
 	for _, opt := range opts {
 		opt(k)
 	}
 
+	go k.schedule()
+
+	for range k.concurrency {
+		go k.work()
+	}
+
 	for _, key := range keys {
 		k.AddKey(key.Fqdn, &key)
 	}
@@ -77,9 +111,14 @@ func WithTimeout(d time.Duration) Option {
 }
 
 // WithCollector sets the Prometheus metrics collector for tracking key operations and errors.
+// The collector reports the domain and worker counts of the keys (see fetchStats).
 func WithCollector(c *metrics.Collector) Option {
 	return func(k *Keys) {
 		k.collector = c
+
+		if c != nil {
+			c.SetFetchStats(k.fetchStats)
+		}
 	}
 }
 
@@ -90,6 +129,127 @@ func WithDumpInterval(d time.Duration) Option {
 	}
 }
 
+// WithConcurrency sets the maximum number of concurrent certificate fetches.
+func WithConcurrency(n int) Option {
+	return func(k *Keys) {
+		if n > 0 {
+			k.concurrency = n
+		}
+	}
+}
+
+// WithRootCAs sets the root certificates the certificate chains of domains are verified against
+// instead of the system roots (see LoadRootCAs).
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(k *Keys) {
+		k.rootCAs = pool
+	}
+}
+
+// WithSkipVerify disables the verification of the certificate chain and host name of domains,
+// pinning whatever certificate they present, e.g. self-signed certificates of internal services.
+func WithSkipVerify(skip bool) Option {
+	return func(k *Keys) {
+		k.skipVerify = skip
+	}
+}
+
+// WithProxy sets the proxy certificates of domain keys without their own proxy are fetched
+// through (see ParseProxy). ProxyDirect disables the proxy of the HTTPS_PROXY environment variable,
+// which is used by default.
+func WithProxy(raw string) Option {
+	return func(k *Keys) {
+		k.proxy = raw
+	}
+}
+
+// WithAllAddresses fetches the certificates of domains from every address their host name
+// resolves to instead of the first reachable one, e.g. all edges of a multi-CDN setup, and
+// publishes the pins of all of them (see fetchAddresses).
+func WithAllAddresses(all bool) Option {
+	return func(k *Keys) {
+		k.allAddresses = all
+	}
+}
+
+// WithResolvers sets the resolvers the host names of domains fetched without a proxy are
+// resolved with instead of the system resolver, tried in order (see ParseResolver), so that
+// certificates are fetched from the addresses real clients resolve. Invalid resolvers are ignored.
+func WithResolvers(servers []string) Option {
+	return func(k *Keys) {
+		for _, raw := range servers {
+			r, err := ParseResolver(raw)
+			if err != nil {
+				slog.Error("ignoring invalid resolver", "resolver", raw, "err", err)
+				continue
+			}
+
+			k.resolvers = append(k.resolvers, r)
+		}
+	}
+}
+
+// WithOCSP enables checks of the OCSP revocation status of the certificates of domains.
+// The status is taken from the response stapled by the domain or requested from the OCSP
+// responder of the certificate; failed checks are logged and do not fail the fetch.
+// The revocation of a certificate is reported in LastError of the domain key.
+func WithOCSP(enabled bool) Option {
+	return func(k *Keys) {
+		k.ocsp = enabled
+	}
+}
+
+// WithOCSPWithhold withholds the pins of revoked certificates from the published keys
+// (see WithOCSP and withholdPin).
+func WithOCSPWithhold(withhold bool) Option {
+	return func(k *Keys) {
+		k.ocspWithhold = withhold
+	}
+}
+
+// WithCTLogs enables checks of the Certificate Transparency SCTs of the certificates of domains
+// against the known CT logs (see LoadCTLogs and checkSCTs), so that certificates missing from
+// the logs are noticed.
+func WithCTLogs(logs CTLogs) Option {
+	return func(k *Keys) {
+		k.ctLogs = logs
+	}
+}
+
+// WithMinSCTs sets the number of known CT logs with a valid SCT a certificate is expected to
+// have; certificates with fewer SCTs are reported in LastError of the domain key (see WithCTLogs).
+func WithMinSCTs(n int) Option {
+	return func(k *Keys) {
+		k.minSCTs = n
+	}
+}
+
+// WithDeleteFunc sets the function deleting the stored keys of a domain from a file, called
+// when all pins of the domain are withheld (see WithOCSPWithhold).
+func WithDeleteFunc(f func(file, fqdn string) error) Option {
+	return func(k *Keys) {
+		k.deleteFunc = f
+	}
+}
+
+// WithPinHistory sets the number of distinct pins of a domain that are published, the current
+// pin and the previous ones, so that clients keep accepting the previous certificate during
+// server-side rotations (see rememberPin). Only the current pin is published by default.
+func WithPinHistory(n int) Option {
+	return func(k *Keys) {
+		k.pinHistory = n
+	}
+}
+
+// WithFetchInterval sets the interval of certificate fetches of domain keys without an interval.
+func WithFetchInterval(d time.Duration) Option {
+	return func(k *Keys) {
+		if d > 0 {
+			k.fetchInterval = d
+		}
+	}
+}
+
 // WithFlushFunc sets the callback function used to persist keys to storage during periodic dumps.
 func WithFlushFunc(f func(map[string]types.DomainKey) error) Option {
 	return func(k *Keys) {
@@ -101,19 +261,57 @@ func WithFlushFunc(f func(map[string]types.DomainKey) error) Option {
 type Option func(*Keys)
 
 // Keys manages a collection of domain keys with concurrent access and automatic certificate updates.
-// It maintains a map of domain keys, runs background workers for each domain to fetch SSL certificates,
-// collects metrics, and periodically persists keys to storage.
+// It maintains a map of domain keys, schedules the SSL certificate fetches of every domain on a
+// bounded pool of workers, collects metrics, and periodically persists keys to storage.
 type Keys struct {
 	ctx context.Context
 	mu  sync.RWMutex
 
-	store   map[string]*types.DomainKey
-	workers map[string]context.CancelFunc
-
-	collector    *metrics.Collector
-	dumpInterval time.Duration
-	flushFunc    func(map[string]types.DomainKey) error
-	timeout      time.Duration
+	store          map[string]*types.DomainKey
+	scheduled      map[string]uint64
+	gen            uint64
+	history        map[string][]string
+	expiryNotified map[string]int
+	fetchStates    map[string]fetchState
+	pinChanges     []PinChange
+
+	qmu      sync.Mutex
+	queue    queue
+	jobs     chan fetch
+	wake     chan struct{}
+	pausedAt time.Time
+	workers  atomic.Int64
+	busy     atomic.Int64
+
+	allAddresses     bool
+	collector        *metrics.Collector
+	concurrency      int
+	ctLogs           CTLogs
+	dumpInterval     time.Duration
+	envProxy         func(*url.URL) (*url.URL, error)
+	fetch            func(key *types.DomainKey) (*types.DomainKey, error)
+	fetchInterval    time.Duration
+	limiter          *limiter
+	deleteFunc       func(file, fqdn string) error
+	dohClient        *http.Client
+	expiryFunc       func(ExpiryEvent) error
+	expiryThresholds []int
+	flushFunc        func(map[string]types.DomainKey) error
+	minSCTs          int
+	ocsp             bool
+	ocspClient       *http.Client
+	ocspWithhold     bool
+	pinChangeFunc    func(PinChange) error
+	pinHistory       int
+	proxy            string
+	resolvers        []*url.URL
+	rootCAs          *x509.CertPool
+	skipVerify       bool
+	timeout          time.Duration
+
+	lastFlush    time.Time
+	lastFlushErr error
+	ready        bool
 }
 
 // Set stores or updates a domain key in the collection with thread-safe write access.
@@ -153,100 +351,453 @@ func (k *Keys) Snapshot() map[string]types.DomainKey {
 	return out
 }
 
-// AddKey adds a domain key to the collection and starts a background worker for it.
-// If a worker for this FQDN already exists, it skips worker creation.
-// The worker continuously fetches and updates the SSL certificate for the domain.
+// LastFlush returns the time of the latest periodic flush and the error it returned.
+// The time is zero if no flush has run yet.
+func (k *Keys) LastFlush() (time.Time, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.lastFlush, k.lastFlushErr
+}
+
+// Ready reports whether a flush completed after every domain was fetched at least once, i.e. the
+// storage holds the keys fetched by this instance. Domains whose fetch or save failed do not hold
+// it back (see types.ErrPartialSave). Once ready, it stays ready. Domains are not waited for while
+// fetches are paused (see Pause).
+func (k *Keys) Ready() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.ready
+}
+
+// AddKey adds a domain key to the collection and schedules an immediate fetch of its SSL
+// certificate, after which it is fetched once per interval (see interval).
+// If the FQDN is already scheduled, only the stored key is updated.
 func (k *Keys) AddKey(fqdn string, key *types.DomainKey) {
 	k.Set(fqdn, *key)
 
-	if _, exists := k.workers[fqdn]; exists {
+	k.mu.Lock()
+	_, exists := k.scheduled[fqdn]
+	if !exists {
+		k.gen++
+		k.scheduled[fqdn] = k.gen
+	}
+	gen := k.gen
+	k.mu.Unlock()
+
+	if exists {
 		return
 	}
 
-	ctx, cancel := context.WithCancel(k.ctx)
-	k.workers[fqdn] = cancel
+	slog.Info("scheduling key", "fqdn", fqdn, "interval", k.interval(key).String())
 
-	go k.worker(ctx, key)
+	k.enqueue(fetch{key: *key, gen: gen, at: time.Now()})
 }
 
-// fetchDomainKey establishes a TLS connection to the domain and extracts its SSL certificate.
-// It computes the SHA-256 hash of the certificate's public key and returns it base64-encoded
-// along with the certificate's expiration time in seconds.
-// Returns an error if connection fails or certificate cannot be processed.
-func (k *Keys) fetchDomainKey(fqdn string) (*types.DomainKey, error) {
-	dialer := &net.Dialer{
-		Timeout: k.timeout,
+// RemoveKey removes a domain key from the collection and stops fetching its SSL certificate.
+// A fetch in progress completes but its result is discarded. Removing a wildcard domain key
+// also removes the hosts expanded from it (see expand). Returns the removed key and
+// false if the FQDN is unknown.
+func (k *Keys) RemoveKey(fqdn string) (types.DomainKey, bool) {
+	k.mu.Lock()
+	ptr, ok := k.store[fqdn]
+
+	var children []string
+
+	if ok && ptr.IsWildcard() {
+		for host, val := range k.store {
+			if val.Wildcard == fqdn {
+				children = append(children, host)
+			}
+		}
+	}
+	delete(k.store, fqdn)
+	delete(k.scheduled, fqdn)
+	delete(k.history, fqdn)
+	delete(k.expiryNotified, fqdn)
+	delete(k.fetchStates, fqdn)
+	k.mu.Unlock()
+
+	if !ok {
+		return types.DomainKey{}, false
+	}
+
+	slog.Info("unscheduling key", "fqdn", fqdn)
+
+	if ptr.Key != "" {
+		k.collector.ClearExpire(ptr.Key, fqdn)
+	}
+
+	k.collector.ClearOCSPStatus(fqdn)
+	k.collector.ClearSCTs(fqdn)
+	k.collector.ClearDomainError(fqdn)
+	k.collector.ClearFetchDurations(fqdn)
+	k.collector.ClearNotAfter(fqdn)
+	k.collector.ClearPinMismatch(fqdn)
+	k.collector.ClearExpiryThreshold(fqdn)
+	k.collector.ClearPinRotations(fqdn)
+
+	for _, host := range children {
+		k.removeExpanded(host)
+	}
+
+	return *ptr, true
+}
+
+// Reconcile updates the collection to the domain keys of a reloaded configuration.
+// Domains missing from keys are removed (see RemoveKey) and new domains are added (see AddKey).
+// Domains whose configuration changed (see sameConfig) are removed and added again.
+// Hosts expanded from a wildcard domain are left to their wildcard (see expand).
+// Returns the removed keys whose file is no longer published by the domain, sorted by FQDN.
+func (k *Keys) Reconcile(keys []types.DomainKey) []types.DomainKey {
+	want := make(map[string]types.DomainKey, len(keys))
+	for _, key := range keys {
+		want[key.Fqdn] = key
 	}
 
-	conn, err := tls.DialWithDialer(dialer, "tcp", fqdn+":443", &tls.Config{
-		ServerName: fqdn,
+	var removed []types.DomainKey
+
+	for fqdn, cur := range k.Snapshot() {
+		key, ok := want[fqdn]
+		if !ok && cur.Wildcard != "" {
+			continue
+		}
+
+		if ok && cur.Wildcard == "" && sameConfig(key, cur) {
+			delete(want, fqdn)
+			continue
+		}
+
+		if old, ok := k.RemoveKey(fqdn); ok && old.File != key.File {
+			removed = append(removed, old)
+		}
+	}
+
+	for _, key := range keys {
+		if _, ok := want[key.Fqdn]; ok {
+			k.AddKey(key.Fqdn, &key)
+		}
+	}
+
+	slices.SortFunc(removed, func(a, b types.DomainKey) int {
+		return strings.Compare(a.Fqdn, b.Fqdn)
 	})
-	if err != nil {
+
+	return removed
+}
+
+// sameConfig reports whether two domain keys have the same configuration.
+func sameConfig(a, b types.DomainKey) bool {
+	return a.File == b.File &&
+		a.DomainName == b.DomainName &&
+		a.Connect == b.Connect &&
+		a.ServerName == b.ServerName &&
+		a.Proxy == b.Proxy &&
+		a.ClientCert == b.ClientCert &&
+		a.ClientKey == b.ClientKey &&
+		slices.Equal(a.Chain, b.Chain) &&
+		slices.Equal(a.BackupPins, b.BackupPins) &&
+		slices.Equal(a.Hosts, b.Hosts) &&
+		a.Interval == b.Interval
+}
+
+// fetchDomainKey establishes a TLS connection to the domain and extracts its SSL certificate.
+// It dials the address of the key (see types.DomainKey.Address), through its proxy if any
+// (see proxyFor), with the server name of the key as SNI (see types.DomainKey.SNI), computes
+// the SHA-256 hash of the certificate's public key and returns it base64-encoded along with
+// the certificate's expiration time in seconds. The certificate chain is verified against the
+// roots set with WithRootCAs or the system roots, unless disabled with WithSkipVerify. The client
+// certificate of the key, if any, is loaded on every fetch so that renewed certificates are used
+// without a restart. When the key selects chain elements, the pins of all of them are returned
+// (see chainPins). The revocation status of the certificate is checked with WithOCSP and its
+// SCTs with WithCTLogs. With WithAllAddresses every address of the domain is fetched
+// (see fetchAddresses). Every fetch first takes a token of the limiter set with WithDialRate;
+// the timeout of the fetch starts once it is taken.
+// Returns an error if connection fails, verification fails or certificate cannot be processed.
+func (k *Keys) fetchDomainKey(key *types.DomainKey) (*types.DomainKey, error) {
+	if err := k.limiter.wait(k.ctx); err != nil {
 		return nil, err
 	}
-	defer conn.Close()
 
-	cert := conn.ConnectionState().PeerCertificates[0]
+	ctx := k.ctx
+	if k.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, k.timeout)
+		defer cancel()
+	}
+
+	cfg := &tls.Config{
+		InsecureSkipVerify: k.skipVerify,
+		RootCAs:            k.rootCAs,
+		ServerName:         key.SNI(),
+	}
+
+	if key.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(key.ClientCert, key.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if k.allAddresses {
+		if addrs := k.addresses(ctx, key); len(addrs) > 1 {
+			return k.fetchAddresses(ctx, key, cfg, addrs)
+		}
+
+		k.collector.SetPinMismatch(key.Fqdn, 0)
+	}
+
+	raw, err := k.dial(ctx, key)
+	if err != nil {
+		return nil, err
+	}
 
-	pubKeyBytes, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	state, err := handshake(ctx, cfg, raw)
 	if err != nil {
-		slog.Error("Failed to marshal public key", "error", err, "fqdn", fqdn)
 		return nil, err
 	}
 
-	hash := sha256.Sum256(pubKeyBytes)
+	return k.keyOf(ctx, key, state)
+}
+
+// handshake performs the TLS handshake with a domain over a connection and closes it.
+func handshake(ctx context.Context, cfg *tls.Config, raw net.Conn) (tls.ConnectionState, error) {
+	conn := tls.Client(raw, cfg)
+	defer conn.Close()
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return tls.ConnectionState{}, &handshakeError{err: err}
+	}
+
+	return conn.ConnectionState(), nil
+}
+
+// keyOf returns the key, pins and metadata of the certificate a domain presented in a TLS handshake.
+func (k *Keys) keyOf(ctx context.Context, key *types.DomainKey, state tls.ConnectionState) (*types.DomainKey, error) {
+	cert := state.PeerCertificates[0]
 
-	return &types.DomainKey{
+	res := &types.DomainKey{
+		Cert:   certificateOf(key.Fqdn, cert, time.Now()),
 		Expire: int64(time.Until(cert.NotAfter).Seconds()),
-		Key:    base64.StdEncoding.EncodeToString(hash[:]),
-	}, nil
+	}
+
+	if len(key.Chain) == 0 {
+		p, err := pin(cert)
+		if err != nil {
+			slog.Error("Failed to marshal public key", "error", err, "fqdn", key.Fqdn)
+			return nil, err
+		}
+
+		res.Key = p
+	} else {
+		chain := state.PeerCertificates
+		if len(state.VerifiedChains) > 0 {
+			chain = state.VerifiedChains[0]
+		}
+
+		pins, err := chainPins(chain, key.Chain)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Key = pins[0]
+		res.Pins = pins
+	}
+
+	if k.ocsp {
+		if err := k.checkRevocation(ctx, key.Fqdn, state, res); err != nil {
+			return nil, err
+		}
+	}
+
+	if k.ctLogs != nil {
+		k.checkSCTs(key.Fqdn, state, res)
+	}
+
+	return res, nil
 }
 
-// worker is a background goroutine that periodically fetches and updates SSL certificate for a domain.
-// It runs every second, fetches the domain's certificate, updates the key with new expiration and hash,
-// tracks errors in metrics, and continues until the context is cancelled.
-func (k *Keys) worker(ctx context.Context, key *types.DomainKey) {
-	slog.Info("starting key worker", "fqdn", key.Fqdn)
+// LoadRootCAs returns the system root certificates extended by the PEM encoded certificates
+// of file, e.g. the roots of an internal PKI.
+// Returns an error if the file cannot be read or contains no certificate.
+func LoadRootCAs(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root CAs: %w", err)
+	}
 
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		slog.Warn("failed to load system root CAs", "error", err)
+		pool = x509.NewCertPool()
+	}
 
-	k.collector.ClearError(key.File)
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			slog.Info("key worker stopping", "fqdn", key.Fqdn)
-			return
-		case <-ticker.C:
-			cur := time.Now()
+	return pool, nil
+}
 
-			val, _ := k.Get(key.Fqdn)
-			val.Date = &cur
+// interval returns the certificate fetch interval of a domain key: its own interval if set,
+// otherwise the interval set with WithFetchInterval.
+func (k *Keys) interval(key *types.DomainKey) time.Duration {
+	if key.Interval > 0 {
+		return key.Interval
+	}
 
-			if res, err := k.fetchDomainKey(key.Fqdn); err == nil {
-				val.Expire = res.Expire
-				val.Key = res.Key
-				val.LastError = ""
+	return k.fetchInterval
+}
 
-				k.collector.SetExpire(res.Key, key.Fqdn, float64(res.Expire))
-			} else {
-				slog.Error("failed to fetch domain key", "fqdn", key.Fqdn, "err", err)
+// update fetches the certificate of a domain and stores its key and pins, followed by the
+// previous pins of the domain (see rememberPin) and its backup pins (see withBackupPins),
+// or the fetch error, and records the outcome (see Status). Changes of the pin (see PinChanges)
+// and expiry thresholds crossed by the certificate are reported (see checkExpiry).
+// The pin of a revoked certificate is withheld (see withholdPin) and the
+// stored keys of the domain are deleted with the function set with WithDeleteFunc when no
+// pin remains.
+// The result is applied to the stored key, so pins staged during the fetch (see StagePin) are kept.
+func (k *Keys) update(key *types.DomainKey) {
+	cur := time.Now()
+
+	var category string
+
+	res, err := k.fetch(key)
+	if err == nil && res.LastError == "" {
+		k.collector.ObserveFetch(key.Fqdn, metrics.FetchSuccess, time.Since(cur))
+	} else {
+		k.collector.ObserveFetch(key.Fqdn, metrics.FetchError, time.Since(cur))
+	}
+
+	if err == nil {
+		k.collector.SetExpire(res.Key, key.Fqdn, float64(res.Expire))
+
+		if res.Cert != nil {
+			k.collector.SetNotAfter(key.Fqdn, res.Cert.NotAfter)
+		}
+
+		if category = res.ErrorCategory; res.LastError != "" {
+			slog.Warn("domain key fetched with error", "fqdn", key.Fqdn, "category", category, "err", res.LastError)
+		}
+	} else {
+		category = errorCategory(err)
+
+		slog.Error("failed to fetch domain key", "fqdn", key.Fqdn, "category", category, "err", err)
+	}
+
+	if category != "" {
+		k.collector.SetDomainError(key.Fqdn, category)
+	} else {
+		k.collector.ClearDomainError(key.Fqdn)
+	}
+
+	var (
+		change    *PinChange
+		revoked   *RevokedError
+		unpublish bool
+	)
+
+	// the key may have been removed while it was fetched
+	k.mu.Lock()
+	if _, ok := k.scheduled[key.Fqdn]; ok {
+		var val types.DomainKey
+		if ptr, ok := k.store[key.Fqdn]; ok {
+			val = *ptr
+		}
+
+		val.Date = &cur
+		val.ErrorCategory = category
+
+		k.recordFetch(key.Fqdn, cur, err)
+
+		if err == nil {
+			if val.Key != "" && val.Key != res.Key {
+				change = &PinChange{
+					Event:  EventPinChange,
+					Date:   cur.UTC(),
+					Fqdn:   key.Fqdn,
+					File:   key.File,
+					OldPin: val.Key,
+					NewPin: res.Key,
+				}
+
+				k.recordPinChange(*change)
+			}
 
-				val.LastError = err.Error()
-				k.collector.IncError(key.File)
+			val.Cert = res.Cert
+			val.Expire = res.Expire
+			val.Key = res.Key
+			val.Pins = withBackupPins(res, append(k.rememberPin(key.Fqdn, res.Key), val.BackupPins...))
+			val.LastError = res.LastError
+			val.SCTs = res.SCTs
+		} else {
+			val.LastError = err.Error()
+
+			if errors.As(err, &revoked) {
+				unpublish = k.withholdPin(&val, revoked.Pin)
 			}
+		}
+
+		k.store[key.Fqdn] = &val
+	}
+	k.mu.Unlock()
 
-			k.Set(key.Fqdn, val)
+	if revoked != nil {
+		k.collector.ClearExpire(revoked.Pin, key.Fqdn)
+	}
 
-			slog.Debug("updated domain key", "fqdn", key.Fqdn)
+	if change != nil {
+		k.notifyPinChange(*change)
+	}
+
+	if err == nil {
+		k.checkExpiry(key, res)
+	}
+
+	if unpublish && k.deleteFunc != nil {
+		if err := k.deleteFunc(key.File, key.Fqdn); err != nil {
+			slog.Error("failed to delete withheld domain key", "fqdn", key.Fqdn, "err", err)
 		}
 	}
+
+	slog.Debug("updated domain key", "fqdn", key.Fqdn)
+}
+
+// StagePin adds a pin to the backup pins of a domain key, e.g. the pin of the next certificate
+// before it is deployed (see ParsePin), and publishes it with the next flush. Staged pins are
+// not persisted to the configuration and are lost on restart or when the domain is reconfigured.
+// Returns the updated key and false if the FQDN is unknown.
+func (k *Keys) StagePin(fqdn, pin string) (types.DomainKey, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	ptr, ok := k.store[fqdn]
+	if !ok || ptr == nil {
+		return types.DomainKey{}, false
+	}
+
+	val := *ptr
+
+	if !slices.Contains(val.BackupPins, pin) {
+		val.BackupPins = append(slices.Clone(val.BackupPins), pin)
+
+		// keys without a live pin are not published yet, the next fetch adds the backup pins
+		if val.Key != "" {
+			val.Pins = withBackupPins(&val, []string{pin})
+		}
+
+		k.store[fqdn] = &val
+	}
+
+	return val, true
 }
 
 // StartPeriodicFlush runs a background loop that periodically persists all domain keys to storage.
 // It creates a snapshot of current keys and calls the configured flush function at intervals
-// specified by dumpInterval. Continues until the context is cancelled.
+// specified by dumpInterval and records the outcome (see LastFlush and Ready) and its metrics. Continues until the context is cancelled.
 func (k *Keys) StartPeriodicFlush() {
 	slog.Info("starting periodic flush", "interval", k.dumpInterval.Seconds())
 
@@ -260,14 +811,47 @@ func (k *Keys) StartPeriodicFlush() {
 			return
 		case <-ticker.C:
 			list := k.Snapshot()
+			_, paused := k.Paused()
+			fetched := paused || allFetched(list)
 
 			slog.Debug("StartPeriodicFlush", "keys_count", len(list), "keys", list)
 
-			if err := k.flushFunc(list); err != nil {
+			// keys that were not saved, e.g. of domains that cannot be fetched, do not fail the flush
+			err := k.flushFunc(list)
+			if errors.Is(err, types.ErrPartialSave) {
+				slog.Warn("some keys were not flushed", "err", err)
+				err = nil
+			}
+
+			if err != nil {
 				slog.Error("failed to flush keys", "err", err)
 			} else {
 				slog.Debug("successfully flushed keys")
 			}
+
+			now := time.Now()
+			k.collector.ObserveFlush(now, err)
+
+			k.mu.Lock()
+			k.lastFlush = now
+			k.lastFlushErr = err
+			if err == nil && fetched && !k.ready {
+				k.ready = true
+
+				slog.Info("all domains fetched and flushed, instance is ready", "keys_count", len(list))
+			}
+			k.mu.Unlock()
+		}
+	}
+}
+
+// allFetched reports whether every domain key of list was fetched at least once.
+func allFetched(list map[string]types.DomainKey) bool {
+	for _, key := range list {
+		if key.Date == nil {
+			return false
 		}
 	}
+
+	return true
 }
@@ -33,12 +33,31 @@ package keys
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	logger "gopkg.in/slog-handler.v1"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -123,6 +142,57 @@ func TestNewKeys(t *testing.T) {
 				assert.Equal(t, 10*time.Second, k.dumpInterval)
 			},
 		},
+		{
+			name: "default fetch interval",
+			keys: []types.DomainKey{},
+			opts: []Option{
+				WithFetchInterval(0),
+			},
+			validate: func(t *testing.T, k *Keys) {
+				assert.Equal(t, defaultFetchInterval, k.fetchInterval)
+			},
+		},
+		{
+			name: "with fetch interval option",
+			keys: []types.DomainKey{},
+			opts: []Option{
+				WithFetchInterval(30 * time.Second),
+			},
+			validate: func(t *testing.T, k *Keys) {
+				assert.Equal(t, 30*time.Second, k.fetchInterval)
+			},
+		},
+		{
+			name: "default concurrency",
+			keys: []types.DomainKey{},
+			opts: []Option{
+				WithConcurrency(0),
+			},
+			validate: func(t *testing.T, k *Keys) {
+				assert.Equal(t, defaultConcurrency, k.concurrency)
+			},
+		},
+		{
+			name: "with concurrency option",
+			keys: []types.DomainKey{},
+			opts: []Option{
+				WithConcurrency(4),
+			},
+			validate: func(t *testing.T, k *Keys) {
+				assert.Equal(t, 4, k.concurrency)
+			},
+		},
+		{
+			name: "with proxy option",
+			keys: []types.DomainKey{},
+			opts: []Option{
+				WithProxy("socks5://proxy.internal:1080"),
+			},
+			validate: func(t *testing.T, k *Keys) {
+				assert.Equal(t, "socks5://proxy.internal:1080", k.proxy)
+				assert.NotNil(t, k.envProxy)
+			},
+		},
 		{
 			name: "with collector option",
 			keys: []types.DomainKey{},
@@ -319,10 +389,121 @@ func TestKeys_AddKey(t *testing.T) {
 	require.True(t, ok2)
 	assert.Equal(t, "key2", val2.Key)
 
-	// Verify workers are created
-	assert.Len(t, k.workers, 2)
-	assert.Contains(t, k.workers, "example.com")
-	assert.Contains(t, k.workers, "test.com")
+	// Verify keys are scheduled
+	assert.Len(t, k.scheduled, 2)
+	assert.Contains(t, k.scheduled, "example.com")
+	assert.Contains(t, k.scheduled, "test.com")
+}
+
+func TestKeys_RemoveKey(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithFetchInterval(10*time.Millisecond),
+	)
+
+	var fetches atomic.Int32
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		fetches.Add(1)
+		return &types.DomainKey{Expire: 3600, Key: "key"}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.AddKey(key.Fqdn, &key)
+
+	require.Eventually(t, func() bool { return fetches.Load() >= 2 }, time.Second, time.Millisecond)
+
+	removed, ok := k.RemoveKey("example.com")
+	require.True(t, ok)
+	assert.Equal(t, "example.json", removed.File)
+	assert.NotContains(t, k.scheduled, "example.com")
+
+	_, ok = k.Get("example.com")
+	assert.False(t, ok)
+
+	// a fetch in progress may still complete
+	time.Sleep(20 * time.Millisecond)
+	n := fetches.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, n, fetches.Load(), "removed key must not be fetched")
+
+	_, ok = k.Get("example.com")
+	assert.False(t, ok, "removed key must not be stored by a late fetch")
+
+	_, ok = k.RemoveKey("example.com")
+	assert.False(t, ok)
+
+	// the key can be added again
+	k.AddKey(key.Fqdn, &key)
+	require.Eventually(t, func() bool { return fetches.Load() > n }, time.Second, time.Millisecond)
+}
+
+func TestKeys_Reconcile(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithFetchInterval(time.Hour),
+	)
+
+	var fetches sync.Map
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		n, _ := fetches.LoadOrStore(key.Fqdn, new(atomic.Int32))
+		n.(*atomic.Int32).Add(1)
+		return &types.DomainKey{Expire: 3600, Key: "key-" + key.Fqdn}, nil
+	}
+	fetched := func(fqdn string) int32 {
+		n, ok := fetches.Load(fqdn)
+		if !ok {
+			return 0
+		}
+		return n.(*atomic.Int32).Load()
+	}
+
+	for _, key := range []types.DomainKey{
+		{Fqdn: "kept.com", File: "kept.json"},
+		{Fqdn: "removed.com", File: "removed.json"},
+		{Fqdn: "moved.com", File: "old.json"},
+		{Fqdn: "slower.com", File: "slower.json"},
+	} {
+		k.AddKey(key.Fqdn, &key)
+	}
+
+	require.Eventually(t, func() bool {
+		return fetched("kept.com") == 1 && fetched("removed.com") == 1 && fetched("moved.com") == 1 && fetched("slower.com") == 1
+	}, time.Second, time.Millisecond)
+
+	removed := k.Reconcile([]types.DomainKey{
+		{Fqdn: "kept.com", File: "kept.json"},
+		{Fqdn: "moved.com", File: "new.json"},
+		{Fqdn: "slower.com", File: "slower.json", Interval: 2 * time.Hour},
+		{Fqdn: "added.com", File: "added.json"},
+	})
+
+	require.Len(t, removed, 2)
+	assert.Equal(t, "moved.com", removed[0].Fqdn)
+	assert.Equal(t, "old.json", removed[0].File)
+	assert.Equal(t, "removed.com", removed[1].Fqdn)
+
+	snapshot := k.Snapshot()
+	assert.Len(t, snapshot, 4)
+	assert.NotContains(t, snapshot, "removed.com")
+	assert.Equal(t, "new.json", snapshot["moved.com"].File)
+	assert.Equal(t, 2*time.Hour, snapshot["slower.com"].Interval)
+
+	// unchanged keys keep their schedule, added and changed keys are fetched immediately
+	require.Eventually(t, func() bool {
+		return fetched("added.com") == 1 && fetched("moved.com") == 2 && fetched("slower.com") == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(1), fetched("kept.com"))
+	assert.Equal(t, int32(1), fetched("removed.com"))
 }
 
 func TestKeys_ConcurrentAccess(t *testing.T) {
@@ -428,6 +609,150 @@ func TestKeys_StartPeriodicFlush(t *testing.T) {
 	assert.GreaterOrEqual(t, count, 2, "expected at least 2 flush operations")
 }
 
+func TestKeys_LastFlush(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	flushErr := errors.New("storage unavailable")
+	flushed := make(chan struct{}, 1)
+
+	k := NewKeys(ctx, nil,
+		WithCollector(metrics.NewCollector()),
+		WithDumpInterval(10*time.Millisecond),
+		WithFlushFunc(func(map[string]types.DomainKey) error {
+			select {
+			case flushed <- struct{}{}:
+			default:
+			}
+			return flushErr
+		}),
+	)
+
+	last, err := k.LastFlush()
+	assert.True(t, last.IsZero())
+	assert.NoError(t, err)
+
+	start := time.Now()
+	go k.StartPeriodicFlush()
+
+	<-flushed
+	require.Eventually(t, func() bool {
+		last, _ := k.LastFlush()
+		return !last.IsZero()
+	}, time.Second, 5*time.Millisecond)
+
+	last, err = k.LastFlush()
+	assert.False(t, last.Before(start))
+	assert.ErrorIs(t, err, flushErr)
+}
+
+func TestKeys_Ready(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var failing, partial atomic.Bool
+	failing.Store(true)
+
+	collector := metrics.NewCollector()
+	k := NewKeys(ctx, nil,
+		WithCollector(collector),
+		WithDumpInterval(5*time.Millisecond),
+		WithFlushFunc(func(map[string]types.DomainKey) error {
+			if failing.Load() {
+				return errors.New("storage unavailable")
+			}
+			if partial.Load() {
+				return fmt.Errorf("%w: empty key", types.ErrPartialSave)
+			}
+			return nil
+		}),
+	)
+
+	now := time.Now()
+	k.Set("a.example.com", types.DomainKey{Fqdn: "a.example.com", Date: &now})
+	k.Set("b.example.com", types.DomainKey{Fqdn: "b.example.com"})
+
+	go k.StartPeriodicFlush()
+
+	// b.example.com was not fetched yet and the flush fails
+	require.Eventually(t, func() bool {
+		last, _ := k.LastFlush()
+		return !last.IsZero()
+	}, time.Second, time.Millisecond)
+	assert.False(t, k.Ready())
+
+	k.Set("b.example.com", types.DomainKey{Fqdn: "b.example.com", Date: &now})
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, k.Ready(), "not ready while the flush fails")
+
+	assert.Zero(t, testutil.CollectAndCount(collector, "ssl_pinning_last_flush_timestamp_seconds"))
+
+	// a domain that cannot be fetched or saved does not hold back readiness
+	partial.Store(true)
+	failing.Store(false)
+	require.Eventually(t, k.Ready, time.Second, time.Millisecond)
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "ssl_pinning_last_flush_timestamp_seconds"))
+
+	// stays ready, e.g. when a domain is added
+	k.Set("c.example.com", types.DomainKey{Fqdn: "c.example.com"})
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, k.Ready())
+}
+
+func TestKeys_Worker_Interval(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name          string
+		fetchInterval time.Duration
+		keyInterval   time.Duration
+		wantRepeated  bool
+	}{
+		{name: "global interval", fetchInterval: 10 * time.Millisecond, wantRepeated: true},
+		{name: "key interval overrides global", fetchInterval: time.Hour, keyInterval: 10 * time.Millisecond, wantRepeated: true},
+		{name: "fetched once when started", fetchInterval: time.Hour},
+		{name: "key interval slower than global", fetchInterval: 10 * time.Millisecond, keyInterval: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k := NewKeys(ctx, []types.DomainKey{},
+				WithCollector(metrics.NewCollector()),
+				WithFetchInterval(tt.fetchInterval),
+			)
+
+			var fetches atomic.Int32
+			k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+				n := fetches.Add(1)
+				return &types.DomainKey{Expire: 3600, Key: fmt.Sprintf("key-%d", n)}, nil
+			}
+
+			key := types.DomainKey{Fqdn: "example.com", File: "example.json", Interval: tt.keyInterval}
+			k.AddKey(key.Fqdn, &key)
+
+			require.Eventually(t, func() bool {
+				val, _ := k.Get("example.com")
+				return val.Key == "key-1"
+			}, time.Second, time.Millisecond, "key must be fetched when the worker starts")
+
+			if tt.wantRepeated {
+				require.Eventually(t, func() bool { return fetches.Load() >= 3 }, time.Second, time.Millisecond)
+				return
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, int32(1), fetches.Load())
+		})
+	}
+}
+
 func TestKeys_FetchDomainKey(t *testing.T) {
 	logger.SetGlobalLogger(logger.Options{Null: true})
 
@@ -458,7 +783,7 @@ func TestKeys_FetchDomainKey(t *testing.T) {
 
 			k := NewKeys(ctx, []types.DomainKey{}, WithTimeout(tt.timeout))
 
-			result, err := k.fetchDomainKey(tt.fqdn)
+			result, err := k.fetchDomainKey(&types.DomainKey{Fqdn: tt.fqdn})
 
 			if tt.wantError {
 				assert.Error(t, err)
@@ -472,3 +797,253 @@ func TestKeys_FetchDomainKey(t *testing.T) {
 		})
 	}
 }
+
+func TestKeys_FetchDomainKey_Address(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	var serverName atomic.Value
+
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName.Store(hello.ServerName)
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		key            types.DomainKey
+		wantServerName string
+	}{
+		{
+			name:           "port of fqdn",
+			key:            types.DomainKey{Fqdn: "pinned.example.com:" + port, Connect: "127.0.0.1"},
+			wantServerName: "pinned.example.com",
+		},
+		{
+			name:           "port of connect address",
+			key:            types.DomainKey{Fqdn: "pinned.example.com", Connect: "127.0.0.1:" + port},
+			wantServerName: "pinned.example.com",
+		},
+		{
+			name:           "server name",
+			key:            types.DomainKey{Fqdn: "pinned.example.com", Connect: "127.0.0.1:" + port, ServerName: "public.example.com"},
+			wantServerName: "public.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			serverName.Store("")
+
+			k := NewKeys(ctx, []types.DomainKey{}, WithTimeout(2*time.Second))
+
+			// the certificate of the test server is not trusted, the handshake reaching
+			// verification proves the address was dialed
+			_, err := k.fetchDomainKey(&tt.key)
+
+			var unknownAuthority x509.UnknownAuthorityError
+			require.ErrorAs(t, err, &unknownAuthority)
+			assert.Equal(t, tt.wantServerName, serverName.Load())
+		})
+	}
+}
+
+func TestLoadRootCAs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	dir := t.TempDir()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+
+	emptyFile := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(emptyFile, []byte("no certificates"), 0o600))
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{name: "bundle", file: caFile},
+		{name: "no certificates", file: emptyFile, wantErr: true},
+		{name: "missing file", file: filepath.Join(dir, "missing.pem"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := LoadRootCAs(tt.file)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, pool)
+				return
+			}
+
+			require.NoError(t, err)
+
+			_, err = srv.Certificate().Verify(x509.VerifyOptions{Roots: pool, DNSName: "example.com"})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestKeys_FetchDomainKey_Verify(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	pubKey, err := x509.MarshalPKIXPublicKey(srv.Certificate().PublicKey)
+	require.NoError(t, err)
+	hash := sha256.Sum256(pubKey)
+
+	tests := []struct {
+		name      string
+		fqdn      string
+		opts      []Option
+		wantError bool
+	}{
+		{name: "untrusted", fqdn: "example.com", wantError: true},
+		{name: "root CAs", fqdn: "example.com", opts: []Option{WithRootCAs(roots)}},
+		{name: "root CAs host name mismatch", fqdn: "example.org", opts: []Option{WithRootCAs(roots)}, wantError: true},
+		{name: "skip verify", fqdn: "example.org", opts: []Option{WithSkipVerify(true)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k := NewKeys(ctx, []types.DomainKey{}, append(tt.opts, WithTimeout(2*time.Second))...)
+
+			res, err := k.fetchDomainKey(&types.DomainKey{Fqdn: tt.fqdn, Connect: srv.Listener.Addr().String()})
+			if tt.wantError {
+				assert.Error(t, err)
+				assert.Nil(t, res)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, base64.StdEncoding.EncodeToString(hash[:]), res.Key)
+			assert.Positive(t, res.Expire)
+		})
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and its key as PEM files.
+func writeClientCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ssl-pinning"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func TestKeys_FetchDomainKey_ClientCert(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	var clientCN atomic.Value
+
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequestClientCert,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				clientCN.Store("")
+				return nil
+			}
+
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+
+			clientCN.Store(cert.Subject.CommonName)
+			return nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	certFile, keyFile := writeClientCert(t, t.TempDir())
+
+	tests := []struct {
+		name       string
+		clientCert string
+		clientKey  string
+		wantCN     string
+		wantError  bool
+	}{
+		{name: "client certificate", clientCert: certFile, clientKey: keyFile, wantCN: "ssl-pinning"},
+		{name: "no client certificate"},
+		{name: "missing key", clientCert: certFile, clientKey: filepath.Join(t.TempDir(), "missing.key"), wantError: true},
+		{name: "mismatched files", clientCert: keyFile, clientKey: certFile, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			clientCN.Store("none")
+
+			k := NewKeys(ctx, []types.DomainKey{}, WithRootCAs(roots), WithTimeout(2*time.Second))
+
+			res, err := k.fetchDomainKey(&types.DomainKey{
+				Fqdn:       "example.com",
+				Connect:    srv.Listener.Addr().String(),
+				ClientCert: tt.clientCert,
+				ClientKey:  tt.clientKey,
+			})
+			if tt.wantError {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "failed to load client certificate")
+				assert.Nil(t, res)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotEmpty(t, res.Key)
+
+			// with TLS 1.3 the server verifies the client certificate after the client completed the handshake
+			require.Eventually(t, func() bool { return clientCN.Load() != "none" }, time.Second, time.Millisecond)
+			assert.Equal(t, tt.wantCN, clientCN.Load())
+		})
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLimiter(t *testing.T) {
+	assert.Nil(t, newLimiter(0, 10))
+	assert.Nil(t, newLimiter(-1, 10))
+
+	l := newLimiter(2, 0)
+	require.NotNil(t, l)
+	assert.Equal(t, float64(1), l.burst)
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	l := newLimiter(10, 2)
+	now := l.last
+
+	// the burst is available at once
+	assert.Zero(t, l.reserve(now))
+	assert.Zero(t, l.reserve(now))
+
+	// further dials wait for the refill in order
+	assert.Equal(t, 100*time.Millisecond, l.reserve(now))
+	assert.Equal(t, 200*time.Millisecond, l.reserve(now))
+
+	// the bucket is refilled up to the burst
+	now = now.Add(time.Hour)
+	assert.Zero(t, l.reserve(now))
+	assert.Zero(t, l.reserve(now))
+	assert.Equal(t, 100*time.Millisecond, l.reserve(now))
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	var nilLimiter *limiter
+	require.NoError(t, nilLimiter.wait(context.Background()))
+
+	l := newLimiter(50, 1)
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, l.wait(context.Background()))
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	l = newLimiter(0.001, 1)
+	require.NoError(t, l.wait(ctx))
+	assert.ErrorIs(t, l.wait(ctx), context.Canceled)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"log/slog"
+	"time"
+)
+
+// WithPaused starts the scheduler paused (see Pause), e.g. when the service is started
+// during a planned maintenance of upstream hosts.
+func WithPaused(paused bool) Option {
+	return func(k *Keys) {
+		if paused {
+			k.pausedAt = time.Now()
+		}
+	}
+}
+
+// Pause stops handing scheduled fetches to the worker pool, e.g. during a planned maintenance
+// of upstream hosts. Fetches in progress complete, the keys fetched last stay published.
+// Returns false if fetching is already paused.
+func (k *Keys) Pause() bool {
+	k.qmu.Lock()
+	defer k.qmu.Unlock()
+
+	if !k.pausedAt.IsZero() {
+		return false
+	}
+
+	k.pausedAt = time.Now()
+
+	slog.Info("fetching paused")
+
+	return true
+}
+
+// Resume resumes fetching after Pause. Fetches that became due while paused are handed to
+// the worker pool at once, after which every domain is fetched at its usual phase again.
+// Returns false if fetching is not paused.
+func (k *Keys) Resume() bool {
+	k.qmu.Lock()
+
+	if k.pausedAt.IsZero() {
+		k.qmu.Unlock()
+		return false
+	}
+
+	slog.Info("fetching resumed", "paused", time.Since(k.pausedAt).String())
+
+	k.pausedAt = time.Time{}
+	k.qmu.Unlock()
+
+	select {
+	case k.wake <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+// Paused returns the time fetching was paused at and whether it is paused.
+func (k *Keys) Paused() (time.Time, bool) {
+	k.qmu.Lock()
+	defer k.qmu.Unlock()
+
+	return k.pausedAt, !k.pausedAt.IsZero()
+}
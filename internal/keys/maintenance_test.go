@@ -0,0 +1,96 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestKeys_Pause(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithFetchInterval(time.Hour),
+		WithPaused(true),
+	)
+
+	var fetches atomic.Int32
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		fetches.Add(1)
+		return &types.DomainKey{Expire: 3600, Key: "key"}, nil
+	}
+
+	since, paused := k.Paused()
+	require.True(t, paused)
+	assert.False(t, since.IsZero())
+	assert.False(t, k.Pause(), "pausing twice must be reported")
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.AddKey(key.Fqdn, &key)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(0), fetches.Load(), "no fetch must run while paused")
+
+	require.True(t, k.Resume())
+	assert.False(t, k.Resume(), "resuming twice must be reported")
+
+	require.Eventually(t, func() bool { return fetches.Load() == 1 }, time.Second, time.Millisecond)
+
+	_, paused = k.Paused()
+	assert.False(t, paused)
+
+	val, ok := k.Get(key.Fqdn)
+	require.True(t, ok)
+	assert.Equal(t, "key", val.Key)
+
+	require.True(t, k.Pause())
+
+	// the key stays published while paused
+	val, ok = k.Get(key.Fqdn)
+	require.True(t, ok)
+	assert.Equal(t, "key", val.Key)
+}
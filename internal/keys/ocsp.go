@@ -0,0 +1,262 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// OCSP certificate statuses (RFC 6960), the values of the ssl_pinning_ocsp_status metric.
+const (
+	OCSPGood = iota
+	OCSPRevoked
+	OCSPUnknown
+)
+
+// maxOCSPResponseSize is the largest OCSP response read from a responder.
+const maxOCSPResponseSize = 1 << 20
+
+// ocspClockSkew is the tolerated clock skew of OCSP responders.
+const ocspClockSkew = 5 * time.Minute
+
+// RevokedError is returned by certificate fetches of domains whose certificate is revoked
+// when the pin is withheld (see WithOCSPWithhold). Pin is the pin of the revoked certificate.
+type RevokedError struct {
+	Pin       string
+	RevokedAt time.Time
+}
+
+func (e *RevokedError) Error() string {
+	return fmt.Sprintf("certificate revoked at %s", e.RevokedAt.UTC().Format(time.RFC3339))
+}
+
+// ocspStatus is the OCSP status of a certificate.
+type ocspStatus struct {
+	Status    int
+	RevokedAt time.Time
+}
+
+// issuerOf returns the issuer of the certificate of a domain from the verified chain, or
+// from the chain presented by the domain if it was not verified. Returns nil if the
+// domain did not present it.
+func issuerOf(state tls.ConnectionState) *x509.Certificate {
+	chain := state.PeerCertificates
+	if len(state.VerifiedChains) > 0 {
+		chain = state.VerifiedChains[0]
+	}
+
+	if len(chain) < 2 {
+		return nil
+	}
+
+	return chain[1]
+}
+
+// ocspStatus returns the OCSP status of the certificate of a domain from the response stapled
+// by the domain or, without one, from the OCSP responder of the certificate.
+// Returns nil if there is neither a stapled response nor a responder.
+func (k *Keys) ocspStatus(ctx context.Context, cert, issuer *x509.Certificate, stapled []byte) (*ocspStatus, error) {
+	der := stapled
+	if len(der) == 0 {
+		if len(cert.OCSPServer) == 0 {
+			return nil, nil
+		}
+
+		var err error
+		if der, err = k.queryOCSP(ctx, cert.OCSPServer[0], cert, issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	return parseOCSPResponse(der, cert, issuer, time.Now())
+}
+
+// queryOCSP requests the status of a certificate issued by issuer from an OCSP responder
+// over HTTP POST, with SHA-1 hashes as required by the lightweight OCSP profile (RFC 5019).
+func (k *Keys) queryOCSP(ctx context.Context, server string, cert, issuer *x509.Certificate) ([]byte, error) {
+	body, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ocsp request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ocsp responder: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	res, err := k.ocspClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ocsp responder: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp responder returned %s", res.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, maxOCSPResponseSize))
+}
+
+// parseOCSPResponse returns the status of cert from a DER encoded OCSP response. The response
+// must be signed by issuer or by a responder certificate issued by it for OCSP signing, and be
+// current at now.
+func parseOCSPResponse(der []byte, cert, issuer *x509.Certificate, now time.Time) (*ocspStatus, error) {
+	// the responder is verified below rather than by ocsp, which neither accepts an issuer that
+	// embeds its own certificate nor requires delegated responders to be authorized for OCSP signing
+	resp, err := ocsp.ParseResponseForCert(der, cert, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ocsp response: %w", err)
+	}
+
+	if resp.Certificate == nil || bytes.Equal(resp.Certificate.Raw, issuer.Raw) {
+		if err := resp.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("invalid ocsp response signature: %w", err)
+		}
+	} else {
+		if err := resp.Certificate.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("ocsp responder certificate not issued by the issuer: %w", err)
+		}
+
+		if !slices.Contains(resp.Certificate.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) {
+			return nil, errors.New("ocsp responder certificate not authorized for ocsp signing")
+		}
+	}
+
+	if now.Add(ocspClockSkew).Before(resp.ThisUpdate) {
+		return nil, fmt.Errorf("ocsp response not valid before %s", resp.ThisUpdate)
+	}
+
+	if !resp.NextUpdate.IsZero() && now.After(resp.NextUpdate.Add(ocspClockSkew)) {
+		return nil, fmt.Errorf("ocsp response expired at %s", resp.NextUpdate)
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return &ocspStatus{Status: OCSPGood}, nil
+	case ocsp.Revoked:
+		return &ocspStatus{Status: OCSPRevoked, RevokedAt: resp.RevokedAt}, nil
+	default:
+		return &ocspStatus{Status: OCSPUnknown}, nil
+	}
+}
+
+// checkRevocation checks the OCSP status of the certificate of a domain (see WithOCSP) and
+// records it in the ssl_pinning_ocsp_status metric. The revocation of the certificate is
+// reported in LastError of res, or returned as a RevokedError with WithOCSPWithhold.
+func (k *Keys) checkRevocation(ctx context.Context, fqdn string, state tls.ConnectionState, res *types.DomainKey) error {
+	cert := state.PeerCertificates[0]
+
+	issuer := issuerOf(state)
+	if issuer == nil {
+		slog.Warn("failed to check ocsp status", "fqdn", fqdn, "err", "issuer certificate not presented")
+		return nil
+	}
+
+	status, err := k.ocspStatus(ctx, cert, issuer, state.OCSPResponse)
+	if err != nil {
+		slog.Warn("failed to check ocsp status", "fqdn", fqdn, "err", err)
+		return nil
+	}
+
+	if status == nil {
+		slog.Debug("no ocsp responder", "fqdn", fqdn)
+		return nil
+	}
+
+	k.collector.SetOCSPStatus(fqdn, float64(status.Status))
+
+	if status.Status != OCSPRevoked {
+		return nil
+	}
+
+	revoked := &RevokedError{RevokedAt: status.RevokedAt}
+
+	if !k.ocspWithhold {
+		addLastError(res, ErrorRevoked, revoked.Error())
+		return nil
+	}
+
+	if revoked.Pin, err = pin(cert); err != nil {
+		return err
+	}
+
+	return revoked
+}
+
+// withholdPin removes the pin of a revoked certificate from the published pins and the pin
+// history of a domain key; Key becomes the first remaining pin, e.g. a backup pin.
+// Reports whether no pin remains, in which case the domain key is no longer published.
+// k.mu must be held.
+func (k *Keys) withholdPin(val *types.DomainKey, revoked string) bool {
+	pins := val.Pins
+	if len(pins) == 0 && val.Key != "" {
+		pins = []string{val.Key}
+	}
+
+	isRevoked := func(p string) bool { return p == revoked }
+
+	pins = slices.DeleteFunc(slices.Clone(pins), isRevoked)
+
+	if history, ok := k.history[val.Fqdn]; ok {
+		k.history[val.Fqdn] = slices.DeleteFunc(history, isRevoked)
+	}
+
+	val.Key = ""
+	val.Pins = nil
+
+	if len(pins) > 0 {
+		val.Key = pins[0]
+	}
+
+	if len(pins) > 1 {
+		val.Pins = pins
+	}
+
+	return val.Key == ""
+}
@@ -0,0 +1,388 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"golang.org/x/crypto/ocsp"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+// ocspFixture describes an OCSP response of the leaf certificate of a testChain.
+type ocspFixture struct {
+	status     int
+	serial     *big.Int
+	nextUpdate time.Time
+	signer     *x509.Certificate
+	signerKey  crypto.Signer
+	embed      bool
+}
+
+// newOCSPResponse returns a DER encoded OCSP response for the leaf of c, signed by the
+// intermediate CA of c unless the fixture sets another signer.
+func newOCSPResponse(t *testing.T, c testChain, f ocspFixture) []byte {
+	t.Helper()
+
+	if f.signer == nil {
+		f.signer, f.signerKey = c.intermediate, c.intermediateKey
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	template := ocsp.Response{
+		Status:       f.status,
+		SerialNumber: c.leaf.SerialNumber,
+		ThisUpdate:   now.Add(-time.Hour),
+		NextUpdate:   f.nextUpdate,
+		RevokedAt:    now.Add(-time.Minute),
+	}
+
+	if f.serial != nil {
+		template.SerialNumber = f.serial
+	}
+
+	if f.embed {
+		template.Certificate = f.signer
+	}
+
+	der, err := ocsp.CreateResponse(c.intermediate, f.signer, template, f.signerKey)
+	require.NoError(t, err)
+
+	return der
+}
+
+// newOCSPResponder returns a certificate of an OCSP responder issued by the intermediate CA of c.
+func newOCSPResponder(t *testing.T, c testChain, usage []x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(10),
+		Subject:      pkix.Name{CommonName: "ocsp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  usage,
+	}, c.intermediate, &priv.PublicKey, c.intermediateKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, priv
+}
+
+func TestParseOCSPResponse(t *testing.T) {
+	c := newTestChain(t)
+
+	responder, responderKey := newOCSPResponder(t, c, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning})
+	unauthorized, unauthorizedKey := newOCSPResponder(t, c, nil)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		der        []byte
+		wantStatus int
+		wantErr    bool
+	}{
+		{name: "good", der: newOCSPResponse(t, c, ocspFixture{status: OCSPGood}), wantStatus: OCSPGood},
+		{name: "revoked", der: newOCSPResponse(t, c, ocspFixture{status: OCSPRevoked}), wantStatus: OCSPRevoked},
+		{name: "unknown", der: newOCSPResponse(t, c, ocspFixture{status: OCSPUnknown}), wantStatus: OCSPUnknown},
+		{name: "current", der: newOCSPResponse(t, c, ocspFixture{status: OCSPGood, nextUpdate: time.Now().Add(time.Hour).UTC()}), wantStatus: OCSPGood},
+		{name: "issuer embedded", der: newOCSPResponse(t, c, ocspFixture{status: OCSPGood, embed: true}), wantStatus: OCSPGood},
+		{
+			name:       "delegated responder",
+			der:        newOCSPResponse(t, c, ocspFixture{status: OCSPRevoked, signer: responder, signerKey: responderKey, embed: true}),
+			wantStatus: OCSPRevoked,
+		},
+		{
+			name:    "responder not authorized",
+			der:     newOCSPResponse(t, c, ocspFixture{status: OCSPGood, signer: unauthorized, signerKey: unauthorizedKey, embed: true}),
+			wantErr: true,
+		},
+		{
+			name:    "invalid signature",
+			der:     newOCSPResponse(t, c, ocspFixture{status: OCSPGood, signer: c.intermediate, signerKey: otherKey}),
+			wantErr: true,
+		},
+		{name: "expired", der: newOCSPResponse(t, c, ocspFixture{status: OCSPGood, nextUpdate: time.Now().Add(-time.Hour).UTC()}), wantErr: true},
+		{name: "other certificate", der: newOCSPResponse(t, c, ocspFixture{status: OCSPGood, serial: big.NewInt(42)}), wantErr: true},
+		{name: "responder error", der: []byte{0x30, 0x03, 0x0a, 0x01, 0x03}, wantErr: true},
+		{name: "invalid response", der: []byte("ocsp"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := parseOCSPResponse(tt.der, c.leaf, c.intermediate, time.Now())
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, status)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, status.Status)
+
+			if tt.wantStatus == OCSPRevoked {
+				assert.False(t, status.RevokedAt.IsZero())
+			}
+		})
+	}
+}
+
+func TestKeys_FetchDomainKey_OCSP(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	var (
+		response atomic.Value
+		requests atomic.Int32
+	)
+
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		body, _ := io.ReadAll(r.Body)
+
+		if _, err := ocsp.ParseRequest(body); err != nil || r.Header.Get("Content-Type") != "application/ocsp-request" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		der, _ := response.Load().([]byte)
+		if der == nil {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(der)
+	}))
+	defer responder.Close()
+
+	c := newTestChain(t, responder.URL)
+
+	newServer := func(staple []byte) *httptest.Server {
+		srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+		srv.TLS = &tls.Config{
+			Certificates: []tls.Certificate{{
+				Certificate: [][]byte{c.leaf.Raw, c.intermediate.Raw},
+				PrivateKey:  c.leafKey,
+				OCSPStaple:  staple,
+			}},
+		}
+		srv.StartTLS()
+		t.Cleanup(srv.Close)
+
+		return srv
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(c.root)
+
+	leafPin, err := pin(c.leaf)
+	require.NoError(t, err)
+
+	good := newOCSPResponse(t, c, ocspFixture{status: OCSPGood})
+	revoked := newOCSPResponse(t, c, ocspFixture{status: OCSPRevoked})
+
+	tests := []struct {
+		name          string
+		response      []byte
+		staple        []byte
+		opts          []Option
+		wantLastError string
+		wantRevoked   bool
+		wantRequests  int32
+	}{
+		{name: "disabled", response: revoked},
+		{name: "good", response: good, opts: []Option{WithOCSP(true)}, wantRequests: 1},
+		{name: "revoked", response: revoked, opts: []Option{WithOCSP(true)}, wantLastError: "certificate revoked", wantRequests: 1},
+		{name: "revoked and withheld", response: revoked, opts: []Option{WithOCSP(true), WithOCSPWithhold(true)}, wantRevoked: true, wantRequests: 1},
+		{name: "stapled", response: good, staple: revoked, opts: []Option{WithOCSP(true)}, wantLastError: "certificate revoked"},
+		{name: "responder unavailable", opts: []Option{WithOCSP(true)}, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			response.Store(tt.response)
+			requests.Store(0)
+
+			srv := newServer(tt.staple)
+
+			opts := append([]Option{WithCollector(metrics.NewCollector()), WithRootCAs(roots), WithTimeout(2 * time.Second)}, tt.opts...)
+			k := NewKeys(ctx, []types.DomainKey{}, opts...)
+
+			res, err := k.fetchDomainKey(&types.DomainKey{Fqdn: "example.com", Connect: srv.Listener.Addr().String()})
+			assert.Equal(t, tt.wantRequests, requests.Load())
+
+			if tt.wantRevoked {
+				var revokedErr *RevokedError
+				require.True(t, errors.As(err, &revokedErr), "got %v", err)
+				assert.Equal(t, leafPin, revokedErr.Pin)
+				assert.Nil(t, res)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, leafPin, res.Key)
+
+			if tt.wantLastError != "" {
+				assert.Contains(t, res.LastError, tt.wantLastError)
+			} else {
+				assert.Empty(t, res.LastError)
+			}
+		})
+	}
+}
+
+func TestKeys_withholdPin(t *testing.T) {
+	tests := []struct {
+		name          string
+		val           types.DomainKey
+		history       []string
+		wantKey       string
+		wantPins      []string
+		wantHistory   []string
+		wantUnpublish bool
+	}{
+		{name: "only pin", val: types.DomainKey{Fqdn: "example.com", Key: "leaf"}, wantUnpublish: true},
+		{name: "not fetched yet", val: types.DomainKey{Fqdn: "example.com"}, wantUnpublish: true},
+		{
+			name:     "backup pins remain",
+			val:      types.DomainKey{Fqdn: "example.com", Key: "leaf", Pins: []string{"leaf", "ca", "next"}},
+			wantKey:  "ca",
+			wantPins: []string{"ca", "next"},
+		},
+		{
+			name:        "single pin remains",
+			val:         types.DomainKey{Fqdn: "example.com", Key: "leaf", Pins: []string{"leaf", "previous"}},
+			history:     []string{"leaf", "previous"},
+			wantKey:     "previous",
+			wantHistory: []string{"previous"},
+		},
+		{
+			name:        "revoked previous pin",
+			val:         types.DomainKey{Fqdn: "example.com", Key: "current", Pins: []string{"current", "leaf"}},
+			history:     []string{"current", "leaf"},
+			wantKey:     "current",
+			wantHistory: []string{"current"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &Keys{history: make(map[string][]string)}
+			if tt.history != nil {
+				k.history["example.com"] = tt.history
+			}
+
+			val := tt.val
+			assert.Equal(t, tt.wantUnpublish, k.withholdPin(&val, "leaf"))
+			assert.Equal(t, tt.wantKey, val.Key)
+			assert.Equal(t, tt.wantPins, val.Pins)
+			assert.Equal(t, tt.wantHistory, k.history["example.com"])
+		})
+	}
+}
+
+func TestKeys_Update_Withhold(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var deleted []string
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithDeleteFunc(func(file, fqdn string) error {
+			deleted = append(deleted, file+"/"+fqdn)
+			return nil
+		}),
+	)
+
+	var revoked atomic.Bool
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		if revoked.Load() {
+			return nil, &RevokedError{Pin: "leaf", RevokedAt: time.Now()}
+		}
+
+		return &types.DomainKey{Expire: 3600, Key: "leaf"}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.mu.Lock()
+	k.scheduled[key.Fqdn] = 1
+	k.mu.Unlock()
+	k.Set(key.Fqdn, key)
+
+	k.update(&key)
+	val, _ := k.Get(key.Fqdn)
+	assert.Equal(t, "leaf", val.Key)
+	assert.Empty(t, deleted)
+
+	revoked.Store(true)
+	k.update(&key)
+	val, _ = k.Get(key.Fqdn)
+	assert.Empty(t, val.Key)
+	assert.Contains(t, val.LastError, "certificate revoked")
+	assert.Equal(t, []string{"example.json/example.com"}, deleted)
+}
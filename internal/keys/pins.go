@@ -0,0 +1,236 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"slices"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+const (
+	// ChainLeaf selects the certificate of the domain
+	ChainLeaf = "leaf"
+	// ChainIntermediate selects the intermediate CA certificates of the chain
+	ChainIntermediate = "intermediate"
+	// ChainRoot selects the root CA certificate of the chain
+	ChainRoot = "root"
+)
+
+// ValidateChain checks the chain elements selected for pinning of a domain key.
+// Returns an error for elements other than ChainLeaf, ChainIntermediate and ChainRoot.
+func ValidateChain(chain []string) error {
+	for _, element := range chain {
+		switch element {
+		case ChainLeaf, ChainIntermediate, ChainRoot:
+		default:
+			return fmt.Errorf("unknown chain element %q, expected %s, %s or %s", element, ChainLeaf, ChainIntermediate, ChainRoot)
+		}
+	}
+
+	return nil
+}
+
+// ValidatePins checks backup pins of a domain key.
+// Returns an error for pins other than base64-encoded SHA-256 hashes.
+func ValidatePins(pins []string) error {
+	for _, p := range pins {
+		hash, err := base64.StdEncoding.DecodeString(p)
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("invalid pin %q, expected a base64-encoded SHA-256 hash", p)
+		}
+	}
+
+	return nil
+}
+
+// withBackupPins returns the pins of a fetched key, its Key if it has no Pins, followed by the
+// backup pins that are not among them. Returns the pins of the fetched key if there are no backup pins.
+func withBackupPins(res *types.DomainKey, backup []string) []string {
+	if len(backup) == 0 {
+		return res.Pins
+	}
+
+	pins := res.Pins
+	if len(pins) == 0 {
+		pins = []string{res.Key}
+	}
+
+	pins = slices.Clone(pins)
+
+	for _, p := range backup {
+		if !slices.Contains(pins, p) {
+			pins = append(pins, p)
+		}
+	}
+
+	return pins
+}
+
+// ParsePin returns the pin of the first PEM encoded certificate, certificate signing request or
+// public key in data, e.g. of the next certificate of a domain before it is deployed.
+// The signature of a certificate signing request is checked.
+// Returns an error if data contains none of them or it cannot be parsed.
+func ParsePin(data []byte) (string, error) {
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			return "", fmt.Errorf("no certificate, certificate request or public key found")
+		}
+
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse certificate: %w", err)
+			}
+
+			return pin(cert)
+		case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse certificate request: %w", err)
+			}
+
+			if err := csr.CheckSignature(); err != nil {
+				return "", fmt.Errorf("invalid certificate request signature: %w", err)
+			}
+
+			return publicKeyPin(csr.PublicKey)
+		case "PUBLIC KEY":
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse public key: %w", err)
+			}
+
+			return publicKeyPin(pub)
+		}
+	}
+}
+
+// pin returns the base64-encoded SHA-256 hash of the public key of a certificate.
+func pin(cert *x509.Certificate) (string, error) {
+	return publicKeyPin(cert.PublicKey)
+}
+
+// publicKeyPin returns the base64-encoded SHA-256 hash of the DER encoded PKIX public key.
+func publicKeyPin(pub any) (string, error) {
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(pubKeyBytes)
+
+	return base64.StdEncoding.EncodeToString(hash[:]), nil
+}
+
+// chainPins returns the pins of the certificates of a chain selected by the elements, in chain
+// order. chain starts with the leaf and ends with the root if the chain was verified; otherwise
+// it is the chain presented by the domain, whose last certificate is the root only if self-signed.
+// Returns an error if a selected element is not part of the chain.
+func chainPins(chain []*x509.Certificate, elements []string) ([]string, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificate presented")
+	}
+
+	var root *x509.Certificate
+
+	intermediates := chain[1:]
+	if last := chain[len(chain)-1]; len(chain) > 1 && bytes.Equal(last.RawIssuer, last.RawSubject) {
+		root = last
+		intermediates = chain[1 : len(chain)-1]
+	}
+
+	var selected []*x509.Certificate
+
+	if slices.Contains(elements, ChainLeaf) {
+		selected = append(selected, chain[0])
+	}
+
+	if slices.Contains(elements, ChainIntermediate) {
+		if len(intermediates) == 0 {
+			return nil, fmt.Errorf("chain has no intermediate certificate")
+		}
+
+		selected = append(selected, intermediates...)
+	}
+
+	if slices.Contains(elements, ChainRoot) {
+		if root == nil {
+			return nil, fmt.Errorf("chain has no root certificate")
+		}
+
+		selected = append(selected, root)
+	}
+
+	pins := make([]string, 0, len(selected))
+
+	for _, cert := range selected {
+		p, err := pin(cert)
+		if err != nil {
+			return nil, err
+		}
+
+		pins = append(pins, p)
+	}
+
+	return pins, nil
+}
+
+// rememberPin records the current pin of a domain in its history of distinct pins, most recent
+// first and limited to WithPinHistory pins, and returns the previous pins that are still published.
+// A pin that becomes current again moves to the front. k.mu must be held.
+func (k *Keys) rememberPin(fqdn, pin string) []string {
+	if k.pinHistory < 2 {
+		return nil
+	}
+
+	history := []string{pin}
+	for _, p := range k.history[fqdn] {
+		if p != pin && len(history) < k.pinHistory {
+			history = append(history, p)
+		}
+	}
+
+	k.history[fqdn] = history
+
+	return slices.Clone(history[1:])
+}
@@ -0,0 +1,503 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+// testChain is a certificate chain of example.com issued by an intermediate CA of a root CA.
+type testChain struct {
+	leaf, intermediate, root *x509.Certificate
+	leafKey, intermediateKey crypto.Signer
+}
+
+// newTestChain creates a root CA, an intermediate CA and a leaf certificate of example.com
+// with the OCSP responders ocspServer.
+func newTestChain(t *testing.T, ocspServer ...string) testChain {
+	t.Helper()
+
+	issue := func(tmpl, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		if parent == nil {
+			parent, parentKey = tmpl, priv
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &priv.PublicKey, parentKey)
+		require.NoError(t, err)
+
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+
+		return cert, priv
+	}
+
+	ca := func(serial int64, cn string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+	}
+
+	root, rootKey := issue(ca(1, "root"), nil, nil)
+	intermediate, intermediateKey := issue(ca(2, "intermediate"), root, rootKey)
+	leaf, leafKey := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPServer:   ocspServer,
+	}, intermediate, intermediateKey)
+
+	return testChain{leaf: leaf, intermediate: intermediate, root: root, leafKey: leafKey, intermediateKey: intermediateKey}
+}
+
+func TestValidateChain(t *testing.T) {
+	tests := []struct {
+		name    string
+		chain   []string
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "all elements", chain: []string{ChainLeaf, ChainIntermediate, ChainRoot}},
+		{name: "unknown element", chain: []string{ChainLeaf, "issuer"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateChain(tt.chain)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidatePins(t *testing.T) {
+	hash := sha256.Sum256([]byte("next"))
+	valid := base64.StdEncoding.EncodeToString(hash[:])
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "valid", pins: []string{valid}},
+		{name: "not base64", pins: []string{valid, "not a pin"}, wantErr: true},
+		{name: "not a sha256 hash", pins: []string{base64.StdEncoding.EncodeToString(hash[:20])}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePins(tt.pins)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWithBackupPins(t *testing.T) {
+	tests := []struct {
+		name   string
+		res    types.DomainKey
+		backup []string
+		want   []string
+	}{
+		{name: "no backup pins", res: types.DomainKey{Key: "leaf"}},
+		{name: "no backup pins with chain", res: types.DomainKey{Key: "leaf", Pins: []string{"leaf", "ca"}}, want: []string{"leaf", "ca"}},
+		{name: "live pin first", res: types.DomainKey{Key: "leaf"}, backup: []string{"next"}, want: []string{"leaf", "next"}},
+		{
+			name:   "chain pins first",
+			res:    types.DomainKey{Key: "leaf", Pins: []string{"leaf", "ca"}},
+			backup: []string{"next", "ca"},
+			want:   []string{"leaf", "ca", "next"},
+		},
+		{name: "backup pin is live", res: types.DomainKey{Key: "next"}, backup: []string{"next"}, want: []string{"next"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.res
+			assert.Equal(t, tt.want, withBackupPins(&res, tt.backup))
+			assert.Equal(t, tt.res.Pins, res.Pins, "pins of the fetched key must not be modified")
+		})
+	}
+}
+
+func TestKeys_Update_BackupPins(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{}, WithCollector(metrics.NewCollector()))
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		return &types.DomainKey{Expire: 3600, Key: "leaf"}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json", BackupPins: []string{"next"}}
+	k.AddKey(key.Fqdn, &key)
+
+	require.Eventually(t, func() bool {
+		val, ok := k.Get("example.com")
+		return ok && val.Key == "leaf"
+	}, time.Second, time.Millisecond)
+
+	val, _ := k.Get("example.com")
+	assert.Equal(t, []string{"leaf", "next"}, val.Pins)
+}
+
+func TestParsePin(t *testing.T) {
+	c := newTestChain(t)
+
+	want, err := pin(c.leaf)
+	require.NoError(t, err)
+
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: c.leaf.Subject}, c.leafKey)
+	require.NoError(t, err)
+
+	pubDER, err := x509.MarshalPKIXPublicKey(c.leafKey.Public())
+	require.NoError(t, err)
+
+	tampered := slices.Clone(csrDER)
+	tampered[len(tampered)-1] ^= 0xff
+
+	encode := func(typ string, der []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "certificate", data: encode("CERTIFICATE", c.leaf.Raw)},
+		{name: "certificate request", data: encode("CERTIFICATE REQUEST", csrDER)},
+		{name: "legacy certificate request", data: encode("NEW CERTIFICATE REQUEST", csrDER)},
+		{name: "public key", data: encode("PUBLIC KEY", pubDER)},
+		{name: "certificate after private key", data: append(encode("EC PRIVATE KEY", []byte("key")), encode("CERTIFICATE", c.leaf.Raw)...)},
+		{name: "first certificate of bundle", data: append(encode("CERTIFICATE", c.leaf.Raw), encode("CERTIFICATE", c.intermediate.Raw)...)},
+		{name: "invalid certificate request signature", data: encode("CERTIFICATE REQUEST", tampered), wantErr: true},
+		{name: "invalid certificate", data: encode("CERTIFICATE", []byte("cert")), wantErr: true},
+		{name: "no pem", data: []byte("not a certificate"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePin(tt.data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestKeys_StagePin(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{}, WithCollector(metrics.NewCollector()))
+	k.Set("example.com", types.DomainKey{Fqdn: "example.com", Key: "leaf", Pins: []string{"leaf", "ca"}, BackupPins: []string{"next"}})
+	k.Set("example.org", types.DomainKey{Fqdn: "example.org"})
+
+	key, ok := k.StagePin("example.com", "upcoming")
+	require.True(t, ok)
+	assert.Equal(t, []string{"next", "upcoming"}, key.BackupPins)
+	assert.Equal(t, []string{"leaf", "ca", "upcoming"}, key.Pins)
+
+	// staging a pin again is a no-op
+	key, ok = k.StagePin("example.com", "upcoming")
+	require.True(t, ok)
+	assert.Equal(t, []string{"next", "upcoming"}, key.BackupPins)
+	assert.Equal(t, []string{"leaf", "ca", "upcoming"}, key.Pins)
+
+	// pins of keys that were not fetched yet are published with the first fetch
+	key, ok = k.StagePin("example.org", "upcoming")
+	require.True(t, ok)
+	assert.Equal(t, []string{"upcoming"}, key.BackupPins)
+	assert.Nil(t, key.Pins)
+
+	_, ok = k.StagePin("example.net", "upcoming")
+	assert.False(t, ok)
+}
+
+func TestKeys_rememberPin(t *testing.T) {
+	tests := []struct {
+		name       string
+		pinHistory int
+		pins       []string
+		want       []string
+		wantStored []string
+	}{
+		{name: "disabled", pinHistory: 1, pins: []string{"a", "b"}, want: nil},
+		{name: "first pin", pinHistory: 2, pins: []string{"a"}, want: []string{}, wantStored: []string{"a"}},
+		{name: "same pin", pinHistory: 2, pins: []string{"a", "a", "a"}, want: []string{}, wantStored: []string{"a"}},
+		{name: "rotation", pinHistory: 2, pins: []string{"a", "b"}, want: []string{"a"}, wantStored: []string{"b", "a"}},
+		{name: "aged out", pinHistory: 2, pins: []string{"a", "b", "c"}, want: []string{"b"}, wantStored: []string{"c", "b"}},
+		{name: "rollback", pinHistory: 3, pins: []string{"a", "b", "a"}, want: []string{"b"}, wantStored: []string{"a", "b"}},
+		{name: "longer history", pinHistory: 3, pins: []string{"a", "b", "c", "d"}, want: []string{"c", "b"}, wantStored: []string{"d", "c", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &Keys{history: make(map[string][]string), pinHistory: tt.pinHistory}
+
+			var got []string
+			for _, p := range tt.pins {
+				got = k.rememberPin("example.com", p)
+			}
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantStored, k.history["example.com"])
+		})
+	}
+}
+
+func TestKeys_Update_PinHistory(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{}, WithCollector(metrics.NewCollector()), WithPinHistory(2))
+
+	var live atomic.Value
+	live.Store("a")
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		return &types.DomainKey{Expire: 3600, Key: live.Load().(string)}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json", BackupPins: []string{"next"}}
+	k.mu.Lock()
+	k.scheduled[key.Fqdn] = 1
+	k.mu.Unlock()
+	k.Set(key.Fqdn, key)
+
+	k.update(&key)
+	val, _ := k.Get(key.Fqdn)
+	assert.Equal(t, "a", val.Key)
+	assert.Equal(t, []string{"a", "next"}, val.Pins)
+
+	live.Store("b")
+	k.update(&key)
+	val, _ = k.Get(key.Fqdn)
+	assert.Equal(t, "b", val.Key)
+	assert.Equal(t, []string{"b", "a", "next"}, val.Pins)
+
+	live.Store("c")
+	k.update(&key)
+	val, _ = k.Get(key.Fqdn)
+	assert.Equal(t, []string{"c", "b", "next"}, val.Pins)
+
+	// the history of removed keys is dropped
+	k.RemoveKey(key.Fqdn)
+	assert.NotContains(t, k.history, key.Fqdn)
+}
+
+func TestChainPins(t *testing.T) {
+	c := newTestChain(t)
+
+	pinOf := func(cert *x509.Certificate) string {
+		p, err := pin(cert)
+		require.NoError(t, err)
+		return p
+	}
+
+	verified := []*x509.Certificate{c.leaf, c.intermediate, c.root}
+	presented := []*x509.Certificate{c.leaf, c.intermediate}
+
+	tests := []struct {
+		name     string
+		chain    []*x509.Certificate
+		elements []string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "leaf", chain: verified, elements: []string{ChainLeaf}, want: []string{pinOf(c.leaf)}},
+		{name: "intermediate", chain: verified, elements: []string{ChainIntermediate}, want: []string{pinOf(c.intermediate)}},
+		{name: "root", chain: verified, elements: []string{ChainRoot}, want: []string{pinOf(c.root)}},
+		{
+			name:     "chain order",
+			chain:    verified,
+			elements: []string{ChainRoot, ChainLeaf, ChainIntermediate},
+			want:     []string{pinOf(c.leaf), pinOf(c.intermediate), pinOf(c.root)},
+		},
+		{name: "presented chain without root", chain: presented, elements: []string{ChainRoot}, wantErr: true},
+		{name: "presented chain intermediate", chain: presented, elements: []string{ChainIntermediate}, want: []string{pinOf(c.intermediate)}},
+		{name: "leaf only", chain: []*x509.Certificate{c.leaf}, elements: []string{ChainIntermediate}, wantErr: true},
+		{name: "self-signed leaf is no root", chain: []*x509.Certificate{c.root}, elements: []string{ChainRoot}, wantErr: true},
+		{name: "no certificate", elements: []string{ChainLeaf}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pins, err := chainPins(tt.chain, tt.elements)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, pins)
+		})
+	}
+}
+
+func TestKeys_FetchDomainKey_Chain(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	c := newTestChain(t)
+
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{c.leaf.Raw, c.intermediate.Raw},
+			PrivateKey:  c.leafKey,
+		}},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(c.root)
+
+	leafPin, err := pin(c.leaf)
+	require.NoError(t, err)
+	intermediatePin, err := pin(c.intermediate)
+	require.NoError(t, err)
+	rootPin, err := pin(c.root)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		chain     []string
+		opts      []Option
+		wantKey   string
+		wantPins  []string
+		wantError bool
+	}{
+		{name: "leaf by default", opts: []Option{WithRootCAs(roots)}, wantKey: leafPin},
+		{
+			name:     "verified chain",
+			chain:    []string{ChainLeaf, ChainIntermediate, ChainRoot},
+			opts:     []Option{WithRootCAs(roots)},
+			wantKey:  leafPin,
+			wantPins: []string{leafPin, intermediatePin, rootPin},
+		},
+		{
+			name:     "backup pin only",
+			chain:    []string{ChainIntermediate},
+			opts:     []Option{WithRootCAs(roots)},
+			wantKey:  intermediatePin,
+			wantPins: []string{intermediatePin},
+		},
+		{
+			name:     "unverified chain",
+			chain:    []string{ChainLeaf, ChainIntermediate},
+			opts:     []Option{WithSkipVerify(true)},
+			wantKey:  leafPin,
+			wantPins: []string{leafPin, intermediatePin},
+		},
+		{name: "unverified chain without root", chain: []string{ChainRoot}, opts: []Option{WithSkipVerify(true)}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			k := NewKeys(ctx, []types.DomainKey{}, append(tt.opts, WithTimeout(2*time.Second))...)
+
+			res, err := k.fetchDomainKey(&types.DomainKey{
+				Chain:   tt.chain,
+				Connect: srv.Listener.Addr().String(),
+				Fqdn:    "example.com",
+			})
+			if tt.wantError {
+				assert.Error(t, err)
+				assert.Nil(t, res)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKey, res.Key)
+			assert.Equal(t, tt.wantPins, res.Pins)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// ProxyDirect is the proxy setting that fetches certificates without a proxy, ignoring
+// the global proxy and the HTTPS_PROXY environment variable.
+const ProxyDirect = "direct"
+
+// proxyPorts are the default ports of the supported proxy schemes.
+var proxyPorts = map[string]string{
+	"http":    "80",
+	"https":   "443",
+	"socks5":  "1080",
+	"socks5h": "1080",
+}
+
+// ParseProxy parses the URL of a proxy certificates are fetched through: an HTTP CONNECT proxy
+// (http:// or https://) or a SOCKS5 proxy (socks5:// or socks5h://, host names are always
+// resolved by the proxy), with optional user info for authentication. The port defaults to
+// the one of the scheme. Returns nil for ProxyDirect and an error for invalid URLs.
+func ParseProxy(raw string) (*url.URL, error) {
+	if raw == ProxyDirect {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+
+	port, ok := proxyPorts[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https, socks5 or socks5h", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy url %q has no host", raw)
+	}
+
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return u, nil
+}
+
+// proxyFor returns the proxy the certificate of a domain key is fetched through: the proxy of
+// the key, otherwise the one set with WithProxy, otherwise the one of the HTTPS_PROXY and
+// NO_PROXY environment variables. Returns nil if the certificate is fetched directly.
+func (k *Keys) proxyFor(key *types.DomainKey) (*url.URL, error) {
+	raw := key.Proxy
+	if raw == "" {
+		raw = k.proxy
+	}
+
+	if raw != "" {
+		return ParseProxy(raw)
+	}
+
+	if k.envProxy == nil {
+		return nil, nil
+	}
+
+	return k.envProxy(&url.URL{Scheme: "https", Host: key.Address()})
+}
+
+// dial connects to the address of a domain key, through its proxy if any (see proxyFor).
+func (k *Keys) dial(ctx context.Context, key *types.DomainKey) (net.Conn, error) {
+	dialer := &net.Dialer{}
+
+	proxyURL, err := k.proxyFor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL == nil {
+		return k.dialDirect(ctx, dialer, key.Address())
+	}
+
+	if proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h" {
+		return dialSOCKS5(ctx, dialer, proxyURL, key.Address())
+	}
+
+	return dialConnect(ctx, dialer, proxyURL, key.Address())
+}
+
+// dialSOCKS5 connects to addr through a SOCKS5 proxy.
+func dialSOCKS5(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+
+	socks, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 proxy %s: %w", proxyURL.Redacted(), err)
+	}
+
+	return conn, nil
+}
+
+// dialConnect connects to addr through an HTTP CONNECT tunnel of an HTTP or HTTPS proxy.
+func dialConnect(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("https proxy %s: %w", proxyURL.Redacted(), err)
+		}
+
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy %s: %w", proxyURL.Redacted(), err)
+	}
+
+	br := bufio.NewReader(conn)
+
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy %s: %w", proxyURL.Redacted(), err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy %s: CONNECT %s: %s", proxyURL.Redacted(), addr, res.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn is a connection whose reads drain the reader of the proxy response first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
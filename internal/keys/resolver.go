@@ -0,0 +1,236 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxDoHResponseSize is the maximum size of DNS-over-HTTPS responses.
+const maxDoHResponseSize = 64 << 10
+
+// resolverPorts are the default ports of the supported resolver schemes.
+var resolverPorts = map[string]string{
+	"https": "443",
+	"tcp":   "53",
+	"udp":   "53",
+}
+
+// ParseResolver parses a resolver the host names of domains are resolved with instead of the
+// system resolver: the address of a DNS server (host[:port], udp:// or tcp://) or the URL of a
+// DNS-over-HTTPS endpoint (https://, RFC 8484). The port defaults to the one of the scheme and
+// the path of DNS-over-HTTPS endpoints to /dns-query. Returns an error for invalid resolvers.
+func ParseResolver(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "udp://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resolver: %w", err)
+	}
+
+	port, ok := resolverPorts[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported resolver scheme %q, expected udp, tcp or https", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("resolver %q has no host", raw)
+	}
+
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	if u.Scheme == "https" && (u.Path == "" || u.Path == "/") {
+		u.Path = "/dns-query"
+	}
+
+	return u, nil
+}
+
+// dialDirect connects to an address without a proxy. Host names are resolved with the
+// resolvers set with WithResolvers, if any (see lookup), and the addresses are tried in order.
+func (k *Keys) dialDirect(ctx context.Context, dialer *net.Dialer, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || len(k.resolvers) == 0 || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	ips, err := k.lookup(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, port)); err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+// lookup resolves a host name with the resolvers set with WithResolvers, trying them in order
+// until one answers. Returns a *net.DNSError if none of them resolves the host name.
+func (k *Keys) lookup(ctx context.Context, host string) ([]string, error) {
+	var err error
+
+	for _, r := range k.resolvers {
+		var ips []string
+
+		if r.Scheme == "https" {
+			ips, err = k.lookupDoH(ctx, r, host)
+		} else {
+			ips, err = lookupDNS(ctx, r, host)
+		}
+
+		if err == nil {
+			return ips, nil
+		}
+
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			break
+		}
+	}
+
+	return nil, err
+}
+
+// lookupDNS resolves a host name with a DNS server.
+func lookupDNS(ctx context.Context, server *url.URL, host string) ([]string, error) {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, server.Scheme, server.Host)
+		},
+	}
+
+	return r.LookupHost(ctx, host)
+}
+
+// lookupDoH resolves the IPv4 and IPv6 addresses of a host name with a DNS-over-HTTPS endpoint.
+func (k *Keys) lookupDoH(ctx context.Context, endpoint *url.URL, host string) ([]string, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host, Server: endpoint.Redacted()}
+	}
+
+	var ips []string
+
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answer, err := k.queryDoH(ctx, endpoint, name, qtype)
+		if err != nil {
+			return nil, &net.DNSError{Err: err.Error(), Name: host, Server: endpoint.Redacted()}
+		}
+
+		if answer.RCode == dnsmessage.RCodeNameError {
+			return nil, &net.DNSError{Err: "no such host", Name: host, Server: endpoint.Redacted(), IsNotFound: true}
+		}
+
+		if answer.RCode != dnsmessage.RCodeSuccess {
+			return nil, &net.DNSError{Err: fmt.Sprintf("server responded %s", answer.RCode), Name: host, Server: endpoint.Redacted()}
+		}
+
+		for _, rr := range answer.Answers {
+			switch body := rr.Body.(type) {
+			case *dnsmessage.AResource:
+				ips = append(ips, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				ips = append(ips, net.IP(body.AAAA[:]).String())
+			}
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, Server: endpoint.Redacted(), IsNotFound: true}
+	}
+
+	return ips, nil
+}
+
+// queryDoH sends a DNS query to a DNS-over-HTTPS endpoint with the POST method of RFC 8484.
+func (k *Keys) queryDoH(ctx context.Context, endpoint *url.URL, name dnsmessage.Name, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+
+	body, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	res, err := k.dohClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh endpoint responded %s", res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxDoHResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(data); err != nil {
+		return nil, fmt.Errorf("invalid doh response: %w", err)
+	}
+
+	return &answer, nil
+}
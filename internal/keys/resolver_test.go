@@ -0,0 +1,256 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+// dnsAnswer answers a packed DNS query with the A records of hosts, or NXDOMAIN for
+// unknown host names.
+func dnsAnswer(t *testing.T, query []byte, hosts map[string][]string) []byte {
+	t.Helper()
+
+	var msg dnsmessage.Message
+	require.NoError(t, msg.Unpack(query))
+
+	msg.Header.Response = true
+
+	q := msg.Questions[0]
+
+	ips, ok := hosts[q.Name.String()]
+	switch {
+	case !ok:
+		msg.Header.RCode = dnsmessage.RCodeNameError
+	case q.Type == dnsmessage.TypeA:
+		for _, ip := range ips {
+			var a [4]byte
+			copy(a[:], net.ParseIP(ip).To4())
+
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.AResource{A: a},
+			})
+		}
+	}
+
+	data, err := msg.Pack()
+	require.NoError(t, err)
+
+	return data
+}
+
+// newDNSServer starts a UDP DNS server answering with the A records of hosts.
+func newDNSServer(t *testing.T, hosts map[string][]string) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			conn.WriteTo(dnsAnswer(t, buf[:n], hosts), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// newDoHServer starts a DNS-over-HTTPS endpoint answering with the A records of hosts.
+func newDoHServer(t *testing.T, hosts map[string][]string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/dns-query" || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		query, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(dnsAnswer(t, query, hosts))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestParseResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "ip", raw: "1.1.1.1", want: "udp://1.1.1.1:53"},
+		{name: "ip and port", raw: "1.1.1.1:5353", want: "udp://1.1.1.1:5353"},
+		{name: "ipv6", raw: "[2606:4700:4700::1111]", want: "udp://[2606:4700:4700::1111]:53"},
+		{name: "host name", raw: "dns.internal", want: "udp://dns.internal:53"},
+		{name: "tcp", raw: "tcp://1.1.1.1", want: "tcp://1.1.1.1:53"},
+		{name: "doh", raw: "https://cloudflare-dns.com", want: "https://cloudflare-dns.com:443/dns-query"},
+		{name: "doh with path", raw: "https://dns.google/resolve", want: "https://dns.google:443/resolve"},
+		{name: "unsupported scheme", raw: "tls://1.1.1.1", wantErr: true},
+		{name: "no host", raw: "https:///dns-query", wantErr: true},
+		{name: "invalid", raw: "udp://[::1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResolver(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestKeys_lookup(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	hosts := map[string][]string{"example.com.": {"192.0.2.1"}}
+
+	dns := newDNSServer(t, hosts)
+	doh := newDoHServer(t, hosts)
+
+	unreachable := newDoHServer(t, hosts)
+	unreachable.Close()
+
+	tests := []struct {
+		name         string
+		resolvers    []string
+		host         string
+		want         []string
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{name: "dns server", resolvers: []string{dns}, host: "example.com", want: []string{"192.0.2.1"}},
+		{name: "doh", resolvers: []string{doh.URL}, host: "example.com", want: []string{"192.0.2.1"}},
+		{name: "dns server not found", resolvers: []string{dns}, host: "missing.example.com", wantNotFound: true},
+		{name: "doh not found", resolvers: []string{doh.URL}, host: "missing.example.com", wantNotFound: true},
+		{name: "fallback", resolvers: []string{unreachable.URL, doh.URL}, host: "example.com", want: []string{"192.0.2.1"}},
+		{name: "unreachable", resolvers: []string{unreachable.URL}, host: "example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			k := NewKeys(ctx, []types.DomainKey{}, WithResolvers(tt.resolvers))
+			k.dohClient = doh.Client()
+
+			got, err := k.lookup(ctx, tt.host)
+			if tt.wantNotFound || tt.wantErr {
+				var dnsErr *net.DNSError
+				require.ErrorAs(t, err, &dnsErr)
+				assert.Equal(t, tt.wantNotFound, dnsErr.IsNotFound)
+				assert.Equal(t, ErrorDNS, errorCategory(err))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKeys_FetchDomainKey_Resolver(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newTestChain(t)
+
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{c.leaf.Raw, c.intermediate.Raw},
+			PrivateKey:  c.leafKey,
+		}},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	doh := newDoHServer(t, map[string][]string{"example.com.": {"127.0.0.1"}})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(c.root)
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithProxy(ProxyDirect),
+		WithResolvers([]string{doh.URL}),
+		WithRootCAs(roots),
+		WithTimeout(2*time.Second),
+	)
+	k.dohClient = doh.Client()
+
+	res, err := k.fetchDomainKey(&types.DomainKey{Fqdn: "example.com:" + port})
+	require.NoError(t, err)
+
+	leafPin, err := pin(c.leaf)
+	require.NoError(t, err)
+	assert.Equal(t, leafPin, res.Key)
+}
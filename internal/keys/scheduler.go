@@ -0,0 +1,203 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// fetch is a scheduled certificate fetch of a domain key.
+// gen is the generation of the key when it was added (see AddKey), fetches of removed
+// or re-added keys are dropped.
+type fetch struct {
+	key types.DomainKey
+	gen uint64
+	at  time.Time
+}
+
+// queue is a min-heap of fetches ordered by time.
+type queue []fetch
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+func (q queue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x any)        { *q = append(*q, x.(fetch)) }
+
+func (q *queue) Pop() any {
+	old := *q
+	n := len(old)
+	f := old[n-1]
+	*q = old[:n-1]
+
+	return f
+}
+
+// enqueue schedules a fetch and wakes up the scheduler.
+func (k *Keys) enqueue(f fetch) {
+	k.qmu.Lock()
+	heap.Push(&k.queue, f)
+	k.qmu.Unlock()
+
+	select {
+	case k.wake <- struct{}{}:
+	default:
+	}
+}
+
+// schedule is a background goroutine that hands due fetches to the worker pool in time order.
+// It waits for the earliest fetch or for a new one to be enqueued and blocks while all workers
+// are busy, so at most WithConcurrency certificates are fetched at once. No fetch is handed
+// over while paused (see Pause).
+// It runs until the context is cancelled.
+func (k *Keys) schedule() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		k.qmu.Lock()
+
+		if !k.pausedAt.IsZero() {
+			k.qmu.Unlock()
+
+			select {
+			case <-k.ctx.Done():
+				return
+			case <-k.wake:
+			}
+
+			continue
+		}
+
+		wait := time.Hour
+		if len(k.queue) > 0 {
+			wait = time.Until(k.queue[0].at)
+		}
+
+		if len(k.queue) > 0 && wait <= 0 {
+			f := heap.Pop(&k.queue).(fetch)
+			k.qmu.Unlock()
+
+			select {
+			case k.jobs <- f:
+			case <-k.ctx.Done():
+				return
+			}
+
+			continue
+		}
+
+		k.qmu.Unlock()
+
+		timer.Reset(wait)
+
+		select {
+		case <-k.ctx.Done():
+			return
+		case <-k.wake:
+		case <-timer.C:
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// work is a background goroutine of the worker pool. It fetches the certificates of the
+// keys handed over by schedule, or expands wildcard keys (see expand), and enqueues their next fetch (see nextFetch) once done,
+// so a key is never fetched twice at the same time. Fetches of removed keys are dropped.
+// Running and busy workers are counted for metrics (see fetchStats).
+// It runs until the context is cancelled.
+func (k *Keys) work() {
+	k.workers.Add(1)
+	defer k.workers.Add(-1)
+
+	for {
+		select {
+		case <-k.ctx.Done():
+			return
+		case f := <-k.jobs:
+			if !k.current(f) {
+				continue
+			}
+
+			k.busy.Add(1)
+			if f.key.IsWildcard() {
+				k.expand(&f.key)
+			} else {
+				k.update(&f.key)
+			}
+			k.busy.Add(-1)
+
+			if !k.current(f) {
+				continue
+			}
+
+			f.at = nextFetch(f.key.Fqdn, k.interval(&f.key), time.Now())
+			k.enqueue(f)
+		}
+	}
+}
+
+// current reports whether a fetch belongs to the key currently scheduled for its FQDN.
+func (k *Keys) current(f fetch) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	gen, ok := k.scheduled[f.key.Fqdn]
+
+	return ok && gen == f.gen
+}
+
+// nextFetch returns the next fetch time of a domain after now. Every domain is fetched at a
+// fixed phase of its interval derived from the FQDN, so the fetches of many domains are spread
+// over the interval instead of hitting upstream hosts and the egress at once.
+func nextFetch(fqdn string, interval time.Duration, now time.Time) time.Time {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(fqdn))
+
+	phase := time.Duration(h.Sum64() % uint64(interval))
+
+	at := now.Truncate(interval).Add(phase)
+	if !at.After(now) {
+		at = at.Add(interval)
+	}
+
+	return at
+}
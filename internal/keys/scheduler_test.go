@@ -0,0 +1,175 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestNextFetch(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		fqdn     string
+		interval time.Duration
+	}{
+		{name: "minute", fqdn: "example.com", interval: time.Minute},
+		{name: "hour", fqdn: "example.com", interval: time.Hour},
+		{name: "another domain", fqdn: "test.com", interval: time.Minute},
+		{name: "sub-second", fqdn: "example.com", interval: 10 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := nextFetch(tt.fqdn, tt.interval, now)
+
+			assert.True(t, at.After(now))
+			assert.LessOrEqual(t, at.Sub(now), tt.interval)
+
+			// the phase within the interval is stable
+			next := nextFetch(tt.fqdn, tt.interval, at)
+			assert.Equal(t, tt.interval, next.Sub(at))
+		})
+	}
+}
+
+func TestNextFetch_Spread(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	interval := time.Minute
+
+	buckets := make(map[int64]int)
+	for i := range 1000 {
+		at := nextFetch(fmt.Sprintf("host-%d.example.com", i), interval, now)
+		buckets[int64(at.Sub(now)/(6*time.Second))]++
+	}
+
+	// 1000 domains over 10 buckets of 6s, none of them must take the bulk of the fetches
+	require.Len(t, buckets, 10)
+	for b, n := range buckets {
+		assert.Less(t, n, 200, "bucket %d", b)
+	}
+}
+
+func TestKeys_Schedule_Concurrency(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithConcurrency(3),
+		WithFetchInterval(time.Hour),
+	)
+
+	var inFlight, maxInFlight, fetches atomic.Int32
+	release := make(chan struct{})
+
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		<-release
+		fetches.Add(1)
+
+		return &types.DomainKey{Expire: 3600, Key: "key-" + key.Fqdn}, nil
+	}
+
+	for i := range 10 {
+		key := types.DomainKey{Fqdn: fmt.Sprintf("host-%d.example.com", i), File: fmt.Sprintf("host-%d.json", i)}
+		k.AddKey(key.Fqdn, &key)
+	}
+
+	require.Eventually(t, func() bool { return inFlight.Load() == 3 }, time.Second, time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(3), inFlight.Load(), "fetches must not exceed the concurrency")
+
+	close(release)
+
+	require.Eventually(t, func() bool { return fetches.Load() == 10 }, time.Second, time.Millisecond)
+	assert.Equal(t, int32(3), maxInFlight.Load())
+
+	for i := range 10 {
+		fqdn := fmt.Sprintf("host-%d.example.com", i)
+		val, ok := k.Get(fqdn)
+		require.True(t, ok)
+		assert.Equal(t, "key-"+fqdn, val.Key)
+	}
+}
+
+func TestKeys_AddKey_Scheduled(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithFetchInterval(time.Hour),
+	)
+
+	var fetches atomic.Int32
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		fetches.Add(1)
+		return &types.DomainKey{Expire: 3600, Key: "key"}, nil
+	}
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.AddKey(key.Fqdn, &key)
+	k.AddKey(key.Fqdn, &key)
+
+	require.Eventually(t, func() bool { return fetches.Load() == 1 }, time.Second, time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(1), fetches.Load(), "a scheduled key must not be fetched twice")
+}
@@ -0,0 +1,165 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"time"
+
+	"ssl-pinning/internal/metrics"
+)
+
+// fetchState is the outcome of the latest certificate fetches of a domain (see Status).
+type fetchState struct {
+	lastSuccess time.Time
+	failures    int
+}
+
+// DomainStatus is the state of the certificate fetches of a monitored domain.
+// Expire is the number of seconds the certificate was valid for at the latest successful fetch
+// and NotAfter its expiration. ConsecutiveFailures counts the failed fetches since the latest
+// successful one. NextFetch is empty while the domain is being fetched.
+type DomainStatus struct {
+	Fqdn                string     `json:"fqdn"`
+	File                string     `json:"file"`
+	Pin                 string     `json:"pin,omitempty"`
+	Pins                []string   `json:"pins,omitempty"`
+	Expire              int64      `json:"expire,omitempty"`
+	NotAfter            *time.Time `json:"not_after,omitempty"`
+	LastFetch           *time.Time `json:"last_fetch,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ErrorCategory       string     `json:"error_category,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	NextFetch           *time.Time `json:"next_fetch,omitempty"`
+}
+
+// Status returns the state of the certificate fetches of a monitored domain.
+// Returns false if the FQDN is unknown.
+func (k *Keys) Status(fqdn string) (DomainStatus, bool) {
+	k.mu.RLock()
+	ptr, ok := k.store[fqdn]
+	state := k.fetchStates[fqdn]
+	gen, scheduled := k.scheduled[fqdn]
+	k.mu.RUnlock()
+
+	if !ok || ptr == nil {
+		return DomainStatus{}, false
+	}
+
+	key := *ptr
+
+	status := DomainStatus{
+		Fqdn:                key.Fqdn,
+		File:                key.File,
+		Pin:                 key.Key,
+		Pins:                key.Pins,
+		Expire:              key.Expire,
+		LastFetch:           key.Date,
+		LastError:           key.LastError,
+		ErrorCategory:       key.ErrorCategory,
+		ConsecutiveFailures: state.failures,
+	}
+
+	if key.Cert != nil {
+		notAfter := key.Cert.NotAfter
+		status.NotAfter = &notAfter
+	}
+
+	if !state.lastSuccess.IsZero() {
+		lastSuccess := state.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+
+	if scheduled {
+		status.NextFetch = k.nextScheduled(fqdn, gen)
+	}
+
+	return status, true
+}
+
+// recordFetch updates the fetch state of a domain with the outcome of a fetch at date.
+// The caller must hold k.mu.
+func (k *Keys) recordFetch(fqdn string, date time.Time, err error) {
+	state := k.fetchStates[fqdn]
+
+	if err == nil {
+		state.lastSuccess = date
+		state.failures = 0
+	} else {
+		state.failures++
+	}
+
+	k.fetchStates[fqdn] = state
+}
+
+// fetchStats returns the number of monitored domains, excluding wildcard domains but including
+// the hosts expanded from them, the number of those whose latest fetch failed, and the number
+// of running and busy workers.
+func (k *Keys) fetchStats() metrics.FetchStats {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	stats := metrics.FetchStats{
+		Workers:     int(k.workers.Load()),
+		WorkersBusy: int(k.busy.Load()),
+	}
+
+	for fqdn, key := range k.store {
+		if key == nil || key.IsWildcard() {
+			continue
+		}
+
+		stats.Domains++
+
+		if k.fetchStates[fqdn].failures > 0 {
+			stats.DomainsFailing++
+		}
+	}
+
+	return stats
+}
+
+// nextScheduled returns the time of the scheduled fetch of a domain, or nil if the domain
+// is not queued, e.g. while it is being fetched.
+func (k *Keys) nextScheduled(fqdn string, gen uint64) *time.Time {
+	k.qmu.Lock()
+	defer k.qmu.Unlock()
+
+	for _, f := range k.queue {
+		if f.key.Fqdn == fqdn && f.gen == gen {
+			at := f.at
+			return &at
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestKeys_Status(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithFetchInterval(time.Hour),
+	)
+
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var fail atomic.Bool
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		if fail.Load() {
+			return nil, errors.New("connection refused")
+		}
+
+		return &types.DomainKey{Expire: 3600, Key: "key", Cert: &types.Certificate{NotAfter: notAfter}}, nil
+	}
+
+	_, ok := k.Status("example.com")
+	assert.False(t, ok)
+
+	key := types.DomainKey{Fqdn: "example.com", File: "example.json"}
+	k.AddKey(key.Fqdn, &key)
+
+	var status DomainStatus
+	require.Eventually(t, func() bool {
+		status, _ = k.Status(key.Fqdn)
+		return status.LastSuccess != nil && status.NextFetch != nil
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, "example.com", status.Fqdn)
+	assert.Equal(t, "example.json", status.File)
+	assert.Equal(t, "key", status.Pin)
+	assert.Equal(t, int64(3600), status.Expire)
+	assert.Equal(t, &notAfter, status.NotAfter)
+	require.NotNil(t, status.LastSuccess)
+	assert.Equal(t, status.LastFetch, status.LastSuccess)
+	assert.Zero(t, status.ConsecutiveFailures)
+	assert.True(t, status.NextFetch.After(*status.LastFetch))
+
+	fail.Store(true)
+	lastSuccess := *status.LastSuccess
+
+	for i := range 2 {
+		k.update(&key)
+
+		status, _ = k.Status(key.Fqdn)
+		assert.Equal(t, i+1, status.ConsecutiveFailures)
+	}
+
+	assert.Equal(t, "connection refused", status.LastError)
+	assert.Equal(t, lastSuccess, *status.LastSuccess)
+	assert.Equal(t, "key", status.Pin, "the pin of the latest successful fetch is kept")
+
+	fail.Store(false)
+	k.update(&key)
+
+	status, _ = k.Status(key.Fqdn)
+	assert.Zero(t, status.ConsecutiveFailures)
+	assert.Empty(t, status.LastError)
+	assert.True(t, status.LastSuccess.After(lastSuccess))
+
+	k.RemoveKey(key.Fqdn)
+
+	_, ok = k.Status(key.Fqdn)
+	assert.False(t, ok)
+}
+
+func TestKeys_fetchStats(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{}, WithCollector(metrics.NewCollector()), WithConcurrency(2))
+
+	release := make(chan struct{})
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		<-release
+
+		if key.Fqdn == "fail.example.com" {
+			return nil, errors.New("connection refused")
+		}
+
+		return &types.DomainKey{Expire: 3600, Key: "key"}, nil
+	}
+
+	require.Eventually(t, func() bool { return k.fetchStats().Workers == 2 }, time.Second, 10*time.Millisecond)
+
+	k.Set("*.example.com", types.DomainKey{Fqdn: "*.example.com"})
+	k.AddKey("ok.example.com", &types.DomainKey{Fqdn: "ok.example.com"})
+	k.AddKey("fail.example.com", &types.DomainKey{Fqdn: "fail.example.com"})
+
+	require.Eventually(t, func() bool { return k.fetchStats().WorkersBusy == 2 }, time.Second, 10*time.Millisecond)
+
+	close(release)
+
+	require.Eventually(t, func() bool { return k.fetchStats().WorkersBusy == 0 }, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, metrics.FetchStats{Domains: 2, DomainsFailing: 1, Workers: 2}, k.fetchStats())
+
+	cancel()
+
+	require.Eventually(t, func() bool { return k.fetchStats().Workers == 0 }, time.Second, 10*time.Millisecond)
+}
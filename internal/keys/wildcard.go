@@ -0,0 +1,187 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// expand resolves the hosts of a wildcard domain key (see wildcardHosts) and monitors a domain
+// key per host, with the settings of the wildcard key, in place of the wildcard. Hosts that are
+// no longer found are no longer monitored and their stored keys are deleted with the function
+// set with WithDeleteFunc. Hosts configured with their own key are left as they are.
+// The outcome is recorded in the wildcard key; on failure the hosts found last are kept.
+func (k *Keys) expand(key *types.DomainKey) {
+	cur := time.Now()
+
+	hosts, err := k.wildcardHosts(key)
+	if err != nil {
+		slog.Error("failed to expand wildcard domain", "fqdn", key.Fqdn, "err", err)
+	}
+
+	k.mu.Lock()
+	if _, ok := k.scheduled[key.Fqdn]; !ok {
+		k.mu.Unlock()
+		return
+	}
+
+	var val types.DomainKey
+	if ptr, ok := k.store[key.Fqdn]; ok {
+		val = *ptr
+	}
+
+	val.Date = &cur
+	val.LastError = ""
+	val.ErrorCategory = ""
+
+	if err != nil {
+		val.LastError = err.Error()
+		val.ErrorCategory = errorCategory(err)
+	}
+
+	k.store[key.Fqdn] = &val
+	k.recordFetch(key.Fqdn, cur, err)
+
+	var stale []string
+
+	if err == nil {
+		for fqdn, ptr := range k.store {
+			if ptr.Wildcard == key.Fqdn && !slices.Contains(hosts, fqdn) {
+				stale = append(stale, fqdn)
+			}
+		}
+	}
+	k.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	for _, fqdn := range stale {
+		k.removeExpanded(fqdn)
+	}
+
+	for _, fqdn := range hosts {
+		if _, ok := k.Get(fqdn); ok {
+			continue
+		}
+
+		child := *key
+		child.Fqdn = fqdn
+		child.Hosts = nil
+		child.Wildcard = key.Fqdn
+		child.Date = nil
+		child.LastError = ""
+		child.ErrorCategory = ""
+
+		slog.Info("wildcard domain expanded", "fqdn", fqdn, "wildcard", key.Fqdn)
+
+		k.AddKey(fqdn, &child)
+	}
+}
+
+// wildcardHosts returns the FQDNs of the hosts of a wildcard domain key, with the port of the
+// wildcard if any: its Hosts if set, otherwise the DNS names among the subject alternative names
+// of the certificate of the apex domain covered by the wildcard (see types.DomainKey.MatchesWildcard).
+// Returns an error if the certificate of the apex domain cannot be fetched or covers no host.
+func (k *Keys) wildcardHosts(key *types.DomainKey) ([]string, error) {
+	names := key.Hosts
+
+	if len(names) == 0 {
+		apex := *key
+		apex.Fqdn = key.Apex()
+		apex.Chain = nil
+		apex.ServerName = ""
+
+		res, err := k.fetch(&apex)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.Cert == nil {
+			return nil, fmt.Errorf("no certificate of %s", apex.Fqdn)
+		}
+
+		names = res.Cert.SANs
+	}
+
+	_, port, err := net.SplitHostPort(key.Fqdn)
+	if err != nil {
+		port = ""
+	}
+
+	var hosts []string
+
+	for _, name := range names {
+		if !key.MatchesWildcard(name) {
+			continue
+		}
+
+		fqdn := strings.ToLower(name)
+		if port != "" {
+			fqdn = net.JoinHostPort(fqdn, port)
+		}
+
+		if !slices.Contains(hosts, fqdn) {
+			hosts = append(hosts, fqdn)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, errors.New("no hosts match the wildcard")
+	}
+
+	slices.Sort(hosts)
+
+	return hosts, nil
+}
+
+// removeExpanded stops monitoring a host expanded from a wildcard domain key and deletes its
+// stored keys with the function set with WithDeleteFunc.
+func (k *Keys) removeExpanded(fqdn string) {
+	key, ok := k.RemoveKey(fqdn)
+	if !ok || k.deleteFunc == nil {
+		return
+	}
+
+	if err := k.deleteFunc(key.File, fqdn); err != nil && !errors.Is(err, types.ErrNotFound) {
+		slog.Error("failed to delete keys of expanded domain", "fqdn", fqdn, "err", err)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package keys
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	logger "gopkg.in/slog-handler.v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+func TestKeys_expand(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		sans    = []string{"example.com", "*.example.com", "a.example.com", "B.example.com", "a.b.example.com", "other.com"}
+		deleted []string
+	)
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithFetchInterval(time.Hour),
+		WithDeleteFunc(func(file, fqdn string) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			deleted = append(deleted, fqdn)
+
+			return nil
+		}),
+	)
+
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if key.Fqdn == "example.com:8443" {
+			return &types.DomainKey{Key: "apex", Cert: &types.Certificate{SANs: slices.Clone(sans)}}, nil
+		}
+
+		return &types.DomainKey{Key: "pin-" + key.Fqdn}, nil
+	}
+
+	wildcard := types.DomainKey{Fqdn: "*.example.com:8443", File: "example.json", BackupPins: []string{"backup"}}
+	k.AddKey(wildcard.Fqdn, &wildcard)
+
+	require.Eventually(t, func() bool {
+		a, okA := k.Get("a.example.com:8443")
+		b, okB := k.Get("b.example.com:8443")
+		return okA && okB && a.Key != "" && b.Key != ""
+	}, time.Second, time.Millisecond)
+
+	child, _ := k.Get("a.example.com:8443")
+	assert.Equal(t, "pin-a.example.com:8443", child.Key)
+	assert.Equal(t, "example.json", child.File)
+	assert.Equal(t, wildcard.Fqdn, child.Wildcard)
+	assert.Contains(t, child.Pins, "backup")
+	assert.Len(t, k.Snapshot(), 3, "only hosts matching one label are expanded")
+
+	status, ok := k.Status(wildcard.Fqdn)
+	require.True(t, ok)
+	assert.Empty(t, status.LastError)
+	assert.NotNil(t, status.LastSuccess)
+
+	mu.Lock()
+	sans = []string{"a.example.com"}
+	mu.Unlock()
+
+	k.expand(&wildcard)
+
+	_, ok = k.Get("b.example.com:8443")
+	assert.False(t, ok, "vanished hosts are removed")
+	assert.Equal(t, []string{"b.example.com:8443"}, deleted)
+
+	mu.Lock()
+	sans = nil
+	mu.Unlock()
+
+	k.expand(&wildcard)
+
+	_, ok = k.Get("a.example.com:8443")
+	assert.True(t, ok, "hosts are kept when the expansion fails")
+
+	status, _ = k.Status(wildcard.Fqdn)
+	assert.Equal(t, "no hosts match the wildcard", status.LastError)
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+
+	k.RemoveKey(wildcard.Fqdn)
+
+	assert.Empty(t, k.Snapshot(), "hosts are removed along with their wildcard")
+	assert.ElementsMatch(t, []string{"b.example.com:8443", "a.example.com:8443"}, deleted)
+}
+
+func TestKeys_wildcardHosts(t *testing.T) {
+	k := &Keys{}
+
+	key := types.DomainKey{
+		Fqdn:  "*.example.com",
+		Hosts: []string{"b.example.com", "a.example.com", "A.example.com", "a.b.example.com"},
+	}
+
+	hosts, err := k.wildcardHosts(&key)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, hosts)
+
+	key.Hosts = []string{"other.com"}
+
+	_, err = k.wildcardHosts(&key)
+	assert.Error(t, err)
+}
+
+func TestKeys_Reconcile_Wildcard(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := NewKeys(ctx, []types.DomainKey{},
+		WithCollector(metrics.NewCollector()),
+		WithFetchInterval(time.Hour),
+	)
+
+	k.fetch = func(key *types.DomainKey) (*types.DomainKey, error) {
+		return &types.DomainKey{Key: "pin"}, nil
+	}
+
+	wildcard := types.DomainKey{Fqdn: "*.example.com", File: "example.json", Hosts: []string{"a.example.com"}}
+
+	k.Reconcile([]types.DomainKey{wildcard})
+
+	require.Eventually(t, func() bool {
+		_, ok := k.Get("a.example.com")
+		return ok
+	}, time.Second, time.Millisecond)
+
+	k.Reconcile([]types.DomainKey{wildcard})
+
+	_, ok := k.Get("a.example.com")
+	assert.True(t, ok, "expanded hosts are kept when their wildcard is unchanged")
+
+	k.Reconcile([]types.DomainKey{})
+
+	assert.Empty(t, k.Snapshot())
+}
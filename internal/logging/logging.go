@@ -0,0 +1,174 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// modulePath is the import path of the module, stripped from the package names.
+const modulePath = "ssl-pinning/"
+
+// levels are the minimum levels of the records passed on by the handlers of NewHandler.
+var levels atomic.Pointer[Levels]
+
+func init() {
+	SetLevels(Levels{Level: slog.LevelInfo})
+}
+
+// Levels are the minimum log level of the application and of single packages, e.g. to log
+// the keys worker at debug level while investigating a domain. Packages are named by their
+// path below internal, e.g. keys or storage/redis, or below the module for others, e.g. cmd.
+// The level of a package applies to its subpackages without a level of their own.
+type Levels struct {
+	Level    slog.Level
+	Packages map[string]slog.Level
+
+	min slog.Level
+}
+
+// SetLevels sets the minimum levels of the records passed on by the handlers of NewHandler.
+// It is safe to call while logging, e.g. to change the levels at runtime.
+func SetLevels(l Levels) {
+	l.Packages = maps.Clone(l.Packages)
+
+	l.min = l.Level
+	for _, level := range l.Packages {
+		l.min = min(l.min, level)
+	}
+
+	levels.Store(&l)
+}
+
+// CurrentLevels returns the levels set with SetLevels.
+func CurrentLevels() Levels {
+	l := *levels.Load()
+	l.Packages = maps.Clone(l.Packages)
+
+	return l
+}
+
+// ParseLevel parses a level name: debug, info, warn or error, optionally with an offset such
+// as debug+2 (see slog.Level.UnmarshalText).
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("invalid log level %q", s)
+	}
+
+	return level, nil
+}
+
+// level returns the minimum level of the records logged by the package pkg.
+func (l *Levels) level(pkg string) slog.Level {
+	for pkg != "" {
+		if level, ok := l.Packages[pkg]; ok {
+			return level
+		}
+
+		i := strings.LastIndex(pkg, "/")
+		if i < 0 {
+			break
+		}
+
+		pkg = pkg[:i]
+	}
+
+	return l.Level
+}
+
+// handler passes on the records of a package at or above its level (see SetLevels).
+type handler struct {
+	slog.Handler
+}
+
+// NewHandler returns a handler passing records at or above the level of the package logging
+// them on to h (see SetLevels). The level of h itself should be the lowest one, as records
+// below it are dropped regardless of the levels.
+func NewHandler(h slog.Handler) slog.Handler {
+	return handler{Handler: h}
+}
+
+// Enabled reports whether a record of level is passed on by any package.
+func (h handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= levels.Load().min && h.Handler.Enabled(ctx, level)
+}
+
+// Handle passes r on if its level is at or above the level of the package logging it.
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < levels.Load().level(pkg(r.PC)) {
+		return nil
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler wrapping the handler with attrs.
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler wrapping the handler with the group name.
+func (h handler) WithGroup(name string) slog.Handler {
+	return handler{Handler: h.Handler.WithGroup(name)}
+}
+
+// pkg returns the name of the package of the function at pc as used by Levels, e.g. keys
+// for ssl-pinning/internal/keys.(*Keys).update, or an empty string if pc is unknown.
+func pkg(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+
+	name := frame.Function
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		if j := strings.Index(name[i:], "."); j >= 0 {
+			name = name[:i+j]
+		}
+	} else if j := strings.Index(name, "."); j >= 0 {
+		name = name[:j]
+	}
+
+	if p, ok := strings.CutPrefix(name, modulePath+"internal/"); ok {
+		return p
+	}
+
+	return strings.TrimPrefix(name, modulePath)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	t.Cleanup(func() { SetLevels(Levels{Level: slog.LevelInfo}) })
+
+	var buf bytes.Buffer
+	log := slog.New(NewHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	tests := []struct {
+		name   string
+		levels Levels
+		want   bool
+	}{
+		{name: "below the level", levels: Levels{Level: slog.LevelInfo}, want: false},
+		{name: "at the level", levels: Levels{Level: slog.LevelDebug}, want: true},
+		{name: "level of the package", levels: Levels{Level: slog.LevelInfo, Packages: map[string]slog.Level{"logging": slog.LevelDebug}}, want: true},
+		{name: "level of another package", levels: Levels{Level: slog.LevelInfo, Packages: map[string]slog.Level{"keys": slog.LevelDebug}}, want: false},
+		{name: "package above the level", levels: Levels{Level: slog.LevelDebug, Packages: map[string]slog.Level{"logging": slog.LevelWarn}}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			SetLevels(tt.levels)
+
+			log.With("fqdn", "example.com").Debug("fetching")
+
+			assert.Equal(t, tt.want, buf.Len() > 0, buf.String())
+		})
+	}
+}
+
+func TestLevels_level(t *testing.T) {
+	l := Levels{
+		Level: slog.LevelInfo,
+		Packages: map[string]slog.Level{
+			"storage":       slog.LevelWarn,
+			"storage/redis": slog.LevelDebug,
+		},
+	}
+
+	assert.Equal(t, slog.LevelDebug, l.level("storage/redis"))
+	assert.Equal(t, slog.LevelWarn, l.level("storage/memory"))
+	assert.Equal(t, slog.LevelWarn, l.level("storage"))
+	assert.Equal(t, slog.LevelInfo, l.level("keys"))
+	assert.Equal(t, slog.LevelInfo, l.level(""))
+}
+
+func TestCurrentLevels(t *testing.T) {
+	t.Cleanup(func() { SetLevels(Levels{Level: slog.LevelInfo}) })
+
+	packages := map[string]slog.Level{"keys": slog.LevelDebug}
+	SetLevels(Levels{Level: slog.LevelWarn, Packages: packages})
+
+	// the levels are copied, changes to the maps are not picked up
+	packages["keys"] = slog.LevelError
+	CurrentLevels().Packages["keys"] = slog.LevelError
+
+	l := CurrentLevels()
+	assert.Equal(t, slog.LevelWarn, l.Level)
+	assert.Equal(t, map[string]slog.Level{"keys": slog.LevelDebug}, l.Packages)
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("debug")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	level, err = ParseLevel("WARN")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func Test_pkg(t *testing.T) {
+	assert.Empty(t, pkg(0))
+
+	var pc uintptr
+	log := slog.New(handlerFunc(func(r slog.Record) { pc = r.PC }))
+	log.Info("test")
+
+	assert.Equal(t, "logging", pkg(pc))
+}
+
+// handlerFunc is a slog.Handler calling the function with every record.
+type handlerFunc func(r slog.Record)
+
+func (f handlerFunc) Enabled(context.Context, slog.Level) bool      { return true }
+func (f handlerFunc) Handle(_ context.Context, r slog.Record) error { f(r); return nil }
+func (f handlerFunc) WithAttrs([]slog.Attr) slog.Handler            { return f }
+func (f handlerFunc) WithGroup(string) slog.Handler                 { return f }
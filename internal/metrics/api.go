@@ -0,0 +1,112 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptors of the API key and panic metrics.
+var (
+	apiKeyRequestsDesc = prometheus.NewDesc(
+		"ssl_pinning_api_key_requests_total",
+		"Number of requests to the public API authenticated with an API key",
+		[]string{"api_key"},
+		nil,
+	)
+	apiKeyRejectionsDesc = prometheus.NewDesc(
+		"ssl_pinning_api_key_rejections_total",
+		"Number of requests to the public API rejected for a missing or unknown API key",
+		nil,
+		nil,
+	)
+	httpPanicsDesc = prometheus.NewDesc(
+		"ssl_pinning_http_panics_total",
+		"Number of panics recovered while serving requests",
+		[]string{"server"},
+		nil,
+	)
+)
+
+// IncAPIKeyRequest increments the counter of requests to the public API authenticated with the API key of name.
+func (c *Collector) IncAPIKeyRequest(name string) {
+	v, _ := c.apiKeys.LoadOrStore(name, new(atomic.Uint64))
+	v.(*atomic.Uint64).Add(1)
+}
+
+// IncAPIKeyRejection increments the counter of requests to the public API rejected for a missing or unknown API key.
+func (c *Collector) IncAPIKeyRejection() {
+	c.apiKeyRejections.Add(1)
+}
+
+// IncPanic increments the counter of panics recovered while serving requests of server.
+func (c *Collector) IncPanic(server string) {
+	v, _ := c.panics.LoadOrStore(server, new(atomic.Uint64))
+	v.(*atomic.Uint64).Add(1)
+}
+
+// collectAPIKeys sends the API key metrics to Prometheus:
+// - ssl_pinning_api_key_requests_total: number of authenticated requests per API key name (counter)
+// - ssl_pinning_api_key_rejections_total: number of requests without a valid API key (counter)
+func (c *Collector) collectAPIKeys(ch chan<- prometheus.Metric) {
+	c.apiKeys.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			apiKeyRequestsDesc,
+			prometheus.CounterValue,
+			float64(v.(*atomic.Uint64).Load()),
+			k.(string),
+		)
+		return true
+	})
+
+	ch <- prometheus.MustNewConstMetric(
+		apiKeyRejectionsDesc,
+		prometheus.CounterValue,
+		float64(c.apiKeyRejections.Load()),
+	)
+}
+
+// collectPanics sends the number of recovered panics per server to Prometheus
+// (ssl_pinning_http_panics_total, counter).
+func (c *Collector) collectPanics(ch chan<- prometheus.Metric) {
+	c.panics.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			httpPanicsDesc,
+			prometheus.CounterValue,
+			float64(v.(*atomic.Uint64).Load()),
+			k.(string),
+		)
+		return true
+	})
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_APIKeys(t *testing.T) {
+	c := new(Collector)
+
+	c.IncAPIKeyRequest("ios")
+	c.IncAPIKeyRequest("ios")
+	c.IncAPIKeyRequest("android")
+	c.IncAPIKeyRejection()
+	c.IncPanic("api")
+
+	ch := make(chan prometheus.Metric, 10)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	requests := make(map[string]float64)
+	rejections := 0.0
+	panics := make(map[string]float64)
+
+	for m := range ch {
+		if strings.Contains(m.Desc().String(), "ssl_pinning_build_info") {
+			continue
+		}
+
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+
+		if l := metric.GetLabel(); len(l) == 1 && l[0].GetName() == "server" {
+			panics[l[0].GetValue()] = metric.GetCounter().GetValue()
+			continue
+		}
+
+		if len(metric.GetLabel()) == 0 {
+			rejections = metric.GetCounter().GetValue()
+			continue
+		}
+
+		requests[metric.GetLabel()[0].GetValue()] = metric.GetCounter().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{"ios": 2, "android": 1}, requests)
+	assert.Equal(t, 1.0, rejections)
+	assert.Equal(t, map[string]float64{"api": 1}, panics)
+}
@@ -0,0 +1,178 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of certificate fetches recorded with ObserveFetch.
+const (
+	FetchSuccess = "success"
+	FetchError   = "error"
+)
+
+// Descriptors of the certificate fetch metrics.
+var (
+	fetchDurationDesc = prometheus.NewDesc(
+		"ssl_pinning_fetch_duration_seconds",
+		"Duration of certificate fetches in seconds",
+		[]string{"fqdn", "result"},
+		nil,
+	)
+	domainsDesc = prometheus.NewDesc(
+		"ssl_pinning_domains",
+		"Number of monitored domains",
+		nil,
+		nil,
+	)
+	domainsFailingDesc = prometheus.NewDesc(
+		"ssl_pinning_domains_failing",
+		"Number of monitored domains whose latest certificate fetch failed",
+		nil,
+		nil,
+	)
+	workersDesc = prometheus.NewDesc(
+		"ssl_pinning_workers",
+		"Number of running certificate fetch workers",
+		nil,
+		nil,
+	)
+	workersBusyDesc = prometheus.NewDesc(
+		"ssl_pinning_workers_busy",
+		"Number of certificate fetch workers fetching a certificate",
+		nil,
+		nil,
+	)
+)
+
+// FetchStats is the state of the certificate fetch subsystem at the time of a scrape.
+type FetchStats struct {
+	Domains        int
+	DomainsFailing int
+	Workers        int
+	WorkersBusy    int
+}
+
+type fetchItem struct {
+	FQDN   string
+	Result string
+}
+
+type fetchStats struct {
+	mu      sync.Mutex
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+var fetchBuckets = prometheus.DefBuckets
+
+// ObserveFetch records the duration of a certificate fetch of a FQDN by its result,
+// FetchSuccess or FetchError.
+func (c *Collector) ObserveFetch(fqdn, result string, d time.Duration) {
+	v, _ := c.fetchDurations.LoadOrStore(
+		fetchItem{FQDN: fqdn, Result: result},
+		&fetchStats{buckets: make([]uint64, len(fetchBuckets))},
+	)
+	stats := v.(*fetchStats)
+
+	seconds := d.Seconds()
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.count++
+	stats.sum += seconds
+
+	if i := sort.SearchFloat64s(fetchBuckets, seconds); i < len(fetchBuckets) {
+		stats.buckets[i]++
+	}
+}
+
+// ClearFetchDurations removes the fetch duration metrics of a FQDN.
+// Used when a domain is removed from monitoring.
+func (c *Collector) ClearFetchDurations(fqdn string) {
+	for _, result := range []string{FetchSuccess, FetchError} {
+		c.fetchDurations.Delete(fetchItem{FQDN: fqdn, Result: result})
+	}
+}
+
+// SetFetchStats sets the function returning the state of the certificate fetch subsystem,
+// called on every scrape. A function set before is replaced.
+func (c *Collector) SetFetchStats(f func() FetchStats) {
+	c.fetchStats.Store(&f)
+}
+
+// collectFetch sends the certificate fetch metrics to Prometheus:
+// - ssl_pinning_fetch_duration_seconds: duration of certificate fetches per FQDN and result (histogram)
+// - ssl_pinning_domains, ssl_pinning_domains_failing: monitored and failing domains (gauge)
+// - ssl_pinning_workers, ssl_pinning_workers_busy: running and busy fetch workers (gauge)
+func (c *Collector) collectFetch(ch chan<- prometheus.Metric) {
+	if f := c.fetchStats.Load(); f != nil {
+		stats := (*f)()
+
+		ch <- prometheus.MustNewConstMetric(domainsDesc, prometheus.GaugeValue, float64(stats.Domains))
+		ch <- prometheus.MustNewConstMetric(domainsFailingDesc, prometheus.GaugeValue, float64(stats.DomainsFailing))
+		ch <- prometheus.MustNewConstMetric(workersDesc, prometheus.GaugeValue, float64(stats.Workers))
+		ch <- prometheus.MustNewConstMetric(workersBusyDesc, prometheus.GaugeValue, float64(stats.WorkersBusy))
+	}
+
+	c.fetchDurations.Range(func(k, v any) bool {
+		item := k.(fetchItem)
+		stats := v.(*fetchStats)
+
+		stats.mu.Lock()
+		buckets := make(map[float64]uint64, len(fetchBuckets))
+		cumulative := uint64(0)
+		for i, le := range fetchBuckets {
+			cumulative += stats.buckets[i]
+			buckets[le] = cumulative
+		}
+		count, sum := stats.count, stats.sum
+		stats.mu.Unlock()
+
+		ch <- prometheus.MustNewConstHistogram(
+			fetchDurationDesc,
+			count,
+			sum,
+			buckets,
+			item.FQDN,
+			item.Result,
+		)
+		return true
+	})
+}
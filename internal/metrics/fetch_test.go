@@ -0,0 +1,102 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_ObserveFetch(t *testing.T) {
+	c := new(Collector)
+
+	c.ObserveFetch("example.com", FetchSuccess, 20*time.Millisecond)
+	c.ObserveFetch("example.com", FetchSuccess, 3*time.Second)
+	c.ObserveFetch("example.com", FetchError, time.Minute)
+	c.ObserveFetch("example.org", FetchSuccess, time.Millisecond)
+
+	collect := func() map[fetchItem]*dto.Histogram {
+		ch := make(chan prometheus.Metric, 10)
+		go func() {
+			c.collectFetch(ch)
+			close(ch)
+		}()
+
+		histograms := make(map[fetchItem]*dto.Histogram)
+		for m := range ch {
+			var metric dto.Metric
+			require.NoError(t, m.Write(&metric))
+
+			labels := make(map[string]string)
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			histograms[fetchItem{FQDN: labels["fqdn"], Result: labels["result"]}] = metric.GetHistogram()
+		}
+
+		return histograms
+	}
+
+	histograms := collect()
+	require.Len(t, histograms, 3)
+
+	success := histograms[fetchItem{FQDN: "example.com", Result: FetchSuccess}]
+	require.NotNil(t, success)
+	assert.Equal(t, uint64(2), success.GetSampleCount())
+	assert.InDelta(t, 3.02, success.GetSampleSum(), 1e-9)
+
+	for _, b := range success.GetBucket() {
+		switch b.GetUpperBound() {
+		case 0.025:
+			assert.Equal(t, uint64(1), b.GetCumulativeCount())
+		case 5:
+			assert.Equal(t, uint64(2), b.GetCumulativeCount())
+		}
+	}
+
+	// a fetch beyond the largest bucket is only counted in +Inf
+	failed := histograms[fetchItem{FQDN: "example.com", Result: FetchError}]
+	require.NotNil(t, failed)
+	assert.Equal(t, uint64(1), failed.GetSampleCount())
+	assert.Equal(t, uint64(0), failed.GetBucket()[len(failed.GetBucket())-1].GetCumulativeCount())
+
+	c.ClearFetchDurations("example.com")
+
+	histograms = collect()
+	assert.Len(t, histograms, 1)
+	assert.Contains(t, histograms, fetchItem{FQDN: "example.org", Result: FetchSuccess})
+}
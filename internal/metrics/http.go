@@ -33,19 +33,180 @@ package metrics
 
 import (
 	"fmt"
+	"html"
 	"log/slog"
 	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptors of the HTTP request metrics.
+var (
+	httpRequestsDesc = prometheus.NewDesc(
+		"ssl_pinning_http_requests_total",
+		"Number of HTTP requests served",
+		[]string{"server", "route", "method", "status"},
+		nil,
+	)
+	httpRequestDurationDesc = prometheus.NewDesc(
+		"ssl_pinning_http_request_duration_seconds",
+		"Duration of HTTP requests in seconds",
+		[]string{"server", "route", "method"},
+		nil,
+	)
+	httpRequestsInFlightDesc = prometheus.NewDesc(
+		"ssl_pinning_http_requests_in_flight",
+		"Number of HTTP requests being served",
+		[]string{"server"},
+		nil,
+	)
 )
 
+// HTTPRoute is a composite key for HTTP request duration metrics.
+// It combines the name of the server, the route pattern and the request method.
+type HTTPRoute struct {
+	Server string
+	Route  string
+	Method string
+}
+
+// httpItem is a composite key for HTTP request counters: the route and the response status.
+type httpItem struct {
+	HTTPRoute
+	Status int
+}
+
+// httpStats accumulates the latency histogram of a route.
+type httpStats struct {
+	mu      sync.Mutex
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// httpBuckets are the upper bounds of the HTTP request latency histogram in seconds.
+var httpBuckets = prometheus.DefBuckets
+
+// HTTPObserver records the requests of a named server in a Collector.
+// It implements server.RequestObserver.
+type HTTPObserver struct {
+	collector *Collector
+	server    string
+}
+
+// HTTPObserver returns the observer of the requests of the server named server, e.g. "api".
+func (c *Collector) HTTPObserver(server string) HTTPObserver {
+	return HTTPObserver{collector: c, server: server}
+}
+
+// AddInFlight adds delta to the number of requests being served by the server.
+func (o HTTPObserver) AddInFlight(delta int64) {
+	v, _ := o.collector.httpInFlight.LoadOrStore(o.server, new(atomic.Int64))
+	v.(*atomic.Int64).Add(delta)
+}
+
+// ObserveRequest records the status and duration of a request served by route.
+func (o HTTPObserver) ObserveRequest(route, method string, status int, d time.Duration) {
+	key := HTTPRoute{Server: o.server, Route: route, Method: method}
+
+	n, _ := o.collector.httpRequests.LoadOrStore(httpItem{HTTPRoute: key, Status: status}, new(atomic.Uint64))
+	n.(*atomic.Uint64).Add(1)
+
+	v, _ := o.collector.httpDurations.LoadOrStore(key, &httpStats{buckets: make([]uint64, len(httpBuckets))})
+	stats := v.(*httpStats)
+
+	seconds := d.Seconds()
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.count++
+	stats.sum += seconds
+
+	if i := sort.SearchFloat64s(httpBuckets, seconds); i < len(httpBuckets) {
+		stats.buckets[i]++
+	}
+}
+
+// collectHTTP sends the HTTP request metrics to Prometheus:
+// - ssl_pinning_http_requests_total: number of requests per server, route, method and status (counter)
+// - ssl_pinning_http_request_duration_seconds: latency of requests per server, route and method (histogram)
+// - ssl_pinning_http_requests_in_flight: number of requests being served per server (gauge)
+func (c *Collector) collectHTTP(ch chan<- prometheus.Metric) {
+	c.httpRequests.Range(func(k, v any) bool {
+		item := k.(httpItem)
+
+		ch <- prometheus.MustNewConstMetric(
+			httpRequestsDesc,
+			prometheus.CounterValue,
+			float64(v.(*atomic.Uint64).Load()),
+			item.Server,
+			item.Route,
+			item.Method,
+			strconv.Itoa(item.Status),
+		)
+		return true
+	})
+
+	c.httpDurations.Range(func(k, v any) bool {
+		key := k.(HTTPRoute)
+		stats := v.(*httpStats)
+
+		stats.mu.Lock()
+		buckets := make(map[float64]uint64, len(httpBuckets))
+		cumulative := uint64(0)
+		for i, le := range httpBuckets {
+			cumulative += stats.buckets[i]
+			buckets[le] = cumulative
+		}
+		count, sum := stats.count, stats.sum
+		stats.mu.Unlock()
+
+		ch <- prometheus.MustNewConstHistogram(
+			httpRequestDurationDesc,
+			count,
+			sum,
+			buckets,
+			key.Server,
+			key.Route,
+			key.Method,
+		)
+		return true
+	})
+
+	c.httpInFlight.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			httpRequestsInFlightDesc,
+			prometheus.GaugeValue,
+			float64(v.(*atomic.Int64).Load()),
+			k.(string),
+		)
+		return true
+	})
+}
+
 // Root handles the root HTTP endpoint for the metrics server.
 // It returns an HTML page with a link to the Prometheus metrics endpoint (/metrics).
 // Used as the default landing page for the metrics server.
 func Root(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
+	Landing("/metrics")(w, r)
+}
+
+// Landing returns the handler of the root HTTP endpoint of a metrics server serving the
+// Prometheus metrics endpoint at path, e.g. below a path prefix. See Root.
+func Landing(path string) http.HandlerFunc {
+	page := fmt.Sprintf("<h1>Metrics</h1><br><a href='%s'>Metrics</a>", html.EscapeString(path))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
 
-	if _, err := fmt.Fprintln(w,
-		"<h1>Metrics</h1><br><a href='/metrics'>Metrics</a>",
-	); err != nil {
-		slog.Error("failed to write response", "err", err)
+		if _, err := fmt.Fprintln(w, page); err != nil {
+			slog.Error("failed to write response", "err", err)
+		}
 	}
 }
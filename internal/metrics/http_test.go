@@ -36,6 +36,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRoot(t *testing.T) {
@@ -131,6 +137,19 @@ func TestRoot(t *testing.T) {
 	}
 }
 
+func TestLanding(t *testing.T) {
+	w := httptest.NewRecorder()
+	Landing("/ssl-pinning/metrics")(w, httptest.NewRequest(http.MethodGet, "/ssl-pinning/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Landing() status = %v, want %v", w.Code, http.StatusOK)
+	}
+
+	if want := "<a href='/ssl-pinning/metrics'>Metrics</a>"; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("Landing() body does not contain %q, got: %v", want, w.Body.String())
+	}
+}
+
 func TestRoot_ContentType(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	w := httptest.NewRecorder()
@@ -271,3 +290,47 @@ func BenchmarkRoot_Parallel(b *testing.B) {
 		}
 	})
 }
+
+func TestCollector_HTTPObserver(t *testing.T) {
+	c := new(Collector)
+	o := c.HTTPObserver("api")
+
+	o.AddInFlight(1)
+	o.ObserveRequest("/api/v1/{file}", "GET", 200, 3*time.Millisecond)
+	o.ObserveRequest("/api/v1/{file}", "GET", 200, time.Minute)
+	o.ObserveRequest("/api/v1/{file}", "GET", 304, time.Millisecond)
+
+	ch := make(chan prometheus.Metric, 10)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	counters := make(map[string]float64)
+	var histogram *dto.Histogram
+	var inFlight float64
+
+	for m := range ch {
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+
+		labels := make(map[string]string)
+		for _, l := range metric.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+
+		switch {
+		case metric.Histogram != nil:
+			histogram = metric.GetHistogram()
+		case metric.Gauge != nil:
+			inFlight = metric.GetGauge().GetValue()
+		case labels["status"] != "":
+			counters[labels["status"]] = metric.GetCounter().GetValue()
+		}
+	}
+
+	assert.Equal(t, map[string]float64{"200": 2, "304": 1}, counters)
+	require.NotNil(t, histogram)
+	assert.Equal(t, uint64(3), histogram.GetSampleCount())
+	assert.Equal(t, 1.0, inFlight)
+}
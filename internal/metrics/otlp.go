@@ -0,0 +1,113 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	otelprom "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"ssl-pinning/internal/version"
+)
+
+// serviceName is reported as the service.name resource attribute of exported metrics.
+const serviceName = "ssl-pinning"
+
+// OTLP configures the export of the Prometheus metrics via OTLP/HTTP (see SetupOTLP).
+// Endpoint is the collector URL (e.g. http://localhost:4318); when empty the standard
+// OTEL_EXPORTER_OTLP_* environment variables are used. Interval is the export interval.
+type OTLP struct {
+	Endpoint string
+	Insecure bool
+	Interval time.Duration
+}
+
+// SetupOTLP exports the metrics of gatherer, usually prometheus.DefaultGatherer, every
+// opts.Interval via OTLP/HTTP, so the same metrics as on the Prometheus endpoint reach
+// collectors without a Prometheus scraper.
+// Returns a function that exports pending metrics and stops the export.
+func SetupOTLP(ctx context.Context, gatherer prometheus.Gatherer, opts OTLP) (func(context.Context) error, error) {
+	exporterOpts := make([]otlpmetrichttp.Option, 0, 2)
+
+	if opts.Endpoint != "" {
+		u, err := url.Parse(opts.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid otlp metrics endpoint: %q", opts.Endpoint)
+		}
+
+		exporterOpts = append(exporterOpts, otlpmetrichttp.WithEndpointURL(opts.Endpoint))
+	}
+
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlpmetrichttp.WithInsecure())
+	}
+
+	exporter, err := otlpmetrichttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp metrics exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", version.GetVersion()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics resource: %w", err)
+	}
+
+	readerOpts := []sdkmetric.PeriodicReaderOption{
+		sdkmetric.WithProducer(otelprom.NewMetricProducer(otelprom.WithGatherer(gatherer))),
+	}
+
+	if opts.Interval > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithInterval(opts.Interval))
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, readerOpts...)),
+		sdkmetric.WithResource(res),
+	)
+
+	slog.Info("otlp metrics export enabled", "endpoint", opts.Endpoint, "interval", opts.Interval)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+func TestSetupOTLP(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	var exports atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/metrics" && len(body) > 0 {
+			exports.Add(1)
+		}
+	}))
+	defer collector.Close()
+
+	reg := prometheus.NewRegistry()
+	c := new(Collector)
+	c.IncPanic("api")
+	require.NoError(t, reg.Register(c))
+
+	shutdown, err := SetupOTLP(context.Background(), reg, OTLP{
+		Endpoint: collector.URL,
+		Insecure: true,
+		Interval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return exports.Load() > 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestSetupOTLP_InvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"://invalid", "localhost:4318", "ftp://localhost:4318"} {
+		_, err := SetupOTLP(context.Background(), prometheus.NewRegistry(), OTLP{Endpoint: endpoint})
+		assert.Error(t, err, endpoint)
+	}
+}
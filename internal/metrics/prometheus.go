@@ -32,11 +32,80 @@ POSSIBILITY OF SUCH DAMAGE.
 package metrics
 
 import (
+	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"ssl-pinning/internal/version"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Descriptors of the pinning metrics, declared once so Describe and Collect share them.
+var (
+	expireDesc = prometheus.NewDesc(
+		"ssl_pinning_expire",
+		"Certificate expiration timestamp or seconds until expiry",
+		[]string{"key", "fqdn"},
+		nil,
+	)
+	certNotAfterDesc = prometheus.NewDesc(
+		"ssl_pinning_cert_not_after_timestamp_seconds",
+		"Expiration of the certificate as Unix timestamp in seconds",
+		[]string{"fqdn"},
+		nil,
+	)
+	certDaysRemainingDesc = prometheus.NewDesc(
+		"ssl_pinning_cert_days_remaining",
+		"Days until the certificate expires at the time of the scrape",
+		[]string{"fqdn"},
+		nil,
+	)
+	expiryThresholdDesc = prometheus.NewDesc(
+		"ssl_pinning_expiry_threshold",
+		"Lowest expiry threshold in days crossed by the certificate, 0 if none",
+		[]string{"fqdn"},
+		nil,
+	)
+	domainErrorDesc = prometheus.NewDesc(
+		"ssl_pinning_domain_error",
+		"Whether the latest certificate fetch of the domain failed, by error category",
+		[]string{"fqdn", "category"},
+		nil,
+	)
+	pinMismatchDesc = prometheus.NewDesc(
+		"ssl_pinning_pin_mismatch",
+		"Whether the addresses of the domain serve different certificates",
+		[]string{"fqdn"},
+		nil,
+	)
+	pinRotationsDesc = prometheus.NewDesc(
+		"ssl_pinning_pin_rotations_total",
+		"Number of changes of the pin of the domain between certificate fetches",
+		[]string{"fqdn"},
+		nil,
+	)
+	ocspStatusDesc = prometheus.NewDesc(
+		"ssl_pinning_ocsp_status",
+		"OCSP status of the certificate: 0 good, 1 revoked, 2 unknown",
+		[]string{"fqdn"},
+		nil,
+	)
+	sctsDesc = prometheus.NewDesc(
+		"ssl_pinning_ct_scts",
+		"Number of known CT logs with a valid SCT of the certificate",
+		[]string{"fqdn"},
+		nil,
+	)
+	buildInfoDesc = prometheus.NewDesc(
+		"ssl_pinning_build_info",
+		"Build information of the binary, always 1",
+		[]string{"version", "commit", "go_version"},
+		nil,
+	)
+)
+
 // ExpireItem is a composite key for certificate expiration metrics.
 // It combines the certificate hash key and fully qualified domain name (FQDN)
 // to uniquely identify a certificate expiration metric in Prometheus.
@@ -46,84 +115,233 @@ type ExpireItem struct {
 }
 
 // Collector is a Prometheus collector that tracks SSL pinning metrics.
-// It maintains counters for validation errors per file and certificate expiration times per domain.
+// It maintains certificate expiration times and timestamps, crossed expiry thresholds, fetch durations
+// and the errors of the latest fetch per domain, pin mismatches and rotations,
+// OCSP statuses and SCTs per domain, latency and error statistics of storage operations and keys
+// written per backend, flushes of the keys, signings of files, lookups of the payload cache,
+// requests to the public API per API key, requests served by the HTTP servers and panics they recovered.
 // Implements prometheus.Collector interface for custom metrics collection.
 type Collector struct {
-	errors  sync.Map
-	expires sync.Map
+	apiKeyRejections   atomic.Uint64
+	apiKeys            sync.Map
+	expires            sync.Map
+	expiry             sync.Map
+	domainErrors       sync.Map
+	fetchDurations     sync.Map
+	fetchStats         atomic.Pointer[func() FetchStats]
+	flushErrors        atomic.Uint64
+	httpDurations      sync.Map
+	httpInFlight       sync.Map
+	httpRequests       sync.Map
+	lastFlush          atomic.Int64
+	notAfter           sync.Map
+	ocsp               sync.Map
+	panics             sync.Map
+	payloadCacheHits   atomic.Uint64
+	payloadCacheMisses atomic.Uint64
+	pins               sync.Map
+	rotations          sync.Map
+	scts               sync.Map
+	signatures         sync.Map
+	storage            sync.Map
+	storageWrites      sync.Map
 }
 
 // NewCollector creates and registers a new Collector instance with Prometheus.
-// The collector tracks SSL pinning errors and certificate expiration times.
+// The collector tracks fetch errors and certificate expiration times of the domains.
+// A Collector registered before is replaced, as both describe the same metrics.
 // Panics if registration with Prometheus fails.
 func NewCollector() *Collector {
 	c := new(Collector)
-	// c.errors = sync.Map{}
 	// c.expires = sync.Map{}
-	prometheus.MustRegister(c)
+
+	var registered prometheus.AlreadyRegisteredError
+	if err := prometheus.Register(c); errors.As(err, &registered) {
+		prometheus.Unregister(registered.ExistingCollector)
+		prometheus.MustRegister(c)
+	} else if err != nil {
+		panic(err)
+	}
+
 	return c
 }
 
 // Describe implements prometheus.Collector interface.
-// Returns an empty description as metrics are dynamically generated during collection.
-func (c *Collector) Describe(ch chan<- *prometheus.Desc) {}
+// Sends the descriptors of all metrics the collector may send from Collect.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		expireDesc,
+		certNotAfterDesc,
+		certDaysRemainingDesc,
+		expiryThresholdDesc,
+		domainErrorDesc,
+		fetchDurationDesc,
+		domainsDesc,
+		domainsFailingDesc,
+		workersDesc,
+		workersBusyDesc,
+		pinMismatchDesc,
+		pinRotationsDesc,
+		ocspStatusDesc,
+		sctsDesc,
+		storageDurationDesc,
+		storageErrorsDesc,
+		storageKeysWrittenDesc,
+		signDurationDesc,
+		signErrorsDesc,
+		payloadCacheHitsDesc,
+		payloadCacheMissesDesc,
+		lastFlushDesc,
+		flushErrorsDesc,
+		apiKeyRequestsDesc,
+		apiKeyRejectionsDesc,
+		httpPanicsDesc,
+		httpRequestsDesc,
+		httpRequestDurationDesc,
+		httpRequestsInFlightDesc,
+		buildInfoDesc,
+	} {
+		ch <- desc
+	}
+}
 
 // Collect implements prometheus.Collector interface.
 // Gathers and sends all SSL pinning metrics to Prometheus:
-// - ssl_pinning_errors: number of validation errors per file (gauge, cleared after collection)
 // - ssl_pinning_expire: certificate expiration time in seconds per key/FQDN (gauge)
+// - ssl_pinning_cert_not_after_timestamp_seconds: certificate expiration as Unix timestamp per FQDN (gauge)
+// - ssl_pinning_cert_days_remaining: days until the certificate expires per FQDN at scrape time (gauge)
+// - ssl_pinning_expiry_threshold: lowest expiry threshold in days crossed by the certificate per FQDN (gauge)
+// - ssl_pinning_domain_error: 1 while the latest certificate fetch of a FQDN failed, by error category (gauge)
+// - certificate fetch durations, domain and worker counts (see collectFetch)
+// - ssl_pinning_pin_mismatch: whether the addresses of a FQDN serve different certificates (gauge)
+// - ssl_pinning_pin_rotations_total: number of changes of the pin per FQDN (counter)
+// - ssl_pinning_ocsp_status: OCSP status of the certificate per FQDN (gauge)
+// - ssl_pinning_ct_scts: number of known CT logs with a valid SCT of the certificate per FQDN (gauge)
+// - storage operation metrics (see collectStorage)
+// - signature metrics (see collectSignatures)
+// - API key metrics (see collectAPIKeys)
+// - recovered panics (see collectPanics)
+// - HTTP request metrics (see collectHTTP)
+// - build information (see collectBuildInfo)
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
-	c.errors.Range(func(k, v any) bool {
-		file := k.(string)
-		val := v.(float64)
+	c.expires.Range(func(k, v any) bool {
+		item := k.(ExpireItem)
+		expire := v.(float64)
+
+		ch <- prometheus.MustNewConstMetric(
+			expireDesc,
+			prometheus.GaugeValue,
+			expire,
+			item.Key,
+			item.FQDN,
+		)
+		return true
+	})
+
+	now := time.Now()
+
+	c.notAfter.Range(func(k, v any) bool {
+		notAfter := v.(time.Time)
+
+		ch <- prometheus.MustNewConstMetric(
+			certNotAfterDesc,
+			prometheus.GaugeValue,
+			float64(notAfter.Unix()),
+			k.(string),
+		)
 
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				"ssl_pinning_errors",
-				"Number of pinning validation errors per file",
-				[]string{"file"},
-				nil,
-			),
+			certDaysRemainingDesc,
 			prometheus.GaugeValue,
-			val,
-			file,
+			notAfter.Sub(now).Hours()/24,
+			k.(string),
 		)
+		return true
+	})
 
-		c.ClearError(file)
+	c.expiry.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			expiryThresholdDesc,
+			prometheus.GaugeValue,
+			v.(float64),
+			k.(string),
+		)
 		return true
 	})
 
-	c.expires.Range(func(k, v any) bool {
-		item := k.(ExpireItem)
-		expire := v.(float64)
+	c.domainErrors.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			domainErrorDesc,
+			prometheus.GaugeValue,
+			1,
+			k.(string),
+			v.(string),
+		)
+		return true
+	})
 
+	c.pins.Range(func(k, v any) bool {
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				"ssl_pinning_expire",
-				"Certificate expiration timestamp or seconds until expiry",
-				[]string{"key", "fqdn"},
-				nil,
-			),
+			pinMismatchDesc,
 			prometheus.GaugeValue,
-			expire,
-			item.Key,
-			item.FQDN,
+			v.(float64),
+			k.(string),
 		)
 		return true
 	})
-}
 
-// IncError increments the error counter for a specific file.
-// Used to track failed SSL certificate validation attempts.
-func (c *Collector) IncError(file string) {
-	val, _ := c.errors.LoadOrStore(file, 0.0)
-	c.errors.Store(file, val.(float64)+1)
+	c.rotations.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			pinRotationsDesc,
+			prometheus.CounterValue,
+			v.(float64),
+			k.(string),
+		)
+		return true
+	})
+
+	c.ocsp.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			ocspStatusDesc,
+			prometheus.GaugeValue,
+			v.(float64),
+			k.(string),
+		)
+		return true
+	})
+
+	c.scts.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			sctsDesc,
+			prometheus.GaugeValue,
+			v.(float64),
+			k.(string),
+		)
+		return true
+	})
+
+	c.collectFetch(ch)
+	c.collectStorage(ch)
+	c.collectSignatures(ch)
+	c.collectAPIKeys(ch)
+	c.collectPanics(ch)
+	c.collectHTTP(ch)
+	collectBuildInfo(ch)
 }
 
-// ClearError resets the error counter for a specific file to zero.
-// Automatically called after metrics collection to prevent error accumulation.
-func (c *Collector) ClearError(file string) {
-	c.errors.Store(file, 0.0)
+// collectBuildInfo sends ssl_pinning_build_info, a gauge of 1 labeled with the version, git commit
+// and Go version of the binary, e.g. to find outdated binaries with count by (version) (ssl_pinning_build_info).
+func collectBuildInfo(ch chan<- prometheus.Metric) {
+	info := version.Get()
+
+	ch <- prometheus.MustNewConstMetric(
+		buildInfoDesc,
+		prometheus.GaugeValue,
+		1,
+		info.Version,
+		info.GitCommit,
+		info.GoVersion,
+	)
 }
 
 // SetExpire updates the certificate expiration metric for a specific key and FQDN.
@@ -137,3 +355,85 @@ func (c *Collector) SetExpire(key, fqdn string, expire float64) {
 func (c *Collector) ClearExpire(key, fqdn string) {
 	c.expires.Delete(ExpireItem{Key: key, FQDN: fqdn})
 }
+
+// SetExpiryThreshold updates the metric of the lowest expiry threshold in days crossed by the
+// certificate of a FQDN, 0 if it crossed none.
+func (c *Collector) SetExpiryThreshold(fqdn string, days float64) {
+	c.expiry.Store(fqdn, days)
+}
+
+// ClearExpiryThreshold removes the expiry threshold metric of a FQDN.
+// Used when a domain is removed from monitoring.
+func (c *Collector) ClearExpiryThreshold(fqdn string) {
+	c.expiry.Delete(fqdn)
+}
+
+// SetNotAfter records the expiration of the certificate of a FQDN.
+func (c *Collector) SetNotAfter(fqdn string, notAfter time.Time) {
+	c.notAfter.Store(fqdn, notAfter)
+}
+
+// ClearNotAfter removes the certificate expiration metrics of a FQDN.
+// Used when a domain is removed from monitoring.
+func (c *Collector) ClearNotAfter(fqdn string) {
+	c.notAfter.Delete(fqdn)
+}
+
+// SetDomainError records the error category of the failed latest certificate fetch of a FQDN.
+func (c *Collector) SetDomainError(fqdn, category string) {
+	c.domainErrors.Store(fqdn, category)
+}
+
+// ClearDomainError removes the domain error metric of a FQDN.
+// Used when a fetch succeeds or a domain is removed from monitoring.
+func (c *Collector) ClearDomainError(fqdn string) {
+	c.domainErrors.Delete(fqdn)
+}
+
+// SetPinMismatch updates the metric of whether the addresses of a FQDN serve different
+// certificates: 1 if they do, 0 otherwise.
+func (c *Collector) SetPinMismatch(fqdn string, mismatch float64) {
+	c.pins.Store(fqdn, mismatch)
+}
+
+// ClearPinMismatch removes the pin mismatch metric of a FQDN.
+// Used when a domain is removed from monitoring.
+func (c *Collector) ClearPinMismatch(fqdn string) {
+	c.pins.Delete(fqdn)
+}
+
+// IncPinRotation increments the counter of changes of the pin of a FQDN.
+func (c *Collector) IncPinRotation(fqdn string) {
+	val, _ := c.rotations.LoadOrStore(fqdn, 0.0)
+	c.rotations.Store(fqdn, val.(float64)+1)
+}
+
+// ClearPinRotations removes the pin rotation counter of a FQDN.
+// Used when a domain is removed from monitoring.
+func (c *Collector) ClearPinRotations(fqdn string) {
+	c.rotations.Delete(fqdn)
+}
+
+// SetOCSPStatus updates the OCSP status metric of the certificate of a FQDN.
+// The status is 0 for good, 1 for revoked and 2 for unknown certificates (RFC 6960).
+func (c *Collector) SetOCSPStatus(fqdn string, status float64) {
+	c.ocsp.Store(fqdn, status)
+}
+
+// ClearOCSPStatus removes the OCSP status metric of a FQDN.
+// Used when a domain is removed from monitoring.
+func (c *Collector) ClearOCSPStatus(fqdn string) {
+	c.ocsp.Delete(fqdn)
+}
+
+// SetSCTs updates the metric of the number of known CT logs with a valid SCT of the
+// certificate of a FQDN.
+func (c *Collector) SetSCTs(fqdn string, n float64) {
+	c.scts.Store(fqdn, n)
+}
+
+// ClearSCTs removes the SCT metric of a FQDN.
+// Used when a domain is removed from monitoring.
+func (c *Collector) ClearSCTs(fqdn string) {
+	c.scts.Delete(fqdn)
+}
@@ -32,10 +32,20 @@ POSSIBILITY OF SUCH DAMAGE.
 package metrics
 
 import (
+	"errors"
+	"net/http"
+	"runtime"
+	"slices"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/client_golang/prometheus/testutil/promlint"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewCollector(t *testing.T) {
@@ -55,107 +65,13 @@ func TestNewCollector(t *testing.T) {
 	prometheus.Unregister(c)
 }
 
-func TestCollector_IncError(t *testing.T) {
-	tests := []struct {
-		name      string
-		file      string
-		incCount  int
-		wantValue float64
-	}{
-		{
-			name:      "increment once",
-			file:      "test1.json",
-			incCount:  1,
-			wantValue: 1.0,
-		},
-		{
-			name:      "increment multiple times",
-			file:      "test2.json",
-			incCount:  5,
-			wantValue: 5.0,
-		},
-		{
-			name:      "increment zero times",
-			file:      "test3.json",
-			incCount:  0,
-			wantValue: 0.0,
-		},
-		{
-			name:      "increment same file multiple times",
-			file:      "test4.json",
-			incCount:  10,
-			wantValue: 10.0,
-		},
-	}
+func TestNewCollector_Replace(t *testing.T) {
+	NewCollector()
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			c := new(Collector)
-
-			for i := 0; i < tt.incCount; i++ {
-				c.IncError(tt.file)
-			}
-
-			val, ok := c.errors.Load(tt.file)
-			if tt.incCount > 0 && !ok {
-				t.Error("IncError() did not store value")
-				return
-			}
-
-			if tt.incCount > 0 {
-				if got := val.(float64); got != tt.wantValue {
-					t.Errorf("IncError() value = %v, want %v", got, tt.wantValue)
-				}
-			}
-		})
-	}
-}
-
-func TestCollector_ClearError(t *testing.T) {
-	tests := []struct {
-		name      string
-		file      string
-		initValue float64
-	}{
-		{
-			name:      "clear zero value",
-			file:      "test1.json",
-			initValue: 0.0,
-		},
-		{
-			name:      "clear non-zero value",
-			file:      "test2.json",
-			initValue: 5.0,
-		},
-		{
-			name:      "clear large value",
-			file:      "test3.json",
-			initValue: 100.0,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			c := new(Collector)
-
-			// Set initial value
-			c.errors.Store(tt.file, tt.initValue)
-
-			// Clear the error
-			c.ClearError(tt.file)
-
-			// Verify it's set to 0.0
-			val, ok := c.errors.Load(tt.file)
-			if !ok {
-				t.Error("ClearError() removed the entry instead of setting to 0")
-				return
-			}
+	assert.NotPanics(t, func() { NewCollector().IncPanic("api") })
 
-			if got := val.(float64); got != 0.0 {
-				t.Errorf("ClearError() value = %v, want 0.0", got)
-			}
-		})
-	}
+	_, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
 }
 
 func TestCollector_SetExpire(t *testing.T) {
@@ -252,15 +168,162 @@ func TestCollector_ClearExpire(t *testing.T) {
 	}
 }
 
+func TestCollector_OCSPStatus(t *testing.T) {
+	c := new(Collector)
+
+	c.SetOCSPStatus("example.com", 1)
+
+	val, ok := c.ocsp.Load("example.com")
+	if !ok || val.(float64) != 1 {
+		t.Errorf("SetOCSPStatus() stored %v, want 1", val)
+	}
+
+	c.SetOCSPStatus("example.com", 0)
+
+	if val, _ := c.ocsp.Load("example.com"); val.(float64) != 0 {
+		t.Errorf("SetOCSPStatus() stored %v, want 0", val)
+	}
+
+	c.ClearOCSPStatus("example.com")
+
+	if _, ok := c.ocsp.Load("example.com"); ok {
+		t.Error("ClearOCSPStatus() did not delete the entry")
+	}
+}
+
+func TestCollector_ExpiryThreshold(t *testing.T) {
+	c := new(Collector)
+
+	c.SetExpiryThreshold("example.com", 14)
+
+	val, ok := c.expiry.Load("example.com")
+	if !ok || val.(float64) != 14 {
+		t.Errorf("SetExpiryThreshold() stored %v, want 14", val)
+	}
+
+	c.ClearExpiryThreshold("example.com")
+
+	if _, ok := c.expiry.Load("example.com"); ok {
+		t.Error("ClearExpiryThreshold() did not delete the entry")
+	}
+}
+
+func TestCollector_PinRotations(t *testing.T) {
+	c := new(Collector)
+
+	c.IncPinRotation("example.com")
+	c.IncPinRotation("example.com")
+
+	val, ok := c.rotations.Load("example.com")
+	if !ok || val.(float64) != 2 {
+		t.Errorf("IncPinRotation() stored %v, want 2", val)
+	}
+
+	c.ClearPinRotations("example.com")
+
+	if _, ok := c.rotations.Load("example.com"); ok {
+		t.Error("ClearPinRotations() did not delete the entry")
+	}
+}
+
+func TestCollector_DomainError(t *testing.T) {
+	c := new(Collector)
+
+	c.SetDomainError("example.com", "dns")
+	c.SetDomainError("example.com", "timeout")
+
+	val, ok := c.domainErrors.Load("example.com")
+	if !ok || val.(string) != "timeout" {
+		t.Errorf("SetDomainError() stored %v, want timeout", val)
+	}
+
+	c.ClearDomainError("example.com")
+
+	if _, ok := c.domainErrors.Load("example.com"); ok {
+		t.Error("ClearDomainError() did not delete the entry")
+	}
+}
+
+func TestCollector_PinMismatch(t *testing.T) {
+	c := new(Collector)
+
+	c.SetPinMismatch("example.com", 1)
+
+	val, ok := c.pins.Load("example.com")
+	if !ok || val.(float64) != 1 {
+		t.Errorf("SetPinMismatch() stored %v, want 1", val)
+	}
+
+	c.ClearPinMismatch("example.com")
+
+	if _, ok := c.pins.Load("example.com"); ok {
+		t.Error("ClearPinMismatch() did not delete the entry")
+	}
+}
+
+func TestCollector_SCTs(t *testing.T) {
+	c := new(Collector)
+
+	c.SetSCTs("example.com", 2)
+
+	val, ok := c.scts.Load("example.com")
+	if !ok || val.(float64) != 2 {
+		t.Errorf("SetSCTs() stored %v, want 2", val)
+	}
+
+	c.ClearSCTs("example.com")
+
+	if _, ok := c.scts.Load("example.com"); ok {
+		t.Error("ClearSCTs() did not delete the entry")
+	}
+}
+
+func TestCollector_NotAfter(t *testing.T) {
+	c := new(Collector)
+
+	notAfter := time.Now().Add(36 * time.Hour).Truncate(time.Second)
+	c.SetNotAfter("example.com", notAfter)
+
+	ch := make(chan prometheus.Metric, 32)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	values := make(map[string]float64)
+	for m := range ch {
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+
+		switch m.Desc() {
+		case certNotAfterDesc, certDaysRemainingDesc:
+			assert.Equal(t, "example.com", metric.GetLabel()[0].GetValue())
+			values[m.Desc().String()] = metric.GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, float64(notAfter.Unix()), values[certNotAfterDesc.String()])
+	assert.InDelta(t, 1.5, values[certDaysRemainingDesc.String()], 0.01)
+
+	c.ClearNotAfter("example.com")
+
+	if _, ok := c.notAfter.Load("example.com"); ok {
+		t.Error("ClearNotAfter() did not delete the entry")
+	}
+}
+
 func TestCollector_Collect(t *testing.T) {
 	c := new(Collector)
 
 	// Add some test data
-	c.IncError("test1.json")
-	c.IncError("test1.json")
-	c.IncError("test2.json")
 	c.SetExpire("key1", "example.com", 3600.0)
 	c.SetExpire("key2", "test.com", 1800.0)
+	c.SetOCSPStatus("example.com", 0)
+	c.SetSCTs("example.com", 2)
+	c.SetDomainError("test.com", "dns")
+	c.SetPinMismatch("test.com", 0)
+	c.SetExpiryThreshold("test.com", 30)
+	c.IncPinRotation("test.com")
 
 	// Collect metrics
 	ch := make(chan prometheus.Metric, 10)
@@ -283,26 +346,105 @@ func TestCollector_Collect(t *testing.T) {
 	}
 }
 
+func TestCollector_BuildInfo(t *testing.T) {
+	ch := make(chan prometheus.Metric, 1)
+	collectBuildInfo(ch)
+
+	var metric dto.Metric
+	if err := (<-ch).Write(&metric); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if metric.GetGauge().GetValue() != 1 {
+		t.Errorf("ssl_pinning_build_info = %v, want 1", metric.GetGauge().GetValue())
+	}
+
+	labels := make(map[string]string)
+	for _, l := range metric.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	if labels["go_version"] != runtime.Version() {
+		t.Errorf("ssl_pinning_build_info go_version = %q, want %q", labels["go_version"], runtime.Version())
+	}
+
+	for _, name := range []string{"version", "commit"} {
+		if _, ok := labels[name]; !ok {
+			t.Errorf("ssl_pinning_build_info lacks the %s label", name)
+		}
+	}
+}
+
 func TestCollector_Describe(t *testing.T) {
 	c := new(Collector)
 
-	ch := make(chan *prometheus.Desc, 10)
+	ch := make(chan *prometheus.Desc, 32)
 	go func() {
 		c.Describe(ch)
 		close(ch)
 	}()
 
-	// Describe should send nothing (empty implementation)
-	count := 0
-	for range ch {
-		count++
+	names := make(map[string]bool)
+	for desc := range ch {
+		names[desc.String()] = true
 	}
 
-	if count != 0 {
-		t.Errorf("Describe() sent %d descriptions, want 0", count)
+	if len(names) != 30 {
+		t.Errorf("Describe() sent %d distinct descriptions, want 30", len(names))
 	}
 }
 
+// populatedCollector returns a collector with a value of every metric.
+func populatedCollector() *Collector {
+	c := new(Collector)
+
+	c.SetExpire("key1", "example.com", 3600)
+	c.SetNotAfter("example.com", time.Now().Add(time.Hour))
+	c.SetExpiryThreshold("example.com", 14)
+	c.SetDomainError("example.com", "dns")
+	c.ObserveFetch("example.com", FetchSuccess, time.Second)
+	c.SetFetchStats(func() FetchStats { return FetchStats{Domains: 2, DomainsFailing: 1, Workers: 16, WorkersBusy: 1} })
+	c.SetPinMismatch("example.com", 1)
+	c.IncPinRotation("example.com")
+	c.SetOCSPStatus("example.com", 0)
+	c.SetSCTs("example.com", 2)
+	c.ObserveStorage("memory", "get", time.Millisecond, errors.New("failed"))
+	c.AddStorageKeysWritten("memory", 2)
+	c.ObserveFlush(time.Now(), nil)
+	c.ObserveSign("app.json", "ES256", time.Millisecond, nil)
+	c.ObservePayloadCache(true)
+	c.IncAPIKeyRequest("mobile")
+	c.IncAPIKeyRejection()
+	c.IncPanic("api")
+
+	o := c.HTTPObserver("api")
+	o.AddInFlight(1)
+	o.ObserveRequest("/api/v1/{file}", http.MethodGet, http.StatusOK, time.Millisecond)
+
+	return c
+}
+
+func TestCollector_Pedantic(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(populatedCollector()))
+
+	// The pedantic registry fails for metrics collected with an undescribed or inconsistent descriptor.
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	assert.Len(t, families, 30)
+}
+
+func TestCollector_Lint(t *testing.T) {
+	problems, err := testutil.CollectAndLint(populatedCollector())
+	require.NoError(t, err)
+
+	// the days remaining are a convenience gauge next to the timestamp in base units
+	problems = slices.DeleteFunc(problems, func(p promlint.Problem) bool {
+		return p.Metric == "ssl_pinning_cert_days_remaining"
+	})
+	assert.Empty(t, problems)
+}
+
 func TestCollector_ConcurrentAccess(t *testing.T) {
 	c := new(Collector)
 
@@ -311,13 +453,13 @@ func TestCollector_ConcurrentAccess(t *testing.T) {
 
 	var wg sync.WaitGroup
 
-	// Concurrent IncError
+	// Concurrent SetDomainError
 	for i := 0; i < numGoroutines; i++ {
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
 			for j := 0; j < numOperations; j++ {
-				c.IncError("test.json")
+				c.SetDomainError("example.com", "dns")
 			}
 		}(i)
 	}
@@ -333,13 +475,13 @@ func TestCollector_ConcurrentAccess(t *testing.T) {
 		}(i)
 	}
 
-	// Concurrent ClearError
+	// Concurrent ClearDomainError
 	for i := 0; i < numGoroutines; i++ {
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
 			for j := 0; j < numOperations; j++ {
-				c.ClearError("test.json")
+				c.ClearDomainError("example.com")
 			}
 		}(i)
 	}
@@ -386,47 +528,6 @@ func TestExpireItem_AsMapKey(t *testing.T) {
 	}
 }
 
-func TestCollector_ErrorsAfterCollect(t *testing.T) {
-	c := new(Collector)
-
-	// Add errors
-	c.IncError("test.json")
-	c.IncError("test.json")
-	c.IncError("test.json")
-
-	// Verify error count before collect
-	val, _ := c.errors.Load("test.json")
-	if got := val.(float64); got != 3.0 {
-		t.Errorf("Before collect: error count = %v, want 3.0", got)
-	}
-
-	// Collect metrics (should clear errors)
-	ch := make(chan prometheus.Metric, 10)
-	go func() {
-		c.Collect(ch)
-		close(ch)
-	}()
-
-	// Drain channel
-	for range ch {
-	}
-
-	// Verify errors are cleared after collect
-	val, _ = c.errors.Load("test.json")
-	if got := val.(float64); got != 0.0 {
-		t.Errorf("After collect: error count = %v, want 0.0", got)
-	}
-}
-
-func BenchmarkCollector_IncError(b *testing.B) {
-	c := new(Collector)
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		c.IncError("test.json")
-	}
-}
-
 func BenchmarkCollector_SetExpire(b *testing.B) {
 	c := new(Collector)
 
@@ -440,8 +541,7 @@ func BenchmarkCollector_Collect(b *testing.B) {
 	c := new(Collector)
 
 	// Setup test data
-	c.IncError("test1.json")
-	c.IncError("test2.json")
+	c.SetDomainError("example.com", "dns")
 	c.SetExpire("key1", "example.com", 3600.0)
 	c.SetExpire("key2", "test.com", 1800.0)
 
@@ -465,11 +565,11 @@ func BenchmarkCollector_ConcurrentOps(b *testing.B) {
 		for pb.Next() {
 			switch i % 4 {
 			case 0:
-				c.IncError("test.json")
+				c.SetDomainError("example.com", "dns")
 			case 1:
 				c.SetExpire("key", "example.com", 3600.0)
 			case 2:
-				c.ClearError("test.json")
+				c.ClearDomainError("example.com")
 			case 3:
 				c.ClearExpire("key", "example.com")
 			}
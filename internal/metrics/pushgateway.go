@@ -0,0 +1,78 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Pushgateway configures the push of the metrics to a Prometheus Pushgateway (see Push).
+// URL is the Pushgateway URL (e.g. http://pushgateway:9091), Job and Instance group the
+// pushed metrics; when Instance is empty the hostname is used.
+type Pushgateway struct {
+	URL      string
+	Job      string
+	Instance string
+}
+
+// Push replaces the metrics of the job and instance at the Pushgateway with the metrics of
+// gatherer, usually prometheus.DefaultGatherer. It is meant for the final metrics of a run,
+// which would otherwise vanish with the process before they are scraped.
+func Push(ctx context.Context, gatherer prometheus.Gatherer, opts Pushgateway) error {
+	instance := opts.Instance
+	if instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to get hostname for the pushgateway instance: %w", err)
+		}
+
+		instance = hostname
+	}
+
+	err := push.New(opts.URL, opts.Job).
+		Gatherer(gatherer).
+		Grouping("instance", instance).
+		PushContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to pushgateway: %w", err)
+	}
+
+	slog.Info("metrics pushed to pushgateway", "url", opts.URL, "job", opts.Job, "instance", instance)
+
+	return nil
+}
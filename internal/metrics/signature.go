@@ -0,0 +1,159 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptors of the signature metrics.
+var (
+	signDurationDesc = prometheus.NewDesc(
+		"ssl_pinning_sign_duration_seconds",
+		"Duration of signing files in seconds",
+		[]string{"file", "algorithm"},
+		nil,
+	)
+	signErrorsDesc = prometheus.NewDesc(
+		"ssl_pinning_sign_errors_total",
+		"Number of failed signings of files",
+		[]string{"file", "algorithm"},
+		nil,
+	)
+	payloadCacheHitsDesc = prometheus.NewDesc(
+		"ssl_pinning_payload_cache_hits_total",
+		"Number of signed payloads served from the payload cache",
+		nil,
+		nil,
+	)
+	payloadCacheMissesDesc = prometheus.NewDesc(
+		"ssl_pinning_payload_cache_misses_total",
+		"Number of payloads rendered and signed on a miss of the payload cache",
+		nil,
+		nil,
+	)
+)
+
+type signItem struct {
+	File      string
+	Algorithm string
+}
+
+type signStats struct {
+	mu      sync.Mutex
+	buckets []uint64
+	count   uint64
+	errors  uint64
+	sum     float64
+}
+
+var signBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// ObserveSign records the duration and outcome of signing a file with algorithm.
+func (c *Collector) ObserveSign(file, algorithm string, d time.Duration, err error) {
+	v, _ := c.signatures.LoadOrStore(
+		signItem{File: file, Algorithm: algorithm},
+		&signStats{buckets: make([]uint64, len(signBuckets))},
+	)
+	stats := v.(*signStats)
+
+	seconds := d.Seconds()
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.count++
+	stats.sum += seconds
+
+	if err != nil {
+		stats.errors++
+	}
+
+	if i := sort.SearchFloat64s(signBuckets, seconds); i < len(signBuckets) {
+		stats.buckets[i]++
+	}
+}
+
+// ObservePayloadCache counts a lookup of the payload cache, a hit or a miss.
+func (c *Collector) ObservePayloadCache(hit bool) {
+	if hit {
+		c.payloadCacheHits.Add(1)
+	} else {
+		c.payloadCacheMisses.Add(1)
+	}
+}
+
+// collectSignatures sends the signature metrics to Prometheus:
+// - ssl_pinning_sign_duration_seconds: duration of signing per file and algorithm (histogram)
+// - ssl_pinning_sign_errors_total: failed signings per file and algorithm (counter)
+// - ssl_pinning_payload_cache_hits_total, ssl_pinning_payload_cache_misses_total: lookups of the payload cache (counter)
+func (c *Collector) collectSignatures(ch chan<- prometheus.Metric) {
+	c.signatures.Range(func(k, v any) bool {
+		item := k.(signItem)
+		stats := v.(*signStats)
+
+		stats.mu.Lock()
+		buckets := make(map[float64]uint64, len(signBuckets))
+		cumulative := uint64(0)
+		for i, le := range signBuckets {
+			cumulative += stats.buckets[i]
+			buckets[le] = cumulative
+		}
+		count, sum, errors := stats.count, stats.sum, stats.errors
+		stats.mu.Unlock()
+
+		ch <- prometheus.MustNewConstHistogram(
+			signDurationDesc,
+			count,
+			sum,
+			buckets,
+			item.File,
+			item.Algorithm,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			signErrorsDesc,
+			prometheus.CounterValue,
+			float64(errors),
+			item.File,
+			item.Algorithm,
+		)
+		return true
+	})
+
+	ch <- prometheus.MustNewConstMetric(payloadCacheHitsDesc, prometheus.CounterValue, float64(c.payloadCacheHits.Load()))
+	ch <- prometheus.MustNewConstMetric(payloadCacheMissesDesc, prometheus.CounterValue, float64(c.payloadCacheMisses.Load()))
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_ObserveSign(t *testing.T) {
+	c := new(Collector)
+
+	c.ObserveSign("app.json", "ES256", 2*time.Millisecond, nil)
+	c.ObserveSign("app.json", "ES256", 30*time.Millisecond, errors.New("kms unavailable"))
+	c.ObserveSign("app.json", "ES256", 10*time.Second, nil)
+	c.ObservePayloadCache(true)
+	c.ObservePayloadCache(true)
+	c.ObservePayloadCache(false)
+
+	ch := make(chan prometheus.Metric, 10)
+	go func() {
+		c.collectSignatures(ch)
+		close(ch)
+	}()
+
+	var histogram *dto.Histogram
+	counters := make(map[string]float64)
+
+	for m := range ch {
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+
+		switch m.Desc() {
+		case signDurationDesc:
+			histogram = metric.GetHistogram()
+		case signErrorsDesc:
+			counters["errors"] = metric.GetCounter().GetValue()
+		case payloadCacheHitsDesc:
+			counters["hits"] = metric.GetCounter().GetValue()
+		case payloadCacheMissesDesc:
+			counters["misses"] = metric.GetCounter().GetValue()
+		}
+	}
+
+	require.NotNil(t, histogram)
+	assert.Equal(t, uint64(3), histogram.GetSampleCount())
+
+	for _, b := range histogram.GetBucket() {
+		switch b.GetUpperBound() {
+		case .0025:
+			assert.Equal(t, uint64(1), b.GetCumulativeCount())
+		case .05:
+			assert.Equal(t, uint64(2), b.GetCumulativeCount())
+		case 2.5:
+			assert.Equal(t, uint64(2), b.GetCumulativeCount(), "values above the last bucket only count in +Inf")
+		}
+	}
+
+	assert.Equal(t, map[string]float64{"errors": 1, "hits": 2, "misses": 1}, counters)
+}
@@ -0,0 +1,232 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// statsdPacketSize is the maximum size of a StatsD packet, fitting the MTU of common networks.
+const statsdPacketSize = 1432
+
+// statsdTagReplacer replaces the characters separating tags and fields of the DogStatsD format in tag values.
+var statsdTagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+// StatsD configures the push of the pinning metrics to a StatsD server (see SetupStatsD).
+// Addr is the host:port of the server, Prefix is prepended to every metric name and
+// Interval is the push interval.
+type StatsD struct {
+	Addr     string
+	Prefix   string
+	Interval time.Duration
+}
+
+// SetupStatsD pushes the ssl_pinning_* metrics of gatherer, usually prometheus.DefaultGatherer,
+// every opts.Interval to a StatsD server over UDP in the DogStatsD format, so teams monitoring
+// with Datadog get the same metrics as Prometheus. Names lose their ssl_pinning_ prefix in favour
+// of opts.Prefix and labels become tags: gauges are sent as gauges, counters as the increase since
+// the previous push and histograms as the increase of their count and sum.
+// Returns a function that pushes the metrics a last time and stops the push.
+func SetupStatsD(ctx context.Context, gatherer prometheus.Gatherer, opts StatsD) (func(context.Context) error, error) {
+	conn, err := net.Dial("udp", opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to statsd server: %w", err)
+	}
+
+	s := &statsd{
+		conn:     conn,
+		gatherer: gatherer,
+		prefix:   opts.Prefix,
+		previous: make(map[string]float64),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.push(); err != nil {
+					slog.Warn("failed to push metrics to statsd", "addr", opts.Addr, "err", err)
+				}
+			}
+		}
+	}()
+
+	slog.Info("statsd metrics push enabled", "addr", opts.Addr, "prefix", opts.Prefix, "interval", opts.Interval)
+
+	return func(context.Context) error {
+		cancel()
+		<-done
+
+		defer conn.Close()
+
+		return s.push()
+	}, nil
+}
+
+// statsd pushes the gathered metrics to a StatsD server.
+type statsd struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	gatherer prometheus.Gatherer
+	prefix   string
+	previous map[string]float64
+}
+
+// push gathers the metrics and sends them in packets of up to statsdPacketSize bytes.
+func (s *statsd) push() error {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var packet []byte
+
+	for _, line := range s.lines(families) {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdPacketSize {
+			if _, err := s.conn.Write(packet); err != nil {
+				return err
+			}
+
+			packet = packet[:0]
+		}
+
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+
+		packet = append(packet, line...)
+	}
+
+	if len(packet) > 0 {
+		if _, err := s.conn.Write(packet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lines returns the StatsD lines of the ssl_pinning_* metrics of families.
+// The caller must hold s.mu.
+func (s *statsd) lines(families []*dto.MetricFamily) []string {
+	var lines []string
+
+	for _, family := range families {
+		name, ok := strings.CutPrefix(family.GetName(), "ssl_pinning_")
+		if !ok {
+			continue
+		}
+
+		name = s.prefix + name
+
+		for _, m := range family.GetMetric() {
+			tags := statsdTags(m.GetLabel())
+
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				lines = append(lines, statsdLine(name, m.GetGauge().GetValue(), "g", tags))
+			case dto.MetricType_COUNTER:
+				if delta := s.delta(name+tags, m.GetCounter().GetValue()); delta > 0 {
+					lines = append(lines, statsdLine(name, delta, "c", tags))
+				}
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+
+				if delta := s.delta(name+".count"+tags, float64(h.GetSampleCount())); delta > 0 {
+					lines = append(lines, statsdLine(name+".count", delta, "c", tags))
+				}
+
+				if delta := s.delta(name+".sum"+tags, h.GetSampleSum()); delta > 0 {
+					lines = append(lines, statsdLine(name+".sum", delta, "c", tags))
+				}
+			}
+		}
+	}
+
+	return lines
+}
+
+// delta returns the increase of the counter identified by key since the previous push.
+// A counter that decreased, e.g. when a domain was removed and added again, is taken as reset.
+// The caller must hold s.mu.
+func (s *statsd) delta(key string, value float64) float64 {
+	prev, ok := s.previous[key]
+	s.previous[key] = value
+
+	if !ok || value < prev {
+		return value
+	}
+
+	return value - prev
+}
+
+// statsdTags returns the DogStatsD tags of labels, e.g. "|#fqdn:example.com,category:dns".
+func statsdTags(labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	tags := make([]string, 0, len(labels))
+	for _, l := range labels {
+		tags = append(tags, l.GetName()+":"+statsdTagReplacer.Replace(l.GetValue()))
+	}
+
+	return "|#" + strings.Join(tags, ",")
+}
+
+// statsdLine returns the StatsD line of a metric, e.g. "ssl_pinning.ct_scts:2|g|#fqdn:example.com".
+func statsdLine(name string, value float64, kind, tags string) string {
+	return name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind + tags
+}
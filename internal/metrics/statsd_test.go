@@ -0,0 +1,124 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+func TestSetupStatsD(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reg := prometheus.NewRegistry()
+	c := new(Collector)
+	c.IncPanic("api")
+	c.SetSCTs("example.com", 2)
+	require.NoError(t, reg.Register(c))
+
+	shutdown, err := SetupStatsD(context.Background(), reg, StatsD{
+		Addr:     conn.LocalAddr().String(),
+		Prefix:   "pinning.",
+		Interval: time.Hour,
+	})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	buf := make([]byte, statsdPacketSize)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	lines := strings.Split(string(buf[:n]), "\n")
+	assert.Contains(t, lines, "pinning.http_panics_total:1|c|#server:api")
+	assert.Contains(t, lines, "pinning.ct_scts:2|g|#fqdn:example.com")
+}
+
+func TestStatsd_Lines(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := new(Collector)
+	require.NoError(t, reg.Register(c))
+
+	s := &statsd{gatherer: reg, prefix: "ssl_pinning.", previous: make(map[string]float64)}
+
+	gather := func() []string {
+		families, err := reg.Gather()
+		require.NoError(t, err)
+
+		return s.lines(families)
+	}
+
+	c.IncPinRotation("example.com")
+	c.ObserveFetch("example.com", FetchError, 250*time.Millisecond)
+
+	lines := gather()
+	assert.Contains(t, lines, "ssl_pinning.pin_rotations_total:1|c|#fqdn:example.com")
+	assert.Contains(t, lines, "ssl_pinning.fetch_duration_seconds.count:1|c|#fqdn:example.com,result:error")
+	assert.Contains(t, lines, "ssl_pinning.fetch_duration_seconds.sum:0.25|c|#fqdn:example.com,result:error")
+
+	// counters are pushed as the increase since the previous push, unchanged ones not at all
+	c.IncPinRotation("example.com")
+	c.IncPinRotation("example.com")
+
+	lines = gather()
+	assert.Contains(t, lines, "ssl_pinning.pin_rotations_total:2|c|#fqdn:example.com")
+
+	for _, line := range lines {
+		assert.False(t, strings.HasPrefix(line, "ssl_pinning.fetch_duration_seconds."), line)
+	}
+}
+
+func TestStatsdTags(t *testing.T) {
+	assert.Empty(t, statsdTags(nil))
+
+	name, value := "file", "a,b|c#d"
+	assert.Equal(t, "|#file:a_b_c_d", statsdTags([]*dto.LabelPair{{Name: &name, Value: &value}}))
+}
+
+func TestSetupStatsD_InvalidAddr(t *testing.T) {
+	_, err := SetupStatsD(context.Background(), prometheus.NewRegistry(), StatsD{Addr: "localhost", Interval: time.Second})
+	assert.Error(t, err)
+}
@@ -0,0 +1,190 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptors of the storage operation metrics.
+var (
+	storageDurationDesc = prometheus.NewDesc(
+		"ssl_pinning_storage_duration_seconds",
+		"Duration of storage operations in seconds",
+		[]string{"backend", "operation"},
+		nil,
+	)
+	storageErrorsDesc = prometheus.NewDesc(
+		"ssl_pinning_storage_errors_total",
+		"Number of failed storage operations",
+		[]string{"backend", "operation"},
+		nil,
+	)
+	storageKeysWrittenDesc = prometheus.NewDesc(
+		"ssl_pinning_storage_keys_written_total",
+		"Number of domain keys written to storage",
+		[]string{"backend"},
+		nil,
+	)
+	lastFlushDesc = prometheus.NewDesc(
+		"ssl_pinning_last_flush_timestamp_seconds",
+		"Time of the latest successful flush of the keys to storage as Unix timestamp in seconds",
+		nil,
+		nil,
+	)
+	flushErrorsDesc = prometheus.NewDesc(
+		"ssl_pinning_flush_errors_total",
+		"Number of failed flushes of the keys to storage",
+		nil,
+		nil,
+	)
+)
+
+// StorageItem is a composite key for storage operation metrics.
+// It combines the storage backend type and the name of the storage operation.
+type StorageItem struct {
+	Backend   string
+	Operation string
+}
+
+// storageStats accumulates the latency histogram and error count of a storage operation.
+type storageStats struct {
+	mu      sync.Mutex
+	buckets []uint64
+	count   uint64
+	errors  uint64
+	sum     float64
+}
+
+// storageBuckets are the upper bounds of the storage operation latency histogram in seconds.
+var storageBuckets = prometheus.DefBuckets
+
+// ObserveStorage records the duration and outcome of a storage operation for a backend.
+// A non-nil err increments the error counter of the operation.
+func (c *Collector) ObserveStorage(backend, operation string, d time.Duration, err error) {
+	v, _ := c.storage.LoadOrStore(
+		StorageItem{Backend: backend, Operation: operation},
+		&storageStats{buckets: make([]uint64, len(storageBuckets))},
+	)
+	stats := v.(*storageStats)
+
+	seconds := d.Seconds()
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.count++
+	stats.sum += seconds
+
+	if err != nil {
+		stats.errors++
+	}
+
+	if i := sort.SearchFloat64s(storageBuckets, seconds); i < len(storageBuckets) {
+		stats.buckets[i]++
+	}
+}
+
+// collectStorage sends the storage operation metrics to Prometheus:
+// - ssl_pinning_storage_duration_seconds: latency of storage operations per backend (histogram)
+// - ssl_pinning_storage_errors_total: number of failed storage operations per backend (counter)
+func (c *Collector) AddStorageKeysWritten(backend string, n int) {
+	v, _ := c.storageWrites.LoadOrStore(backend, new(atomic.Uint64))
+	v.(*atomic.Uint64).Add(uint64(n))
+}
+
+// ObserveFlush records a flush of the keys to storage at date: the time of a successful flush
+// or a failed one.
+func (c *Collector) ObserveFlush(date time.Time, err error) {
+	if err != nil {
+		c.flushErrors.Add(1)
+		return
+	}
+
+	c.lastFlush.Store(date.Unix())
+}
+
+func (c *Collector) collectStorage(ch chan<- prometheus.Metric) {
+	if last := c.lastFlush.Load(); last != 0 {
+		ch <- prometheus.MustNewConstMetric(lastFlushDesc, prometheus.GaugeValue, float64(last))
+	}
+
+	ch <- prometheus.MustNewConstMetric(flushErrorsDesc, prometheus.CounterValue, float64(c.flushErrors.Load()))
+
+	c.storageWrites.Range(func(k, v any) bool {
+		ch <- prometheus.MustNewConstMetric(
+			storageKeysWrittenDesc,
+			prometheus.CounterValue,
+			float64(v.(*atomic.Uint64).Load()),
+			k.(string),
+		)
+		return true
+	})
+
+	c.storage.Range(func(k, v any) bool {
+		item := k.(StorageItem)
+		stats := v.(*storageStats)
+
+		stats.mu.Lock()
+		buckets := make(map[float64]uint64, len(storageBuckets))
+		cumulative := uint64(0)
+		for i, le := range storageBuckets {
+			cumulative += stats.buckets[i]
+			buckets[le] = cumulative
+		}
+		count, sum, errors := stats.count, stats.sum, stats.errors
+		stats.mu.Unlock()
+
+		ch <- prometheus.MustNewConstHistogram(
+			storageDurationDesc,
+			count,
+			sum,
+			buckets,
+			item.Backend,
+			item.Operation,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			storageErrorsDesc,
+			prometheus.CounterValue,
+			float64(errors),
+			item.Backend,
+			item.Operation,
+		)
+		return true
+	})
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_ObserveStorage(t *testing.T) {
+	c := new(Collector)
+
+	c.ObserveStorage("postgres", "save", 3*time.Millisecond, nil)
+	c.ObserveStorage("postgres", "save", 200*time.Millisecond, errors.New("boom"))
+	c.ObserveStorage("postgres", "save", time.Minute, nil)
+	c.ObserveStorage("redis", "get_by_file", time.Millisecond, nil)
+
+	ch := make(chan prometheus.Metric, 10)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	histograms := make(map[StorageItem]*dto.Histogram)
+	counters := make(map[StorageItem]float64)
+
+	for m := range ch {
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+
+		labels := make(map[string]string)
+		for _, l := range metric.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		item := StorageItem{Backend: labels["backend"], Operation: labels["operation"]}
+
+		switch {
+		case metric.Histogram != nil:
+			histograms[item] = metric.GetHistogram()
+		case metric.Counter != nil:
+			counters[item] = metric.GetCounter().GetValue()
+		}
+	}
+
+	save := StorageItem{Backend: "postgres", Operation: "save"}
+	require.Contains(t, histograms, save)
+
+	h := histograms[save]
+	assert.Equal(t, uint64(3), h.GetSampleCount())
+	assert.InDelta(t, 60.203, h.GetSampleSum(), 0.0001)
+
+	for _, b := range h.GetBucket() {
+		switch b.GetUpperBound() {
+		case 0.005:
+			assert.Equal(t, uint64(1), b.GetCumulativeCount())
+		case 0.25:
+			assert.Equal(t, uint64(2), b.GetCumulativeCount())
+		case 10:
+			assert.Equal(t, uint64(2), b.GetCumulativeCount(), "values above the last bucket only count in +Inf")
+		}
+	}
+
+	assert.Equal(t, 1.0, counters[save])
+	assert.Equal(t, 0.0, counters[StorageItem{Backend: "redis", Operation: "get_by_file"}])
+}
+
+func TestCollector_ObserveFlush(t *testing.T) {
+	c := new(Collector)
+
+	collect := func() map[string]float64 {
+		ch := make(chan prometheus.Metric, 10)
+		go func() {
+			c.collectStorage(ch)
+			close(ch)
+		}()
+
+		values := make(map[string]float64)
+		for m := range ch {
+			var metric dto.Metric
+			require.NoError(t, m.Write(&metric))
+
+			switch m.Desc() {
+			case lastFlushDesc:
+				values["last"] = metric.GetGauge().GetValue()
+			case flushErrorsDesc:
+				values["errors"] = metric.GetCounter().GetValue()
+			}
+		}
+
+		return values
+	}
+
+	// no successful flush yet
+	c.ObserveFlush(time.Now(), errors.New("boom"))
+	assert.Equal(t, map[string]float64{"errors": 1}, collect())
+
+	date := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.ObserveFlush(date, nil)
+	c.ObserveFlush(date.Add(time.Minute), errors.New("boom"))
+
+	assert.Equal(t, map[string]float64{"last": float64(date.Unix()), "errors": 2}, collect())
+}
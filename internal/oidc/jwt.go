@@ -0,0 +1,135 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// signatureAlgorithms are the algorithms tokens may be signed with. Only asymmetric algorithms
+// are accepted, "none" and HMAC are rejected, and go-jose only accepts ECDSA keys with the
+// algorithm of their curve (RFC 7518).
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+}
+
+// Claims are the claims of a verified token used for authorization.
+// Scopes are taken from the space separated scope claim (RFC 8693) or the scp claim.
+type Claims struct {
+	Audience  []string
+	Expiry    time.Time
+	Issuer    string
+	NotBefore time.Time
+	Scopes    []string
+	Subject   string
+}
+
+// HasAudience reports whether the token was issued for audience.
+func (c Claims) HasAudience(audience string) bool {
+	return slices.Contains(c.Audience, audience)
+}
+
+// HasScopes reports whether the token grants all of scopes.
+func (c Claims) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// scopeClaims are the scope claims of a token, a space separated string or a list of strings.
+type scopeClaims struct {
+	Scope string       `json:"scope"`
+	Scp   jwt.Audience `json:"scp"`
+}
+
+// newClaims returns the claims of a token from its registered and scope claims.
+func newClaims(c jwt.Claims, s scopeClaims) Claims {
+	claims := Claims{
+		Audience:  c.Audience,
+		Expiry:    c.Expiry.Time(),
+		Issuer:    c.Issuer,
+		NotBefore: c.NotBefore.Time(),
+		Scopes:    strings.Fields(s.Scope),
+		Subject:   c.Subject,
+	}
+
+	if len(claims.Scopes) == 0 {
+		claims.Scopes = s.Scp
+	}
+
+	return claims
+}
+
+// jwks is a JSON Web Key Set (RFC 7517) whose keys are decoded one by one, so that keys of
+// types unknown to go-jose do not fail the whole set.
+type jwks struct {
+	Keys []json.RawMessage `json:"keys"`
+}
+
+// publicKeys returns the RSA and ECDSA signing keys of the set by key ID.
+// Keys of other uses and key types and keys that cannot be decoded are skipped.
+func (s jwks) publicKeys() map[string]crypto.PublicKey {
+	keys := make(map[string]crypto.PublicKey, len(s.Keys))
+
+	for _, raw := range s.Keys {
+		var k jose.JSONWebKey
+		if err := k.UnmarshalJSON(raw); err != nil {
+			continue
+		}
+
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		switch key := k.Key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys[k.KeyID] = key
+		}
+	}
+
+	return keys
+}
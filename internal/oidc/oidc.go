@@ -0,0 +1,341 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	// defaultTimeout bounds every discovery and JWKS request.
+	defaultTimeout = 5 * time.Second
+	// defaultLeeway is the clock skew tolerated when validating exp and nbf.
+	defaultLeeway = time.Minute
+	// keysMaxAge is how long fetched signing keys are used before they are refreshed.
+	keysMaxAge = time.Hour
+	// keysMinInterval limits refreshes triggered by tokens signed with unknown keys.
+	keysMinInterval = time.Minute
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, not signed by the issuer,
+// expired or issued for another audience.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Verifier validates JWT bearer tokens issued by an OpenID Connect provider.
+// The signing keys are discovered via the provider metadata of the issuer
+// (/.well-known/openid-configuration) unless a JWKS URL is configured, and cached.
+type Verifier struct {
+	audience string
+	client   *http.Client
+	issuer   string
+	jwksURL  string
+	leeway   time.Duration
+	now      func() time.Time
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	fetched time.Time
+	keys    map[string]crypto.PublicKey
+	refresh *refresh
+}
+
+// refresh is a fetch of the signing keys shared by the requests that need it.
+// err is set and done closed once the fetch completed.
+type refresh struct {
+	done chan struct{}
+	err  error
+}
+
+// Option is a functional option type for configuring Verifier instance.
+type Option func(*Verifier)
+
+// WithAudience sets the audience tokens must be issued for (the aud claim).
+// The audience is not checked when empty.
+func WithAudience(audience string) Option {
+	return func(v *Verifier) {
+		v.audience = audience
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for discovery and JWKS requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(v *Verifier) {
+		v.client = client
+	}
+}
+
+// WithJWKSURL sets the URL of the JSON Web Key Set of the issuer, skipping discovery.
+func WithJWKSURL(jwksURL string) Option {
+	return func(v *Verifier) {
+		v.jwksURL = jwksURL
+	}
+}
+
+// WithTimeout sets the timeout of discovery and JWKS requests.
+func WithTimeout(timeout time.Duration) Option {
+	return func(v *Verifier) {
+		if timeout > 0 {
+			v.timeout = timeout
+		}
+	}
+}
+
+// New returns a verifier of tokens of issuer.
+// Returns an error if issuer is not an absolute http or https URL.
+func New(issuer string, opts ...Option) (*Verifier, error) {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oidc issuer: %w", err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid oidc issuer %q: expected http or https URL", u.Redacted())
+	}
+
+	v := &Verifier{
+		client:  http.DefaultClient,
+		issuer:  issuer,
+		leeway:  defaultLeeway,
+		now:     time.Now,
+		timeout: defaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v, nil
+}
+
+// Verify validates the signature and the iss, aud, exp and nbf claims of token and returns its claims.
+// Errors of invalid tokens wrap ErrInvalidToken; other errors are failures to fetch the signing keys.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	tok, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	// compact tokens carry exactly one signature
+	key, err := v.key(ctx, tok.Headers[0].KeyID)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	var (
+		registered jwt.Claims
+		scopes     scopeClaims
+	)
+
+	if err := tok.Claims(key, &registered, &scopes); err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if err := v.validate(registered); err != nil {
+		return Claims{}, err
+	}
+
+	return newClaims(registered, scopes), nil
+}
+
+// validate checks the registered claims of a token with a verified signature.
+func (v *Verifier) validate(c jwt.Claims) error {
+	if c.Expiry == nil {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+
+	expected := jwt.Expected{Issuer: v.issuer, Time: v.now()}
+	if v.audience != "" {
+		expected.AnyAudience = jwt.Audience{v.audience}
+	}
+
+	if err := c.ValidateWithLeeway(expected, v.leeway); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	return nil
+}
+
+// key returns the signing key with kid, refreshing the cached keys when they are stale or
+// kid is unknown. A token without kid is accepted if the issuer publishes a single key.
+// Cached keys are kept when a refresh fails.
+// The keys are fetched without holding the lock, once for all requests waiting for them
+// (see refreshKeys), so that a slow issuer does not stall requests with cached keys.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+
+	k, ok := v.lookup(kid)
+
+	now := v.now()
+	if (ok || now.Sub(v.fetched) <= keysMinInterval) && now.Sub(v.fetched) <= keysMaxAge {
+		v.mu.Unlock()
+		return knownKey(k, ok, kid)
+	}
+
+	r := v.refresh
+	if r == nil {
+		r = &refresh{done: make(chan struct{})}
+		v.refresh = r
+
+		// the fetch is shared, so it must not be cancelled with the request that started it
+		go v.refreshKeys(context.WithoutCancel(ctx), r)
+	}
+
+	v.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		if ok {
+			return k, nil
+		}
+
+		return nil, ctx.Err()
+	}
+
+	v.mu.Lock()
+	k, ok = v.lookup(kid)
+	v.mu.Unlock()
+
+	if r.err != nil && !ok {
+		return nil, r.err
+	}
+
+	return knownKey(k, ok, kid)
+}
+
+// refreshKeys fetches the signing keys for r and replaces the cached keys unless the fetch failed.
+func (v *Verifier) refreshKeys(ctx context.Context, r *refresh) {
+	keys, err := v.fetchKeys(ctx)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err == nil {
+		v.keys, v.fetched = keys, v.now()
+	}
+
+	v.refresh = nil
+	r.err = err
+	close(r.done)
+}
+
+// lookup returns the cached signing key with kid, or the only key if kid is empty.
+// v.mu must be held.
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+
+	k, ok := v.keys[kid]
+
+	return k, ok
+}
+
+// knownKey returns k if it was found, otherwise an error of an unknown signing key.
+func knownKey(k crypto.PublicKey, ok bool, kid string) (crypto.PublicKey, error) {
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, kid)
+	}
+
+	return k, nil
+}
+
+// fetchKeys fetches the JSON Web Key Set of the issuer, discovering its URL first if not configured.
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	if v.jwksURL == "" {
+		var meta struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+
+		if err := v.get(ctx, strings.TrimSuffix(v.issuer, "/")+"/.well-known/openid-configuration", &meta); err != nil {
+			return nil, fmt.Errorf("failed to discover oidc provider: %w", err)
+		}
+
+		if meta.Issuer != v.issuer {
+			return nil, fmt.Errorf("oidc provider metadata is of issuer %q, expected %q", meta.Issuer, v.issuer)
+		}
+
+		if meta.JWKSURI == "" {
+			return nil, fmt.Errorf("oidc provider metadata has no jwks_uri")
+		}
+
+		v.jwksURL = meta.JWKSURI
+	}
+
+	var set jwks
+	if err := v.get(ctx, v.jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc signing keys: %w", err)
+	}
+
+	return set.publicKeys(), nil
+}
+
+// get fetches rawURL and decodes the JSON response into dst.
+func (v *Verifier) get(ctx context.Context, rawURL string, dst any) error {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return fmt.Errorf("unexpected response: %s", res.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(dst)
+}
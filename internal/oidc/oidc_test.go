@@ -0,0 +1,459 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// provider is a fake OpenID Connect provider publishing an RSA and an EC signing key.
+type provider struct {
+	*httptest.Server
+	ec       *ecdsa.PrivateKey
+	jwksHits int
+	rsa      *rsa.PrivateKey
+}
+
+func newProvider(t *testing.T) *provider {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	p := &provider{ec: ecKey, rsa: rsaKey}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   p.URL,
+			"jwks_uri": p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.jwksHits++
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{
+				{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+				{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+				{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+			},
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+// token returns a JWT with claims signed by the key kid ("rsa" or "ec") of the provider.
+func (p *provider) token(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+
+	alg := "RS256"
+	if kid == "ec" {
+		alg = "ES256"
+	}
+
+	h, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+
+	c, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+
+	if kid == "ec" {
+		r, s, err := ecdsa.Sign(rand.Reader, p.ec, digest[:])
+		require.NoError(t, err)
+
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsa, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	p := newProvider(t)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		kid     string
+		claims  map[string]any
+		token   string
+		wantErr bool
+	}{
+		{
+			name:   "rsa",
+			kid:    "rsa",
+			claims: map[string]any{"iss": p.URL, "aud": "ssl-pinning", "exp": exp, "sub": "alice", "scope": "openid pins:admin"},
+		},
+		{
+			name:   "ec with audience list",
+			kid:    "ec",
+			claims: map[string]any{"iss": p.URL, "aud": []string{"other", "ssl-pinning"}, "exp": exp, "sub": "alice"},
+		},
+		{
+			name:    "wrong issuer",
+			kid:     "rsa",
+			claims:  map[string]any{"iss": "https://evil.example.com", "aud": "ssl-pinning", "exp": exp},
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			kid:     "rsa",
+			claims:  map[string]any{"iss": p.URL, "aud": "other", "exp": exp},
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			kid:     "rsa",
+			claims:  map[string]any{"iss": p.URL, "aud": "ssl-pinning", "exp": time.Now().Add(-time.Hour).Unix()},
+			wantErr: true,
+		},
+		{
+			name:    "not yet valid",
+			kid:     "rsa",
+			claims:  map[string]any{"iss": p.URL, "aud": "ssl-pinning", "exp": exp, "nbf": exp},
+			wantErr: true,
+		},
+		{
+			name:    "missing expiry",
+			kid:     "rsa",
+			claims:  map[string]any{"iss": p.URL, "aud": "ssl-pinning"},
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			token:   "not-a-token",
+			wantErr: true,
+		},
+		{
+			name:    "unsigned",
+			token:   "eyJhbGciOiJub25lIiwia2lkIjoicnNhIn0.eyJpc3MiOiJ4In0.",
+			wantErr: true,
+		},
+	}
+
+	v, err := New(p.URL, WithAudience("ssl-pinning"))
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.token
+			if token == "" {
+				token = p.token(t, tt.kid, tt.claims)
+			}
+
+			claims, err := v.Verify(context.Background(), token)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.claims["sub"], claims.Subject)
+		})
+	}
+
+	assert.Equal(t, 1, p.jwksHits, "signing keys should be cached")
+}
+
+func TestVerifier_Verify_TamperedSignature(t *testing.T) {
+	p := newProvider(t)
+
+	v, err := New(p.URL)
+	require.NoError(t, err)
+
+	a := p.token(t, "rsa", map[string]any{"iss": p.URL, "exp": time.Now().Add(time.Hour).Unix(), "scope": "read"})
+	b := p.token(t, "rsa", map[string]any{"iss": p.URL, "exp": time.Now().Add(time.Hour).Unix(), "scope": "admin"})
+
+	// claims of b with the signature of a
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	tampered := strings.Join([]string{pb[0], pb[1], pa[2]}, ".")
+
+	_, err = v.Verify(context.Background(), tampered)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifier_Verify_UnknownKey(t *testing.T) {
+	p := newProvider(t)
+
+	v, err := New(p.URL, WithJWKSURL(p.URL+"/jwks"))
+	require.NoError(t, err)
+
+	token := p.token(t, "rsa", map[string]any{"iss": p.URL, "exp": time.Now().Add(time.Hour).Unix()})
+
+	_, err = v.Verify(context.Background(), token)
+	require.NoError(t, err)
+
+	// a token signed with a key of another use is rejected without refetching the keys
+	parts := strings.Split(token, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"enc"}`))
+
+	_, err = v.Verify(context.Background(), strings.Join(parts, "."))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, 1, p.jwksHits)
+}
+
+func TestVerifier_Verify_ProviderDown(t *testing.T) {
+	p := newProvider(t)
+	p.Close()
+
+	v, err := New(p.URL)
+	require.NoError(t, err)
+
+	_, err = v.Verify(context.Background(), p.token(t, "rsa", map[string]any{"iss": p.URL}))
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrInvalidToken))
+}
+
+func TestVerifier_Verify_ECDSACurve(t *testing.T) {
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	keys := map[string]*ecdsa.PrivateKey{}
+	set := []map[string]string{}
+
+	for name, curve := range map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		require.NoError(t, err)
+
+		keys[name] = key
+
+		size := (curve.Params().BitSize + 7) / 8
+		set = append(set, map[string]string{
+			"kty": "EC", "kid": name, "crv": name,
+			"x": b64(key.X.FillBytes(make([]byte, size))), "y": b64(key.Y.FillBytes(make([]byte, size))),
+		})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": set})
+	}))
+	defer srv.Close()
+
+	v, err := New(srv.URL, WithJWKSURL(srv.URL))
+	require.NoError(t, err)
+
+	// token returns a token of the key of curve claiming alg and signed over hash
+	token := func(t *testing.T, alg string, hash crypto.Hash, curve string) string {
+		h, err := json.Marshal(map[string]string{"alg": alg, "kid": curve, "typ": "JWT"})
+		require.NoError(t, err)
+
+		c, err := json.Marshal(map[string]any{"iss": srv.URL, "exp": time.Now().Add(time.Hour).Unix()})
+		require.NoError(t, err)
+
+		signed := b64(h) + "." + b64(c)
+
+		digest := hash.New()
+		digest.Write([]byte(signed))
+
+		key := keys[curve]
+
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		require.NoError(t, err)
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+
+		return signed + "." + b64(append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...))
+	}
+
+	tests := []struct {
+		name    string
+		alg     string
+		hash    crypto.Hash
+		curve   string
+		wantErr bool
+	}{
+		{name: "ES256 with P-256", alg: "ES256", hash: crypto.SHA256, curve: "P-256"},
+		{name: "ES384 with P-384", alg: "ES384", hash: crypto.SHA384, curve: "P-384"},
+		{name: "ES512 with P-521", alg: "ES512", hash: crypto.SHA512, curve: "P-521"},
+		{name: "ES384 with P-256", alg: "ES384", hash: crypto.SHA384, curve: "P-256", wantErr: true},
+		{name: "ES512 with P-256", alg: "ES512", hash: crypto.SHA512, curve: "P-256", wantErr: true},
+		{name: "ES256 with P-384", alg: "ES256", hash: crypto.SHA256, curve: "P-384", wantErr: true},
+		{name: "ES512 with P-384", alg: "ES512", hash: crypto.SHA512, curve: "P-384", wantErr: true},
+		{name: "ES256 with P-521", alg: "ES256", hash: crypto.SHA256, curve: "P-521", wantErr: true},
+		{name: "RS256 with P-256", alg: "RS256", hash: crypto.SHA256, curve: "P-256", wantErr: true},
+		{name: "HS256 with P-256", alg: "HS256", hash: crypto.SHA256, curve: "P-256", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the signature itself is valid for the hash of alg, only the curve does not match
+			_, err := v.Verify(context.Background(), token(t, tt.alg, tt.hash, tt.curve))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifier_Verify_SlowProvider(t *testing.T) {
+	p := newProvider(t)
+
+	var hits atomic.Int32
+	release := make(chan struct{})
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		http.Redirect(w, r, p.URL+"/jwks", http.StatusFound)
+	}))
+	defer slow.Close()
+
+	v, err := New(p.URL, WithJWKSURL(slow.URL))
+	require.NoError(t, err)
+
+	// only the rsa key is cached, tokens of the ec key refresh the keys
+	v.keys, v.fetched = map[string]crypto.PublicKey{"rsa": &p.rsa.PublicKey}, time.Now().Add(-time.Hour/2)
+
+	claims := map[string]any{"iss": p.URL, "exp": time.Now().Add(time.Hour).Unix()}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			_, err := v.Verify(context.Background(), p.token(t, "ec", claims))
+			assert.NoError(t, err)
+		})
+	}
+
+	require.Eventually(t, func() bool { return hits.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// tokens of cached keys are verified while the keys are fetched
+	_, err = v.Verify(context.Background(), p.token(t, "rsa", claims))
+	require.NoError(t, err)
+
+	// requests waiting for the keys give up with their context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = v.Verify(ctx, p.token(t, "ec", claims))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), hits.Load(), "concurrent refreshes should share one fetch")
+}
+
+func TestVerifier_Verify_AlgorithmCurveMismatch(t *testing.T) {
+	p := newProvider(t)
+
+	v, err := New(p.URL)
+	require.NoError(t, err)
+
+	// a token of the P-256 key "ec" claiming ES384 and signed over SHA-384
+	h, err := json.Marshal(map[string]string{"alg": "ES384", "kid": "ec", "typ": "JWT"})
+	require.NoError(t, err)
+
+	c, err := json.Marshal(map[string]any{"iss": p.URL, "exp": time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha512.Sum384([]byte(signed))
+
+	r, s, err := ecdsa.Sign(rand.Reader, p.ec, digest[:])
+	require.NoError(t, err)
+
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	_, err = v.Verify(context.Background(), signed+"."+base64.RawURLEncoding.EncodeToString(signature))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("ftp://issuer.example.com")
+	assert.Error(t, err)
+
+	_, err = New("https://issuer.example.com/realms/ops")
+	assert.NoError(t, err)
+}
+
+func TestClaims_HasScopes(t *testing.T) {
+	c := Claims{Scopes: []string{"openid", "pins:admin"}}
+
+	assert.True(t, c.HasScopes())
+	assert.True(t, c.HasScopes("pins:admin"))
+	assert.False(t, c.HasScopes("pins:admin", "pins:write"))
+}
+
+func TestNewClaims_ScpClaim(t *testing.T) {
+	var scopes scopeClaims
+	require.NoError(t, json.Unmarshal([]byte(`{"scp":["a","b"]}`), &scopes))
+
+	exp := jwt.NewNumericDate(time.Unix(1, 0))
+
+	claims := newClaims(jwt.Claims{Expiry: exp}, scopes)
+	assert.Equal(t, []string{"a", "b"}, claims.Scopes)
+	assert.Equal(t, time.Unix(1, 0), claims.Expiry)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"scope":"c d","scp":"a"}`), &scopes))
+	assert.Equal(t, []string{"c", "d"}, newClaims(jwt.Claims{}, scopes).Scopes)
+}
@@ -0,0 +1,588 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package openapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"ssl-pinning/internal/storage/types"
+	"ssl-pinning/internal/version"
+	"ssl-pinning/pkg/verify"
+)
+
+// document and schema are built once on first use as the described types never change at runtime.
+var (
+	document = sync.OnceValue(Document)
+	schema   = sync.OnceValue(JSONSchema)
+)
+
+// Document returns the OpenAPI 3.1 document describing the public v1 and v2 APIs.
+// Component schemas are generated from the Go types used to render responses,
+// so they always match the payloads served by this binary.
+func Document() map[string]any {
+	g := NewGenerator("#/components/schemas/")
+
+	text := map[string]any{
+		"text/plain": map[string]any{
+			"schema": map[string]any{"type": "string"},
+		},
+	}
+
+	jsonContent := func(s map[string]any) map[string]any {
+		return map[string]any{
+			"application/json": map[string]any{
+				"schema": s,
+			},
+		}
+	}
+
+	apiError := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     jsonContent(g.Schema(types.ErrorResponse{})),
+		}
+	}
+
+	pageParameters := []any{
+		map[string]any{
+			"name":        "limit",
+			"in":          "query",
+			"description": "Maximum number of items of the page",
+			"schema":      map[string]any{"type": "integer", "minimum": 1, "maximum": 1000, "default": 100},
+		},
+		map[string]any{
+			"name":        "cursor",
+			"in":          "query",
+			"description": "Opaque `next_cursor` of the previous page",
+			"schema":      map[string]any{"type": "string"},
+		},
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "ssl-pinning",
+			"description": "Dynamic SSL pinning service publishing signed SPKI fingerprints of HTTPS endpoints.",
+			"version":     apiVersion(),
+		},
+		"paths": map[string]any{
+			"/api/v1/domains/{fqdn}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getDomain",
+					"summary":     "Get the current keys of a single host",
+					"description": "Returns the pin, expiry and last error of the host for every file it is published in. " +
+						"The response is not signed.",
+					"parameters": []any{
+						map[string]any{
+							"name":     "fqdn",
+							"in":       "path",
+							"required": true,
+							"schema":   map[string]any{"type": "string"},
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Current keys of the host",
+							"content":     jsonContent(g.Schema(types.FileKeys{})),
+						},
+						"400": map[string]any{"description": "FQDN is missing", "content": text},
+						"404": map[string]any{"description": "Host not found", "content": text},
+						"500": map[string]any{"description": "Storage error", "content": text},
+					},
+				},
+			},
+			"/api/v1/domains/{fqdn}/cert": map[string]any{
+				"get": map[string]any{
+					"operationId": "getDomainCertificate",
+					"summary":     "Get the certificate metadata of a single host",
+					"description": "Returns the issuer, subject, serial number, validity and subject alternative names " +
+						"of the certificate captured at the latest successful fetch of the host by the serving instance.",
+					"parameters": []any{
+						map[string]any{
+							"name":     "fqdn",
+							"in":       "path",
+							"required": true,
+							"schema":   map[string]any{"type": "string"},
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Certificate metadata of the host",
+							"content":     jsonContent(g.Schema(types.Certificate{})),
+						},
+						"400": map[string]any{"description": "FQDN is missing", "content": text},
+						"404": map[string]any{"description": "Host not monitored or not fetched yet", "content": text},
+					},
+				},
+			},
+			"/api/v1/files": map[string]any{
+				"get": map[string]any{
+					"operationId": "listFiles",
+					"summary":     "List published pin files",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Published files with key counts and last update time",
+							"content":     jsonContent(g.Schema(types.FileList{})),
+						},
+						"500": map[string]any{"description": "Storage error", "content": text},
+					},
+				},
+			},
+			"/api/v1/stream": map[string]any{
+				"get": map[string]any{
+					"operationId": "streamFiles",
+					"summary":     "Stream pin updates",
+					"description": "Server-Sent Events stream sending a `file` event whenever the pin set of a file changes. " +
+						"The data of every event is the file name, key count and latest key update; files that are no " +
+						"longer published are sent with zero keys. Missed events are not replayed.",
+					"parameters": []any{
+						map[string]any{
+							"name":        "file",
+							"in":          "query",
+							"description": "Only send events of these files, may be repeated",
+							"schema":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Event stream",
+							"content": map[string]any{
+								"text/event-stream": map[string]any{
+									"schema": map[string]any{"type": "string"},
+								},
+							},
+						},
+					},
+				},
+			},
+			"/api/v1/subscribe": map[string]any{
+				"get": map[string]any{
+					"operationId": "subscribeFiles",
+					"summary":     "Subscribe to signed deltas of pins over a WebSocket",
+					"description": "Upgrades to a WebSocket sending a text message with a signed delta of the keys of a file, " +
+						"in the `application/json` envelope, whenever keys of the subscription change. Clients replace " +
+						"the subscription by sending it as a JSON message. Missed deltas are not replayed.",
+					"parameters": []any{
+						map[string]any{
+							"name":        "file",
+							"in":          "query",
+							"description": "Only send deltas of these files, may be repeated",
+							"schema":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+						},
+						map[string]any{
+							"name":        "fqdn",
+							"in":          "query",
+							"description": "Only send changes of these hosts, may be repeated",
+							"schema":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+						},
+					},
+					"responses": map[string]any{
+						"101": map[string]any{"description": "Switched to the WebSocket protocol"},
+						"400": map[string]any{"description": "Not a WebSocket handshake", "content": text},
+					},
+				},
+			},
+			"/api/v1/{file}/delta": map[string]any{
+				"get": map[string]any{
+					"operationId": "getFileDelta",
+					"summary":     "Get the pins of a file changed since a version",
+					"description": "Returns the keys added or changed and the FQDNs removed since `since`, signed like the file " +
+						"in the `application/json` envelope, with the current version as `cursor`. Unknown versions are " +
+						"answered with `reset` and all keys.",
+					"parameters": []any{
+						map[string]any{
+							"name":     "file",
+							"in":       "path",
+							"required": true,
+							"schema":   map[string]any{"type": "string"},
+						},
+						map[string]any{
+							"name":        "since",
+							"in":          "query",
+							"description": "`cursor` of a previous delta or an RFC 3339 time",
+							"schema":      map[string]any{"type": "string"},
+						},
+						map[string]any{
+							"name":        "pin_encoding",
+							"in":          "query",
+							"description": "Textual form of the published pins, defaults to `server.pin_encoding`",
+							"schema": map[string]any{
+								"type": "string",
+								"enum": []string{
+									string(types.PinEncodingBase64),
+									string(types.PinEncodingSHA256),
+									string(types.PinEncodingHex),
+								},
+							},
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Signed delta",
+							"content": jsonContent(map[string]any{
+								"type":     "object",
+								"required": []string{"payload", "signature"},
+								"properties": map[string]any{
+									"payload":   g.Schema(types.Delta{}),
+									"signature": map[string]any{"type": "string"},
+									"alg":       map[string]any{"type": "string"},
+									"kid":       map[string]any{"type": "string"},
+									"signed_at": map[string]any{"type": "string", "format": "date-time"},
+								},
+							}),
+						},
+						"400": map[string]any{"description": "Invalid file name or unknown pin encoding", "content": text},
+						"404": map[string]any{"description": "File not found", "content": text},
+						"500": map[string]any{"description": "Storage or signing error", "content": text},
+					},
+				},
+			},
+			"/api/v1/{file}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getFile",
+					"summary":     "Get a signed pin file",
+					"description": "The payload field naming may be selected with an Accept media type parameter, " +
+						"e.g. `application/json; naming=snake_case`. The schema below describes the legacy naming. " +
+						"`application/jose` and `application/jose+json` select the RFC 7515 compact and flattened JSON " +
+						"serializations, whose payload is the signed `payload` object. `application/cose` selects a " +
+						"COSE_Sign1 message (RFC 9052) whose payload is the `payload` object encoded as CBOR. " +
+						"Responses carry an `ETag`, `Last-Modified` and `Cache-Control` header; conditional requests " +
+						"with `If-None-Match` or `If-Modified-Since` are answered with `304` when the file is unchanged. " +
+						"`format` renders the keys as unsigned client configuration instead, e.g. the TrustKit " +
+						"`kTSKPinnedDomains` JSON or `TSKConfiguration` property list, or the pins of the OkHttp " +
+						"`CertificatePinner` as JSON or Kotlin code, or lines of the `pin-sha256` directives of HPKP " +
+						"and the `sha256//` pins of curl `--pinnedpubkey` per host.",
+					"parameters": []any{
+						map[string]any{
+							"name":     "file",
+							"in":       "path",
+							"required": true,
+							"schema":   map[string]any{"type": "string"},
+						},
+						map[string]any{
+							"name":        "pin_encoding",
+							"in":          "query",
+							"description": "Textual form of the published pins, defaults to `server.pin_encoding`",
+							"schema": map[string]any{
+								"type": "string",
+								"enum": []string{
+									string(types.PinEncodingBase64),
+									string(types.PinEncodingSHA256),
+									string(types.PinEncodingHex),
+								},
+							},
+						},
+						map[string]any{
+							"name":        "format",
+							"in":          "query",
+							"description": "Client configuration to render the keys as, instead of the signed file",
+							"schema": map[string]any{
+								"type": "string",
+								"enum": []string{
+									string(types.FormatTrustKit),
+									string(types.FormatTrustKitPlist),
+									string(types.FormatOkHttp),
+									string(types.FormatOkHttpKotlin),
+									string(types.FormatHPKP),
+									string(types.FormatCurl),
+								},
+							},
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Signed pin file",
+							"headers": map[string]any{
+								"ETag":          map[string]any{"description": "Strong entity tag of the payload", "schema": map[string]any{"type": "string"}},
+								"Last-Modified": map[string]any{"description": "Latest update of the keys of the file", "schema": map[string]any{"type": "string"}},
+								"Cache-Control": map[string]any{"description": "`public, max-age=…` of `server.cache_max_age`, or `no-cache`", "schema": map[string]any{"type": "string"}},
+							},
+							"content": map[string]any{
+								types.EnvelopeLegacy.MediaType(): map[string]any{
+									"schema": g.Schema(types.FileStructure{}),
+								},
+								types.EnvelopeJWS.MediaType(): map[string]any{
+									"schema": map[string]any{"type": "string"},
+								},
+								types.EnvelopeCOSE.MediaType(): map[string]any{
+									"schema": map[string]any{"type": "string", "format": "binary"},
+								},
+								types.FormatTrustKitPlist.MediaType(): map[string]any{
+									"schema": map[string]any{"type": "string"},
+								},
+								types.FormatOkHttpKotlin.MediaType(): map[string]any{
+									"schema": map[string]any{"type": "string"},
+								},
+								types.FormatCurl.MediaType(): map[string]any{
+									"schema": map[string]any{"type": "string"},
+								},
+								types.EnvelopeJWSJSON.MediaType(): map[string]any{
+									"schema": map[string]any{
+										"oneOf": []any{
+											map[string]any{
+												"description": "Flattened JWS JSON serialization",
+												"type":        "object",
+												"required":    []string{"payload", "protected", "signature"},
+												"properties": map[string]any{
+													"payload":   map[string]any{"type": "string"},
+													"protected": map[string]any{"type": "string"},
+													"signature": map[string]any{"type": "string"},
+												},
+											},
+											map[string]any{
+												"description": "General JWS JSON serialization, used during a signing key rotation",
+												"type":        "object",
+												"required":    []string{"payload", "signatures"},
+												"properties": map[string]any{
+													"payload": map[string]any{"type": "string"},
+													"signatures": map[string]any{
+														"type": "array",
+														"items": map[string]any{
+															"type":     "object",
+															"required": []string{"protected", "signature"},
+															"properties": map[string]any{
+																"protected": map[string]any{"type": "string"},
+																"signature": map[string]any{"type": "string"},
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+						"304": map[string]any{"description": "File not modified since the requested ETag or time"},
+						"400": map[string]any{"description": "File name is missing or unknown pin encoding or format", "content": text},
+						"404": map[string]any{"description": "File not found", "content": text},
+						"406": map[string]any{"description": "Unknown payload naming requested", "content": text},
+						"500": map[string]any{"description": "Storage or signing error", "content": text},
+					},
+				},
+			},
+			"/api/v1/verify": map[string]any{
+				"post": map[string]any{
+					"operationId": "verifyFile",
+					"summary":     "Verify a signed pin file",
+					"description": "Verifies the signatures of a signed file in the `application/json` envelope with the " +
+						"public keys of the service, including the new key of a signing key rotation.",
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  jsonContent(g.Schema(types.FileStructure{})),
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The file is signed with a key of the service",
+							"content":     jsonContent(g.Schema(verify.Verification{})),
+						},
+						"400": map[string]any{
+							"description": "The body is not a signed file",
+							"content":     jsonContent(g.Schema(verify.Verification{})),
+						},
+						"413": map[string]any{"description": "The body is too large", "content": text},
+						"422": map[string]any{
+							"description": "No signature matches a key of the service",
+							"content":     jsonContent(g.Schema(verify.Verification{})),
+						},
+					},
+				},
+			},
+			"/api/v2/files": map[string]any{
+				"get": map[string]any{
+					"operationId": "listFilesV2",
+					"summary":     "List published pin files, paginated",
+					"parameters":  pageParameters,
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "A page of the published files sorted by name",
+							"content":     jsonContent(g.Schema(types.FilePage{})),
+						},
+						"400": apiError("Invalid limit or cursor"),
+						"401": apiError("Missing or unknown API key"),
+						"500": apiError("Storage error"),
+					},
+				},
+			},
+			"/api/v2/files/{file}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getFileV2",
+					"summary":     "Get a page of the keys of a pin file",
+					"description": "The keys matching the filters are sorted by expiry and FQDN and every page is signed as " +
+						"a file of its own in the `application/json` envelope, so it can be verified independently. " +
+						"`file` is omitted if no key matches. The payload field naming is negotiated as for `getFile`.",
+					"parameters": append([]any{
+						map[string]any{
+							"name":     "file",
+							"in":       "path",
+							"required": true,
+							"schema":   map[string]any{"type": "string"},
+						},
+						map[string]any{
+							"name":        "fqdn",
+							"in":          "query",
+							"description": "Only return the keys of these hosts, may be repeated",
+							"schema":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+						},
+						map[string]any{
+							"name":        "expiring_before",
+							"in":          "query",
+							"description": "Only return keys whose certificate expires before this time",
+							"schema":      map[string]any{"type": "string", "format": "date-time"},
+						},
+						map[string]any{
+							"name":        "pin_encoding",
+							"in":          "query",
+							"description": "Textual form of the published pins, defaults to `server.pin_encoding`",
+							"schema": map[string]any{
+								"type": "string",
+								"enum": []string{
+									string(types.PinEncodingBase64),
+									string(types.PinEncodingSHA256),
+									string(types.PinEncodingHex),
+								},
+							},
+						},
+					}, pageParameters...),
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "A page of the keys of the file, signed",
+							"content": jsonContent(map[string]any{
+								"type":     "object",
+								"required": []string{"page"},
+								"properties": map[string]any{
+									"file": g.Schema(types.FileStructure{}),
+									"page": g.Schema(types.Page{}),
+								},
+							}),
+						},
+						"400": apiError("Invalid file name, filter, pin encoding, limit or cursor"),
+						"401": apiError("Missing or unknown API key"),
+						"404": apiError("File not found"),
+						"406": apiError("Unknown payload naming requested"),
+						"500": apiError("Storage or signing error"),
+					},
+				},
+			},
+			"/api/v1/openapi.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "getOpenAPI",
+					"summary":     "This OpenAPI document",
+					"responses": map[string]any{
+						"200": map[string]any{"description": "OpenAPI document"},
+					},
+				},
+			},
+			"/api/v1/schema.json": map[string]any{
+				"get": map[string]any{
+					"operationId": "getSchema",
+					"summary":     "JSON Schema of the signed pin file format",
+					"responses": map[string]any{
+						"200": map[string]any{"description": "JSON Schema document"},
+					},
+				},
+			},
+			"/version": map[string]any{
+				"get": map[string]any{
+					"operationId": "getVersion",
+					"summary":     "Build information of the running binary",
+					"security":    []any{},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Version, git commit and Go version",
+							"content":     jsonContent(g.Schema(version.BuildInfo{})),
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": g.Definitions(),
+			"securitySchemes": map[string]any{
+				"apiKeyHeader": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"apiKeyQuery":  map[string]any{"type": "apiKey", "in": "query", "name": "api_key"},
+			},
+		},
+		// API keys are only required when server.api_keys is configured.
+		"security": []any{
+			map[string]any{},
+			map[string]any{"apiKeyHeader": []any{}},
+			map[string]any{"apiKeyQuery": []any{}},
+		},
+	}
+}
+
+// JSONSchema returns a standalone JSON Schema (draft 2020-12) of the signed pin file format.
+func JSONSchema() map[string]any {
+	g := NewGenerator("#/$defs/")
+
+	s := g.Schema(types.FileStructure{})
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "ssl-pinning signed file"
+	s["$defs"] = g.Definitions()
+
+	return s
+}
+
+// HandleDocument serves the OpenAPI document as JSON.
+func HandleDocument(w http.ResponseWriter, r *http.Request) {
+	write(w, document())
+}
+
+// HandleSchema serves the JSON Schema of the signed pin file format.
+func HandleSchema(w http.ResponseWriter, r *http.Request) {
+	write(w, schema())
+}
+
+// write encodes v as indented JSON into the response.
+func write(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		slog.Error("failed to write response", "err", err)
+	}
+}
+
+// apiVersion returns the binary version used as the document version.
+func apiVersion() string {
+	if v := version.GetVersion(); v != "" {
+		return v
+	}
+
+	return "dev"
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	doc := Document()
+
+	assert.Equal(t, "3.1.0", doc["openapi"])
+
+	paths, ok := doc["paths"].(map[string]any)
+	require.True(t, ok)
+	assert.Contains(t, paths, "/api/v1/domains/{fqdn}")
+	assert.Contains(t, paths, "/api/v1/domains/{fqdn}/cert")
+	assert.Contains(t, paths, "/api/v1/files")
+	assert.Contains(t, paths, "/api/v1/{file}")
+	assert.Contains(t, paths, "/api/v1/stream")
+	assert.Contains(t, paths, "/api/v1/subscribe")
+	assert.Contains(t, paths, "/api/v1/{file}/delta")
+	assert.Contains(t, paths, "/api/v2/files")
+	assert.Contains(t, paths, "/api/v2/files/{file}")
+	assert.Contains(t, paths["/api/v1/verify"], "post")
+
+	file := paths["/api/v1/{file}"].(map[string]any)["get"].(map[string]any)
+	content := file["responses"].(map[string]any)["200"].(map[string]any)["content"].(map[string]any)
+	for _, mediaType := range []string{"application/json", "application/jose", "application/jose+json", "application/cose"} {
+		assert.Contains(t, content, mediaType)
+	}
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	for _, name := range []string{"Certificate", "DomainKey", "FileInfo", "FileKeys", "FileList", "FileStructure", "Verification"} {
+		assert.Contains(t, schemas, name)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	s := JSONSchema()
+
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", s["$schema"])
+	assert.Equal(t, "#/$defs/FileStructure", s["$ref"])
+
+	defs, ok := s["$defs"].(map[string]any)
+	require.True(t, ok)
+	assert.Contains(t, defs, "FileStructure")
+	assert.Contains(t, defs, "DomainKey")
+	assert.NotContains(t, defs, "FileList")
+}
+
+func TestHandlers(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantKey string
+	}{
+		{name: "openapi document", handler: HandleDocument, wantKey: "openapi"},
+		{name: "json schema", handler: HandleSchema, wantKey: "$schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Contains(t, body, tt.wantKey)
+		})
+	}
+}
+
+func TestHandleSwaggerUI(t *testing.T) {
+	w := httptest.NewRecorder()
+	HandleSwaggerUI(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `url: "/openapi.json"`)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Generator builds JSON Schemas from Go types using reflection.
+// Named struct types are collected as reusable definitions and referenced via $ref,
+// anonymous structs are inlined. Field names and optionality follow the json struct tags.
+type Generator struct {
+	defs   map[string]any
+	prefix string
+}
+
+// NewGenerator creates a new Generator that references named definitions with the given prefix,
+// e.g. "#/components/schemas/" for OpenAPI documents or "#/$defs/" for JSON Schema documents.
+func NewGenerator(prefix string) *Generator {
+	return &Generator{
+		defs:   make(map[string]any),
+		prefix: prefix,
+	}
+}
+
+// Definitions returns all named schemas collected so far, keyed by Go type name.
+func (g *Generator) Definitions() map[string]any {
+	return g.defs
+}
+
+// Schema returns the JSON Schema for the type of v.
+// Named struct types are registered as definitions and a $ref to them is returned.
+func (g *Generator) Schema(v any) map[string]any {
+	return g.schema(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema converts a reflect.Type into a JSON Schema.
+func (g *Generator) schema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := map[string]any{"type": "integer"}
+		if t.Bits() == 64 {
+			s["format"] = "int64"
+		}
+		return s
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+
+		if _, ok := g.defs[t.Name()]; !ok {
+			// register a placeholder first to terminate recursive types
+			g.defs[t.Name()] = map[string]any{}
+			g.defs[t.Name()] = g.object(t)
+		}
+
+		return map[string]any{"$ref": g.prefix + t.Name()}
+
+	default:
+		return map[string]any{}
+	}
+}
+
+// object converts a struct type into a JSON Schema object.
+// Fields without the omitempty option are listed as required.
+func (g *Generator) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = g.schema(f.Type)
+
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	s := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		s["required"] = required
+	}
+
+	return s
+}
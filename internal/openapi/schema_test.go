@@ -0,0 +1,122 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testNested struct {
+	Name string `json:"name"`
+}
+
+type testRecursive struct {
+	Next *testRecursive `json:"next,omitempty"`
+}
+
+func TestGenerator_Schema(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want map[string]any
+	}{
+		{name: "bool", v: true, want: map[string]any{"type": "boolean"}},
+		{name: "int", v: int32(1), want: map[string]any{"type": "integer"}},
+		{name: "int64", v: int64(1), want: map[string]any{"type": "integer", "format": "int64"}},
+		{name: "float", v: 1.5, want: map[string]any{"type": "number"}},
+		{name: "string", v: "", want: map[string]any{"type": "string"}},
+		{name: "bytes", v: []byte{}, want: map[string]any{"type": "string", "contentEncoding": "base64"}},
+		{name: "time", v: time.Time{}, want: map[string]any{"type": "string", "format": "date-time"}},
+		{name: "time pointer", v: &time.Time{}, want: map[string]any{"type": "string", "format": "date-time"}},
+		{
+			name: "slice",
+			v:    []string{},
+			want: map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+		{
+			name: "map",
+			v:    map[string]int{},
+			want: map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "integer", "format": "int64"}},
+		},
+		{
+			name: "anonymous struct",
+			v: struct {
+				A string `json:"a"`
+				B int    `json:"b,omitempty"`
+				C string `json:"-"`
+				d string
+			}{},
+			want: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"a": map[string]any{"type": "string"},
+					"b": map[string]any{"type": "integer", "format": "int64"},
+				},
+				"required": []string{"a"},
+			},
+		},
+		{
+			name: "named struct",
+			v:    testNested{},
+			want: map[string]any{"$ref": "#/$defs/testNested"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("#/$defs/")
+			assert.Equal(t, tt.want, g.Schema(tt.v))
+		})
+	}
+}
+
+func TestGenerator_Definitions(t *testing.T) {
+	g := NewGenerator("#/components/schemas/")
+
+	g.Schema([]testNested{})
+	g.Schema(testRecursive{})
+
+	defs := g.Definitions()
+	require.Contains(t, defs, "testNested")
+	require.Contains(t, defs, "testRecursive")
+
+	assert.Equal(t, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"next": map[string]any{"$ref": "#/components/schemas/testRecursive"},
+		},
+	}, defs["testRecursive"])
+}
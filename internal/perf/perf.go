@@ -0,0 +1,127 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package perf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// Budget is the published performance budget of an operation.
+// A zero field is not enforced.
+type Budget struct {
+	P99         time.Duration
+	AllocsPerOp uint64
+	BytesPerOp  uint64
+}
+
+// Result holds the latency distribution and memory usage measured for an operation.
+type Result struct {
+	Iterations  int
+	P50         time.Duration
+	P99         time.Duration
+	Max         time.Duration
+	AllocsPerOp uint64
+	BytesPerOp  uint64
+}
+
+// String formats the result in a single line suitable for test and CI logs.
+func (r Result) String() string {
+	return fmt.Sprintf("n=%d p50=%s p99=%s max=%s allocs/op=%d B/op=%d",
+		r.Iterations, r.P50, r.P99, r.Max, r.AllocsPerOp, r.BytesPerOp)
+}
+
+// Measure runs fn the given number of times and returns the observed latency
+// percentiles and the average allocations per run. Allocations include those made
+// by goroutines started by fn, so fn must wait for them before returning.
+func Measure(iterations int, fn func()) Result {
+	if iterations < 1 {
+		iterations = 1
+	}
+
+	durations := make([]time.Duration, iterations)
+
+	var before, after runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	for i := range durations {
+		start := time.Now()
+		fn()
+		durations[i] = time.Since(start)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	slices.Sort(durations)
+
+	return Result{
+		Iterations:  iterations,
+		P50:         percentile(durations, 0.50),
+		P99:         percentile(durations, 0.99),
+		Max:         durations[len(durations)-1],
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / uint64(iterations),
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / uint64(iterations),
+	}
+}
+
+// Check returns an error describing every budget exceeded by r.
+func (b Budget) Check(r Result) error {
+	var errs []error
+
+	if b.P99 > 0 && r.P99 > b.P99 {
+		errs = append(errs, fmt.Errorf("p99 latency %s exceeds budget %s", r.P99, b.P99))
+	}
+
+	if b.AllocsPerOp > 0 && r.AllocsPerOp > b.AllocsPerOp {
+		errs = append(errs, fmt.Errorf("%d allocs/op exceed budget %d", r.AllocsPerOp, b.AllocsPerOp))
+	}
+
+	if b.BytesPerOp > 0 && r.BytesPerOp > b.BytesPerOp {
+		errs = append(errs, fmt.Errorf("%d B/op exceed budget %d", r.BytesPerOp, b.BytesPerOp))
+	}
+
+	return errors.Join(errs...)
+}
+
+// percentile returns the nearest-rank percentile p (0..1] of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(float64(len(sorted))*p)) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+
+	return sorted[rank]
+}
@@ -0,0 +1,279 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package perf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/signer"
+	"ssl-pinning/internal/storage/memory"
+	"ssl-pinning/internal/storage/types"
+)
+
+// budgetEnv enables TestBudgets. Budgets are wall-clock sensitive, so they are only
+// enforced on dedicated runs (task perf-budget, CI) and not by a plain go test.
+const budgetEnv = "SSL_PINNING_PERF_BUDGET"
+
+// largeKeySet is the number of keys in a file used by the budget scenarios.
+const largeKeySet = 1000
+
+func TestMeasure(t *testing.T) {
+	calls := 0
+	var sink [][]byte
+
+	r := Measure(10, func() {
+		calls++
+		sink = append(sink, make([]byte, 1024))
+		time.Sleep(time.Millisecond)
+	})
+
+	assert.Equal(t, 10, calls)
+	assert.Len(t, sink, 10)
+	assert.Equal(t, 10, r.Iterations)
+	assert.GreaterOrEqual(t, r.P50, time.Millisecond)
+	assert.GreaterOrEqual(t, r.P99, r.P50)
+	assert.GreaterOrEqual(t, r.Max, r.P99)
+	assert.GreaterOrEqual(t, r.AllocsPerOp, uint64(1))
+	assert.GreaterOrEqual(t, r.BytesPerOp, uint64(1024))
+	assert.Contains(t, r.String(), "n=10 ")
+}
+
+func TestMeasure_MinIterations(t *testing.T) {
+	calls := 0
+
+	r := Measure(0, func() { calls++ })
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 1, r.Iterations)
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{name: "p50", sorted: sorted, p: 0.50, want: 50 * time.Millisecond},
+		{name: "p99", sorted: sorted, p: 0.99, want: 99 * time.Millisecond},
+		{name: "p100", sorted: sorted, p: 1, want: 100 * time.Millisecond},
+		{name: "single", sorted: sorted[:1], p: 0.99, want: time.Millisecond},
+		{name: "small set", sorted: sorted[:10], p: 0.99, want: 10 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, percentile(tt.sorted, tt.p))
+		})
+	}
+}
+
+func TestBudget_Check(t *testing.T) {
+	result := Result{P99: 10 * time.Millisecond, AllocsPerOp: 100, BytesPerOp: 4096}
+
+	tests := []struct {
+		name    string
+		budget  Budget
+		wantErr []string
+	}{
+		{name: "within budget", budget: Budget{P99: 20 * time.Millisecond, AllocsPerOp: 100, BytesPerOp: 8192}},
+		{name: "not enforced", budget: Budget{}},
+		{name: "latency", budget: Budget{P99: 5 * time.Millisecond}, wantErr: []string{"p99 latency"}},
+		{name: "allocations", budget: Budget{AllocsPerOp: 99}, wantErr: []string{"allocs/op"}},
+		{
+			name:    "all",
+			budget:  Budget{P99: time.Millisecond, AllocsPerOp: 1, BytesPerOp: 1},
+			wantErr: []string{"p99 latency", "allocs/op", "B/op"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.budget.Check(result)
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+// TestBudgets enforces the performance budgets published in docs/performance.md
+// on the signing and storage path of /api/v1/{file}.
+func TestBudgets(t *testing.T) {
+	if os.Getenv(budgetEnv) == "" {
+		t.Skipf("set %s=1 to enforce performance budgets", budgetEnv)
+	}
+
+	s := newSigner(t)
+	keys := domainKeys(largeKeySet)
+	list := make([]types.DomainKey, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, key)
+	}
+
+	store, err := memory.New(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, store.SaveKeys(keys))
+
+	tests := []struct {
+		name       string
+		iterations int
+		budget     Budget
+		fn         func()
+	}{
+		{
+			name:       "sign file",
+			iterations: 200,
+			budget:     Budget{P99: 100 * time.Millisecond, AllocsPerOp: 80_000},
+			fn: func() {
+				_, err := types.SignedKeys("large.json", list, s)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:       "sign file with snake case naming",
+			iterations: 200,
+			budget:     Budget{P99: 100 * time.Millisecond, AllocsPerOp: 80_000},
+			fn: func() {
+				_, err := types.SignedKeysWithNaming("large.json", list, s, types.NamingSnake)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:       "memory get by file",
+			iterations: 1000,
+			budget:     Budget{P99: 5 * time.Millisecond, AllocsPerOp: 50},
+			fn: func() {
+				got, _, err := store.GetByFile("large.json")
+				require.NoError(t, err)
+				require.Len(t, got, largeKeySet)
+			},
+		},
+		{
+			name:       "memory concurrent flush",
+			iterations: 200,
+			budget:     Budget{P99: 25 * time.Millisecond, AllocsPerOp: 200},
+			fn: func() {
+				concurrentFlush(t, store, keys, 4)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Measure(tt.iterations, tt.fn)
+			t.Log(r)
+
+			assert.NoError(t, tt.budget.Check(r))
+		})
+	}
+}
+
+// concurrentFlush saves keys from workers goroutines while as many readers fetch the file.
+func concurrentFlush(t *testing.T, store types.Storage, keys map[string]types.DomainKey, workers int) {
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Go(func() {
+			assert.NoError(t, store.SaveKeys(keys))
+		})
+		wg.Go(func() {
+			_, _, err := store.GetByFile("large.json")
+			assert.NoError(t, err)
+		})
+	}
+
+	wg.Wait()
+}
+
+// domainKeys returns n keys of large.json.
+func domainKeys(n int) map[string]types.DomainKey {
+	now := time.Now().UTC()
+	keys := make(map[string]types.DomainKey, n)
+
+	for i := range n {
+		fqdn := fmt.Sprintf("host-%04d.example.com", i)
+		keys[fqdn] = types.DomainKey{
+			Date:       &now,
+			DomainName: "*.example.com",
+			Expire:     now.Add(90 * 24 * time.Hour).Unix(),
+			File:       "large.json",
+			Fqdn:       fqdn,
+			Key:        fmt.Sprintf("%044d", i),
+		}
+	}
+
+	return keys
+}
+
+// newSigner returns a signer with a 4096 bit RSA key, matching task generate-keys.
+func newSigner(t *testing.T) *signer.Signer {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "prv.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	s, err := signer.NewSigner(path)
+	require.NoError(t, err)
+
+	return s
+}
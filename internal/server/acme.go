@@ -0,0 +1,124 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACME configures certificates obtained and renewed automatically from an ACME CA, e.g. Let's Encrypt.
+// Certificates are requested for Hosts only and stored in CacheDir, so that they survive restarts.
+// Challenges are answered with TLS-ALPN-01 on the address of the server and, with HTTPListen, with HTTP-01
+// on that address, which redirects all other requests to HTTPS. ACME is disabled when Hosts is empty.
+type ACME struct {
+	CacheDir     string
+	DirectoryURL string
+	Email        string
+	Hosts        []string
+	HTTPListen   string
+}
+
+// enabled reports whether certificates are obtained via ACME.
+func (a ACME) enabled() bool {
+	return len(a.Hosts) > 0
+}
+
+// manager returns the autocert manager of the configuration. The terms of service of the CA are accepted.
+func (a ACME) manager() *autocert.Manager {
+	m := &autocert.Manager{
+		Cache:      autocert.DirCache(a.CacheDir),
+		Email:      a.Email,
+		HostPolicy: autocert.HostWhitelist(a.Hosts...),
+		Prompt:     autocert.AcceptTOS,
+	}
+
+	if a.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: a.DirectoryURL}
+	}
+
+	return m
+}
+
+// WithACME returns an option that serves HTTPS with certificates obtained and renewed via ACME.
+// It takes precedence over WithTLS.
+func WithACME(a ACME) Option {
+	return func(s *Server) {
+		s.acme = a
+	}
+}
+
+// serveACME makes the TLS configuration of the server serve the certificates of m and answer TLS-ALPN-01
+// challenges. The challenge handshakes use a configuration of their own, as the CA presents no client
+// certificate with mTLS. With HTTPListen it serves HTTP-01 challenges until ctx is done.
+func (s *Server) serveACME(ctx context.Context, m *autocert.Manager) {
+	cfg := s.http.TLSConfig
+	cfg.GetCertificate = m.GetCertificate
+	cfg.NextProtos = append(cfg.NextProtos, "h2", "http/1.1", acme.ALPNProto)
+	cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+			return m.TLSConfig(), nil
+		}
+
+		return nil, nil
+	}
+
+	if s.acme.HTTPListen == "" {
+		return
+	}
+
+	challenges := &http.Server{
+		Addr:              s.acme.HTTPListen,
+		Handler:           m.HTTPHandler(nil),
+		ReadHeaderTimeout: s.http.ReadTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		challenges.Close()
+	}()
+
+	go func() {
+		slog.Info("start acme http-01 server", "addr", challenges.Addr)
+
+		if err := challenges.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.errs <- err
+		}
+	}()
+}
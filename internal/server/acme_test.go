@@ -0,0 +1,98 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+)
+
+func TestACME_manager(t *testing.T) {
+	a := ACME{
+		CacheDir:     t.TempDir(),
+		DirectoryURL: "https://acme-staging-v02.api.letsencrypt.org/directory",
+		Email:        "ops@example.com",
+		Hosts:        []string{"pins.example.com"},
+	}
+	require.True(t, a.enabled())
+	assert.False(t, ACME{CacheDir: t.TempDir()}.enabled())
+
+	m := a.manager()
+	assert.Equal(t, "ops@example.com", m.Email)
+	require.NotNil(t, m.Client)
+	assert.Equal(t, a.DirectoryURL, m.Client.DirectoryURL)
+	assert.True(t, m.Prompt("https://letsencrypt.org/tos"))
+	assert.NoError(t, m.HostPolicy(context.Background(), "pins.example.com"))
+	assert.Error(t, m.HostPolicy(context.Background(), "other.example.com"))
+
+	assert.Nil(t, ACME{Hosts: a.Hosts}.manager().Client)
+}
+
+func TestServer_serveACME(t *testing.T) {
+	ca := newTestCA(t)
+	s := NewServer(
+		WithACME(ACME{CacheDir: t.TempDir(), Hosts: []string{"pins.example.com"}}),
+		WithClientAuth(ca.pool(), nil),
+	)
+
+	s.http.TLSConfig = s.tlsConfig()
+	require.NotNil(t, s.http.TLSConfig)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, s.http.TLSConfig.ClientAuth)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.serveACME(ctx, s.acme.manager())
+
+	cfg := s.http.TLSConfig
+	assert.NotNil(t, cfg.GetCertificate)
+	assert.Equal(t, []string{"h2", "http/1.1", acme.ALPNProto}, cfg.NextProtos)
+
+	t.Run("tls-alpn-01 challenge without client certificate", func(t *testing.T) {
+		challenge, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: []string{acme.ALPNProto}})
+		require.NoError(t, err)
+		require.NotNil(t, challenge)
+		assert.Equal(t, tls.NoClientCert, challenge.ClientAuth)
+		assert.Contains(t, challenge.NextProtos, acme.ALPNProto)
+	})
+
+	t.Run("regular handshake", func(t *testing.T) {
+		regular, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{SupportedProtos: []string{"h2", "http/1.1"}})
+		require.NoError(t, err)
+		assert.Nil(t, regular)
+	})
+}
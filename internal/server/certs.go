@@ -0,0 +1,186 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WithCertReload returns an option that checks the certificate and key files of WithTLS for changes
+// every interval, in addition to watching their directories. Changed files are loaded without a
+// restart, e.g. renewed by cert-manager. The files are only watched when interval is not positive.
+func WithCertReload(interval time.Duration) Option {
+	return func(s *Server) {
+		s.certReloadInterval = interval
+	}
+}
+
+// certReloader serves the certificate of a certificate and key file pair and reloads it when the
+// files change. A pair that fails to load, e.g. while it is being written, is logged and the
+// current certificate is kept.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	version string
+}
+
+// newCertReloader loads the certificate of the files.
+// Returns an error if the files cannot be read or do not contain a matching certificate and key.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// getCertificate returns the current certificate, see tls.Config.GetCertificate.
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cert, nil
+}
+
+// fileVersion returns the size and modification time of the files, following symbolic links,
+// which change whenever either file is replaced.
+func (c *certReloader) fileVersion() (string, error) {
+	var version string
+
+	for _, file := range []string{c.certFile, c.keyFile} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+
+		version += fmt.Sprintf("%d:%d;", fi.Size(), fi.ModTime().UnixNano())
+	}
+
+	return version, nil
+}
+
+// reload loads the files if they changed since the last load and reports whether the certificate
+// was replaced.
+func (c *certReloader) reload() (bool, error) {
+	version, err := c.fileVersion()
+	if err != nil {
+		return false, fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+
+	c.mu.RLock()
+	unchanged := version == c.version
+	c.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.version = version
+	c.mu.Unlock()
+
+	return true, nil
+}
+
+// watch reloads the certificate on changes in the directories of the files and every interval
+// if positive, until ctx is done. Directories are watched rather than the files, so that files
+// replaced by a rename or a symbolic link swap, as of Kubernetes secrets, are seen.
+func (c *certReloader) watch(ctx context.Context, interval time.Duration) {
+	var events <-chan fsnotify.Event
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("failed to watch TLS certificate files", "error", err)
+	} else {
+		defer watcher.Close()
+
+		dirs := []string{filepath.Dir(c.certFile), filepath.Dir(c.keyFile)}
+		slices.Sort(dirs)
+
+		for _, dir := range slices.Compact(dirs) {
+			if err := watcher.Add(dir); err != nil {
+				slog.Warn("failed to watch TLS certificate directory", "dir", dir, "error", err)
+			}
+		}
+
+		events = watcher.Events
+	}
+
+	var tick <-chan time.Time
+
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-events:
+		case <-tick:
+		}
+
+		reloaded, err := c.reload()
+		if err != nil {
+			slog.Warn("keeping the current TLS certificate", "cert_file", c.certFile, "error", err)
+			continue
+		}
+
+		if reloaded {
+			slog.Info("TLS certificate reloaded", "cert_file", c.certFile)
+		}
+	}
+}
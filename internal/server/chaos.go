@@ -0,0 +1,81 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Chaos defines artificial latency of requests, e.g. to test the timeouts and retries of clients
+// against a staging instance. Every request is delayed by Latency plus a random duration of up to
+// Jitter. Requests are not delayed when both are zero.
+type Chaos struct {
+	Jitter  time.Duration
+	Latency time.Duration
+}
+
+// enabled reports whether requests are delayed.
+func (c Chaos) enabled() bool {
+	return c.Latency > 0 || c.Jitter > 0
+}
+
+// delay returns the latency of a request.
+func (c Chaos) delay() time.Duration {
+	if c.Jitter <= 0 {
+		return c.Latency
+	}
+
+	return c.Latency + rand.N(c.Jitter+1)
+}
+
+// WithChaos returns an option that delays every request before it is handled.
+func WithChaos(c Chaos) Option {
+	return func(s *Server) {
+		s.chaos = c
+	}
+}
+
+// chaos wraps next to delay requests. Requests cancelled while delayed are not handled.
+func chaos(next http.Handler, c Chaos) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := time.NewTimer(c.delay())
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+			next.ServeHTTP(w, r)
+		case <-r.Context().Done():
+		}
+	})
+}
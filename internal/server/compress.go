@@ -0,0 +1,275 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// encoder is a content coding the server compresses responses with.
+type encoder struct {
+	name   string
+	writer func(w io.Writer) io.WriteCloser
+}
+
+// encoders lists the supported content codings in order of preference.
+var encoders = []encoder{
+	{
+		name: "br",
+		writer: func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriter(w)
+		},
+	},
+	{
+		name: "gzip",
+		writer: func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+	},
+}
+
+// WithCompression returns an option that compresses response bodies of at least minSize bytes
+// with a content coding negotiated via the Accept-Encoding header of the request.
+func WithCompression(enabled bool, minSize int) Option {
+	return func(s *Server) {
+		s.compression = enabled
+		s.compressionMinSize = minSize
+	}
+}
+
+// compress wraps next with response compression of bodies of at least minSize bytes.
+func compress(next http.Handler, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		enc, ok := negotiate(r.Header.Get("Accept-Encoding"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoder: enc, minSize: minSize}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate returns the encoder with the highest quality in the Accept-Encoding header,
+// preferring the order of encoders on ties. Codings with a quality of zero are refused,
+// "*" sets the quality of any coding not listed explicitly, and no encoder is returned
+// when identity is preferred over every accepted coding.
+func negotiate(header string) (encoder, bool) {
+	accepted := map[string]float64{}
+
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		accepted[name] = q
+	}
+
+	var (
+		best  encoder
+		bestQ float64
+	)
+
+	for _, enc := range encoders {
+		q, listed := accepted[enc.name]
+		if !listed {
+			q = accepted["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	if bestQ == 0 {
+		return encoder{}, false
+	}
+
+	if q, listed := accepted["identity"]; listed && q > bestQ {
+		return encoder{}, false
+	}
+
+	return best, true
+}
+
+// compressWriter buffers the start of a response until minSize bytes are written
+// and compresses the body from then on. Smaller bodies, responses other than 200 OK
+// and responses already carrying a Content-Encoding are passed through unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	buf     []byte
+	decided bool
+	encoder encoder
+	minSize int
+	status  int
+	writer  io.WriteCloser
+}
+
+// WriteHeader delays 200 OK until the size of the body is known; other statuses are written immediately.
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+
+	cw.status = code
+	if code != http.StatusOK {
+		cw.start(false)
+	}
+}
+
+// Write buffers p until the body reaches minSize bytes, then writes it compressed.
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+
+		return len(p), nil
+	}
+
+	if cw.writer != nil {
+		return cw.writer.Write(p)
+	}
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush writes buffered data to the client if the underlying writer supports it.
+func (cw *compressWriter) Flush() {
+	if !cw.decided && cw.status != 0 {
+		cw.start(len(cw.buf) >= cw.minSize)
+	}
+
+	if f, ok := cw.writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack takes over the connection of the underlying writer, e.g. for WebSockets.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(cw.ResponseWriter).Hijack()
+	if err == nil {
+		cw.decided = true
+	}
+
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close writes a body smaller than minSize uncompressed and finishes the compressed stream.
+func (cw *compressWriter) Close() error {
+	if !cw.decided && cw.status != 0 {
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+
+	return nil
+}
+
+// start writes the header, compressed if requested and applicable, followed by the buffered body.
+// The ETag is weakened as the compressed body is no longer byte-identical to the tagged payload,
+// which still lets If-None-Match revalidate against it.
+func (cw *compressWriter) start(compressed bool) error {
+	cw.decided = true
+
+	h := cw.Header()
+	if compressed && cw.status == http.StatusOK && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", cw.encoder.name)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		cw.writer = cw.encoder.writer(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if cw.writer != nil {
+		_, err := cw.writer.Write(buf)
+		return err
+	}
+
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
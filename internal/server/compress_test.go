@@ -0,0 +1,202 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "empty", header: "", want: ""},
+		{name: "gzip", header: "gzip", want: "gzip"},
+		{name: "br", header: "br", want: "br"},
+		{name: "list prefers br", header: "gzip, deflate, br", want: "br"},
+		{name: "unsupported only", header: "deflate, zstd", want: ""},
+		{name: "case insensitive", header: "GZIP", want: "gzip"},
+		{name: "quality", header: "gzip;q=0.5", want: "gzip"},
+		{name: "higher quality wins", header: "br;q=0.5, gzip;q=0.8", want: "gzip"},
+		{name: "equal quality prefers br", header: "gzip;q=0.5, br;q=0.5", want: "br"},
+		{name: "refused", header: "gzip;q=0", want: ""},
+		{name: "refused br", header: "br;q=0, gzip", want: "gzip"},
+		{name: "invalid quality", header: "br;q=high", want: "br"},
+		{name: "wildcard", header: "*", want: "br"},
+		{name: "wildcard with refused br", header: "*, br;q=0", want: "gzip"},
+		{name: "wildcard with quality", header: "gzip, *;q=0.1", want: "gzip"},
+		{name: "wildcard refused", header: "*;q=0", want: ""},
+		{name: "identity", header: "identity", want: ""},
+		{name: "identity preferred", header: "identity, gzip;q=0.5", want: ""},
+		{name: "identity less preferred", header: "identity;q=0.5, gzip", want: "gzip"},
+		{name: "identity refused", header: "identity;q=0, br", want: "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, _ := negotiate(tt.header)
+			assert.Equal(t, tt.want, enc.name)
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("pin", 100)
+
+	handler := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+
+		switch r.URL.Path {
+		case "/small":
+			io.WriteString(w, "{}")
+		case "/missing":
+			http.Error(w, body, http.StatusNotFound)
+		case "/encoded":
+			w.Header().Set("Content-Encoding", "gzip")
+			io.WriteString(w, body)
+		default:
+			io.WriteString(w, body[:100])
+			io.WriteString(w, body[100:])
+		}
+	}), 64)
+
+	serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("compressed", func(t *testing.T) {
+		w := serve("/", "gzip")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal(t, `W/"abc"`, w.Header().Get("ETag"))
+		assert.Less(t, w.Body.Len(), len(body))
+
+		zr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, body, string(data))
+	})
+
+	t.Run("brotli", func(t *testing.T) {
+		w := serve("/", "gzip, br")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, `W/"abc"`, w.Header().Get("ETag"))
+		assert.Less(t, w.Body.Len(), len(body))
+
+		data, err := io.ReadAll(brotli.NewReader(bytes.NewReader(w.Body.Bytes())))
+		require.NoError(t, err)
+		assert.Equal(t, body, string(data))
+	})
+
+	t.Run("identity preferred", func(t *testing.T) {
+		w := serve("/", "identity, gzip;q=0.5")
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, body, w.Body.String())
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		w := serve("/", "")
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		assert.Equal(t, `"abc"`, w.Header().Get("ETag"))
+		assert.Equal(t, body, w.Body.String())
+	})
+
+	t.Run("below min size", func(t *testing.T) {
+		w := serve("/small", "gzip")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "{}", w.Body.String())
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		w := serve("/missing", "gzip")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, body+"\n", w.Body.String())
+	})
+
+	t.Run("already encoded", func(t *testing.T) {
+		w := serve("/encoded", "gzip")
+
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, body, w.Body.String())
+	})
+}
+
+func TestServer_handler_Compression(t *testing.T) {
+	s := NewServer(
+		WithCompression(true, 0),
+		WithHandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "test response")
+		}),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, r)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+}
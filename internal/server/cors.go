@@ -0,0 +1,160 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS defines the cross-origin requests allowed by the server.
+// AllowedOrigins lists origins such as "https://dashboard.example.com" or "*" for any origin;
+// CORS headers are only sent when it is not empty. AllowedHeaders may contain "*" to allow any
+// request header. ExposedHeaders are made readable to scripts and MaxAge is the lifetime of preflight results.
+type CORS struct {
+	AllowedHeaders []string
+	AllowedMethods []string
+	AllowedOrigins []string
+	ExposedHeaders []string
+	MaxAge         time.Duration
+}
+
+// WithCORS returns an option that answers preflight requests and adds CORS headers to responses
+// of requests from the allowed origins.
+func WithCORS(cors CORS) Option {
+	return func(s *Server) {
+		s.cors = cors
+	}
+}
+
+// enabled reports whether any origin is allowed.
+func (c CORS) enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin header for origin, or false if it is not allowed.
+func (c CORS) allowOrigin(origin string) (string, bool) {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return "*", true
+		}
+
+		if strings.EqualFold(o, origin) {
+			return origin, true
+		}
+	}
+
+	return "", false
+}
+
+// allowMethod reports whether method may be used in cross-origin requests.
+func (c CORS) allowMethod(method string) bool {
+	return slices.ContainsFunc(c.AllowedMethods, func(m string) bool {
+		return strings.EqualFold(m, method)
+	})
+}
+
+// allowHeaders reports whether all headers of the comma separated list may be sent in cross-origin requests.
+func (c CORS) allowHeaders(list string) bool {
+	if slices.Contains(c.AllowedHeaders, "*") {
+		return true
+	}
+
+	for header := range strings.SplitSeq(list, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+
+		if !slices.ContainsFunc(c.AllowedHeaders, func(h string) bool {
+			return strings.EqualFold(h, header)
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cors wraps next with CORS handling. Preflight requests are answered with 204 No Content
+// and never reach next; the CORS headers are omitted when the request is not allowed,
+// which makes the browser reject it.
+func cors(next http.Handler, c CORS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		allowed, ok := c.allowOrigin(origin)
+
+		method := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == http.MethodOptions && method != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+
+			requested := r.Header.Get("Access-Control-Request-Headers")
+			if ok && c.allowMethod(method) && c.allowHeaders(requested) {
+				h.Set("Access-Control-Allow-Origin", allowed)
+				h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+
+				if requested != "" {
+					h.Set("Access-Control-Allow-Headers", requested)
+				}
+
+				if c.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+				}
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if ok {
+			h.Set("Access-Control-Allow-Origin", allowed)
+
+			if len(c.ExposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,160 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	c := CORS{
+		AllowedHeaders: []string{"Accept", "If-None-Match"},
+		AllowedMethods: []string{"GET", "HEAD"},
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+		ExposedHeaders: []string{"ETag"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	handler := cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "pins")
+	}), c)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		status  int
+		want    map[string]string
+	}{
+		{
+			name:   "same origin",
+			method: http.MethodGet,
+			status: http.StatusOK,
+			want:   map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		},
+		{
+			name:    "allowed origin",
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://dashboard.example.com"},
+			status:  http.StatusOK,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":   "https://dashboard.example.com",
+				"Access-Control-Expose-Headers": "ETag",
+				"Vary":                          "Origin",
+			},
+		},
+		{
+			name:    "disallowed origin",
+			method:  http.MethodGet,
+			headers: map[string]string{"Origin": "https://evil.example.com"},
+			status:  http.StatusOK,
+			want:    map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			name:   "preflight",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://dashboard.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "if-none-match",
+			},
+			status: http.StatusNoContent,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":  "https://dashboard.example.com",
+				"Access-Control-Allow-Methods": "GET, HEAD",
+				"Access-Control-Allow-Headers": "if-none-match",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:   "preflight with disallowed method",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://dashboard.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			status: http.StatusNoContent,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight with disallowed header",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://dashboard.example.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "Authorization",
+			},
+			status: http.StatusNoContent,
+			want:   map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/v1/pins.json", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			for k, v := range tt.want {
+				assert.Equal(t, v, w.Header().Get(k), k)
+			}
+		})
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	c := CORS{AllowedMethods: []string{"GET"}, AllowedOrigins: []string{"*"}}
+
+	s := NewServer(
+		WithCORS(c),
+		WithHandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	r.Header.Set("Origin", "https://any.example.com")
+
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, r)
+
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+func TestServer_SecurityHeaders(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		tls      bool
+		wantHSTS string
+	}{
+		{name: "disabled", enabled: false},
+		{name: "plain http", enabled: true},
+		{name: "tls", enabled: true, tls: true, wantHSTS: hstsMaxAge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(
+				WithSecurityHeaders(tt.enabled),
+				WithHandleFunc("GET /test", func(w http.ResponseWriter, r *http.Request) {}),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/unknown", nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			w := httptest.NewRecorder()
+
+			s.handler().ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, tt.wantHSTS, w.Header().Get("Strict-Transport-Security"))
+
+			if !tt.enabled {
+				assert.Empty(t, w.Header().Get("X-Frame-Options"))
+				return
+			}
+
+			assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+			assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+			assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+		})
+	}
+}
+
+func TestServer_MethodNotAllowed(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	s := NewServer(
+		WithHandleFunc("GET /api/v1/{file}", func(w http.ResponseWriter, r *http.Request) {}),
+		WithHandleFunc("POST /api/v1/verify", func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{method: http.MethodGet, path: "/api/v1/app.json", wantStatus: http.StatusOK},
+		{method: http.MethodHead, path: "/api/v1/app.json", wantStatus: http.StatusOK},
+		{method: http.MethodPost, path: "/api/v1/app.json", wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD"},
+		{method: http.MethodPut, path: "/api/v1/app.json", wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD"},
+		{method: http.MethodPost, path: "/api/v1/verify", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.handler().ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantAllow, w.Header().Get("Allow"))
+		})
+	}
+}
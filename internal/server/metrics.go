@@ -0,0 +1,84 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestObserver records metrics of the requests served by a server.
+type RequestObserver interface {
+	// AddInFlight adds delta to the number of requests being served.
+	AddInFlight(delta int64)
+	// ObserveRequest records a served request of route, its method, status and duration.
+	ObserveRequest(route, method string, status int, d time.Duration)
+}
+
+// WithMetrics returns an option that reports every request to observer.
+func WithMetrics(observer RequestObserver) Option {
+	return func(s *Server) {
+		s.observer = observer
+	}
+}
+
+// route returns the path pattern of the mux route that served r, or "unmatched" if none did,
+// e.g. for rate limited requests and unknown paths. Patterns keep the cardinality of metrics
+// bounded unlike request paths.
+func route(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+
+	return r.Pattern
+}
+
+// observe wraps next to report its requests to observer. The mux sets the pattern of the route
+// on the request, so observe must not be wrapped inside middleware replacing the request.
+func observe(next http.Handler, observer RequestObserver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		observer.AddInFlight(1)
+		defer observer.AddInFlight(-1)
+
+		sw := &statusWriter{ResponseWriter: w}
+		start := time.Now()
+
+		next.ServeHTTP(sw, r)
+
+		observer.ObserveRequest(route(r), r.Method, sw.statusCode(), time.Since(start))
+	})
+}
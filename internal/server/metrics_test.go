@@ -0,0 +1,196 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+// recordingObserver records the requests reported to it.
+type recordingObserver struct {
+	mu       sync.Mutex
+	inFlight int64
+	peak     int64
+	requests []string
+}
+
+func (o *recordingObserver) AddInFlight(delta int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.inFlight += delta
+	o.peak = max(o.peak, o.inFlight)
+}
+
+func (o *recordingObserver) ObserveRequest(route, method string, status int, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.requests = append(o.requests, method+" "+route+" "+http.StatusText(status))
+}
+
+func TestServer_Metrics(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	o := new(recordingObserver)
+
+	s := NewServer(
+		WithAccessLog(true),
+		WithMetrics(o),
+		WithRateLimit(RateLimit{Burst: 2, Rate: 0.1}),
+		WithHandleFunc("GET /api/v1/{file}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	h := s.handler()
+
+	for _, path := range []string{"/api/v1/pins.json", "/unknown", "/api/v1/other.json"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, []string{
+		"GET /api/v1/{file} OK",
+		"GET unmatched Not Found",
+		"GET unmatched Too Many Requests",
+	}, o.requests)
+	assert.Equal(t, int64(0), o.inFlight)
+	assert.Equal(t, int64(1), o.peak)
+}
+
+func TestServer_Hijack(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	o := new(recordingObserver)
+
+	s := NewServer(
+		WithAccessLog(true),
+		WithCompression(true, 1),
+		WithMetrics(o),
+		WithRecovery(nil),
+		WithTracing(true),
+		WithHandleFunc("GET /ws", func(w http.ResponseWriter, r *http.Request) {
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Hijack() error = %v", err)
+				return
+			}
+			defer conn.Close()
+
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+			rw.Flush()
+		}),
+	)
+
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nAccept-Encoding: gzip\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+	require.NoError(t, err)
+
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 101 Switching Protocols\r\n", status)
+
+	require.Eventually(t, func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		return len(o.requests) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	assert.Equal(t, []string{"GET /ws Switching Protocols"}, o.requests)
+}
+
+func TestServer_Flush(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	release := make(chan struct{})
+
+	s := NewServer(
+		WithAccessLog(true),
+		WithCompression(true, 1),
+		WithMetrics(new(recordingObserver)),
+		WithRecovery(nil),
+		WithTracing(true),
+		WithHandleFunc("GET /stream", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "data: first\n\n")
+
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Errorf("Flush() error = %v", err)
+			}
+
+			<-release
+		}),
+	)
+
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+	defer close(release)
+
+	res, err := http.Get(srv.URL + "/stream")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	// the event is received while the handler still runs
+	line := make(chan string, 1)
+	go func() {
+		l, _ := bufio.NewReader(res.Body).ReadString('\n')
+		line <- l
+	}()
+
+	select {
+	case l := <-line:
+		assert.Equal(t, "data: first\n", l)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not flushed")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// WithMiddleware returns an option that applies middleware to all routes of the server, see Use.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(s *Server) {
+		s.Use(middleware...)
+	}
+}
+
+// Use applies middleware to all routes of the server, including routes registered before, e.g. to
+// authenticate, log or instrument every request. The first middleware is the outermost one. Unlike the
+// built-in middleware of the server (see handler) it runs once the mux matched a route, so it may replace
+// the request, e.g. to add values to its context, and r.Pattern is set. Use must be called before Up.
+func (s *Server) Use(middleware ...func(http.Handler) http.Handler) {
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// routeHandler is a handler of the mux wrapped with the middleware of the server on its first request,
+// so that middleware added after the route was registered applies as well.
+type routeHandler struct {
+	server  *Server
+	handler http.Handler
+
+	once    sync.Once
+	wrapped http.Handler
+}
+
+// routed returns h wrapped with the middleware of the server.
+func (s *Server) routed(h http.Handler) http.Handler {
+	return &routeHandler{server: s, handler: h}
+}
+
+// ServeHTTP implements http.Handler.
+func (rt *routeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.once.Do(func() {
+		rt.wrapped = rt.handler
+
+		for _, m := range slices.Backward(rt.server.middleware) {
+			rt.wrapped = m(rt.wrapped)
+		}
+	})
+
+	rt.wrapped.ServeHTTP(w, r)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+type contextKey struct{}
+
+// tag returns a middleware appending name to the X-Chain response header and the request context.
+func tag(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", name)
+
+			chain, _ := r.Context().Value(contextKey{}).(string)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, chain+name)))
+		})
+	}
+}
+
+func TestServer_Use(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	o := new(recordingObserver)
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		chain, _ := r.Context().Value(contextKey{}).(string)
+		_, _ = io.WriteString(w, r.Pattern+" "+chain)
+	}
+
+	s := NewServer(
+		WithMetrics(o),
+		WithMiddleware(tag("a")),
+		WithHandleFunc("GET /before", echo),
+	)
+	s.Use(tag("b"), tag("c"))
+	s.SetHandleFunc("GET /after/{id}", echo)
+
+	h := s.handler()
+
+	for _, path := range []string{"/before", "/after/1"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, []string{"a", "b", "c"}, w.Header().Values("X-Chain"))
+			assert.True(t, strings.HasSuffix(w.Body.String(), " abc"), w.Body.String())
+		})
+	}
+
+	t.Run("unmatched", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Header().Values("X-Chain"))
+	})
+
+	// routes are reported although the middleware replaced the request
+	assert.Equal(t, []string{
+		"GET /before OK",
+		"GET /after/{id} OK",
+		"GET unmatched Not Found",
+	}, o.requests)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval is how often buckets of idle clients are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit defines per-client rate limiting of requests with a token bucket per client.
+// Every client may send Burst requests at once and Rate requests per second on average;
+// Key identifies the client of a request and defaults to ClientIP. Requests are not
+// limited when Rate is not positive.
+type RateLimit struct {
+	Burst int
+	Key   func(r *http.Request) string
+	Rate  float64
+}
+
+// WithRateLimit returns an option that answers requests of clients exceeding the limit
+// with 429 Too Many Requests and a Retry-After header.
+func WithRateLimit(limit RateLimit) Option {
+	return func(s *Server) {
+		s.rateLimit = limit
+	}
+}
+
+// ClientIP returns the IP address of the remote end of the connection of r.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// bucket is the token bucket of a client.
+type bucket struct {
+	last   time.Time
+	tokens float64
+}
+
+// rateLimiter holds the buckets of all clients. Buckets refilled completely are dropped
+// periodically, as a new full bucket is equivalent.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	burst   float64
+	rate    float64
+	swept   time.Time
+}
+
+// newRateLimiter creates a limiter with rate requests per second and bursts of burst requests per client.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*bucket),
+		burst:   float64(max(burst, 1)),
+		rate:    rate,
+		swept:   time.Now(),
+	}
+}
+
+// allow takes a token of the bucket of client and returns 0, or how long to wait for the next
+// token if the bucket is empty. Rejected requests do not take a token.
+func (l *rateLimiter) allow(client string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{last: now, tokens: l.burst}
+		l.buckets[client] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return 0
+}
+
+// sweep drops the buckets that are full by now.
+func (l *rateLimiter) sweep(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+
+	l.swept = now
+}
+
+// rateLimit wraps next with per-client rate limiting.
+func rateLimit(next http.Handler, limit RateLimit) http.Handler {
+	l := newRateLimiter(limit.Rate, limit.Burst)
+
+	key := limit.Key
+	if key == nil {
+		key = ClientIP
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait := l.allow(key(r), time.Now()); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
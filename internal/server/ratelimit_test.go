@@ -0,0 +1,113 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_allow(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Now()
+
+	for i := range 3 {
+		assert.Zero(t, l.allow("a", now), "request %d within burst", i)
+	}
+
+	assert.Equal(t, 500*time.Millisecond, l.allow("a", now))
+	assert.Zero(t, l.allow("b", now), "clients have their own bucket")
+
+	now = now.Add(500 * time.Millisecond)
+	assert.Zero(t, l.allow("a", now))
+	assert.Equal(t, 500*time.Millisecond, l.allow("a", now))
+}
+
+func TestRateLimiter_sweep(t *testing.T) {
+	l := newRateLimiter(1, 2)
+	now := time.Now()
+
+	l.allow("idle", now)
+	l.allow("busy", now)
+	l.allow("busy", now)
+
+	l.sweep(now.Add(time.Second))
+
+	assert.NotContains(t, l.buckets, "idle")
+	assert.Contains(t, l.buckets, "busy")
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	s := NewServer(
+		WithRateLimit(RateLimit{Burst: 1, Rate: 0.1}),
+		WithHandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	h := s.handler()
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/test", nil)
+		r.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("192.0.2.1:1234").Code)
+
+	w := serve("192.0.2.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, serve("192.0.2.2:1234").Code)
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	r.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "192.0.2.1", ClientIP(r))
+
+	r.RemoteAddr = "[2001:db8::1]:1234"
+	assert.Equal(t, "2001:db8::1", ClientIP(r))
+
+	r.RemoteAddr = "pipe"
+	assert.Equal(t, "pipe", ClientIP(r))
+}
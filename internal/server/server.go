@@ -33,11 +33,15 @@ package server
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
+
+	"ssl-pinning/internal/tracing"
 )
 
 // Option is a functional option type for configuring Server instance.
@@ -47,10 +51,30 @@ type Option func(*Server)
 // It wraps http.Server with context-based lifecycle control, custom routing via ServeMux,
 // and error handling through a dedicated error channel.
 type Server struct {
-	ctx  context.Context
-	errs chan error
-	http *http.Server
-	mux  *http.ServeMux
+	accessLog          bool
+	acme               ACME
+	certFile           string
+	certReloadInterval time.Duration
+	chaos              Chaos
+	clientCAs          *x509.CertPool
+	clientNames        []string
+	compression        bool
+	compressionMinSize int
+	cors               CORS
+	ctx                context.Context
+	drainTimeout       time.Duration
+	errs               chan error
+	http               *http.Server
+	keyFile            string
+	middleware         []func(http.Handler) http.Handler
+	mux                *http.ServeMux
+	observer           RequestObserver
+	onPanic            func(r *http.Request)
+	pathPrefix         string
+	rateLimit          RateLimit
+	recovery           bool
+	securityHeaders    bool
+	tracing            bool
 	// storage types.Storage
 }
 
@@ -100,6 +124,43 @@ func WithWriteTimeout(d time.Duration) Option {
 	}
 }
 
+// WithTracing returns an option that wraps all handlers with an OpenTelemetry server span
+// continuing the W3C trace context of incoming requests.
+func WithTracing(enabled bool) Option {
+	return func(s *Server) {
+		s.tracing = enabled
+	}
+}
+
+// WithHTTP2 returns an option that enables or disables HTTP/2 over TLS, so that clients like API gateways
+// can multiplex many requests on one connection. With cleartext, HTTP/2 without TLS (h2c, with prior
+// knowledge) is served as well, e.g. behind a load balancer terminating TLS. HTTP/1.1 is always served.
+func WithHTTP2(enabled, cleartext bool) Option {
+	return func(s *Server) {
+		s.http.Protocols = new(http.Protocols)
+		s.http.Protocols.SetHTTP1(true)
+		s.http.Protocols.SetHTTP2(enabled)
+		s.http.Protocols.SetUnencryptedHTTP2(enabled && cleartext)
+	}
+}
+
+// WithDrainTimeout returns an option that limits how long Down waits for in-flight requests to complete
+// before it closes their connections. Down waits until all requests completed when d is not positive.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.drainTimeout = d
+	}
+}
+
+// WithPathPrefix returns an option that serves the handlers registered after it below prefix,
+// e.g. "/ssl-pinning" serves a handler of "GET /metrics" at "GET /ssl-pinning/metrics".
+// It must precede WithHandleFunc in the options.
+func WithPathPrefix(prefix string) Option {
+	return func(s *Server) {
+		s.pathPrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
 // WithAddr returns an option that sets the TCP address for the server to listen on.
 // Format: "host:port" (e.g., "127.0.0.1:8080" or ":8080" for all interfaces).
 func WithAddr(addr string) Option {
@@ -110,12 +171,27 @@ func WithAddr(addr string) Option {
 
 // SetHandleFunc registers an HTTP handler function for the specified pattern in the server's mux.
 func (s *Server) SetHandleFunc(pattern string, handlerFunc http.HandlerFunc) {
-	s.mux.HandleFunc(pattern, handlerFunc)
+	s.mux.Handle(s.prefixed(pattern), s.routed(handlerFunc))
 }
 
 // SetHandle registers an HTTP handler for the specified pattern in the server's mux.
 func (s *Server) SetHandle(pattern string, handler http.Handler) {
-	s.mux.Handle(pattern, handler)
+	s.mux.Handle(s.prefixed(pattern), s.routed(handler))
+}
+
+// Path returns the path the server serves path at, i.e. path below the prefix of WithPathPrefix.
+func (s *Server) Path(path string) string {
+	return s.pathPrefix + path
+}
+
+// prefixed returns pattern with the prefix of WithPathPrefix inserted before its path,
+// after the method if any.
+func (s *Server) prefixed(pattern string) string {
+	if method, path, ok := strings.Cut(pattern, " "); ok {
+		return method + " " + s.Path(path)
+	}
+
+	return s.Path(pattern)
 }
 
 // Up starts the HTTP server in a goroutine and blocks until context is cancelled or an error occurs.
@@ -130,33 +206,126 @@ func (s *Server) Up() {
 	}
 }
 
-// Down performs graceful shutdown of the HTTP server with a 10-second timeout.
-// It waits for active connections to complete or forces shutdown after timeout.
+// Down performs graceful shutdown of the HTTP server: it stops accepting connections and waits up to
+// the drain timeout (see WithDrainTimeout) for in-flight requests to complete, then closes the
+// connections of requests still in flight.
 // Exits with status code 1 if shutdown fails for reasons other than deadline exceeded.
 func (s *Server) Down() {
-	ctx := s.ctx
-	// ctx, cancel := context.WithTimeout(s.ctx, time.Second*10)
-	// defer cancel()
+	ctx := context.WithoutCancel(s.ctx)
+
+	if s.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.drainTimeout)
+		defer cancel()
+	}
+
+	slog.Info("draining http server", "addr", s.http.Addr, "timeout", s.drainTimeout)
 
 	if err := s.http.Shutdown(ctx); err != nil {
 		if !errors.Is(err, context.DeadlineExceeded) {
 			slog.Error("failed to shutdown http server", "err", err)
 			os.Exit(1)
 		}
+
+		slog.Warn("drain timeout exceeded, closing connections of requests in flight", "addr", s.http.Addr, "timeout", s.drainTimeout)
+
+		if err := s.http.Close(); err != nil {
+			slog.Error("failed to close http server", "err", err)
+		}
+
+		return
 	}
 
 	slog.Info("http server stopped gracefully")
 }
 
+// handler returns the root handler of the server: the mux, wrapped with artificial latency, panic recovery,
+// compression, rate limiting, CORS, metrics, access logging, security headers, tracing and request IDs if enabled. CORS
+// preflights are thus not rate limited, and the artificial latency is part of the observed request duration.
+// The mux answers requests with a method not registered for their route with 405 Method Not Allowed and
+// the allowed methods in the Allow header.
+func (s *Server) handler() http.Handler {
+	var h http.Handler = s.mux
+
+	if s.chaos.enabled() {
+		slog.Warn("artificial latency of requests enabled", "addr", s.http.Addr, "latency", s.chaos.Latency, "jitter", s.chaos.Jitter)
+		h = chaos(h, s.chaos)
+	}
+
+	if s.recovery {
+		h = recovery(h, s.onPanic)
+	}
+
+	if s.compression {
+		h = compress(h, s.compressionMinSize)
+	}
+
+	if s.rateLimit.Rate > 0 {
+		h = rateLimit(h, s.rateLimit)
+	}
+
+	if s.cors.enabled() {
+		h = cors(h, s.cors)
+	}
+
+	if s.observer != nil {
+		h = observe(h, s.observer)
+	}
+
+	if s.accessLog {
+		h = accessLog(h)
+	}
+
+	if s.securityHeaders {
+		h = securityHeaders(h)
+	}
+
+	if s.tracing {
+		h = tracing.Handler(h)
+	}
+
+	// the request ID is added to a copy of the request outside tracing, which reads the pattern
+	// the mux sets on the request it passes on
+	if s.accessLog {
+		h = requestID(h)
+	}
+
+	return h
+}
+
 // run starts the HTTP server and listens for incoming connections.
 // Errors other than http.ErrServerClosed are sent to the error channel for handling.
 // This method is intended to be called in a goroutine from Up().
 func (s *Server) run() error {
-	slog.Info("start http server", "addr", s.http.Addr)
+	slog.Info("start http server", "addr", s.http.Addr, "tls", s.certFile != "" || s.acme.enabled(), "acme", s.acme.enabled(), "mtls", s.clientCAs != nil)
+
+	s.http.Handler = s.handler()
+	s.http.TLSConfig = s.tlsConfig()
+
+	var err error
+	if s.http.TLSConfig != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.http.RegisterOnShutdown(cancel)
+
+		if s.acme.enabled() {
+			s.serveACME(ctx, s.acme.manager())
+		} else {
+			var certs *certReloader
+			if certs, err = newCertReloader(s.certFile, s.keyFile); err != nil {
+				cancel()
+				s.errs <- err
+				return nil
+			}
 
-	s.http.Handler = s.mux
+			s.http.TLSConfig.GetCertificate = certs.getCertificate
 
-	err := s.http.ListenAndServe()
+			go certs.watch(ctx, s.certReloadInterval)
+		}
+
+		err = s.http.ListenAndServeTLS("", "")
+	} else {
+		err = s.http.ListenAndServe()
+	}
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.errs <- err
 	}
@@ -42,6 +42,11 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	logger "gopkg.in/slog-handler.v1"
 )
 
@@ -216,6 +221,95 @@ func TestWithAddr(t *testing.T) {
 	}
 }
 
+func TestWithHTTP2(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		cleartext bool
+		wantH2C   bool
+	}{
+		{name: "disabled", enabled: false, cleartext: true, wantH2C: false},
+		{name: "tls only", enabled: true, cleartext: false, wantH2C: false},
+		{name: "h2c", enabled: true, cleartext: true, wantH2C: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(
+				WithHTTP2(tt.enabled, tt.cleartext),
+				WithHandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.WriteString(w, r.Proto)
+				}),
+			)
+
+			assert.True(t, s.http.Protocols.HTTP1())
+			assert.Equal(t, tt.enabled, s.http.Protocols.HTTP2())
+
+			ts := httptest.NewUnstartedServer(s.handler())
+			ts.Config.Protocols = s.http.Protocols
+			ts.Start()
+			defer ts.Close()
+
+			protocols := new(http.Protocols)
+			protocols.SetUnencryptedHTTP2(true)
+			client := &http.Client{Transport: &http.Transport{Protocols: protocols}, Timeout: time.Second}
+
+			res, err := client.Get(ts.URL + "/test")
+			if !tt.wantH2C {
+				assert.Error(t, err)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				defer res.Body.Close()
+
+				body, _ := io.ReadAll(res.Body)
+				assert.Equal(t, "HTTP/2.0", string(body))
+			}
+		})
+	}
+}
+
+func TestWithPathPrefix(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	s := NewServer(
+		WithPathPrefix("/ssl-pinning/"),
+		WithHandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, r.Pattern)
+		}),
+		WithHandleFunc("/health/liveness", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, r.Pattern)
+		}),
+	)
+
+	assert.Equal(t, "/ssl-pinning/metrics", s.Path("/metrics"))
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{path: "/ssl-pinning/metrics", wantStatus: http.StatusOK, wantBody: "GET /ssl-pinning/metrics"},
+		{path: "/ssl-pinning/health/liveness", wantStatus: http.StatusOK, wantBody: "/ssl-pinning/health/liveness"},
+		{path: "/metrics", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestWithReadTimeout(t *testing.T) {
 	logger.SetGlobalLogger(logger.Options{Null: true})
 
@@ -278,6 +372,75 @@ func TestWithWriteTimeout(t *testing.T) {
 	}
 }
 
+func TestWithTracing(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name    string
+		enabled bool
+		wantMux bool
+	}{
+		{
+			name:    "disabled serves mux directly",
+			enabled: false,
+			wantMux: true,
+		},
+		{
+			name:    "enabled wraps mux",
+			enabled: true,
+			wantMux: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(WithTracing(tt.enabled))
+			s.SetHandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+
+			h := s.handler()
+
+			if _, ok := h.(*http.ServeMux); ok != tt.wantMux {
+				t.Errorf("handler() is mux = %v, want %v", ok, tt.wantMux)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+			if w.Code != http.StatusTeapot {
+				t.Errorf("handler() status = %v, want %v", w.Code, http.StatusTeapot)
+			}
+		})
+	}
+}
+
+func TestWithTracing_Route(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	// the access log adds the request ID to a copy of the request
+	s := NewServer(WithTracing(true), WithAccessLog(true))
+	s.SetHandleFunc("GET /test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, RequestID(r.Context()))
+	})
+
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/1", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /test/{id}", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("http.route", "/test/{id}"))
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+}
+
 func TestWithHandleFunc(t *testing.T) {
 	logger.SetGlobalLogger(logger.Options{Null: true})
 
@@ -407,6 +570,71 @@ func TestServer_Down(t *testing.T) {
 	}
 }
 
+func TestServer_Down_DrainTimeout(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name         string
+		latency      time.Duration
+		drainTimeout time.Duration
+		wantErr      bool
+	}{
+		{name: "request completes within the drain timeout", latency: 50 * time.Millisecond, drainTimeout: 5 * time.Second},
+		{name: "request exceeding the drain timeout", latency: 5 * time.Second, drainTimeout: 100 * time.Millisecond, wantErr: true},
+		{name: "no drain timeout", latency: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to find available port: %v", err)
+			}
+			addr := listener.Addr().String()
+			listener.Close()
+
+			started := make(chan struct{})
+			s := NewServer(
+				WithAddr(addr),
+				WithDrainTimeout(tt.drainTimeout),
+				WithHandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+					close(started)
+
+					select {
+					case <-time.After(tt.latency):
+						w.WriteHeader(http.StatusOK)
+					case <-r.Context().Done():
+					}
+				}),
+			)
+
+			go s.run()
+			time.Sleep(100 * time.Millisecond)
+
+			errs := make(chan error, 1)
+			go func() {
+				res, err := http.Get(fmt.Sprintf("http://%s/slow", addr))
+				if err == nil {
+					res.Body.Close()
+				}
+				errs <- err
+			}()
+
+			<-started
+
+			begin := time.Now()
+			s.Down()
+
+			if tt.wantErr {
+				assert.Error(t, <-errs)
+				assert.Less(t, time.Since(begin), tt.latency)
+			} else {
+				assert.NoError(t, <-errs)
+			}
+		})
+	}
+}
+
 func TestServer_MultipleHandlers(t *testing.T) {
 	logger.SetGlobalLogger(logger.Options{Null: true})
 
@@ -0,0 +1,115 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// WithTLS returns an option that serves HTTPS with the PEM encoded certificate chain and private key of the files.
+// The files are reloaded when they change (see WithCertReload).
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithClientAuth returns an option that requires clients to present a certificate issued by clientCAs (mTLS).
+// With allowedNames, the client certificate must also carry one of the names as its common name,
+// DNS name, email address or URI SAN. It has no effect without WithTLS or WithACME.
+func WithClientAuth(clientCAs *x509.CertPool, allowedNames []string) Option {
+	return func(s *Server) {
+		s.clientCAs = clientCAs
+		s.clientNames = allowedNames
+	}
+}
+
+// LoadClientCAs returns the pool of the PEM encoded CA certificates of file that client certificates
+// are verified against. Unlike the roots of domains, the system roots are not included.
+// Returns an error if the file cannot be read or contains no certificate.
+func LoadClientCAs(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CAs: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+
+	return pool, nil
+}
+
+// tlsConfig returns the TLS configuration of the server, nil if neither TLS nor ACME is enabled.
+func (s *Server) tlsConfig() *tls.Config {
+	if s.certFile == "" && !s.acme.enabled() {
+		return nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if s.clientCAs != nil {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = s.clientCAs
+
+		if len(s.clientNames) > 0 {
+			cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+				if len(cs.PeerCertificates) == 0 || !allowedClient(cs.PeerCertificates[0], s.clientNames) {
+					return errors.New("client certificate is not allowed")
+				}
+
+				return nil
+			}
+		}
+	}
+
+	return cfg
+}
+
+// allowedClient reports whether cert carries one of names as its common name or SAN.
+func allowedClient(cert *x509.Certificate, names []string) bool {
+	candidates := slices.Concat([]string{cert.Subject.CommonName}, cert.DNSNames, cert.EmailAddresses)
+	for _, u := range cert.URIs {
+		candidates = append(candidates, u.String())
+	}
+
+	return slices.ContainsFunc(candidates, func(c string) bool {
+		return c != "" && slices.Contains(names, c)
+	})
+}
@@ -0,0 +1,340 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package gcpkms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Scheme is the scheme of Cloud KMS signer DSNs.
+const Scheme = "kms"
+
+// scope is the OAuth 2.0 scope of the Cloud KMS API.
+const scope = "https://www.googleapis.com/auth/cloudkms"
+
+// defaultEndpoint is the Cloud KMS API endpoint.
+const defaultEndpoint = "https://cloudkms.googleapis.com"
+
+// defaultTimeout bounds every request to Cloud KMS.
+const defaultTimeout = 10 * time.Second
+
+// maxResponseSize limits the size of responses read from Cloud KMS.
+const maxResponseSize = 1 << 20
+
+// keyVersionName matches the resource name of a crypto key version.
+var keyVersionName = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+/cryptoKeyVersions/[^/]+$`)
+
+// algorithms maps the supported Cloud KMS key algorithms to the hash they sign.
+// The hashes match the RS512, ES256 and ES384 algorithms of the signer.
+var algorithms = map[string]crypto.Hash{
+	"EC_SIGN_P256_SHA256":        crypto.SHA256,
+	"EC_SIGN_P384_SHA384":        crypto.SHA384,
+	"RSA_SIGN_PKCS1_4096_SHA512": crypto.SHA512,
+}
+
+// crc32c is the CRC32C (Castagnoli) table used for integrity checks of Cloud KMS requests and responses.
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ParseDSN returns the crypto key version resource name of a signer DSN,
+// e.g. kms://projects/my-project/locations/global/keyRings/my-ring/cryptoKeys/my-key/cryptoKeyVersions/1.
+func ParseDSN(dsn string) (string, error) {
+	name, ok := strings.CutPrefix(dsn, Scheme+"://")
+	if !ok {
+		return "", fmt.Errorf("invalid signer dsn %q, expected %s://projects/.../cryptoKeyVersions/...", dsn, Scheme)
+	}
+
+	if !keyVersionName.MatchString(name) {
+		return "", fmt.Errorf("invalid signer dsn %q, expected a crypto key version "+
+			"projects/{project}/locations/{location}/keyRings/{ring}/cryptoKeys/{key}/cryptoKeyVersions/{version}", dsn)
+	}
+
+	return name, nil
+}
+
+// Key is an asymmetric Google Cloud KMS crypto key version implementing crypto.Signer.
+// The public key is fetched once by New and cached; every signature is a Cloud KMS
+// AsymmetricSign request with the message digest.
+type Key struct {
+	client    *http.Client
+	endpoint  string
+	hash      crypto.Hash
+	name      string
+	publicKey crypto.PublicKey
+	timeout   time.Duration
+}
+
+// Option is a functional option type for configuring Key instance.
+type Option func(*Key)
+
+// WithEndpoint overrides the Cloud KMS endpoint, e.g. for Private Service Connect.
+// The default (or an empty endpoint) is https://cloudkms.googleapis.com.
+func WithEndpoint(endpoint string) Option {
+	return func(k *Key) {
+		if endpoint != "" {
+			k.endpoint = strings.TrimSuffix(endpoint, "/")
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for Cloud KMS requests. The client must authorize
+// requests itself; by default a client with Application Default Credentials is used.
+func WithHTTPClient(client *http.Client) Option {
+	return func(k *Key) {
+		k.client = client
+	}
+}
+
+// WithTimeout sets the timeout of Cloud KMS requests.
+func WithTimeout(timeout time.Duration) Option {
+	return func(k *Key) {
+		if timeout > 0 {
+			k.timeout = timeout
+		}
+	}
+}
+
+// New returns the Cloud KMS crypto key version of the signer DSN (see ParseDSN).
+// Requests are authorized with Application Default Credentials: the service account key of
+// GOOGLE_APPLICATION_CREDENTIALS, the gcloud user credentials, or the service account of the
+// GCE instance, GKE workload identity or Cloud Run service from the metadata server.
+// It retrieves and caches the public key of the key version with GetPublicKey.
+// Returns an error if the DSN is invalid, no credentials are found, the public key cannot be
+// retrieved, or the key algorithm is not EC_SIGN_P256_SHA256, EC_SIGN_P384_SHA384 or
+// RSA_SIGN_PKCS1_4096_SHA512.
+func New(ctx context.Context, dsn string, opts ...Option) (*Key, error) {
+	name, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Key{
+		endpoint: defaultEndpoint,
+		name:     name,
+		timeout:  defaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	if k.client == nil {
+		creds, err := google.FindDefaultCredentials(ctx, scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find google credentials: %w", err)
+		}
+
+		k.client = oauth2.NewClient(context.Background(), creds.TokenSource)
+	}
+
+	var res struct {
+		Algorithm string `json:"algorithm"`
+		PEM       string `json:"pem"`
+		PEMCRC32C string `json:"pemCrc32c"`
+	}
+
+	if err := k.call(ctx, http.MethodGet, "/publicKey", nil, &res); err != nil {
+		return nil, fmt.Errorf("failed to get public key of cloud kms key %q: %w", name, err)
+	}
+
+	if err := checkCRC32C([]byte(res.PEM), res.PEMCRC32C); err != nil {
+		return nil, fmt.Errorf("public key of cloud kms key %q: %w", name, err)
+	}
+
+	hash, ok := algorithms[res.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q of cloud kms key %q, "+
+			"use EC_SIGN_P256_SHA256, EC_SIGN_P384_SHA384 or RSA_SIGN_PKCS1_4096_SHA512", res.Algorithm, name)
+	}
+
+	block, _ := pem.Decode([]byte(res.PEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode public key of cloud kms key %q", name)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of cloud kms key %q: %w", name, err)
+	}
+
+	k.hash = hash
+	k.publicKey = publicKey
+
+	return k, nil
+}
+
+// Public returns the cached public key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.publicKey
+}
+
+// Sign signs digest with the Cloud KMS key. opts must be the hash of the key algorithm;
+// RSA keys sign with PKCS1v15, ECDSA keys return ASN.1 DER signatures. rand is ignored.
+func (k *Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != k.hash {
+		return nil, fmt.Errorf("cloud kms key %q signs %s digests, got %s", k.name, k.hash, opts.HashFunc())
+	}
+
+	if len(digest) != k.hash.Size() {
+		return nil, fmt.Errorf("digest length %d does not match hash %s", len(digest), k.hash)
+	}
+
+	field := map[crypto.Hash]string{
+		crypto.SHA256: "sha256",
+		crypto.SHA384: "sha384",
+		crypto.SHA512: "sha512",
+	}[k.hash]
+
+	req := struct {
+		Digest       map[string][]byte `json:"digest"`
+		DigestCRC32C string            `json:"digestCrc32c"`
+	}{
+		Digest:       map[string][]byte{field: digest},
+		DigestCRC32C: strconv.FormatUint(uint64(crc32.Checksum(digest, crc32c)), 10),
+	}
+
+	var res struct {
+		Signature            []byte `json:"signature"`
+		SignatureCRC32C      string `json:"signatureCrc32c"`
+		VerifiedDigestCRC32C bool   `json:"verifiedDigestCrc32c"`
+	}
+
+	if err := k.call(context.Background(), http.MethodPost, ":asymmetricSign", req, &res); err != nil {
+		return nil, fmt.Errorf("cloud kms sign: %w", err)
+	}
+
+	if !res.VerifiedDigestCRC32C {
+		return nil, fmt.Errorf("cloud kms sign: digest checksum was not verified")
+	}
+
+	if err := checkCRC32C(res.Signature, res.SignatureCRC32C); err != nil {
+		return nil, fmt.Errorf("cloud kms sign: %w", err)
+	}
+
+	return res.Signature, nil
+}
+
+// checkCRC32C verifies the CRC32C checksum (a decimal string) of data.
+func checkCRC32C(data []byte, checksum string) error {
+	want, err := strconv.ParseUint(checksum, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid checksum %q", checksum)
+	}
+
+	if got := crc32.Checksum(data, crc32c); uint64(got) != want {
+		return fmt.Errorf("checksum mismatch, response corrupted in transit")
+	}
+
+	return nil
+}
+
+// APIError is an error returned by the Cloud KMS API, e.g. PERMISSION_DENIED or NOT_FOUND.
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// Error returns the error status and message.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s (status %d)", e.Status, e.Message, e.Code)
+}
+
+// call sends a request for the method (a path suffix of the key version resource) with input
+// as JSON body and decodes the response into output.
+func (k *Key) call(ctx context.Context, httpMethod, method string, input, output any) error {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+
+	var body io.Reader
+	if input != nil {
+		data, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, httpMethod, k.endpoint+"/v1/"+k.name+method, body)
+	if err != nil {
+		return err
+	}
+
+	if input != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var res struct {
+			Error *APIError `json:"error"`
+		}
+
+		if err := json.Unmarshal(data, &res); err == nil && res.Error != nil {
+			return res.Error
+		}
+
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(data, output); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
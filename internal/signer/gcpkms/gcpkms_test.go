@@ -0,0 +1,267 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package gcpkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testName = "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+
+// fakeKMS is a Cloud KMS API server signing with a local key.
+type fakeKMS struct {
+	key             crypto.Signer
+	algorithm       string
+	corruptResponse bool
+}
+
+func checksum(data []byte) string {
+	return strconv.FormatUint(uint64(crc32.Checksum(data, crc32c)), 10)
+}
+
+func (f *fakeKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/"), ":")
+	name = strings.TrimSuffix(name, "/publicKey")
+
+	if name != testName {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"code":404,"message":"CryptoKeyVersion not found.","status":"NOT_FOUND"}}`))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/publicKey"):
+		der, _ := x509.MarshalPKIXPublicKey(f.key.Public())
+		data := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+		crc := checksum(data)
+		if f.corruptResponse {
+			crc = "1"
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"algorithm": f.algorithm,
+			"name":      testName,
+			"pem":       string(data),
+			"pemCrc32c": crc,
+		})
+	case r.Method == http.MethodPost && method == "asymmetricSign":
+		var req struct {
+			Digest       map[string][]byte `json:"digest"`
+			DigestCRC32C string            `json:"digestCrc32c"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		hash := map[string]crypto.Hash{"sha256": crypto.SHA256, "sha384": crypto.SHA384, "sha512": crypto.SHA512}
+		for field, digest := range req.Digest {
+			signature, err := f.key.Sign(rand.Reader, digest, hash[field])
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			json.NewEncoder(w).Encode(map[string]any{
+				"signature":            signature,
+				"signatureCrc32c":      checksum(signature),
+				"verifiedDigestCrc32c": req.DigestCRC32C == checksum(digest),
+			})
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// setupFakeKMS starts a fake Cloud KMS server for key.
+func setupFakeKMS(t *testing.T, key crypto.Signer, algorithm string) (*fakeKMS, []Option) {
+	t.Helper()
+
+	f := &fakeKMS{key: key, algorithm: algorithm}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	return f, []Option{WithEndpoint(srv.URL), WithHTTPClient(srv.Client())}
+}
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		dsn     string
+		want    string
+		wantErr bool
+	}{
+		{name: "key version", dsn: "kms://" + testName, want: testName},
+		{name: "wrong scheme", dsn: "gcpkms://" + testName, wantErr: true},
+		{name: "crypto key without version", dsn: "kms://projects/p/locations/global/keyRings/r/cryptoKeys/k", wantErr: true},
+		{name: "empty", dsn: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDSN(tt.dsn)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		key       crypto.Signer
+		algorithm string
+		dsn       string
+		corrupt   bool
+		wantErr   string
+	}{
+		{name: "rsa", key: rsaKey, algorithm: "RSA_SIGN_PKCS1_4096_SHA512", dsn: "kms://" + testName},
+		{name: "ecdsa", key: ecKey, algorithm: "EC_SIGN_P256_SHA256", dsn: "kms://" + testName},
+		{name: "invalid dsn", key: ecKey, algorithm: "EC_SIGN_P256_SHA256", dsn: testName, wantErr: "invalid signer dsn"},
+		{
+			name:      "not found",
+			key:       ecKey,
+			algorithm: "EC_SIGN_P256_SHA256",
+			dsn:       "kms://projects/p/locations/global/keyRings/r/cryptoKeys/other/cryptoKeyVersions/1",
+			wantErr:   "NOT_FOUND: CryptoKeyVersion not found. (status 404)",
+		},
+		{name: "pss", key: rsaKey, algorithm: "RSA_SIGN_PSS_4096_SHA512", dsn: "kms://" + testName, wantErr: `unsupported algorithm "RSA_SIGN_PSS_4096_SHA512"`},
+		{name: "sha256 rsa", key: rsaKey, algorithm: "RSA_SIGN_PKCS1_2048_SHA256", dsn: "kms://" + testName, wantErr: "unsupported algorithm"},
+		{name: "corrupted", key: ecKey, algorithm: "EC_SIGN_P256_SHA256", dsn: "kms://" + testName, corrupt: true, wantErr: "checksum mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, opts := setupFakeKMS(t, tt.key, tt.algorithm)
+			f.corruptResponse = tt.corrupt
+
+			k, err := New(context.Background(), tt.dsn, opts...)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.key.Public(), k.Public())
+		})
+	}
+}
+
+func TestKey_Sign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	sum384 := sha512.Sum384([]byte("data"))
+	sum512 := sha512.Sum512([]byte("data"))
+	sum256 := sha256.Sum256([]byte("data"))
+
+	tests := []struct {
+		name      string
+		key       crypto.Signer
+		algorithm string
+		digest    []byte
+		opts      crypto.SignerOpts
+		wantErr   string
+	}{
+		{name: "rsa", key: rsaKey, algorithm: "RSA_SIGN_PKCS1_4096_SHA512", digest: sum512[:], opts: crypto.SHA512},
+		{name: "ecdsa", key: ecKey, algorithm: "EC_SIGN_P384_SHA384", digest: sum384[:], opts: crypto.SHA384},
+		{name: "hash mismatch", key: ecKey, algorithm: "EC_SIGN_P384_SHA384", digest: sum256[:], opts: crypto.SHA256, wantErr: "signs SHA-384 digests, got SHA-256"},
+		{name: "digest length", key: ecKey, algorithm: "EC_SIGN_P384_SHA384", digest: sum256[:], opts: crypto.SHA384, wantErr: "digest length 32 does not match hash SHA-384"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, opts := setupFakeKMS(t, tt.key, tt.algorithm)
+
+			k, err := New(context.Background(), "kms://"+testName, opts...)
+			require.NoError(t, err)
+
+			signature, err := k.Sign(rand.Reader, tt.digest, tt.opts)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			switch pub := tt.key.Public().(type) {
+			case *rsa.PublicKey:
+				assert.NoError(t, rsa.VerifyPKCS1v15(pub, tt.opts.HashFunc(), tt.digest, signature))
+			case *ecdsa.PublicKey:
+				assert.True(t, ecdsa.VerifyASN1(pub, tt.digest, signature))
+			}
+		})
+	}
+}
+
+func TestCheckCRC32C(t *testing.T) {
+	data := []byte("signature")
+
+	assert.NoError(t, checkCRC32C(data, checksum(data)))
+	assert.ErrorContains(t, checkCRC32C(data, "1"), "checksum mismatch")
+	assert.ErrorContains(t, checkCRC32C(data, ""), "invalid checksum")
+}
@@ -0,0 +1,270 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// defaultTimeout bounds every request to KMS.
+const defaultTimeout = 10 * time.Second
+
+// API is the part of the AWS KMS client used by Key.
+type API interface {
+	GetPublicKey(ctx context.Context, in *awskms.GetPublicKeyInput, optFns ...func(*awskms.Options)) (*awskms.GetPublicKeyOutput, error)
+	Sign(ctx context.Context, in *awskms.SignInput, optFns ...func(*awskms.Options)) (*awskms.SignOutput, error)
+}
+
+// Key is an asymmetric AWS KMS key (RSA or ECDSA on P-256 or P-384 with key usage SIGN_VERIFY)
+// implementing crypto.Signer. The public key is fetched once by New and cached; every
+// signature is a KMS Sign request with the message digest.
+type Key struct {
+	client    API
+	endpoint  string
+	keyID     string
+	publicKey crypto.PublicKey
+	region    string
+	timeout   time.Duration
+}
+
+// Option is a functional option type for configuring Key instance.
+type Option func(*Key)
+
+// WithClient sets the KMS client. By default the client is created from the shared AWS
+// configuration, see New.
+func WithClient(client API) Option {
+	return func(k *Key) {
+		k.client = client
+	}
+}
+
+// WithEndpoint overrides the KMS endpoint, e.g. for VPC endpoints or local KMS emulators.
+// The default (or an empty endpoint) is resolved by the AWS SDK from the region.
+func WithEndpoint(endpoint string) Option {
+	return func(k *Key) {
+		if endpoint != "" {
+			k.endpoint = strings.TrimSuffix(endpoint, "/")
+		}
+	}
+}
+
+// WithRegion sets the AWS region of the key. By default (or for an empty region) it is
+// taken from the key ARN or from the shared AWS configuration.
+func WithRegion(region string) Option {
+	return func(k *Key) {
+		if region != "" {
+			k.region = region
+		}
+	}
+}
+
+// WithTimeout sets the timeout of KMS requests.
+func WithTimeout(timeout time.Duration) Option {
+	return func(k *Key) {
+		if timeout > 0 {
+			k.timeout = timeout
+		}
+	}
+}
+
+// New returns the KMS key identified by keyID (key ID, key ARN, alias name or alias ARN).
+// Unless a client is set with WithClient, the client is created with the shared AWS
+// configuration (config.LoadDefaultConfig), so the region and credentials are resolved like
+// in every AWS SDK: environment, shared config files, web identity token, container
+// credentials endpoint or the IAM role of the EC2 instance.
+// It retrieves and caches the public key of the key with GetPublicKey.
+// Returns an error if the region cannot be determined, the public key cannot be retrieved,
+// or the key is not an asymmetric signing key of a supported type.
+func New(ctx context.Context, keyID string, opts ...Option) (*Key, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("kms key id is required")
+	}
+
+	k := &Key{
+		keyID:   keyID,
+		region:  regionFromARN(keyID),
+		timeout: defaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	if k.client == nil {
+		client, err := k.newClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		k.client = client
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+
+	res, err := k.client.GetPublicKey(ctx, &awskms.GetPublicKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key of kms key %q: %w", keyID, err)
+	}
+
+	if res.KeyUsage != kmstypes.KeyUsageTypeSignVerify {
+		return nil, fmt.Errorf("kms key %q has key usage %q, SIGN_VERIFY is required", keyID, res.KeyUsage)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(res.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of kms key %q: %w", keyID, err)
+	}
+
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() && pub.Curve != elliptic.P384() {
+			return nil, fmt.Errorf("unsupported curve %s of kms key %q, use P-256 or P-384",
+				pub.Curve.Params().Name, keyID)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key spec %q of kms key %q, use RSA or ECDSA", res.KeySpec, keyID)
+	}
+
+	k.publicKey = publicKey
+
+	return k, nil
+}
+
+// newClient creates a KMS client from the shared AWS configuration, the region and the endpoint.
+func (k *Key) newClient(ctx context.Context) (*awskms.Client, error) {
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	if k.region != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(k.region))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws configuration: %w", err)
+	}
+
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("kms region is required, set it or AWS_REGION")
+	}
+
+	return awskms.NewFromConfig(cfg, func(o *awskms.Options) {
+		if k.endpoint != "" {
+			o.BaseEndpoint = aws.String(k.endpoint)
+		}
+	}), nil
+}
+
+// regionFromARN returns the region of a key or alias ARN (arn:aws:kms:{region}:...),
+// or an empty string for other key identifiers.
+func regionFromARN(keyID string) string {
+	parts := strings.Split(keyID, ":")
+	if len(parts) < 6 || parts[0] != "arn" || parts[2] != "kms" {
+		return ""
+	}
+
+	return parts[3]
+}
+
+// Public returns the cached public key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.publicKey
+}
+
+// Sign signs digest with the KMS key. opts must be the hash used to compute digest
+// (SHA-256, SHA-384 or SHA-512); RSA keys sign with PKCS1v15, ECDSA keys return ASN.1 DER
+// signatures. rand is ignored.
+func (k *Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	algorithm, err := k.signingAlgorithm(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(digest) != opts.HashFunc().Size() {
+		return nil, fmt.Errorf("digest length %d does not match hash %s", len(digest), opts.HashFunc())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), k.timeout)
+	defer cancel()
+
+	res, err := k.client.Sign(ctx, &awskms.SignInput{
+		KeyId:            aws.String(k.keyID),
+		Message:          digest,
+		MessageType:      kmstypes.MessageTypeDigest,
+		SigningAlgorithm: algorithm,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms sign: %w", err)
+	}
+
+	return res.Signature, nil
+}
+
+// signingAlgorithm returns the KMS signing algorithm of the key type and hash.
+func (k *Key) signingAlgorithm(opts crypto.SignerOpts) (kmstypes.SigningAlgorithmSpec, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return "", fmt.Errorf("rsa pss signatures are not supported")
+	}
+
+	var bits string
+
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		bits = "256"
+	case crypto.SHA384:
+		bits = "384"
+	case crypto.SHA512:
+		bits = "512"
+	default:
+		return "", fmt.Errorf("unsupported hash %s", opts.HashFunc())
+	}
+
+	if _, ok := k.publicKey.(*ecdsa.PublicKey); ok {
+		return kmstypes.SigningAlgorithmSpec("ECDSA_SHA_" + bits), nil
+	}
+
+	return kmstypes.SigningAlgorithmSpec("RSASSA_PKCS1_V1_5_SHA_" + bits), nil
+}
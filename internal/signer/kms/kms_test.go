@@ -0,0 +1,314 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKMS is a KMS API server signing with a local key.
+type fakeKMS struct {
+	key           crypto.Signer
+	keyUsage      string
+	getPublicKeys atomic.Int32
+	signs         atomic.Int32
+	lastSign      map[string]any
+}
+
+func (f *fakeKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazon.coral.service#UnrecognizedClientException","message":"invalid signature"}`))
+		return
+	}
+
+	var req map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if req["KeyId"] != "alias/signing" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"NotFoundException","message":"Alias is not found."}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "TrentService.GetPublicKey":
+		f.getPublicKeys.Add(1)
+
+		der, _ := x509.MarshalPKIXPublicKey(f.key.Public())
+		json.NewEncoder(w).Encode(map[string]any{
+			"KeyId":     "arn:aws:kms:eu-west-1:111122223333:key/1234",
+			"KeySpec":   "RSA_2048",
+			"KeyUsage":  f.keyUsage,
+			"PublicKey": der,
+		})
+	case "TrentService.Sign":
+		f.signs.Add(1)
+		f.lastSign = req
+
+		var hash crypto.Hash
+		switch {
+		case strings.HasSuffix(req["SigningAlgorithm"].(string), "_256"):
+			hash = crypto.SHA256
+		case strings.HasSuffix(req["SigningAlgorithm"].(string), "_384"):
+			hash = crypto.SHA384
+		default:
+			hash = crypto.SHA512
+		}
+
+		var digest []byte
+		json.Unmarshal([]byte(`"`+req["Message"].(string)+`"`), &digest)
+
+		signature, err := f.key.Sign(rand.Reader, digest, hash)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"Signature": signature})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// setupFakeKMS starts a fake KMS server for key.
+func setupFakeKMS(t *testing.T, key crypto.Signer) (*fakeKMS, []Option) {
+	t.Helper()
+
+	f := &fakeKMS{key: key, keyUsage: "SIGN_VERIFY"}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	setupAWSEnv(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	return f, []Option{
+		WithEndpoint(srv.URL),
+		WithRegion("eu-west-1"),
+	}
+}
+
+// setupAWSEnv isolates the shared AWS configuration from the environment of the test.
+func setupAWSEnv(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+}
+
+func TestNew(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		key      crypto.Signer
+		keyID    string
+		keyUsage string
+		wantErr  string
+	}{
+		{name: "rsa", key: rsaKey, keyID: "alias/signing"},
+		{name: "empty key id", key: rsaKey, wantErr: "kms key id is required"},
+		{name: "not found", key: rsaKey, keyID: "alias/missing", wantErr: "NotFoundException: Alias is not found."},
+		{name: "encrypt key", key: rsaKey, keyID: "alias/signing", keyUsage: "ENCRYPT_DECRYPT", wantErr: "SIGN_VERIFY is required"},
+		{name: "p521", key: p521Key, keyID: "alias/signing", wantErr: "unsupported curve P-521"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, opts := setupFakeKMS(t, tt.key)
+			if tt.keyUsage != "" {
+				f.keyUsage = tt.keyUsage
+			}
+
+			k, err := New(context.Background(), tt.keyID, opts...)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.key.Public(), k.Public())
+		})
+	}
+}
+
+func TestNew_Region(t *testing.T) {
+	setupAWSEnv(t)
+
+	_, err := New(context.Background(), "alias/signing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kms region is required")
+
+	assert.Equal(t, "eu-central-1", regionFromARN("arn:aws:kms:eu-central-1:111122223333:key/1234"))
+	assert.Equal(t, "", regionFromARN("alias/signing"))
+	assert.Equal(t, "", regionFromARN("arn:aws:s3:::bucket"))
+}
+
+func TestKey_Sign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sum256 := sha256.Sum256([]byte("data"))
+	sum512 := sha512.Sum512([]byte("data"))
+
+	tests := []struct {
+		name          string
+		key           crypto.Signer
+		digest        []byte
+		opts          crypto.SignerOpts
+		wantAlgorithm string
+		wantErr       string
+	}{
+		{name: "rsa sha512", key: rsaKey, digest: sum512[:], opts: crypto.SHA512, wantAlgorithm: "RSASSA_PKCS1_V1_5_SHA_512"},
+		{name: "ecdsa sha256", key: ecKey, digest: sum256[:], opts: crypto.SHA256, wantAlgorithm: "ECDSA_SHA_256"},
+		{name: "digest length", key: rsaKey, digest: sum256[:], opts: crypto.SHA512, wantErr: "digest length 32 does not match hash SHA-512"},
+		{name: "pss", key: rsaKey, digest: sum256[:], opts: &rsa.PSSOptions{Hash: crypto.SHA256}, wantErr: "rsa pss signatures are not supported"},
+		{name: "unsupported hash", key: rsaKey, digest: sum256[:], opts: crypto.SHA1, wantErr: "unsupported hash SHA-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, opts := setupFakeKMS(t, tt.key)
+
+			k, err := New(context.Background(), "alias/signing", opts...)
+			require.NoError(t, err)
+
+			signature, err := k.Sign(rand.Reader, tt.digest, tt.opts)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				assert.Equal(t, int32(0), f.signs.Load())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAlgorithm, f.lastSign["SigningAlgorithm"])
+			assert.Equal(t, "DIGEST", f.lastSign["MessageType"])
+
+			switch pub := tt.key.Public().(type) {
+			case *rsa.PublicKey:
+				assert.NoError(t, rsa.VerifyPKCS1v15(pub, tt.opts.HashFunc(), tt.digest, signature))
+			case *ecdsa.PublicKey:
+				assert.True(t, ecdsa.VerifyASN1(pub, tt.digest, signature))
+			}
+		})
+	}
+}
+
+// stubAPI is a KMS client returning the public key of key.
+type stubAPI struct {
+	API
+	key crypto.Signer
+}
+
+func (s stubAPI) GetPublicKey(context.Context, *awskms.GetPublicKeyInput, ...func(*awskms.Options)) (*awskms.GetPublicKeyOutput, error) {
+	der, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &awskms.GetPublicKeyOutput{KeyUsage: kmstypes.KeyUsageTypeSignVerify, PublicKey: der}, nil
+}
+
+func TestNew_WithClient(t *testing.T) {
+	// no region or credentials are needed with an explicit client
+	setupAWSEnv(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	k, err := New(context.Background(), "alias/signing", WithClient(stubAPI{key: key}))
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), k.Public())
+}
+
+func TestKey_PublicKeyCached(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	f, opts := setupFakeKMS(t, key)
+
+	k, err := New(context.Background(), "alias/signing", opts...)
+	require.NoError(t, err)
+
+	sum := sha512.Sum384([]byte("data"))
+	for range 3 {
+		_, err := k.Sign(rand.Reader, sum[:], crypto.SHA384)
+		require.NoError(t, err)
+		assert.Equal(t, key.Public(), k.Public())
+	}
+
+	assert.Equal(t, int32(1), f.getPublicKeys.Load())
+	assert.Equal(t, int32(3), f.signs.Load())
+}
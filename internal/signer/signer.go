@@ -33,71 +33,431 @@ package signer
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
+	"math/big"
 	"os"
+	"time"
 
 	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+
+	"ssl-pinning/pkg/verify"
+)
+
+// Supported signature algorithms, named after their JWA (RFC 7518) identifiers.
+const (
+	// AlgorithmRS512 is RSA PKCS1v15 with SHA-512, the default for RSA keys
+	AlgorithmRS512 = "RS512"
+	// AlgorithmES256 is ECDSA on P-256 with SHA-256
+	AlgorithmES256 = "ES256"
+	// AlgorithmES384 is ECDSA on P-384 with SHA-384
+	AlgorithmES384 = "ES384"
 )
 
-// Signer provides cryptographic signing functionality using RSA private key.
-// It signs JSON data after canonicalization using SHA-512 hash and PKCS1v15 signature scheme.
+// Signer provides cryptographic signing functionality using an RSA or ECDSA private key.
+// It signs JSON data after canonicalization. RSA keys sign with SHA-512 and PKCS1v15,
+// ECDSA keys sign with the hash matching their curve and produce ASN.1 DER signatures.
+//
+// During a key rotation (see WithRotation) the signer holds the old and the new key:
+// it signs with the old key until the cutover and with the new key afterwards, while
+// Cosigners returns the new key until the cutover so that files carry both signatures.
 type Signer struct {
-	privateKey *rsa.PrivateKey
+	privateKey   crypto.Signer
+	algorithm    string
+	hash         crypto.Hash
+	keyID        string
+	legacyFormat bool
+	next         *Signer
+	nextKeyPath  string
+	cutover      time.Time
+	passphrase   []byte
+	timestamper  Timestamper
+	now          func() time.Time
+}
+
+// Timestamper obtains trusted timestamps of signatures, see WithTimestamper.
+type Timestamper interface {
+	// Timestamp returns a DER encoded RFC 3161 timestamp token for data
+	Timestamp(data []byte) ([]byte, error)
+}
+
+// Option is a functional option type for configuring Signer instance.
+type Option func(*Signer)
+
+// WithAlgorithm requires the signature algorithm (AlgorithmRS512, AlgorithmES256 or
+// AlgorithmES384). NewSigner fails if the key does not support it. An empty value
+// selects the algorithm from the key.
+func WithAlgorithm(algorithm string) Option {
+	return func(s *Signer) {
+		s.algorithm = algorithm
+	}
+}
+
+// WithLegacyFormat sets whether the signer produces files in the original format without key ID,
+// algorithm and signing time metadata, for clients that predate it. Enabled by default, so that
+// the signature of published files stays the same on upgrades.
+func WithLegacyFormat(enabled bool) Option {
+	return func(s *Signer) {
+		s.legacyFormat = enabled
+	}
+}
+
+// WithPassphrase sets the passphrase of encrypted PKCS#8 ("ENCRYPTED PRIVATE KEY") private keys,
+// see LoadPassphrase. It is used for the key of a rotation as well.
+func WithPassphrase(passphrase []byte) Option {
+	return func(s *Signer) {
+		s.passphrase = passphrase
+	}
+}
+
+// WithTimestamper makes the signer obtain an RFC 3161 timestamp token of the primary
+// signature of every signed file, see Timestamp.
+func WithTimestamper(timestamper Timestamper) Option {
+	return func(s *Signer) {
+		s.timestamper = timestamper
+	}
+}
+
+// WithRotation starts a key rotation to the private key at nextKeyPath. Until cutover files
+// are signed with both keys, so clients with either public key keep verifying them; from
+// cutover on they are signed with the new key only. The new key may use a different algorithm.
+func WithRotation(nextKeyPath string, cutover time.Time) Option {
+	return func(s *Signer) {
+		s.nextKeyPath = nextKeyPath
+		s.cutover = cutover
+	}
 }
 
 // NewSigner creates and initializes a new Signer instance from a PEM-encoded private key file.
-// The private key must be in PKCS8 format and of type RSA.
-// Returns an error if the file cannot be read, PEM decoding fails, or key parsing fails.
-func NewSigner(privateKeyPath string) (*Signer, error) {
-	privPem, err := os.ReadFile(privateKeyPath)
+// The private key may be in PKCS8 ("PRIVATE KEY"), PKCS1 ("RSA PRIVATE KEY") or SEC1
+// ("EC PRIVATE KEY") format, or in encrypted PKCS8 ("ENCRYPTED PRIVATE KEY") format
+// decrypted with the passphrase set by WithPassphrase, and must be an RSA key or an ECDSA
+// key on P-256 or P-384.
+// The signature algorithm is selected from the key unless required with WithAlgorithm.
+// Returns an error if the file cannot be read, PEM decoding fails, key parsing fails,
+// the key does not match the required algorithm or the rotation key cannot be loaded.
+func NewSigner(privateKeyPath string, opts ...Option) (*Signer, error) {
+	s := newSigner(opts)
+
+	privKey, err := loadKey(privateKeyPath, s.passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := privKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T, use RSA or ECDSA", privKey)
+	}
+
+	return s.init(key)
+}
+
+// NewKeySigner creates a Signer using a key held outside of the process, e.g. in a KMS,
+// exposed as a crypto.Signer. The key must implement PKCS1v15 signatures for RSA public keys
+// and ASN.1 DER encoded signatures for ECDSA public keys on P-256 or P-384, like the keys
+// of the standard library. The signature algorithm is selected from the public key unless
+// required with WithAlgorithm. A rotation key set by WithRotation is loaded from a file.
+func NewKeySigner(key crypto.Signer, opts ...Option) (*Signer, error) {
+	return newSigner(opts).init(key)
+}
+
+// newSigner returns a signer configured with opts.
+func newSigner(opts []Option) *Signer {
+	s := &Signer{
+		legacyFormat: true,
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// init sets the signing key of s, selects its algorithm and loads the rotation key.
+func (s *Signer) init(key crypto.Signer) (*Signer, error) {
+	algorithm, hash, err := keyAlgorithm(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	if s.algorithm != "" && s.algorithm != algorithm {
+		return nil, fmt.Errorf("private key does not support algorithm %q, key algorithm is %q",
+			s.algorithm, algorithm)
+	}
+
+	s.privateKey = key
+	s.algorithm = algorithm
+	s.hash = hash
+
+	if s.keyID, err = verify.KeyID(s.privateKey.Public()); err != nil {
+		return nil, err
+	}
+
+	if s.nextKeyPath != "" {
+		s.next, err = NewSigner(s.nextKeyPath, WithLegacyFormat(s.legacyFormat), WithPassphrase(s.passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rotation key: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// loadKey reads and parses a PEM-encoded private key file, decrypting it with passphrase if it is encrypted.
+func loadKey(path string, passphrase []byte) (any, error) {
+	privPem, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read private key file: %w", err)
 	}
 
 	block, _ := pem.Decode(privPem)
-	if block == nil || block.Type != "PRIVATE KEY" {
+	if block == nil {
 		return nil, fmt.Errorf("failed to decode PEM block containing private key")
 	}
 
-	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if _, ok := block.Headers["Proc-Type"]; ok {
+		return nil, fmt.Errorf("legacy PEM encryption is not supported, convert the key with \"openssl pkcs8 -topk8 -v2 aes-256-cbc\"")
+	}
+
+	var privKey any
+
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("private key is encrypted, a passphrase is required")
+		}
+
+		der, err := decryptPKCS8(block.Bytes, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+
+		if privKey, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key: %w", ErrPassphrase)
+		}
+	case "PRIVATE KEY":
+		privKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("failed to decode PEM block containing private key: unsupported type %q", block.Type)
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
 
-	rsaPriv, ok := privKey.(*rsa.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("private key is not of type *rsa.PrivateKey")
+	return privKey, nil
+}
+
+// active returns the signer of the key currently used for the primary signature:
+// the new key of a rotation once its cutover has passed, otherwise s itself.
+func (s *Signer) active() *Signer {
+	if s.next != nil && !s.now().Before(s.cutover) {
+		return s.next
+	}
+
+	return s
+}
+
+// Cosigners returns the signers whose signatures must be added next to the primary signature:
+// the new key of a rotation until its cutover, nil otherwise.
+func (s *Signer) Cosigners() []*Signer {
+	if s.next == nil || s.active() == s.next {
+		return nil
 	}
 
-	return &Signer{
-		privateKey: rsaPriv,
-	}, nil
+	return []*Signer{s.next}
 }
 
-// Sign signs JSON data using RSA-SHA512 signature algorithm.
+// keyAlgorithm returns the signature algorithm and hash used with the private key of a public key.
+func keyAlgorithm(key crypto.PublicKey) (string, crypto.Hash, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return AlgorithmRS512, crypto.SHA512, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return AlgorithmES256, crypto.SHA256, nil
+		case elliptic.P384():
+			return AlgorithmES384, crypto.SHA384, nil
+		}
+
+		return "", 0, fmt.Errorf("unsupported ECDSA curve %s, use P-256 or P-384", k.Curve.Params().Name)
+	}
+
+	return "", 0, fmt.Errorf("unsupported key type %T, use RSA or ECDSA", key)
+}
+
+// Sign signs JSON data with the signer's algorithm.
 // It performs three steps:
 // 1. Canonicalizes the JSON data to ensure consistent representation
-// 2. Computes SHA-512 hash of the canonical JSON
-// 3. Signs the hash using RSA PKCS1v15 and returns base64-encoded signature
+// 2. Computes the hash of the canonical JSON (SHA-512, SHA-256 or SHA-384, see Algorithm)
+// 3. Signs the hash using RSA PKCS1v15 or ECDSA (ASN.1 DER) and returns base64-encoded signature
 // Returns an error if canonicalization or signing fails.
 func (s *Signer) Sign(data []byte) (string, error) {
+	s = s.active()
+
 	canonical, err := jsoncanonicalizer.Transform(data)
 	if err != nil {
 		return "", fmt.Errorf("failed to canonicalize JSON: %w", err)
 	}
 
-	hashed := sha512.Sum512(canonical)
-
-	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA512, hashed[:])
+	signature, err := s.privateKey.Sign(rand.Reader, s.digest(canonical), s.hash)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign JSON: %w", err)
 	}
 
 	return base64.StdEncoding.EncodeToString(signature), nil
 }
+
+// Timestamp returns the base64-encoded timestamp token of a base64-encoded signature returned
+// by Sign, so that clients can prove the signature existed at the time of the token.
+// Returns an empty token if the signer has no timestamper (see WithTimestamper).
+func (s *Signer) Timestamp(signature string) (string, error) {
+	if s.timestamper == nil {
+		return "", nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	token, err := s.timestamper.Timestamp(decoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to timestamp signature: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(token), nil
+}
+
+// SignJWS signs a JWS signing input (RFC 7515, section 5.1) without canonicalization and
+// returns the base64url-encoded signature. ECDSA signatures are encoded as the fixed-size
+// concatenation of R and S as required by RFC 7518, section 3.4.
+func (s *Signer) SignJWS(signingInput []byte) (string, error) {
+	signature, err := s.signRaw(signingInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWS: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// SignCOSE signs the encoded Sig_structure of a COSE_Sign1 message (RFC 9052, section 4.4)
+// without canonicalization. ECDSA signatures are encoded as the fixed-size concatenation
+// of R and S as required by RFC 9053, section 2.1.
+func (s *Signer) SignCOSE(toBeSigned []byte) ([]byte, error) {
+	signature, err := s.signRaw(toBeSigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign COSE: %w", err)
+	}
+
+	return signature, nil
+}
+
+// signRaw signs data as is with the active key, converting ECDSA signatures to R || S.
+func (s *Signer) signRaw(data []byte) ([]byte, error) {
+	s = s.active()
+
+	signature, err := s.privateKey.Sign(rand.Reader, s.digest(data), s.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if k, ok := s.privateKey.Public().(*ecdsa.PublicKey); ok {
+		return rawECDSASignature(signature, (k.Curve.Params().BitSize+7)/8)
+	}
+
+	return signature, nil
+}
+
+// rawECDSASignature converts an ASN.1 DER ECDSA signature into R || S, each left-padded to size bytes.
+func rawECDSASignature(der []byte, size int) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+	}
+
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+
+	return raw, nil
+}
+
+// digest hashes data with the hash of the signer's algorithm.
+func (s *Signer) digest(data []byte) []byte {
+	switch s.hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	default:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	}
+}
+
+// Algorithm returns the JWA name of the signature algorithm (AlgorithmRS512, AlgorithmES256 or AlgorithmES384).
+func (s *Signer) Algorithm() string {
+	return s.active().algorithm
+}
+
+// KeySize returns the size of the private key in bits.
+func (s *Signer) KeySize() int {
+	switch k := s.active().privateKey.Public().(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	}
+
+	return 0
+}
+
+// KeyID returns the identifier of the signing key: the base64-encoded SHA-256 hash of its public key.
+// It follows keys rotated outside of the application, such as Vault transit keys.
+func (s *Signer) KeyID() string {
+	s = s.active()
+
+	id, err := verify.KeyID(s.privateKey.Public())
+	if err != nil {
+		return s.keyID
+	}
+
+	return id
+}
+
+// PublicKeys returns the public keys of the signer: the signing key and the new key of a rotation, if any.
+func (s *Signer) PublicKeys() []crypto.PublicKey {
+	keys := []crypto.PublicKey{s.privateKey.Public()}
+	if s.next != nil {
+		keys = append(keys, s.next.privateKey.Public())
+	}
+
+	return keys
+}
+
+// LegacyFormat reports whether signed files are produced without key ID, algorithm and signing time metadata.
+func (s *Signer) LegacyFormat() bool {
+	return s.legacyFormat
+}
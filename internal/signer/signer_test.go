@@ -33,19 +33,28 @@ package signer
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"errors"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/pkg/verify"
 )
 
 // generateTestKeyPair generates RSA key pair for testing
@@ -325,6 +334,470 @@ func TestSigner_Sign_Concurrent(t *testing.T) {
 	}
 }
 
+// writePEM writes a PEM block of the given type to a temporary file
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	tmpFile := filepath.Join(t.TempDir(), "key.pem")
+	err := os.WriteFile(tmpFile, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	require.NoError(t, err, "failed to write private key file")
+
+	return tmpFile
+}
+
+func TestNewSigner_KeyTypes(t *testing.T) {
+	rsaKey, _ := generateTestKeyPair(t)
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8 := func(key any) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		return der
+	}
+	sec1 := func(key *ecdsa.PrivateKey) []byte {
+		der, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return der
+	}
+
+	tests := []struct {
+		name          string
+		blockType     string
+		der           []byte
+		opts          []Option
+		wantAlgorithm string
+		wantKeySize   int
+		errContains   string
+	}{
+		{
+			name:          "rsa pkcs8",
+			blockType:     "PRIVATE KEY",
+			der:           pkcs8(rsaKey),
+			wantAlgorithm: AlgorithmRS512,
+			wantKeySize:   2048,
+		},
+		{
+			name:          "rsa pkcs1",
+			blockType:     "RSA PRIVATE KEY",
+			der:           x509.MarshalPKCS1PrivateKey(rsaKey),
+			wantAlgorithm: AlgorithmRS512,
+			wantKeySize:   2048,
+		},
+		{
+			name:          "ecdsa p-256 pkcs8",
+			blockType:     "PRIVATE KEY",
+			der:           pkcs8(p256),
+			wantAlgorithm: AlgorithmES256,
+			wantKeySize:   256,
+		},
+		{
+			name:          "ecdsa p-384 sec1",
+			blockType:     "EC PRIVATE KEY",
+			der:           sec1(p384),
+			wantAlgorithm: AlgorithmES384,
+			wantKeySize:   384,
+		},
+		{
+			name:          "required algorithm matches key",
+			blockType:     "EC PRIVATE KEY",
+			der:           sec1(p256),
+			opts:          []Option{WithAlgorithm(AlgorithmES256)},
+			wantAlgorithm: AlgorithmES256,
+			wantKeySize:   256,
+		},
+		{
+			name:        "required algorithm does not match key",
+			blockType:   "PRIVATE KEY",
+			der:         pkcs8(rsaKey),
+			opts:        []Option{WithAlgorithm(AlgorithmES256)},
+			errContains: `does not support algorithm "ES256"`,
+		},
+		{
+			name:        "unknown required algorithm",
+			blockType:   "PRIVATE KEY",
+			der:         pkcs8(p384),
+			opts:        []Option{WithAlgorithm("HS256")},
+			errContains: `does not support algorithm "HS256"`,
+		},
+		{
+			name:        "unsupported curve",
+			blockType:   "EC PRIVATE KEY",
+			der:         sec1(p521),
+			errContains: "unsupported ECDSA curve P-521",
+		},
+		{
+			name:        "invalid sec1 key",
+			blockType:   "EC PRIVATE KEY",
+			der:         []byte("invalid"),
+			errContains: "failed to parse private key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(writePEM(t, tt.blockType, tt.der), tt.opts...)
+			if tt.errContains != "" {
+				assert.ErrorContains(t, err, tt.errContains)
+				assert.Nil(t, signer)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAlgorithm, signer.Algorithm())
+			assert.Equal(t, tt.wantKeySize, signer.KeySize())
+		})
+	}
+}
+
+func TestNewKeySigner(t *testing.T) {
+	rsaKey, _ := generateTestKeyPair(t)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		key           crypto.Signer
+		opts          []Option
+		wantAlgorithm string
+		errContains   string
+	}{
+		{name: "rsa", key: rsaKey, wantAlgorithm: AlgorithmRS512},
+		{name: "ecdsa p-384", key: p384, wantAlgorithm: AlgorithmES384},
+		{
+			name:        "required algorithm does not match key",
+			key:         p384,
+			opts:        []Option{WithAlgorithm(AlgorithmES256)},
+			errContains: `does not support algorithm "ES256"`,
+		},
+		{name: "unsupported key type", key: edKey, errContains: "unsupported key type ed25519.PublicKey"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewKeySigner(tt.key, tt.opts...)
+			if tt.errContains != "" {
+				assert.ErrorContains(t, err, tt.errContains)
+				assert.Nil(t, signer)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAlgorithm, signer.Algorithm())
+
+			wantKeyID, err := verify.KeyID(tt.key.Public())
+			require.NoError(t, err)
+			assert.Equal(t, wantKeyID, signer.KeyID())
+
+			_, err = signer.Sign([]byte(`{"a":1}`))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// swappableKey is a crypto.Signer whose key is rotated outside of the signer.
+type swappableKey struct {
+	crypto.Signer
+}
+
+func TestSigner_KeyID_ExternalRotation(t *testing.T) {
+	first, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	second, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	key := &swappableKey{Signer: first}
+
+	signer, err := NewKeySigner(key)
+	require.NoError(t, err)
+
+	firstID, err := verify.KeyID(first.Public())
+	require.NoError(t, err)
+	assert.Equal(t, firstID, signer.KeyID())
+
+	key.Signer = second
+
+	secondID, err := verify.KeyID(second.Public())
+	require.NoError(t, err)
+	assert.Equal(t, secondID, signer.KeyID(), "key ID should follow the rotated key")
+}
+
+func TestSigner_Sign_ECDSA(t *testing.T) {
+	data := []byte(`{"b":2,"a":1}`)
+	canonical, err := jsoncanonicalizer.Transform(data)
+	require.NoError(t, err)
+
+	sum256 := sha256.Sum256(canonical)
+	sum384 := sha512.Sum384(canonical)
+
+	tests := []struct {
+		name   string
+		curve  elliptic.Curve
+		hashed []byte
+	}{
+		{name: "ES256", curve: elliptic.P256(), hashed: sum256[:]},
+		{name: "ES384", curve: elliptic.P384(), hashed: sum384[:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(tt.curve, rand.Reader)
+			require.NoError(t, err)
+
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			require.NoError(t, err)
+
+			signer, err := NewSigner(writePEM(t, "PRIVATE KEY", der))
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, signer.Algorithm())
+
+			signature, err := signer.Sign(data)
+			require.NoError(t, err)
+
+			decoded, err := base64.StdEncoding.DecodeString(signature)
+			require.NoError(t, err)
+
+			assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, tt.hashed, decoded),
+				"signature should be a valid ASN.1 DER ECDSA signature of the canonical JSON")
+		})
+	}
+}
+
+func TestSigner_SignJWS(t *testing.T) {
+	rsaKey, _ := generateTestKeyPair(t)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	// the signing input is signed as is, without canonicalization
+	input := []byte("eyJhbGciOiJFUzI1NiJ9.eyJiIjoyLCJhIjoxfQ")
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		verify func(t *testing.T, sig []byte)
+	}{
+		{
+			name: "RS512",
+			key:  rsaKey,
+			verify: func(t *testing.T, sig []byte) {
+				hashed := sha512.Sum512(input)
+				assert.NoError(t, rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA512, hashed[:], sig))
+			},
+		},
+		{
+			name: "ES256",
+			key:  p256,
+			verify: func(t *testing.T, sig []byte) {
+				require.Len(t, sig, 64)
+				hashed := sha256.Sum256(input)
+				r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+				assert.True(t, ecdsa.Verify(&p256.PublicKey, hashed[:], r, s))
+			},
+		},
+		{
+			name: "ES384",
+			key:  p384,
+			verify: func(t *testing.T, sig []byte) {
+				require.Len(t, sig, 96)
+				hashed := sha512.Sum384(input)
+				r, s := new(big.Int).SetBytes(sig[:48]), new(big.Int).SetBytes(sig[48:])
+				assert.True(t, ecdsa.Verify(&p384.PublicKey, hashed[:], r, s))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(tt.key)
+			require.NoError(t, err)
+
+			signer, err := NewSigner(writePEM(t, "PRIVATE KEY", der))
+			require.NoError(t, err)
+
+			signature, err := signer.SignJWS(input)
+			require.NoError(t, err)
+			assert.NotContains(t, signature, "=", "signature must be unpadded base64url")
+
+			decoded, err := base64.RawURLEncoding.DecodeString(signature)
+			require.NoError(t, err)
+
+			tt.verify(t, decoded)
+
+			// COSE signatures use the same encoding without base64
+			raw, err := signer.SignCOSE(input)
+			require.NoError(t, err)
+
+			tt.verify(t, raw)
+		})
+	}
+}
+
+func TestRawECDSASignature_Invalid(t *testing.T) {
+	_, err := rawECDSASignature([]byte("not der"), 32)
+	assert.ErrorContains(t, err, "failed to decode ECDSA signature")
+}
+
+func TestSigner_Metadata(t *testing.T) {
+	privateKey, _ := generateTestKeyPair(t)
+	keyPath := createTestPrivateKeyFile(t, privateKey)
+
+	signer, err := NewSigner(keyPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, "RS512", signer.Algorithm())
+	assert.Equal(t, privateKey.N.BitLen(), signer.KeySize())
+	// the original format is kept unless the metadata is enabled
+	assert.True(t, signer.LegacyFormat())
+
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	hash := sha256.Sum256(der)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(hash[:]), signer.KeyID())
+
+	metadata, err := NewSigner(keyPath, WithLegacyFormat(false))
+	require.NoError(t, err)
+	assert.False(t, metadata.LegacyFormat())
+	assert.Equal(t, signer.KeyID(), metadata.KeyID())
+}
+
+// timestamperFunc adapts a function to the Timestamper interface.
+type timestamperFunc func([]byte) ([]byte, error)
+
+func (f timestamperFunc) Timestamp(data []byte) ([]byte, error) { return f(data) }
+
+func TestSigner_Timestamp(t *testing.T) {
+	privateKey, _ := generateTestKeyPair(t)
+	keyPath := createTestPrivateKeyFile(t, privateKey)
+
+	signature := base64.StdEncoding.EncodeToString([]byte("signature"))
+
+	tests := []struct {
+		name        string
+		timestamper Timestamper
+		signature   string
+		want        string
+		wantErr     string
+	}{
+		{
+			name:      "no timestamper",
+			signature: signature,
+		},
+		{
+			name: "token",
+			timestamper: timestamperFunc(func(data []byte) ([]byte, error) {
+				assert.Equal(t, []byte("signature"), data)
+				return []byte("token"), nil
+			}),
+			signature: signature,
+			want:      base64.StdEncoding.EncodeToString([]byte("token")),
+		},
+		{
+			name: "timestamper error",
+			timestamper: timestamperFunc(func([]byte) ([]byte, error) {
+				return nil, errors.New("tsa unavailable")
+			}),
+			signature: signature,
+			wantErr:   "failed to timestamp signature: tsa unavailable",
+		},
+		{
+			name:        "invalid signature",
+			timestamper: timestamperFunc(func([]byte) ([]byte, error) { return nil, nil }),
+			signature:   "not base64!",
+			wantErr:     "failed to decode signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSigner(keyPath, WithTimestamper(tt.timestamper))
+			require.NoError(t, err)
+
+			got, err := s.Timestamp(tt.signature)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSigner_Rotation(t *testing.T) {
+	oldKey, _ := generateTestKeyPair(t)
+	oldPath := createTestPrivateKeyFile(t, oldKey)
+
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newDER, err := x509.MarshalPKCS8PrivateKey(newKey)
+	require.NoError(t, err)
+	newPath := writePEM(t, "PRIVATE KEY", newDER)
+
+	cutover := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	signer, err := NewSigner(oldPath, WithAlgorithm(AlgorithmRS512), WithRotation(newPath, cutover))
+	require.NoError(t, err)
+
+	data := []byte(`{"a":1}`)
+	hashed := sha256.Sum256(data)
+
+	t.Run("before cutover", func(t *testing.T) {
+		signer.now = func() time.Time { return cutover.Add(-time.Second) }
+
+		assert.Equal(t, AlgorithmRS512, signer.Algorithm())
+		assert.Equal(t, oldKey.N.BitLen(), signer.KeySize())
+
+		cosigners := signer.Cosigners()
+		require.Len(t, cosigners, 1)
+		assert.Equal(t, AlgorithmES256, cosigners[0].Algorithm())
+		assert.NotEqual(t, signer.KeyID(), cosigners[0].KeyID())
+
+		signature, err := cosigners[0].Sign(data)
+		require.NoError(t, err)
+		decoded, err := base64.StdEncoding.DecodeString(signature)
+		require.NoError(t, err)
+		assert.True(t, ecdsa.VerifyASN1(&newKey.PublicKey, hashed[:], decoded))
+	})
+
+	t.Run("after cutover", func(t *testing.T) {
+		signer.now = func() time.Time { return cutover }
+
+		assert.Equal(t, AlgorithmES256, signer.Algorithm())
+		assert.Equal(t, 256, signer.KeySize())
+		assert.Nil(t, signer.Cosigners())
+
+		signature, err := signer.Sign(data)
+		require.NoError(t, err)
+		decoded, err := base64.StdEncoding.DecodeString(signature)
+		require.NoError(t, err)
+		assert.True(t, ecdsa.VerifyASN1(&newKey.PublicKey, hashed[:], decoded))
+	})
+
+	t.Run("missing rotation key", func(t *testing.T) {
+		_, err := NewSigner(oldPath, WithRotation(filepath.Join(t.TempDir(), "missing.pem"), cutover))
+		assert.ErrorContains(t, err, "failed to load rotation key")
+	})
+
+	t.Run("no rotation", func(t *testing.T) {
+		s, err := NewSigner(oldPath)
+		require.NoError(t, err)
+		assert.Nil(t, s.Cosigners())
+	})
+}
+
 func BenchmarkNewSigner(b *testing.B) {
 	privateKey, _ := generateTestKeyPair(&testing.T{})
 	tmpFile := filepath.Join(b.TempDir(), "bench_private.pem")
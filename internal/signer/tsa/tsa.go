@@ -0,0 +1,312 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds every request to the TSA.
+const defaultTimeout = 10 * time.Second
+
+// maxResponseSize limits the size of TSA responses.
+const maxResponseSize = 1 << 20
+
+var (
+	// oidSHA256 identifies the hash algorithm of message imprints (RFC 5754, section 2.2)
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	// oidSignedData is the content type of timestamp tokens (RFC 5652, section 5.1)
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	// oidTSTInfo is the encapsulated content type of timestamp tokens (RFC 3161, section 2.4.2)
+	oidTSTInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+)
+
+// messageImprint is the hash of the timestamped data (RFC 3161, section 2.4.1).
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// timeStampReq is a timestamp request (RFC 3161, section 2.4.1).
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+// pkiStatusInfo is the status of a timestamp response (RFC 3161, section 2.4.2).
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+// timeStampResp is a timestamp response (RFC 3161, section 2.4.2).
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// contentInfo is the CMS content of a timestamp token (RFC 5652, section 3).
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// signedData is the beginning of the CMS SignedData of a timestamp token (RFC 5652, section 5.1),
+// the certificates and signer infos that follow are not decoded.
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo struct {
+		EContentType asn1.ObjectIdentifier
+		EContent     asn1.RawValue
+	}
+}
+
+// accuracy is the accuracy of the time of a timestamp (RFC 3161, section 2.4.2).
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// tstInfo is the timestamp token info signed by the TSA (RFC 3161, section 2.4.2),
+// the TSA name and extensions that follow are not decoded.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Ordering       bool      `asn1:"optional"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+// Client requests RFC 3161 timestamp tokens from a time-stamping authority (TSA) over HTTP.
+// Data is timestamped by its SHA-256 hash.
+type Client struct {
+	client  *http.Client
+	timeout time.Duration
+	url     string
+}
+
+// Option is a functional option type for configuring Client instance.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for TSA requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithTimeout sets the timeout of TSA requests.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// New returns a client of the TSA at rawURL.
+// Returns an error if rawURL is not an absolute http or https URL.
+func New(rawURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tsa url: %w", err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid tsa url %q: expected http or https URL", rawURL)
+	}
+
+	c := &Client{
+		client:  http.DefaultClient,
+		timeout: defaultTimeout,
+		url:     rawURL,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// Timestamp returns the DER encoded timestamp token (a CMS SignedData ContentInfo) issued
+// by the TSA for data. The request carries a random nonce and asks for the TSA certificate
+// to be included in the token. The message imprint and nonce of the token are checked, its
+// signature is left to the clients verifying it with the TSA certificate.
+// Returns an error if the request fails or the TSA does not grant a valid token.
+func (c *Client) Timestamp(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tsa nonce: %w", err)
+	}
+
+	imprint := messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+		HashedMessage: digest[:],
+	}
+
+	req, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: imprint,
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tsa request: %w", err)
+	}
+
+	body, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse tsa response: %w", err)
+	}
+
+	// 0 is granted, 1 is granted with modifications
+	if resp.Status.Status > 1 {
+		return nil, fmt.Errorf("tsa rejected the request: status %d: %s",
+			resp.Status.Status, strings.Join(resp.Status.StatusString, "; "))
+	}
+
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("tsa response has no timestamp token")
+	}
+
+	info, err := parseToken(resp.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) ||
+		!bytes.Equal(info.MessageImprint.HashedMessage, digest[:]) {
+		return nil, fmt.Errorf("tsa token message imprint does not match the request")
+	}
+
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("tsa token nonce does not match the request")
+	}
+
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+// do posts a DER encoded timestamp request to the TSA and returns the response body.
+func (c *Client) do(query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tsa request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/timestamp-query")
+	req.Header.Set("Accept", "application/timestamp-reply")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tsa request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tsa response: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tsa request failed: %s", res.Status)
+	}
+
+	return body, nil
+}
+
+// parseToken decodes the TSTInfo encapsulated in a timestamp token.
+func parseToken(token []byte) (tstInfo, error) {
+	var (
+		ci   contentInfo
+		sd   signedData
+		info tstInfo
+	)
+
+	if _, err := asn1.Unmarshal(token, &ci); err != nil {
+		return info, fmt.Errorf("failed to parse tsa token: %w", err)
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) || ci.Content.Class != asn1.ClassContextSpecific || ci.Content.Tag != 0 {
+		return info, fmt.Errorf("tsa token is not a signed data content")
+	}
+
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return info, fmt.Errorf("failed to parse tsa token signed data: %w", err)
+	}
+
+	content := sd.EncapContentInfo.EContent
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) || content.Class != asn1.ClassContextSpecific || content.Tag != 0 {
+		return info, fmt.Errorf("tsa token does not contain a timestamp token info")
+	}
+
+	var encoded []byte
+	if _, err := asn1.Unmarshal(content.Bytes, &encoded); err != nil {
+		return info, fmt.Errorf("failed to parse tsa token content: %w", err)
+	}
+
+	if _, err := asn1.Unmarshal(encoded, &info); err != nil {
+		return info, fmt.Errorf("failed to parse tsa token info: %w", err)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,250 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package tsa
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenResponse builds a DER encoded timestamp response granting a token for the imprint and nonce.
+func tokenResponse(t *testing.T, imprint messageImprint, nonce *big.Int) []byte {
+	t.Helper()
+
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: imprint,
+		SerialNumber:   big.NewInt(42),
+		GenTime:        time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Nonce:          nonce,
+	})
+	require.NoError(t, err)
+
+	content, err := asn1.Marshal(info)
+	require.NoError(t, err)
+
+	sd := signedData{Version: 3, DigestAlgorithms: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}}
+	sd.EncapContentInfo.EContentType = oidTSTInfo
+	sd.EncapContentInfo.EContent = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content}
+
+	sdBytes, err := asn1.Marshal(sd)
+	require.NoError(t, err)
+
+	res, err := asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{Status: 0},
+		TimeStampToken: asn1.RawValue{FullBytes: mustMarshal(t, contentInfo{
+			ContentType: oidSignedData,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdBytes},
+		})},
+	})
+	require.NoError(t, err)
+
+	return res
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+
+	b, err := asn1.Marshal(v)
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "https", url: "https://tsa.example.com/tsr"},
+		{name: "http", url: "http://127.0.0.1:8080"},
+		{name: "no scheme", url: "tsa.example.com", wantErr: true},
+		{name: "unsupported scheme", url: "ftp://tsa.example.com", wantErr: true},
+		{name: "invalid", url: "http://[::1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.url, WithTimeout(time.Second))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.url, c.url)
+			assert.Equal(t, time.Second, c.timeout)
+		})
+	}
+}
+
+func TestClient_Timestamp(t *testing.T) {
+	data := []byte("signature")
+	digest := sha256.Sum256(data)
+
+	tests := []struct {
+		name    string
+		respond func(t *testing.T, w http.ResponseWriter, req timeStampReq)
+		wantErr string
+	}{
+		{
+			name: "granted",
+			respond: func(t *testing.T, w http.ResponseWriter, req timeStampReq) {
+				_, _ = w.Write(tokenResponse(t, req.MessageImprint, req.Nonce))
+			},
+		},
+		{
+			name: "rejected",
+			respond: func(t *testing.T, w http.ResponseWriter, req timeStampReq) {
+				_, _ = w.Write(mustMarshal(t, timeStampResp{
+					Status: pkiStatusInfo{Status: 2, StatusString: []string{"bad alg"}},
+				}))
+			},
+			wantErr: "tsa rejected the request: status 2: bad alg",
+		},
+		{
+			name: "no token",
+			respond: func(t *testing.T, w http.ResponseWriter, req timeStampReq) {
+				_, _ = w.Write(mustMarshal(t, timeStampResp{Status: pkiStatusInfo{Status: 0}}))
+			},
+			wantErr: "no timestamp token",
+		},
+		{
+			name: "imprint mismatch",
+			respond: func(t *testing.T, w http.ResponseWriter, req timeStampReq) {
+				imprint := req.MessageImprint
+				imprint.HashedMessage = make([]byte, sha256.Size)
+				_, _ = w.Write(tokenResponse(t, imprint, req.Nonce))
+			},
+			wantErr: "message imprint does not match",
+		},
+		{
+			name: "nonce mismatch",
+			respond: func(t *testing.T, w http.ResponseWriter, req timeStampReq) {
+				_, _ = w.Write(tokenResponse(t, req.MessageImprint, big.NewInt(1)))
+			},
+			wantErr: "nonce does not match",
+		},
+		{
+			name: "malformed response",
+			respond: func(t *testing.T, w http.ResponseWriter, req timeStampReq) {
+				_, _ = w.Write([]byte("garbage"))
+			},
+			wantErr: "failed to parse tsa response",
+		},
+		{
+			name: "http error",
+			respond: func(t *testing.T, w http.ResponseWriter, req timeStampReq) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantErr: "503 Service Unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/timestamp-query", r.Header.Get("Content-Type"))
+
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				var req timeStampReq
+				_, err = asn1.Unmarshal(body, &req)
+				require.NoError(t, err)
+
+				assert.Equal(t, 1, req.Version)
+				assert.True(t, req.CertReq)
+				assert.NotNil(t, req.Nonce)
+				assert.True(t, req.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256))
+				assert.Equal(t, digest[:], req.MessageImprint.HashedMessage)
+
+				w.Header().Set("Content-Type", "application/timestamp-reply")
+				tt.respond(t, w, req)
+			}))
+			defer srv.Close()
+
+			c, err := New(srv.URL, WithHTTPClient(srv.Client()))
+			require.NoError(t, err)
+
+			token, err := c.Timestamp(data)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			info, err := parseToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, digest[:], info.MessageImprint.HashedMessage)
+			assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), info.GenTime.UTC())
+		})
+	}
+}
+
+func TestParseToken_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   []byte
+		wantErr string
+	}{
+		{name: "garbage", token: []byte("garbage"), wantErr: "failed to parse tsa token"},
+		{
+			name: "not signed data",
+			token: mustMarshal(t, contentInfo{
+				ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1},
+				Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: []byte{0x05, 0x00}},
+			}),
+			wantErr: "not a signed data content",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseToken(tt.token)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
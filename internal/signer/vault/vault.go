@@ -0,0 +1,490 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTimeout bounds every request to Vault.
+const defaultTimeout = 10 * time.Second
+
+// defaultRefreshInterval is how often the latest version of the key is looked up.
+const defaultRefreshInterval = time.Minute
+
+// maxResponseSize limits the size of responses read from Vault.
+const maxResponseSize = 1 << 20
+
+// keyTypes are the supported transit key types.
+var keyTypes = map[string]bool{
+	"ecdsa-p256": true,
+	"ecdsa-p384": true,
+	"rsa-2048":   true,
+	"rsa-3072":   true,
+	"rsa-4096":   true,
+}
+
+// hashAlgorithms maps hashes to the transit hash_algorithm parameter.
+var hashAlgorithms = map[crypto.Hash]string{
+	crypto.SHA256: "sha2-256",
+	crypto.SHA384: "sha2-384",
+	crypto.SHA512: "sha2-512",
+}
+
+// Key is a Vault transit signing key implementing crypto.Signer.
+// Signatures are made with the latest version of the key, so rotating the key is a Vault
+// operation: the new version is picked up by the periodic refresh without a deployment.
+// The token is renewed in the background while it is renewable.
+type Key struct {
+	address         string
+	client          *http.Client
+	mount           string
+	name            string
+	namespace       string
+	refreshInterval time.Duration
+	timeout         time.Duration
+	tokenFile       string
+
+	mu        sync.RWMutex
+	publicKey crypto.PublicKey
+	token     string
+	version   int
+}
+
+// Option is a functional option type for configuring Key instance.
+type Option func(*Key)
+
+// WithAddress sets the Vault address. By default (or for an empty address) it is read from VAULT_ADDR.
+func WithAddress(address string) Option {
+	return func(k *Key) {
+		if address != "" {
+			k.address = strings.TrimSuffix(address, "/")
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for Vault requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(k *Key) {
+		k.client = client
+	}
+}
+
+// WithMount sets the mount path of the transit secrets engine, "transit" by default.
+func WithMount(mount string) Option {
+	return func(k *Key) {
+		if mount != "" {
+			k.mount = strings.Trim(mount, "/")
+		}
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace. By default it is read from VAULT_NAMESPACE.
+func WithNamespace(namespace string) Option {
+	return func(k *Key) {
+		if namespace != "" {
+			k.namespace = namespace
+		}
+	}
+}
+
+// WithRefreshInterval sets how often the latest version of the key is looked up.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(k *Key) {
+		if interval > 0 {
+			k.refreshInterval = interval
+		}
+	}
+}
+
+// WithTimeout sets the timeout of Vault requests.
+func WithTimeout(timeout time.Duration) Option {
+	return func(k *Key) {
+		if timeout > 0 {
+			k.timeout = timeout
+		}
+	}
+}
+
+// WithTokenFile reads the Vault token from a file, e.g. the sink of a Vault agent.
+// The file is read again before every renewal, so that tokens replaced by the agent are picked up.
+// By default the token is read from VAULT_TOKEN.
+func WithTokenFile(path string) Option {
+	return func(k *Key) {
+		k.tokenFile = path
+	}
+}
+
+// New returns the transit key name. It loads the public key of the latest key version and
+// starts refreshing it and renewing the token until ctx is done.
+// Returns an error if the address or token is missing, the key cannot be read, or the key type
+// is not one of ecdsa-p256, ecdsa-p384, rsa-2048, rsa-3072 or rsa-4096.
+func New(ctx context.Context, name string, opts ...Option) (*Key, error) {
+	if name == "" {
+		return nil, fmt.Errorf("vault transit key name is required")
+	}
+
+	k := &Key{
+		address:         strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"),
+		client:          http.DefaultClient,
+		mount:           "transit",
+		name:            name,
+		namespace:       os.Getenv("VAULT_NAMESPACE"),
+		refreshInterval: defaultRefreshInterval,
+		timeout:         defaultTimeout,
+		token:           os.Getenv("VAULT_TOKEN"),
+	}
+
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	if k.address == "" {
+		return nil, fmt.Errorf("vault address is required, set it or VAULT_ADDR")
+	}
+
+	if err := k.readToken(); err != nil {
+		return nil, err
+	}
+
+	if err := k.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	var lookup struct {
+		Data struct {
+			Renewable bool `json:"renewable"`
+			TTL       int  `json:"ttl"`
+		} `json:"data"`
+	}
+
+	if err := k.call(ctx, http.MethodGet, "/v1/auth/token/lookup-self", nil, &lookup); err != nil {
+		return nil, fmt.Errorf("failed to look up vault token: %w", err)
+	}
+
+	var renewIn time.Duration
+	if lookup.Data.Renewable && lookup.Data.TTL > 0 {
+		renewIn = time.Duration(lookup.Data.TTL) * time.Second / 2
+	}
+
+	go k.run(ctx, renewIn)
+
+	return k, nil
+}
+
+// readToken reads the token from the token file, if any.
+func (k *Key) readToken() error {
+	if k.tokenFile != "" {
+		data, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read vault token file: %w", err)
+		}
+
+		k.mu.Lock()
+		k.token = strings.TrimSpace(string(data))
+		k.mu.Unlock()
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.token == "" {
+		return fmt.Errorf("vault token is required, set the token file or VAULT_TOKEN")
+	}
+
+	return nil
+}
+
+// run refreshes the key and, unless renewIn is zero, renews the token after renewIn until ctx is done.
+func (k *Key) run(ctx context.Context, renewIn time.Duration) {
+	refresh := time.NewTicker(k.refreshInterval)
+	defer refresh.Stop()
+
+	renew := time.NewTimer(renewIn)
+	defer renew.Stop()
+
+	if renewIn == 0 {
+		renew.Stop()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refresh.C:
+			if err := k.refresh(ctx); err != nil {
+				slog.Error("failed to refresh vault transit key", "key", k.name, "err", err)
+			}
+		case <-renew.C:
+			renewIn, err := k.renewToken(ctx)
+			if err != nil {
+				slog.Error("failed to renew vault token", "err", err)
+				renew.Reset(k.refreshInterval)
+				continue
+			}
+
+			if renewIn > 0 {
+				renew.Reset(renewIn)
+			}
+		}
+	}
+}
+
+// renewToken renews the token and returns when it should be renewed again, or zero if it is
+// no longer renewable.
+func (k *Key) renewToken(ctx context.Context) (time.Duration, error) {
+	if err := k.readToken(); err != nil {
+		return 0, err
+	}
+
+	var res struct {
+		Auth struct {
+			LeaseDuration int  `json:"lease_duration"`
+			Renewable     bool `json:"renewable"`
+		} `json:"auth"`
+	}
+
+	if err := k.call(ctx, http.MethodPost, "/v1/auth/token/renew-self", struct{}{}, &res); err != nil {
+		return 0, err
+	}
+
+	slog.Debug("vault token renewed", "lease_duration", res.Auth.LeaseDuration)
+
+	if !res.Auth.Renewable || res.Auth.LeaseDuration <= 0 {
+		slog.Warn("vault token is no longer renewable", "lease_duration", res.Auth.LeaseDuration)
+		return 0, nil
+	}
+
+	return max(time.Duration(res.Auth.LeaseDuration)*time.Second/2, time.Second), nil
+}
+
+// refresh loads the public key of the latest version of the key.
+func (k *Key) refresh(ctx context.Context) error {
+	var res struct {
+		Data struct {
+			Type          string `json:"type"`
+			LatestVersion int    `json:"latest_version"`
+			Keys          map[string]struct {
+				PublicKey string `json:"public_key"`
+			} `json:"keys"`
+		} `json:"data"`
+	}
+
+	if err := k.call(ctx, http.MethodGet, "/v1/"+k.mount+"/keys/"+k.name, nil, &res); err != nil {
+		return fmt.Errorf("failed to read vault transit key %q: %w", k.name, err)
+	}
+
+	if !keyTypes[res.Data.Type] {
+		return fmt.Errorf("unsupported type %q of vault transit key %q, "+
+			"use ecdsa-p256, ecdsa-p384, rsa-2048, rsa-3072 or rsa-4096", res.Data.Type, k.name)
+	}
+
+	latest, ok := res.Data.Keys[strconv.Itoa(res.Data.LatestVersion)]
+	if !ok {
+		return fmt.Errorf("vault transit key %q has no version %d", k.name, res.Data.LatestVersion)
+	}
+
+	block, _ := pem.Decode([]byte(latest.PublicKey))
+	if block == nil {
+		return fmt.Errorf("failed to decode public key of vault transit key %q", k.name)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key of vault transit key %q: %w", k.name, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.version != 0 && k.version != res.Data.LatestVersion {
+		slog.Info("vault transit key rotated", "key", k.name, "version", res.Data.LatestVersion)
+	}
+
+	k.publicKey = publicKey
+	k.version = res.Data.LatestVersion
+
+	return nil
+}
+
+// Public returns the public key of the latest key version.
+func (k *Key) Public() crypto.PublicKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.publicKey
+}
+
+// Version returns the latest key version.
+func (k *Key) Version() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.version
+}
+
+// Sign signs digest with the latest version of the key. opts must be the SHA-256, SHA-384 or
+// SHA-512 hash used to compute digest; RSA keys sign with PKCS1v15, ECDSA keys return ASN.1
+// DER signatures. rand is ignored.
+func (k *Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, fmt.Errorf("rsa pss signatures are not supported")
+	}
+
+	hashAlgorithm, ok := hashAlgorithms[opts.HashFunc()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash %s", opts.HashFunc())
+	}
+
+	if len(digest) != opts.HashFunc().Size() {
+		return nil, fmt.Errorf("digest length %d does not match hash %s", len(digest), opts.HashFunc())
+	}
+
+	version := k.Version()
+
+	req := map[string]any{
+		"input":                base64.StdEncoding.EncodeToString(digest),
+		"key_version":          version,
+		"marshaling_algorithm": "asn1",
+		"prehashed":            true,
+		"signature_algorithm":  "pkcs1v15",
+	}
+
+	var res struct {
+		Data struct {
+			Signature string `json:"signature"`
+		} `json:"data"`
+	}
+
+	if err := k.call(context.Background(), http.MethodPost,
+		"/v1/"+k.mount+"/sign/"+k.name+"/"+hashAlgorithm, req, &res); err != nil {
+		return nil, fmt.Errorf("vault transit sign: %w", err)
+	}
+
+	encoded, ok := strings.CutPrefix(res.Data.Signature, fmt.Sprintf("vault:v%d:", version))
+	if !ok {
+		return nil, fmt.Errorf("vault transit sign: unexpected signature %q, want version %d", res.Data.Signature, version)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("vault transit sign: failed to decode signature: %w", err)
+	}
+
+	return signature, nil
+}
+
+// APIError is an error returned by the Vault API.
+type APIError struct {
+	Errors []string `json:"errors"`
+	Status int      `json:"-"`
+}
+
+// Error returns the error messages.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (status %d)", strings.Join(e.Errors, "; "), e.Status)
+}
+
+// call sends a Vault API request with input as JSON body and decodes the response into output.
+func (k *Key) call(ctx context.Context, method, path string, input, output any) error {
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+
+	var body io.Reader
+	if input != nil {
+		data, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, k.address+path, body)
+	if err != nil {
+		return err
+	}
+
+	k.mu.RLock()
+	req.Header.Set("X-Vault-Token", k.token)
+	k.mu.RUnlock()
+
+	if k.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", k.namespace)
+	}
+
+	if input != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{Status: resp.StatusCode}
+		if err := json.Unmarshal(data, apiErr); err != nil || len(apiErr.Errors) == 0 {
+			apiErr.Errors = []string{http.StatusText(resp.StatusCode)}
+		}
+
+		return apiErr
+	}
+
+	if err := json.Unmarshal(data, output); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,351 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package vault
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+)
+
+// fakeVault is a Vault server with a transit key backed by local keys, one per version.
+type fakeVault struct {
+	mu       sync.Mutex
+	keyType  string
+	versions []crypto.Signer
+	token    string
+	ttl      int
+	renewals atomic.Int32
+}
+
+func (f *fakeVault) rotate(t *testing.T, key crypto.Signer) {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.versions = append(f.versions, key)
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != f.token {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/auth/token/lookup-self":
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"renewable": f.ttl > 0, "ttl": f.ttl}})
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/auth/token/renew-self":
+		f.renewals.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"renewable": true, "lease_duration": f.ttl}})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/transit/keys/signing":
+		keys := map[string]any{}
+		for i, key := range f.versions {
+			der, _ := x509.MarshalPKIXPublicKey(key.Public())
+			keys[strconv.Itoa(i+1)] = map[string]any{
+				"public_key": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"type":           f.keyType,
+			"latest_version": len(f.versions),
+			"keys":           keys,
+		}})
+	case r.Method == http.MethodPost && len(r.URL.Path) > len("/v1/transit/sign/signing/"):
+		var req struct {
+			Input              string `json:"input"`
+			KeyVersion         int    `json:"key_version"`
+			Prehashed          bool   `json:"prehashed"`
+			SignatureAlgorithm string `json:"signature_algorithm"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		hash := map[string]crypto.Hash{
+			"sha2-256": crypto.SHA256,
+			"sha2-384": crypto.SHA384,
+			"sha2-512": crypto.SHA512,
+		}[r.URL.Path[len("/v1/transit/sign/signing/"):]]
+
+		digest, _ := base64.StdEncoding.DecodeString(req.Input)
+		if !req.Prehashed || req.SignatureAlgorithm != "pkcs1v15" || req.KeyVersion < 1 || req.KeyVersion > len(f.versions) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["invalid request"]}`))
+			return
+		}
+
+		signature, err := f.versions[req.KeyVersion-1].Sign(rand.Reader, digest, hash)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"signature":   fmt.Sprintf("vault:v%d:%s", req.KeyVersion, base64.StdEncoding.EncodeToString(signature)),
+			"key_version": req.KeyVersion,
+		}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+	}
+}
+
+// setupFakeVault starts a fake Vault server with a transit key "signing" of keyType.
+func setupFakeVault(t *testing.T, keyType string, key crypto.Signer) (*fakeVault, []Option) {
+	t.Helper()
+
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	f := &fakeVault{keyType: keyType, versions: []crypto.Signer{key}, token: "s.token"}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "s.token")
+	t.Setenv("VAULT_NAMESPACE", "")
+
+	return f, []Option{WithAddress(srv.URL), WithHTTPClient(srv.Client())}
+}
+
+func TestNew(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		keyName string
+		keyType string
+		setup   func(t *testing.T) []Option
+		wantErr string
+	}{
+		{name: "ecdsa", keyName: "signing", keyType: "ecdsa-p256"},
+		{name: "empty name", keyType: "ecdsa-p256", wantErr: "vault transit key name is required"},
+		{name: "missing key", keyName: "missing", keyType: "ecdsa-p256", wantErr: `failed to read vault transit key "missing"`},
+		{name: "unsupported type", keyName: "signing", keyType: "ed25519", wantErr: `unsupported type "ed25519"`},
+		{
+			name:    "missing token",
+			keyName: "signing",
+			keyType: "ecdsa-p256",
+			setup: func(t *testing.T) []Option {
+				t.Setenv("VAULT_TOKEN", "")
+				return nil
+			},
+			wantErr: "vault token is required",
+		},
+		{
+			name:    "wrong token",
+			keyName: "signing",
+			keyType: "ecdsa-p256",
+			setup: func(t *testing.T) []Option {
+				t.Setenv("VAULT_TOKEN", "s.other")
+				return nil
+			},
+			wantErr: "permission denied (status 403)",
+		},
+		{
+			name:    "token file",
+			keyName: "signing",
+			keyType: "ecdsa-p256",
+			setup: func(t *testing.T) []Option {
+				t.Setenv("VAULT_TOKEN", "")
+
+				path := filepath.Join(t.TempDir(), "token")
+				require.NoError(t, os.WriteFile(path, []byte("s.token\n"), 0o600))
+
+				return []Option{WithTokenFile(path)}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, opts := setupFakeVault(t, tt.keyType, ecKey)
+			if tt.setup != nil {
+				opts = append(opts, tt.setup(t)...)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+
+			k, err := New(ctx, tt.keyName, opts...)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, &ecKey.PublicKey, k.Public())
+			assert.Equal(t, 1, k.Version())
+		})
+	}
+}
+
+func TestNew_Address(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+
+	_, err := New(context.Background(), "signing")
+	assert.ErrorContains(t, err, "vault address is required")
+}
+
+func TestKey_Sign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	sum256 := sha256.Sum256([]byte("data"))
+	sum384 := sha512.Sum384([]byte("data"))
+	sum512 := sha512.Sum512([]byte("data"))
+
+	tests := []struct {
+		name    string
+		keyType string
+		key     crypto.Signer
+		digest  []byte
+		opts    crypto.SignerOpts
+		wantErr string
+	}{
+		{name: "rsa", keyType: "rsa-2048", key: rsaKey, digest: sum512[:], opts: crypto.SHA512},
+		{name: "ecdsa", keyType: "ecdsa-p384", key: ecKey, digest: sum384[:], opts: crypto.SHA384},
+		{name: "digest length", keyType: "ecdsa-p384", key: ecKey, digest: sum256[:], opts: crypto.SHA384, wantErr: "digest length 32 does not match hash SHA-384"},
+		{name: "pss", keyType: "rsa-2048", key: rsaKey, digest: sum256[:], opts: &rsa.PSSOptions{Hash: crypto.SHA256}, wantErr: "rsa pss signatures are not supported"},
+		{name: "unsupported hash", keyType: "rsa-2048", key: rsaKey, digest: sum256[:], opts: crypto.SHA1, wantErr: "unsupported hash SHA-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, opts := setupFakeVault(t, tt.keyType, tt.key)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+
+			k, err := New(ctx, "signing", opts...)
+			require.NoError(t, err)
+
+			signature, err := k.Sign(rand.Reader, tt.digest, tt.opts)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			switch pub := tt.key.Public().(type) {
+			case *rsa.PublicKey:
+				assert.NoError(t, rsa.VerifyPKCS1v15(pub, tt.opts.HashFunc(), tt.digest, signature))
+			case *ecdsa.PublicKey:
+				assert.True(t, ecdsa.VerifyASN1(pub, tt.digest, signature))
+			}
+		})
+	}
+}
+
+func TestKey_Rotation(t *testing.T) {
+	first, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	second, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	f, opts := setupFakeVault(t, "ecdsa-p256", first)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	k, err := New(ctx, "signing", append(opts, WithRefreshInterval(10*time.Millisecond))...)
+	require.NoError(t, err)
+
+	f.rotate(t, second)
+
+	require.Eventually(t, func() bool { return k.Version() == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, &second.PublicKey, k.Public())
+
+	sum := sha256.Sum256([]byte("data"))
+	signature, err := k.Sign(rand.Reader, sum[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&second.PublicKey, sum[:], signature), "signed with the new key version")
+}
+
+func TestKey_TokenRenewal(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	f, opts := setupFakeVault(t, "ecdsa-p256", key)
+	f.ttl = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	_, err = New(ctx, "signing", opts...)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return f.renewals.Load() > 0 }, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestAPIError(t *testing.T) {
+	err := &APIError{Errors: []string{"permission denied", "token expired"}, Status: http.StatusForbidden}
+
+	assert.EqualError(t, err, "permission denied; token expired (status 403)")
+}
@@ -0,0 +1,210 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package cached
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// New wraps a storage backend with a read-through cache of GetByFile results. Entries
+// expire after ttl and at most size entries are kept, evicting the least recently used
+// one. The whole cache is invalidated by SaveKeys and DeleteKeys of this instance;
+// changes written by other instances sharing the backend become visible once the
+// entries expire.
+func New(s types.Storage, ttl time.Duration, size int) *Storage {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Storage{
+		Storage: s,
+		cache: &cache{
+			entries: make(map[string]*list.Element, size),
+			lru:     list.New(),
+			now:     time.Now,
+			size:    size,
+			ttl:     ttl,
+		},
+	}
+}
+
+// Storage implements the types.Storage interface by caching reads of the wrapped backend.
+type Storage struct {
+	types.Storage
+	cache *cache
+}
+
+// WithContext returns a copy of the storage bound to ctx that shares the cache.
+func (s *Storage) WithContext(ctx context.Context) types.Storage {
+	c := *s
+	c.Storage = types.WithContext(ctx, s.Storage)
+
+	return &c
+}
+
+// SaveKeys persists domain keys using the wrapped backend and invalidates the cache.
+func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
+	defer s.cache.invalidate()
+
+	return s.Storage.SaveKeys(keys)
+}
+
+// DeleteKeys removes the keys of a FQDN using the wrapped backend and invalidates the cache.
+func (s *Storage) DeleteKeys(file, fqdn string) error {
+	defer s.cache.invalidate()
+
+	return s.Storage.DeleteKeys(file, fqdn)
+}
+
+// GetByFile retrieves domain keys by filename from the cache or the wrapped backend.
+// Errors are not cached. The returned keys are a copy and may be reordered by the
+// caller; the returned data is shared and must not be modified.
+func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
+	key := "file:" + file
+
+	if v, ok := s.cache.get(key); ok {
+		f := v.(fileEntry)
+		return slices.Clone(f.keys), f.data, nil
+	}
+
+	gen := s.cache.generation()
+
+	keys, data, err := s.Storage.GetByFile(file)
+	if err != nil {
+		return keys, data, err
+	}
+
+	s.cache.set(key, fileEntry{keys: slices.Clone(keys), data: data}, gen)
+
+	return keys, data, nil
+}
+
+// fileEntry is a cached GetByFile result.
+type fileEntry struct {
+	keys []types.DomainKey
+	data []byte
+}
+
+// entry is an element of the LRU list.
+type entry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+// cache is a size bounded LRU cache with expiring entries. The generation is
+// incremented on every invalidation, so values read from the backend before an
+// invalidation are not stored after it.
+type cache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	gen     uint64
+	lru     *list.List
+	now     func() time.Time
+	size    int
+	ttl     time.Duration
+}
+
+// get returns the unexpired value of key and marks it as recently used.
+func (c *cache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+
+	return e.value, true
+}
+
+// generation returns the current cache generation.
+func (c *cache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+// set stores value under key unless the cache has been invalidated since gen.
+func (c *cache) set(key string, value any, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry{
+		key:     key,
+		value:   value,
+		expires: c.now().Add(c.ttl),
+	})
+}
+
+// invalidate drops every entry and starts a new generation.
+func (c *cache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.lru.Init()
+	clear(c.entries)
+}
+
+// remove drops el from the cache. The caller must hold the lock.
+func (c *cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*entry).key)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package cached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// countingStorage counts reads and returns the configured keys.
+type countingStorage struct {
+	types.Storage
+	mu    sync.Mutex
+	reads int
+	keys  []types.DomainKey
+	err   error
+	ctx   context.Context
+}
+
+func (s *countingStorage) GetByFile(string) ([]types.DomainKey, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reads++
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+
+	return append([]types.DomainKey(nil), s.keys...), []byte("data"), nil
+}
+
+func (s *countingStorage) SaveKeys(map[string]types.DomainKey) error { return nil }
+func (s *countingStorage) DeleteKeys(string, string) error           { return nil }
+
+func (s *countingStorage) WithContext(ctx context.Context) types.Storage {
+	c := &countingStorage{keys: s.keys, ctx: ctx}
+	return c
+}
+
+func (s *countingStorage) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reads
+}
+
+// clock is a manually advanced time source.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func newTestStorage(backend types.Storage, ttl time.Duration, size int) (*Storage, *clock) {
+	s := New(backend, ttl, size)
+	c := &clock{now: time.Unix(1700000000, 0)}
+	s.cache.now = c.Now
+
+	return s, c
+}
+
+func TestStorage_GetByFile(t *testing.T) {
+	backend := &countingStorage{keys: []types.DomainKey{{Fqdn: "a.example.com", Key: "pin"}}}
+	s, clk := newTestStorage(backend, time.Minute, 10)
+
+	keys, data, err := s.GetByFile("f.json")
+	require.NoError(t, err)
+	assert.Equal(t, backend.keys, keys)
+	assert.Equal(t, []byte("data"), data)
+
+	// hits are served from the cache and return copies of the keys
+	keys[0].Key = "modified"
+	keys, _, err = s.GetByFile("f.json")
+	require.NoError(t, err)
+	assert.Equal(t, "pin", keys[0].Key)
+	assert.Equal(t, 1, backend.count())
+
+	// other files are cached separately
+	_, _, err = s.GetByFile("g.json")
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.count())
+
+	// entries expire after the ttl
+	clk.now = clk.now.Add(time.Minute)
+	_, _, err = s.GetByFile("f.json")
+	require.NoError(t, err)
+	assert.Equal(t, 3, backend.count())
+}
+
+func TestStorage_GetByFile_Error(t *testing.T) {
+	backend := &countingStorage{err: errors.New("connection refused")}
+	s, _ := newTestStorage(backend, time.Minute, 10)
+
+	for range 2 {
+		_, _, err := s.GetByFile("f.json")
+		assert.EqualError(t, err, "connection refused")
+	}
+
+	assert.Equal(t, 2, backend.count())
+}
+
+func TestStorage_Invalidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(s *Storage) error
+	}{
+		{
+			name:  "save keys",
+			write: func(s *Storage) error { return s.SaveKeys(map[string]types.DomainKey{}) },
+		},
+		{
+			name:  "delete keys",
+			write: func(s *Storage) error { return s.DeleteKeys("f.json", "a.example.com") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &countingStorage{keys: []types.DomainKey{{Fqdn: "a.example.com", Key: "pin"}}}
+			s, _ := newTestStorage(backend, time.Minute, 10)
+
+			_, _, err := s.GetByFile("f.json")
+			require.NoError(t, err)
+
+			require.NoError(t, tt.write(s))
+
+			_, _, err = s.GetByFile("f.json")
+			require.NoError(t, err)
+
+			assert.Equal(t, 2, backend.count())
+		})
+	}
+}
+
+func TestStorage_Eviction(t *testing.T) {
+	backend := &countingStorage{}
+	s, _ := newTestStorage(backend, time.Minute, 2)
+
+	for _, file := range []string{"a.json", "b.json", "a.json", "c.json", "a.json", "b.json"} {
+		_, _, err := s.GetByFile(file)
+		require.NoError(t, err)
+	}
+
+	// "b" is the least recently used entry when "c" is added and "c" when "b" is added again
+	assert.Equal(t, 4, backend.count())
+	assert.Equal(t, 2, s.cache.lru.Len())
+	assert.Len(t, s.cache.entries, 2)
+}
+
+func TestNew_MinSize(t *testing.T) {
+	s := New(&countingStorage{}, time.Minute, 0)
+
+	assert.Equal(t, 1, s.cache.size)
+}
+
+func TestStorage_WithContext(t *testing.T) {
+	backend := &countingStorage{keys: []types.DomainKey{{Fqdn: "a.example.com", Key: "pin"}}}
+	s, _ := newTestStorage(backend, time.Minute, 10)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	bound := types.WithContext(ctx, s)
+	require.IsType(t, &Storage{}, bound)
+
+	inner := bound.(*Storage).Storage.(*countingStorage)
+	assert.Equal(t, ctx, inner.ctx)
+
+	// bound copies share the cache
+	_, _, err := bound.GetByFile("f.json")
+	require.NoError(t, err)
+	_, _, err = s.GetByFile("f.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, inner.count())
+	assert.Equal(t, 0, backend.count())
+}
+
+func TestStorage_Concurrent(t *testing.T) {
+	backend := &countingStorage{keys: []types.DomainKey{{Fqdn: "a.example.com", Key: "pin"}}}
+	s := New(backend, time.Minute, 4)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			file := fmt.Sprintf("f%d.json", i%8)
+
+			keys, _, err := s.GetByFile(file)
+			assert.NoError(t, err)
+			assert.Len(t, keys, 1)
+
+			if i%5 == 0 {
+				assert.NoError(t, s.SaveKeys(nil))
+			}
+		})
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,268 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// prefix marks values encrypted by this package. Values without the prefix are
+// returned as is, so storages populated before encryption was enabled stay readable.
+const prefix = "enc:v1:"
+
+// ErrInvalidKey is returned when the encryption key is not a base64 encoded AES key.
+var ErrInvalidKey = errors.New("invalid encryption key")
+
+// New wraps a storage backend with a decorator that encrypts the Key, Pins and LastError
+// fields of domain keys with AES-GCM before they are saved and decrypts them on read.
+// The key must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+//
+// Empty values are stored as is, so the health probes of the wrapped backend that
+// check for missing keys and errors keep working on encrypted data.
+func New(s types.Storage, key []byte) (types.Storage, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm cipher: %w", err)
+	}
+
+	return &Storage{
+		Storage: s,
+		aead:    aead,
+	}, nil
+}
+
+// ParseKey decodes a base64 encoded (standard or URL alphabet, padded or not) AES key.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		key, err := enc.DecodeString(s)
+		if err != nil {
+			continue
+		}
+
+		switch len(key) {
+		case 16, 24, 32:
+			return key, nil
+		}
+
+		return nil, fmt.Errorf("%w: length must be 16, 24 or 32 bytes, got %d", ErrInvalidKey, len(key))
+	}
+
+	return nil, fmt.Errorf("%w: not base64 encoded", ErrInvalidKey)
+}
+
+// LoadKey returns the AES key from the value itself or, when value is empty, from
+// the contents of file.
+func LoadKey(value, file string) ([]byte, error) {
+	if value == "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+
+		value = string(data)
+	}
+
+	return ParseKey(value)
+}
+
+// Storage implements the types.Storage interface by encrypting key material
+// before delegating to the wrapped backend.
+type Storage struct {
+	types.Storage
+	aead cipher.AEAD
+}
+
+// WithContext returns a copy of the storage bound to ctx.
+func (s *Storage) WithContext(ctx context.Context) types.Storage {
+	c := *s
+	c.Storage = types.WithContext(ctx, s.Storage)
+
+	return &c
+}
+
+// SaveKeys encrypts the key material and persists it using the wrapped backend.
+func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
+	sealed := make(map[string]types.DomainKey, len(keys))
+
+	for name, key := range keys {
+		var err error
+
+		if key.Key, err = s.seal(key.Key, key.Fqdn); err != nil {
+			return err
+		}
+
+		if key.Pins, err = s.sealPins(key.Pins, key.Fqdn); err != nil {
+			return err
+		}
+
+		if key.LastError, err = s.seal(key.LastError, key.Fqdn); err != nil {
+			return err
+		}
+
+		sealed[name] = key
+	}
+
+	return s.Storage.SaveKeys(sealed)
+}
+
+// GetByFile retrieves domain keys by filename using the wrapped backend and decrypts them.
+func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
+	keys, data, err := s.Storage.GetByFile(file)
+	if err != nil {
+		return keys, data, err
+	}
+
+	if err := s.openAll(keys); err != nil {
+		return nil, nil, err
+	}
+
+	return keys, data, nil
+}
+
+// GetByFqdn retrieves the keys of a FQDN using the wrapped backend and decrypts them.
+func (s *Storage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	keys, err := s.Storage.GetByFqdn(fqdn)
+	if err != nil {
+		return keys, err
+	}
+
+	if err := s.openAll(keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// openAll decrypts the key material of keys in place.
+func (s *Storage) openAll(keys []types.DomainKey) error {
+	for i := range keys {
+		var err error
+
+		if keys[i].Key, err = s.open(keys[i].Key, keys[i].Fqdn); err != nil {
+			return err
+		}
+
+		for j := range keys[i].Pins {
+			if keys[i].Pins[j], err = s.open(keys[i].Pins[j], keys[i].Fqdn); err != nil {
+				return err
+			}
+		}
+
+		if keys[i].LastError, err = s.open(keys[i].LastError, keys[i].Fqdn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sealPins encrypts every pin into a new slice, so the pins of the caller are not modified.
+func (s *Storage) sealPins(pins []string, fqdn string) ([]string, error) {
+	if len(pins) == 0 {
+		return pins, nil
+	}
+
+	sealed := make([]string, len(pins))
+
+	for i, pin := range pins {
+		var err error
+
+		if sealed[i], err = s.seal(pin, fqdn); err != nil {
+			return nil, err
+		}
+	}
+
+	return sealed, nil
+}
+
+// seal encrypts a non-empty value. The FQDN is used as additional data, so a
+// ciphertext copied to another domain fails to decrypt.
+func (s *Storage) seal(value, fqdn string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(value)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return prefix + base64.StdEncoding.EncodeToString(
+		s.aead.Seal(nonce, nonce, []byte(value), []byte(fqdn)),
+	), nil
+}
+
+// open decrypts a value produced by seal. Values without the prefix are returned unchanged.
+func (s *Storage) open(value, fqdn string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode encrypted value of fqdn %q: %w", fqdn, err)
+	}
+
+	if len(data) < s.aead.NonceSize() {
+		return "", fmt.Errorf("failed to decrypt value of fqdn %q: ciphertext too short", fqdn)
+	}
+
+	plain, err := s.aead.Open(nil, data[:s.aead.NonceSize()], data[s.aead.NonceSize():], []byte(fqdn))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value of fqdn %q: %w", fqdn, err)
+	}
+
+	return string(plain), nil
+}
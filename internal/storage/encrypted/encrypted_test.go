@@ -0,0 +1,376 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/storage/redis"
+	"ssl-pinning/internal/storage/types"
+)
+
+// recordingStorage keeps saved keys as is, so tests can inspect what reaches the backend.
+type recordingStorage struct {
+	types.Storage
+	keys map[string]types.DomainKey
+	ctx  context.Context
+}
+
+func (s *recordingStorage) SaveKeys(keys map[string]types.DomainKey) error {
+	s.keys = keys
+	return nil
+}
+
+func (s *recordingStorage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
+	var out []types.DomainKey
+	for _, k := range s.keys {
+		if k.File == file {
+			out = append(out, k)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, nil, types.ErrNotFound
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Fqdn < out[j].Fqdn })
+
+	return out, nil, nil
+}
+
+func (s *recordingStorage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	var out []types.DomainKey
+	for _, k := range s.keys {
+		if k.Fqdn == fqdn {
+			out = append(out, k)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, types.ErrNotFound
+	}
+
+	return out, nil
+}
+
+func (s *recordingStorage) WithContext(ctx context.Context) types.Storage {
+	c := *s
+	c.ctx = ctx
+	return &c
+}
+
+func testKey() []byte {
+	return bytes.Repeat([]byte{0x42}, 32)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr bool
+	}{
+		{name: "aes-128", key: make([]byte, 16)},
+		{name: "aes-192", key: make([]byte, 24)},
+		{name: "aes-256", key: make([]byte, 32)},
+		{name: "empty", key: nil, wantErr: true},
+		{name: "invalid length", key: make([]byte, 20), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(&recordingStorage{}, tt.key)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidKey)
+				assert.Nil(t, s)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotNil(t, s)
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	raw := testKey()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "standard", value: base64.StdEncoding.EncodeToString(raw), want: raw},
+		{name: "raw standard", value: base64.RawStdEncoding.EncodeToString(raw[:16]), want: raw[:16]},
+		{name: "url", value: base64.URLEncoding.EncodeToString(raw[:24]), want: raw[:24]},
+		{name: "trailing newline", value: base64.StdEncoding.EncodeToString(raw) + "\n", want: raw},
+		{name: "wrong length", value: base64.StdEncoding.EncodeToString(raw[:10]), wantErr: true},
+		{name: "not base64", value: "not a key!", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKey(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidKey)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKey())
+	file := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(file, []byte(encoded+"\n"), 0o600))
+
+	key, err := LoadKey(encoded, "")
+	require.NoError(t, err)
+	assert.Equal(t, testKey(), key)
+
+	key, err = LoadKey("", file)
+	require.NoError(t, err)
+	assert.Equal(t, testKey(), key)
+
+	_, err = LoadKey("", filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStorage_RoundTrip(t *testing.T) {
+	backend := &recordingStorage{}
+	s, err := New(backend, testKey())
+	require.NoError(t, err)
+
+	keys := map[string]types.DomainKey{
+		"a": {File: "f.json", Fqdn: "a.example.com", Key: "pin-a", Pins: []string{"pin-a", "pin-ca"}},
+		"b": {File: "f.json", Fqdn: "b.example.com", LastError: "dial tcp: timeout"},
+	}
+
+	require.NoError(t, s.SaveKeys(keys))
+
+	// the backend never sees plaintext and the caller's map is not modified
+	assert.True(t, strings.HasPrefix(backend.keys["a"].Key, prefix))
+	assert.NotContains(t, backend.keys["a"].Key, "pin-a")
+	assert.Empty(t, backend.keys["a"].LastError)
+	assert.Empty(t, backend.keys["b"].Key)
+	assert.True(t, strings.HasPrefix(backend.keys["b"].LastError, prefix))
+	assert.Equal(t, "pin-a", keys["a"].Key)
+	assert.Equal(t, []string{"pin-a", "pin-ca"}, keys["a"].Pins)
+
+	require.Len(t, backend.keys["a"].Pins, 2)
+	for _, pin := range backend.keys["a"].Pins {
+		assert.True(t, strings.HasPrefix(pin, prefix))
+		assert.NotContains(t, pin, "pin-")
+	}
+
+	got, _, err := s.GetByFile("f.json")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "pin-a", got[0].Key)
+	assert.Equal(t, []string{"pin-a", "pin-ca"}, got[0].Pins)
+	assert.Empty(t, got[0].LastError)
+	assert.Empty(t, got[1].Key)
+	assert.Equal(t, "dial tcp: timeout", got[1].LastError)
+
+	byFqdn, err := s.GetByFqdn("a.example.com")
+	require.NoError(t, err)
+	require.Len(t, byFqdn, 1)
+	assert.Equal(t, "pin-a", byFqdn[0].Key)
+	assert.Equal(t, []string{"pin-a", "pin-ca"}, byFqdn[0].Pins)
+}
+
+func TestStorage_RoundTripRedis(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	backend, err := redis.New(context.Background(),
+		types.WithAppID("test-app"),
+		types.WithDSN("redis://"+mr.Addr()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { backend.Close() })
+
+	s, err := New(backend, testKey())
+	require.NoError(t, err)
+
+	pins := []string{"bGVhZi1waW4=", "Y2EtcGlu"}
+
+	require.NoError(t, s.SaveKeys(map[string]types.DomainKey{
+		"a": {File: "f.json", Fqdn: "a.example.com", Key: pins[0], Pins: pins},
+	}))
+
+	// the raw values stored by the backend contain no plaintext pin
+	hash := "f.json:a.example.com:test-app"
+	for _, field := range []string{"key", "pins"} {
+		raw := mr.HGet(hash, field)
+		require.NotEmpty(t, raw)
+
+		for _, pin := range pins {
+			assert.NotContains(t, raw, pin, field)
+		}
+	}
+
+	got, _, err := s.GetByFile("f.json")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, pins[0], got[0].Key)
+	assert.Equal(t, pins, got[0].Pins)
+}
+
+func TestStorage_NonDeterministic(t *testing.T) {
+	backend := &recordingStorage{}
+	s, err := New(backend, testKey())
+	require.NoError(t, err)
+
+	key := types.DomainKey{File: "f.json", Fqdn: "a.example.com", Key: "pin"}
+
+	require.NoError(t, s.SaveKeys(map[string]types.DomainKey{"a": key}))
+	first := backend.keys["a"].Key
+
+	require.NoError(t, s.SaveKeys(map[string]types.DomainKey{"a": key}))
+	assert.NotEqual(t, first, backend.keys["a"].Key)
+}
+
+func TestStorage_Plaintext(t *testing.T) {
+	backend := &recordingStorage{keys: map[string]types.DomainKey{
+		"a": {File: "f.json", Fqdn: "a.example.com", Key: "legacy-pin"},
+	}}
+
+	s, err := New(backend, testKey())
+	require.NoError(t, err)
+
+	got, _, err := s.GetByFile("f.json")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "legacy-pin", got[0].Key)
+}
+
+func TestStorage_DecryptErrors(t *testing.T) {
+	backend := &recordingStorage{}
+	s, err := New(backend, testKey())
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveKeys(map[string]types.DomainKey{
+		"a": {File: "f.json", Fqdn: "a.example.com", Key: "pin"},
+	}))
+	sealed := backend.keys["a"].Key
+
+	other, err := New(backend, bytes.Repeat([]byte{0x01}, 32))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		storage types.Storage
+		key     types.DomainKey
+	}{
+		{
+			name:    "wrong key",
+			storage: other,
+			key:     types.DomainKey{File: "f.json", Fqdn: "a.example.com", Key: sealed},
+		},
+		{
+			name:    "moved to another fqdn",
+			storage: s,
+			key:     types.DomainKey{File: "f.json", Fqdn: "b.example.com", Key: sealed},
+		},
+		{
+			name:    "not base64",
+			storage: s,
+			key:     types.DomainKey{File: "f.json", Fqdn: "a.example.com", Key: prefix + "!!!"},
+		},
+		{
+			name:    "too short",
+			storage: s,
+			key:     types.DomainKey{File: "f.json", Fqdn: "a.example.com", Key: prefix + "AAAA"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend.keys = map[string]types.DomainKey{"a": tt.key}
+
+			keys, _, err := tt.storage.GetByFile("f.json")
+			assert.Error(t, err)
+			assert.Nil(t, keys)
+
+			byFqdn, err := tt.storage.GetByFqdn(tt.key.Fqdn)
+			assert.Error(t, err)
+			assert.Nil(t, byFqdn)
+		})
+	}
+}
+
+func TestStorage_NotFound(t *testing.T) {
+	s, err := New(&recordingStorage{}, testKey())
+	require.NoError(t, err)
+
+	_, _, err = s.GetByFile("missing.json")
+	assert.ErrorIs(t, err, types.ErrNotFound)
+
+	_, err = s.GetByFqdn("missing.example.com")
+	assert.ErrorIs(t, err, types.ErrNotFound)
+}
+
+func TestStorage_WithContext(t *testing.T) {
+	backend := &recordingStorage{}
+	s, err := New(backend, testKey())
+	require.NoError(t, err)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	bound := types.WithContext(ctx, s)
+	require.IsType(t, &Storage{}, bound)
+
+	inner := bound.(*Storage).Storage.(*recordingStorage)
+	assert.Equal(t, ctx, inner.ctx)
+	assert.Nil(t, backend.ctx)
+}
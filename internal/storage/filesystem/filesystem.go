@@ -34,11 +34,13 @@ package filesystem
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -69,11 +71,15 @@ func New(ctx context.Context, opts ...types.Option) (types.Storage, error) {
 
 // Storage implements the types.Storage interface using filesystem for persistence.
 // Keys are stored as signed JSON files in the dump directory, with atomic writes
-// using temporary files and rename operations to ensure consistency.
+// using temporary files and rename operations to ensure consistency. The signed files carry the
+// v1 payload, which omits fields added since, so the keys of every file are stored in full in a
+// hidden file next to it as well (see keysFile).
 type Storage struct {
-	appID   string
-	dumpDir string
-	signer  *signer.Signer
+	appID     string
+	dumpDir   string
+	signer    *signer.Signer
+	maxAge    time.Duration
+	staleness *types.Staleness
 	// dumpInterval time.Duration
 }
 
@@ -122,16 +128,37 @@ func (s *Storage) WithMaxOpenConns(n int) {
 	// no-op for this storage
 }
 
+// WithMaxAge sets the maximum age of keys considered fresh by the health probes.
+func (s *Storage) WithMaxAge(d time.Duration) {
+	s.maxAge = d
+}
+
+// WithStaleness sets how the health probes measure the age of keys (see types.Staleness).
+func (s *Storage) WithStaleness(st *types.Staleness) {
+	s.staleness = st
+}
+
+// probeMaxAge returns the configured maximum key age or types.DefaultMaxAge.
+func (s *Storage) probeMaxAge() time.Duration {
+	if s.maxAge <= 0 {
+		return types.DefaultMaxAge
+	}
+
+	return s.maxAge
+}
+
 // SaveKeys persists domain keys to filesystem as signed JSON files.
 // Keys are grouped by file name, signed using the configured signer,
-// and written atomically to prevent corruption. Keys with empty Key field are skipped.
+// and written atomically to prevent corruption. Keys with empty Key field are skipped and
+// reported as types.ErrPartialSave if all files were written.
 func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
 	errs := make([]error, 0)
+	skipped := make([]error, 0)
 
 	files := make(map[string][]types.DomainKey)
 	for _, key := range keys {
 		if key.Key == "" {
-			errs = append(errs, fmt.Errorf("empty key for fqdn=%q domain=%q file=%q",
+			skipped = append(skipped, fmt.Errorf("empty key for fqdn=%q domain=%q file=%q",
 				key.Fqdn, key.DomainName, key.File))
 			continue
 		}
@@ -151,6 +178,12 @@ func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
 			continue
 		}
 
+		if err := s.saveKeysFile(file, keys); err != nil {
+			slog.Error("failed to save keys file", "file", file, "error", err)
+			errs = append(errs, fmt.Errorf("failed to save keys file %s: %w", file, err))
+			continue
+		}
+
 		if err := s.saveFile(file, data); err != nil {
 			slog.Error("failed to save file", "file", file, "error", err)
 			errs = append(errs, fmt.Errorf("failed to save file %s: %w", file, err))
@@ -159,24 +192,244 @@ func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("failed to save some files: %v", errs)
+		return fmt.Errorf("failed to save some files: %v", append(errs, skipped...))
+	}
+
+	if len(skipped) > 0 {
+		return fmt.Errorf("%w: %v", types.ErrPartialSave, skipped)
 	}
 
 	return nil
 }
 
 // GetByFile reads and returns the contents of a JSON file from the dump directory.
-// Returns the raw file data suitable for HTTP response.
-// Returns an error if the file doesn't exist or cannot be read.
+// Returns the raw file data suitable for HTTP response together with the keys of the file
+// (see readKeys).
+// Returns an error if the file name is invalid, the file doesn't exist or cannot be read.
 func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
+	if err := types.ValidateFile(file); err != nil {
+		return nil, nil, err
+	}
+
 	f := fmt.Sprintf("%s/%s", s.dumpDir, file)
 
-	if data, err := os.ReadFile(f); err == nil {
-		return nil, data, nil
-	} else {
+	data, err := os.ReadFile(f)
+	if err != nil {
 		slog.Error("GetByFile: read file", "file", file, "error", err)
 		return nil, nil, fmt.Errorf("file %s not found", file)
 	}
+
+	keys, err := s.readKeys(file, data)
+	if err != nil {
+		slog.Error("GetByFile: read keys", "file", file, "error", err)
+		return nil, data, nil
+	}
+
+	return keys, data, nil
+}
+
+// GetByFqdn reads every JSON file in the dump directory and returns the keys of a FQDN.
+// The File field of returned keys is set to the name of the file the key was found in.
+// Returns types.ErrNotFound if the FQDN is not published in any file.
+func (s *Storage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	files, err := s.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]types.DomainKey, 0)
+
+	for _, file := range files {
+		raw, err := os.ReadFile(filepath.Join(s.dumpDir, file))
+		if err != nil {
+			slog.Error("GetByFqdn: read file", "file", file, "error", err)
+			continue
+		}
+
+		fileKeys, err := s.readKeys(file, raw)
+		if err != nil {
+			slog.Error("GetByFqdn: parse file", "file", file, "error", err)
+			continue
+		}
+
+		for _, k := range fileKeys {
+			if k.Fqdn != fqdn || k.Key == "" {
+				continue
+			}
+
+			k.File = file
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key for fqdn=%q: %w", fqdn, types.ErrNotFound)
+	}
+
+	return keys, nil
+}
+
+// ListFiles returns the sorted names of all files in the dump directory.
+// Directories and hidden files (including temporary files of atomic writes) are skipped.
+func (s *Storage) ListFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.dumpDir)
+	if err != nil {
+		slog.Error("ListFiles: read dump dir", "dumpDir", s.dumpDir, "error", err)
+		return nil, fmt.Errorf("failed to read dump dir")
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		files = append(files, e.Name())
+	}
+
+	return files, nil
+}
+
+// DeleteKeys removes the key of a FQDN from a signed JSON file.
+// The remaining keys are re-signed and written atomically; the file is removed
+// when no keys are left. Returns types.ErrNotFound if the file or FQDN does not exist
+// and types.ErrInvalidFile if the file name is not a plain file name.
+func (s *Storage) DeleteKeys(file, fqdn string) error {
+	if err := types.ValidateFile(file); err != nil {
+		return err
+	}
+
+	f := fmt.Sprintf("%s/%s", s.dumpDir, file)
+
+	raw, err := os.ReadFile(f)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("file %s: %w", file, types.ErrNotFound)
+		}
+
+		return fmt.Errorf("DeleteKeys: read file: %w", err)
+	}
+
+	stored, err := s.readKeys(file, raw)
+	if err != nil {
+		return fmt.Errorf("DeleteKeys: file %s: %w", file, err)
+	}
+
+	keys := make([]types.DomainKey, 0, len(stored))
+	for _, k := range stored {
+		if k.Fqdn == fqdn {
+			continue
+		}
+
+		keys = append(keys, k)
+	}
+
+	if len(keys) == len(stored) {
+		return fmt.Errorf("key for fqdn=%q file=%q: %w", fqdn, file, types.ErrNotFound)
+	}
+
+	if len(keys) == 0 {
+		if err := os.Remove(f); err != nil {
+			return fmt.Errorf("DeleteKeys: remove file: %w", err)
+		}
+
+		if err := os.Remove(filepath.Join(s.dumpDir, keysFile(file))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("DeleteKeys: remove keys file: %w", err)
+		}
+
+		return nil
+	}
+
+	out, err := types.SignedKeys(file, keys, s.signer)
+	if err != nil {
+		return fmt.Errorf("DeleteKeys: failed signing keys for file %s: %w", file, err)
+	}
+
+	if err := s.saveKeysFile(file, keys); err != nil {
+		return fmt.Errorf("DeleteKeys: %w", err)
+	}
+
+	return s.saveFile(file, out)
+}
+
+// keysFile returns the name of the hidden file the keys of file are stored in with all their
+// published fields, e.g. pins and error_category, which the v1 payload of the signed file omits.
+// Being hidden, it is neither listed nor checked by the probes.
+func keysFile(file string) string {
+	return "." + file + ".keys.json"
+}
+
+// saveKeysFile writes the keys of file to its keys file atomically (see saveFile).
+func (s *Storage) saveKeysFile(file string, keys []types.DomainKey) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to marshal keys: %w", err)
+	}
+
+	return s.saveFile(keysFile(file), data)
+}
+
+// readKeys returns the keys of file from its keys file, or from the payload of the signed file
+// data if the keys file is missing or does not match it, e.g. for files written by earlier versions.
+func (s *Storage) readKeys(file string, data []byte) ([]types.DomainKey, error) {
+	structure, err := types.ParseFileStructure(data)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := os.ReadFile(filepath.Join(s.dumpDir, keysFile(file)))
+	if errors.Is(err, os.ErrNotExist) {
+		return structure.Payload.Keys, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read keys file: %w", err)
+	}
+
+	var keys []types.DomainKey
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return nil, fmt.Errorf("parse keys file: %w", err)
+	}
+
+	if !slices.EqualFunc(keys, structure.Payload.Keys, sameKey) {
+		slog.Warn("keys file does not match the signed file", "file", file)
+		return structure.Payload.Keys, nil
+	}
+
+	return keys, nil
+}
+
+// sameKey reports whether a and b are the same key of a domain fetched at the same time.
+func sameKey(a, b types.DomainKey) bool {
+	if a.Fqdn != b.Fqdn || a.Key != b.Key || (a.Date == nil) != (b.Date == nil) {
+		return false
+	}
+
+	return a.Date == nil || a.Date.Equal(*b.Date)
+}
+
+// catalogFile is the file of the pin catalogue in the dump directory. It is hidden so it is
+// neither listed nor checked by the probes.
+const catalogFile = ".catalog.json"
+
+// GetCatalog reads the pin catalogue from the dump directory.
+// Returns types.ErrNotFound if no catalogue was saved.
+func (s *Storage) GetCatalog() ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dumpDir, catalogFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("catalog: %w", types.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("GetCatalog: read file: %w", err)
+	}
+
+	return data, nil
+}
+
+// SaveCatalog writes the pin catalogue to the dump directory atomically (see saveFile).
+func (s *Storage) SaveCatalog(data []byte) error {
+	return s.saveFile(catalogFile, data)
 }
 
 // Close is a no-op for filesystem storage as there are no connections to close.
@@ -228,12 +481,12 @@ func (s *Storage) saveFile(file string, data []byte) error {
 //   - At least one JSON file exists
 //   - Files can be parsed as valid JSON
 //   - Keys contain valid data and no errors
-//   - At least one key has been updated within maxAge (10 seconds)
+//   - At least one key has been updated within maxAge (see WithMaxAge)
 //
 // Returns 503 Service Unavailable if any check fails, 200 OK if all checks pass.
 func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const maxAge = 10 * time.Second
+		maxAge := s.probeMaxAge()
 
 		now := time.Now()
 		errs := make([]string, 0)
@@ -274,7 +527,7 @@ func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 		}
 
 		for _, e := range entries {
-			if e.IsDir() {
+			if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
 				continue
 			}
 
@@ -316,7 +569,7 @@ func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 					continue
 				}
 
-				age := now.Sub(*k.Date)
+				age := s.staleness.Age(*k.Date, now)
 				if age >= maxAge {
 					errs = append(errs,
 						fmt.Sprintf("key for %s (%s) appears stale (age=%s >= %s)",
@@ -338,12 +591,12 @@ func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 // It checks that:
 //   - Dump directory is readable
 //   - At least one file exists
-//   - At least one file has been modified within maxAge (10 seconds)
+//   - At least one file has been modified within maxAge (see WithMaxAge)
 //
 // Returns 503 Service Unavailable if any check fails, 200 OK if all checks pass.
 func (s *Storage) ProbeReadiness() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const maxAge = 10 * time.Second
+		maxAge := s.probeMaxAge()
 
 		now := time.Now()
 		errs := make([]string, 0)
@@ -381,6 +634,10 @@ func (s *Storage) ProbeReadiness() func(w http.ResponseWriter, r *http.Request)
 		}
 
 		for _, e := range entries {
+			if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+
 			info, err := e.Info()
 			if err != nil {
 				errs = append(errs,
@@ -388,7 +645,7 @@ func (s *Storage) ProbeReadiness() func(w http.ResponseWriter, r *http.Request)
 				continue
 			}
 
-			if now.Sub(info.ModTime()) >= maxAge {
+			if s.staleness.Age(info.ModTime(), now) >= maxAge {
 				errs = append(errs,
 					fmt.Sprintf("no dump files newer than %s", maxAge))
 			}
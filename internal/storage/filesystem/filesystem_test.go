@@ -306,6 +306,7 @@ func TestStorage_SaveKeys(t *testing.T) {
 				if tt.wantErrMsg != "" {
 					assert.Contains(t, err.Error(), tt.wantErrMsg)
 				}
+				assert.ErrorIs(t, err, types.ErrPartialSave)
 			} else {
 				assert.NoError(t, err)
 				if tt.validate != nil {
@@ -370,7 +371,7 @@ func TestStorage_GetByFile(t *testing.T) {
 				assert.Nil(t, data)
 			} else {
 				assert.NoError(t, err)
-				assert.Nil(t, keys) // filesystem always returns nil for keys
+				assert.Nil(t, keys) // no keys without a signed payload
 				assert.NotNil(t, data)
 				if tt.validate != nil {
 					tt.validate(t, data)
@@ -565,6 +566,27 @@ func TestStorage_ProbeReadiness(t *testing.T) {
 			wantStatusCode:   http.StatusServiceUnavailable,
 			wantBodyContains: "no dump files found",
 		},
+		{
+			name: "not ready with files older than default max age",
+			setup: func(t *testing.T, dumpDir string, s *Storage) {
+				path := filepath.Join(dumpDir, "test.json")
+				require.NoError(t, os.WriteFile(path, []byte("{}"), 0o644))
+				require.NoError(t, os.Chtimes(path, now.Add(-20*time.Second), now.Add(-20*time.Second)))
+			},
+			wantStatusCode:   http.StatusServiceUnavailable,
+			wantBodyContains: "no dump files newer than 10s",
+		},
+		{
+			name: "ready with files within configured max age",
+			setup: func(t *testing.T, dumpDir string, s *Storage) {
+				path := filepath.Join(dumpDir, "test.json")
+				require.NoError(t, os.WriteFile(path, []byte("{}"), 0o644))
+				require.NoError(t, os.Chtimes(path, now.Add(-20*time.Second), now.Add(-20*time.Second)))
+
+				s.WithMaxAge(time.Minute)
+			},
+			wantStatusCode: http.StatusOK,
+		},
 	}
 
 	for _, tt := range tests {
@@ -658,3 +680,305 @@ func createTestSigner(t *testing.T) *signer.Signer {
 
 	return s
 }
+
+func TestStorage_DeleteKeys(t *testing.T) {
+	testSigner := createTestSigner(t)
+
+	now := time.Now()
+	expire := now.Add(24 * time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		file     string
+		fqdn     string
+		wantErr  error
+		validate func(t *testing.T, s types.Storage, dumpDir string)
+	}{
+		{
+			name: "removes key and re-signs file",
+			file: "test.json",
+			fqdn: "www.example.com",
+			validate: func(t *testing.T, s types.Storage, dumpDir string) {
+				_, data, err := s.GetByFile("test.json")
+				require.NoError(t, err)
+
+				var structure types.FileStructure
+				require.NoError(t, json.Unmarshal(data, &structure))
+				require.Len(t, structure.Payload.Keys, 1)
+				assert.Equal(t, "www.test.com", structure.Payload.Keys[0].Fqdn)
+				assert.NotEmpty(t, structure.Signature)
+			},
+		},
+		{
+			name: "removes file with last key",
+			file: "single.json",
+			fqdn: "www.single.com",
+			validate: func(t *testing.T, s types.Storage, dumpDir string) {
+				_, err := os.Stat(filepath.Join(dumpDir, "single.json"))
+				assert.ErrorIs(t, err, os.ErrNotExist)
+			},
+		},
+		{
+			name:    "unknown fqdn",
+			file:    "test.json",
+			fqdn:    "www.unknown.com",
+			wantErr: types.ErrNotFound,
+		},
+		{
+			name:    "unknown file",
+			file:    "missing.json",
+			fqdn:    "www.example.com",
+			wantErr: types.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dumpDir := t.TempDir()
+
+			s, err := New(context.Background(),
+				types.WithDumpDir(dumpDir),
+				types.WithSigner(testSigner),
+			)
+			require.NoError(t, err)
+
+			require.NoError(t, s.SaveKeys(map[string]types.DomainKey{
+				"www.example.com": {Date: &now, Expire: expire, File: "test.json", Fqdn: "www.example.com", Key: "key1"},
+				"www.test.com":    {Date: &now, Expire: expire, File: "test.json", Fqdn: "www.test.com", Key: "key2"},
+				"www.single.com":  {Date: &now, Expire: expire, File: "single.json", Fqdn: "www.single.com", Key: "key3"},
+			}))
+
+			err = s.DeleteKeys(tt.file, tt.fqdn)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			if tt.validate != nil {
+				tt.validate(t, s, dumpDir)
+			}
+		})
+	}
+}
+
+func TestStorage_KeysFile(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	testSigner := createTestSigner(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	expire := now.Add(24 * time.Hour).Unix()
+
+	dumpDir := t.TempDir()
+
+	s, err := New(context.Background(),
+		types.WithDumpDir(dumpDir),
+		types.WithSigner(testSigner),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveKeys(map[string]types.DomainKey{
+		"www.example.com": {Date: &now, Expire: expire, File: "test.json", Fqdn: "www.example.com", Key: "key1", Pins: []string{"key1", "ca1", "backup1"}},
+		"www.test.com":    {Date: &now, Expire: expire + 1, File: "test.json", Fqdn: "www.test.com", Key: "key2", Pins: []string{"key2", "backup2"}},
+	}))
+
+	files, err := s.ListFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test.json"}, files)
+
+	t.Run("signed file keeps the v1 payload", func(t *testing.T) {
+		_, data, err := s.GetByFile("test.json")
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), `"pins"`)
+	})
+
+	t.Run("GetByFile", func(t *testing.T) {
+		keys, _, err := s.GetByFile("test.json")
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, []string{"key1", "ca1", "backup1"}, keys[0].Pins)
+		assert.Equal(t, []string{"key2", "backup2"}, keys[1].Pins)
+	})
+
+	t.Run("GetByFqdn", func(t *testing.T) {
+		keys, err := s.GetByFqdn("www.test.com")
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, []string{"key2", "backup2"}, keys[0].Pins)
+	})
+
+	t.Run("DeleteKeys", func(t *testing.T) {
+		require.NoError(t, s.DeleteKeys("test.json", "www.example.com"))
+
+		keys, _, err := s.GetByFile("test.json")
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, []string{"key2", "backup2"}, keys[0].Pins)
+	})
+
+	t.Run("keys file not matching the signed file", func(t *testing.T) {
+		data, err := types.SignedKeys("test.json", []types.DomainKey{
+			{Date: &now, Expire: expire, Fqdn: "www.test.com", Key: "key3"},
+		}, testSigner)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dumpDir, "test.json"), data, 0600))
+
+		keys, _, err := s.GetByFile("test.json")
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "key3", keys[0].Key)
+		assert.Empty(t, keys[0].Pins)
+	})
+
+	t.Run("removed with the last key", func(t *testing.T) {
+		require.NoError(t, s.DeleteKeys("test.json", "www.test.com"))
+
+		_, err := os.Stat(filepath.Join(dumpDir, keysFile("test.json")))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestStorage_KeysFile_ErrorCategory(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	s, err := New(context.Background(),
+		types.WithDumpDir(t.TempDir()),
+		types.WithSigner(createTestSigner(t)),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveKeys(map[string]types.DomainKey{
+		"www.example.com": {Date: &now, Expire: 3600, File: "test.json", Fqdn: "www.example.com", Key: "key1", LastError: "i/o timeout", ErrorCategory: "timeout"},
+	}))
+
+	keys, data, err := s.GetByFile("test.json")
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"error_category"`)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "i/o timeout", keys[0].LastError)
+	assert.Equal(t, "timeout", keys[0].ErrorCategory)
+
+	keys, err = s.GetByFqdn("www.example.com")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "timeout", keys[0].ErrorCategory)
+}
+
+func TestStorage_ListFiles(t *testing.T) {
+	dumpDir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dumpDir, "b.json"), []byte("{}"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dumpDir, "a.json"), []byte("{}"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dumpDir, ".a.json.tmp-123"), []byte("{}"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dumpDir, "dir"), 0700))
+
+	s := &Storage{dumpDir: dumpDir}
+
+	files, err := s.ListFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.json", "b.json"}, files)
+
+	_, err = (&Storage{dumpDir: filepath.Join(dumpDir, "missing")}).ListFiles()
+	assert.Error(t, err)
+}
+
+func TestStorage_GetByFqdn(t *testing.T) {
+	testSigner := createTestSigner(t)
+
+	now := time.Now()
+	expire := now.Add(24 * time.Hour).Unix()
+
+	dumpDir := t.TempDir()
+
+	s, err := New(context.Background(),
+		types.WithDumpDir(dumpDir),
+		types.WithSigner(testSigner),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, s.SaveKeys(map[string]types.DomainKey{
+		"www.example.com": {Date: &now, Expire: expire, File: "b.json", Fqdn: "www.example.com", Key: "key1"},
+		"www.test.com":    {Date: &now, Expire: expire, File: "b.json", Fqdn: "www.test.com", Key: "key2"},
+	}))
+	require.NoError(t, s.SaveKeys(map[string]types.DomainKey{
+		"www.example.com": {Date: &now, Expire: expire, File: "a.json", Fqdn: "www.example.com", Key: "key3"},
+	}))
+	require.NoError(t, os.WriteFile(filepath.Join(dumpDir, "broken.json"), []byte("{"), 0600))
+
+	keys, err := s.GetByFqdn("www.example.com")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "a.json", keys[0].File)
+	assert.Equal(t, "key3", keys[0].Key)
+	assert.Equal(t, "b.json", keys[1].File)
+	assert.Equal(t, "key1", keys[1].Key)
+	assert.Equal(t, expire, keys[1].Expire)
+
+	_, err = s.GetByFqdn("www.unknown.com")
+	assert.ErrorIs(t, err, types.ErrNotFound)
+}
+
+func TestStorage_GetByFile_InvalidFile(t *testing.T) {
+	root := t.TempDir()
+	dumpDir := filepath.Join(root, "dump")
+	require.NoError(t, os.Mkdir(dumpDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret.json"), []byte("secret"), 0600))
+
+	s := &Storage{dumpDir: dumpDir}
+
+	for _, file := range []string{"../secret.json", "..", ".", "sub/../../secret.json", ""} {
+		_, data, err := s.GetByFile(file)
+		assert.ErrorIs(t, err, types.ErrInvalidFile, file)
+		assert.Nil(t, data)
+	}
+
+	assert.ErrorIs(t, s.DeleteKeys("../secret.json", "example.com"), types.ErrInvalidFile)
+	_, err := os.Stat(filepath.Join(root, "secret.json"))
+	assert.NoError(t, err)
+}
+
+func FuzzStorage_GetByFile(f *testing.F) {
+	for _, seed := range []string{"test.json", "../secret.json", "..", "./test.json", "dump/../../secret.json"} {
+		f.Add(seed)
+	}
+
+	root := f.TempDir()
+	dumpDir := filepath.Join(root, "dump")
+	require.NoError(f, os.Mkdir(dumpDir, 0700))
+	require.NoError(f, os.WriteFile(filepath.Join(root, "secret.json"), []byte("secret"), 0600))
+	require.NoError(f, os.WriteFile(filepath.Join(dumpDir, "test.json"), []byte("{}"), 0600))
+
+	s := &Storage{dumpDir: dumpDir}
+
+	f.Fuzz(func(t *testing.T, file string) {
+		_, data, _ := s.GetByFile(file)
+
+		if string(data) == "secret" {
+			t.Fatalf("GetByFile(%q) read a file outside of the dump directory", file)
+		}
+	})
+}
+
+func TestStorage_Catalog(t *testing.T) {
+	dumpDir := t.TempDir()
+
+	s := &Storage{dumpDir: dumpDir}
+
+	_, err := s.GetCatalog()
+	assert.ErrorIs(t, err, types.ErrNotFound)
+
+	require.NoError(t, s.SaveCatalog([]byte(`{"files": {}}`)))
+
+	data, err := s.GetCatalog()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"files": {}}`, string(data))
+
+	files, err := s.ListFiles()
+	require.NoError(t, err)
+	assert.Empty(t, files, "the catalog is not a published file")
+}
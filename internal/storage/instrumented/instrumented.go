@@ -0,0 +1,158 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+// New wraps a storage backend with a decorator that records the duration and outcome
+// of every data operation and the number of keys written into the Prometheus collector,
+// labeled with the backend type.
+// Health probes and configuration setters are passed through unchanged.
+func New(s types.Storage, backend types.StorageType, collector *metrics.Collector) types.Storage {
+	return &Storage{
+		Storage:   s,
+		backend:   string(backend),
+		collector: collector,
+	}
+}
+
+// Storage implements the types.Storage interface by delegating to the wrapped backend
+// and recording metrics for SaveKeys, GetByFile, GetByFqdn, ListFiles and DeleteKeys.
+type Storage struct {
+	types.Storage
+	backend   string
+	collector *metrics.Collector
+}
+
+// WithContext returns a copy of the storage with ctx bound to the wrapped backend.
+func (s *Storage) WithContext(ctx context.Context) types.Storage {
+	c := *s
+	c.Storage = types.WithContext(ctx, s.Storage)
+
+	return &c
+}
+
+// SaveKeys persists domain keys using the wrapped backend and records the "save" operation
+// and, if it succeeded, the number of keys written.
+func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
+	start := time.Now()
+	err := s.Storage.SaveKeys(keys)
+	s.observe("save", start, err)
+
+	if err == nil && s.collector != nil {
+		s.collector.AddStorageKeysWritten(s.backend, len(keys))
+	}
+
+	return err
+}
+
+// GetByFile retrieves domain keys by filename using the wrapped backend and records the "get_by_file" operation.
+func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
+	start := time.Now()
+	keys, data, err := s.Storage.GetByFile(file)
+	s.observe("get_by_file", start, err)
+
+	return keys, data, err
+}
+
+// GetByFqdn retrieves the keys of a FQDN using the wrapped backend and records the "get_by_fqdn" operation.
+// A types.ErrNotFound result is not counted as an error.
+func (s *Storage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	start := time.Now()
+	keys, err := s.Storage.GetByFqdn(fqdn)
+	s.observe("get_by_fqdn", start, ignoreNotFound(err))
+
+	return keys, err
+}
+
+// ListFiles returns the published files using the wrapped backend and records the "list_files" operation.
+func (s *Storage) ListFiles() ([]string, error) {
+	start := time.Now()
+	files, err := s.Storage.ListFiles()
+	s.observe("list_files", start, err)
+
+	return files, err
+}
+
+// DeleteKeys removes the keys of a FQDN using the wrapped backend and records the "delete" operation.
+// A types.ErrNotFound result is not counted as an error.
+func (s *Storage) DeleteKeys(file, fqdn string) error {
+	start := time.Now()
+	err := s.Storage.DeleteKeys(file, fqdn)
+	s.observe("delete", start, ignoreNotFound(err))
+
+	return err
+}
+
+// GetCatalog retrieves the pin catalogue using the wrapped backend and records the "get_catalog" operation.
+// A types.ErrNotFound result is not counted as an error.
+func (s *Storage) GetCatalog() ([]byte, error) {
+	start := time.Now()
+	data, err := s.Storage.GetCatalog()
+	s.observe("get_catalog", start, ignoreNotFound(err))
+
+	return data, err
+}
+
+// SaveCatalog persists the pin catalogue using the wrapped backend and records the "save_catalog" operation.
+func (s *Storage) SaveCatalog(data []byte) error {
+	start := time.Now()
+	err := s.Storage.SaveCatalog(data)
+	s.observe("save_catalog", start, err)
+
+	return err
+}
+
+// observe records an operation started at start into the collector.
+func (s *Storage) observe(operation string, start time.Time, err error) {
+	if s.collector == nil {
+		return
+	}
+
+	s.collector.ObserveStorage(s.backend, operation, time.Since(start), err)
+}
+
+// ignoreNotFound returns nil for types.ErrNotFound as a missing key is a valid lookup result.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, types.ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
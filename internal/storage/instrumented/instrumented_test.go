@@ -0,0 +1,221 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ssl-pinning/internal/metrics"
+	"ssl-pinning/internal/storage/types"
+)
+
+// stubStorage returns the configured error from every data operation.
+type stubStorage struct {
+	types.Storage
+	err   error
+	calls int
+}
+
+func (s *stubStorage) SaveKeys(map[string]types.DomainKey) error { s.calls++; return s.err }
+func (s *stubStorage) GetByFile(string) ([]types.DomainKey, []byte, error) {
+	s.calls++
+	return nil, nil, s.err
+}
+func (s *stubStorage) GetByFqdn(string) ([]types.DomainKey, error) { s.calls++; return nil, s.err }
+func (s *stubStorage) ListFiles() ([]string, error)                { s.calls++; return nil, s.err }
+func (s *stubStorage) DeleteKeys(string, string) error             { s.calls++; return s.err }
+
+// collect returns the sample count and error count per operation gathered by the collector.
+func collect(t *testing.T, c *metrics.Collector) (map[string]uint64, map[string]float64) {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	counts := make(map[string]uint64)
+	errs := make(map[string]float64)
+
+	for m := range ch {
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+
+		labels := make(map[string]string)
+		for _, l := range metric.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+
+		if _, ok := labels["operation"]; !ok || labels["backend"] != "postgres" {
+			continue
+		}
+
+		if metric.Histogram != nil {
+			counts[labels["operation"]] = metric.GetHistogram().GetSampleCount()
+		}
+		if metric.Counter != nil {
+			errs[labels["operation"]] = metric.GetCounter().GetValue()
+		}
+	}
+
+	return counts, errs
+}
+
+func TestStorage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantErrs map[string]float64
+	}{
+		{
+			name:     "success",
+			wantErrs: map[string]float64{"save": 0, "get_by_file": 0, "get_by_fqdn": 0, "list_files": 0, "delete": 0},
+		},
+		{
+			name:     "failure",
+			err:      errors.New("boom"),
+			wantErrs: map[string]float64{"save": 1, "get_by_file": 1, "get_by_fqdn": 1, "list_files": 1, "delete": 1},
+		},
+		{
+			name:     "not found is not an error",
+			err:      types.ErrNotFound,
+			wantErrs: map[string]float64{"save": 1, "get_by_file": 1, "get_by_fqdn": 0, "list_files": 1, "delete": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubStorage{err: tt.err}
+			c := new(metrics.Collector)
+
+			s := New(stub, types.StoragePostgres, c)
+
+			assert.ErrorIs(t, s.SaveKeys(nil), tt.err)
+			_, _, err := s.GetByFile("test.json")
+			assert.ErrorIs(t, err, tt.err)
+			_, err = s.GetByFqdn("example.com")
+			assert.ErrorIs(t, err, tt.err)
+			_, err = s.ListFiles()
+			assert.ErrorIs(t, err, tt.err)
+			assert.ErrorIs(t, s.DeleteKeys("test.json", "example.com"), tt.err)
+
+			assert.Equal(t, 5, stub.calls)
+
+			counts, errs := collect(t, c)
+			for op := range tt.wantErrs {
+				assert.Equal(t, uint64(1), counts[op], op)
+			}
+			assert.Equal(t, tt.wantErrs, errs)
+		})
+	}
+}
+
+func TestStorage_KeysWritten(t *testing.T) {
+	stub := &stubStorage{}
+	c := new(metrics.Collector)
+
+	s := New(stub, types.StorageRedis, c)
+
+	keys := map[string]types.DomainKey{
+		"example.com": {Fqdn: "example.com"},
+		"example.org": {Fqdn: "example.org"},
+	}
+
+	require.NoError(t, s.SaveKeys(keys))
+	require.NoError(t, s.SaveKeys(keys))
+
+	stub.err = errors.New("boom")
+	require.Error(t, s.SaveKeys(keys))
+
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var written float64
+	for m := range ch {
+		if !strings.Contains(m.Desc().String(), `"ssl_pinning_storage_keys_written_total"`) {
+			continue
+		}
+
+		var metric dto.Metric
+		require.NoError(t, m.Write(&metric))
+		assert.Equal(t, "redis", metric.GetLabel()[0].GetValue())
+		written = metric.GetCounter().GetValue()
+	}
+
+	assert.Equal(t, 4.0, written)
+}
+
+func TestStorage_NilCollector(t *testing.T) {
+	stub := &stubStorage{}
+
+	s := New(stub, types.StorageMemory, nil)
+
+	assert.NoError(t, s.SaveKeys(nil))
+	assert.Equal(t, 1, stub.calls)
+}
+
+// contextStub records the context it was bound to.
+type contextStub struct {
+	stubStorage
+	ctx context.Context
+}
+
+func (s *contextStub) WithContext(ctx context.Context) types.Storage {
+	return &contextStub{ctx: ctx}
+}
+
+func TestStorage_WithContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "request")
+
+	s := New(&contextStub{}, types.StorageRedis, new(metrics.Collector))
+
+	bound, ok := types.WithContext(ctx, s).(*Storage)
+	require.True(t, ok)
+	assert.NotSame(t, s, bound)
+
+	inner, ok := bound.Storage.(*contextStub)
+	require.True(t, ok)
+	assert.Equal(t, "request", inner.ctx.Value(key{}))
+}
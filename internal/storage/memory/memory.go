@@ -32,11 +32,14 @@ POSSIBILITY OF SUCH DAMAGE.
 package memory
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"ssl-pinning/internal/signer"
@@ -64,9 +67,13 @@ func New(ctx context.Context, opts ...types.Option) (types.Storage, error) {
 // All data is stored in RAM and is lost when the application restarts.
 // Keys are indexed by FQDN for fast lookup.
 type Storage struct {
-	appID  string
-	keys   map[string]types.DomainKey
-	signer *signer.Signer
+	mu        sync.RWMutex
+	appID     string
+	catalog   []byte
+	keys      map[string]types.DomainKey
+	signer    *signer.Signer
+	maxAge    time.Duration
+	staleness *types.Staleness
 	// dumpInterval time.Duration
 }
 
@@ -115,8 +122,28 @@ func (s *Storage) WithMaxOpenConns(n int) {
 	// no-op for this storage
 }
 
+// WithMaxAge sets the maximum age of keys considered fresh by the health probes.
+func (s *Storage) WithMaxAge(d time.Duration) {
+	s.maxAge = d
+}
+
+// WithStaleness sets how the health probes measure the age of keys (see types.Staleness).
+func (s *Storage) WithStaleness(st *types.Staleness) {
+	s.staleness = st
+}
+
+// probeMaxAge returns the configured maximum key age or types.DefaultMaxAge.
+func (s *Storage) probeMaxAge() time.Duration {
+	if s.maxAge <= 0 {
+		return types.DefaultMaxAge
+	}
+
+	return s.maxAge
+}
+
 // SaveKeys stores domain keys in memory, indexed by FQDN.
-// Keys with empty Key field are skipped. This operation replaces all existing keys.
+// Keys with empty Key field are skipped and reported as types.ErrPartialSave. This operation
+// replaces all existing keys.
 func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
 	errs := make([]error, 0)
 
@@ -130,10 +157,13 @@ func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
 
 		list[key.Fqdn] = key
 	}
+
+	s.mu.Lock()
 	s.keys = list
+	s.mu.Unlock()
 
 	if len(errs) > 0 {
-		return fmt.Errorf("failed to save some keys: %v", errs)
+		return fmt.Errorf("%w: %v", types.ErrPartialSave, errs)
 	}
 
 	return nil
@@ -143,6 +173,9 @@ func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
 // The File field is cleared in returned keys to avoid redundancy.
 // Returns empty slice if no matching keys are found.
 func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	keys := []types.DomainKey{}
 
 	for _, key := range s.keys {
@@ -160,6 +193,85 @@ func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
 	return keys, nil, nil
 }
 
+// GetByFqdn retrieves the domain key of a FQDN from memory.
+// Returns types.ErrNotFound if no non-empty key is stored for the FQDN.
+func (s *Storage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[fqdn]
+	if !ok || key.Key == "" {
+		return nil, fmt.Errorf("key for fqdn=%q: %w", fqdn, types.ErrNotFound)
+	}
+
+	return []types.DomainKey{key}, nil
+}
+
+// ListFiles returns the sorted names of all files that have at least one non-empty key in memory.
+func (s *Storage) ListFiles() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]struct{})
+	files := make([]string, 0)
+
+	for _, key := range s.keys {
+		if key.Key == "" {
+			continue
+		}
+
+		if _, ok := seen[key.File]; ok {
+			continue
+		}
+
+		seen[key.File] = struct{}{}
+		files = append(files, key.File)
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// DeleteKeys removes the key of a FQDN from memory if it belongs to the given file.
+// Returns types.ErrNotFound if no such key is stored.
+func (s *Storage) DeleteKeys(file, fqdn string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[fqdn]
+	if !ok || key.File != file {
+		return fmt.Errorf("key for fqdn=%q file=%q: %w", fqdn, file, types.ErrNotFound)
+	}
+
+	delete(s.keys, fqdn)
+
+	return nil
+}
+
+// GetCatalog returns the pin catalogue stored in memory.
+// Returns types.ErrNotFound if no catalogue was saved.
+func (s *Storage) GetCatalog() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.catalog == nil {
+		return nil, fmt.Errorf("catalog: %w", types.ErrNotFound)
+	}
+
+	return bytes.Clone(s.catalog), nil
+}
+
+// SaveCatalog stores the pin catalogue in memory, replacing the previous one.
+func (s *Storage) SaveCatalog(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.catalog = bytes.Clone(data)
+
+	return nil
+}
+
 // Close is a no-op for in-memory storage as there are no resources to release.
 func (s *Storage) Close() error {
 	return nil
@@ -168,13 +280,13 @@ func (s *Storage) Close() error {
 // ProbeLiveness returns an HTTP handler for Kubernetes liveness probe.
 // It checks that:
 //   - Keys exist in memory
-//   - At least one key has been updated within maxAge (10 seconds)
+//   - At least one key has been updated within maxAge (see WithMaxAge)
 //   - Keys contain required fields (key, date) and have no errors
 //
 // Returns 503 Service Unavailable if any check fails, 200 OK if all checks pass.
 func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const maxAge = 10 * time.Second
+		maxAge := s.probeMaxAge()
 
 		now := time.Now()
 		errs := make([]string, 0)
@@ -200,6 +312,9 @@ func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 		}()
 
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
 		if len(s.keys) == 0 {
 			errs = append(errs, "no keys in memory")
 			return
@@ -221,7 +336,7 @@ func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			age := now.Sub(*k.Date)
+			age := s.staleness.Age(*k.Date, now)
 			if age >= maxAge {
 				errs = append(errs,
 					fmt.Sprintf("key for %s (%s) appears stale (age=%s >= %s)",
@@ -270,6 +385,9 @@ func (s *Storage) ProbeReadiness() func(w http.ResponseWriter, r *http.Request)
 			w.WriteHeader(http.StatusOK)
 		}()
 
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
 		if len(s.keys) == 0 {
 			errs = append(errs, "no keys in memory")
 			return
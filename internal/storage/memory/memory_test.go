@@ -33,6 +33,7 @@ package memory
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -237,6 +238,7 @@ func TestStorage_SaveKeys(t *testing.T) {
 				if tt.wantErrMsg != "" {
 					assert.Contains(t, err.Error(), tt.wantErrMsg)
 				}
+				assert.ErrorIs(t, err, types.ErrPartialSave)
 			} else {
 				assert.NoError(t, err)
 				if tt.validate != nil {
@@ -426,6 +428,29 @@ func TestStorage_ProbeLiveness(t *testing.T) {
 			wantStatusCode:   http.StatusServiceUnavailable,
 			wantBodyContains: "appears stale",
 		},
+		{
+			name: "healthy with keys fetched before maintenance",
+			setup: func(t *testing.T) *Storage {
+				staleness := types.NewStaleness()
+				staleness.Pause(staleTime.Add(time.Second))
+
+				return &Storage{
+					appID:     "test-app",
+					staleness: staleness,
+					keys: map[string]types.DomainKey{
+						"www.example.com": {
+							Date:       &staleTime,
+							DomainName: "example.com",
+							Expire:     expire,
+							File:       "test.json",
+							Fqdn:       "www.example.com",
+							Key:        "test-key",
+						},
+					},
+				}
+			},
+			wantStatusCode: http.StatusOK,
+		},
 		{
 			name: "unhealthy with empty key",
 			setup: func(t *testing.T) *Storage {
@@ -480,6 +505,52 @@ func TestStorage_ProbeLiveness(t *testing.T) {
 			wantStatusCode:   http.StatusServiceUnavailable,
 			wantBodyContains: "no fresh keys found in memory",
 		},
+		{
+			name: "healthy with keys within configured max age",
+			setup: func(t *testing.T) *Storage {
+				s := &Storage{
+					appID: "test-app",
+					keys: map[string]types.DomainKey{
+						"www.example.com": {
+							Date:       &staleTime,
+							DomainName: "example.com",
+							Expire:     expire,
+							File:       "test.json",
+							Fqdn:       "www.example.com",
+							Key:        "test-key",
+						},
+					},
+				}
+				s.WithMaxAge(time.Minute)
+
+				return s
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "unhealthy with keys older than configured max age",
+			setup: func(t *testing.T) *Storage {
+				recent := now.Add(-2 * time.Second)
+				s := &Storage{
+					appID: "test-app",
+					keys: map[string]types.DomainKey{
+						"www.example.com": {
+							Date:       &recent,
+							DomainName: "example.com",
+							Expire:     expire,
+							File:       "test.json",
+							Fqdn:       "www.example.com",
+							Key:        "test-key",
+						},
+					},
+				}
+				s.WithMaxAge(time.Second)
+
+				return s
+			},
+			wantStatusCode:   http.StatusServiceUnavailable,
+			wantBodyContains: ">= 1s",
+		},
 	}
 
 	for _, tt := range tests {
@@ -698,3 +769,152 @@ func TestStorage_Concurrent_GetByFile(t *testing.T) {
 		<-done
 	}
 }
+
+func TestStorage_DeleteKeys(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		file     string
+		fqdn     string
+		wantErr  error
+		wantKeys int
+	}{
+		{name: "success", file: "test.json", fqdn: "www.example.com", wantKeys: 1},
+		{name: "unknown fqdn", file: "test.json", fqdn: "www.unknown.com", wantErr: types.ErrNotFound, wantKeys: 2},
+		{name: "fqdn in another file", file: "other.json", fqdn: "www.example.com", wantErr: types.ErrNotFound, wantKeys: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Storage{
+				keys: map[string]types.DomainKey{
+					"www.example.com": {Date: &now, File: "test.json", Fqdn: "www.example.com", Key: "key1"},
+					"www.test.com":    {Date: &now, File: "test.json", Fqdn: "www.test.com", Key: "key2"},
+				},
+			}
+
+			err := s.DeleteKeys(tt.file, tt.fqdn)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			keys, _, err := s.GetByFile("test.json")
+			require.NoError(t, err)
+			assert.Len(t, keys, tt.wantKeys)
+		})
+	}
+}
+
+func TestStorage_ListFiles(t *testing.T) {
+	now := time.Now()
+
+	s := &Storage{
+		keys: map[string]types.DomainKey{
+			"www.example.com": {Date: &now, File: "b.json", Fqdn: "www.example.com", Key: "key1"},
+			"www.test.com":    {Date: &now, File: "a.json", Fqdn: "www.test.com", Key: "key2"},
+			"api.test.com":    {Date: &now, File: "a.json", Fqdn: "api.test.com", Key: "key3"},
+			"empty.test.com":  {Date: &now, File: "c.json", Fqdn: "empty.test.com"},
+		},
+	}
+
+	files, err := s.ListFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.json", "b.json"}, files)
+
+	files, err = new(Storage).ListFiles()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestStorage_GetByFqdn(t *testing.T) {
+	now := time.Now()
+
+	s := &Storage{
+		keys: map[string]types.DomainKey{
+			"www.example.com": {Date: &now, Expire: 3600, File: "test.json", Fqdn: "www.example.com", Key: "key1", LastError: "timeout"},
+			"empty.test.com":  {Date: &now, File: "test.json", Fqdn: "empty.test.com"},
+		},
+	}
+
+	keys, err := s.GetByFqdn("www.example.com")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "test.json", keys[0].File)
+	assert.Equal(t, "key1", keys[0].Key)
+	assert.Equal(t, int64(3600), keys[0].Expire)
+	assert.Equal(t, "timeout", keys[0].LastError)
+
+	_, err = s.GetByFqdn("empty.test.com")
+	assert.ErrorIs(t, err, types.ErrNotFound)
+
+	_, err = s.GetByFqdn("www.unknown.com")
+	assert.ErrorIs(t, err, types.ErrNotFound)
+}
+
+// largeKeySet returns n keys of large.json.
+func largeKeySet(n int) map[string]types.DomainKey {
+	now := time.Now()
+	expire := now.Add(24 * time.Hour).Unix()
+
+	keys := make(map[string]types.DomainKey, n)
+	for i := 0; i < n; i++ {
+		fqdn := fmt.Sprintf("host-%04d.example.com", i)
+		keys[fqdn] = types.DomainKey{
+			Date:       &now,
+			DomainName: "*.example.com",
+			Expire:     expire,
+			File:       "large.json",
+			Fqdn:       fqdn,
+			Key:        fmt.Sprintf("%044d", i),
+		}
+	}
+
+	return keys
+}
+
+func BenchmarkStorage_GetByFile_LargeKeySet(b *testing.B) {
+	s := &Storage{}
+	require.NoError(b, s.SaveKeys(largeKeySet(1000)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, _ = s.GetByFile("large.json")
+	}
+}
+
+func BenchmarkStorage_SaveKeys_Concurrent(b *testing.B) {
+	s := &Storage{}
+	keys := largeKeySet(1000)
+	require.NoError(b, s.SaveKeys(keys))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			// every other iteration flushes while the rest read the file
+			if i%2 == 0 {
+				_ = s.SaveKeys(keys)
+			} else {
+				_, _, _ = s.GetByFile("large.json")
+			}
+		}
+	})
+}
+
+func TestStorage_Catalog(t *testing.T) {
+	s := new(Storage)
+
+	_, err := s.GetCatalog()
+	assert.ErrorIs(t, err, types.ErrNotFound)
+
+	require.NoError(t, s.SaveCatalog([]byte(`{"files": {}}`)))
+
+	data, err := s.GetCatalog()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"files": {}}`, string(data))
+}
@@ -0,0 +1 @@
+ALTER TABLE domain_keys ADD COLUMN IF NOT EXISTS pins TEXT NOT NULL DEFAULT '';
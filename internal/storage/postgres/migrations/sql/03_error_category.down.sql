@@ -0,0 +1 @@
+ALTER TABLE domain_keys DROP COLUMN IF EXISTS error_category;
@@ -0,0 +1 @@
+ALTER TABLE domain_keys ADD COLUMN IF NOT EXISTS error_category TEXT NOT NULL DEFAULT '';
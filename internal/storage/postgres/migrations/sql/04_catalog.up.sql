@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS catalog (
+    id          BOOLEAN     PRIMARY KEY DEFAULT TRUE CHECK (id),
+    data        JSONB       NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
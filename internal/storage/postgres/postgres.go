@@ -34,13 +34,14 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"ssl-pinning/internal/signer"
 	"ssl-pinning/internal/storage/postgres/migrations"
@@ -62,11 +63,13 @@ func New(ctx context.Context, opts ...types.Option) (types.Storage, error) {
 	// 	s.dumpInterval = 15 * time.Second
 	// }
 
-	db, err := sql.Open("postgres", s.dsn)
+	connector, err := parseDSN(s.dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open postgres dsn: %w", err)
+		return nil, err
 	}
 
+	db := sql.OpenDB(connector)
+
 	if err := db.PingContext(ctx); err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
 	}
@@ -86,6 +89,17 @@ func New(ctx context.Context, opts ...types.Option) (types.Storage, error) {
 	return s, nil
 }
 
+// parseDSN validates a PostgreSQL DSN in URL (postgres://) or key=value form
+// and returns a connector for it without opening a connection.
+func parseDSN(dsn string) (*pq.Connector, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse postgres dsn: %w", err)
+	}
+
+	return connector, nil
+}
+
 // Storage implements the types.Storage interface using PostgreSQL as the backend.
 // It stores domain keys in the domain_keys table with automatic conflict resolution
 // on (app_id, file, fqdn) composite key.
@@ -99,9 +113,20 @@ type Storage struct {
 	connMaxLifetime time.Duration
 	maxIdleConns    int
 	maxOpenConns    int
+	maxAge          time.Duration
+	staleness       *types.Staleness
 	// dumpInterval time.Duration
 }
 
+// WithContext returns a copy of the storage sharing its client that runs its queries within
+// ctx, e.g. the context of an HTTP request, so they are aborted when ctx is cancelled.
+func (s *Storage) WithContext(ctx context.Context) types.Storage {
+	c := *s
+	c.ctx = ctx
+
+	return &c
+}
+
 // WithAppID sets the application ID for this storage instance.
 func (s *Storage) WithAppID(appID string) {
 	s.appID = appID
@@ -147,6 +172,25 @@ func (s *Storage) WithMaxOpenConns(n int) {
 	s.maxOpenConns = n
 }
 
+// WithMaxAge sets the maximum age of keys considered fresh by the health probes.
+func (s *Storage) WithMaxAge(d time.Duration) {
+	s.maxAge = d
+}
+
+// WithStaleness sets how the health probes measure the age of keys (see types.Staleness).
+func (s *Storage) WithStaleness(st *types.Staleness) {
+	s.staleness = st
+}
+
+// probeMaxAge returns the configured maximum key age or types.DefaultMaxAge.
+func (s *Storage) probeMaxAge() time.Duration {
+	if s.maxAge <= 0 {
+		return types.DefaultMaxAge
+	}
+
+	return s.maxAge
+}
+
 // SaveKeys persists a map of domain keys to PostgreSQL in a single transaction.
 // Uses INSERT ... ON CONFLICT DO UPDATE to handle duplicate keys gracefully.
 // The composite unique key is (app_id, file, fqdn).
@@ -163,20 +207,24 @@ INSERT INTO domain_keys (
     app_id,
     date,
     domain_name,
+    error_category,
     expire,
     file,
     fqdn,
     key,
-    last_error
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    last_error,
+    pins
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 ON CONFLICT (app_id, file, fqdn) DO UPDATE
 SET
-    date        = EXCLUDED.date,
-    domain_name = EXCLUDED.domain_name,
-    expire      = EXCLUDED.expire,
-    key         = EXCLUDED.key,
-    last_error  = EXCLUDED.last_error,
-    updated_at  = now();
+    date           = EXCLUDED.date,
+    domain_name    = EXCLUDED.domain_name,
+    error_category = EXCLUDED.error_category,
+    expire         = EXCLUDED.expire,
+    key            = EXCLUDED.key,
+    last_error     = EXCLUDED.last_error,
+    pins           = EXCLUDED.pins,
+    updated_at     = now();
 `
 
 	stmt, err := tx.PrepareContext(s.ctx, q)
@@ -193,11 +241,13 @@ SET
 			s.appID,
 			k.Date,
 			k.DomainName,
+			k.ErrorCategory,
 			k.Expire,
 			k.File,
 			k.Fqdn,
 			k.Key,
 			k.LastError,
+			types.JoinPins(k.Pins),
 		); err != nil {
 			slog.Error("failed to save key to postgres", "error", err, "key", k)
 			_ = tx.Rollback()
@@ -222,10 +272,12 @@ func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
 SELECT DISTINCT ON (fqdn)
        date,
        domain_name,
+       error_category,
        expire,
        fqdn,
        key,
-       last_error
+       last_error,
+       pins
 FROM domain_keys
 WHERE file = $1
   AND key <> ''
@@ -246,15 +298,18 @@ ORDER BY fqdn, expire ASC
 			dk        types.DomainKey
 			dateNT    sql.NullTime
 			lastErrNS sql.NullString
+			pins      string
 		)
 
 		if err := rows.Scan(
 			&dateNT,
 			&dk.DomainName,
+			&dk.ErrorCategory,
 			&dk.Expire,
 			&dk.Fqdn,
 			&dk.Key,
 			&lastErrNS,
+			&pins,
 		); err != nil {
 			slog.Error("failed to scan row", "error", err)
 			return nil, nil, fmt.Errorf("failed to scan row")
@@ -273,6 +328,8 @@ ORDER BY fqdn, expire ASC
 			dk.LastError = lastErrNS.String
 		}
 
+		dk.Pins = types.SplitPins(pins)
+
 		result = append(result, dk)
 	}
 
@@ -286,6 +343,191 @@ ORDER BY fqdn, expire ASC
 	return result, nil, nil
 }
 
+// GetByFqdn retrieves the keys of a FQDN from PostgreSQL.
+// Uses DISTINCT ON (file) to return only the earliest expiring key per file.
+// Returns types.ErrNotFound if no valid keys are found.
+func (s *Storage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	const q = `
+SELECT DISTINCT ON (file)
+       date,
+       domain_name,
+       error_category,
+       expire,
+       file,
+       fqdn,
+       key,
+       last_error,
+       pins
+FROM domain_keys
+WHERE fqdn = $1
+  AND key <> ''
+ORDER BY file, expire ASC
+`
+
+	rows, err := s.client.QueryContext(s.ctx, q, fqdn)
+	if err != nil {
+		slog.Error("failed to query domain_keys by fqdn", "error", err, "fqdn", fqdn)
+		return nil, fmt.Errorf("failed to query keys from postgres")
+	}
+	defer rows.Close()
+
+	var result []types.DomainKey
+
+	for rows.Next() {
+		var (
+			dk        types.DomainKey
+			dateNT    sql.NullTime
+			lastErrNS sql.NullString
+			pins      string
+		)
+
+		if err := rows.Scan(
+			&dateNT,
+			&dk.DomainName,
+			&dk.ErrorCategory,
+			&dk.Expire,
+			&dk.File,
+			&dk.Fqdn,
+			&dk.Key,
+			&lastErrNS,
+			&pins,
+		); err != nil {
+			slog.Error("failed to scan row", "error", err)
+			return nil, fmt.Errorf("failed to scan row")
+		}
+
+		if dateNT.Valid {
+			dk.Date = &dateNT.Time
+		}
+
+		if lastErrNS.Valid {
+			dk.LastError = lastErrNS.String
+		}
+
+		dk.Pins = types.SplitPins(pins)
+
+		result = append(result, dk)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("rows error", "error", err)
+		return nil, fmt.Errorf("failed to read rows")
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("key for fqdn=%q: %w", fqdn, types.ErrNotFound)
+	}
+
+	return result, nil
+}
+
+// ListFiles returns the sorted names of all files with at least one non-empty key in PostgreSQL.
+func (s *Storage) ListFiles() ([]string, error) {
+	const q = `
+SELECT DISTINCT file
+FROM domain_keys
+WHERE key <> ''
+ORDER BY file
+`
+
+	rows, err := s.client.QueryContext(s.ctx, q)
+	if err != nil {
+		slog.Error("failed to query files from domain_keys", "error", err)
+		return nil, fmt.Errorf("failed to query files from postgres")
+	}
+	defer rows.Close()
+
+	files := make([]string, 0)
+
+	for rows.Next() {
+		var file string
+
+		if err := rows.Scan(&file); err != nil {
+			slog.Error("failed to scan row", "error", err)
+			return nil, fmt.Errorf("failed to scan row")
+		}
+
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.Error("rows error", "error", err)
+		return nil, fmt.Errorf("failed to read rows")
+	}
+
+	return files, nil
+}
+
+// DeleteKeys removes the rows of a FQDN in a file for all application instances.
+// Returns types.ErrNotFound if no rows were deleted.
+func (s *Storage) DeleteKeys(file, fqdn string) error {
+	const q = `
+DELETE FROM domain_keys
+WHERE file = $1
+  AND fqdn = $2
+`
+
+	res, err := s.client.ExecContext(s.ctx, q, file, fqdn)
+	if err != nil {
+		slog.Error("failed to delete domain_keys", "error", err, "file", file, "fqdn", fqdn)
+		return fmt.Errorf("failed to delete keys from postgres")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		slog.Error("failed to get affected rows", "error", err)
+		return fmt.Errorf("failed to delete keys from postgres")
+	}
+
+	if n == 0 {
+		return fmt.Errorf("key for fqdn=%q file=%q: %w", fqdn, file, types.ErrNotFound)
+	}
+
+	slog.Debug("deleted keys from postgres", "rows", n, "file", file, "fqdn", fqdn)
+
+	return nil
+}
+
+// GetCatalog retrieves the pin catalogue from PostgreSQL.
+// Returns types.ErrNotFound if no catalogue was saved.
+func (s *Storage) GetCatalog() ([]byte, error) {
+	const q = `
+SELECT data
+FROM catalog
+`
+
+	var data []byte
+
+	if err := s.client.QueryRowContext(s.ctx, q).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("catalog: %w", types.ErrNotFound)
+		}
+
+		slog.Error("failed to query catalog", "error", err)
+		return nil, fmt.Errorf("failed to query catalog from postgres")
+	}
+
+	return data, nil
+}
+
+// SaveCatalog stores the pin catalogue in PostgreSQL, replacing the previous one.
+func (s *Storage) SaveCatalog(data []byte) error {
+	const q = `
+INSERT INTO catalog (data)
+VALUES ($1)
+ON CONFLICT (id) DO UPDATE SET
+  data       = EXCLUDED.data,
+  updated_at = now()
+`
+
+	if _, err := s.client.ExecContext(s.ctx, q, data); err != nil {
+		slog.Error("failed to save catalog", "error", err)
+		return fmt.Errorf("failed to save catalog to postgres")
+	}
+
+	return nil
+}
+
 // Close releases PostgreSQL database connection resources.
 // Logs any errors but always returns nil to satisfy the Storage interface.
 func (s *Storage) Close() error {
@@ -297,13 +539,13 @@ func (s *Storage) Close() error {
 // It checks that:
 //   - PostgreSQL is accessible
 //   - Keys exist for the current appID
-//   - At least one key has been updated within maxAge (10 seconds)
+//   - At least one key has been updated within maxAge (see WithMaxAge)
 //   - Keys have no errors and contain valid data
 //
 // Returns 503 Service Unavailable if any check fails, 200 OK if all checks pass.
 func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const maxAge = 10 * time.Second
+		maxAge := s.probeMaxAge()
 		now := time.Now()
 
 		errs := make([]string, 0)
@@ -398,7 +640,7 @@ WHERE app_id = $1
 
 			k.Date = &dateNT.Time
 
-			age := now.Sub(*k.Date)
+			age := s.staleness.Age(*k.Date, now)
 			if age >= maxAge {
 				errs = append(errs,
 					fmt.Sprintf("key for %s (%s) appears stale (age=%s >= %s)",
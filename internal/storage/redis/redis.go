@@ -33,10 +33,12 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -66,20 +68,45 @@ func New(ctx context.Context, opts ...types.Option) (types.Storage, error) {
 
 	s.ctx = ctx
 
+	o, err := parseDSN(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	o.ClientName = s.appID
+
+	slog.Debug("initialized redis client", "raw;options", o, "raw;storage", s)
+
+	s.client = redis.NewClient(o)
+
+	if err := s.client.Ping(s.ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return s, nil
+}
+
+// parseDSN converts a Redis DSN into client options.
+// It extracts the address, password, database number and maintenance notifications mode.
+// Returns an error if the DSN cannot be parsed, the database number is not a non-negative
+// integer or the maintenance notifications mode is unknown.
+func parseDSN(dsn string) (*redis.Options, error) {
 	o := &redis.Options{
-		ClientName:               s.appID,
 		MaintNotificationsConfig: &maintnotifications.Config{},
 	}
 
-	u, err := url.Parse(s.dsn)
+	u, err := url.Parse(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse redis dsn: %w", err)
 	}
 
-	if mode := u.Query().Get("maintnotifications"); mode == "" {
+	switch mode := maintnotifications.Mode(u.Query().Get("maintnotifications")); mode {
+	case "":
 		o.MaintNotificationsConfig.Mode = maintnotifications.ModeDisabled
-	} else {
-		o.MaintNotificationsConfig.Mode = maintnotifications.Mode(mode)
+	case maintnotifications.ModeDisabled, maintnotifications.ModeEnabled, maintnotifications.ModeAuto:
+		o.MaintNotificationsConfig.Mode = mode
+	default:
+		return nil, fmt.Errorf("failed to parse redis dsn: invalid maintnotifications mode %q", mode)
 	}
 
 	o.Addr = u.Host
@@ -93,33 +120,41 @@ func New(ctx context.Context, opts ...types.Option) (types.Storage, error) {
 	if len(u.Path) > 1 {
 		db, err := strconv.Atoi(u.Path[1:])
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to parse redis dsn: database: %w", err)
 		}
-		o.DB = db
-	}
-
-	slog.Debug("initialized redis client", "raw;options", o, "raw;storage", s)
 
-	s.client = redis.NewClient(o)
+		if db < 0 {
+			return nil, fmt.Errorf("failed to parse redis dsn: negative database %d", db)
+		}
 
-	if err := s.client.Ping(s.ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+		o.DB = db
 	}
 
-	return s, nil
+	return o, nil
 }
 
 // Storage implements the types.Storage interface using Redis as the backend.
 // It stores domain keys as Redis hashes with composite keys (file:fqdn:appID).
 type Storage struct {
-	ctx    context.Context
-	appID  string
-	client *redis.Client
-	dsn    string
-	signer *signer.Signer
+	ctx       context.Context
+	appID     string
+	client    *redis.Client
+	dsn       string
+	signer    *signer.Signer
+	maxAge    time.Duration
+	staleness *types.Staleness
 	// dumpInterval time.Duration
 }
 
+// WithContext returns a copy of the storage sharing its client that runs its commands within
+// ctx, e.g. the context of an HTTP request, so they are aborted when ctx is cancelled.
+func (s *Storage) WithContext(ctx context.Context) types.Storage {
+	c := *s
+	c.ctx = ctx
+
+	return &c
+}
+
 // WithAppID sets the application ID for this storage instance.
 func (s *Storage) WithAppID(appID string) {
 	s.appID = appID
@@ -165,6 +200,25 @@ func (s *Storage) WithMaxOpenConns(n int) {
 	// no-op this storage
 }
 
+// WithMaxAge sets the maximum age of keys considered fresh by the health probes.
+func (s *Storage) WithMaxAge(d time.Duration) {
+	s.maxAge = d
+}
+
+// WithStaleness sets how the health probes measure the age of keys (see types.Staleness).
+func (s *Storage) WithStaleness(st *types.Staleness) {
+	s.staleness = st
+}
+
+// probeMaxAge returns the configured maximum key age or types.DefaultMaxAge.
+func (s *Storage) probeMaxAge() time.Duration {
+	if s.maxAge <= 0 {
+		return types.DefaultMaxAge
+	}
+
+	return s.maxAge
+}
+
 // SaveKeys persists a map of domain keys to Redis.
 // Each key is stored as a Redis hash with composite key format: "file:fqdn:appID".
 // Keys with empty Key field are skipped.
@@ -181,11 +235,13 @@ func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
 		if err := s.client.HSet(s.ctx, hash,
 			"date", key.Date,
 			"domainName", key.DomainName,
+			"error_category", key.ErrorCategory,
 			"expire", key.Expire,
 			"file", key.File,
 			"fqdn", key.Fqdn,
 			"key", key.Key,
 			"last_error", key.LastError,
+			"pins", types.JoinPins(key.Pins),
 		).Err(); err != nil {
 			slog.Error("failed to save key to redis", "error", err, "key", key)
 			errs = append(errs, err)
@@ -202,11 +258,14 @@ func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
 	return nil
 }
 
+// globEscaper escapes the special characters of Redis glob-style patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
 // GetByFile retrieves all domain keys associated with a specific file from Redis.
 // It searches for keys matching the pattern "file:*" and returns the best (earliest expiring)
-// key for each unique FQDN. Returns empty slices if no keys are found.
+// key for each unique FQDN of the file. Returns empty slices if no keys are found.
 func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
-	pattern := fmt.Sprintf("%s:*", file)
+	pattern := fmt.Sprintf("%s:*", globEscaper.Replace(file))
 
 	list, err := s.client.Keys(s.ctx, pattern).Result()
 	if err != nil {
@@ -240,7 +299,9 @@ func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
 			continue
 		}
 
-		if data["key"] == "" {
+		// the pattern matches hashes of other files when the FQDN contains a port, e.g. of file
+		// "f.json:host" for "f.json:host:8443:app"
+		if data["key"] == "" || data["file"] != file {
 			continue
 		}
 
@@ -248,12 +309,14 @@ func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
 		expire, _ := strconv.ParseInt(data["expire"], 10, 64)
 
 		k := types.DomainKey{
-			Date:       &date,
-			DomainName: data["domainName"],
-			Expire:     expire,
-			Fqdn:       data["fqdn"],
-			Key:        data["key"],
-			LastError:  data["last_error"],
+			Date:          &date,
+			DomainName:    data["domainName"],
+			ErrorCategory: data["error_category"],
+			Expire:        expire,
+			Fqdn:          data["fqdn"],
+			Key:           data["key"],
+			LastError:     data["last_error"],
+			Pins:          types.SplitPins(data["pins"]),
 		}
 
 		fqdn := data["fqdn"]
@@ -273,6 +336,208 @@ func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
 	return keys, nil, nil
 }
 
+// GetByFqdn retrieves the keys of a FQDN from every file it is stored in.
+// It searches for hashes matching the pattern "*:fqdn:*" and returns the best (earliest expiring)
+// key for each file, sorted by file name. Returns types.ErrNotFound if no keys are found.
+func (s *Storage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	pattern := fmt.Sprintf("*:%s:*", globEscaper.Replace(fqdn))
+
+	list, err := s.client.Keys(s.ctx, pattern).Result()
+	if err != nil {
+		slog.Error("failed to get keys from redis", "error", err)
+		return nil, fmt.Errorf("failed to get keys from redis")
+	}
+
+	slog.Debug("getting keys by fqdn", "keys", list, "fqdn", fqdn)
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(list))
+
+	for i, k := range list {
+		cmds[i] = pipe.HGetAll(s.ctx, k)
+	}
+
+	if len(list) > 0 {
+		if _, err := pipe.Exec(s.ctx); err != nil {
+			slog.Error("failed to execute pipeline", "error", err)
+			return nil, fmt.Errorf("failed to execute pipeline")
+		}
+	}
+
+	best := make(map[string]types.DomainKey)
+
+	for _, cmd := range cmds {
+		data, err := cmd.Result()
+		if err != nil || len(data) == 0 {
+			continue
+		}
+
+		// the pattern may match hashes of other FQDNs when the file name or the FQDN contain colons
+		if data["key"] == "" || data["fqdn"] != fqdn {
+			continue
+		}
+
+		date, _ := time.Parse(time.RFC3339Nano, data["date"])
+		expire, _ := strconv.ParseInt(data["expire"], 10, 64)
+
+		k := types.DomainKey{
+			Date:          &date,
+			DomainName:    data["domainName"],
+			ErrorCategory: data["error_category"],
+			Expire:        expire,
+			File:          data["file"],
+			Fqdn:          data["fqdn"],
+			Key:           data["key"],
+			LastError:     data["last_error"],
+			Pins:          types.SplitPins(data["pins"]),
+		}
+
+		if prev, ok := best[k.File]; !ok || k.Expire < prev.Expire {
+			best[k.File] = k
+		}
+	}
+
+	if len(best) == 0 {
+		return nil, fmt.Errorf("key for fqdn=%q: %w", fqdn, types.ErrNotFound)
+	}
+
+	keys := make([]types.DomainKey, 0, len(best))
+	for _, v := range best {
+		keys = append(keys, v)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].File < keys[j].File
+	})
+
+	return keys, nil
+}
+
+// ListFiles returns the sorted names of all files stored in Redis.
+// File names are read from the "file" field of the hashes, since the hash keys of the format
+// "file:fqdn:appID" cannot be split when the FQDN contains a port.
+func (s *Storage) ListFiles() ([]string, error) {
+	list, err := s.client.Keys(s.ctx, "*:*:*").Result()
+	if err != nil {
+		slog.Error("failed to get keys from redis", "error", err)
+		return nil, fmt.Errorf("failed to get keys from redis")
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(list))
+
+	for i, k := range list {
+		cmds[i] = pipe.HGet(s.ctx, k, "file")
+	}
+
+	if len(list) > 0 {
+		if _, err := pipe.Exec(s.ctx); err != nil && !errors.Is(err, redis.Nil) {
+			slog.Error("failed to execute pipeline", "error", err)
+			return nil, fmt.Errorf("failed to execute pipeline")
+		}
+	}
+
+	seen := make(map[string]struct{})
+	files := make([]string, 0)
+
+	for _, cmd := range cmds {
+		file, err := cmd.Result()
+		if err != nil || file == "" {
+			continue
+		}
+
+		if _, ok := seen[file]; ok {
+			continue
+		}
+
+		seen[file] = struct{}{}
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// DeleteKeys removes the hashes of a FQDN in a file for all application instances.
+// Hashes are matched by the pattern "file:fqdn:*" and by their "file" and "fqdn" fields, since
+// the pattern of "host" also matches the hashes of "host:8443".
+// Returns types.ErrNotFound if no hashes match.
+func (s *Storage) DeleteKeys(file, fqdn string) error {
+	pattern := fmt.Sprintf("%s:%s:*", globEscaper.Replace(file), globEscaper.Replace(fqdn))
+
+	matched, err := s.client.Keys(s.ctx, pattern).Result()
+	if err != nil {
+		slog.Error("failed to get keys from redis", "error", err)
+		return fmt.Errorf("failed to get keys from redis")
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(matched))
+
+	for i, k := range matched {
+		cmds[i] = pipe.HMGet(s.ctx, k, "file", "fqdn")
+	}
+
+	if len(matched) > 0 {
+		if _, err := pipe.Exec(s.ctx); err != nil {
+			slog.Error("failed to execute pipeline", "error", err)
+			return fmt.Errorf("failed to execute pipeline")
+		}
+	}
+
+	list := make([]string, 0, len(matched))
+
+	for i, cmd := range cmds {
+		if fields, err := cmd.Result(); err == nil && fields[0] == file && fields[1] == fqdn {
+			list = append(list, matched[i])
+		}
+	}
+
+	if len(list) == 0 {
+		return fmt.Errorf("key for fqdn=%q file=%q: %w", fqdn, file, types.ErrNotFound)
+	}
+
+	if err := s.client.Del(s.ctx, list...).Err(); err != nil {
+		slog.Error("failed to delete keys from redis", "error", err, "keys", list)
+		return fmt.Errorf("failed to delete keys from redis")
+	}
+
+	slog.Debug("deleted keys from redis", "keys", list, "file", file, "fqdn", fqdn)
+
+	return nil
+}
+
+// catalogKey is the Redis key of the pin catalogue. It has no colon, so it never matches
+// the patterns of the hashes of domain keys.
+const catalogKey = "catalog"
+
+// GetCatalog retrieves the pin catalogue from Redis.
+// Returns types.ErrNotFound if no catalogue was saved.
+func (s *Storage) GetCatalog() ([]byte, error) {
+	data, err := s.client.Get(s.ctx, catalogKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("catalog: %w", types.ErrNotFound)
+		}
+
+		slog.Error("failed to get catalog from redis", "error", err)
+		return nil, fmt.Errorf("failed to get catalog from redis")
+	}
+
+	return data, nil
+}
+
+// SaveCatalog stores the pin catalogue in Redis, replacing the previous one.
+func (s *Storage) SaveCatalog(data []byte) error {
+	if err := s.client.Set(s.ctx, catalogKey, data, 0).Err(); err != nil {
+		slog.Error("failed to save catalog to redis", "error", err)
+		return fmt.Errorf("failed to save catalog to redis")
+	}
+
+	return nil
+}
+
 // Close releases Redis client resources. Currently a no-op but satisfies the Storage interface.
 func (s *Storage) Close() error {
 	return s.client.Close()
@@ -282,13 +547,13 @@ func (s *Storage) Close() error {
 // It checks that:
 //   - Redis is accessible
 //   - Keys exist for the current appID
-//   - At least one key has been updated within maxAge (10 seconds)
+//   - At least one key has been updated within maxAge (see WithMaxAge)
 //   - Keys have no errors and contain valid data
 //
 // Returns 503 Service Unavailable if any check fails, 200 OK if all checks pass.
 func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const maxAge = 10 * time.Second
+		maxAge := s.probeMaxAge()
 		now := time.Now()
 
 		errs := make([]string, 0)
@@ -383,7 +648,7 @@ func (s *Storage) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			age := now.Sub(t)
+			age := s.staleness.Age(t, now)
 			if age >= maxAge {
 				errs = append(errs,
 					fmt.Sprintf("key for %s (%s) appears stale (age=%s >= %s)",
@@ -0,0 +1,186 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package traced
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// tracerName is the instrumentation scope of storage spans.
+// The tracer is looked up from the global provider on every operation so that providers
+// installed after the storage has been created are honored.
+const tracerName = "ssl-pinning/internal/storage"
+
+// New wraps a storage backend with a decorator that records an OpenTelemetry span
+// for every data operation. Spans are children of the context bound with WithContext,
+// so operations performed while serving a request are part of the request trace.
+func New(s types.Storage, backend types.StorageType) types.Storage {
+	return &Storage{
+		Storage: s,
+		backend: string(backend),
+		ctx:     context.Background(),
+	}
+}
+
+// Storage implements the types.Storage interface by delegating to the wrapped backend
+// inside a client span per operation.
+type Storage struct {
+	types.Storage
+	backend string
+	ctx     context.Context
+}
+
+// WithContext returns a copy of the storage whose spans are children of ctx.
+func (s *Storage) WithContext(ctx context.Context) types.Storage {
+	c := *s
+	c.Storage = types.WithContext(ctx, s.Storage)
+	c.ctx = ctx
+
+	return &c
+}
+
+// SaveKeys persists domain keys using the wrapped backend inside a "storage.SaveKeys" span.
+func (s *Storage) SaveKeys(keys map[string]types.DomainKey) error {
+	span := s.start("SaveKeys", attribute.Int("storage.keys", len(keys)))
+	defer span.End()
+
+	err := s.Storage.SaveKeys(keys)
+	finish(span, err)
+
+	return err
+}
+
+// GetByFile retrieves domain keys by filename using the wrapped backend inside a "storage.GetByFile" span.
+func (s *Storage) GetByFile(file string) ([]types.DomainKey, []byte, error) {
+	span := s.start("GetByFile", attribute.String("storage.file", file))
+	defer span.End()
+
+	keys, data, err := s.Storage.GetByFile(file)
+	span.SetAttributes(
+		attribute.Int("storage.keys", len(keys)),
+		attribute.Int("storage.data_size", len(data)),
+	)
+	finish(span, err)
+
+	return keys, data, err
+}
+
+// GetByFqdn retrieves the keys of a FQDN using the wrapped backend inside a "storage.GetByFqdn" span.
+func (s *Storage) GetByFqdn(fqdn string) ([]types.DomainKey, error) {
+	span := s.start("GetByFqdn", attribute.String("storage.fqdn", fqdn))
+	defer span.End()
+
+	keys, err := s.Storage.GetByFqdn(fqdn)
+	span.SetAttributes(attribute.Int("storage.keys", len(keys)))
+	finish(span, err)
+
+	return keys, err
+}
+
+// ListFiles returns the published files using the wrapped backend inside a "storage.ListFiles" span.
+func (s *Storage) ListFiles() ([]string, error) {
+	span := s.start("ListFiles")
+	defer span.End()
+
+	files, err := s.Storage.ListFiles()
+	span.SetAttributes(attribute.Int("storage.files", len(files)))
+	finish(span, err)
+
+	return files, err
+}
+
+// DeleteKeys removes the keys of a FQDN using the wrapped backend inside a "storage.DeleteKeys" span.
+func (s *Storage) DeleteKeys(file, fqdn string) error {
+	span := s.start("DeleteKeys",
+		attribute.String("storage.file", file),
+		attribute.String("storage.fqdn", fqdn),
+	)
+	defer span.End()
+
+	err := s.Storage.DeleteKeys(file, fqdn)
+	finish(span, err)
+
+	return err
+}
+
+// GetCatalog retrieves the pin catalogue using the wrapped backend inside a "storage.GetCatalog" span.
+func (s *Storage) GetCatalog() ([]byte, error) {
+	span := s.start("GetCatalog")
+	defer span.End()
+
+	data, err := s.Storage.GetCatalog()
+	finish(span, err)
+
+	return data, err
+}
+
+// SaveCatalog persists the pin catalogue using the wrapped backend inside a "storage.SaveCatalog" span.
+func (s *Storage) SaveCatalog(data []byte) error {
+	span := s.start("SaveCatalog")
+	defer span.End()
+
+	err := s.Storage.SaveCatalog(data)
+	finish(span, err)
+
+	return err
+}
+
+// start begins a client span for a storage operation labeled with the backend type.
+func (s *Storage) start(operation string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := otel.Tracer(tracerName).Start(s.ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs,
+			attribute.String("storage.backend", s.backend),
+			attribute.String("storage.operation", operation),
+		)...),
+	)
+
+	return span
+}
+
+// finish records err on the span. A types.ErrNotFound result is not an error.
+func finish(span trace.Span, err error) {
+	if err == nil || errors.Is(err, types.ErrNotFound) {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
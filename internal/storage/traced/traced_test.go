@@ -0,0 +1,163 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package traced
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"ssl-pinning/internal/storage/types"
+)
+
+// stubStorage returns the configured error from every data operation.
+type stubStorage struct {
+	types.Storage
+	err error
+}
+
+func (s *stubStorage) SaveKeys(map[string]types.DomainKey) error { return s.err }
+func (s *stubStorage) GetByFile(string) ([]types.DomainKey, []byte, error) {
+	return []types.DomainKey{{Key: "key1"}}, nil, s.err
+}
+func (s *stubStorage) GetByFqdn(string) ([]types.DomainKey, error) { return nil, s.err }
+func (s *stubStorage) ListFiles() ([]string, error)                { return []string{"a.json"}, s.err }
+func (s *stubStorage) DeleteKeys(string, string) error             { return s.err }
+
+// setupRecorder installs a tracer provider recording all spans in memory for the duration of the test.
+func setupRecorder(t *testing.T) (*tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	return recorder, provider
+}
+
+func TestStorage(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantError bool
+	}{
+		{name: "success"},
+		{name: "failure", err: errors.New("boom"), wantError: true},
+		{name: "not found is not an error", err: types.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, _ := setupRecorder(t)
+
+			s := New(&stubStorage{err: tt.err}, types.StoragePostgres)
+
+			assert.ErrorIs(t, s.SaveKeys(map[string]types.DomainKey{"a": {}}), tt.err)
+			_, _, err := s.GetByFile("test.json")
+			assert.ErrorIs(t, err, tt.err)
+			_, err = s.GetByFqdn("example.com")
+			assert.ErrorIs(t, err, tt.err)
+			_, err = s.ListFiles()
+			assert.ErrorIs(t, err, tt.err)
+			assert.ErrorIs(t, s.DeleteKeys("test.json", "example.com"), tt.err)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 5)
+
+			names := make([]string, 0, len(spans))
+			for _, span := range spans {
+				names = append(names, span.Name())
+
+				assert.Contains(t, span.Attributes(), attribute.String("storage.backend", "postgres"))
+
+				if tt.wantError {
+					assert.Equal(t, codes.Error, span.Status().Code, span.Name())
+				} else {
+					assert.Equal(t, codes.Unset, span.Status().Code, span.Name())
+				}
+			}
+
+			assert.Equal(t, []string{
+				"storage.SaveKeys",
+				"storage.GetByFile",
+				"storage.GetByFqdn",
+				"storage.ListFiles",
+				"storage.DeleteKeys",
+			}, names)
+
+			assert.Contains(t, spans[1].Attributes(), attribute.String("storage.file", "test.json"))
+			assert.Contains(t, spans[1].Attributes(), attribute.Int("storage.keys", 1))
+		})
+	}
+}
+
+func TestStorage_WithContext(t *testing.T) {
+	recorder, provider := setupRecorder(t)
+
+	s := New(&stubStorage{}, types.StorageRedis)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+
+	bound := types.WithContext(ctx, s)
+	_, _, err := bound.GetByFile("test.json")
+	require.NoError(t, err)
+
+	// the original storage is not affected by binding
+	_, err = s.ListFiles()
+	require.NoError(t, err)
+
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "storage.GetByFile", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+
+	assert.Equal(t, "storage.ListFiles", spans[1].Name())
+	assert.False(t, spans[1].Parent().IsValid())
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"ssl-pinning/internal/signer"
+)
+
+const (
+	// coseSign1Tag is the CBOR tag of COSE_Sign1 messages (RFC 9052, section 4.2)
+	coseSign1Tag = 18
+	// coseHeaderAlg is the label of the algorithm header parameter (RFC 9052, section 3.1)
+	coseHeaderAlg = 1
+	// coseHeaderKid is the label of the key identifier header parameter (RFC 9052, section 3.1)
+	coseHeaderKid = 4
+)
+
+// coseAlgorithms maps the JWA names of the signer's algorithms to COSE algorithm identifiers
+// (RFC 9053, section 2.1 and RFC 8812, section 2).
+var coseAlgorithms = map[string]int64{
+	signer.AlgorithmES256: -7,
+	signer.AlgorithmES384: -35,
+	signer.AlgorithmRS512: -259,
+}
+
+// coseEncMode encodes COSE messages and payloads with the core deterministic encoding
+// (RFC 8949, section 4.2.1), times are encoded as Unix timestamps.
+var coseEncMode = func() cbor.EncMode {
+	opts := cbor.CoreDetEncOptions()
+	opts.Time = cbor.TimeUnix
+
+	em, err := opts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return em
+}()
+
+// SignedKeysCOSE creates a COSE_Sign1 message (RFC 9052, section 4.2) containing domain keys.
+// The keys are sorted by expiration time and encoded as CBOR with the naming style, field
+// names follow the JSON payload and dates are Unix timestamps. The protected header names
+// the algorithm and, unless the signer uses the legacy format, the key of the signer.
+// Like the compact JWS envelope it carries the primary signature only.
+// Returns nil if there are no keys.
+func SignedKeysCOSE(file string, keys []DomainKey, signer *signer.Signer, naming Naming) ([]byte, error) {
+	if len(keys) < 1 {
+		slog.Warn("SignedKeys - no keys to save", "file", file)
+		return nil, nil
+	}
+
+	alg, ok := coseAlgorithms[signer.Algorithm()]
+	if !ok {
+		return nil, fmt.Errorf("SignedKeys - unsupported COSE algorithm %s", signer.Algorithm())
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Expire < keys[j].Expire
+	})
+
+	payload, err := coseEncMode.Marshal(naming.payload(keys))
+	if err != nil {
+		return nil, fmt.Errorf("SignedKeys - failed to marshal keys to CBOR: %w", err)
+	}
+
+	header := map[int]any{coseHeaderAlg: alg, coseHeaderKid: []byte(signer.KeyID())}
+
+	protected, err := coseEncMode.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("SignedKeys - failed to marshal COSE header: %w", err)
+	}
+
+	toBeSigned, err := coseEncMode.Marshal([]any{"Signature1", protected, []byte{}, payload})
+	if err != nil {
+		return nil, fmt.Errorf("SignedKeys - failed to marshal COSE Sig_structure: %w", err)
+	}
+
+	signature, err := signer.SignCOSE(toBeSigned)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("COSE_Sign1 created", "file", file, "naming", naming)
+
+	out, err := coseEncMode.Marshal(cbor.Tag{
+		Number:  coseSign1Tag,
+		Content: []any{protected, map[int]any{}, payload, signature},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SignedKeys - failed to marshal COSE_Sign1: %w", err)
+	}
+
+	return out, nil
+}
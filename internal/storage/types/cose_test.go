@@ -0,0 +1,167 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/signer"
+)
+
+func TestSignedKeysCOSE(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	rsaPath := filepath.Join(t.TempDir(), "rsa.pem")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(rsaPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	rsaSigner, err := signer.NewSigner(rsaPath, signer.WithLegacyFormat(true))
+	require.NoError(t, err)
+
+	ecSigner, ecPublic := setupTestECSigner(t)
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newKeys := func() []DomainKey {
+		return []DomainKey{
+			{Date: &now, DomainName: "example.com", Expire: 2, Fqdn: "b.example.com", Key: "key2"},
+			{Date: &now, DomainName: "example.com", Expire: 1, Fqdn: "a.example.com", Key: "key1", LastError: "err"},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		signer      *signer.Signer
+		naming      Naming
+		wantHeader  map[int]any
+		wantPayload map[string]any
+		verify      func(t *testing.T, input, sig []byte)
+	}{
+		{
+			name:       "RS512 legacy format",
+			signer:     rsaSigner,
+			naming:     NamingLegacy,
+			wantHeader: map[int]any{1: int64(-259), 4: []byte(rsaSigner.KeyID())},
+			wantPayload: map[string]any{"keys": []any{
+				map[any]any{"date": uint64(now.Unix()), "domainName": "example.com", "expire": uint64(1), "fqdn": "a.example.com", "key": "key1", "last_error": "err"},
+				map[any]any{"date": uint64(now.Unix()), "domainName": "example.com", "expire": uint64(2), "fqdn": "b.example.com", "key": "key2"},
+			}},
+			verify: func(t *testing.T, input, sig []byte) {
+				hashed := sha512.Sum512(input)
+				assert.NoError(t, rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA512, hashed[:], sig))
+			},
+		},
+		{
+			name:       "ES256 snake case",
+			signer:     ecSigner,
+			naming:     NamingSnake,
+			wantHeader: map[int]any{1: int64(-7), 4: []byte(ecSigner.KeyID())},
+			wantPayload: map[string]any{"keys": []any{
+				map[any]any{"date": uint64(now.Unix()), "domain_name": "example.com", "expire": uint64(1), "fqdn": "a.example.com", "key": "key1", "last_error": "err"},
+				map[any]any{"date": uint64(now.Unix()), "domain_name": "example.com", "expire": uint64(2), "fqdn": "b.example.com", "key": "key2"},
+			}},
+			verify: func(t *testing.T, input, sig []byte) {
+				require.Len(t, sig, 64)
+				hashed := sha256.Sum256(input)
+				r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+				assert.True(t, ecdsa.Verify(ecPublic, hashed[:], r, s))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := SignedKeysWithEnvelope("test.json", newKeys(), tt.signer, tt.naming, EnvelopeCOSE)
+			require.NoError(t, err)
+
+			var msg struct {
+				_           struct{} `cbor:",toarray"`
+				Protected   []byte
+				Unprotected map[int]any
+				Payload     []byte
+				Signature   []byte
+			}
+
+			var tag cbor.RawTag
+			require.NoError(t, cbor.Unmarshal(res, &tag))
+			assert.Equal(t, uint64(18), tag.Number)
+			require.NoError(t, cbor.Unmarshal(tag.Content, &msg))
+			assert.Empty(t, msg.Unprotected)
+
+			var header map[int]any
+			require.NoError(t, cbor.Unmarshal(msg.Protected, &header))
+			assert.Equal(t, tt.wantHeader, header)
+
+			var payload map[string]any
+			require.NoError(t, cbor.Unmarshal(msg.Payload, &payload))
+			assert.Equal(t, tt.wantPayload, payload)
+
+			toBeSigned, err := cbor.Marshal([]any{"Signature1", msg.Protected, []byte{}, msg.Payload})
+			require.NoError(t, err)
+			tt.verify(t, toBeSigned, msg.Signature)
+
+			// the binary envelope is more compact than the JWS one
+			jws, err := SignedKeysWithEnvelope("test.json", newKeys(), tt.signer, tt.naming, EnvelopeJWS)
+			require.NoError(t, err)
+			assert.Less(t, len(res), len(jws))
+		})
+	}
+}
+
+func TestSignedKeysCOSE_NoKeys(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	s, _ := setupTestECSigner(t)
+
+	res, err := SignedKeysCOSE("test.json", nil, s, NamingLegacy)
+	require.NoError(t, err)
+	assert.Nil(t, res)
+}
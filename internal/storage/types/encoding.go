@@ -0,0 +1,121 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+// PinEncoding defines the textual form of the pins of published payloads.
+// Pins are stored base64 encoded and re-encoded when a file is rendered (see EncodePins).
+type PinEncoding string
+
+const (
+	// PinEncodingBase64 is the base64 encoded SHA-256 hash of the public key, e.g. for TrustKit
+	PinEncodingBase64 PinEncoding = "base64"
+	// PinEncodingSHA256 prefixes the base64 encoded hash with "sha256//", e.g. for curl
+	PinEncodingSHA256 PinEncoding = "sha256"
+	// PinEncodingHex is the hex encoded SHA-256 hash of the public key
+	PinEncodingHex PinEncoding = "hex"
+)
+
+// pinPrefixSHA256 is the prefix of pins encoded with PinEncodingSHA256.
+const pinPrefixSHA256 = "sha256//"
+
+// ParsePinEncoding converts a configuration or query parameter value into a PinEncoding.
+// An empty value is treated as PinEncodingBase64 so existing deployments keep their output.
+// Returns an error for unknown encodings.
+func ParsePinEncoding(v string) (PinEncoding, error) {
+	switch PinEncoding(v) {
+	case "", PinEncodingBase64:
+		return PinEncodingBase64, nil
+	case PinEncodingSHA256, PinEncodingHex:
+		return PinEncoding(v), nil
+	default:
+		return "", fmt.Errorf("invalid pin encoding: %s", v)
+	}
+}
+
+// Encode returns a base64 encoded pin in the encoding.
+// Returns an error if the pin is not valid base64.
+func (e PinEncoding) Encode(pin string) (string, error) {
+	switch e {
+	case PinEncodingSHA256:
+		return pinPrefixSHA256 + pin, nil
+	case PinEncodingHex:
+		raw, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil {
+			return "", fmt.Errorf("invalid pin %q: %w", pin, err)
+		}
+
+		return hex.EncodeToString(raw), nil
+	default:
+		return pin, nil
+	}
+}
+
+// EncodePins returns copies of the keys with Key and Pins in the encoding.
+// The keys are returned as is for PinEncodingBase64.
+func EncodePins(keys []DomainKey, encoding PinEncoding) ([]DomainKey, error) {
+	if encoding == PinEncodingBase64 || encoding == "" {
+		return keys, nil
+	}
+
+	list := make([]DomainKey, len(keys))
+
+	for i, k := range keys {
+		var err error
+
+		if k.Key != "" {
+			if k.Key, err = encoding.Encode(k.Key); err != nil {
+				return nil, err
+			}
+		}
+
+		if k.Pins != nil {
+			k.Pins = slices.Clone(k.Pins)
+
+			for j, p := range k.Pins {
+				if k.Pins[j], err = encoding.Encode(p); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		list[i] = k
+	}
+
+	return list, nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPin = "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+func TestParsePinEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    PinEncoding
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: PinEncodingBase64},
+		{name: "base64", value: "base64", want: PinEncodingBase64},
+		{name: "sha256", value: "sha256", want: PinEncodingSHA256},
+		{name: "hex", value: "hex", want: PinEncodingHex},
+		{name: "unknown", value: "base32", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePinEncoding(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPinEncoding_Encode(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding PinEncoding
+		pin      string
+		want     string
+		wantErr  bool
+	}{
+		{name: "base64", encoding: PinEncodingBase64, pin: testPin, want: testPin},
+		{name: "sha256", encoding: PinEncodingSHA256, pin: testPin, want: "sha256//" + testPin},
+		{name: "hex", encoding: PinEncodingHex, pin: testPin, want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{name: "invalid hex input", encoding: PinEncodingHex, pin: "not base64!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.encoding.Encode(tt.pin)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEncodePins(t *testing.T) {
+	keys := []DomainKey{
+		{Fqdn: "example.com", Key: testPin, Pins: []string{testPin, testPin}},
+		{Fqdn: "failed.example.com", LastError: "timeout"},
+	}
+
+	got, err := EncodePins(keys, PinEncodingSHA256)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256//"+testPin, got[0].Key)
+	assert.Equal(t, []string{"sha256//" + testPin, "sha256//" + testPin}, got[0].Pins)
+	assert.Empty(t, got[1].Key)
+	assert.Nil(t, got[1].Pins)
+
+	// the keys are not modified
+	assert.Equal(t, testPin, keys[0].Key)
+	assert.Equal(t, testPin, keys[0].Pins[0])
+
+	same, err := EncodePins(keys, PinEncodingBase64)
+	require.NoError(t, err)
+	assert.Equal(t, keys, same)
+
+	_, err = EncodePins([]DomainKey{{Key: "not base64!"}}, PinEncodingHex)
+	assert.Error(t, err)
+}
@@ -0,0 +1,380 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Format defines a client configuration rendered from the keys of a file, for clients that
+// consume pins through their own configuration rather than the signed payload.
+type Format string
+
+const (
+	// FormatTrustKit is the JSON configuration of TrustKit (kTSKPinnedDomains)
+	FormatTrustKit Format = "trustkit"
+	// FormatTrustKitPlist is the TrustKit configuration as property list (TSKConfiguration of Info.plist)
+	FormatTrustKitPlist Format = "trustkit-plist"
+	// FormatOkHttp is the JSON object of hostname patterns and pins of the OkHttp CertificatePinner
+	FormatOkHttp Format = "okhttp"
+	// FormatOkHttpKotlin is Kotlin code building the OkHttp CertificatePinner
+	FormatOkHttpKotlin Format = "okhttp-kotlin"
+	// FormatHPKP lists the pin-sha256 directives of the Public-Key-Pins header (RFC 7469) by hostname pattern
+	FormatHPKP Format = "hpkp"
+	// FormatCurl lists the public key pins accepted by curl --pinnedpubkey by hostname pattern
+	FormatCurl Format = "curl"
+)
+
+// pinPrefixOkHttp is the prefix of the pins of the OkHttp CertificatePinner.
+const pinPrefixOkHttp = "sha256/"
+
+// ErrTooFewPins is returned by Format.Render when a domain has no backup pin besides its live
+// pin, which TrustKit and HPKP require and OkHttp needs to survive a key rotation.
+var ErrTooFewPins = errors.New("too few pins")
+
+// ParseFormat converts a query parameter value into a Format.
+// Returns an error for unknown formats.
+func ParseFormat(v string) (Format, error) {
+	switch Format(v) {
+	case FormatTrustKit, FormatTrustKitPlist, FormatOkHttp, FormatOkHttpKotlin, FormatHPKP, FormatCurl:
+		return Format(v), nil
+	default:
+		return "", fmt.Errorf("invalid format: %s", v)
+	}
+}
+
+// MediaType returns the media type of configurations in the format.
+func (f Format) MediaType() string {
+	switch f {
+	case FormatTrustKitPlist:
+		return "application/x-plist"
+	case FormatOkHttpKotlin:
+		return "text/x-kotlin; charset=utf-8"
+	case FormatHPKP, FormatCurl:
+		return "text/plain; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Render returns the configuration of the keys in the format.
+// Keys without pins, e.g. of domains that were never fetched, are left out.
+// Returns ErrTooFewPins if a domain has a single pin in any format but curl, as clients reject
+// such a configuration or lock out users on the next key rotation.
+func (f Format) Render(keys []DomainKey) ([]byte, error) {
+	domains := pinnedDomains(keys)
+
+	switch f {
+	case FormatTrustKit, FormatTrustKitPlist:
+		cfg, err := trustKitConfig(domains)
+		if err != nil {
+			return nil, err
+		}
+
+		if f == FormatTrustKitPlist {
+			return trustKitPlist(cfg), nil
+		}
+
+		return json.MarshalIndent(cfg, "", "  ")
+	case FormatOkHttp, FormatOkHttpKotlin, FormatHPKP:
+		for _, d := range domains {
+			if err := checkPins(d.Pattern, d.Pins); err != nil {
+				return nil, err
+			}
+		}
+
+		switch f {
+		case FormatOkHttp:
+			return json.MarshalIndent(okHttpPins(domains), "", "  ")
+		case FormatOkHttpKotlin:
+			return okHttpKotlin(domains), nil
+		default:
+			return pinLines(domains, hpkpPins), nil
+		}
+	case FormatCurl:
+		return pinLines(domains, curlPins), nil
+	default:
+		return nil, fmt.Errorf("invalid format: %s", f)
+	}
+}
+
+// pinnedDomain is the hostname pattern of one or more keys with their pins: the host of the
+// FQDN, or the wildcard, e.g. "*.example.com", covering exactly one label.
+type pinnedDomain struct {
+	Pattern string
+	Pins    []string
+}
+
+// apex returns the host of the pattern with the wildcard label removed and whether the pattern
+// is a wildcard.
+func (d pinnedDomain) apex() (string, bool) {
+	host, ok := strings.CutPrefix(d.Pattern, wildcardPrefix)
+	return host, ok
+}
+
+// pinnedDomains returns the pinned domains of the keys sorted by pattern. Keys of the same host,
+// e.g. on different ports, are merged with the union of their pins: the live pins followed by
+// the backup pins, or the key if it has no pins.
+func pinnedDomains(keys []DomainKey) []pinnedDomain {
+	var (
+		domains []pinnedDomain
+		index   = make(map[string]int)
+	)
+
+	for _, k := range keys {
+		pins := k.Pins
+		if len(pins) == 0 && k.Key != "" {
+			pins = []string{k.Key}
+		}
+
+		if len(pins) == 0 {
+			continue
+		}
+
+		pattern := strings.ToLower(k.Host())
+
+		i, ok := index[pattern]
+		if !ok {
+			i = len(domains)
+			index[pattern] = i
+			domains = append(domains, pinnedDomain{Pattern: pattern})
+		}
+
+		domains[i].Pins = appendPins(domains[i].Pins, pins)
+	}
+
+	slices.SortFunc(domains, func(a, b pinnedDomain) int {
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+
+	return domains
+}
+
+// checkPins returns ErrTooFewPins if the pins of a host have no backup pin besides the live pin.
+func checkPins(host string, pins []string) error {
+	if len(pins) < 2 {
+		return fmt.Errorf("%w: %s has no backup pin, configure backup_pins or chain", ErrTooFewPins, host)
+	}
+
+	return nil
+}
+
+// appendPins appends the pins that are not in list yet.
+func appendPins(list, pins []string) []string {
+	for _, p := range pins {
+		if !slices.Contains(list, p) {
+			list = append(list, p)
+		}
+	}
+
+	return list
+}
+
+// TrustKitConfig is the TrustKit configuration of pinned domains, as passed to
+// TrustKit.initSharedInstance(withConfiguration:) or set as TSKConfiguration in Info.plist.
+type TrustKitConfig struct {
+	PinnedDomains map[string]TrustKitDomain `json:"kTSKPinnedDomains"`
+}
+
+// TrustKitDomain is the pinning policy of a domain in a TrustKit configuration.
+// TrustKit requires at least two hashes per domain, e.g. a backup pin (see DomainKey.BackupPins).
+type TrustKitDomain struct {
+	EnforcePinning    bool     `json:"kTSKEnforcePinning"`
+	IncludeSubdomains bool     `json:"kTSKIncludeSubdomains"`
+	PublicKeyHashes   []string `json:"kTSKPublicKeyHashes"`
+}
+
+// trustKitConfig returns the TrustKit configuration of the domains with pinning enforced.
+// TrustKit has no single-label wildcards, so a wildcard pins its apex domain with all subdomains
+// and is merged with the apex domain if that is pinned as well.
+// Returns ErrTooFewPins if a merged domain has less than the two hashes TrustKit requires.
+func trustKitConfig(domains []pinnedDomain) (TrustKitConfig, error) {
+	cfg := TrustKitConfig{PinnedDomains: make(map[string]TrustKitDomain, len(domains))}
+
+	for _, d := range domains {
+		host, wildcard := d.apex()
+
+		policy := cfg.PinnedDomains[host]
+		policy.EnforcePinning = true
+		policy.IncludeSubdomains = policy.IncludeSubdomains || wildcard
+		policy.PublicKeyHashes = appendPins(policy.PublicKeyHashes, d.Pins)
+
+		cfg.PinnedDomains[host] = policy
+	}
+
+	for _, host := range slices.Sorted(maps.Keys(cfg.PinnedDomains)) {
+		if err := checkPins(host, cfg.PinnedDomains[host].PublicKeyHashes); err != nil {
+			return TrustKitConfig{}, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// trustKitPlist returns the TrustKit configuration as XML property list
+// with the TSKConfiguration key of Info.plist.
+func trustKitPlist(cfg TrustKitConfig) []byte {
+	hosts := slices.Sorted(maps.Keys(cfg.PinnedDomains))
+
+	var b bytes.Buffer
+
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	plistKey(&b, 1, "TSKConfiguration")
+	b.WriteString("\t<dict>\n")
+	plistKey(&b, 2, "kTSKPinnedDomains")
+	b.WriteString("\t\t<dict>\n")
+
+	for _, host := range hosts {
+		policy := cfg.PinnedDomains[host]
+
+		plistKey(&b, 3, host)
+		b.WriteString("\t\t\t<dict>\n")
+		plistKey(&b, 4, "kTSKEnforcePinning")
+		b.WriteString("\t\t\t\t<true/>\n")
+		plistKey(&b, 4, "kTSKIncludeSubdomains")
+		b.WriteString("\t\t\t\t" + plistBool(policy.IncludeSubdomains) + "\n")
+		plistKey(&b, 4, "kTSKPublicKeyHashes")
+		b.WriteString("\t\t\t\t<array>\n")
+
+		for _, p := range policy.PublicKeyHashes {
+			b.WriteString("\t\t\t\t\t<string>")
+			_ = xml.EscapeText(&b, []byte(p))
+			b.WriteString("</string>\n")
+		}
+
+		b.WriteString("\t\t\t\t</array>\n")
+		b.WriteString("\t\t\t</dict>\n")
+	}
+
+	b.WriteString("\t\t</dict>\n\t</dict>\n</dict>\n</plist>\n")
+
+	return b.Bytes()
+}
+
+// plistKey writes a property list key indented by depth tabs.
+func plistKey(b *bytes.Buffer, depth int, key string) {
+	b.WriteString(strings.Repeat("\t", depth) + "<key>")
+	_ = xml.EscapeText(b, []byte(key))
+	b.WriteString("</key>\n")
+}
+
+// plistBool returns the property list element of a boolean.
+func plistBool(v bool) string {
+	if v {
+		return "<true/>"
+	}
+
+	return "<false/>"
+}
+
+// okHttpPins returns the pins of the domains by hostname pattern as expected by
+// CertificatePinner.Builder.add of OkHttp, e.g. "sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=".
+// The wildcard patterns of OkHttp match exactly one label like wildcard FQDNs.
+func okHttpPins(domains []pinnedDomain) map[string][]string {
+	pins := make(map[string][]string, len(domains))
+
+	for _, d := range domains {
+		list := make([]string, len(d.Pins))
+		for i, p := range d.Pins {
+			list[i] = pinPrefixOkHttp + p
+		}
+
+		pins[d.Pattern] = list
+	}
+
+	return pins
+}
+
+// okHttpKotlin returns Kotlin code building the OkHttp CertificatePinner of the domains.
+func okHttpKotlin(domains []pinnedDomain) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("val certificatePinner = CertificatePinner.Builder()\n")
+
+	for _, d := range domains {
+		b.WriteString("    .add(\n")
+		b.WriteString("        " + strconv.Quote(d.Pattern) + ",\n")
+
+		for _, p := range d.Pins {
+			b.WriteString("        " + strconv.Quote(pinPrefixOkHttp+p) + ",\n")
+		}
+
+		b.WriteString("    )\n")
+	}
+
+	b.WriteString("    .build()\n")
+
+	return b.Bytes()
+}
+
+// pinLines returns a line per domain with its pattern and its pins joined by join, separated by a tab.
+func pinLines(domains []pinnedDomain, join func(pins []string) string) []byte {
+	var b bytes.Buffer
+
+	for _, d := range domains {
+		b.WriteString(d.Pattern + "\t" + join(d.Pins) + "\n")
+	}
+
+	return b.Bytes()
+}
+
+// hpkpPins returns the pin-sha256 directives of the Public-Key-Pins header of the pins,
+// e.g. `pin-sha256="47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="`, without max-age.
+func hpkpPins(pins []string) string {
+	list := make([]string, len(pins))
+	for i, p := range pins {
+		list[i] = fmt.Sprintf("pin-sha256=%q", p)
+	}
+
+	return strings.Join(list, "; ")
+}
+
+// curlPins returns the pins in the form of curl --pinnedpubkey, which accepts any of several
+// pins separated by semicolons, e.g. "sha256//47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=".
+func curlPins(pins []string) string {
+	list := make([]string, len(pins))
+	for i, p := range pins {
+		list[i] = pinPrefixSHA256 + p
+	}
+
+	return strings.Join(list, ";")
+}
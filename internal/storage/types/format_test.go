@@ -0,0 +1,244 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Format
+		wantErr bool
+	}{
+		{name: "trustkit", value: "trustkit", want: FormatTrustKit},
+		{name: "trustkit plist", value: "trustkit-plist", want: FormatTrustKitPlist},
+		{name: "okhttp", value: "okhttp", want: FormatOkHttp},
+		{name: "okhttp kotlin", value: "okhttp-kotlin", want: FormatOkHttpKotlin},
+		{name: "hpkp", value: "hpkp", want: FormatHPKP},
+		{name: "curl", value: "curl", want: FormatCurl},
+		{name: "empty", value: "", wantErr: true},
+		{name: "unknown", value: "android", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormat(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPinnedDomains(t *testing.T) {
+	backup := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	keys := []DomainKey{
+		{Fqdn: "b.example.com", Key: testPin},
+		{Fqdn: "*.example.org", Key: testPin, Pins: []string{testPin, backup}},
+		{Fqdn: "B.example.com:8443", Key: backup, Pins: []string{backup, testPin}},
+		{Fqdn: "down.example.com", LastError: "connection refused"},
+	}
+
+	assert.Equal(t, []pinnedDomain{
+		{Pattern: "*.example.org", Pins: []string{testPin, backup}},
+		{Pattern: "b.example.com", Pins: []string{testPin, backup}},
+	}, pinnedDomains(keys))
+}
+
+func TestFormat_Render(t *testing.T) {
+	backup := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	intermediate := "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB="
+
+	keys := []DomainKey{
+		{Fqdn: "*.example.com", Key: testPin, Pins: []string{testPin, intermediate}},
+		{Fqdn: "api.example.org", Key: testPin, Pins: []string{testPin, backup}},
+	}
+
+	t.Run("trustkit", func(t *testing.T) {
+		data, err := FormatTrustKit.Render(keys)
+		require.NoError(t, err)
+
+		var cfg map[string]map[string]map[string]any
+		require.NoError(t, json.Unmarshal(data, &cfg))
+
+		assert.Equal(t, map[string]map[string]map[string]any{
+			"kTSKPinnedDomains": {
+				"example.com": {
+					"kTSKEnforcePinning":    true,
+					"kTSKIncludeSubdomains": true,
+					"kTSKPublicKeyHashes":   []any{testPin, intermediate},
+				},
+				"api.example.org": {
+					"kTSKEnforcePinning":    true,
+					"kTSKIncludeSubdomains": false,
+					"kTSKPublicKeyHashes":   []any{testPin, backup},
+				},
+			},
+		}, cfg)
+	})
+
+	t.Run("trustkit plist", func(t *testing.T) {
+		data, err := FormatTrustKitPlist.Render(keys)
+		require.NoError(t, err)
+
+		assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>TSKConfiguration</key>
+	<dict>
+		<key>kTSKPinnedDomains</key>
+		<dict>
+			<key>api.example.org</key>
+			<dict>
+				<key>kTSKEnforcePinning</key>
+				<true/>
+				<key>kTSKIncludeSubdomains</key>
+				<false/>
+				<key>kTSKPublicKeyHashes</key>
+				<array>
+					<string>`+testPin+`</string>
+					<string>`+backup+`</string>
+				</array>
+			</dict>
+			<key>example.com</key>
+			<dict>
+				<key>kTSKEnforcePinning</key>
+				<true/>
+				<key>kTSKIncludeSubdomains</key>
+				<true/>
+				<key>kTSKPublicKeyHashes</key>
+				<array>
+					<string>`+testPin+`</string>
+					<string>`+intermediate+`</string>
+				</array>
+			</dict>
+		</dict>
+	</dict>
+</dict>
+</plist>
+`, string(data))
+	})
+	t.Run("trustkit merges wildcards into apex", func(t *testing.T) {
+		data, err := FormatTrustKit.Render([]DomainKey{
+			{Fqdn: "*.example.com", Key: testPin},
+			{Fqdn: "example.com", Key: backup},
+		})
+		require.NoError(t, err)
+
+		var cfg TrustKitConfig
+		require.NoError(t, json.Unmarshal(data, &cfg))
+
+		assert.Equal(t, TrustKitConfig{
+			PinnedDomains: map[string]TrustKitDomain{
+				"example.com": {EnforcePinning: true, IncludeSubdomains: true, PublicKeyHashes: []string{testPin, backup}},
+			},
+		}, cfg)
+	})
+
+	t.Run("okhttp", func(t *testing.T) {
+		data, err := FormatOkHttp.Render(keys)
+		require.NoError(t, err)
+
+		var pins map[string][]string
+		require.NoError(t, json.Unmarshal(data, &pins))
+
+		assert.Equal(t, map[string][]string{
+			"*.example.com":   {"sha256/" + testPin, "sha256/" + intermediate},
+			"api.example.org": {"sha256/" + testPin, "sha256/" + backup},
+		}, pins)
+	})
+
+	t.Run("okhttp kotlin", func(t *testing.T) {
+		data, err := FormatOkHttpKotlin.Render(keys)
+		require.NoError(t, err)
+
+		assert.Equal(t, `val certificatePinner = CertificatePinner.Builder()
+    .add(
+        "*.example.com",
+        "sha256/`+testPin+`",
+        "sha256/`+intermediate+`",
+    )
+    .add(
+        "api.example.org",
+        "sha256/`+testPin+`",
+        "sha256/`+backup+`",
+    )
+    .build()
+`, string(data))
+	})
+	t.Run("hpkp", func(t *testing.T) {
+		data, err := FormatHPKP.Render(keys)
+		require.NoError(t, err)
+
+		assert.Equal(t, "*.example.com\tpin-sha256=\""+testPin+"\"; pin-sha256=\""+intermediate+"\"\n"+
+			"api.example.org\tpin-sha256=\""+testPin+"\"; pin-sha256=\""+backup+"\"\n", string(data))
+	})
+
+	t.Run("curl", func(t *testing.T) {
+		data, err := FormatCurl.Render(keys)
+		require.NoError(t, err)
+
+		assert.Equal(t, "*.example.com\tsha256//"+testPin+";sha256//"+intermediate+"\n"+
+			"api.example.org\tsha256//"+testPin+";sha256//"+backup+"\n", string(data))
+	})
+
+	t.Run("single pin", func(t *testing.T) {
+		single := []DomainKey{
+			{Fqdn: "api.example.com", Key: testPin},
+			{Fqdn: "api.example.org", Key: testPin, Pins: []string{testPin, backup}},
+		}
+
+		for _, f := range []Format{FormatTrustKit, FormatTrustKitPlist, FormatOkHttp, FormatOkHttpKotlin, FormatHPKP} {
+			_, err := f.Render(single)
+			require.ErrorIs(t, err, ErrTooFewPins, f)
+			assert.Contains(t, err.Error(), "api.example.com", f)
+		}
+
+		data, err := FormatCurl.Render(single)
+		require.NoError(t, err)
+		assert.Equal(t, "api.example.com\tsha256//"+testPin+"\n"+
+			"api.example.org\tsha256//"+testPin+";sha256//"+backup+"\n", string(data))
+	})
+}
@@ -0,0 +1,211 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"ssl-pinning/internal/signer"
+)
+
+// Envelope defines how a signed payload is serialized.
+type Envelope string
+
+const (
+	// EnvelopeLegacy wraps the payload as {"payload": ..., "signature": ...} signed over canonical JSON
+	EnvelopeLegacy Envelope = "legacy"
+	// EnvelopeJWS is the JWS compact serialization (RFC 7515, section 7.1)
+	EnvelopeJWS Envelope = "jws"
+	// EnvelopeJWSJSON is the flattened JWS JSON serialization (RFC 7515, section 7.2.2),
+	// or the general one (section 7.2.1) when files carry several signatures during a key rotation
+	EnvelopeJWSJSON Envelope = "jws+json"
+	// EnvelopeCOSE is a COSE_Sign1 message (RFC 9052) over the CBOR encoded payload, see SignedKeysCOSE
+	EnvelopeCOSE Envelope = "cose"
+)
+
+// ParseEnvelope converts a configuration value into an Envelope.
+// An empty value is treated as EnvelopeLegacy. Returns an error for unknown envelopes.
+func ParseEnvelope(v string) (Envelope, error) {
+	switch Envelope(v) {
+	case "", EnvelopeLegacy:
+		return EnvelopeLegacy, nil
+	case EnvelopeJWS, EnvelopeJWSJSON, EnvelopeCOSE:
+		return Envelope(v), nil
+	default:
+		return "", fmt.Errorf("invalid envelope: %s", v)
+	}
+}
+
+// MediaType returns the media type of files serialized with the envelope.
+func (e Envelope) MediaType() string {
+	switch e {
+	case EnvelopeJWS:
+		return "application/jose"
+	case EnvelopeJWSJSON:
+		return "application/jose+json"
+	case EnvelopeCOSE:
+		return "application/cose"
+	default:
+		return "application/json"
+	}
+}
+
+// jwsHeader is the JWS protected header.
+// Kid is omitted when the signer uses the legacy format.
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ"`
+}
+
+// jwsJSON is the flattened JWS JSON serialization.
+type jwsJSON struct {
+	Payload   string `json:"payload"`
+	Protected string `json:"protected"`
+	Signature string `json:"signature"`
+}
+
+// jwsSignature is a signature of the general JWS JSON serialization.
+type jwsSignature struct {
+	Protected string `json:"protected"`
+	Signature string `json:"signature"`
+}
+
+// jwsGeneralJSON is the general JWS JSON serialization carrying several signatures.
+type jwsGeneralJSON struct {
+	Payload    string         `json:"payload"`
+	Signatures []jwsSignature `json:"signatures"`
+}
+
+// SignedKeysWithEnvelope creates a signed file containing domain keys serialized with the
+// given envelope. EnvelopeLegacy is handled by SignedKeysWithNaming and EnvelopeCOSE by
+// SignedKeysCOSE. For the JWS envelopes the keys are sorted by expiration time, marshaled
+// with the naming style and signed as is, so clients can verify them with any JOSE library without canonicalizing the payload.
+// The compact serialization carries the primary signature only, the JSON serialization
+// also carries the signatures of the signer's cosigners during a key rotation.
+// Returns nil if there are no keys.
+func SignedKeysWithEnvelope(file string, keys []DomainKey, signer *signer.Signer, naming Naming, envelope Envelope) ([]byte, error) {
+	if envelope == EnvelopeCOSE {
+		return SignedKeysCOSE(file, keys, signer, naming)
+	}
+
+	if envelope != EnvelopeJWS && envelope != EnvelopeJWSJSON {
+		return SignedKeysWithNaming(file, keys, signer, naming)
+	}
+
+	if len(keys) < 1 {
+		slog.Warn("SignedKeys - no keys to save", "file", file)
+		return nil, nil
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Expire < keys[j].Expire
+	})
+
+	payload, err := json.Marshal(naming.payload(keys))
+	if err != nil {
+		return nil, fmt.Errorf("SignedKeys - failed to marshal keys to JSON: %w", err)
+	}
+
+	typ := "JOSE"
+	if envelope == EnvelopeJWSJSON {
+		typ = "JOSE+JSON"
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+
+	primary, err := signJWS(signer, typ, encodedPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("JWS created", "file", file, "naming", naming, "envelope", envelope)
+
+	if envelope == EnvelopeJWS {
+		return []byte(primary.Protected + "." + encodedPayload + "." + primary.Signature), nil
+	}
+
+	var doc any = jwsJSON{
+		Payload:   encodedPayload,
+		Protected: primary.Protected,
+		Signature: primary.Signature,
+	}
+
+	if cosigners := signer.Cosigners(); len(cosigners) > 0 {
+		general := jwsGeneralJSON{
+			Payload:    encodedPayload,
+			Signatures: []jwsSignature{primary},
+		}
+
+		for _, c := range cosigners {
+			sig, err := signJWS(c, typ, encodedPayload)
+			if err != nil {
+				return nil, err
+			}
+
+			general.Signatures = append(general.Signatures, sig)
+		}
+
+		doc = general
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("SignedKeys - failed to marshal JWS to JSON: %w", err)
+	}
+
+	return out, nil
+}
+
+// signJWS signs an encoded payload with a protected header naming the algorithm and the key
+// of the signer. The legacy format only applies to the legacy envelope.
+func signJWS(signer *signer.Signer, typ, encodedPayload string) (jwsSignature, error) {
+	h := jwsHeader{Alg: signer.Algorithm(), Kid: signer.KeyID(), Typ: typ}
+
+	header, err := json.Marshal(h)
+	if err != nil {
+		return jwsSignature{}, fmt.Errorf("SignedKeys - failed to marshal JWS header: %w", err)
+	}
+
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	sig, err := signer.SignJWS([]byte(protected + "." + encodedPayload))
+	if err != nil {
+		return jwsSignature{}, fmt.Errorf("SignedKeys - failed to sign data: %w", err)
+	}
+
+	return jwsSignature{Protected: protected, Signature: sig}, nil
+}
@@ -0,0 +1,288 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/signer"
+)
+
+// writeTestECKey writes a new P-256 private key to a temporary file and returns its path and public key.
+func writeTestECKey(t *testing.T) (string, *ecdsa.PublicKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "ec.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	return path, &key.PublicKey
+}
+
+// setupTestECSigner creates a signer with a P-256 key and returns it with the public key.
+func setupTestECSigner(t *testing.T, opts ...signer.Option) (*signer.Signer, *ecdsa.PublicKey) {
+	t.Helper()
+
+	path, public := writeTestECKey(t)
+
+	s, err := signer.NewSigner(path, append([]signer.Option{signer.WithLegacyFormat(false)}, opts...)...)
+	require.NoError(t, err)
+
+	return s, public
+}
+
+func TestParseEnvelope(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Envelope
+		wantErr bool
+	}{
+		{value: "", want: EnvelopeLegacy},
+		{value: "legacy", want: EnvelopeLegacy},
+		{value: "jws", want: EnvelopeJWS},
+		{value: "jws+json", want: EnvelopeJWSJSON},
+		{value: "cose", want: EnvelopeCOSE},
+		{value: "jwt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseEnvelope(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEnvelope_MediaType(t *testing.T) {
+	assert.Equal(t, "application/json", EnvelopeLegacy.MediaType())
+	assert.Equal(t, "application/jose", EnvelopeJWS.MediaType())
+	assert.Equal(t, "application/jose+json", EnvelopeJWSJSON.MediaType())
+	assert.Equal(t, "application/cose", EnvelopeCOSE.MediaType())
+}
+
+func TestSignedKeysWithEnvelope(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	rsaPath := filepath.Join(t.TempDir(), "rsa.pem")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(rsaPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	rsaSigner, err := signer.NewSigner(rsaPath, signer.WithLegacyFormat(true))
+	require.NoError(t, err)
+
+	ecSigner, ecPublic := setupTestECSigner(t)
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newKeys := func() []DomainKey {
+		return []DomainKey{
+			{Date: &now, DomainName: "example.com", Expire: 2, Fqdn: "b.example.com", Key: "key2"},
+			{Date: &now, DomainName: "example.com", Expire: 1, Fqdn: "a.example.com", Key: "key1", LastError: "err"},
+		}
+	}
+
+	verifyRSA := func(t *testing.T, input, sig []byte) {
+		hashed := sha512.Sum512(input)
+		assert.NoError(t, rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA512, hashed[:], sig))
+	}
+	verifyEC := func(t *testing.T, input, sig []byte) {
+		require.Len(t, sig, 64)
+		hashed := sha256.Sum256(input)
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		assert.True(t, ecdsa.Verify(ecPublic, hashed[:], r, s))
+	}
+
+	tests := []struct {
+		name        string
+		signer      *signer.Signer
+		naming      Naming
+		envelope    Envelope
+		wantHeader  string
+		wantPayload string
+		verify      func(t *testing.T, input, sig []byte)
+	}{
+		{
+			name:        "compact RS512 legacy format",
+			signer:      rsaSigner,
+			naming:      NamingLegacy,
+			envelope:    EnvelopeJWS,
+			wantHeader:  `{"alg":"RS512","kid":"` + rsaSigner.KeyID() + `","typ":"JOSE"}`,
+			wantPayload: `{"keys":[{"date":"2025-01-01T00:00:00Z","domainName":"example.com","expire":1,"fqdn":"a.example.com","key":"key1","last_error":"err"},{"date":"2025-01-01T00:00:00Z","domainName":"example.com","expire":2,"fqdn":"b.example.com","key":"key2"}]}`,
+			verify:      verifyRSA,
+		},
+		{
+			name:        "json ES256 snake case",
+			signer:      ecSigner,
+			naming:      NamingSnake,
+			envelope:    EnvelopeJWSJSON,
+			wantHeader:  `{"alg":"ES256","kid":"` + ecSigner.KeyID() + `","typ":"JOSE+JSON"}`,
+			wantPayload: `{"keys":[{"date":"2025-01-01T00:00:00Z","domain_name":"example.com","expire":1,"fqdn":"a.example.com","key":"key1","last_error":"err"},{"date":"2025-01-01T00:00:00Z","domain_name":"example.com","expire":2,"fqdn":"b.example.com","key":"key2"}]}`,
+			verify:      verifyEC,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := SignedKeysWithEnvelope("test.json", newKeys(), tt.signer, tt.naming, tt.envelope)
+			require.NoError(t, err)
+
+			var protected, payload, signature string
+
+			if tt.envelope == EnvelopeJWS {
+				parts := strings.Split(string(res), ".")
+				require.Len(t, parts, 3)
+				protected, payload, signature = parts[0], parts[1], parts[2]
+			} else {
+				var jws map[string]string
+				require.NoError(t, json.Unmarshal(res, &jws))
+				assert.Len(t, jws, 3)
+				protected, payload, signature = jws["protected"], jws["payload"], jws["signature"]
+			}
+
+			header, err := base64.RawURLEncoding.DecodeString(protected)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantHeader, string(header))
+
+			body, err := base64.RawURLEncoding.DecodeString(payload)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wantPayload, string(body))
+
+			sig, err := base64.RawURLEncoding.DecodeString(signature)
+			require.NoError(t, err)
+			tt.verify(t, []byte(protected+"."+payload), sig)
+		})
+	}
+}
+
+func TestSignedKeysWithEnvelope_Legacy(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	s, _ := setupTestECSigner(t)
+	now := time.Now()
+	keys := []DomainKey{
+		{Date: &now, Fqdn: "a.example.com", Key: "key1"},
+		{Date: &now, Fqdn: "b.example.com", Key: "key2"},
+	}
+
+	res, err := SignedKeysWithEnvelope("test.json", keys, s, NamingLegacy, EnvelopeLegacy)
+	require.NoError(t, err)
+
+	structure, err := ParseFileStructure(res)
+	require.NoError(t, err)
+	assert.Len(t, structure.Payload.Keys, 2)
+	assert.NotEmpty(t, structure.Signature)
+}
+
+func TestSignedKeysWithEnvelope_NoKeys(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	s, _ := setupTestECSigner(t)
+
+	for _, envelope := range []Envelope{EnvelopeJWS, EnvelopeJWSJSON} {
+		res, err := SignedKeysWithEnvelope("test.json", nil, s, NamingLegacy, envelope)
+		assert.NoError(t, err)
+		assert.Nil(t, res)
+	}
+}
+
+func TestSignedKeysWithEnvelope_Rotation(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	newPath, newPublic := writeTestECKey(t)
+	s, oldPublic := setupTestECSigner(t, signer.WithRotation(newPath, time.Now().Add(time.Hour)))
+
+	now := time.Now()
+	keys := []DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: 1, Fqdn: "a.example.com", Key: "key1"},
+	}
+
+	t.Run("json carries both signatures", func(t *testing.T) {
+		res, err := SignedKeysWithEnvelope("test.json", keys, s, NamingLegacy, EnvelopeJWSJSON)
+		require.NoError(t, err)
+
+		var jws jwsGeneralJSON
+		require.NoError(t, json.Unmarshal(res, &jws))
+		require.Len(t, jws.Signatures, 2)
+
+		for i, public := range []*ecdsa.PublicKey{oldPublic, newPublic} {
+			header, err := base64.RawURLEncoding.DecodeString(jws.Signatures[i].Protected)
+			require.NoError(t, err)
+			assert.Contains(t, string(header), `"kid"`)
+
+			sig, err := base64.RawURLEncoding.DecodeString(jws.Signatures[i].Signature)
+			require.NoError(t, err)
+
+			hashed := sha256.Sum256([]byte(jws.Signatures[i].Protected + "." + jws.Payload))
+			r, rs := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			assert.True(t, ecdsa.Verify(public, hashed[:], r, rs))
+		}
+	})
+
+	t.Run("compact carries the primary signature", func(t *testing.T) {
+		res, err := SignedKeysWithEnvelope("test.json", keys, s, NamingLegacy, EnvelopeJWS)
+		require.NoError(t, err)
+		assert.Len(t, strings.Split(string(res), "."), 3)
+	})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"sync"
+	"time"
+)
+
+// Staleness measures the age of keys for the health probes of a storage.
+// The time fetching was paused for maintenance (see Pause) is not counted, so keys fetched
+// before the maintenance do not appear stale while it lasts and keep their age when it ends.
+// Only the latest maintenance is taken into account. A nil Staleness measures the plain age
+// and ignores maintenance.
+type Staleness struct {
+	mu   sync.RWMutex
+	from time.Time
+	to   time.Time
+}
+
+// NewStaleness creates a Staleness without maintenance.
+func NewStaleness() *Staleness {
+	return &Staleness{}
+}
+
+// Pause starts a maintenance at the given time.
+func (s *Staleness) Pause(at time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.from = at
+	s.to = time.Time{}
+}
+
+// Resume ends the maintenance at the given time.
+func (s *Staleness) Resume(at time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.from.IsZero() && s.to.IsZero() {
+		s.to = at
+	}
+}
+
+// Age returns the age at now of a key updated at date, excluding the maintenance that
+// started after date.
+func (s *Staleness) Age(date, now time.Time) time.Duration {
+	age := now.Sub(date)
+
+	if s == nil {
+		return age
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.from.IsZero() || !date.Before(s.from) {
+		return age
+	}
+
+	to := s.to
+	if to.IsZero() || to.After(now) {
+		to = now
+	}
+
+	if to.Before(s.from) {
+		return age
+	}
+
+	return age - to.Sub(s.from)
+}
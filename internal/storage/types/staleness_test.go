@@ -0,0 +1,67 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleness_Age(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	date := start.Add(-time.Minute)
+
+	var nilStaleness *Staleness
+	assert.Equal(t, 2*time.Minute, nilStaleness.Age(date, start.Add(time.Minute)))
+
+	s := NewStaleness()
+	assert.Equal(t, 2*time.Minute, s.Age(date, start.Add(time.Minute)))
+
+	s.Pause(start)
+
+	// the age of keys updated before the maintenance is frozen
+	assert.Equal(t, time.Minute, s.Age(date, start.Add(time.Hour)))
+	// keys updated during the maintenance age normally
+	assert.Equal(t, time.Minute, s.Age(start.Add(time.Minute), start.Add(2*time.Minute)))
+
+	s.Resume(start.Add(time.Hour))
+
+	// the maintenance is not counted once it ended
+	assert.Equal(t, 2*time.Minute, s.Age(date, start.Add(time.Hour+time.Minute)))
+	assert.Equal(t, time.Minute, s.Age(start.Add(time.Hour), start.Add(time.Hour+time.Minute)))
+
+	// resuming twice keeps the end of the maintenance
+	s.Resume(start.Add(2 * time.Hour))
+	assert.Equal(t, 2*time.Minute, s.Age(date, start.Add(time.Hour+time.Minute)))
+}
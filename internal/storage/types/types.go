@@ -32,12 +32,21 @@ POSSIBILITY OF SUCH DAMAGE.
 package types
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"slices"
 	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"ssl-pinning/internal/signer"
 )
@@ -45,22 +54,278 @@ import (
 // DomainKey represents a domain's SSL certificate pinning information.
 // It contains the certificate's public key hash, expiration time, associated domain details,
 // and metadata such as application ID, last update timestamp, and error information.
+// Fields tagged `json:"-"` are configuration or diagnostics and are not published.
 type DomainKey struct {
-	AppID      string     `json:"app_id,omitempty"`
+	AppID string `json:"app_id,omitempty"`
+	// BackupPins are configured public key hashes, e.g. of the next certificate,
+	// published in Pins after the live pins
+	BackupPins []string `json:"-" mapstructure:"backup_pins"`
+	// Cert is the metadata of the certificate of the latest successful fetch
+	Cert *Certificate `json:"-"`
+	// Chain selects the certificates of the chain to pin: leaf, intermediate, root
+	Chain []string `json:"-" mapstructure:"chain"`
+	// ClientCert is the PEM file of a client certificate presented to the domain
+	ClientCert string `json:"-" mapstructure:"client_cert"`
+	// ClientKey is the PEM file of the key of ClientCert
+	ClientKey string `json:"-" mapstructure:"client_key"`
+	// Connect is an address to fetch the certificate from instead of the FQDN (see Address)
+	Connect    string     `json:"-" mapstructure:"connect"`
 	Date       *time.Time `json:"date,omitempty"`
 	DomainName string     `json:"domainName,omitempty"`
-	Expire     int64      `json:"expire,omitempty"`
-	File       string     `json:"file,omitempty"`
-	Fqdn       string     `json:"fqdn,omitempty"`
-	Key        string     `json:"key,omitempty"`
-	LastError  string     `json:"last_error,omitempty"`
+	// ErrorCategory classifies LastError for dashboards, e.g. dns, timeout or revoked
+	ErrorCategory string `json:"error_category,omitempty"`
+	Expire        int64  `json:"expire,omitempty"`
+	File          string `json:"file,omitempty"`
+	Fqdn          string `json:"fqdn,omitempty"`
+	// Hosts are the hosts a wildcard FQDN (see IsWildcard) is expanded into; if empty, the
+	// matching subject alternative names of the certificate of the apex domain
+	Hosts []string `json:"-" mapstructure:"hosts"`
+	// Interval is the certificate fetch interval of the domain
+	Interval time.Duration `json:"-" mapstructure:"interval"`
+	// Key is the public key hash of the certificate, the first of Pins when Chain is set
+	Key       string `json:"key,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	// Pins are the public key hashes of the certificates selected with Chain in chain order
+	Pins []string `json:"pins,omitempty"`
+	// Proxy is the proxy the certificate is fetched through
+	Proxy string `json:"-" mapstructure:"proxy"`
+	// SCTs is the number of known CT logs with a valid SCT of the certificate when CT checks are enabled
+	SCTs int `json:"-"`
+	// ServerName overrides the host name sent as SNI (see SNI)
+	ServerName string `json:"-" mapstructure:"server_name"`
+	// Wildcard is the wildcard FQDN the key was expanded from
+	Wildcard string `json:"-"`
+}
+
+// Certificate is the metadata of the certificate of a domain captured at its latest successful
+// fetch, for debugging and audits. Serial is the hex encoded serial number and SANs are the
+// subject alternative names: DNS names, IP addresses, email addresses and URIs.
+type Certificate struct {
+	Date      time.Time `json:"date"`
+	Fqdn      string    `json:"fqdn"`
+	Issuer    string    `json:"issuer"`
+	NotAfter  time.Time `json:"not_after"`
+	NotBefore time.Time `json:"not_before"`
+	SANs      []string  `json:"sans,omitempty"`
+	Serial    string    `json:"serial"`
+	Subject   string    `json:"subject"`
+}
+
+// WithDefaults returns the key with an empty File defaulting to "{fqdn}.json"
+// and an empty DomainName to "*.{host}", where host is the FQDN without port (see Host),
+// or to the host of a wildcard FQDN.
+func (k DomainKey) WithDefaults() DomainKey {
+	if k.File == "" {
+		k.File = fmt.Sprintf("%s.json", k.Fqdn)
+	}
+
+	if k.DomainName == "" && k.IsWildcard() {
+		k.DomainName = k.Host()
+	}
+
+	if k.DomainName == "" {
+		k.DomainName = fmt.Sprintf("*.%s", k.Host())
+	}
+
+	return k
+}
+
+// wildcardPrefix is the prefix of wildcard FQDNs.
+const wildcardPrefix = "*."
+
+// IsWildcard reports whether the FQDN is a wildcard, e.g. "*.example.com".
+func (k DomainKey) IsWildcard() bool {
+	return strings.HasPrefix(k.Fqdn, wildcardPrefix)
+}
+
+// Apex returns the FQDN of the apex domain of a wildcard FQDN, e.g. "example.com" for
+// "*.example.com", with the port of the wildcard if any.
+func (k DomainKey) Apex() string {
+	return strings.TrimPrefix(k.Fqdn, wildcardPrefix)
+}
+
+// MatchesWildcard reports whether a host name is covered by the wildcard FQDN, that is it has
+// exactly one label in place of the asterisk. Host names are compared case-insensitively.
+func (k DomainKey) MatchesWildcard(host string) bool {
+	if !k.IsWildcard() || strings.Contains(host, "*") {
+		return false
+	}
+
+	label, rest, ok := strings.Cut(strings.ToLower(host), ".")
+
+	return ok && label != "" && rest == strings.ToLower(strings.TrimPrefix(k.Host(), wildcardPrefix))
+}
+
+// JoinPins encodes the pins of a domain key as a comma-separated list for storage backends
+// with flat fields. Pins are base64-encoded and never contain commas.
+func JoinPins(pins []string) string {
+	return strings.Join(pins, ",")
+}
+
+// SplitPins decodes pins encoded with JoinPins. Returns nil for an empty list.
+func SplitPins(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
+
+// defaultPort is the port certificates are fetched from when the FQDN has none.
+const defaultPort = "443"
+
+// Host returns the host name of the FQDN, which may be given as "host:port".
+func (k DomainKey) Host() string {
+	host, _ := k.hostPort()
+	return host
+}
+
+// SNI returns the host name sent as SNI and verified against the certificate of the domain:
+// ServerName if set, e.g. the public name of a domain fetched from an IP or internal load
+// balancer, otherwise the host of the FQDN.
+func (k DomainKey) SNI() string {
+	if k.ServerName != "" {
+		return k.ServerName
+	}
+
+	return k.Host()
+}
+
+// Address returns the "host:port" address the certificate of the domain is fetched from.
+// It is Connect if set, e.g. an internal or jump address, otherwise the FQDN. The port of
+// the FQDN, or 443, is used if the address has none.
+func (k DomainKey) Address() string {
+	host, port := k.hostPort()
+
+	if k.Connect == "" {
+		return net.JoinHostPort(host, port)
+	}
+
+	if _, _, err := net.SplitHostPort(k.Connect); err == nil {
+		return k.Connect
+	}
+
+	return net.JoinHostPort(strings.Trim(k.Connect, "[]"), port)
+}
+
+// hostPort splits the FQDN into host and port, defaulting the port to 443.
+func (k DomainKey) hostPort() (string, string) {
+	host, port, err := net.SplitHostPort(k.Fqdn)
+	if err != nil {
+		return k.Fqdn, defaultPort
+	}
+
+	return host, port
+}
+
+// domainKeyLegacy mirrors DomainKey with the fields of the v1 payload schema selected with
+// NamingLegacy in the legacy envelope, which is kept byte-stable for existing clients:
+// fields added since v1, i.e. ErrorCategory and Pins, are only published with other namings
+// and envelopes.
+type domainKeyLegacy struct {
+	AppID         string        `json:"app_id,omitempty"`
+	BackupPins    []string      `json:"-"`
+	Cert          *Certificate  `json:"-"`
+	Chain         []string      `json:"-"`
+	ClientCert    string        `json:"-"`
+	ClientKey     string        `json:"-"`
+	Connect       string        `json:"-"`
+	Date          *time.Time    `json:"date,omitempty"`
+	DomainName    string        `json:"domainName,omitempty"`
+	ErrorCategory string        `json:"-"`
+	Expire        int64         `json:"expire,omitempty"`
+	File          string        `json:"file,omitempty"`
+	Fqdn          string        `json:"fqdn,omitempty"`
+	Hosts         []string      `json:"-"`
+	Interval      time.Duration `json:"-"`
+	Key           string        `json:"key,omitempty"`
+	LastError     string        `json:"last_error,omitempty"`
+	Pins          []string      `json:"-"`
+	Proxy         string        `json:"-"`
+	SCTs          int           `json:"-"`
+	ServerName    string        `json:"-"`
+	Wildcard      string        `json:"-"`
+}
+
+// domainKeySnake mirrors DomainKey with every field rendered in snake_case.
+// It is used by the v2 payload schema selected with NamingSnake.
+type domainKeySnake struct {
+	AppID         string        `json:"app_id,omitempty"`
+	BackupPins    []string      `json:"-"`
+	Cert          *Certificate  `json:"-"`
+	Chain         []string      `json:"-"`
+	ClientCert    string        `json:"-"`
+	ClientKey     string        `json:"-"`
+	Connect       string        `json:"-"`
+	Date          *time.Time    `json:"date,omitempty"`
+	DomainName    string        `json:"domain_name,omitempty"`
+	ErrorCategory string        `json:"error_category,omitempty"`
+	Expire        int64         `json:"expire,omitempty"`
+	File          string        `json:"file,omitempty"`
+	Fqdn          string        `json:"fqdn,omitempty"`
+	Hosts         []string      `json:"-"`
+	Interval      time.Duration `json:"-"`
+	Key           string        `json:"key,omitempty"`
+	LastError     string        `json:"last_error,omitempty"`
+	Pins          []string      `json:"pins,omitempty"`
+	Proxy         string        `json:"-"`
+	SCTs          int           `json:"-"`
+	ServerName    string        `json:"-"`
+	Wildcard      string        `json:"-"`
+}
+
+// domainKeyCamel mirrors DomainKey with every field rendered in camelCase.
+// It is used by the v2 payload schema selected with NamingCamel.
+type domainKeyCamel struct {
+	AppID         string        `json:"appId,omitempty"`
+	BackupPins    []string      `json:"-"`
+	Cert          *Certificate  `json:"-"`
+	Chain         []string      `json:"-"`
+	ClientCert    string        `json:"-"`
+	ClientKey     string        `json:"-"`
+	Connect       string        `json:"-"`
+	Date          *time.Time    `json:"date,omitempty"`
+	DomainName    string        `json:"domainName,omitempty"`
+	ErrorCategory string        `json:"errorCategory,omitempty"`
+	Expire        int64         `json:"expire,omitempty"`
+	File          string        `json:"file,omitempty"`
+	Fqdn          string        `json:"fqdn,omitempty"`
+	Hosts         []string      `json:"-"`
+	Interval      time.Duration `json:"-"`
+	Key           string        `json:"key,omitempty"`
+	LastError     string        `json:"lastError,omitempty"`
+	Pins          []string      `json:"pins,omitempty"`
+	Proxy         string        `json:"-"`
+	SCTs          int           `json:"-"`
+	ServerName    string        `json:"-"`
+	Wildcard      string        `json:"-"`
 }
 
 // FileStructure represents the JSON file format for signed domain keys.
 // It wraps the payload (keys) along with a cryptographic signature for integrity verification.
+// Alg names the signature algorithm, Kid identifies the signing key (see signer.KeyID) and
+// SignedAt is the signing time, letting clients pick the right public key during rotations
+// and reject stale documents. When SignedAt is set the signature covers the whole structure
+// without the signature fields, otherwise (legacy format) it covers the payload only and Alg
+// is omitted for the default RS512. During a key rotation Signatures holds the signatures of
+// the same content made with the new key. Timestamp is the base64-encoded RFC 3161 timestamp
+// token of the primary signature when a TSA is configured (see signer.Signer.Timestamp); like
+// the signatures it is not covered by the signature.
 type FileStructure struct {
-	Payload   FileKeys `json:"payload,omitempty"`
-	Signature string   `json:"signature,omitempty"`
+	Payload    FileKeys    `json:"payload,omitempty"`
+	Signature  string      `json:"signature,omitempty"`
+	Alg        string      `json:"alg,omitempty"`
+	Kid        string      `json:"kid,omitempty"`
+	SignedAt   *time.Time  `json:"signed_at,omitempty"`
+	Signatures []Signature `json:"signatures,omitempty"`
+	Timestamp  string      `json:"timestamp,omitempty"`
+}
+
+// Signature is an additional signature of a file made with another key, see signer.Signer.Cosigners.
+type Signature struct {
+	Alg       string `json:"alg"`
+	Kid       string `json:"kid"`
+	Signature string `json:"signature"`
 }
 
 // FileKeys contains a collection of domain keys for a specific file.
@@ -68,6 +333,235 @@ type FileKeys struct {
 	Keys []DomainKey `json:"keys,omitempty"`
 }
 
+// FileInfo describes a published pin file.
+// It contains the file name, the number of keys in the file and the time of the latest key update.
+type FileInfo struct {
+	File      string     `json:"file"`
+	Keys      int        `json:"keys"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// FileList is the response body of the files listing endpoint.
+type FileList struct {
+	Files []FileInfo `json:"files"`
+}
+
+// Page describes the page of a paginated v2 API response: the maximum number of items of the
+// page, the total number of items matching the request and the opaque cursor of the next page,
+// empty on the last page.
+type Page struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// FilePage is the response body of the v2 files listing endpoint.
+type FilePage struct {
+	Files []FileInfo `json:"files"`
+	Page  Page       `json:"page"`
+}
+
+// KeyPage is the response body of the v2 file endpoint: the selected page of the keys of a file
+// signed as a file of its own, or no file if no key matches the request.
+type KeyPage struct {
+	File json.RawMessage `json:"file,omitempty"`
+	Page Page            `json:"page"`
+}
+
+// Delta is a change of the keys of a file: the keys added or changed and the FQDNs of the
+// keys removed since a previous version of the file. Cursor is the version of the file after
+// the change (see Version). Reset is set when the previous version is unknown, in which case
+// Changed holds all keys of the file and keys not listed are to be dropped.
+type Delta struct {
+	Changed []DomainKey `json:"changed,omitempty"`
+	Cursor  string      `json:"cursor,omitempty"`
+	File    string      `json:"file"`
+	Removed []string    `json:"removed,omitempty"`
+	Reset   bool        `json:"reset,omitempty"`
+}
+
+// Version returns the version of the pin set of a file: a digest of the FQDN, key and backup
+// pins of its keys, independent of their order, dates and errors, so that every instance
+// derives the same version from the same pins.
+func Version(keys []DomainKey) string {
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, strings.Join([]string{k.Fqdn, k.Key, JoinPins(k.Pins)}, "\x00"))
+	}
+
+	slices.Sort(lines)
+
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+
+	return hex.EncodeToString(sum[:16])
+}
+
+// Diff returns the delta of the keys of a file from previous to current. Keys are matched by FQDN
+// and changed when their key or pins differ (see DomainKey.Pins: the live pins of the selected
+// chain certificates, previous pins and backup pins); dates and errors are not compared.
+// Changed keys are sorted by FQDN, as are removed FQDNs.
+func Diff(file string, previous, current []DomainKey) Delta {
+	delta := Delta{File: file}
+
+	old := make(map[string]DomainKey, len(previous))
+	for _, k := range previous {
+		old[k.Fqdn] = k
+	}
+
+	seen := make(map[string]struct{}, len(current))
+
+	for _, k := range current {
+		seen[k.Fqdn] = struct{}{}
+
+		if o, ok := old[k.Fqdn]; ok && o.Key == k.Key && slices.Equal(o.Pins, k.Pins) {
+			continue
+		}
+
+		delta.Changed = append(delta.Changed, k)
+	}
+
+	for _, k := range previous {
+		if _, ok := seen[k.Fqdn]; !ok {
+			delta.Removed = append(delta.Removed, k.Fqdn)
+		}
+	}
+
+	slices.SortFunc(delta.Changed, func(a, b DomainKey) int {
+		return strings.Compare(a.Fqdn, b.Fqdn)
+	})
+	slices.Sort(delta.Removed)
+
+	return delta
+}
+
+// Empty reports whether the delta has no changes.
+func (d Delta) Empty() bool {
+	return len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Filter returns the delta restricted to the FQDNs, or the delta as is if fqdns is empty.
+func (d Delta) Filter(fqdns []string) Delta {
+	if len(fqdns) == 0 {
+		return d
+	}
+
+	res := Delta{Cursor: d.Cursor, File: d.File, Reset: d.Reset}
+
+	for _, k := range d.Changed {
+		if slices.Contains(fqdns, k.Fqdn) {
+			res.Changed = append(res.Changed, k)
+		}
+	}
+
+	for _, fqdn := range d.Removed {
+		if slices.Contains(fqdns, fqdn) {
+			res.Removed = append(res.Removed, fqdn)
+		}
+	}
+
+	return res
+}
+
+// APIError is an error of the v2 API: the HTTP status, a stable machine readable code,
+// a human readable message and the ID of the failed request if request IDs are assigned.
+type APIError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Status    int    `json:"status"`
+}
+
+// ErrorResponse is the response body of every error of the v2 API.
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// legacyAlgorithm is the signature algorithm of files without an "alg" field.
+const legacyAlgorithm = signer.AlgorithmRS512
+
+// now returns the signing time of files, it is replaced in tests.
+var now = time.Now
+
+// signedFile is the envelope used when marshaling a signed payload of any naming style.
+// For NamingLegacy it produces exactly the same bytes as FileStructure.
+type signedFile struct {
+	Payload    any         `json:"payload,omitempty"`
+	Signature  string      `json:"signature,omitempty"`
+	Alg        string      `json:"alg,omitempty"`
+	Kid        string      `json:"kid,omitempty"`
+	SignedAt   *time.Time  `json:"signed_at,omitempty"`
+	Signatures []Signature `json:"signatures,omitempty"`
+	Timestamp  string      `json:"timestamp,omitempty"`
+}
+
+// Naming defines the JSON field naming style of the published payload.
+type Naming string
+
+const (
+	// NamingLegacy keeps the original v1 field names (app_id, domainName, last_error)
+	NamingLegacy Naming = "legacy"
+	// NamingSnake renders all payload fields in snake_case (v2 schema)
+	NamingSnake Naming = "snake_case"
+	// NamingCamel renders all payload fields in camelCase (v2 schema)
+	NamingCamel Naming = "camelCase"
+)
+
+// ParseNaming converts a configuration or Accept header value into a Naming.
+// An empty value is treated as NamingLegacy so existing deployments keep the v1 output.
+// Returns an error for unknown naming styles.
+func ParseNaming(v string) (Naming, error) {
+	switch Naming(v) {
+	case "", NamingLegacy:
+		return NamingLegacy, nil
+	case NamingSnake, NamingCamel:
+		return Naming(v), nil
+	default:
+		return "", fmt.Errorf("invalid naming: %s", v)
+	}
+}
+
+// payload builds the value marshaled as the "payload" field for the naming style.
+func (n Naming) payload(keys []DomainKey) any {
+	switch n {
+	case NamingSnake:
+		list := make([]domainKeySnake, len(keys))
+		for i, k := range keys {
+			list[i] = domainKeySnake(k)
+		}
+
+		return struct {
+			Keys []domainKeySnake `json:"keys,omitempty"`
+		}{list}
+
+	case NamingCamel:
+		list := make([]domainKeyCamel, len(keys))
+		for i, k := range keys {
+			list[i] = domainKeyCamel(k)
+		}
+
+		return struct {
+			Keys []domainKeyCamel `json:"keys,omitempty"`
+		}{list}
+
+	default:
+		return FileKeys{
+			Keys: keys,
+		}
+	}
+}
+
+// legacyPayload returns the v1 payload of keys in the legacy envelope (see domainKeyLegacy).
+func legacyPayload(keys []DomainKey) any {
+	list := make([]domainKeyLegacy, len(keys))
+	for i, k := range keys {
+		list[i] = domainKeyLegacy(k)
+	}
+
+	return struct {
+		Keys []domainKeyLegacy `json:"keys,omitempty"`
+	}{list}
+}
+
 // StorageType defines the type of storage backend to use.
 type StorageType string
 
@@ -82,19 +576,86 @@ const (
 	StoragePostgres StorageType = "postgres"
 )
 
+// ErrNotFound is returned by storage backends when the requested keys do not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidFile is returned when a file name is not a single plain path element.
+var ErrInvalidFile = errors.New("invalid file name")
+
+// ErrPartialSave is returned by SaveKeys of storage backends that skipped some keys, e.g. of
+// domains that were not fetched yet, and saved the others.
+var ErrPartialSave = errors.New("failed to save some keys")
+
+// DefaultMaxAge is the maximum age of keys considered fresh by the health probes
+// of a storage that has not been configured with WithMaxAge.
+const DefaultMaxAge = 10 * time.Second
+
+// MaxFileSize limits the size of a signed file accepted by ParseFileStructure.
+const MaxFileSize = 16 << 20
+
+// maxFileNameLength is the longest file name accepted by ValidateFile, matching common filesystem limits.
+const maxFileNameLength = 255
+
+// ValidateFile checks that a file name taken from untrusted input (e.g. a URL path parameter)
+// is a single plain path element: non-empty, valid UTF-8, not hidden and without path separators
+// or control characters. It prevents path traversal in file-based storage backends.
+func ValidateFile(file string) error {
+	if file == "" || len(file) > maxFileNameLength || !utf8.ValidString(file) {
+		return fmt.Errorf("%w: %q", ErrInvalidFile, file)
+	}
+
+	if strings.HasPrefix(file, ".") {
+		return fmt.Errorf("%w: %q", ErrInvalidFile, file)
+	}
+
+	for _, r := range file {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return fmt.Errorf("%w: %q", ErrInvalidFile, file)
+		}
+	}
+
+	return nil
+}
+
+// ParseFileStructure decodes a signed file.
+// Returns an error if data exceeds MaxFileSize or is not a valid FileStructure document.
+func ParseFileStructure(data []byte) (FileStructure, error) {
+	var structure FileStructure
+
+	if len(data) > MaxFileSize {
+		return structure, fmt.Errorf("file too large: %d bytes", len(data))
+	}
+
+	if err := json.Unmarshal(data, &structure); err != nil {
+		return structure, fmt.Errorf("failed to unmarshal file: %w", err)
+	}
+
+	return structure, nil
+}
+
 // Storage defines the interface for domain key storage backends.
 // It provides methods for retrieving keys, health checks, persistence, and configuration.
 type Storage interface {
 	// Close releases storage resources and closes connections
 	Close() error
+	// DeleteKeys removes the keys of a FQDN from a file for all application instances
+	DeleteKeys(file, fqdn string) error
 	// GetByFile retrieves domain keys by filename
 	GetByFile(string) ([]DomainKey, []byte, error)
+	// GetByFqdn retrieves the current keys of a FQDN from every file it is published in
+	GetByFqdn(fqdn string) ([]DomainKey, error)
+	// GetCatalog retrieves the pin catalogue managed with the admin API, ErrNotFound if none was saved
+	GetCatalog() ([]byte, error)
+	// ListFiles returns the sorted names of all published files
+	ListFiles() ([]string, error)
 	// ProbeLiveness returns an HTTP handler for liveness probe
 	ProbeLiveness() func(w http.ResponseWriter, r *http.Request)
 	// ProbeReadiness returns an HTTP handler for readiness probe
 	ProbeReadiness() func(w http.ResponseWriter, r *http.Request)
 	// ProbeStartup returns an HTTP handler for startup probe
 	ProbeStartup() func(w http.ResponseWriter, r *http.Request)
+	// SaveCatalog persists the pin catalogue managed with the admin API for all application instances
+	SaveCatalog([]byte) error
 	// SaveKeys persists a map of domain keys to storage
 	SaveKeys(map[string]DomainKey) error
 	// WithAppID sets the application ID for the storage instance
@@ -115,6 +676,26 @@ type Storage interface {
 	WithMaxIdleConns(int)
 	// WithMaxOpenConns sets the maximum number of open connections to the database
 	WithMaxOpenConns(int)
+	// WithMaxAge sets the maximum age of keys considered fresh by the health probes
+	WithMaxAge(time.Duration)
+	// WithStaleness sets how the health probes measure the age of keys
+	WithStaleness(*Staleness)
+}
+
+// ContextBinder is implemented by storage decorators that attach a caller context,
+// e.g. the context of an HTTP request, to the operations they perform.
+type ContextBinder interface {
+	// WithContext returns a storage performing its operations within ctx
+	WithContext(ctx context.Context) Storage
+}
+
+// WithContext binds ctx to s if it implements ContextBinder, otherwise s is returned unchanged.
+func WithContext(ctx context.Context, s Storage) Storage {
+	if b, ok := s.(ContextBinder); ok {
+		return b.WithContext(ctx)
+	}
+
+	return s
 }
 
 // Option is a functional option type for configuring Storage implementations.
@@ -183,16 +764,40 @@ func WithMaxOpenConns(n int) Option {
 	}
 }
 
-// SignedKeys creates a signed JSON structure containing domain keys for a file.
+// WithMaxAge returns an option that sets the maximum age of keys considered fresh by the
+// health probes. Backends fall back to DefaultMaxAge when it is not positive.
+func WithMaxAge(d time.Duration) Option {
+	return func(s Storage) {
+		s.WithMaxAge(d)
+	}
+}
+
+// WithStaleness returns an option that sets how the health probes measure the age of keys,
+// e.g. excluding maintenance (see Staleness). Backends measure the plain age when it is nil.
+func WithStaleness(st *Staleness) Option {
+	return func(s Storage) {
+		s.WithStaleness(st)
+	}
+}
+
+// SignedKeys creates a signed JSON structure containing domain keys for a file
+// using the legacy (v1) field naming. See SignedKeysWithNaming for details.
+func SignedKeys(file string, keys []DomainKey, signer *signer.Signer) ([]byte, error) {
+	return SignedKeysWithNaming(file, keys, signer, NamingLegacy)
+}
+
+// SignedKeysWithNaming creates a signed JSON structure containing domain keys for a file.
 // It performs the following steps:
 //  1. Validates that keys are provided
 //  2. Sorts keys by expiration time (ascending)
-//  3. Marshals keys to indented JSON
-//  4. Signs the JSON using the provided signer
-//  5. Wraps payload and signature into FileStructure
+//  3. Marshals keys to indented JSON using the requested naming style
+//  4. Adds the algorithm, key ID and signing time metadata unless the signer uses the legacy format
+//  5. Signs the payload with its metadata using the provided signer and its cosigners
+//  6. Wraps payload, metadata and signatures into the file envelope
 //
+// In the legacy format only the payload is signed and the algorithm is added for non-RS512 signers.
 // Returns the final JSON bytes or an error if any step fails.
-func SignedKeys(file string, keys []DomainKey, signer *signer.Signer) ([]byte, error) {
+func SignedKeysWithNaming(file string, keys []DomainKey, signer *signer.Signer, naming Naming) ([]byte, error) {
 	if len(keys) < 1 {
 		slog.Warn("SignedKeys - no keys to save", "file", file)
 		return nil, nil
@@ -202,13 +807,46 @@ func SignedKeys(file string, keys []DomainKey, signer *signer.Signer) ([]byte, e
 		return keys[i].Expire < keys[j].Expire
 	})
 
-	payload := FileKeys{
-		Keys: keys,
+	payload := naming.payload(keys)
+	if naming == NamingLegacy {
+		payload = legacyPayload(keys)
+	}
+
+	return signPayload(file, naming, payload, signer)
+}
+
+// SignedDelta creates a signed JSON structure containing a delta of the keys of a file in the
+// legacy envelope, signed like a file (see SignedKeysWithNaming), so clients verify it the same way.
+func SignedDelta(delta Delta, signer *signer.Signer) ([]byte, error) {
+	return signPayload(delta.File, NamingLegacy, delta, signer)
+}
+
+// signPayload signs a payload with its metadata and wraps it into the file envelope,
+// see SignedKeysWithNaming.
+func signPayload(file string, naming Naming, payload any, signer *signer.Signer) ([]byte, error) {
+	envelope := signedFile{
+		Payload: payload,
+	}
+
+	var signed any = envelope.Payload
+
+	if signer.LegacyFormat() {
+		if alg := signer.Algorithm(); alg != legacyAlgorithm {
+			envelope.Alg = alg
+		}
+	} else {
+		signedAt := now().UTC().Truncate(time.Second)
+
+		envelope.Alg = signer.Algorithm()
+		envelope.Kid = signer.KeyID()
+		envelope.SignedAt = &signedAt
+
+		signed = envelope
 	}
 
 	out := []byte{}
 
-	if res, err := json.MarshalIndent(payload, "", "  "); err == nil {
+	if res, err := json.MarshalIndent(signed, "", "  "); err == nil {
 		out = res
 	} else {
 		return nil, fmt.Errorf("SignedKeys - failed to marshal keys to JSON: %w", err)
@@ -222,13 +860,31 @@ func SignedKeys(file string, keys []DomainKey, signer *signer.Signer) ([]byte, e
 	slog.Debug("signature created",
 		"canonical", string(out),
 		"file", file,
+		"naming", naming,
 		"sig", string(sig),
 	)
 
-	if res, err := json.MarshalIndent(FileStructure{
-		Payload:   payload,
-		Signature: string(sig),
-	}, "", "  "); err == nil {
+	envelope.Signature = sig
+
+	// a file without timestamp is better than no file while the TSA is unavailable
+	if envelope.Timestamp, err = signer.Timestamp(sig); err != nil {
+		slog.Error("SignedKeys - failed to timestamp signature", "file", file, "error", err)
+	}
+
+	for _, c := range signer.Cosigners() {
+		sig, err := c.Sign(out)
+		if err != nil {
+			return nil, fmt.Errorf("SignedKeys - failed to cosign data: %w", err)
+		}
+
+		envelope.Signatures = append(envelope.Signatures, Signature{
+			Alg:       c.Algorithm(),
+			Kid:       c.KeyID(),
+			Signature: sig,
+		})
+	}
+
+	if res, err := json.MarshalIndent(envelope, "", "  "); err == nil {
 		out = res
 	} else {
 		return nil, fmt.Errorf("SignedKeys - failed to marshal signed payload to JSON: %w", err)
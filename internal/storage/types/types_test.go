@@ -32,25 +32,37 @@ POSSIBILITY OF SUCH DAMAGE.
 package types
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	logger "gopkg.in/slog-handler.v1"
 
 	"ssl-pinning/internal/signer"
+	"ssl-pinning/pkg/verify"
 )
 
-func setupTestSigner(t *testing.T) *signer.Signer {
+func setupTestSigner(t *testing.T, opts ...signer.Option) *signer.Signer {
 	t.Helper()
 
 	tmpDir := t.TempDir()
@@ -75,13 +87,22 @@ func setupTestSigner(t *testing.T) *signer.Signer {
 	require.NoError(t, err)
 	privKeyFile.Close()
 
-	// Create signer
-	signer, err := signer.NewSigner(privKeyPath)
+	// Create signer, with the metadata enabled unless a test asks for the legacy format
+	signer, err := signer.NewSigner(privKeyPath, append([]signer.Option{signer.WithLegacyFormat(false)}, opts...)...)
 	require.NoError(t, err)
 
 	return signer
 }
 
+// setNow makes files signed during the test carry the signing time ts.
+func setNow(t *testing.T, ts time.Time) {
+	t.Helper()
+
+	orig := now
+	now = func() time.Time { return ts }
+	t.Cleanup(func() { now = orig })
+}
+
 func TestDomainKey_JSON(t *testing.T) {
 	logger.SetGlobalLogger(logger.Options{Null: true})
 
@@ -118,16 +139,19 @@ func TestDomainKey_JSON(t *testing.T) {
 		{
 			name: "domain key with last error",
 			key: DomainKey{
-				DomainName: "example.com",
-				Fqdn:       "www.example.com",
-				Key:        "test-key",
-				LastError:  "connection timeout",
+				DomainName:    "example.com",
+				Fqdn:          "www.example.com",
+				Key:           "test-key",
+				LastError:     "connection timeout",
+				ErrorCategory: "timeout",
 			},
 			validate: func(t *testing.T, data []byte) {
 				var decoded DomainKey
 				err := json.Unmarshal(data, &decoded)
 				require.NoError(t, err)
 				assert.Equal(t, "connection timeout", decoded.LastError)
+				assert.Equal(t, "timeout", decoded.ErrorCategory)
+				assert.Contains(t, string(data), `"error_category":"timeout"`)
 			},
 		},
 		{
@@ -142,6 +166,23 @@ func TestDomainKey_JSON(t *testing.T) {
 				require.NoError(t, err)
 				assert.Equal(t, "www.example.com", decoded.Fqdn)
 				assert.Equal(t, "key", decoded.Key)
+				assert.NotContains(t, string(data), `"pins"`)
+			},
+		},
+		{
+			name: "domain key with chain pins",
+			key: DomainKey{
+				Chain: []string{"leaf", "intermediate"},
+				Fqdn:  "www.example.com",
+				Key:   "leaf-pin",
+				Pins:  []string{"leaf-pin", "ca-pin"},
+			},
+			validate: func(t *testing.T, data []byte) {
+				var decoded DomainKey
+				err := json.Unmarshal(data, &decoded)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"leaf-pin", "ca-pin"}, decoded.Pins)
+				assert.NotContains(t, string(data), `"chain"`)
 			},
 		},
 	}
@@ -159,6 +200,114 @@ func TestDomainKey_JSON(t *testing.T) {
 	}
 }
 
+func TestJoinPins(t *testing.T) {
+	tests := []struct {
+		name    string
+		pins    []string
+		encoded string
+	}{
+		{name: "none", pins: nil, encoded: ""},
+		{name: "single", pins: []string{"a"}, encoded: "a"},
+		{name: "multiple", pins: []string{"a", "b+/="}, encoded: "a,b+/="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.encoded, JoinPins(tt.pins))
+			assert.Equal(t, tt.pins, SplitPins(tt.encoded))
+		})
+	}
+}
+
+func TestDomainKey_WithDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		key  DomainKey
+		want DomainKey
+	}{
+		{
+			name: "defaults",
+			key:  DomainKey{Fqdn: "example.com"},
+			want: DomainKey{Fqdn: "example.com", File: "example.com.json", DomainName: "*.example.com"},
+		},
+		{
+			name: "fqdn with port",
+			key:  DomainKey{Fqdn: "example.com:8443"},
+			want: DomainKey{Fqdn: "example.com:8443", File: "example.com:8443.json", DomainName: "*.example.com"},
+		},
+		{
+			name: "preserve file and domain name",
+			key:  DomainKey{Fqdn: "example.com", File: "pins.json", DomainName: "example.com"},
+			want: DomainKey{Fqdn: "example.com", File: "pins.json", DomainName: "example.com"},
+		},
+		{
+			name: "wildcard",
+			key:  DomainKey{Fqdn: "*.example.com"},
+			want: DomainKey{Fqdn: "*.example.com", File: "*.example.com.json", DomainName: "*.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.key.WithDefaults())
+		})
+	}
+}
+
+func TestDomainKey_Wildcard(t *testing.T) {
+	key := DomainKey{Fqdn: "*.example.com:8443"}
+
+	assert.True(t, key.IsWildcard())
+	assert.False(t, DomainKey{Fqdn: "example.com"}.IsWildcard())
+	assert.Equal(t, "example.com:8443", key.Apex())
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "www.example.com", want: true},
+		{host: "API.Example.com", want: true},
+		{host: "example.com", want: false},
+		{host: "a.b.example.com", want: false},
+		{host: "*.example.com", want: false},
+		{host: "www.example.org", want: false},
+		{host: ".example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.want, key.MatchesWildcard(tt.host))
+		})
+	}
+
+	assert.False(t, DomainKey{Fqdn: "example.com"}.MatchesWildcard("www.example.com"))
+}
+
+func TestDomainKey_AddressAndSNI(t *testing.T) {
+	tests := []struct {
+		name           string
+		key            DomainKey
+		wantAddress    string
+		wantServerName string
+	}{
+		{name: "fqdn", key: DomainKey{Fqdn: "example.com"}, wantAddress: "example.com:443", wantServerName: "example.com"},
+		{name: "fqdn with port", key: DomainKey{Fqdn: "example.com:8443"}, wantAddress: "example.com:8443", wantServerName: "example.com"},
+		{name: "connect", key: DomainKey{Fqdn: "example.com", Connect: "10.0.0.1"}, wantAddress: "10.0.0.1:443", wantServerName: "example.com"},
+		{name: "connect with port of fqdn", key: DomainKey{Fqdn: "example.com:8443", Connect: "10.0.0.1"}, wantAddress: "10.0.0.1:8443", wantServerName: "example.com"},
+		{name: "connect with port", key: DomainKey{Fqdn: "example.com:8443", Connect: "jump.internal:9443"}, wantAddress: "jump.internal:9443", wantServerName: "example.com"},
+		{name: "connect ipv6", key: DomainKey{Fqdn: "example.com", Connect: "::1"}, wantAddress: "[::1]:443", wantServerName: "example.com"},
+		{name: "server name", key: DomainKey{Fqdn: "example.com", Connect: "10.0.0.1", ServerName: "www.example.com"}, wantAddress: "10.0.0.1:443", wantServerName: "www.example.com"},
+		{name: "connect ipv6 with port", key: DomainKey{Fqdn: "example.com", Connect: "[::1]:8443"}, wantAddress: "[::1]:8443", wantServerName: "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantAddress, tt.key.Address())
+			assert.Equal(t, tt.wantServerName, tt.key.SNI())
+		})
+	}
+}
+
 func TestFileStructure_JSON(t *testing.T) {
 	logger.SetGlobalLogger(logger.Options{Null: true})
 
@@ -344,6 +493,29 @@ func TestOption_WithMaxOpenConns(t *testing.T) {
 	assert.Equal(t, 100, mockStorage.maxOpenConns)
 }
 
+func TestOption_WithMaxAge(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	mockStorage := &mockStorageImpl{}
+
+	opt := WithMaxAge(time.Minute)
+	opt(mockStorage)
+
+	assert.Equal(t, time.Minute, mockStorage.maxAge)
+}
+
+func TestOption_WithStaleness(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	mockStorage := &mockStorageImpl{}
+	staleness := NewStaleness()
+
+	opt := WithStaleness(staleness)
+	opt(mockStorage)
+
+	assert.Same(t, staleness, mockStorage.staleness)
+}
+
 func TestSignedKeys(t *testing.T) {
 	logger.SetGlobalLogger(logger.Options{Null: true})
 
@@ -498,6 +670,7 @@ func TestSignedKeys_SignatureVerification(t *testing.T) {
 	expire := now.Add(24 * time.Hour).Unix()
 
 	testSigner := setupTestSigner(t)
+	setNow(t, now)
 
 	keys := []DomainKey{
 		{
@@ -523,6 +696,330 @@ func TestSignedKeys_SignatureVerification(t *testing.T) {
 	assert.Equal(t, struct1.Signature, struct2.Signature)
 }
 
+func TestSignedKeys_BaselineFormat(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "prv.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	// a signer created without options must keep producing the original format
+	defaultSigner, err := signer.NewSigner(keyPath)
+	require.NoError(t, err)
+
+	date := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	keys := []DomainKey{
+		{Date: &date, DomainName: "*.example.com", Expire: 7200, Fqdn: "b.example.com", Key: "key2"},
+		{Date: &date, DomainName: "*.example.com", Expire: 3600, Fqdn: "a.example.com", Key: "key1"},
+	}
+
+	// baselineKey, baselineKeys and baselineFile mirror the file format of the first release
+	type baselineKey struct {
+		Date       *time.Time `json:"date,omitempty"`
+		DomainName string     `json:"domainName,omitempty"`
+		Expire     int64      `json:"expire,omitempty"`
+		Fqdn       string     `json:"fqdn,omitempty"`
+		Key        string     `json:"key,omitempty"`
+	}
+	type baselineKeys struct {
+		Keys []baselineKey `json:"keys,omitempty"`
+	}
+	type baselineFile struct {
+		Payload   baselineKeys `json:"payload,omitempty"`
+		Signature string       `json:"signature,omitempty"`
+	}
+
+	payload := baselineKeys{Keys: []baselineKey{
+		{Date: &date, DomainName: "*.example.com", Expire: 3600, Fqdn: "a.example.com", Key: "key1"},
+		{Date: &date, DomainName: "*.example.com", Expire: 7200, Fqdn: "b.example.com", Key: "key2"},
+	}}
+
+	content, err := json.MarshalIndent(payload, "", "  ")
+	require.NoError(t, err)
+
+	canonical, err := jsoncanonicalizer.Transform(content)
+	require.NoError(t, err)
+
+	hashed := sha512.Sum512(canonical)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA512, hashed[:])
+	require.NoError(t, err)
+
+	want, err := json.MarshalIndent(baselineFile{
+		Payload:   payload,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}, "", "  ")
+	require.NoError(t, err)
+
+	got, err := SignedKeys("test.json", keys, defaultSigner)
+	require.NoError(t, err)
+
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestParseNaming(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Naming
+		wantErr bool
+	}{
+		{name: "empty defaults to legacy", value: "", want: NamingLegacy},
+		{name: "legacy", value: "legacy", want: NamingLegacy},
+		{name: "snake case", value: "snake_case", want: NamingSnake},
+		{name: "camel case", value: "camelCase", want: NamingCamel},
+		{name: "unknown", value: "kebab-case", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNaming(tt.value)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// contextStorage records the context it was bound to.
+type contextStorage struct {
+	mockStorageImpl
+	ctx context.Context
+}
+
+func (s *contextStorage) WithContext(ctx context.Context) Storage {
+	return &contextStorage{ctx: ctx}
+}
+
+func TestWithContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "request")
+
+	plain := &mockStorageImpl{}
+	assert.Same(t, plain, WithContext(ctx, plain))
+
+	bound, ok := WithContext(ctx, &contextStorage{}).(*contextStorage)
+	require.True(t, ok)
+	assert.Equal(t, "request", bound.ctx.Value(key{}))
+}
+
+func TestValidateFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{name: "plain name", file: "example.com.json"},
+		{name: "sandbox name", file: "_sandbox.json"},
+		{name: "unicode name", file: "пример.json"},
+		{name: "empty", file: "", wantErr: true},
+		{name: "parent directory", file: "..", wantErr: true},
+		{name: "current directory", file: ".", wantErr: true},
+		{name: "hidden file", file: ".test.json.tmp-123", wantErr: true},
+		{name: "traversal", file: "../../etc/passwd", wantErr: true},
+		{name: "absolute path", file: "/etc/passwd", wantErr: true},
+		{name: "windows separator", file: "..\\secret.json", wantErr: true},
+		{name: "nul byte", file: "test.json\x00", wantErr: true},
+		{name: "newline", file: "test\n.json", wantErr: true},
+		{name: "invalid utf-8", file: "test\xff.json", wantErr: true},
+		{name: "too long", file: strings.Repeat("a", 256), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFile(tt.file)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidFile)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func FuzzValidateFile(f *testing.F) {
+	for _, seed := range []string{"test.json", "..", "../x", "a/b", "a\\b", ".hidden", "", "x\x00"} {
+		f.Add(seed)
+	}
+
+	const dir = "/var/lib/ssl-pinning"
+
+	f.Fuzz(func(t *testing.T, file string) {
+		if err := ValidateFile(file); err != nil {
+			return
+		}
+
+		// an accepted name must always resolve to a direct child of the directory
+		if got := filepath.Join(dir, file); got != dir+"/"+file {
+			t.Fatalf("ValidateFile(%q) accepted a name resolving to %q", file, got)
+		}
+	})
+}
+
+func TestParseFileStructure(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		wantKeys int
+		wantErr  bool
+	}{
+		{name: "valid", data: []byte(`{"payload":{"keys":[{"fqdn":"example.com","key":"k"}]},"signature":"s"}`), wantKeys: 1},
+		{name: "empty object", data: []byte(`{}`)},
+		{name: "invalid json", data: []byte(`{`), wantErr: true},
+		{name: "wrong type", data: []byte(`{"payload":{"keys":"nope"}}`), wantErr: true},
+		{name: "too large", data: make([]byte, MaxFileSize+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structure, err := ParseFileStructure(tt.data)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, structure.Payload.Keys, tt.wantKeys)
+		})
+	}
+}
+
+func FuzzParseFileStructure(f *testing.F) {
+	f.Add([]byte(`{"payload":{"keys":[{"app_id":"a","date":"2025-01-01T00:00:00Z","domainName":"*.example.com","expire":1,"fqdn":"example.com","key":"k","last_error":""}]},"signature":"s"}`))
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`{"payload":{"keys":[null]}}`))
+	f.Add([]byte(`[]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		structure, err := ParseFileStructure(data)
+		if err != nil {
+			return
+		}
+
+		// a decoded document must survive a marshal/unmarshal round trip unchanged
+		first, err := json.Marshal(structure)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		again, err := ParseFileStructure(first)
+		if err != nil {
+			t.Fatalf("re-parse: %v", err)
+		}
+
+		second, err := json.Marshal(again)
+		if err != nil {
+			t.Fatalf("re-marshal: %v", err)
+		}
+
+		if string(first) != string(second) {
+			t.Fatalf("round trip mismatch:\n%s\n%s", first, second)
+		}
+	})
+}
+
+func TestSignedKeysWithNaming(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testSigner := setupTestSigner(t)
+	setNow(t, now)
+
+	newKeys := func() []DomainKey {
+		return []DomainKey{
+			{
+				AppID:         "app",
+				Date:          &now,
+				DomainName:    "*.example.com",
+				Expire:        3600,
+				Fqdn:          "example.com",
+				Key:           "key1",
+				LastError:     "timeout",
+				ErrorCategory: "timeout",
+				Pins:          []string{"pin1"},
+			},
+		}
+	}
+
+	t.Run("legacy output is byte-stable", func(t *testing.T) {
+		result, err := SignedKeysWithNaming("test.json", newKeys(), testSigner, NamingLegacy)
+		require.NoError(t, err)
+
+		var structure FileStructure
+		require.NoError(t, json.Unmarshal(result, &structure))
+
+		want, err := json.MarshalIndent(structure, "", "  ")
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(result))
+
+		legacy, err := SignedKeys("test.json", newKeys(), testSigner)
+		require.NoError(t, err)
+		assert.Equal(t, string(legacy), string(result))
+	})
+
+	t.Run("legacy output keeps the v1 schema", func(t *testing.T) {
+		result, err := SignedKeysWithNaming("test.json", newKeys(), testSigner, NamingLegacy)
+		require.NoError(t, err)
+		assert.Contains(t, string(result), `"last_error"`)
+		assert.NotContains(t, string(result), `"error_category"`)
+		assert.NotContains(t, string(result), `"pins"`)
+	})
+
+	tests := []struct {
+		name    string
+		naming  Naming
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "snake case",
+			naming:  NamingSnake,
+			want:    []string{`"app_id"`, `"domain_name"`, `"error_category"`, `"last_error"`, `"pins"`},
+			notWant: []string{`"domainName"`, `"appId"`, `"errorCategory"`, `"lastError"`},
+		},
+		{
+			name:    "camel case",
+			naming:  NamingCamel,
+			want:    []string{`"appId"`, `"domainName"`, `"errorCategory"`, `"lastError"`, `"pins"`},
+			notWant: []string{`"app_id"`, `"domain_name"`, `"error_category"`, `"last_error"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SignedKeysWithNaming("test.json", newKeys(), testSigner, tt.naming)
+			require.NoError(t, err)
+
+			for _, v := range tt.want {
+				assert.Contains(t, string(result), v)
+			}
+
+			for _, v := range tt.notWant {
+				assert.NotContains(t, string(result), v)
+			}
+
+			var structure struct {
+				Payload   json.RawMessage `json:"payload"`
+				Signature string          `json:"signature"`
+			}
+			require.NoError(t, json.Unmarshal(result, &structure))
+			assert.NotEmpty(t, structure.Signature)
+		})
+	}
+}
+
 // mockStorageImpl is a mock implementation for testing Option functions
 type mockStorageImpl struct {
 	appID           string
@@ -533,10 +1030,16 @@ type mockStorageImpl struct {
 	connMaxLifetime time.Duration
 	maxIdleConns    int
 	maxOpenConns    int
+	maxAge          time.Duration
+	staleness       *Staleness
 }
 
 func (m *mockStorageImpl) Close() error                                  { return nil }
+func (m *mockStorageImpl) DeleteKeys(string, string) error               { return nil }
 func (m *mockStorageImpl) GetByFile(string) ([]DomainKey, []byte, error) { return nil, nil, nil }
+func (m *mockStorageImpl) GetByFqdn(string) ([]DomainKey, error)         { return nil, nil }
+func (m *mockStorageImpl) GetCatalog() ([]byte, error)                   { return nil, nil }
+func (m *mockStorageImpl) ListFiles() ([]string, error)                  { return nil, nil }
 func (m *mockStorageImpl) ProbeLiveness() func(w http.ResponseWriter, r *http.Request) {
 	return nil
 }
@@ -544,6 +1047,7 @@ func (m *mockStorageImpl) ProbeReadiness() func(w http.ResponseWriter, r *http.R
 	return nil
 }
 func (m *mockStorageImpl) ProbeStartup() func(w http.ResponseWriter, r *http.Request) { return nil }
+func (m *mockStorageImpl) SaveCatalog([]byte) error                                   { return nil }
 func (m *mockStorageImpl) SaveKeys(map[string]DomainKey) error                        { return nil }
 func (m *mockStorageImpl) WithAppID(appID string)                                     { m.appID = appID }
 func (m *mockStorageImpl) WithDSN(dsn string)                                         { m.dsn = dsn }
@@ -553,6 +1057,259 @@ func (m *mockStorageImpl) WithConnMaxIdleTime(d time.Duration)
 func (m *mockStorageImpl) WithConnMaxLifetime(d time.Duration)                        { m.connMaxLifetime = d }
 func (m *mockStorageImpl) WithMaxIdleConns(n int)                                     { m.maxIdleConns = n }
 func (m *mockStorageImpl) WithMaxOpenConns(n int)                                     { m.maxOpenConns = n }
+func (m *mockStorageImpl) WithMaxAge(d time.Duration)                                 { m.maxAge = d }
+func (m *mockStorageImpl) WithStaleness(st *Staleness)                                { m.staleness = st }
+
+func TestSignedKeys_Algorithm(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+
+	ecPath := filepath.Join(t.TempDir(), "ec.pem")
+	require.NoError(t, os.WriteFile(ecPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	ecSigner, err := signer.NewSigner(ecPath, signer.WithLegacyFormat(false))
+	require.NoError(t, err)
+
+	now := time.Now()
+	keys := []DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: 1, Fqdn: "a.example.com", Key: "key1"},
+		{Date: &now, DomainName: "example.com", Expire: 2, Fqdn: "b.example.com", Key: "key2"},
+	}
+
+	tests := []struct {
+		name    string
+		signer  *signer.Signer
+		naming  Naming
+		wantAlg string
+	}{
+		{name: "rsa", signer: setupTestSigner(t), naming: NamingLegacy, wantAlg: "RS512"},
+		{name: "legacy format rsa omits algorithm", signer: setupTestSigner(t, signer.WithLegacyFormat(true)), naming: NamingLegacy},
+		{name: "ecdsa legacy", signer: ecSigner, naming: NamingLegacy, wantAlg: "ES256"},
+		{name: "ecdsa snake case", signer: ecSigner, naming: NamingSnake, wantAlg: "ES256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := SignedKeysWithNaming("test.json", keys, tt.signer, tt.naming)
+			require.NoError(t, err)
+
+			var envelope map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(res, &envelope))
+
+			if tt.wantAlg == "" {
+				assert.NotContains(t, envelope, "alg")
+				return
+			}
+
+			assert.JSONEq(t, `"`+tt.wantAlg+`"`, string(envelope["alg"]))
+
+			structure, err := ParseFileStructure(res)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAlg, structure.Alg)
+		})
+	}
+}
+
+func TestSignedKeys_Metadata(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	signedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	setNow(t, signedAt.Add(500*time.Millisecond))
+
+	keys := []DomainKey{
+		{Date: &signedAt, DomainName: "example.com", Expire: 1, Fqdn: "a.example.com", Key: "key1"},
+	}
+
+	// verify checks the base64 encoded ASN.1 signature over the canonical form of content
+	verify := func(t *testing.T, public *ecdsa.PublicKey, content []byte, signature string) {
+		canonical, err := jsoncanonicalizer.Transform(content)
+		require.NoError(t, err)
+
+		sig, err := base64.StdEncoding.DecodeString(signature)
+		require.NoError(t, err)
+
+		hashed := sha256.Sum256(canonical)
+		assert.True(t, ecdsa.VerifyASN1(public, hashed[:], sig))
+	}
+
+	t.Run("metadata is signed", func(t *testing.T) {
+		ecSigner, ecPublic := setupTestECSigner(t)
+
+		res, err := SignedKeys("test.json", keys, ecSigner)
+		require.NoError(t, err)
+
+		structure, err := ParseFileStructure(res)
+		require.NoError(t, err)
+		assert.Equal(t, "ES256", structure.Alg)
+		assert.Equal(t, ecSigner.KeyID(), structure.Kid)
+		require.NotNil(t, structure.SignedAt)
+		assert.Equal(t, signedAt, *structure.SignedAt)
+
+		var envelope map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(res, &envelope))
+		delete(envelope, "signature")
+
+		content, err := json.Marshal(envelope)
+		require.NoError(t, err)
+		verify(t, ecPublic, content, structure.Signature)
+	})
+
+	t.Run("legacy format signs payload only", func(t *testing.T) {
+		legacySigner, ecPublic := setupTestECSigner(t, signer.WithLegacyFormat(true))
+
+		res, err := SignedKeys("test.json", keys, legacySigner)
+		require.NoError(t, err)
+
+		var envelope map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(res, &envelope))
+		assert.NotContains(t, envelope, "kid")
+		assert.NotContains(t, envelope, "signed_at")
+
+		var signature string
+		require.NoError(t, json.Unmarshal(envelope["signature"], &signature))
+		verify(t, ecPublic, envelope["payload"], signature)
+	})
+}
+
+// stubTimestamper issues tokens made of the timestamped data, or fails with err.
+type stubTimestamper struct {
+	err error
+}
+
+func (s stubTimestamper) Timestamp(data []byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return append([]byte("token:"), data...), nil
+}
+
+func TestSignedKeys_Timestamp(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Now()
+	keys := []DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: 1, Fqdn: "a.example.com", Key: "key1"},
+	}
+
+	t.Run("token of the signature", func(t *testing.T) {
+		s, public := setupTestECSigner(t, signer.WithTimestamper(stubTimestamper{}))
+
+		res, err := SignedKeys("test.json", keys, s)
+		require.NoError(t, err)
+
+		structure, err := ParseFileStructure(res)
+		require.NoError(t, err)
+
+		sig, err := base64.StdEncoding.DecodeString(structure.Signature)
+		require.NoError(t, err)
+
+		token, err := base64.StdEncoding.DecodeString(structure.Timestamp)
+		require.NoError(t, err)
+		assert.Equal(t, append([]byte("token:"), sig...), token)
+
+		// the timestamp is not covered by the signature
+		var envelope map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(res, &envelope))
+		delete(envelope, "signature")
+		delete(envelope, "timestamp")
+
+		content, err := json.Marshal(envelope)
+		require.NoError(t, err)
+		canonical, err := jsoncanonicalizer.Transform(content)
+		require.NoError(t, err)
+
+		hashed := sha256.Sum256(canonical)
+		assert.True(t, ecdsa.VerifyASN1(public, hashed[:], sig))
+	})
+
+	t.Run("tsa failure", func(t *testing.T) {
+		s, _ := setupTestECSigner(t, signer.WithTimestamper(stubTimestamper{err: errors.New("tsa unavailable")}))
+
+		res, err := SignedKeys("test.json", keys, s)
+		require.NoError(t, err)
+
+		structure, err := ParseFileStructure(res)
+		require.NoError(t, err)
+		assert.NotEmpty(t, structure.Signature)
+		assert.Empty(t, structure.Timestamp)
+	})
+}
+
+func TestSignedKeys_Rotation(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	now := time.Now()
+	keys := []DomainKey{
+		{Date: &now, DomainName: "example.com", Expire: 1, Fqdn: "a.example.com", Key: "key1"},
+	}
+
+	tests := []struct {
+		name           string
+		cutover        time.Time
+		legacyFormat   bool
+		wantSignatures int
+	}{
+		{name: "before cutover", cutover: now.Add(time.Hour), wantSignatures: 1},
+		{name: "before cutover legacy format", cutover: now.Add(time.Hour), legacyFormat: true, wantSignatures: 1},
+		{name: "after cutover", cutover: now.Add(-time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newPath, newPublic := writeTestECKey(t)
+			s, oldPublic := setupTestECSigner(t,
+				signer.WithLegacyFormat(tt.legacyFormat),
+				signer.WithRotation(newPath, tt.cutover),
+			)
+
+			res, err := SignedKeys("test.json", keys, s)
+			require.NoError(t, err)
+
+			var envelope map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(res, &envelope))
+
+			structure, err := ParseFileStructure(res)
+			require.NoError(t, err)
+			require.Len(t, structure.Signatures, tt.wantSignatures)
+
+			content := envelope["payload"]
+			if !tt.legacyFormat {
+				delete(envelope, "signature")
+				delete(envelope, "signatures")
+
+				content, err = json.Marshal(envelope)
+				require.NoError(t, err)
+			}
+
+			canonical, err := jsoncanonicalizer.Transform(content)
+			require.NoError(t, err)
+			hashed := sha256.Sum256(canonical)
+
+			verify := func(public *ecdsa.PublicKey, signature string) bool {
+				sig, err := base64.StdEncoding.DecodeString(signature)
+				require.NoError(t, err)
+
+				return ecdsa.VerifyASN1(public, hashed[:], sig)
+			}
+
+			if tt.wantSignatures == 0 {
+				assert.True(t, verify(newPublic, structure.Signature), "primary signature must use the new key")
+				return
+			}
+
+			assert.True(t, verify(oldPublic, structure.Signature), "primary signature must use the old key")
+			assert.Equal(t, "ES256", structure.Signatures[0].Alg)
+			assert.Equal(t, s.Cosigners()[0].KeyID(), structure.Signatures[0].Kid)
+			assert.True(t, verify(newPublic, structure.Signatures[0].Signature), "cosignature must use the new key")
+		})
+	}
+}
 
 func BenchmarkSignedKeys_SingleKey(b *testing.B) {
 	now := time.Now()
@@ -628,3 +1385,119 @@ func BenchmarkDomainKey_Unmarshal(b *testing.B) {
 		_ = json.Unmarshal(data, &key)
 	}
 }
+
+func BenchmarkSignedKeys_LargeKeySet(b *testing.B) {
+	now := time.Now()
+	expire := now.Add(24 * time.Hour).Unix()
+
+	testSigner := setupTestSigner(&testing.T{})
+
+	keys := make([]DomainKey, 1000)
+	for i := range keys {
+		keys[i] = DomainKey{
+			Date:       &now,
+			DomainName: "*.example.com",
+			Expire:     expire,
+			Fqdn:       fmt.Sprintf("host-%04d.example.com", i),
+			Key:        fmt.Sprintf("%044d", i),
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = SignedKeys("large.json", keys, testSigner)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	previous := []DomainKey{
+		{Date: &now, Fqdn: "a.example.com", Key: "key1", Pins: []string{"key1", "backup"}},
+		{Date: &now, Fqdn: "b.example.com", Key: "key2"},
+		{Date: &now, Fqdn: "c.example.com", Key: "key3"},
+	}
+
+	current := []DomainKey{
+		{Date: &later, Fqdn: "d.example.com", Key: "key4"},
+		{Date: &later, Fqdn: "b.example.com", Key: "key5"},
+		{Date: &later, Fqdn: "a.example.com", Key: "key1", Pins: []string{"key1", "backup"}, LastError: "timeout"},
+	}
+
+	delta := Diff("app.json", previous, current)
+
+	assert.Equal(t, "app.json", delta.File)
+	assert.Equal(t, []DomainKey{current[1], current[0]}, delta.Changed)
+	assert.Equal(t, []string{"c.example.com"}, delta.Removed)
+	assert.False(t, delta.Empty())
+
+	assert.True(t, Diff("app.json", current, current).Empty())
+	assert.Equal(t, []string{"a.example.com", "b.example.com", "c.example.com"}, Diff("app.json", previous, nil).Removed)
+}
+
+func TestDelta_Filter(t *testing.T) {
+	delta := Delta{
+		Changed: []DomainKey{{Fqdn: "a.example.com"}, {Fqdn: "b.example.com"}},
+		File:    "app.json",
+		Removed: []string{"c.example.com", "d.example.com"},
+	}
+
+	assert.Equal(t, delta, delta.Filter(nil))
+
+	filtered := delta.Filter([]string{"b.example.com", "c.example.com"})
+	assert.Equal(t, "app.json", filtered.File)
+	assert.Equal(t, []DomainKey{{Fqdn: "b.example.com"}}, filtered.Changed)
+	assert.Equal(t, []string{"c.example.com"}, filtered.Removed)
+
+	assert.True(t, delta.Filter([]string{"e.example.com"}).Empty())
+}
+
+func TestSignedDelta(t *testing.T) {
+	testSigner := setupTestSigner(t)
+
+	delta := Delta{
+		Changed: []DomainKey{{Expire: 3600, Fqdn: "a.example.com", Key: "key1"}},
+		File:    "app.json",
+		Removed: []string{"b.example.com"},
+	}
+
+	data, err := SignedDelta(delta, testSigner)
+	require.NoError(t, err)
+
+	var doc struct {
+		Payload Delta `json:"payload"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, delta, doc.Payload)
+
+	verifier, err := verify.New(testSigner.PublicKeys()...)
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(data)
+	assert.NoError(t, err)
+}
+
+func TestVersion(t *testing.T) {
+	now := time.Now()
+
+	keys := []DomainKey{
+		{Date: &now, Fqdn: "a.example.com", Key: "key1", Pins: []string{"key1", "backup"}},
+		{Date: &now, Fqdn: "b.example.com", Key: "key2"},
+	}
+
+	reordered := []DomainKey{
+		{Fqdn: "b.example.com", Key: "key2", LastError: "timeout"},
+		{Fqdn: "a.example.com", Key: "key1", Pins: []string{"key1", "backup"}},
+	}
+
+	changed := []DomainKey{
+		{Fqdn: "a.example.com", Key: "key1"},
+		{Fqdn: "b.example.com", Key: "key2"},
+	}
+
+	assert.Len(t, Version(keys), 32)
+	assert.Equal(t, Version(keys), Version(reordered))
+	assert.NotEqual(t, Version(keys), Version(changed))
+}
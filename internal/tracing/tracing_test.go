@@ -0,0 +1,223 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/config"
+)
+
+// setupRecorder installs a tracer provider recording all spans in memory for the duration of the test.
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	return recorder
+}
+
+func TestSetup(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	tests := []struct {
+		name    string
+		cfg     config.ConfigTracing
+		wantErr bool
+	}{
+		{
+			name: "disabled",
+			cfg:  config.ConfigTracing{},
+		},
+		{
+			name: "enabled with endpoint",
+			cfg: config.ConfigTracing{
+				Enabled:     true,
+				Endpoint:    "http://127.0.0.1:4318",
+				Insecure:    true,
+				SampleRatio: 1,
+			},
+		},
+		{
+			name: "enabled with invalid endpoint",
+			cfg: config.ConfigTracing{
+				Enabled:  true,
+				Endpoint: "://invalid",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := otel.GetTracerProvider()
+			t.Cleanup(func() {
+				if otel.GetTracerProvider() != prev {
+					otel.SetTracerProvider(prev)
+				}
+			})
+
+			shutdown, err := Setup(context.Background(), tt.cfg)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, shutdown)
+
+			assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+
+			_, isSDK := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+			assert.Equal(t, tt.cfg.Enabled, isSDK)
+
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	_, err := Setup(context.Background(), config.ConfigTracing{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		path        string
+		traceparent string
+		status      int
+		wantName    string
+		wantParent  bool
+		wantError   bool
+	}{
+		{
+			name:     "names span after route",
+			path:     "/api/v1/test.json",
+			status:   http.StatusOK,
+			wantName: "GET /api/v1/{file}",
+		},
+		{
+			name:        "continues incoming trace",
+			path:        "/api/v1/test.json",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			status:      http.StatusOK,
+			wantName:    "GET /api/v1/{file}",
+			wantParent:  true,
+		},
+		{
+			name:      "marks server errors",
+			path:      "/api/v1/test.json",
+			status:    http.StatusInternalServerError,
+			wantName:  "GET /api/v1/{file}",
+			wantError: true,
+		},
+		{
+			name:     "unrouted request keeps method name",
+			path:     "/unknown",
+			status:   http.StatusNotFound,
+			wantName: "GET",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := setupRecorder(t)
+
+			var handlerSpan trace.SpanContext
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/v1/{file}", func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(tt.status)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			w := httptest.NewRecorder()
+
+			Handler(mux).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+
+			span := spans[0]
+			assert.Equal(t, tt.wantName, span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", tt.status))
+
+			if tt.status != http.StatusNotFound {
+				assert.Equal(t, span.SpanContext().SpanID(), handlerSpan.SpanID(), "handler must run within the span")
+			}
+
+			if tt.wantParent {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+				assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+			} else {
+				assert.False(t, span.Parent().IsValid())
+			}
+
+			if tt.wantError {
+				assert.Equal(t, codes.Error, span.Status().Code)
+			} else {
+				assert.NotEqual(t, codes.Error, span.Status().Code)
+			}
+		})
+	}
+}
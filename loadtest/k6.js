@@ -0,0 +1,40 @@
+// k6 load test of the public pin file endpoint.
+//
+//   k6 run -e BASE_URL=http://127.0.0.1:7500 -e FILES=example.com.json loadtest/k6.js
+//
+// The run fails when the published performance budget (docs/performance.md) is exceeded.
+
+import http from "k6/http";
+import { check } from "k6";
+
+const baseURL = __ENV.BASE_URL || "http://127.0.0.1:7500";
+const files = (__ENV.FILES || "_sandbox.json").split(",");
+const p99 = __ENV.P99_MS || "100";
+
+export const options = {
+  scenarios: {
+    files: {
+      executor: "constant-arrival-rate",
+      rate: Number(__ENV.RATE || 200),
+      timeUnit: "1s",
+      duration: __ENV.DURATION || "1m",
+      preAllocatedVUs: Number(__ENV.VUS || 50),
+      maxVUs: Number(__ENV.MAX_VUS || 500),
+    },
+  },
+  thresholds: {
+    http_req_duration: [`p(99)<${p99}`],
+    http_req_failed: ["rate<0.001"],
+    checks: ["rate>0.999"],
+  },
+};
+
+export default function () {
+  const file = files[Math.floor(Math.random() * files.length)];
+  const res = http.get(`${baseURL}/api/v1/${file}`, { tags: { name: "/api/v1/{file}" } });
+
+  check(res, {
+    "status is 200": (r) => r.status === 200,
+    "payload is signed": (r) => r.json("signature") !== undefined,
+  });
+}
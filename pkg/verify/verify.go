@@ -0,0 +1,331 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package verify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+)
+
+// Signature algorithms of signed files, named after their JWA (RFC 7518) identifiers.
+const (
+	AlgorithmRS512 = "RS512"
+	AlgorithmES256 = "ES256"
+	AlgorithmES384 = "ES384"
+)
+
+// legacyAlgorithm is the signature algorithm of files without an "alg" field.
+const legacyAlgorithm = AlgorithmRS512
+
+var (
+	// ErrMalformed is returned for documents that are not signed files.
+	ErrMalformed = errors.New("malformed signed file")
+	// ErrUnknownKey is returned when no signature of a file is made with a trusted key.
+	ErrUnknownKey = errors.New("no signature made with a trusted key")
+	// ErrInvalidSignature is returned when a signature made with a trusted key does not match the file.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Verification is the result of a successful verification: the signature that matched a
+// trusted key and the signed metadata of the file.
+type Verification struct {
+	Valid    bool            `json:"valid"`
+	Alg      string          `json:"alg,omitempty"`
+	Kid      string          `json:"kid,omitempty"`
+	SignedAt *time.Time      `json:"signed_at,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Payload  json.RawMessage `json:"-"`
+}
+
+// signature is a signature of a signed file, the primary one or an entry of "signatures".
+type signature struct {
+	Alg       string `json:"alg"`
+	Kid       string `json:"kid"`
+	Signature string `json:"signature"`
+}
+
+// signedFile is the part of a signed file needed for verification.
+type signedFile struct {
+	Payload    json.RawMessage `json:"payload"`
+	Signature  string          `json:"signature"`
+	Alg        string          `json:"alg"`
+	Kid        string          `json:"kid"`
+	SignedAt   *time.Time      `json:"signed_at"`
+	Signatures []signature     `json:"signatures"`
+}
+
+// Verifier verifies signed files with a set of trusted public keys.
+type Verifier struct {
+	keys map[string]crypto.PublicKey
+}
+
+// New returns a verifier trusting the given RSA and ECDSA (P-256, P-384) public keys.
+// Returns an error if a key is of an unsupported type.
+func New(keys ...crypto.PublicKey) (*Verifier, error) {
+	v := &Verifier{
+		keys: make(map[string]crypto.PublicKey, len(keys)),
+	}
+
+	for _, key := range keys {
+		if _, err := algorithm(key); err != nil {
+			return nil, err
+		}
+
+		kid, err := KeyID(key)
+		if err != nil {
+			return nil, err
+		}
+
+		v.keys[kid] = key
+	}
+
+	return v, nil
+}
+
+// ParsePublicKeys parses the PEM-encoded ("PUBLIC KEY") public keys in data, e.g. pub.pem.
+// Returns an error if data contains no public key or a key cannot be parsed.
+func ParsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no PEM-encoded public key found")
+	}
+
+	return keys, nil
+}
+
+// KeyID returns the key identifier ("kid") of a public key: the base64-encoded SHA-256 hash
+// of its DER-encoded SubjectPublicKeyInfo, the same fingerprint format used for pins.
+func KeyID(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	hash := sha256.Sum256(der)
+
+	return base64.StdEncoding.EncodeToString(hash[:]), nil
+}
+
+// Canonicalize returns the RFC 8785 (JCS) canonical form of a JSON document, the form that
+// is hashed and signed.
+func Canonicalize(data []byte) ([]byte, error) {
+	canonical, err := jsoncanonicalizer.Transform(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize JSON: %w", err)
+	}
+
+	return canonical, nil
+}
+
+// SignedContent returns the canonical content covered by the signatures of a signed file.
+// Files with "kid" or "signed_at" metadata sign the whole document without "signature",
+// "signatures" and "timestamp"; legacy files sign "payload" only.
+func SignedContent(data []byte) ([]byte, error) {
+	var file signedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	return signedContent(data, file)
+}
+
+// signedContent returns the canonical signed content of the decoded file data.
+func signedContent(data []byte, file signedFile) ([]byte, error) {
+	if len(file.Payload) == 0 {
+		return nil, fmt.Errorf("%w: missing payload", ErrMalformed)
+	}
+
+	if file.Kid == "" && file.SignedAt == nil {
+		return Canonicalize(file.Payload)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	delete(doc, "signature")
+	delete(doc, "signatures")
+	delete(doc, "timestamp")
+
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	return Canonicalize(content)
+}
+
+// Verify verifies a signed file in the legacy envelope ({"payload": …, "signature": …}).
+// The file is valid if its primary signature or one of its additional signatures (see key
+// rotation) is made with a trusted key. Signatures with a "kid" are only checked against the
+// key with that identifier, signatures without one against every trusted key.
+// Returns ErrMalformed, ErrUnknownKey or ErrInvalidSignature if the file is not valid.
+func (v *Verifier) Verify(data []byte) (Verification, error) {
+	var file signedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Verification{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	if file.Signature == "" {
+		return Verification{}, fmt.Errorf("%w: missing signature", ErrMalformed)
+	}
+
+	content, err := signedContent(data, file)
+	if err != nil {
+		return Verification{}, err
+	}
+
+	primary := signature{Alg: file.Alg, Kid: file.Kid, Signature: file.Signature}
+	if primary.Alg == "" {
+		primary.Alg = legacyAlgorithm
+	}
+
+	verifyErr := ErrUnknownKey
+
+	for _, sig := range append([]signature{primary}, file.Signatures...) {
+		for kid, key := range v.keys {
+			if sig.Kid != "" && sig.Kid != kid {
+				continue
+			}
+
+			if err := verifySignature(key, sig.Alg, content, sig.Signature); err != nil {
+				verifyErr = err
+				continue
+			}
+
+			return Verification{
+				Valid:    true,
+				Alg:      sig.Alg,
+				Kid:      kid,
+				SignedAt: file.SignedAt,
+				Payload:  file.Payload,
+			}, nil
+		}
+	}
+
+	return Verification{}, verifyErr
+}
+
+// algorithm returns the signature algorithm of a public key.
+func algorithm(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return AlgorithmRS512, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return AlgorithmES256, nil
+		case elliptic.P384():
+			return AlgorithmES384, nil
+		}
+
+		return "", fmt.Errorf("unsupported ECDSA curve %s, use P-256 or P-384", k.Curve.Params().Name)
+	}
+
+	return "", fmt.Errorf("unsupported key type %T, use RSA or ECDSA", key)
+}
+
+// verifySignature verifies the base64-encoded signature of content made with key and alg.
+func verifySignature(key crypto.PublicKey, alg string, content []byte, sig string) error {
+	keyAlg, err := algorithm(key)
+	if err != nil {
+		return err
+	}
+
+	if alg != keyAlg {
+		return fmt.Errorf("%w: algorithm %q does not match key algorithm %q", ErrInvalidSignature, alg, keyAlg)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode signature: %w", ErrInvalidSignature, err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		digest := sha512.Sum512(content)
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA512, digest[:], decoded); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+	case *ecdsa.PublicKey:
+		var digest []byte
+
+		if alg == AlgorithmES256 {
+			sum := sha256.Sum256(content)
+			digest = sum[:]
+		} else {
+			sum := sha512.Sum384(content)
+			digest = sum[:]
+		}
+
+		if !ecdsa.VerifyASN1(k, digest, decoded) {
+			return ErrInvalidSignature
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,336 @@
+/*
+Copyright © 2025 Denis Khalturin
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice,
+   this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software
+   without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+// prettier-ignore-end
+package verify_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	logger "gopkg.in/slog-handler.v1"
+
+	"ssl-pinning/internal/signer"
+	"ssl-pinning/internal/storage/types"
+	"ssl-pinning/pkg/verify"
+)
+
+// testKeys returns the keys of a signed test file.
+func testKeys() []types.DomainKey {
+	return []types.DomainKey{
+		{DomainName: "*.example.com", Expire: 1767225600, Fqdn: "example.com", Key: "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+		{DomainName: "*.example.org", Expire: 1767139200, Fqdn: "example.org", Key: "Ln+IuvLeJUntwAsX0xMgj9kMtc4dw4+QwXaR1w1geWE="},
+	}
+}
+
+// signFile signs the test keys with key and returns the signed file.
+func signFile(t *testing.T, key crypto.Signer, opts ...signer.Option) []byte {
+	t.Helper()
+
+	logger.SetGlobalLogger(logger.Options{Null: true})
+
+	// the metadata is enabled unless a test asks for the legacy format
+	s, err := signer.NewKeySigner(key, append([]signer.Option{signer.WithLegacyFormat(false)}, opts...)...)
+	require.NoError(t, err)
+
+	data, err := types.SignedKeys("example.json", testKeys(), s)
+	require.NoError(t, err)
+
+	return data
+}
+
+// writePEMKey writes the PKCS8 encoding of key to a temporary file.
+func writePEMKey(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "prv.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	return path
+}
+
+// modify decodes a signed file, applies fn and encodes it again.
+func modify(t *testing.T, data []byte, fn func(doc map[string]any)) []byte {
+	t.Helper()
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	fn(doc)
+
+	out, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	return out
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	rotated := signFile(t, ecKey, signer.WithRotation(writePEMKey(t, otherKey), time.Now().Add(time.Hour)))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		trusted []crypto.PublicKey
+		wantAlg string
+		wantKey crypto.PublicKey
+		wantErr error
+	}{
+		{
+			name:    "metadata rsa",
+			data:    signFile(t, rsaKey),
+			trusted: []crypto.PublicKey{rsaKey.Public()},
+			wantAlg: verify.AlgorithmRS512,
+			wantKey: rsaKey.Public(),
+		},
+		{
+			name:    "metadata ecdsa",
+			data:    signFile(t, ecKey),
+			trusted: []crypto.PublicKey{rsaKey.Public(), ecKey.Public()},
+			wantAlg: verify.AlgorithmES256,
+			wantKey: ecKey.Public(),
+		},
+		{
+			name:    "legacy rsa",
+			data:    signFile(t, rsaKey, signer.WithLegacyFormat(true)),
+			trusted: []crypto.PublicKey{rsaKey.Public()},
+			wantAlg: verify.AlgorithmRS512,
+			wantKey: rsaKey.Public(),
+		},
+		{
+			name:    "legacy ecdsa",
+			data:    signFile(t, ecKey, signer.WithLegacyFormat(true)),
+			trusted: []crypto.PublicKey{ecKey.Public()},
+			wantAlg: verify.AlgorithmES256,
+			wantKey: ecKey.Public(),
+		},
+		{
+			name:    "rotation with new key only",
+			data:    rotated,
+			trusted: []crypto.PublicKey{otherKey.Public()},
+			wantAlg: verify.AlgorithmES384,
+			wantKey: otherKey.Public(),
+		},
+		{
+			name:    "untrusted key",
+			data:    signFile(t, ecKey),
+			trusted: []crypto.PublicKey{otherKey.Public()},
+			wantErr: verify.ErrUnknownKey,
+		},
+		{
+			name: "tampered payload",
+			data: modify(t, signFile(t, ecKey), func(doc map[string]any) {
+				keys := doc["payload"].(map[string]any)["keys"].([]any)
+				keys[0].(map[string]any)["key"] = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+			}),
+			trusted: []crypto.PublicKey{ecKey.Public()},
+			wantErr: verify.ErrInvalidSignature,
+		},
+		{
+			name: "tampered signing time",
+			data: modify(t, signFile(t, ecKey), func(doc map[string]any) {
+				doc["signed_at"] = "2000-01-01T00:00:00Z"
+			}),
+			trusted: []crypto.PublicKey{ecKey.Public()},
+			wantErr: verify.ErrInvalidSignature,
+		},
+		{
+			name:    "reformatted",
+			data:    modify(t, signFile(t, ecKey), func(map[string]any) {}),
+			trusted: []crypto.PublicKey{ecKey.Public()},
+			wantAlg: verify.AlgorithmES256,
+			wantKey: ecKey.Public(),
+		},
+		{
+			name:    "not json",
+			data:    []byte("signature"),
+			trusted: []crypto.PublicKey{ecKey.Public()},
+			wantErr: verify.ErrMalformed,
+		},
+		{
+			name:    "missing signature",
+			data:    []byte(`{"payload":{"keys":[]}}`),
+			trusted: []crypto.PublicKey{ecKey.Public()},
+			wantErr: verify.ErrMalformed,
+		},
+		{
+			name:    "missing payload",
+			data:    []byte(`{"signature":"c2ln"}`),
+			trusted: []crypto.PublicKey{ecKey.Public()},
+			wantErr: verify.ErrMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := verify.New(tt.trusted...)
+			require.NoError(t, err)
+
+			res, err := v.Verify(tt.data)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.False(t, res.Valid)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, res.Valid)
+			assert.Equal(t, tt.wantAlg, res.Alg)
+
+			wantKid, err := verify.KeyID(tt.wantKey)
+			require.NoError(t, err)
+			assert.Equal(t, wantKid, res.Kid)
+
+			var payload types.FileKeys
+			require.NoError(t, json.Unmarshal(res.Payload, &payload))
+			assert.Len(t, payload.Keys, 2)
+		})
+	}
+}
+
+func TestNew_UnsupportedKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = verify.New(key.Public())
+	assert.ErrorContains(t, err, "unsupported ECDSA curve P-521")
+}
+
+func TestParsePublicKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	encode := func(key crypto.PublicKey) string {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		require.NoError(t, err)
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		want    []crypto.PublicKey
+		wantErr string
+	}{
+		{name: "single", data: encode(rsaKey.Public()), want: []crypto.PublicKey{rsaKey.Public()}},
+		{
+			name: "bundle",
+			data: encode(rsaKey.Public()) + "\n" + encode(ecKey.Public()),
+			want: []crypto.PublicKey{rsaKey.Public(), ecKey.Public()},
+		},
+		{name: "empty", data: "", wantErr: "no PEM-encoded public key found"},
+		{
+			name:    "invalid",
+			data:    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("invalid")})),
+			wantErr: "failed to parse public key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := verify.ParsePublicKeys([]byte(tt.data))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, keys)
+		})
+	}
+}
+
+func TestSignedContent(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "legacy",
+			data: `{"payload": {"keys": [{"fqdn": "example.com", "expire": 1}]}, "signature": "c2ln"}`,
+			want: `{"keys":[{"expire":1,"fqdn":"example.com"}]}`,
+		},
+		{
+			name: "metadata",
+			data: `{"payload": {"keys": []}, "signature": "c2ln", "alg": "ES256", "kid": "a2lk", "signed_at": "2026-01-01T00:00:00Z", "signatures": []}`,
+			want: `{"alg":"ES256","kid":"a2lk","payload":{"keys":[]},"signed_at":"2026-01-01T00:00:00Z"}`,
+		},
+		{
+			name: "timestamp",
+			data: `{"payload": {"keys": []}, "signature": "c2ln", "kid": "a2lk", "timestamp": "dG9rZW4="}`,
+			want: `{"kid":"a2lk","payload":{"keys":[]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verify.SignedContent([]byte(tt.data))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	got, err := verify.Canonicalize([]byte(`{"b": 2, "a": [1.0, "x"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[1,"x"],"b":2}`, string(got))
+
+	_, err = verify.Canonicalize([]byte(`{`))
+	assert.ErrorContains(t, err, "failed to canonicalize JSON")
+}